@@ -7,11 +7,14 @@
 package main
 
 import (
+	"context"
 	"os"
 	_ "walletapp/docs" // docs is generated by Swag CLI, you have to import it.
 	"walletapp/internal/db"
 	"walletapp/internal/handlers"
 	"walletapp/internal/logger"
+	"walletapp/internal/middleware"
+	"walletapp/internal/repositories"
 	"walletapp/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -35,23 +38,103 @@ func main() {
 
 	// Connect to database
 	log.Info("Connecting to database")
-	db.Connect()
+	dbConfig, err := db.ConfigFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid database configuration")
+	}
+	if err := db.ConnectPools(context.Background(), dbConfig); err != nil {
+		log.WithError(err).Fatal("Unable to connect to database")
+	}
 	log.Info("Database connection established")
 
+	queryTimeout, err := repositories.QueryTimeoutFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid query timeout")
+	}
+	repositories.SetQueryTimeout(queryTimeout)
+
+	// Create the repositories on the pool, reading from the replica where there is one
+	users := repositories.NewUserRepositoryWithReplica(db.GetPool(), db.GetReadPool())
+	wallets := repositories.NewWalletRepositoryWithReplica(db.GetPool(), db.GetReadPool())
+	transactions := repositories.NewTransactionRepositoryWithReplica(db.GetPool(), db.GetReadPool())
+	idempotencyRecords := repositories.NewIdempotencyRepository(db.GetPool())
+	passwordResets := repositories.NewPasswordResetRepository(db.GetPool())
+	handlers.SetRepositories(users, wallets, transactions)
+
 	// Create service implementations
 	log.Debug("Initializing services")
-	walletRepo := services.NewWalletRepoImpl()
-	transactionRepo := services.NewTransactionRepoImpl()
+	walletRepo := services.NewWalletRepoImpl(wallets, users)
+	transactionRepo := services.NewTransactionRepoImpl(transactions)
 	dbImpl := services.NewDBImpl()
 
+	walletConfig, err := services.WalletServiceConfigFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid wallet service configuration")
+	}
+
 	// Create and wire up the wallet service
-	walletService := services.NewWalletService(walletRepo, transactionRepo, dbImpl)
+	walletService := services.NewWalletService(walletRepo, transactionRepo, dbImpl, walletConfig)
 
 	// Set the default service for legacy function compatibility
 	services.SetDefaultService(walletService)
+	services.SetDefaultUserService(services.NewUserService(users, walletService))
+	// Emails are only logged until a mail provider is connected
+	emailVerification := services.NewEmailVerificationService(services.NewEmailVerificationRepoImpl(), services.LogMailer{}, dbImpl)
+	services.SetDefaultEmailVerificationService(emailVerification)
+	services.SetDefaultRegistrationService(services.NewRegistrationService(services.NewUserRepoImpl(), walletService, dbImpl, emailVerification))
+	services.SetDefaultPasswordResetService(services.NewPasswordResetService(services.NewPasswordResetRepoImpl(users, passwordResets), services.LogMailer{}, dbImpl))
+
+	scheduleConfig, err := services.ScheduledTransferConfigFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid scheduled transfer configuration")
+	}
+	scheduledTransfers := services.NewScheduledTransferService(services.NewScheduledTransferRepoImpl(), walletService, dbImpl, scheduleConfig)
+	services.SetDefaultScheduledTransferService(scheduledTransfers)
+
+	paymentRequestConfig, err := services.PaymentRequestConfigFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid payment request configuration")
+	}
+	paymentRequests := services.NewPaymentRequestService(services.NewPaymentRequestRepoImpl(), walletService, dbImpl, paymentRequestConfig)
+	services.SetDefaultPaymentRequestService(paymentRequests)
+	services.SetDefaultBeneficiaryService(services.NewBeneficiaryService(services.NewBeneficiaryRepoImpl()))
+
+	archiveConfig, err := services.ArchiveConfigFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid transaction archive configuration")
+	}
+	archive := services.NewArchiveService(services.NewArchiveRepoImpl(transactions), dbImpl, archiveConfig)
+	services.SetDefaultArchiveService(archive)
 	log.Info("Services initialized successfully")
 
-	router := gin.Default()
+	// Execute scheduled transfers, expire payment requests, accrue interest, make the
+	// upcoming transaction partitions, archive old transactions and purge expired
+	// idempotency records in the background for the lifetime of the server
+	go scheduledTransfers.RunWorker(context.Background())
+	go paymentRequests.RunWorker(context.Background())
+	go walletService.RunInterestWorker(context.Background())
+	go walletService.RunPartitionWorker(context.Background())
+	go archive.RunWorker(context.Background())
+	go middleware.RunIdempotencyPurge(context.Background(), idempotencyRecords)
+
+	requestTimeout, err := middleware.RequestTimeoutFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid request timeout")
+	}
+	maxBodySize, err := handlers.MaxBodySizeFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid maximum body size")
+	}
+	handlers.SetMaxBodySize(maxBodySize)
+	slowRequestThreshold, err := middleware.SlowRequestThresholdFromEnv()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid slow request threshold")
+	}
+
+	// gin's own request log and recovery are replaced by the structured access log and
+	// a recovery that answers in JSON
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.RequestLogger(), middleware.AccessLog(slowRequestThreshold), middleware.Recovery())
 
 	// Redirect home page to Swagger UI
 	router.GET("/", func(c *gin.Context) {
@@ -63,23 +146,89 @@ func main() {
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok", "message": "wallet service is running"})
+		c.JSON(200, gin.H{"status": "ok", "message": "wallet service is running", "database": db.Stats(), "panics_recovered": middleware.RecoveredPanics()})
 	})
 
+	// Exports stream for as long as the client keeps reading, so they are left out of
+	// the request timeout
+	router.GET("/api/v1/wallets/:user_id/transactions/export", handlers.ExportTransactions)
+
+	// Deposits, withdrawals and transfers replay their response to retries sent with the
+	// same Idempotency-Key header
+	idempotent := middleware.Idempotency(idempotencyRecords, maxBodySize)
+
 	// Grouped routes
-	api := router.Group("/api")
+	api := router.Group("/api", middleware.Timeout(requestTimeout))
 	{
 		// User
 		api.GET("v1/users", handlers.GetUsers)
 		api.GET("v1/users/:id", handlers.GetUserByID)
 		api.POST("v1/users", handlers.CreateUser)
+		api.PATCH("v1/users/:id", handlers.UpdateUser)
+		api.DELETE("v1/users/:id", handlers.DeleteUser)
+		api.POST("v1/users/:id/password", handlers.ChangePassword)
+		api.POST("v1/users/:id/verification-email", handlers.ResendVerificationEmail)
+		api.POST("v1/auth/forgot-password", handlers.ForgotPassword)
+		api.POST("v1/auth/reset-password", handlers.ResetPasswordWithToken)
+		api.POST("v1/auth/verify-email", handlers.VerifyEmail)
+		api.GET("v1/users/:id/referrals", handlers.GetReferrals)
+		api.GET("v1/users/:id/wallets", handlers.ListWallets)
+		api.POST("v1/users/:id/wallets", handlers.CreateWallet)
+		api.POST("v1/users/:id/wallets/move", handlers.MoveBetweenWallets)
+		api.POST("v1/users/:id/exchange", handlers.ExchangeCurrency)
+		api.GET("v1/users/:id/wallets/:wallet_id", handlers.GetUserWallet)
+		api.GET("v1/users/:id/payment-requests", handlers.GetPaymentRequests)
+		api.POST("v1/users/:id/payment-requests/:request_id/accept", handlers.AcceptPaymentRequest)
+		api.POST("v1/users/:id/payment-requests/:request_id/decline", handlers.DeclinePaymentRequest)
+		api.POST("v1/payment-requests", handlers.CreatePaymentRequest)
+		api.POST("v1/users/:id/beneficiaries", handlers.CreateBeneficiary)
+		api.GET("v1/users/:id/beneficiaries", handlers.GetBeneficiaries)
+		api.DELETE("v1/users/:id/beneficiaries/:beneficiary_id", handlers.DeleteBeneficiary)
+		api.POST("v1/users/:id/wallets/:wallet_id/deposit", idempotent, handlers.DepositToWallet)
+		api.POST("v1/users/:id/wallets/:wallet_id/withdraw", idempotent, handlers.WithdrawFromWallet)
 
 		// Wallet & Transaction
-		api.POST("v1/wallets/:user_id/deposit", handlers.Deposit)
-		api.POST("v1/wallets/:user_id/withdraw", handlers.Withdraw)
+		api.POST("v1/wallets/:user_id/deposit", idempotent, handlers.Deposit)
+		api.POST("v1/wallets/:user_id/deposits/external", handlers.DepositExternal)
+		api.POST("v1/wallets/:user_id/withdraw", idempotent, handlers.Withdraw)
 		api.GET("v1/wallets/:user_id/balance", handlers.GetBalance)
-		api.POST("v1/wallets/transfer", handlers.Transfer)
+		api.DELETE("v1/wallets/:user_id", handlers.CloseWallet)
+		api.POST("v1/wallets/transfer", idempotent, handlers.Transfer)
+		api.POST("v1/wallets/transfers/batch", handlers.BatchTransfer)
+		api.POST("v1/wallets/transfers/intents", handlers.CreateTransferIntent)
+		api.POST("v1/wallets/transfers/intents/:id/confirm", handlers.ConfirmTransferIntent)
+		api.POST("v1/wallets/transfers/schedule", handlers.ScheduleTransfer)
 		api.GET("v1/wallets/:user_id/transactions", handlers.GetTransactionHistory)
+		api.GET("v1/wallets/:user_id/summary", handlers.GetWalletSummary)
+		api.GET("v1/wallets/:user_id/stats", handlers.GetWalletStats)
+		api.GET("v1/wallets/:user_id/statements/:year/:month", handlers.GetWalletStatement)
+		api.GET("v1/transactions/:id", handlers.GetTransaction)
+		api.GET("v1/wallets/:user_id/scheduled-transfers", handlers.GetScheduledTransfers)
+		api.POST("v1/wallets/:user_id/standing-orders", handlers.CreateStandingOrder)
+		api.GET("v1/wallets/:user_id/standing-orders", handlers.GetStandingOrders)
+		api.GET("v1/wallets/:user_id/standing-orders/:id", handlers.GetStandingOrder)
+		api.DELETE("v1/wallets/:user_id/standing-orders/:id", handlers.CancelStandingOrder)
+		api.POST("v1/wallets/:user_id/holds", handlers.CreateHold)
+		api.POST("v1/holds/:id/capture", handlers.CaptureHold)
+		api.POST("v1/holds/:id/release", handlers.ReleaseHold)
+
+		// Admin
+		admin := api.Group("v1/admin", middleware.AdminAuth())
+		admin.GET("reconciliation", handlers.GetReconciliation)
+		admin.GET("risk-flags", handlers.GetRiskFlags)
+		admin.PUT("wallets/:user_id/overdraft", handlers.SetOverdraftLimit)
+		admin.PUT("users/:user_id/kyc-tier", handlers.SetKycTier)
+		admin.POST("users/:user_id/restore", handlers.RestoreUser)
+		admin.POST("users/:user_id/password", handlers.ResetUserPassword)
+		admin.PUT("users/:user_id/wallets/:wallet_id/interest-rate", handlers.SetInterestRate)
+		admin.POST("interest/accrue", handlers.AccrueInterest)
+		admin.POST("transactions/archive", handlers.StartTransactionArchive)
+		admin.GET("transactions/archive", handlers.GetTransactionArchive)
+		admin.POST("wallets/:user_id/adjustments", handlers.CreateAdjustment)
+		admin.POST("wallets/:user_id/bonus", handlers.GrantBonus)
+		admin.POST("wallets/:user_id/freeze", handlers.FreezeWallet)
+		admin.POST("wallets/:user_id/unfreeze", handlers.UnfreezeWallet)
+		admin.POST("transfers/:transfer_id/reverse", handlers.ReverseTransfer)
 	}
 
 	log.Info("Server starting on port 8080")
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultConnectRetries is how many times Connect retries reaching the database when
+// DATABASE_CONNECT_RETRIES is not set
+const DefaultConnectRetries = 5
+
+// DefaultConnectRetryInterval is how long Connect waits before its first retry; the
+// wait doubles with every retry up to maxConnectRetryInterval
+const DefaultConnectRetryInterval = time.Second
+
+// DefaultConnectTimeout is how long Connect keeps trying when DATABASE_CONNECT_TIMEOUT
+// is not set
+const DefaultConnectTimeout = 30 * time.Second
+
+// maxConnectRetryInterval caps the wait between two attempts of Connect
+const maxConnectRetryInterval = 10 * time.Second
+
+// Config holds what Connect needs to reach a database
+type Config struct {
+	// URL is the database to connect to
+	URL string
+	// ReplicaURL is the read replica ConnectPools connects to, if any
+	ReplicaURL string
+	Pool       PoolConfig
+	Tracing    TracerConfig
+	// ConnectRetries is how many times an unreachable database is retried; zero tries
+	// it once
+	ConnectRetries int
+	// ConnectRetryInterval is the wait before the first retry, doubling after each;
+	// zero falls back to DefaultConnectRetryInterval
+	ConnectRetryInterval time.Duration
+	// ConnectTimeout bounds all attempts together; zero leaves them bounded only by
+	// the context
+	ConnectTimeout time.Duration
+}
+
+// ConfigFromEnv reads the database from DATABASE_URL and DATABASE_REPLICA_URL, the
+// settings of PoolConfigFromEnv and TracerConfigFromEnv, and how Connect retries from
+// DATABASE_CONNECT_RETRIES, DATABASE_CONNECT_RETRY_INTERVAL and DATABASE_CONNECT_TIMEOUT.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		URL:                  os.Getenv("DATABASE_URL"),
+		ReplicaURL:           os.Getenv("DATABASE_REPLICA_URL"),
+		ConnectRetries:       DefaultConnectRetries,
+		ConnectRetryInterval: DefaultConnectRetryInterval,
+		ConnectTimeout:       DefaultConnectTimeout,
+	}
+	if config.URL == "" {
+		return Config{}, errors.New("DATABASE_URL is not set")
+	}
+
+	var err error
+	if config.Pool, err = PoolConfigFromEnv(); err != nil {
+		return Config{}, err
+	}
+	if config.Tracing, err = TracerConfigFromEnv(); err != nil {
+		return Config{}, err
+	}
+
+	if raw := os.Getenv("DATABASE_CONNECT_RETRIES"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid DATABASE_CONNECT_RETRIES %q: must be a non-negative integer", raw)
+		}
+		config.ConnectRetries = n
+	}
+	if raw := os.Getenv("DATABASE_CONNECT_RETRY_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid DATABASE_CONNECT_RETRY_INTERVAL %q: must be a positive duration", raw)
+		}
+		config.ConnectRetryInterval = d
+	}
+	if raw := os.Getenv("DATABASE_CONNECT_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid DATABASE_CONNECT_TIMEOUT %q: must be a duration of zero or more", raw)
+		}
+		config.ConnectTimeout = d
+	}
+	return config, nil
+}
+
+// queryExecModes maps the values DATABASE_QUERY_EXEC_MODE accepts to pgx's modes
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// PoolConfig holds the pool settings read from the environment. Zero values keep
+// pgx's defaults.
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	// QueryExecMode is how queries are sent; "simple_protocol" or "exec" work behind
+	// PgBouncer in transaction pooling mode, which cannot keep prepared statements
+	QueryExecMode *pgx.QueryExecMode
+}
+
+// PoolConfigFromEnv reads the pool settings from DATABASE_MAX_CONNS and
+// DATABASE_MIN_CONNS (connection counts), DATABASE_MAX_CONN_LIFETIME and
+// DATABASE_MAX_CONN_IDLE_TIME (durations such as "30m") and DATABASE_QUERY_EXEC_MODE
+// (cache_statement, cache_describe, describe_exec, exec or simple_protocol).
+func PoolConfigFromEnv() (PoolConfig, error) {
+	var config PoolConfig
+	for _, v := range []struct {
+		name   string
+		target *int32
+		min    int64
+	}{
+		{"DATABASE_MAX_CONNS", &config.MaxConns, 1},
+		{"DATABASE_MIN_CONNS", &config.MinConns, 0},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || n < v.min {
+			return PoolConfig{}, fmt.Errorf("invalid %s %q: must be an integer of at least %d", v.name, raw, v.min)
+		}
+		*v.target = int32(n)
+	}
+	if config.MaxConns > 0 && config.MinConns > config.MaxConns {
+		return PoolConfig{}, fmt.Errorf("DATABASE_MIN_CONNS %d exceeds DATABASE_MAX_CONNS %d", config.MinConns, config.MaxConns)
+	}
+
+	for _, v := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"DATABASE_MAX_CONN_LIFETIME", &config.MaxConnLifetime},
+		{"DATABASE_MAX_CONN_IDLE_TIME", &config.MaxConnIdleTime},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return PoolConfig{}, fmt.Errorf("invalid %s %q: must be a positive duration", v.name, raw)
+		}
+		*v.target = d
+	}
+
+	if raw := os.Getenv("DATABASE_QUERY_EXEC_MODE"); raw != "" {
+		mode, ok := queryExecModes[raw]
+		if !ok {
+			return PoolConfig{}, fmt.Errorf("invalid DATABASE_QUERY_EXEC_MODE %q: must be cache_statement, cache_describe, describe_exec, exec or simple_protocol", raw)
+		}
+		config.QueryExecMode = &mode
+	}
+	return config, nil
+}
+
+// apply sets the non-zero settings of c on config
+func (c PoolConfig) apply(config *pgxpool.Config) error {
+	if c.MaxConns > 0 {
+		config.MaxConns = c.MaxConns
+	}
+	if c.MinConns > 0 {
+		config.MinConns = c.MinConns
+	}
+	if config.MinConns > config.MaxConns {
+		return fmt.Errorf("DATABASE_MIN_CONNS %d exceeds the pool's %d max connections", config.MinConns, config.MaxConns)
+	}
+	if c.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = c.MaxConnLifetime
+	}
+	if c.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = c.MaxConnIdleTime
+	}
+	if c.QueryExecMode != nil {
+		config.ConnConfig.DefaultQueryExecMode = *c.QueryExecMode
+	}
+	return nil
+}
+
+// PoolStats is a snapshot of the primary pool's connections
+type PoolStats struct {
+	MaxConns             int32 `json:"max_conns"`
+	TotalConns           int32 `json:"total_conns"`
+	AcquiredConns        int32 `json:"acquired_conns"`
+	IdleConns            int32 `json:"idle_conns"`
+	ConstructingConns    int32 `json:"constructing_conns"`
+	AcquireCount         int64 `json:"acquire_count"`
+	EmptyAcquireCount    int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount int64 `json:"canceled_acquire_count"`
+	// AcquireDurationMs is the total time spent waiting for connections
+	AcquireDurationMs int64 `json:"acquire_duration_ms"`
+}
+
+// Stats reports the primary pool's connections, or nil before Connect
+func Stats() *PoolStats {
+	if DB == nil {
+		return nil
+	}
+	return poolStats(DB.Stat())
+}
+
+func poolStats(stat *pgxpool.Stat) *PoolStats {
+	return &PoolStats{
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDurationMs:    stat.AcquireDuration().Milliseconds(),
+	}
+}
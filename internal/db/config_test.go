@@ -0,0 +1,157 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	simple := pgx.QueryExecModeSimpleProtocol
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    PoolConfig
+		wantErr bool
+	}{
+		{name: "defaults", env: map[string]string{}},
+		{
+			name: "all settings",
+			env: map[string]string{
+				"DATABASE_MAX_CONNS":          "20",
+				"DATABASE_MIN_CONNS":          "2",
+				"DATABASE_MAX_CONN_LIFETIME":  "1h",
+				"DATABASE_MAX_CONN_IDLE_TIME": "30m",
+				"DATABASE_QUERY_EXEC_MODE":    "simple_protocol",
+			},
+			want: PoolConfig{
+				MaxConns:        20,
+				MinConns:        2,
+				MaxConnLifetime: time.Hour,
+				MaxConnIdleTime: 30 * time.Minute,
+				QueryExecMode:   &simple,
+			},
+		},
+		{name: "zero max conns", env: map[string]string{"DATABASE_MAX_CONNS": "0"}, wantErr: true},
+		{name: "negative min conns", env: map[string]string{"DATABASE_MIN_CONNS": "-1"}, wantErr: true},
+		{name: "non-numeric max conns", env: map[string]string{"DATABASE_MAX_CONNS": "many"}, wantErr: true},
+		{name: "min above max", env: map[string]string{"DATABASE_MAX_CONNS": "4", "DATABASE_MIN_CONNS": "5"}, wantErr: true},
+		{name: "invalid lifetime", env: map[string]string{"DATABASE_MAX_CONN_LIFETIME": "forever"}, wantErr: true},
+		{name: "non-positive idle time", env: map[string]string{"DATABASE_MAX_CONN_IDLE_TIME": "0s"}, wantErr: true},
+		{name: "unknown exec mode", env: map[string]string{"DATABASE_QUERY_EXEC_MODE": "pipelined"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DATABASE_MAX_CONNS", "DATABASE_MIN_CONNS", "DATABASE_MAX_CONN_LIFETIME", "DATABASE_MAX_CONN_IDLE_TIME", "DATABASE_QUERY_EXEC_MODE"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			config, err := PoolConfigFromEnv()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, config)
+		})
+	}
+}
+
+func TestPoolConfig_Apply(t *testing.T) {
+	config, err := pgxpool.ParseConfig("postgres://wallet@primary.invalid:5432/walletdb")
+	assert.NoError(t, err)
+	lifetime := config.MaxConnLifetime
+	simple := pgx.QueryExecModeSimpleProtocol
+
+	err = PoolConfig{MaxConns: 20, MinConns: 2, MaxConnIdleTime: time.Minute, QueryExecMode: &simple}.apply(config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(20), config.MaxConns)
+	assert.Equal(t, int32(2), config.MinConns)
+	assert.Equal(t, time.Minute, config.MaxConnIdleTime)
+	assert.Equal(t, lifetime, config.MaxConnLifetime)
+	assert.Equal(t, pgx.QueryExecModeSimpleProtocol, config.ConnConfig.DefaultQueryExecMode)
+}
+
+func TestPoolConfig_ApplyRejectsMinAboveURLMax(t *testing.T) {
+	config, err := pgxpool.ParseConfig("postgres://wallet@primary.invalid:5432/walletdb?pool_max_conns=2")
+	assert.NoError(t, err)
+
+	err = PoolConfig{MinConns: 3}.apply(config)
+
+	assert.Error(t, err)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://wallet@primary.invalid:5432/walletdb")
+	t.Setenv("DATABASE_REPLICA_URL", "postgres://wallet@replica.invalid:5432/walletdb")
+	t.Setenv("DATABASE_MAX_CONNS", "7")
+
+	config, err := ConfigFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://wallet@replica.invalid:5432/walletdb", config.ReplicaURL)
+	assert.Equal(t, int32(7), config.Pool.MaxConns)
+	assert.Equal(t, DefaultConnectRetries, config.ConnectRetries)
+	assert.Equal(t, DefaultConnectRetryInterval, config.ConnectRetryInterval)
+	assert.Equal(t, DefaultConnectTimeout, config.ConnectTimeout)
+
+	t.Setenv("DATABASE_CONNECT_RETRIES", "0")
+	t.Setenv("DATABASE_CONNECT_RETRY_INTERVAL", "250ms")
+	t.Setenv("DATABASE_CONNECT_TIMEOUT", "0")
+	config, err = ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, config.ConnectRetries)
+	assert.Equal(t, 250*time.Millisecond, config.ConnectRetryInterval)
+	assert.Equal(t, time.Duration(0), config.ConnectTimeout)
+}
+
+func TestConfigFromEnv_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		raw  string
+	}{
+		{"missing URL", "DATABASE_URL", ""},
+		{"negative retries", "DATABASE_CONNECT_RETRIES", "-1"},
+		{"non-numeric retries", "DATABASE_CONNECT_RETRIES", "forever"},
+		{"zero retry interval", "DATABASE_CONNECT_RETRY_INTERVAL", "0s"},
+		{"negative connect timeout", "DATABASE_CONNECT_TIMEOUT", "-5s"},
+		{"invalid pool setting", "DATABASE_MAX_CONNS", "0"},
+		{"invalid tracer setting", "DATABASE_QUERY_LOGGING", "sometimes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", "postgres://wallet@primary.invalid:5432/walletdb")
+			t.Setenv(tt.env, tt.raw)
+
+			_, err := ConfigFromEnv()
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Cleanup(func() { DB, ReadDB = nil, nil })
+	DB = nil
+
+	assert.Nil(t, Stats())
+
+	pool, err := NewPool(Config{URL: "postgres://wallet@primary.invalid:5432/walletdb", Pool: PoolConfig{MaxConns: 7}})
+	assert.NoError(t, err)
+	defer pool.Close()
+	DB = pool
+
+	stats := Stats()
+	assert.NotNil(t, stats)
+	assert.Equal(t, int32(7), stats.MaxConns)
+	assert.Equal(t, int32(0), stats.AcquiredConns)
+}
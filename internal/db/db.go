@@ -2,19 +2,119 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"os"
+	"time"
+	"walletapp/internal/logger"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
 )
 
+// DB is the pool on the primary, which every write and transaction runs on
 var DB *pgxpool.Pool
 
-func Connect() {
-	dsn := os.Getenv("DATABASE_URL")
-	var err error
-	DB, err = pgxpool.New(context.Background(), dsn)
+// ReadDB is the pool read-only queries run on: the replica at DATABASE_REPLICA_URL, or
+// DB itself when no replica is configured
+var ReadDB *pgxpool.Pool
+
+// Connect creates a pool on cfg.URL and pings the database until it answers, waiting
+// between attempts with a doubling backoff. It gives up after cfg.ConnectRetries
+// retries, past cfg.ConnectTimeout or when ctx is done, so a database that is still
+// starting up is waited for while one that is down fails startup in bounded time.
+func Connect(ctx context.Context, cfg Config) (*pgxpool.Pool, error) {
+	pool, err := NewPool(cfg)
 	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+	}
+	interval := cfg.ConnectRetryInterval
+	if interval <= 0 {
+		interval = DefaultConnectRetryInterval
+	}
+
+	host := pool.Config().ConnConfig.Host
+	for attempt := 1; ; attempt++ {
+		err = pool.Ping(ctx)
+		if err == nil {
+			return pool, nil
+		}
+		if attempt > cfg.ConnectRetries || ctx.Err() != nil {
+			pool.Close()
+			return nil, fmt.Errorf("database at %s not reachable after %d attempts: %w", host, attempt, err)
+		}
+
+		logger.FromContext(ctx).WithFields(logrus.Fields{
+			"host":        host,
+			"attempt":     attempt,
+			"retry_in_ms": interval.Milliseconds(),
+			"error":       err.Error(),
+		}).Warn("Database not reachable, retrying")
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		interval = min(2*interval, maxConnectRetryInterval)
+	}
+}
+
+// NewPool creates a pool on cfg.URL with cfg's pool and tracer settings. The pool only
+// opens connections once it is used; Connect also checks the database answers.
+func NewPool(cfg Config) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	if err := cfg.Pool.apply(config); err != nil {
+		return nil, err
+	}
+	if !cfg.Tracing.Disabled {
+		config.ConnConfig.Tracer = NewQueryTracer(cfg.Tracing)
+	}
+	return pgxpool.NewWithConfig(context.Background(), config)
+}
+
+// ConnectPools connects the primary at cfg.URL and, when cfg.ReplicaURL is set, the
+// replica, and makes them the pools GetPool and GetReadPool return
+func ConnectPools(ctx context.Context, cfg Config) error {
+	primary, err := Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	replica := primary
+	if cfg.ReplicaURL != "" {
+		replicaCfg := cfg
+		replicaCfg.URL = cfg.ReplicaURL
+		replica, err = Connect(ctx, replicaCfg)
+		if err != nil {
+			primary.Close()
+			return fmt.Errorf("read replica: %w", err)
+		}
+	}
+
+	DB, ReadDB = primary, replica
+	return nil
+}
+
+// ConnectFromEnv connects DB and ReadDB with the settings of ConfigFromEnv, exiting
+// the process when it cannot.
+//
+// Deprecated: use ConfigFromEnv and ConnectPools, which return the error instead.
+func ConnectFromEnv() {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v\n", err)
+	}
+	if err := ConnectPools(context.Background(), cfg); err != nil {
 		log.Fatalf("Unable to connect to database: %v\n", err)
 	}
 }
@@ -22,3 +122,12 @@ func Connect() {
 func GetPool() *pgxpool.Pool {
 	return DB
 }
+
+// GetReadPool returns the pool for read-only queries, which is the primary's when no
+// replica is configured. Queries in a transaction always belong on GetPool.
+func GetReadPool() *pgxpool.Pool {
+	if ReadDB == nil {
+		return DB
+	}
+	return ReadDB
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletapp/internal/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// unreachableURL points at a port nothing listens on, so every attempt fails at once
+const unreachableURL = "postgres://wallet@127.0.0.1:1/walletdb?connect_timeout=1"
+
+// Pools only connect when first used, so the NewPool tests need no database
+
+func TestNewPool_AppliesSettings(t *testing.T) {
+	pool, err := NewPool(Config{URL: "postgres://wallet@primary.invalid:5432/walletdb", Pool: PoolConfig{MaxConns: 7}})
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	assert.Equal(t, "primary.invalid", pool.Config().ConnConfig.Host)
+	assert.Equal(t, int32(7), pool.Config().MaxConns)
+	assert.NotNil(t, pool.Config().ConnConfig.Tracer)
+}
+
+func TestNewPool_InvalidURL(t *testing.T) {
+	_, err := NewPool(Config{URL: "postgres://wallet@primary.invalid:notaport/walletdb"})
+	assert.ErrorContains(t, err, "invalid database URL")
+}
+
+func TestGetReadPool_FallsBackToPrimary(t *testing.T) {
+	primary, err := NewPool(Config{URL: "postgres://wallet@primary.invalid:5432/walletdb"})
+	assert.NoError(t, err)
+	defer primary.Close()
+	t.Cleanup(func() { DB, ReadDB = nil, nil })
+
+	DB, ReadDB = primary, nil
+
+	assert.Same(t, primary, GetReadPool())
+}
+
+func TestConnect_RetriesWithBackoff(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	ctx := logger.NewContext(context.Background(), logrus.NewEntry(log))
+
+	start := time.Now()
+	pool, err := Connect(ctx, Config{URL: unreachableURL, ConnectRetries: 2, ConnectRetryInterval: 20 * time.Millisecond})
+
+	assert.Nil(t, pool)
+	assert.ErrorContains(t, err, "not reachable after 3 attempts")
+	// Waits of 20ms and 40ms between the three attempts
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+	if assert.Len(t, hook.AllEntries(), 2) {
+		assert.Equal(t, int64(20), hook.AllEntries()[0].Data["retry_in_ms"])
+		assert.Equal(t, int64(40), hook.AllEntries()[1].Data["retry_in_ms"])
+	}
+}
+
+func TestConnect_StopsAtConnectTimeout(t *testing.T) {
+	log, _ := test.NewNullLogger()
+	ctx := logger.NewContext(context.Background(), logrus.NewEntry(log))
+
+	start := time.Now()
+	_, err := Connect(ctx, Config{URL: unreachableURL, ConnectRetries: 100, ConnectRetryInterval: time.Second, ConnectTimeout: 100 * time.Millisecond})
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestConnect_StopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Connect(ctx, Config{URL: unreachableURL, ConnectRetries: 100})
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConnectPools_LeavesPoolsUnsetOnFailure(t *testing.T) {
+	t.Cleanup(func() { DB, ReadDB = nil, nil })
+
+	err := ConnectPools(context.Background(), Config{URL: unreachableURL})
+
+	assert.Error(t, err)
+	assert.Nil(t, GetPool())
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"walletapp/internal/logger"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSlowQueryThreshold is how long a query may run before it is logged as slow
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// maxLoggedSQLLength caps the SQL logged for a query
+const maxLoggedSQLLength = 1000
+
+// TracerConfig holds the settings of the query tracer
+type TracerConfig struct {
+	// Disabled leaves queries untraced
+	Disabled bool
+	// SlowQueryThreshold is the duration past which a query is logged at Warn instead
+	// of Debug; zero falls back to DefaultSlowQueryThreshold
+	SlowQueryThreshold time.Duration
+}
+
+// TracerConfigFromEnv reads whether queries are traced from DATABASE_QUERY_LOGGING
+// ("false" turns the tracer off) and the slow query threshold from
+// DATABASE_SLOW_QUERY_THRESHOLD, a duration such as "500ms".
+func TracerConfigFromEnv() (TracerConfig, error) {
+	var cfg TracerConfig
+	if raw := os.Getenv("DATABASE_QUERY_LOGGING"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return TracerConfig{}, fmt.Errorf("invalid DATABASE_QUERY_LOGGING %q: %w", raw, err)
+		}
+		cfg.Disabled = !enabled
+	}
+	if raw := os.Getenv("DATABASE_SLOW_QUERY_THRESHOLD"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return TracerConfig{}, fmt.Errorf("invalid DATABASE_SLOW_QUERY_THRESHOLD %q: must be a positive duration", raw)
+		}
+		cfg.SlowQueryThreshold = d
+	}
+	return cfg, nil
+}
+
+// QueryTracer logs every query with how long it took, at Debug, or at Warn when it
+// ran past the slow query threshold. Each line carries the fields of the logger in
+// the query's context and the query's SQL, never its arguments.
+type QueryTracer struct {
+	slowThreshold time.Duration
+	now           func() time.Time
+}
+
+// NewQueryTracer creates a QueryTracer from cfg
+func NewQueryTracer(cfg TracerConfig) *QueryTracer {
+	threshold := cfg.SlowQueryThreshold
+	if threshold <= 0 {
+		threshold = DefaultSlowQueryThreshold
+	}
+	return &QueryTracer{slowThreshold: threshold, now: time.Now}
+}
+
+// traceKey is the context key TraceQueryStart stores a queryTrace under
+type traceKey struct{}
+
+// queryTrace is a query in flight
+type queryTrace struct {
+	sql   string
+	args  int
+	start time.Time
+}
+
+// TraceQueryStart records when the query started
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, traceKey{}, queryTrace{sql: data.SQL, args: len(data.Args), start: t.now()})
+}
+
+// TraceQueryEnd logs the query once it finished
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(traceKey{}).(queryTrace)
+	if !ok {
+		return
+	}
+	duration := t.now().Sub(trace.start)
+	slow := duration > t.slowThreshold
+
+	log := logger.FromContext(ctx)
+	if !slow && !log.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+	log = log.WithFields(logrus.Fields{
+		"sql":         sanitizeSQL(trace.sql),
+		"args":        trace.args,
+		"duration_ms": duration.Milliseconds(),
+	})
+	if data.Err != nil {
+		log = log.WithField("error", data.Err.Error())
+	} else {
+		log = log.WithField("rows", data.CommandTag.RowsAffected())
+	}
+
+	if slow {
+		log.WithField("threshold_ms", t.slowThreshold.Milliseconds()).Warn("Slow database query")
+		return
+	}
+	log.Debug("Database query")
+}
+
+// sanitizeSQL puts sql on one line and cuts it to maxLoggedSQLLength. Values only
+// reach the database as arguments, which are never logged.
+func sanitizeSQL(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLoggedSQLLength {
+		sql = sql[:maxLoggedSQLLength] + "..."
+	}
+	return sql
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"walletapp/internal/logger"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock hands out the times a test sets
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// traceQuery runs sql through tracer, taking the given duration, with a context whose
+// logger writes to the returned hook
+func traceQuery(t *testing.T, tracer *QueryTracer, level logrus.Level, sql string, duration time.Duration, err error) *test.Hook {
+	t.Helper()
+	log, hook := test.NewNullLogger()
+	log.SetLevel(level)
+	clock := &fakeClock{now: time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	tracer.now = clock.Now
+
+	ctx := logger.NewContext(context.Background(), log.WithFields(logrus.Fields{"operation": "deposit", "path": "/api/v1/wallets/:user_id/deposit"}))
+	ctx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: sql, Args: []any{"secret@example.com", 42}})
+	clock.now = clock.now.Add(duration)
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 1"), Err: err})
+	return hook
+}
+
+func TestQueryTracer_LogsQueriesAtDebug(t *testing.T) {
+	tracer := NewQueryTracer(TracerConfig{})
+
+	hook := traceQuery(t, tracer, logrus.DebugLevel, "UPDATE wallets\n\t\tSET balance = balance + $1\n\t\tWHERE user_id = $2", 15*time.Millisecond, nil)
+
+	if assert.Len(t, hook.AllEntries(), 1) {
+		entry := hook.LastEntry()
+		assert.Equal(t, logrus.DebugLevel, entry.Level)
+		assert.Equal(t, "UPDATE wallets SET balance = balance + $1 WHERE user_id = $2", entry.Data["sql"])
+		assert.Equal(t, int64(15), entry.Data["duration_ms"])
+		assert.Equal(t, int64(1), entry.Data["rows"])
+		assert.Equal(t, 2, entry.Data["args"])
+		assert.Equal(t, "deposit", entry.Data["operation"])
+		assert.Equal(t, "/api/v1/wallets/:user_id/deposit", entry.Data["path"])
+		assert.NotContains(t, entry.Data, "error")
+	}
+}
+
+func TestQueryTracer_WarnsAboutSlowQueries(t *testing.T) {
+	tracer := NewQueryTracer(TracerConfig{SlowQueryThreshold: 100 * time.Millisecond})
+
+	// Slow queries are logged even when Debug is off
+	hook := traceQuery(t, tracer, logrus.InfoLevel, "SELECT 1", 250*time.Millisecond, errors.New("canceled"))
+
+	if assert.Len(t, hook.AllEntries(), 1) {
+		entry := hook.LastEntry()
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		assert.Equal(t, int64(250), entry.Data["duration_ms"])
+		assert.Equal(t, int64(100), entry.Data["threshold_ms"])
+		assert.Equal(t, "canceled", entry.Data["error"])
+		assert.Equal(t, "deposit", entry.Data["operation"])
+	}
+}
+
+func TestQueryTracer_SkipsFastQueriesWithoutDebug(t *testing.T) {
+	tracer := NewQueryTracer(TracerConfig{})
+
+	// A query taking exactly the threshold is not slow
+	hook := traceQuery(t, tracer, logrus.InfoLevel, "SELECT 1", DefaultSlowQueryThreshold, nil)
+
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestQueryTracer_NeverLogsArguments(t *testing.T) {
+	tracer := NewQueryTracer(TracerConfig{})
+
+	hook := traceQuery(t, tracer, logrus.DebugLevel, "SELECT id FROM users WHERE email = $1", time.Millisecond, nil)
+
+	line, err := hook.LastEntry().String()
+	assert.NoError(t, err)
+	assert.NotContains(t, line, "secret@example.com")
+}
+
+func TestSanitizeSQL_CutsLongQueries(t *testing.T) {
+	sql := sanitizeSQL("SELECT " + strings.Repeat("x, ", 1000) + "y FROM t")
+
+	assert.Len(t, sql, maxLoggedSQLLength+len("..."))
+	assert.True(t, strings.HasSuffix(sql, "..."))
+}
+
+func TestTracerConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		logging   string
+		threshold string
+		expected  TracerConfig
+		wantErr   bool
+	}{
+		{"defaults", "", "", TracerConfig{}, false},
+		{"disabled", "false", "", TracerConfig{Disabled: true}, false},
+		{"custom threshold", "true", "500ms", TracerConfig{SlowQueryThreshold: 500 * time.Millisecond}, false},
+		{"invalid logging", "sometimes", "", TracerConfig{}, true},
+		{"invalid threshold", "", "fast", TracerConfig{}, true},
+		{"zero threshold", "", "0s", TracerConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_QUERY_LOGGING", tt.logging)
+			t.Setenv("DATABASE_SLOW_QUERY_THRESHOLD", tt.threshold)
+
+			cfg, err := TracerConfigFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg)
+		})
+	}
+}
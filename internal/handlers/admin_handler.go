@@ -0,0 +1,872 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/middleware"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// GetReconciliation godoc
+// @Summary      Reconcile wallet balances
+// @Description  List wallets whose balance disagrees with the net of their transactions
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Success      200 {object} models.SuccessResponse{data=[]models.Discrepancy}
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/reconciliation [get]
+func GetReconciliation(c *gin.Context) {
+	log := logger.WithField("operation", "api_reconciliation").WithContext(c.Request.Context())
+	log.Info("Reconciliation request received")
+
+	discrepancies, err := services.Reconcile(c.Request.Context())
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Reconciliation failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Reconciliation failed"})
+		return
+	}
+	if discrepancies == nil {
+		discrepancies = []models.Discrepancy{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Reconciliation completed",
+		Data:    discrepancies,
+	})
+}
+
+// GetRiskFlags godoc
+// @Summary      List risk flags
+// @Description  List the operations risk rules flagged, newest first. Flagged operations went through; the flags only mark them for review.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        rule query string false "Only return flags raised by this rule" example(large_amount)
+// @Param        from query string false "Only return flags raised at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param        to query string false "Only return flags raised before this time (RFC 3339 or YYYY-MM-DD)"
+// @Success      200 {object} models.SuccessResponse{data=[]models.RiskFlag}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/risk-flags [get]
+func GetRiskFlags(c *gin.Context) {
+	log := logger.WithField("operation", "api_get_risk_flags").WithContext(c.Request.Context())
+	log.Info("Risk flags request received")
+
+	filter := models.RiskFlagFilter{Rule: c.Query("rule")}
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTimeParam(raw)
+		if err != nil {
+			log.WithField(bound.name, raw).Warn("Invalid time parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: bound.name + " must be an RFC 3339 time or a YYYY-MM-DD date"})
+			return
+		}
+		*bound.target = &t
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		log.Warn("Empty time range")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "from must be before to"})
+		return
+	}
+
+	flags, err := services.GetRiskFlags(c.Request.Context(), filter)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to list risk flags")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to list risk flags"})
+		return
+	}
+	if flags == nil {
+		flags = []models.RiskFlag{}
+	}
+
+	log.WithField("flag_count", len(flags)).Info("Risk flags retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Risk flags retrieved successfully",
+		Data:    flags,
+	})
+}
+
+// parseTimeParam parses a query parameter holding an RFC 3339 time or a date, which
+// stands for UTC midnight at its start
+func parseTimeParam(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, raw)
+}
+
+// SetOverdraftLimit godoc
+// @Summary      Set a wallet's overdraft limit
+// @Description  Allow a wallet to go up to the given amount below zero. A limit of 0 restores the ordinary non-negative balance rule.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        user_id path string true "User ID"
+// @Param        overdraft body models.OverdraftRequest true "Overdraft limit"
+// @Success      200 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/wallets/{user_id}/overdraft [put]
+func SetOverdraftLimit(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_set_overdraft_limit")
+	log.Info("Overdraft limit request received")
+
+	var req models.OverdraftRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Limit has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	wallet, err := services.SetOverdraftLimit(c.Request.Context(), userID, *req.Limit)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Overdraft limit rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, services.ErrInvalidAmount):
+		log.WithField("error", err.Error()).Warn("Overdraft limit rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrInsufficientBalance):
+		log.WithField("error", err.Error()).Warn("Overdraft limit is below the wallet's current overdraft")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeInsufficientBalance, Error: "wallet is overdrawn by more than the requested limit"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to set overdraft limit")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to set overdraft limit"})
+		return
+	}
+
+	log.WithField("overdraft_limit", wallet.OverdraftLimit).Info("Overdraft limit updated")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Overdraft limit updated",
+		Data:    wallet,
+	})
+}
+
+// SetInterestRate godoc
+// @Summary      Set a wallet's interest rate
+// @Description  Set the yearly interest, in basis points (250 is 2.50%), that one of a user's wallets earns on its balance. Interest is credited daily by the interest accrual job. A rate of 0 stops the wallet earning interest.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        user_id path string true "User ID"
+// @Param        wallet_id path string true "Wallet ID"
+// @Param        rate body models.InterestRateRequest true "Interest rate"
+// @Success      200 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/users/{user_id}/wallets/{wallet_id}/interest-rate [put]
+func SetInterestRate(c *gin.Context) {
+	userID, walletID := c.Param("user_id"), c.Param("wallet_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "api_set_interest_rate",
+	})
+	log.Info("Interest rate request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user ID format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user ID format"})
+		return
+	}
+	if _, err := uuid.Parse(walletID); err != nil {
+		log.Warn("Invalid wallet ID format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: errInvalidWalletID.Error()})
+		return
+	}
+	var req models.InterestRateRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "interest_rate_bps must be a whole number of basis points between 0 and 10000", Details: validationDetails(err)})
+		return
+	}
+
+	wallet, err := services.SetInterestRate(c.Request.Context(), userID, walletID, *req.RateBps)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidInterestRate):
+		log.WithField("error", err.Error()).Warn("Interest rate rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Interest rate rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to set interest rate")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to set interest rate"})
+		return
+	}
+
+	log.WithField("interest_rate_bps", wallet.InterestRateBps).Info("Interest rate updated")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Interest rate updated",
+		Data:    wallet,
+	})
+}
+
+// AccrueInterest godoc
+// @Summary      Run the interest accrual job
+// @Description  Credit today's (UTC) interest to every active wallet with a positive balance and interest rate, as the background job does. Wallets that already accrued today are skipped, so running it again the same day credits nothing.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Success      200 {object} models.SuccessResponse{data=models.InterestRun}
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/interest/accrue [post]
+func AccrueInterest(c *gin.Context) {
+	log := logger.WithField("operation", "api_accrue_interest").WithContext(c.Request.Context())
+	log.Info("Interest accrual request received")
+
+	run, err := services.AccrueInterest(c.Request.Context())
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Interest accrual failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Interest accrual failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Interest accrued",
+		Data:    run,
+	})
+}
+
+// StartTransactionArchive godoc
+// @Summary      Run the transaction archive job
+// @Description  Move the transactions older than the retention into the archive, in batches in the background, as the archive worker does. A run a crash or shutdown interrupted is resumed. Returns the run at once; follow it with GET /v1/admin/transactions/archive.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Success      202 {object} models.SuccessResponse{data=models.ArchiveRun}
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/transactions/archive [post]
+func StartTransactionArchive(c *gin.Context) {
+	log := logger.WithField("operation", "api_start_transaction_archive").WithContext(c.Request.Context())
+	log.Info("Transaction archive request received")
+
+	run, err := services.StartTransactionArchive(c.Request.Context())
+	if errors.Is(err, services.ErrArchiveInProgress) {
+		log.Warn("Transaction archive already in progress")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: "transaction archive already in progress"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to start transaction archive")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to start transaction archive"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Code:    202,
+		Message: "Transaction archive started",
+		Data:    run,
+	})
+}
+
+// GetTransactionArchive godoc
+// @Summary      Get transaction archive progress
+// @Description  Report the latest run of the transaction archive job: its status, how many transactions it archived and, while it runs, how many are left to move
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Success      200 {object} models.SuccessResponse{data=models.ArchiveRun}
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/transactions/archive [get]
+func GetTransactionArchive(c *gin.Context) {
+	log := logger.WithField("operation", "api_get_transaction_archive").WithContext(c.Request.Context())
+	log.Info("Transaction archive progress request received")
+
+	run, err := services.GetTransactionArchiveProgress(c.Request.Context())
+	if errors.Is(err, repositories.ErrArchiveRunNotFound) {
+		log.Warn("Transaction archive never ran")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "transaction archive has not run yet"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get transaction archive progress")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get transaction archive progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Transaction archive progress retrieved successfully",
+		Data:    run,
+	})
+}
+
+// SetKycTier godoc
+// @Summary      Set a user's KYC tier
+// @Description  Record how far a user's identity was verified: 0 (unverified), 1 (basic) or 2 (full). When KYC limits are enforced, the tier decides the largest single operation and the daily and monthly totals the user may deposit, withdraw and transfer.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        user_id path string true "User ID"
+// @Param        tier body models.KycTierRequest true "KYC tier"
+// @Success      200 {object} models.SuccessResponse{data=models.UserResponse}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/users/{user_id}/kyc-tier [put]
+func SetKycTier(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_set_kyc_tier")
+	log.Info("KYC tier request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	var req models.KycTierRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	user, err := services.SetKycTier(c.Request.Context(), userID, *req.KycTier)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidKycTier):
+		log.WithField("error", err.Error()).Warn("KYC tier rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("KYC tier rejected, user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "user not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to set KYC tier")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to set KYC tier"})
+		return
+	}
+
+	log.WithField("kyc_tier", int(user.KycTier)).Info("KYC tier updated")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "KYC tier updated",
+		Data:    toUserResponse(user, nil),
+	})
+}
+
+// RestoreUser godoc
+// @Summary      Restore a deleted user
+// @Description  Undo the deletion of a user. The user and their wallets show up in the API again, but the wallets stay closed. Fails when another user took the email or username in the meantime.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        user_id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=models.UserResponse}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/users/{user_id}/restore [post]
+func RestoreUser(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_restore_user")
+	log.Info("Restore user request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+
+	user, err := services.RestoreUser(c.Request.Context(), userID)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "deleted user not found"})
+		return
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to restore user"})
+		return
+	}
+
+	log.Info("User restored")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "User restored",
+		Data:    toUserResponse(user, nil),
+	})
+}
+
+// ResetUserPassword godoc
+// @Summary      Reset a user's password
+// @Description  Set a user's password without their current one, for a user who lost it. The new password follows the same rules as a password change, and the reset is logged with the acting admin.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        X-Admin-User header string true "ID of the admin performing the reset"
+// @Param        user_id path string true "User ID"
+// @Param        password body models.ResetPasswordRequest true "New password"
+// @Success      200 {object} models.SuccessResponse
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/users/{user_id}/password [post]
+func ResetUserPassword(c *gin.Context) {
+	userID := c.Param("user_id")
+	performedBy := c.GetHeader(middleware.AdminUserHeader)
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"performed_by": performedBy,
+		"operation":    "api_reset_password",
+	})
+	log.Info("Reset password request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	var req models.ResetPasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	if err := services.ResetPassword(c.Request.Context(), userID, req.NewPassword, performedBy); err != nil {
+		respondPasswordError(c, log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Password reset",
+	})
+}
+
+// ReverseTransfer godoc
+// @Summary      Reverse a transfer
+// @Description  Undo a completed transfer: the recipient returns the amount to the sender and any fee is refunded. Fails when the recipient has already spent the money. A transfer can be reversed only once.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        transfer_id path string true "Transfer ID"
+// @Param        reversal body models.ReversalRequest true "Why the transfer is reversed"
+// @Success      200 {object} models.SuccessResponse{data=models.TransferReversal}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/transfers/{transfer_id}/reverse [post]
+func ReverseTransfer(c *gin.Context) {
+	transferID := c.Param("transfer_id")
+	log := logger.WithFields(logrus.Fields{
+		"transfer_id": transferID,
+		"operation":   "api_reverse_transfer",
+	}).WithContext(c.Request.Context())
+	log.Info("Transfer reversal request received")
+
+	if _, err := uuid.Parse(transferID); err != nil {
+		log.Warn("Invalid transfer_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid transfer_id format"})
+		return
+	}
+	var req models.ReversalRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	reversal, err := services.ReverseTransfer(c.Request.Context(), transferID, req.Reason)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidReason):
+		log.WithField("error", err.Error()).Warn("Transfer reversal rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrTransferNotFound):
+		log.Warn("Transfer reversal rejected, transfer not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "transfer not found"})
+		return
+	case errors.Is(err, services.ErrTransferAlreadyReversed):
+		log.Warn("Transfer reversal rejected, transfer was already reversed")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: "transfer was already reversed"})
+		return
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn("Transfer reversal aborted due to concurrent updates")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConcurrentUpdate, Error: "Transfer reversal conflicted with concurrent updates, please try again"})
+		return
+	case errors.Is(err, services.ErrReversalFundsSpent):
+		log.WithField("error", err.Error()).Warn("Transfer reversal rejected, funds were already spent")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeBusinessRule, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to reverse transfer")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to reverse transfer"})
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"amount": reversal.Amount,
+		"fee":    reversal.Fee,
+	}).Info("Transfer reversed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Transfer reversed successfully",
+		Data:    reversal,
+	})
+}
+
+// CreateAdjustment godoc
+// @Summary      Adjust a wallet's balance
+// @Description  Credit (positive amount) or debit (negative amount) a wallet to correct its balance, recorded as an ADJUSTMENT transaction with the reason and the acting admin. A negative adjustment may only take the balance below zero, up to the overdraft limit, when force is set.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        X-Admin-User header string true "ID of the admin performing the adjustment"
+// @Param        user_id path string true "User ID"
+// @Param        adjustment body models.AdjustmentRequest true "Signed amount and reason"
+// @Success      201 {object} models.SuccessResponse{data=models.Transaction}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/wallets/{user_id}/adjustments [post]
+func CreateAdjustment(c *gin.Context) {
+	userID := c.Param("user_id")
+	performedBy := c.GetHeader(middleware.AdminUserHeader)
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"performed_by": performedBy,
+		"operation":    "api_adjust",
+	})
+	log.Info("Adjustment request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	var req models.AdjustmentRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	adjustment, err := services.Adjust(c.Request.Context(), userID, req.Amount, req.Reason, performedBy, req.Force)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrInvalidReason), errors.Is(err, services.ErrInvalidAdjustment):
+		log.WithField("error", err.Error()).Warn("Adjustment rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Adjustment rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, services.ErrInsufficientBalance):
+		log.WithField("error", err.Error()).Warn("Adjustment rejected due to insufficient balance")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeInsufficientBalance, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn("Adjustment aborted due to concurrent updates")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConcurrentUpdate, Error: "Adjustment conflicted with concurrent updates, please try again"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to adjust balance")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to adjust balance"})
+		return
+	}
+
+	log.WithField("transaction_id", adjustment.ID.String()).Info("Balance adjusted successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Balance adjusted successfully",
+		Data:    adjustment,
+	})
+}
+
+// GrantBonus godoc
+// @Summary      Grant a promotional bonus
+// @Description  Credit a bonus to a user's wallet on behalf of a marketing campaign, recorded as a BONUS transaction with the campaign in its metadata. Bonuses are reported apart from deposits. Frozen and closed wallets cannot receive bonuses.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        user_id path string true "User ID"
+// @Param        bonus body models.BonusRequest true "Amount and campaign"
+// @Success      201 {object} models.SuccessResponse{data=models.Transaction}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/wallets/{user_id}/bonus [post]
+func GrantBonus(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_grant_bonus")
+	log.Info("Bonus request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	var req models.BonusRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	bonus, err := services.GrantBonus(c.Request.Context(), userID, req.Amount, req.Campaign)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrInvalidCampaign):
+		log.WithField("error", err.Error()).Warn("Bonus rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Bonus rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, services.ErrWalletFrozen):
+		log.WithField("error", err.Error()).Warn("Bonus rejected, wallet is frozen")
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Code: models.CodeWalletFrozen, Error: services.ErrWalletFrozen.Error()})
+		return
+	case errors.Is(err, services.ErrWalletClosed):
+		log.WithField("error", err.Error()).Warn("Bonus rejected, wallet is closed")
+		c.JSON(http.StatusGone, models.ErrorResponse{Code: models.CodeWalletClosed, Error: services.ErrWalletClosed.Error()})
+		return
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn("Bonus aborted due to concurrent updates")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConcurrentUpdate, Error: "Bonus conflicted with concurrent updates, please try again"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to grant bonus")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to grant bonus"})
+		return
+	}
+
+	log.WithField("transaction_id", bonus.ID.String()).Info("Bonus granted successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Bonus granted successfully",
+		Data:    bonus,
+	})
+}
+
+// FreezeWallet godoc
+// @Summary      Freeze a wallet
+// @Description  Stop a wallet from depositing, withdrawing, transferring or holding funds. Its balance can still be read. The freeze is recorded with the reason and the acting admin.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        X-Admin-User header string true "ID of the admin freezing the wallet"
+// @Param        user_id path string true "User ID"
+// @Param        freeze body models.WalletStatusRequest true "Reason"
+// @Success      200 {object} models.SuccessResponse{data=models.WalletStatusChange}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/wallets/{user_id}/freeze [post]
+func FreezeWallet(c *gin.Context) {
+	changeWalletStatus(c, "freeze", services.FreezeWallet)
+}
+
+// UnfreezeWallet godoc
+// @Summary      Unfreeze a wallet
+// @Description  Let a frozen wallet move money again, recording the reason and the acting admin
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin API token"
+// @Param        X-Admin-User header string true "ID of the admin unfreezing the wallet"
+// @Param        user_id path string true "User ID"
+// @Param        unfreeze body models.WalletStatusRequest true "Reason"
+// @Success      200 {object} models.SuccessResponse{data=models.WalletStatusChange}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      401 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/admin/wallets/{user_id}/unfreeze [post]
+func UnfreezeWallet(c *gin.Context) {
+	changeWalletStatus(c, "unfreeze", services.UnfreezeWallet)
+}
+
+// changeWalletStatus handles freeze and unfreeze requests, which differ only in the
+// service call that changes the wallet's status
+func changeWalletStatus(c *gin.Context, action string, change func(ctx context.Context, userID, reason, performedBy string) (*models.WalletStatusChange, error)) {
+	userID := c.Param("user_id")
+	performedBy := c.GetHeader(middleware.AdminUserHeader)
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"performed_by": performedBy,
+		"operation":    "api_" + action + "_wallet",
+	})
+	log.Info("Wallet " + action + " request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	var req models.WalletStatusRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	statusChange, err := change(c.Request.Context(), userID, req.Reason, performedBy)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidReason), errors.Is(err, services.ErrAdminRequired):
+		log.WithField("error", err.Error()).Warn("Wallet " + action + " rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Wallet " + action + " rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, services.ErrWalletClosed):
+		log.WithField("error", err.Error()).Warn("Wallet " + action + " rejected, wallet is closed")
+		c.JSON(http.StatusGone, models.ErrorResponse{Code: models.CodeWalletClosed, Error: services.ErrWalletClosed.Error()})
+		return
+	case errors.Is(err, services.ErrWalletFrozen), errors.Is(err, services.ErrWalletNotFrozen):
+		log.WithField("error", err.Error()).Warn("Wallet " + action + " rejected, status unchanged")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn("Wallet " + action + " aborted due to concurrent updates")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConcurrentUpdate, Error: "Wallet status change conflicted with concurrent updates, please try again"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to change wallet status")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to change wallet status"})
+		return
+	}
+
+	log.WithField("status", statusChange.Status).Info("Wallet status changed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallet status changed successfully",
+		Data:    statusChange,
+	})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ForgotPassword godoc
+// @Summary      Ask to reset a forgotten password
+// @Description  Email the user with this email a token that resets their password within 30 minutes. The response is the same whether or not a user has the email, so it does not reveal who has an account.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ForgotPasswordRequest  true  "Email of the account"
+// @Success      200      {object}  models.SuccessResponse
+// @Failure      400      {object}  models.ErrorResponse
+// @Failure      500      {object}  models.ErrorResponse
+// @Router       /v1/auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	log := logger.WithOperation("api_forgot_password").WithContext(c.Request.Context())
+	log.Info("Forgot password request received")
+
+	var req models.ForgotPasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithError(err).Warn("Invalid request body for forgot password")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	err := services.RequestPasswordReset(c.Request.Context(), req.Email)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to request a password reset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "If an account has this email, a password reset token was sent to it",
+	})
+}
+
+// ResetPasswordWithToken godoc
+// @Summary      Reset a forgotten password
+// @Description  Set a new password with the token emailed by forgot-password. A token works once and for 30 minutes; using one also voids every other token sent to the user. The new password follows the rules of a password change.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ResetPasswordWithTokenRequest  true  "Token and new password"
+// @Success      200      {object}  models.SuccessResponse
+// @Failure      400      {object}  models.ErrorResponse
+// @Failure      500      {object}  models.ErrorResponse
+// @Router       /v1/auth/reset-password [post]
+func ResetPasswordWithToken(c *gin.Context) {
+	log := logger.WithOperation("api_reset_password").WithContext(c.Request.Context())
+	log.Info("Reset password request received")
+
+	var req models.ResetPasswordWithTokenRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithError(err).Warn("Invalid request body for password reset")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	err := services.ResetPasswordWithToken(c.Request.Context(), req.Token, req.NewPassword)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidResetToken):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   err.Error(),
+			Details: map[string]string{"token": "is invalid or expired"},
+		})
+		return
+	default:
+		respondPasswordError(c, log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Password reset successfully",
+	})
+}
+
+// VerifyEmail godoc
+// @Summary      Verify an email
+// @Description  Mark the user verified with the token emailed to them at signup or by a resend. A token works once and for 24 hours; using one also voids every other token sent to the user. Until then, withdrawals and transfers over the unverified email limit are refused with EMAIL_NOT_VERIFIED.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.VerifyEmailRequest  true  "Emailed token"
+// @Success      200      {object}  models.SuccessResponse{data=models.UserResponse}
+// @Failure      400      {object}  models.ErrorResponse
+// @Failure      500      {object}  models.ErrorResponse
+// @Router       /v1/auth/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	log := logger.WithOperation("api_verify_email").WithContext(c.Request.Context())
+	log.Info("Verify email request received")
+
+	var req models.VerifyEmailRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithError(err).Warn("Invalid request body for email verification")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	user, err := services.VerifyEmail(c.Request.Context(), req.Token)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidVerificationToken):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   err.Error(),
+			Details: map[string]string{"token": "is invalid or expired"},
+		})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Email verified successfully",
+		Data:    toUserResponse(user, nil),
+	})
+}
+
+// ResendVerificationEmail godoc
+// @Summary      Resend the email verification token
+// @Description  Email the user a new token to verify their email with. A user is sent at most 3 verification emails, the one sent at signup included, in any hour; past that the response is 429 with a Retry-After header.
+// @Tags         auth
+// @Produce      json
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  models.SuccessResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      409  {object}  models.ErrorResponse
+// @Failure      429  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /v1/users/{id}/verification-email [post]
+func ResendVerificationEmail(c *gin.Context) {
+	id := c.Param("id")
+	log := logger.WithUser(id).WithContext(c.Request.Context()).WithField("operation", "api_resend_verification_email")
+	log.Info("Resend verification email request received")
+
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+
+	err := services.ResendVerificationEmail(c.Request.Context(), id)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "User not found"})
+		return
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrTooManyVerificationEmails):
+		rejectTooManyOperations(c, err)
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Verification email sent",
+	})
+}
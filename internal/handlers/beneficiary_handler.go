@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateBeneficiary godoc
+// @Summary      Save a beneficiary
+// @Description  Save another user to the user's contacts under an optional nickname, so transfers can name them by beneficiary_id. Each user can be saved once.
+// @Tags         beneficiaries
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        request body models.CreateBeneficiaryRequest true "Beneficiary details"
+// @Success      201 {object} models.SuccessResponse{data=models.Beneficiary}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/beneficiaries [post]
+func CreateBeneficiary(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_create_beneficiary")
+
+	log.Info("Create beneficiary request received")
+
+	var req models.CreateBeneficiaryRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+	log = log.WithField("beneficiary_user_id", req.BeneficiaryUserID)
+	if _, err := uuid.Parse(req.BeneficiaryUserID); err != nil {
+		log.Warn("Invalid beneficiary_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid beneficiary_user_id format"})
+		return
+	}
+	if !userExists(c, log, userID) {
+		return
+	}
+
+	beneficiary, err := services.AddBeneficiary(c.Request.Context(), userID, req.BeneficiaryUserID, req.Nickname)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrSelfBeneficiary), errors.Is(err, services.ErrInvalidNickname):
+		log.WithField("error", err.Error()).Warn("Beneficiary rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrBeneficiaryExists):
+		log.WithField("error", err.Error()).Warn("Beneficiary rejected, already saved")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: services.ErrBeneficiaryExists.Error()})
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("Beneficiary rejected, user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "beneficiary_user_id not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to save beneficiary")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to save beneficiary"})
+		return
+	}
+
+	log.WithField("beneficiary_id", beneficiary.ID.String()).Info("Beneficiary saved successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Beneficiary saved successfully",
+		Data:    beneficiary,
+	})
+}
+
+// GetBeneficiaries godoc
+// @Summary      List beneficiaries
+// @Description  List the beneficiaries the user has saved, in the order they were saved
+// @Tags         beneficiaries
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=[]models.Beneficiary}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/beneficiaries [get]
+func GetBeneficiaries(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_beneficiaries")
+
+	log.Info("Beneficiaries request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+
+	beneficiaries, err := services.GetBeneficiaries(c.Request.Context(), userID)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get beneficiaries")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get beneficiaries"})
+		return
+	}
+	if beneficiaries == nil {
+		beneficiaries = []models.Beneficiary{}
+	}
+
+	log.WithField("count", len(beneficiaries)).Info("Beneficiaries retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Beneficiaries retrieved successfully",
+		Data:    beneficiaries,
+	})
+}
+
+// DeleteBeneficiary godoc
+// @Summary      Remove a beneficiary
+// @Description  Remove a beneficiary from the user's contacts
+// @Tags         beneficiaries
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        beneficiary_id path string true "Beneficiary ID"
+// @Success      200 {object} models.SuccessResponse
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/beneficiaries/{beneficiary_id} [delete]
+func DeleteBeneficiary(c *gin.Context) {
+	userID := c.Param("id")
+	beneficiaryID := c.Param("beneficiary_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"operation":      "api_delete_beneficiary",
+		"beneficiary_id": beneficiaryID,
+	})
+
+	log.Info("Delete beneficiary request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+	if _, err := uuid.Parse(beneficiaryID); err != nil {
+		log.Warn("Invalid beneficiary id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid beneficiary id format"})
+		return
+	}
+
+	err := services.RemoveBeneficiary(c.Request.Context(), userID, beneficiaryID)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrBeneficiaryNotFound):
+		log.WithField("error", err.Error()).Warn("Beneficiary not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "beneficiary not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to remove beneficiary")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to remove beneficiary"})
+		return
+	}
+
+	log.Info("Beneficiary removed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Beneficiary removed successfully",
+	})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// DefaultMaxBodySize is how large a request body may be when MAX_BODY_SIZE is not set
+const DefaultMaxBodySize int64 = 64 << 10
+
+// maxBodySize is how many bytes bindJSON reads of a request body
+var maxBodySize = DefaultMaxBodySize
+
+// errTrailingData is returned by bindJSON for a body with more after its JSON value
+var errTrailingData = errors.New("invalid request: body must hold a single JSON value")
+
+// MaxBodySizeFromEnv reads the largest request body accepted from MAX_BODY_SIZE, a
+// number of bytes. Unset, it is DefaultMaxBodySize.
+func MaxBodySizeFromEnv() (int64, error) {
+	raw := os.Getenv("MAX_BODY_SIZE")
+	if raw == "" {
+		return DefaultMaxBodySize, nil
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid MAX_BODY_SIZE %q: must be a positive number of bytes", raw)
+	}
+	return n, nil
+}
+
+// SetMaxBodySize sets the largest request body the handlers accept, in bytes. It must
+// be called before the router serves requests.
+func SetMaxBodySize(n int64) {
+	maxBodySize = n
+}
+
+// bindJSON decodes the JSON request body into obj and validates it like ShouldBindJSON,
+// but strictly: a field obj does not have is an error rather than being ignored, so a
+// typo cannot leave a field unset, a body over maxBodySize is not read past the limit,
+// and anything after the JSON value, such as a second object, is an error rather than
+// being dropped.
+func bindJSON(c *gin.Context, obj any) error {
+	if c.Request.Body == nil {
+		return fmt.Errorf("invalid request: missing body")
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize)
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(obj); err != nil {
+		return err
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			return err
+		}
+		return errTrailingData
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonContext is a test context for a POST of body
+func jsonContext(body string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestBindJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("valid request", func(t *testing.T) {
+		var req TransferRequest
+		err := bindJSON(jsonContext(`{"from_user_id": "a", "to_user_id": "b", "amount": "25.00", "description": "rent"}`), &req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, TransferRequest{FromUserID: "a", ToUserID: "b", Amount: money.MustParse("25.00"), Description: "rent"}, req)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		var req models.AmountRequest
+		err := bindJSON(jsonContext(`{"ammount": 50}`), &req)
+
+		assert.Error(t, err)
+		assert.Equal(t, map[string]string{"ammount": "is not a known field"}, validationDetails(err))
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		var req models.CreateUserRequest
+		err := bindJSON(jsonContext(`{"username": "jo", "first_name": "Jo", "last_name": "Doe", "email": "jo", "password": "secret"}`), &req)
+
+		assert.Error(t, err)
+		assert.Equal(t, map[string]string{"email": "must be a valid email address"}, validationDetails(err))
+	})
+
+	t.Run("trailing JSON value", func(t *testing.T) {
+		var req models.AmountRequest
+		err := bindJSON(jsonContext(`{"amount":1}{"amount":999}`), &req)
+
+		assert.ErrorIs(t, err, errTrailingData)
+		assert.Equal(t, map[string]string{"body": "must hold a single JSON value"}, validationDetails(err))
+	})
+
+	t.Run("trailing whitespace", func(t *testing.T) {
+		var req models.AmountRequest
+		err := bindJSON(jsonContext("{\"amount\": \"1.00\"}\n"), &req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, money.MustParse("1.00"), *req.Amount)
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		SetMaxBodySize(64)
+		t.Cleanup(func() { SetMaxBodySize(DefaultMaxBodySize) })
+
+		var req models.AmountRequest
+		err := bindJSON(jsonContext(`{"amount": "10.00", "description": "`+strings.Repeat("a", 100)+`"}`), &req)
+
+		assert.Error(t, err)
+		assert.Equal(t, map[string]string{"body": "must be at most 64 bytes"}, validationDetails(err))
+	})
+}
+
+func TestMaxBodySizeFromEnv(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int64
+		wantErr bool
+	}{
+		{raw: "", want: DefaultMaxBodySize},
+		{raw: "1048576", want: 1 << 20},
+		{raw: "0", wantErr: true},
+		{raw: "64KB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			t.Setenv("MAX_BODY_SIZE", tt.raw)
+
+			got, err := MaxBodySizeFromEnv()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// exportFlushInterval is how many transactions an export writes between flushes, so
+// the client receives the history as it is read
+const exportFlushInterval = 500
+
+// exportColumns are the columns of a CSV export, in order
+var exportColumns = []string{"id", "created_at", "type", "status", "amount", "currency", "related_user_id", "transfer_id",
+	"external_reference", "description", "metadata"}
+
+// transactionEncoder writes transactions to an export in one format
+type transactionEncoder interface {
+	Encode(t models.Transaction) error
+	// Flush hands what was encoded so far to the underlying writer
+	Flush() error
+}
+
+// csvEncoder writes transactions as CSV rows under a header of exportColumns
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	enc := &csvEncoder{w: csv.NewWriter(w)}
+	return enc, enc.w.Write(exportColumns)
+}
+
+func (e *csvEncoder) Encode(t models.Transaction) error {
+	var metadata string
+	if len(t.Metadata) > 0 {
+		raw, err := json.Marshal(t.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(raw)
+	}
+	var transferID string
+	if t.TransferID != nil {
+		transferID = t.TransferID.String()
+	}
+	return e.w.Write([]string{
+		t.ID.String(),
+		t.CreatedAt.UTC().Format(time.RFC3339Nano),
+		string(t.Type),
+		string(t.Status),
+		t.Amount.String(),
+		t.Currency,
+		deref(t.RelatedUserID),
+		transferID,
+		spreadsheetSafe(deref(t.ExternalReference)),
+		spreadsheetSafe(deref(t.Description)),
+		spreadsheetSafe(metadata),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonlEncoder writes transactions as JSON Lines, one transaction object per line
+type jsonlEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONLEncoder(w io.Writer) *jsonlEncoder {
+	buf := bufio.NewWriter(w)
+	return &jsonlEncoder{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *jsonlEncoder) Encode(t models.Transaction) error {
+	return e.enc.Encode(t)
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+// deref returns the string s points to, or "" for nil
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// spreadsheetSafe keeps a user-supplied cell from being run as a formula when the CSV
+// is opened in a spreadsheet, by quoting it with a leading apostrophe
+func spreadsheetSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// ExportTransactions godoc
+// @Summary      Export transaction history
+// @Description  Stream the wallet's whole transaction history matching the filters, oldest first, as CSV or JSON Lines. The history is written as it is read, so exports of any length take the same memory. A failure after the first transaction was sent cuts the export short.
+// @Tags         wallet
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        user_id path string true "User ID"
+// @Param        format query string false "Format of the export (default: csv)" Enums(csv, jsonl)
+// @Param        status query string false "Only export transactions in this status" Enums(PENDING, COMPLETED, FAILED)
+// @Param        type query []string false "Only export transactions of these types, repeated or comma-separated" collectionFormat(multi)
+// @Param        from query string false "Only export transactions created at or after this RFC3339 time"
+// @Param        to query string false "Only export transactions created at or before this RFC3339 time"
+// @Param        min_amount query string false "Only export transactions of at least this amount" example(500.00)
+// @Param        max_amount query string false "Only export transactions of at most this amount" example(1000.00)
+// @Param        metadata_key query string false "Only export transactions whose metadata has this key, set to metadata_value"
+// @Param        metadata_value query string false "Value of metadata_key to match"
+// @Param        include_archived query bool false "Also export archived transactions, those older than the retention, when from reaches back to them (default: false)"
+// @Success      200 {string} string "The transactions, one per line"
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/transactions/export [get]
+func ExportTransactions(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_export_transactions")
+
+	log.Info("Transaction export request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.WithField("user_id", userID).Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "jsonl":
+		contentType = "application/x-ndjson"
+	default:
+		log.WithField("format", format).Warn("Invalid format parameter")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "format must be csv or jsonl"})
+		return
+	}
+
+	filter, ok := parseTransactionFilter(c, log)
+	if !ok {
+		return
+	}
+
+	// The export is read for as long as the client keeps reading it
+	ctx := c.Request.Context()
+	wallet, err := services.GetWallet(ctx, userID)
+	if err != nil {
+		respondServiceError(c, log, err, "Export")
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions-%s.%s"`, wallet.ID, format))
+	c.Status(http.StatusOK)
+
+	var enc transactionEncoder
+	if format == "csv" {
+		enc, err = newCSVEncoder(c.Writer)
+	} else {
+		enc = newJSONLEncoder(c.Writer)
+	}
+
+	count := 0
+	if err == nil {
+		err = transactionRepo.StreamTransactionsByWalletID(ctx, wallet.ID.String(), filter, func(t models.Transaction) error {
+			if err := enc.Encode(t); err != nil {
+				return err
+			}
+			count++
+			if count%exportFlushInterval == 0 {
+				if err := enc.Flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil && !c.Writer.Written() {
+		// Nothing was flushed yet, so the failure can still be answered properly
+		log.WithField("error", err.Error()).Error("Failed to export transactions")
+		c.Writer.Header().Del("Content-Disposition")
+		c.Writer.Header().Del("Content-Type")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to export transactions"})
+		return
+	}
+	if err != nil {
+		// The status went out with the first flush, so the client can only tell from the
+		// export ending early
+		log.WithFields(logrus.Fields{
+			"error":             err.Error(),
+			"transaction_count": count,
+		}).Error("Transaction export failed")
+		return
+	}
+
+	log.WithField("transaction_count", count).Info("Transaction export completed successfully")
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateHold godoc
+// @Summary      Reserve funds
+// @Description  Place an authorization hold on part of a wallet's balance. Held funds cannot be withdrawn or transferred until the hold is captured or released.
+// @Tags         holds
+// @Accept       json
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        hold body models.HoldRequest true "Amount to hold"
+// @Success      201 {object} models.SuccessResponse{data=models.Hold}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/holds [post]
+func CreateHold(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_create_hold")
+
+	log.Info("Hold request received")
+
+	var req models.HoldRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	hold, err := services.Hold(c.Request.Context(), userID, req.Amount)
+	if err != nil {
+		respondServiceError(c, log, err, "Hold")
+		return
+	}
+
+	log.WithField("hold_id", hold.ID.String()).Info("Hold created successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Funds held successfully",
+		Data:    hold,
+	})
+}
+
+// CaptureHold godoc
+// @Summary      Capture a hold
+// @Description  Withdraw the funds reserved by an active hold
+// @Tags         holds
+// @Produce      json
+// @Param        id path string true "Hold ID"
+// @Success      200 {object} models.SuccessResponse{data=models.Hold}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/holds/{id}/capture [post]
+func CaptureHold(c *gin.Context) {
+	finalizeHold(c, "capture", services.Capture)
+}
+
+// ReleaseHold godoc
+// @Summary      Release a hold
+// @Description  Cancel an active hold and return its funds to the available balance
+// @Tags         holds
+// @Produce      json
+// @Param        id path string true "Hold ID"
+// @Success      200 {object} models.SuccessResponse{data=models.Hold}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/holds/{id}/release [post]
+func ReleaseHold(c *gin.Context) {
+	finalizeHold(c, "release", services.Release)
+}
+
+// finalizeHold handles capture and release requests, which differ only in the
+// service call that settles the hold
+func finalizeHold(c *gin.Context, action string, settle func(ctx context.Context, holdID string) (*models.Hold, error)) {
+	holdID := c.Param("id")
+	log := logger.WithFields(logrus.Fields{
+		"operation": "api_" + action + "_hold",
+		"hold_id":   holdID,
+	}).WithContext(c.Request.Context())
+
+	log.Info("Hold " + action + " request received")
+
+	if _, err := uuid.Parse(holdID); err != nil {
+		log.Warn("Invalid hold id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid hold id format"})
+		return
+	}
+
+	hold, err := settle(c.Request.Context(), holdID)
+	if err != nil {
+		respondServiceError(c, log, err, "Hold "+action)
+		return
+	}
+
+	log.WithField("amount", hold.Amount).Info("Hold " + action + " completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Hold " + strings.ToLower(string(hold.Status)) + " successfully",
+		Data:    hold,
+	})
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CreatePaymentRequest godoc
+// @Summary      Request money
+// @Description  Ask another user to send money, with an optional message. The payer can accept or decline the request until it expires.
+// @Tags         payment-requests
+// @Accept       json
+// @Produce      json
+// @Param        request body models.CreatePaymentRequestRequest true "Payment request details"
+// @Success      201 {object} models.SuccessResponse{data=models.PaymentRequest}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/payment-requests [post]
+func CreatePaymentRequest(c *gin.Context) {
+	log := logger.WithField("operation", "api_create_payment_request").WithContext(c.Request.Context())
+
+	log.Info("Payment request received")
+
+	var req models.CreatePaymentRequestRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"requester_id": req.RequesterID,
+		"payer_id":     req.PayerID,
+	})
+	if _, err := uuid.Parse(req.RequesterID); err != nil {
+		log.Warn("Invalid requester_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid requester_id format"})
+		return
+	}
+	if _, err := uuid.Parse(req.PayerID); err != nil {
+		log.Warn("Invalid payer_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid payer_id format"})
+		return
+	}
+
+	request, err := services.CreatePaymentRequest(c.Request.Context(), req.RequesterID, req.PayerID, req.Amount, req.Message)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("Payment request rejected, user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "user not found"})
+		return
+	case errors.Is(err, services.ErrSelfTransfer), errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrInvalidDescription):
+		log.WithField("error", err.Error()).Warn("Payment request rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to create payment request")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to create payment request"})
+		return
+	}
+
+	log.WithField("payment_request_id", request.ID.String()).Info("Payment request created successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Payment request created successfully",
+		Data:    request,
+	})
+}
+
+// GetPaymentRequests godoc
+// @Summary      List pending payment requests
+// @Description  List the payment requests a user has been asked to pay and has not yet accepted or declined, newest first. Expired requests are left out.
+// @Tags         payment-requests
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=[]models.PaymentRequest}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/payment-requests [get]
+func GetPaymentRequests(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_payment_requests")
+
+	log.Info("Payment requests request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+
+	requests, err := services.GetPendingPaymentRequests(c.Request.Context(), userID)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get payment requests")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get payment requests"})
+		return
+	}
+	if requests == nil {
+		requests = []models.PaymentRequest{}
+	}
+
+	log.WithField("count", len(requests)).Info("Payment requests retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Payment requests retrieved successfully",
+		Data:    requests,
+	})
+}
+
+// AcceptPaymentRequest godoc
+// @Summary      Accept a payment request
+// @Description  Pay a pending payment request with a transfer from the payer to the requester, charged the usual transfer fee. Accepting a request twice pays it once; the second attempt returns 409.
+// @Tags         payment-requests
+// @Produce      json
+// @Param        id path string true "Payer's user ID"
+// @Param        request_id path string true "Payment request ID"
+// @Success      200 {object} models.SuccessResponse{data=models.PaymentRequest}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/payment-requests/{request_id}/accept [post]
+func AcceptPaymentRequest(c *gin.Context) {
+	answerPaymentRequest(c, "accept", services.AcceptPaymentRequest)
+}
+
+// DeclinePaymentRequest godoc
+// @Summary      Decline a payment request
+// @Description  Decline a pending payment request without paying it
+// @Tags         payment-requests
+// @Produce      json
+// @Param        id path string true "Payer's user ID"
+// @Param        request_id path string true "Payment request ID"
+// @Success      200 {object} models.SuccessResponse{data=models.PaymentRequest}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/payment-requests/{request_id}/decline [post]
+func DeclinePaymentRequest(c *gin.Context) {
+	answerPaymentRequest(c, "decline", services.DeclinePaymentRequest)
+}
+
+// answerPaymentRequest handles accept and decline requests, which differ only in the
+// service call that answers the payment request
+func answerPaymentRequest(c *gin.Context, action string, answer func(ctx context.Context, payerID, requestID string) (*models.PaymentRequest, error)) {
+	payerID := c.Param("id")
+	requestID := c.Param("request_id")
+	log := logger.WithUser(payerID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"operation":          "api_" + action + "_payment_request",
+		"payment_request_id": requestID,
+	})
+
+	log.Info("Payment request " + action + " received")
+
+	if _, err := uuid.Parse(payerID); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+	if _, err := uuid.Parse(requestID); err != nil {
+		log.Warn("Invalid payment request id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid payment request id format"})
+		return
+	}
+
+	request, err := answer(c.Request.Context(), payerID, requestID)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrPaymentRequestNotFound):
+		log.WithField("error", err.Error()).Warn("Payment request not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "payment request not found"})
+		return
+	case errors.Is(err, services.ErrPaymentRequestAlreadyPaid), errors.Is(err, services.ErrPaymentRequestDeclined):
+		log.WithField("error", err.Error()).Warn("Payment request was already answered")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrPaymentRequestExpired):
+		log.WithField("error", err.Error()).Warn("Payment request has expired")
+		c.JSON(http.StatusGone, models.ErrorResponse{Code: models.CodeGone, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrInsufficientBalance):
+		log.Warn("Payment request rejected due to insufficient balance")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeInsufficientBalance, Error: "insufficient balance"})
+		return
+	case errors.Is(err, services.ErrTooManyOperations):
+		log.WithField("error", err.Error()).Warn("Payment request rejected, too many operations")
+		rejectTooManyOperations(c, err)
+		return
+	case errors.Is(err, services.ErrWalletFrozen):
+		log.WithField("error", err.Error()).Warn("Payment request rejected, wallet is frozen")
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Code: models.CodeWalletFrozen, Error: services.ErrWalletFrozen.Error()})
+		return
+	case errors.Is(err, services.ErrWalletClosed):
+		log.WithField("error", err.Error()).Warn("Payment request rejected, wallet is closed")
+		c.JSON(http.StatusGone, models.ErrorResponse{Code: models.CodeWalletClosed, Error: services.ErrWalletClosed.Error()})
+		return
+	case errors.Is(err, services.ErrMonthlyLimitExceeded):
+		log.WithField("error", err.Error()).Warn("Payment request rejected due to the monthly limit")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeLimitExceeded, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrKycLimitExceeded):
+		log.WithField("error", err.Error()).Warn("Payment request rejected by KYC tier limits")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeLimitExceeded, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Payment request rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, services.ErrCurrencyMismatch):
+		log.WithField("error", err.Error()).Warn("Payment request rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn("Payment request " + action + " aborted due to concurrent updates")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConcurrentUpdate, Error: "Payment request conflicted with concurrent updates, please try again"})
+		return
+	case errors.Is(err, services.ErrCommitFailed):
+		log.WithField("error", err.Error()).Error("Payment request " + action + " could not be committed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Payment request could not be updated, please try again"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Payment request " + action + " failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to " + action + " payment request"})
+		return
+	}
+
+	log.WithField("status", request.Status).Info("Payment request " + action + " completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Payment request " + strings.ToLower(string(request.Status)) + " successfully",
+		Data:    request,
+	})
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import "walletapp/internal/repositories"
+
+// The repositories the handlers read through when they need no service logic
+var (
+	userRepo        repositories.UserRepository
+	walletRepo      repositories.WalletRepository
+	transactionRepo repositories.TransactionRepository
+)
+
+// SetRepositories sets the repositories the handlers use. It must be called before
+// the router serves requests.
+func SetRepositories(users repositories.UserRepository, wallets repositories.WalletRepository, transactions repositories.TransactionRepository) {
+	userRepo = users
+	walletRepo = wallets
+	transactionRepo = transactions
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduleTransfer godoc
+// @Summary      Schedule a transfer
+// @Description  Schedule a transfer between users to be executed at a later time. The sender's balance is checked when the transfer runs; failed attempts are retried a limited number of times.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        transfer body models.ScheduleTransferRequest true "Transfer details and execution time"
+// @Success      201 {object} models.SuccessResponse{data=models.ScheduledTransfer}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/transfers/schedule [post]
+func ScheduleTransfer(c *gin.Context) {
+	log := logger.WithField("operation", "api_schedule_transfer").WithContext(c.Request.Context())
+
+	log.Info("Schedule transfer request received")
+
+	var req models.ScheduleTransferRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"from_user_id": req.FromUserID,
+		"to_user_id":   req.ToUserID,
+	})
+	if _, err := uuid.Parse(req.FromUserID); err != nil {
+		log.Warn("Invalid from_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid from_user_id format"})
+		return
+	}
+	if _, err := uuid.Parse(req.ToUserID); err != nil {
+		log.Warn("Invalid to_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid to_user_id format"})
+		return
+	}
+
+	scheduled, err := services.ScheduleTransfer(c.Request.Context(), req.FromUserID, req.ToUserID, req.Amount, req.ExecuteAt)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("Scheduled transfer rejected, user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "user not found"})
+		return
+	case errors.Is(err, services.ErrSelfTransfer), errors.Is(err, services.ErrInvalidAmount):
+		log.WithField("error", err.Error()).Warn("Scheduled transfer rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to schedule transfer")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to schedule transfer"})
+		return
+	}
+
+	log.WithField("scheduled_transfer_id", scheduled.ID.String()).Info("Transfer scheduled successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Transfer scheduled successfully",
+		Data:    scheduled,
+	})
+}
+
+// GetScheduledTransfers godoc
+// @Summary      List scheduled transfers
+// @Description  List the transfers a user has scheduled, including executed and failed ones
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=[]models.ScheduledTransfer}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/scheduled-transfers [get]
+func GetScheduledTransfers(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_scheduled_transfers")
+
+	log.Info("Scheduled transfers request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+
+	transfers, err := services.GetScheduledTransfers(c.Request.Context(), userID)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get scheduled transfers")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get scheduled transfers"})
+		return
+	}
+	if transfers == nil {
+		transfers = []models.ScheduledTransfer{}
+	}
+
+	log.WithField("count", len(transfers)).Info("Scheduled transfers retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Scheduled transfers retrieved successfully",
+		Data:    transfers,
+	})
+}
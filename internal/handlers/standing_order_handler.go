@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateStandingOrder godoc
+// @Summary      Create a standing order
+// @Description  Set up a transfer repeated daily, weekly or monthly from start_at, until end_at or max_occurrences is reached. A failed occurrence is recorded and the series continues, unless several occurrences in a row fail.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        user_id path string true "Sender user ID"
+// @Param        order body models.StandingOrderRequest true "Recipient, amount and recurrence"
+// @Success      201 {object} models.SuccessResponse{data=models.StandingOrder}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/standing-orders [post]
+func CreateStandingOrder(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_create_standing_order")
+
+	log.Info("Create standing order request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+
+	var req models.StandingOrderRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"to_user_id": req.ToUserID,
+		"interval":   req.Interval,
+	})
+	if _, err := uuid.Parse(req.ToUserID); err != nil {
+		log.Warn("Invalid to_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid to_user_id format"})
+		return
+	}
+
+	order, err := services.CreateStandingOrder(c.Request.Context(), userID, &req)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("Standing order rejected, user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "user not found"})
+		return
+	case errors.Is(err, services.ErrSelfTransfer), errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrInvalidSchedule):
+		log.WithField("error", err.Error()).Warn("Standing order rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to create standing order")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to create standing order"})
+		return
+	}
+
+	log.WithField("standing_order_id", order.ID.String()).Info("Standing order created successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Standing order created successfully",
+		Data:    order,
+	})
+}
+
+// GetStandingOrders godoc
+// @Summary      List standing orders
+// @Description  List a user's standing orders, including finished and cancelled ones
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=[]models.StandingOrder}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/standing-orders [get]
+func GetStandingOrders(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_standing_orders")
+
+	log.Info("Standing orders request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+
+	orders, err := services.GetStandingOrders(c.Request.Context(), userID)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get standing orders")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get standing orders"})
+		return
+	}
+	if orders == nil {
+		orders = []models.StandingOrder{}
+	}
+
+	log.WithField("count", len(orders)).Info("Standing orders retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Standing orders retrieved successfully",
+		Data:    orders,
+	})
+}
+
+// GetStandingOrder godoc
+// @Summary      Get a standing order
+// @Description  Get one of a user's standing orders, with how many occurrences have been scheduled and when the next one runs
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        id path string true "Standing order ID"
+// @Success      200 {object} models.SuccessResponse{data=models.StandingOrder}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/standing-orders/{id} [get]
+func GetStandingOrder(c *gin.Context) {
+	userID, id, log, ok := standingOrderParams(c, "api_get_standing_order")
+	if !ok {
+		return
+	}
+
+	order, err := services.GetStandingOrder(c.Request.Context(), userID, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrStandingOrderNotFound):
+		log.Warn("Standing order not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "standing order not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to get standing order")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get standing order"})
+		return
+	}
+
+	log.Info("Standing order retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Standing order retrieved successfully",
+		Data:    order,
+	})
+}
+
+// CancelStandingOrder godoc
+// @Summary      Cancel a standing order
+// @Description  Stop an active standing order. Its pending occurrence is cancelled; an occurrence already executing completes.
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        id path string true "Standing order ID"
+// @Success      200 {object} models.SuccessResponse{data=models.StandingOrder}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/standing-orders/{id} [delete]
+func CancelStandingOrder(c *gin.Context) {
+	userID, id, log, ok := standingOrderParams(c, "api_cancel_standing_order")
+	if !ok {
+		return
+	}
+
+	order, err := services.CancelStandingOrder(c.Request.Context(), userID, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrStandingOrderNotFound):
+		log.Warn("Standing order not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "standing order not found"})
+		return
+	case errors.Is(err, services.ErrStandingOrderNotActive):
+		log.WithField("error", err.Error()).Warn("Standing order cancellation conflict")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to cancel standing order")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to cancel standing order"})
+		return
+	}
+
+	log.Info("Standing order cancelled successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Standing order cancelled successfully",
+		Data:    order,
+	})
+}
+
+// standingOrderParams validates the user_id and id path parameters, writing a 400
+// response and returning ok=false when either is not a UUID
+func standingOrderParams(c *gin.Context, operation string) (userID, id string, log *logrus.Entry, ok bool) {
+	userID, id = c.Param("user_id"), c.Param("id")
+	log = logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"standing_order_id": id,
+		"operation":         operation,
+	})
+
+	log.Info("Standing order request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return "", "", nil, false
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid standing order id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid standing order id format"})
+		return "", "", nil, false
+	}
+	return userID, id, log, true
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
-	"context"
-	"math"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 	"walletapp/internal/logger"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
 	"walletapp/internal/repositories"
 	"walletapp/internal/services"
 
@@ -16,31 +21,57 @@ import (
 )
 
 type TransferRequest struct {
-	FromUserID string  `json:"from_user_id"`
-	ToUserID   string  `json:"to_user_id"`
-	Amount     float64 `json:"amount"`
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+	// BeneficiaryID names one of the sender's saved beneficiaries instead of to_user_id
+	BeneficiaryID string `json:"beneficiary_id,omitempty"`
+	// FromWalletID and ToWalletID pick specific wallets of the two users; when left
+	// out, the user's default wallet is used
+	FromWalletID   string            `json:"from_wallet_id,omitempty"`
+	ToWalletID     string            `json:"to_wallet_id,omitempty"`
+	Amount         money.Amount      `json:"amount" swaggertype:"string" example:"25.00"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty" binding:"max=255" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	Description    string            `json:"description,omitempty" example:"rent for May"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
 }
 
 // Transfer godoc
 // @Summary      Transfer money
-// @Description  Transfer money from one user to another
+// @Description  Transfer money from one user to another, between their default wallets unless from_wallet_id or to_wallet_id picks another of their wallets. The recipient is given as to_user_id or as beneficiary_id, one of the sender's saved beneficiaries. The response includes the fee charged to the sender on top of the amount, the IDs of both transactions recorded and the sender's balance afterwards.
 // @Tags         wallet
 // @Accept       json
 // @Produce      json
 // @Param        transfer body TransferRequest true "Transfer details"
-// @Success      200 {object} models.SuccessResponse
+// @Success      200 {object} models.SuccessResponse{data=models.TransferResult}
 // @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
 // @Router       /v1/wallets/transfer [post]
 func Transfer(c *gin.Context) {
-	log := logger.WithField("operation", "api_transfer")
+	log := logger.WithField("operation", "api_transfer").WithContext(c.Request.Context())
 
 	log.Info("Transfer request received")
 
 	var req TransferRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:  models.CodeValidation,
+			Error: money.ErrTooManyDecimals.Error(),
+		})
+		return
+	} else if err != nil {
 		log.WithField("error", err.Error()).Warn("Invalid request body")
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: err.Error(),
+			Code:    models.CodeValidation,
+			Error:   err.Error(),
+			Details: validationDetails(err),
 		})
 		return
 	}
@@ -55,62 +86,253 @@ func Transfer(c *gin.Context) {
 	if _, err := uuid.Parse(req.FromUserID); err != nil {
 		log.WithField("from_user_id", req.FromUserID).Warn("Invalid from_user_id format")
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:  models.CodeValidation,
 			Error: "invalid from_user_id format",
 		})
 		return
 	}
+	if req.BeneficiaryID != "" && !resolveBeneficiary(c, log, &req) {
+		return
+	}
 	if _, err := uuid.Parse(req.ToUserID); err != nil {
 		log.WithField("to_user_id", req.ToUserID).Warn("Invalid to_user_id format")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "invalid to_user_id format"})
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid to_user_id format"})
 		return
 	}
 
-	// Validate amount
-	if req.Amount <= 0 {
-		log.WithField("amount", req.Amount).Warn("Invalid amount: must be positive")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "amount must be positive"})
+	// Check if users exist
+	ctx := c.Request.Context()
+	_, err := userRepo.GetUserByID(ctx, req.FromUserID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		log.WithField("from_user_id", req.FromUserID).Warn("From user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "from_user_id not found"})
 		return
 	}
-	if req.Amount > 1000000 { // $1M limit
-		log.WithField("amount", req.Amount).Warn("Amount exceeds maximum limit")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "amount exceeds maximum limit"})
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
 		return
 	}
-	if math.IsNaN(req.Amount) || math.IsInf(req.Amount, 0) {
-		log.WithField("amount", req.Amount).Warn("Invalid amount: NaN or infinity")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "invalid amount"})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to look up from user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to look up from_user_id"})
+		return
+	}
+	_, err = userRepo.GetUserByID(ctx, req.ToUserID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		log.WithField("to_user_id", req.ToUserID).Warn("To user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "to_user_id not found"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to look up to user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to look up to_user_id"})
 		return
 	}
 
-	// Check if users exist
-	ctx := context.Background()
-	_, err := repositories.GetUserByID(ctx, req.FromUserID)
+	var result *models.TransferResult
+	if req.FromWalletID == "" && req.ToWalletID == "" {
+		result, err = services.Transfer(ctx, req.FromUserID, req.ToUserID, req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
+	} else {
+		fromWalletID, ok := transferWalletID(c, log, req.FromUserID, req.FromWalletID, "from_wallet_id")
+		if !ok {
+			return
+		}
+		toWalletID, ok := transferWalletID(c, log, req.ToUserID, req.ToWalletID, "to_wallet_id")
+		if !ok {
+			return
+		}
+		result, err = services.TransferWallets(ctx, fromWalletID, toWalletID, req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
+	}
 	if err != nil {
-		log.WithField("from_user_id", req.FromUserID).Warn("From user not found")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "from_user_id not found"})
+		respondServiceError(c, log, err, "Transfer")
 		return
 	}
-	_, err = repositories.GetUserByID(ctx, req.ToUserID)
+
+	log.WithFields(logrus.Fields{
+		"transfer_id": result.TransferID.String(),
+		"fee":         result.Fee,
+	}).Info("Transfer completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Transfer successful",
+		Data:    result,
+	})
+}
+
+// resolveBeneficiary replaces the beneficiary_id of a transfer request with the user
+// it names. Beneficiaries of other users are reported as not found.
+func resolveBeneficiary(c *gin.Context, log *logrus.Entry, req *TransferRequest) bool {
+	log = log.WithField("beneficiary_id", req.BeneficiaryID)
+	if req.ToUserID != "" {
+		log.Warn("Both to_user_id and beneficiary_id given")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "give either to_user_id or beneficiary_id, not both"})
+		return false
+	}
+	if _, err := uuid.Parse(req.BeneficiaryID); err != nil {
+		log.Warn("Invalid beneficiary_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid beneficiary_id format"})
+		return false
+	}
+
+	toUserID, err := services.ResolveBeneficiary(c.Request.Context(), req.FromUserID, req.BeneficiaryID)
+	if errors.Is(err, services.ErrBeneficiaryNotFound) {
+		log.Warn("Beneficiary not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "beneficiary_id not found"})
+		return false
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return false
+	}
 	if err != nil {
-		log.WithField("to_user_id", req.ToUserID).Warn("To user not found")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "to_user_id not found"})
+		log.WithField("error", err.Error()).Error("Failed to look up beneficiary")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to look up beneficiary_id"})
+		return false
+	}
+	req.ToUserID = toUserID
+	return true
+}
+
+type BatchTransferRequest struct {
+	FromUserID string              `json:"from_user_id"`
+	Items      []BatchTransferItem `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+type BatchTransferItem struct {
+	ToUserID string       `json:"to_user_id"`
+	Amount   money.Amount `json:"amount" swaggertype:"string" example:"1500.00"`
+}
+
+// BatchTransfer godoc
+// @Summary      Transfer money to several users
+// @Description  Pay up to 100 users from one sender's default wallet, all or nothing. Each payment is charged the fee of a single transfer and the sender must cover the whole batch. When a recipient cannot be paid nothing moves, and the error names the failing item by its index.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        batch body BatchTransferRequest true "Batch transfer details"
+// @Success      200 {object} models.SuccessResponse{data=models.BatchTransferResult}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/wallets/transfers/batch [post]
+func BatchTransfer(c *gin.Context) {
+	log := logger.WithField("operation", "api_batch_transfer").WithContext(c.Request.Context())
+
+	log.Info("Batch transfer request received")
+
+	var req BatchTransferRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:  models.CodeValidation,
+			Error: money.ErrTooManyDecimals.Error(),
+		})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   err.Error(),
+			Details: validationDetails(err),
+		})
 		return
 	}
 
-	err = services.Transfer(ctx, req.FromUserID, req.ToUserID, req.Amount)
+	log = log.WithFields(logrus.Fields{
+		"from_user_id": req.FromUserID,
+		"items":        len(req.Items),
+	})
+
+	if _, err := uuid.Parse(req.FromUserID); err != nil {
+		log.WithField("from_user_id", req.FromUserID).Warn("Invalid from_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid from_user_id format"})
+		return
+	}
+	items := make([]services.BatchItem, len(req.Items))
+	for i, item := range req.Items {
+		if _, err := uuid.Parse(item.ToUserID); err != nil {
+			log.WithField("to_user_id", item.ToUserID).Warn("Invalid to_user_id format")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: fmt.Sprintf("item %d: invalid to_user_id format", i)})
+			return
+		}
+		items[i] = services.BatchItem{ToUserID: item.ToUserID, Amount: item.Amount}
+	}
+
+	ctx := c.Request.Context()
+	_, err := userRepo.GetUserByID(ctx, req.FromUserID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		log.Warn("From user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "from_user_id not found"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to look up from user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to look up from_user_id"})
+		return
+	}
+
+	// Errors about a single recipient name the item, so they are passed on as they are
+	result, err := services.BatchTransfer(ctx, req.FromUserID, items)
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Transfer operation failed")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		respondServiceError(c, log, err, "Batch transfer")
 		return
 	}
 
-	log.Info("Transfer completed successfully")
+	log.WithFields(logrus.Fields{
+		"batch_id": result.BatchID.String(),
+		"total":    result.Total,
+		"fee":      result.Fee,
+	}).Info("Batch transfer completed successfully")
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Code:    200,
-		Message: "Transfer successful",
+		Message: "Batch transfer successful",
+		Data:    result,
 	})
 }
 
+// transferWalletID returns the ID of the wallet of userID a transfer uses: walletID
+// when given, which must belong to the user, otherwise the user's default wallet. It
+// responds 400 or 404, naming field, when there is no such wallet.
+func transferWalletID(c *gin.Context, log *logrus.Entry, userID, walletID, field string) (string, bool) {
+	var wallet *models.Wallet
+	var err error
+	if walletID == "" {
+		wallet, err = services.GetWallet(c.Request.Context(), userID)
+	} else {
+		wallet, err = walletOf(c.Request.Context(), userID, walletID)
+	}
+	switch {
+	case err == nil:
+		return wallet.ID.String(), true
+	case errors.Is(err, errInvalidWalletID):
+		log.WithField(field, walletID).Warn("Invalid wallet ID format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid " + field + " format"})
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField(field, walletID).Warn("Transfer wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: field + " not found"})
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+	default:
+		log.WithField("error", err.Error()).Error("Failed to look up transfer wallet")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to look up " + field})
+	}
+	return "", false
+}
+
 // GetTransactionHistory godoc
 // @Summary      Get transaction history
 // @Description  Get user's wallet transaction history with pagination
@@ -119,19 +341,32 @@ func Transfer(c *gin.Context) {
 // @Param        user_id path string true "User ID"
 // @Param        limit query int false "Number of transactions to return (default: 50, max: 100)"
 // @Param        offset query int false "Number of transactions to skip (default: 0)"
-// @Success      200 {object} models.SuccessResponse{data=[]models.Transaction}
+// @Param        cursor query string false "next_cursor of the previous page, to continue after it instead of skipping offset transactions"
+// @Param        sort query string false "Order as field:direction, where field is created_at or amount and direction asc or desc (default: created_at:desc)"
+// @Param        status query string false "Only return transactions in this status" Enums(PENDING, COMPLETED, FAILED)
+// @Param        type query []string false "Only return transactions of these types, repeated or comma-separated" collectionFormat(multi)
+// @Param        from query string false "Only return transactions created at or after this RFC3339 time"
+// @Param        to query string false "Only return transactions created at or before this RFC3339 time"
+// @Param        min_amount query string false "Only return transactions of at least this amount" example(500.00)
+// @Param        max_amount query string false "Only return transactions of at most this amount" example(1000.00)
+// @Param        metadata_key query string false "Only return transactions whose metadata has this key, set to metadata_value"
+// @Param        metadata_value query string false "Value of metadata_key to match"
+// @Param        include_archived query bool false "Also return archived transactions, those older than the retention, when from reaches back to them (default: false)"
+// @Success      200 {object} models.SuccessResponse{data=models.TransactionPage}
+// @Failure      400 {object} models.ErrorResponse
 // @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
 // @Router       /v1/wallets/{user_id}/transactions [get]
 func GetTransactionHistory(c *gin.Context) {
 	userID := c.Param("user_id")
-	log := logger.WithUser(userID).WithField("operation", "api_get_transaction_history")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_transaction_history")
 
 	log.Info("Transaction history request received")
 
 	// Validate user ID format
 	if _, err := uuid.Parse(userID); err != nil {
 		log.WithField("user_id", userID).Warn("Invalid user_id format")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "invalid user_id format"})
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
 		return
 	}
 
@@ -142,7 +377,7 @@ func GetTransactionHistory(c *gin.Context) {
 			limit = parsed
 		} else {
 			log.WithField("limit", limitStr).Warn("Invalid limit parameter")
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "limit must be between 1 and 100"})
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "limit must be between 1 and 100"})
 			return
 		}
 	}
@@ -153,47 +388,468 @@ func GetTransactionHistory(c *gin.Context) {
 			offset = parsed
 		} else {
 			log.WithField("offset", offsetStr).Warn("Invalid offset parameter")
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "offset must be non-negative"})
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "offset must be non-negative"})
 			return
 		}
 	}
 
+	var cursor *historyCursor
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		parsed, err := decodeHistoryCursor(cursorStr)
+		if err != nil {
+			log.WithField("cursor", cursorStr).Warn("Invalid cursor parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid cursor"})
+			return
+		}
+		if c.Query("offset") != "" {
+			log.Warn("cursor given with offset")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "cursor cannot be combined with offset"})
+			return
+		}
+		cursor = parsed
+	}
+
+	filter, ok := parseTransactionFilter(c, log)
+	if !ok {
+		return
+	}
+
+	var sort repositories.TransactionSort
+	if sortStr := c.Query("sort"); sortStr != "" {
+		parsed, err := repositories.ParseTransactionSort(sortStr)
+		if err != nil {
+			log.WithField("sort", sortStr).Warn("Invalid sort parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "sort must be created_at or amount, optionally followed by :asc or :desc"})
+			return
+		}
+		sort = parsed
+	}
+	// Cursors mark a place in newest-first order
+	if cursor != nil && !sort.IsDefault() {
+		log.WithField("sort", c.Query("sort")).Warn("cursor given with a sort")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "cursor can only be used with the default created_at:desc sort"})
+		return
+	}
+
 	log.WithFields(logrus.Fields{
 		"limit":  limit,
 		"offset": offset,
+		"status": filter.Status,
+		"types":  filter.Types,
 	}).Debug("Pagination parameters")
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	wallet, err := services.GetWallet(ctx, userID)
+	if errors.Is(err, services.ErrWalletNotFound) {
+		log.WithField("error", err.Error()).Warn("Wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
 	if err != nil {
 		log.WithField("error", err.Error()).Error("Failed to get wallet")
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get wallet"})
 		return
 	}
 
-	txs, err := repositories.GetTransactionsByWalletID(ctx, wallet.ID.String())
+	page := models.TransactionPage{Limit: limit, Offset: offset}
+	if cursor != nil {
+		var remaining int
+		page.Items, remaining, err = transactionRepo.GetTransactionsAfterCursor(ctx, wallet.ID.String(), filter, cursor.createdAt, cursor.id.String(), limit)
+		if err == nil {
+			page.HasMore = remaining > len(page.Items)
+			page.Total, err = transactionRepo.CountTransactionsByWalletID(ctx, wallet.ID.String(), filter)
+		}
+	} else {
+		page.Items, page.Total, err = transactionRepo.GetTransactionsByWalletID(ctx, wallet.ID.String(), filter, sort, limit, offset)
+		page.HasMore = offset+len(page.Items) < page.Total
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
 	if err != nil {
 		log.WithField("error", err.Error()).Error("Failed to get transactions")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: err.Error()})
 		return
 	}
+	if page.Items == nil {
+		page.Items = []models.Transaction{}
+	}
 
-	// Apply pagination
-	start := offset
-	end := offset + limit
-	if start >= len(txs) {
-		txs = []models.Transaction{}
-	} else if end > len(txs) {
-		txs = txs[start:]
-	} else {
-		txs = txs[start:end]
+	// The cursor of the last transaction fetches the next page, whichever mode this
+	// page was fetched in
+	var nextCursor string
+	if page.HasMore && sort.IsDefault() {
+		last := page.Items[len(page.Items)-1]
+		nextCursor = encodeHistoryCursor(historyCursor{createdAt: last.CreatedAt, id: last.ID})
 	}
 
-	log.WithField("transaction_count", len(txs)).Info("Transaction history retrieved successfully")
+	log.WithFields(logrus.Fields{
+		"transaction_count": len(page.Items),
+		"total":             page.Total,
+	}).Info("Transaction history retrieved successfully")
 	// Return success response
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:       200,
+		Message:    "Transaction history retrieved successfully",
+		Data:       page,
+		NextCursor: nextCursor,
+	})
+}
+
+// parseTransactionFilter reads the filter of a wallet's history from the status, type,
+// from, to, min_amount, max_amount, metadata_key, metadata_value and include_archived
+// query parameters. It responds 400 and returns false when one is invalid.
+func parseTransactionFilter(c *gin.Context, log *logrus.Entry) (repositories.TransactionFilter, bool) {
+	status := models.TransactionStatus(strings.ToUpper(c.Query("status")))
+	switch status {
+	case "", models.TransactionStatusPending, models.TransactionStatusCompleted, models.TransactionStatusFailed:
+	default:
+		log.WithField("status", c.Query("status")).Warn("Invalid status parameter")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "status must be one of PENDING, COMPLETED, FAILED"})
+		return repositories.TransactionFilter{}, false
+	}
+
+	var types []models.TransactionType
+	for _, param := range c.QueryArray("type") {
+		for _, name := range strings.Split(param, ",") {
+			t := models.TransactionType(strings.ToUpper(strings.TrimSpace(name)))
+			if !slices.Contains(models.TransactionTypes, t) {
+				log.WithField("type", name).Warn("Invalid type parameter")
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: fmt.Sprintf("type %q is not a transaction type", name)})
+				return repositories.TransactionFilter{}, false
+			}
+			types = append(types, t)
+		}
+	}
+
+	var from, to time.Time
+	for _, p := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		instant, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			log.WithField(p.name, raw).Warn("Invalid date parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: p.name + " must be an RFC3339 time, e.g. 2024-03-01T00:00:00Z"})
+			return repositories.TransactionFilter{}, false
+		}
+		*p.target = instant
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		log.WithFields(logrus.Fields{"from": from, "to": to}).Warn("from after to")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "from must not be after to"})
+		return repositories.TransactionFilter{}, false
+	}
+
+	var minAmount, maxAmount *money.Amount
+	for _, p := range []struct {
+		name   string
+		target **money.Amount
+	}{{"min_amount", &minAmount}, {"max_amount", &maxAmount}} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		amount, err := money.Parse(raw)
+		if err != nil || amount < 0 {
+			log.WithField(p.name, raw).Warn("Invalid amount parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: p.name + " must be a non-negative amount, e.g. 500.00"})
+			return repositories.TransactionFilter{}, false
+		}
+		*p.target = &amount
+	}
+	if minAmount != nil && maxAmount != nil && *minAmount > *maxAmount {
+		log.WithFields(logrus.Fields{"min_amount": *minAmount, "max_amount": *maxAmount}).Warn("min_amount above max_amount")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "min_amount must not be greater than max_amount"})
+		return repositories.TransactionFilter{}, false
+	}
+
+	metadataKey, metadataValue := c.Query("metadata_key"), c.Query("metadata_value")
+	if metadataKey == "" && metadataValue != "" {
+		log.Warn("metadata_value given without metadata_key")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "metadata_value requires metadata_key"})
+		return repositories.TransactionFilter{}, false
+	}
+
+	var includeArchived bool
+	if raw := c.Query("include_archived"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.WithField("include_archived", raw).Warn("Invalid include_archived parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "include_archived must be true or false"})
+			return repositories.TransactionFilter{}, false
+		}
+		// The archive is only read when the history reaches back past the retention
+		includeArchived = parsed && services.ArchiveReaches(from)
+	}
+	return repositories.TransactionFilter{
+		Status:          status,
+		Types:           types,
+		From:            from,
+		To:              to,
+		MinAmount:       minAmount,
+		MaxAmount:       maxAmount,
+		MetadataKey:     metadataKey,
+		MetadataValue:   metadataValue,
+		IncludeArchived: includeArchived,
+	}, true
+}
+
+// historyCursor marks a transaction in a wallet's history, which is ordered by
+// (created_at, id), so the next page can start right after it
+type historyCursor struct {
+	createdAt time.Time
+	id        uuid.UUID
+}
+
+// encodeHistoryCursor encodes c as an opaque, URL-safe string
+func encodeHistoryCursor(c historyCursor) string {
+	raw := c.createdAt.UTC().Format(time.RFC3339Nano) + "|" + c.id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeHistoryCursor decodes a cursor made by encodeHistoryCursor
+func decodeHistoryCursor(s string) (*historyCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("malformed cursor")
+	}
+	var c historyCursor
+	if c.createdAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+	if c.id, err = uuid.Parse(id); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetWalletSummary godoc
+// @Summary      Get wallet summary
+// @Description  Totals of the wallet's completed transactions per type over a period of UTC days, with transaction counts, the net change and the opening and closing balance. Bonuses and interest are reported apart from deposits. The period defaults to the current month and can span at most a year.
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        from query string false "First day of the period, YYYY-MM-DD (default: first day of the current month)"
+// @Param        to query string false "Last day of the period, YYYY-MM-DD (default: today)"
+// @Success      200 {object} models.SuccessResponse{data=models.WalletSummary}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/summary [get]
+func GetWalletSummary(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_wallet_summary")
+
+	log.Info("Wallet summary request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.WithField("user_id", userID).Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	var from, to time.Time
+	for _, p := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			log.WithField(p.name, raw).Warn("Invalid date parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: p.name + " must be a date in YYYY-MM-DD format"})
+			return
+		}
+		*p.target = day
+	}
+
+	summary, err := services.GetWalletSummary(c.Request.Context(), userID, from, to)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidDateRange):
+		log.WithField("error", err.Error()).Warn("Invalid summary period")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to get wallet summary")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get wallet summary"})
+		return
+	}
+
+	log.WithField("transaction_count", summary.TransactionCount).Info("Wallet summary retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallet summary retrieved successfully",
+		Data:    summary,
+	})
+}
+
+// GetWalletStatement godoc
+// @Summary      Get monthly statement
+// @Description  Statement of the wallet for a UTC calendar month: the opening and closing balance, the totals of its completed transactions per type, and a page of those transactions, oldest first. Months that have not started yet are rejected; months before the wallet was created have an empty statement that opens and closes at zero.
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        year path int true "Year of the month" example(2024)
+// @Param        month path int true "Month, 1 to 12" example(3)
+// @Param        limit query int false "Number of transactions to return (default: 50, max: 100)"
+// @Param        offset query int false "Number of transactions to skip (default: 0)"
+// @Success      200 {object} models.SuccessResponse{data=models.WalletStatement}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/statements/{year}/{month} [get]
+func GetWalletStatement(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_wallet_statement")
+
+	log.Info("Wallet statement request received")
+
+	if _, err := uuid.Parse(userID); err != nil {
+		log.WithField("user_id", userID).Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user_id format"})
+		return
+	}
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil || year < 1 || year > 9999 {
+		log.WithField("year", c.Param("year")).Warn("Invalid year parameter")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "year must be a four-digit year"})
+		return
+	}
+	month, err := strconv.Atoi(c.Param("month"))
+	if err != nil || month < 1 || month > 12 {
+		log.WithField("month", c.Param("month")).Warn("Invalid month parameter")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "month must be between 1 and 12"})
+		return
+	}
+
+	limit := 50 // default
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		} else {
+			log.WithField("limit", limitStr).Warn("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "limit must be between 1 and 100"})
+			return
+		}
+	}
+	offset := 0 // default
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+			offset = parsed
+		} else {
+			log.WithField("offset", offsetStr).Warn("Invalid offset parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "offset must be non-negative"})
+			return
+		}
+	}
+
+	statement, err := services.GetWalletStatement(c.Request.Context(), userID, year, time.Month(month), limit, offset)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidDateRange):
+		log.WithField("error", err.Error()).Warn("Invalid statement month")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.WithField("error", err.Error()).Warn("Wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to get wallet statement")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get wallet statement"})
+		return
+	}
+
+	log.WithField("transaction_count", statement.TransactionCount).Info("Wallet statement retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallet statement retrieved successfully",
+		Data:    statement,
+	})
+}
+
+// GetTransaction godoc
+// @Summary      Get a transaction
+// @Description  A single transaction of one of the requesting user's wallets, for receipts and deep links. Transfers include the other user's username and completed transactions the wallet's balance right after them.
+// @Tags         wallet
+// @Produce      json
+// @Param        id path string true "Transaction ID"
+// @Param        user_id query string true "ID of the requesting user, who must own the transaction's wallet"
+// @Success      200 {object} models.SuccessResponse{data=models.TransactionDetail}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/transactions/{id} [get]
+func GetTransaction(c *gin.Context) {
+	id, userID := c.Param("id"), c.Query("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"operation":      "api_get_transaction",
+		"transaction_id": id,
+	})
+
+	log.Info("Get transaction request received")
+
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid transaction id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid transaction id format"})
+		return
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "user_id is required and must be a valid UUID"})
+		return
+	}
+
+	detail, err := services.GetTransaction(c.Request.Context(), userID, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "transaction not found"})
+		return
+	case errors.Is(err, services.ErrNotTransactionOwner):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Code: models.CodeForbidden, Error: services.ErrNotTransactionOwner.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to get transaction")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get transaction"})
+		return
+	}
+
+	log.Info("Transaction retrieved successfully")
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Code:    200,
-		Message: "Transaction history retrieved successfully",
-		Data:    txs,
+		Message: "Transaction retrieved successfully",
+		Data:    detail,
 	})
 }
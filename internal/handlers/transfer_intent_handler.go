@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateTransferIntent godoc
+// @Summary      Create a transfer intent
+// @Description  Check that a transfer would go through now, without moving any money, and return a PENDING intent with the fee it would be charged. Confirm the intent before expires_at to execute the transfer.
+// @Tags         wallets
+// @Accept       json
+// @Produce      json
+// @Param        request body models.CreateTransferIntentRequest true "Transfer details"
+// @Success      201 {object} models.SuccessResponse{data=models.TransferIntent}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/transfers/intents [post]
+func CreateTransferIntent(c *gin.Context) {
+	log := logger.WithField("operation", "api_create_transfer_intent").WithContext(c.Request.Context())
+
+	log.Info("Transfer intent request received")
+
+	var req models.CreateTransferIntentRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"from_user_id": req.FromUserID,
+		"to_user_id":   req.ToUserID,
+	})
+	if _, err := uuid.Parse(req.FromUserID); err != nil {
+		log.Warn("Invalid from_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid from_user_id format"})
+		return
+	}
+	if _, err := uuid.Parse(req.ToUserID); err != nil {
+		log.Warn("Invalid to_user_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid to_user_id format"})
+		return
+	}
+
+	intent, err := services.CreateTransferIntent(c.Request.Context(), req.FromUserID, req.ToUserID, req.Amount)
+	if !handleTransferIntentError(c, log, "create", err) {
+		return
+	}
+
+	log.WithField("transfer_intent_id", intent.ID.String()).Info("Transfer intent created successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Transfer intent created successfully",
+		Data:    intent,
+	})
+}
+
+// ConfirmTransferIntent godoc
+// @Summary      Confirm a transfer intent
+// @Description  Execute a pending transfer intent before it expires. Every check is made again, so the transfer can still fail if the balance, limits or wallets changed. Confirming an intent twice transfers once; the second attempt returns 409.
+// @Tags         wallets
+// @Produce      json
+// @Param        id path string true "Transfer intent ID"
+// @Success      200 {object} models.SuccessResponse{data=models.TransferIntent}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/wallets/transfers/intents/{id}/confirm [post]
+func ConfirmTransferIntent(c *gin.Context) {
+	intentID := c.Param("id")
+	log := logger.WithFields(logrus.Fields{
+		"operation":          "api_confirm_transfer_intent",
+		"transfer_intent_id": intentID,
+	}).WithContext(c.Request.Context())
+
+	log.Info("Transfer intent confirmation received")
+
+	if _, err := uuid.Parse(intentID); err != nil {
+		log.Warn("Invalid transfer intent id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid transfer intent id format"})
+		return
+	}
+
+	intent, err := services.ConfirmTransferIntent(c.Request.Context(), intentID)
+	if !handleTransferIntentError(c, log, "confirm", err) {
+		return
+	}
+
+	log.WithField("transfer_id", intent.TransferID.String()).Info("Transfer intent confirmed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Transfer intent confirmed successfully",
+		Data:    intent,
+	})
+}
+
+// handleTransferIntentError writes the response for a failed create or confirm and
+// reports whether err was nil, so the caller should go on to write its own response
+func handleTransferIntentError(c *gin.Context, log *logrus.Entry, action string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrTransferIntentNotFound):
+		log.WithField("error", err.Error()).Warn("Transfer intent not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "transfer intent not found"})
+	case errors.Is(err, services.ErrTransferIntentConfirmed):
+		log.WithField("error", err.Error()).Warn("Transfer intent was already confirmed")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+	case errors.Is(err, services.ErrTransferIntentExpired):
+		log.WithField("error", err.Error()).Warn("Transfer intent has expired")
+		c.JSON(http.StatusGone, models.ErrorResponse{Code: models.CodeGone, Error: err.Error()})
+	case errors.Is(err, services.ErrSelfTransfer), errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrCurrencyMismatch):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+	case errors.Is(err, services.ErrInsufficientBalance):
+		log.Warn("Transfer intent rejected due to insufficient balance")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeInsufficientBalance, Error: "insufficient balance"})
+	case errors.Is(err, services.ErrMonthlyLimitExceeded):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected due to the monthly limit")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeLimitExceeded, Error: err.Error()})
+	case errors.Is(err, services.ErrKycLimitExceeded):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected by KYC tier limits")
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Code: models.CodeLimitExceeded, Error: err.Error()})
+	case errors.Is(err, services.ErrTooManyOperations):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected, too many operations")
+		rejectTooManyOperations(c, err)
+	case errors.Is(err, services.ErrWalletFrozen):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected, wallet is frozen")
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Code: models.CodeWalletFrozen, Error: services.ErrWalletFrozen.Error()})
+	case errors.Is(err, services.ErrWalletClosed):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected, wallet is closed")
+		c.JSON(http.StatusGone, models.ErrorResponse{Code: models.CodeWalletClosed, Error: services.ErrWalletClosed.Error()})
+	case errors.Is(err, services.ErrWalletNotFound), errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected, wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeNotFound, Error: "wallet not found"})
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn("Transfer intent " + action + " aborted due to concurrent updates")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConcurrentUpdate, Error: "Transfer intent conflicted with concurrent updates, please try again"})
+	case errors.Is(err, services.ErrCommitFailed):
+		log.WithField("error", err.Error()).Error("Transfer intent " + action + " could not be committed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Transfer intent could not be saved, please try again"})
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+	default:
+		log.WithField("error", err.Error()).Error("Transfer intent " + action + " failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to " + action + " transfer intent"})
+	}
+	return false
+}
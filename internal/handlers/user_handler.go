@@ -1,52 +1,91 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"walletapp/internal/logger"
 	"walletapp/internal/models"
 	"walletapp/internal/repositories"
 	"walletapp/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // GetUsers godoc
-// @Summary      List all users
-// @Description  get all users
+// @Summary      List users
+// @Description  list users, newest first, a page at a time. q narrows the list to users whose username, email, first or last name contains it, ignoring case
 // @Tags         users
 // @Produce      json
+// @Param        q       query     string  false  "Text to search for in usernames, emails and names"
+// @Param        limit   query     int     false  "Number of users to return (default: 50, max: 100)"
+// @Param        offset  query     int     false  "Number of users to skip (default: 0)"
 // @Success      200  {object}  models.SuccessResponse{data=[]models.UserResponse}
+// @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
 // @Router       /v1/users [get]
 func GetUsers(c *gin.Context) {
-	log := logger.Get()
-	log.Info("Getting all users")
+	query := strings.TrimSpace(c.Query("q"))
+	log := logger.Get().WithField("query", query).WithContext(c.Request.Context())
+	log.Info("Getting users")
 
-	ctx := context.Background()
-	users, err := repositories.GetAllUsers(ctx)
+	limit := 50 // default
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		} else {
+			log.WithField("limit", limitStr).Warn("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "limit must be between 1 and 100"})
+			return
+		}
+	}
+	offset := 0 // default
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+			offset = parsed
+		} else {
+			log.WithField("offset", offsetStr).Warn("Invalid offset parameter")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "offset must be non-negative"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	users, total, err := userRepo.SearchUsers(ctx, query, limit, offset)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("Failed to get all users")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		log.WithError(err).Error("Failed to get users")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: err.Error()})
 		return
 	}
 
-	log.WithField("count", len(users)).Info("Retrieved users successfully")
+	log.WithFields(logrus.Fields{
+		"count": len(users),
+		"total": total,
+	}).Info("Retrieved users successfully")
 
-	var resp []models.UserResponse
-	for _, u := range users {
-		// Get wallet for the user
-		wallet, err := repositories.GetWalletByUserID(ctx, u.ID.String())
-		if err != nil {
-			log.WithError(err).WithField("user_id", u.ID.String()).Warn("Failed to get wallet for user, including user with nil wallet")
-			// Include user with nil wallet
-			resp = append(resp, toUserResponse(&u, nil))
-			continue
-		}
+	// Load every user's wallet in one query rather than one per user
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID.String()
+	}
+	wallets, err := walletRepo.GetWalletsByUserIDs(ctx, ids)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get wallets for users, including users with nil wallets")
+	}
 
-		resp = append(resp, toUserResponse(&u, wallet))
+	resp := []models.UserResponse{}
+	for _, u := range users {
+		// Users without a wallet are included with a nil wallet
+		resp = append(resp, toUserResponse(&u, wallets[u.ID.String()]))
 	}
 
 	// Return success response
@@ -54,6 +93,12 @@ func GetUsers(c *gin.Context) {
 		Code:    200,
 		Message: "Users retrieved successfully",
 		Data:    resp,
+		Pagination: &models.Pagination{
+			Total:   total,
+			Limit:   limit,
+			Offset:  offset,
+			HasMore: offset+len(users) < total,
+		},
 	})
 }
 
@@ -65,25 +110,44 @@ func GetUsers(c *gin.Context) {
 // @Param        id   path      string  true  "User ID"
 // @Success      200  {object}  models.UserResponse
 // @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
 // @Router       /v1/users/{id} [get]
 func GetUserByID(c *gin.Context) {
 	id := c.Param("id")
-	log := logger.Get().WithField("user_id", id)
+	log := logger.Get().WithField("user_id", id).WithContext(c.Request.Context())
 	log.Info("Getting user by ID")
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	// Get user by ID
-	user, err := repositories.GetUserByID(ctx, id)
+	user, err := userRepo.GetUserByID(ctx, id)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		log.WithError(err).Warn("User not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "User not found"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("User not found")
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		log.WithError(err).Error("Failed to get user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get user"})
 		return
 	}
 	// Get wallet for the user
-	wallet, err := repositories.GetWalletByUserID(ctx, id)
+	wallet, err := walletRepo.GetWalletByUserID(ctx, id)
+	if errors.Is(err, repositories.ErrWalletNotFound) {
+		log.WithError(err).Warn("Wallet not found for user")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "Wallet not found"})
+		return
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("Wallet not found for user")
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Wallet not found"})
+		log.WithError(err).Error("Failed to get wallet for user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get wallet"})
 		return
 	}
 
@@ -98,55 +162,318 @@ func GetUserByID(c *gin.Context) {
 
 // CreateUser godoc
 // @Summary      Create user
-// @Description  create a new user, wallet will be created automatically after user creation
+// @Description  create a new user, wallet will be created automatically after user creation and credited with initial_balance when given. A referral_code credits the referral bonus to the user who shared it
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        user body models.CreateUserRequest true "User to create"
 // @Success      201   {object}  models.SuccessResponse{data=models.UserResponse}
+// @Header       201   {string}  Location  "URL of the new user"
 // @Failure      400   {object}  models.ErrorResponse
+// @Failure      409   {object}  models.ErrorResponse
 // @Failure      500   {object}  models.ErrorResponse
 // @Router       /v1/users [post]
 func CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		logger.Get().WithError(err).Error("Invalid request body for user creation")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error(), Details: validationDetails(err)})
 		return
 	}
 
 	log := logger.Get().WithFields(map[string]interface{}{
 		"username": req.Username,
 		"email":    req.Email,
-	})
+	}).WithContext(c.Request.Context())
 	log.Info("Creating new user")
 
 	// Hash the password before saving
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to hash password")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to hash password"})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to hash password"})
 		return
 	}
 	req.Password = string(hashedPassword)
 
-	ctx := context.Background()
-	user, err := services.CreateUserWithWallet(ctx, &req)
+	ctx := c.Request.Context()
+	user, wallet, err := services.CreateUserWithWallet(ctx, &req)
 	if err != nil {
-		if err, ok := err.(*pgconn.PgError); ok && err.Code == "23505" {
-			// 23505 is unique_violation in Postgres
+		if errors.Is(err, services.ErrInvalidAmount) {
+			log.WithError(err).Warn("User creation failed - invalid initial balance")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidReferralCode) {
+			log.WithError(err).Warn("User creation failed - invalid referral code")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrEmailTaken) || errors.Is(err, services.ErrUsernameTaken) {
 			log.WithError(err).Warn("User creation failed - email or username already exists")
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Email or username already exists"})
+			c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
 			return
 		}
 
 		log.WithError(err).Error("Failed to create user")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to create user"})
 		return
 	}
 
 	log.WithField("user_id", user.ID.String()).Info("User created successfully")
-	c.JSON(http.StatusCreated, user)
+	// The route's full path includes the group it is mounted under, such as /api
+	c.Header("Location", path.Join(c.FullPath(), user.ID.String()))
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "User created successfully",
+		Data:    toUserResponse(user, wallet),
+	})
+}
+
+// UpdateUser godoc
+// @Summary      Update user
+// @Description  Change a user's first name, last name or email. Fields left out keep their current value
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string                    true  "User ID"
+// @Param        user  body      models.UpdateUserRequest  true  "Fields to change"
+// @Success      200   {object}  models.SuccessResponse{data=models.UserResponse}
+// @Failure      400   {object}  models.ErrorResponse
+// @Failure      404   {object}  models.ErrorResponse
+// @Failure      409   {object}  models.ErrorResponse
+// @Failure      500   {object}  models.ErrorResponse
+// @Router       /v1/users/{id} [patch]
+func UpdateUser(c *gin.Context) {
+	id := c.Param("id")
+	log := logger.Get().WithField("user_id", id).WithContext(c.Request.Context())
+	log.Info("Updating user")
+
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+	var req models.UpdateUserRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithError(err).Warn("Invalid request body for user update")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error(), Details: validationDetails(err)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := services.UpdateUser(ctx, id, &req)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidUserUpdate):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "User not found"})
+		return
+	case errors.Is(err, services.ErrEmailTaken):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to update user"})
+		return
+	}
+
+	// The update went through, so a wallet that cannot be read leaves it out
+	wallet, err := walletRepo.GetWalletByUserID(ctx, id)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get wallet for updated user")
+		wallet = nil
+	}
+
+	log.Info("User updated successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "User updated successfully",
+		Data:    toUserResponse(user, wallet),
+	})
+}
+
+// DeleteUser godoc
+// @Summary      Delete user
+// @Description  Soft-delete a user and close their wallets. Users with money left in a wallet cannot be deleted; their transaction history is kept
+// @Tags         users
+// @Produce      json
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  models.SuccessResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      409  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /v1/users/{id} [delete]
+func DeleteUser(c *gin.Context) {
+	id := c.Param("id")
+	log := logger.Get().WithField("user_id", id).WithContext(c.Request.Context())
+	log.Info("Deleting user")
+
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+
+	err := services.DeleteUser(c.Request.Context(), id)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "User not found"})
+		return
+	case errors.Is(err, services.ErrUserHasBalance):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: err.Error()})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to delete user"})
+		return
+	}
+
+	log.Info("User deleted successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "User deleted successfully",
+	})
+}
+
+// GetReferrals godoc
+// @Summary      List referrals
+// @Description  List the users who signed up with the user's referral code, oldest first, and the bonuses earned for them
+// @Tags         users
+// @Produce      json
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.ReferralSummary}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /v1/users/{id}/referrals [get]
+func GetReferrals(c *gin.Context) {
+	id := c.Param("id")
+	log := logger.Get().WithField("user_id", id).WithContext(c.Request.Context())
+	log.Info("Getting referrals")
+
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := userRepo.GetUserByID(ctx, id); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			log.WithError(err).Warn("User not found")
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to get user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get user"})
+		return
+	}
+	referrals, err := repositories.GetReferralsByReferrerID(ctx, id)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get referrals")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get referrals"})
+		return
+	}
+
+	summary := models.ReferralSummary{Referrals: referrals}
+	if summary.Referrals == nil {
+		summary.Referrals = []models.Referral{}
+	}
+	for _, r := range referrals {
+		summary.TotalBonus += r.Bonus
+	}
+
+	log.WithField("count", len(summary.Referrals)).Info("Referrals retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Referrals retrieved successfully",
+		Data:    summary,
+	})
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  Change a user's password. The current password must be given, and the new one must be 8 to 72 bytes with a letter and a digit, and differ from the current one
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string                        true  "User ID"
+// @Param        password  body      models.ChangePasswordRequest  true  "Current and new password"
+// @Success      200       {object}  models.SuccessResponse
+// @Failure      400       {object}  models.ErrorResponse
+// @Failure      403       {object}  models.ErrorResponse
+// @Failure      404       {object}  models.ErrorResponse
+// @Failure      429       {object}  models.ErrorResponse
+// @Failure      500       {object}  models.ErrorResponse
+// @Router       /v1/users/{id}/password [post]
+func ChangePassword(c *gin.Context) {
+	id := c.Param("id")
+	log := logger.WithUser(id).WithContext(c.Request.Context()).WithField("operation", "api_change_password")
+	log.Info("Change password request received")
+
+	if _, err := uuid.Parse(id); err != nil {
+		log.Warn("Invalid user id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user id format"})
+		return
+	}
+	var req models.ChangePasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithError(err).Warn("Invalid request body for password change")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+
+	err := services.ChangePassword(c.Request.Context(), id, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		respondPasswordError(c, log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Password changed successfully",
+	})
+}
+
+// respondPasswordError writes the response for an error changing or resetting a password
+func respondPasswordError(c *gin.Context, log *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrPasswordUnchanged):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   err.Error(),
+			Details: map[string]string{"new_password": err.Error()},
+		})
+	case errors.Is(err, services.ErrAdminRequired):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+	case errors.Is(err, services.ErrWrongPassword):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Code: models.CodeForbidden, Error: err.Error()})
+	case errors.Is(err, services.ErrTooManyPasswordAttempts):
+		rejectTooManyOperations(c, err)
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "User not found"})
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to change password"})
+	}
 }
 
 // Helper to map User to UserResponse
@@ -155,20 +482,24 @@ func toUserResponse(u *models.User, wallet *models.Wallet) models.UserResponse {
 	if wallet != nil {
 		walletResp = &models.WalletResponse{
 			ID:        wallet.ID.String(),
+			Currency:  wallet.Currency,
 			Balance:   wallet.Balance,
 			CreatedAt: wallet.CreatedAt,
 			UpdatedAt: wallet.UpdatedAt,
 		}
 	}
 	return models.UserResponse{
-		ID:        u.ID,
-		Username:  u.Username,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
-		Wallet:    walletResp,
+		ID:              u.ID,
+		Username:        u.Username,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		Email:           u.Email,
+		KycTier:         u.KycTier,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		ReferralCode:    u.ReferralCode,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		Wallet:          walletResp,
 	}
 }
 
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingUserRepo is a UserRepository whose lookups wait on the database until the
+// caller gives up, reporting the context error they saw on aborted
+type blockingUserRepo struct {
+	repositories.UserRepository
+	started chan struct{}
+	aborted chan error
+}
+
+func (r *blockingUserRepo) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	close(r.started)
+	<-ctx.Done()
+	r.aborted <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestGetUserByID_ClientDisconnectAbortsQuery(t *testing.T) {
+	repo := &blockingUserRepo{started: make(chan struct{}), aborted: make(chan error, 1)}
+	SetRepositories(repo, walletRepo, transactionRepo)
+	t.Cleanup(setDefaultRepositories)
+
+	router := gin.New()
+	router.GET("/v1/users/:id", GetUserByID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/7f1c1a52-5d0a-4b43-9f6e-2d7c1c1e8a01", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	// The client goes away while the query runs
+	<-repo.started
+	cancel()
+
+	select {
+	case err := <-repo.aborted:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("the query was not aborted when the request was canceled")
+	}
+	<-done
+}
+
+// signupUserRepo is a UserTxRepo that lets any signup through and returns user as the
+// one created
+type signupUserRepo struct {
+	services.UserTxRepo
+	user *models.User
+}
+
+func (r *signupUserRepo) IsEmailExistsTx(context.Context, pgx.Tx, string) (bool, error) {
+	return false, nil
+}
+
+func (r *signupUserRepo) IsUsernameExistsTx(context.Context, pgx.Tx, string) (bool, error) {
+	return false, nil
+}
+
+func (r *signupUserRepo) CreateUserTx(context.Context, pgx.Tx, *models.CreateUserRequest) (*models.User, error) {
+	return r.user, nil
+}
+
+// signupWalletRepo is a WalletRepo that creates an empty wallet for each new user
+type signupWalletRepo struct {
+	services.WalletRepo
+}
+
+func (signupWalletRepo) CreateWalletTx(_ context.Context, _ pgx.Tx, userID string) (*models.Wallet, error) {
+	return &models.Wallet{ID: uuid.New(), UserID: uuid.MustParse(userID), Currency: "USD"}, nil
+}
+
+func TestCreateUser_LocationIncludesRouteGroup(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+
+	user := &models.User{ID: uuid.New(), Username: "newuser", Email: "new@example.com"}
+	wallets := services.NewWalletService(signupWalletRepo{}, nil, mockDB, services.WalletServiceConfig{})
+	services.SetDefaultRegistrationService(services.NewRegistrationService(&signupUserRepo{user: user}, wallets, mockDB, nil))
+
+	router := gin.New()
+	router.Group("/api").POST("v1/users", CreateUser)
+
+	body := `{"username": "newuser", "first_name": "New", "last_name": "User", "email": "new@example.com", "password": "password"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "/api/v1/users/"+user.ID.String(), w.Header().Get("Location"))
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ListWallets godoc
+// @Summary      List a user's wallets
+// @Description  List all wallets of a user, the default wallet first
+// @Tags         wallet
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=[]models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/wallets [get]
+func ListWallets(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_list_wallets")
+
+	log.Info("List wallets request received")
+
+	if !userExists(c, log, userID) {
+		return
+	}
+
+	wallets, err := services.ListWallets(c.Request.Context(), userID)
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to list wallets")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to list wallets"})
+		return
+	}
+
+	log.WithField("wallet_count", len(wallets)).Info("Wallets listed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallets retrieved successfully",
+		Data:    wallets,
+	})
+}
+
+// CreateWallet godoc
+// @Summary      Open an additional wallet
+// @Description  Open a named wallet for a user alongside their default wallet. Names are unique per user. Without a currency the wallet holds the currency of the default wallet.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        wallet body models.CreateWalletRequest true "Wallet name and currency"
+// @Success      201 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/wallets [post]
+func CreateWallet(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_create_wallet")
+
+	log.Info("Create wallet request received")
+
+	var req models.CreateWalletRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body", Details: validationDetails(err)})
+		return
+	}
+	if !userExists(c, log, userID) {
+		return
+	}
+
+	wallet, err := services.CreateWallet(c.Request.Context(), userID, req.Name, req.Currency)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidWalletName), errors.Is(err, services.ErrUnsupportedCurrency):
+		log.WithField("error", err.Error()).Warn("Wallet creation rejected")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrWalletNameTaken):
+		log.WithField("error", err.Error()).Warn("Wallet creation rejected, name already used")
+		c.JSON(http.StatusConflict, models.ErrorResponse{Code: models.CodeConflict, Error: services.ErrWalletNameTaken.Error()})
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		log.WithField("error", err.Error()).Warn("Wallet creation rejected, user not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "user not found"})
+		return
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+		return
+	default:
+		log.WithField("error", err.Error()).Error("Failed to create wallet")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to create wallet"})
+		return
+	}
+
+	log.WithField("wallet_id", wallet.ID.String()).Info("Wallet created successfully")
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Code:    201,
+		Message: "Wallet created successfully",
+		Data:    wallet,
+	})
+}
+
+// GetUserWallet godoc
+// @Summary      Get one of a user's wallets
+// @Description  Get a wallet of the user, including its balance and available balance
+// @Tags         wallet
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        wallet_id path string true "Wallet ID"
+// @Success      200 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/wallets/{wallet_id} [get]
+func GetUserWallet(c *gin.Context) {
+	userID, walletID := c.Param("id"), c.Param("wallet_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "api_get_user_wallet",
+	})
+
+	log.Info("Wallet request received")
+
+	wallet, ok := ownedWallet(c, log, userID, walletID)
+	if !ok {
+		return
+	}
+
+	log.WithField("balance", wallet.Balance).Info("Wallet retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallet retrieved successfully",
+		Data:    wallet,
+	})
+}
+
+// DepositToWallet godoc
+// @Summary      Deposit to a specific wallet
+// @Description  Deposit money to one of the user's wallets
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        wallet_id path string true "Wallet ID"
+// @Param        amount body models.AmountRequest true "Deposit amount"
+// @Success      200 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/wallets/{wallet_id}/deposit [post]
+func DepositToWallet(c *gin.Context) {
+	userID, walletID := c.Param("id"), c.Param("wallet_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "api_deposit",
+	})
+
+	log.Info("Deposit request received")
+
+	req, ok := bindAmountRequest(c, log)
+	if !ok {
+		return
+	}
+	if _, ok := ownedWallet(c, log, userID, walletID); !ok {
+		return
+	}
+
+	log.WithField("amount", *req.Amount).Debug("Processing deposit request")
+
+	wallet, _, err := services.DepositToWallet(c.Request.Context(), walletID, *req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
+	if err != nil {
+		respondServiceError(c, log, err, "Deposit")
+		return
+	}
+
+	log.WithField("new_balance", wallet.Balance).Info("Deposit completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Deposit successful",
+		Data:    wallet,
+	})
+}
+
+// WithdrawFromWallet godoc
+// @Summary      Withdraw from a specific wallet
+// @Description  Withdraw money from one of the user's wallets
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        wallet_id path string true "Wallet ID"
+// @Param        amount body models.AmountRequest true "Withdrawal amount"
+// @Success      200 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/wallets/{wallet_id}/withdraw [post]
+func WithdrawFromWallet(c *gin.Context) {
+	userID, walletID := c.Param("id"), c.Param("wallet_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "api_withdraw",
+	})
+
+	log.Info("Withdrawal request received")
+
+	req, ok := bindAmountRequest(c, log)
+	if !ok {
+		return
+	}
+	if _, ok := ownedWallet(c, log, userID, walletID); !ok {
+		return
+	}
+
+	log.WithField("amount", *req.Amount).Debug("Processing withdrawal request")
+
+	wallet, _, err := services.WithdrawFromWallet(c.Request.Context(), walletID, *req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
+	if err != nil {
+		respondServiceError(c, log, err, "Withdrawal")
+		return
+	}
+
+	log.WithField("new_balance", wallet.Balance).Info("Withdrawal completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Withdrawal successful",
+		Data:    wallet,
+	})
+}
+
+// MoveRequest moves money between two wallets of the same user
+type MoveRequest struct {
+	FromWalletID   string            `json:"from_wallet_id" binding:"required"`
+	ToWalletID     string            `json:"to_wallet_id" binding:"required"`
+	Amount         money.Amount      `json:"amount" binding:"required" swaggertype:"string" example:"25.00"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty" binding:"max=255" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	Description    string            `json:"description,omitempty" example:"monthly savings"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+}
+
+// MoveBetweenWallets godoc
+// @Summary      Move money between own wallets
+// @Description  Move money between two wallets of the same user. No fee is charged; the move is recorded as a transfer with both legs on the user's wallets.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        move body MoveRequest true "Wallets and amount"
+// @Success      200 {object} models.SuccessResponse{data=models.TransferResult}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/wallets/move [post]
+func MoveBetweenWallets(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_move")
+
+	log.Info("Move request received")
+
+	var req MoveRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error(), Details: validationDetails(err)})
+		return
+	}
+	if _, err := uuid.Parse(req.FromWalletID); err != nil {
+		log.WithField("from_wallet_id", req.FromWalletID).Warn("Invalid from_wallet_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid from_wallet_id format"})
+		return
+	}
+	if _, err := uuid.Parse(req.ToWalletID); err != nil {
+		log.WithField("to_wallet_id", req.ToWalletID).Warn("Invalid to_wallet_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid to_wallet_id format"})
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"from_wallet_id": req.FromWalletID,
+		"to_wallet_id":   req.ToWalletID,
+		"amount":         req.Amount,
+	}).Debug("Processing move request")
+
+	result, err := services.TransferBetweenWallets(c.Request.Context(), userID, req.FromWalletID, req.ToWalletID, req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
+	if err != nil {
+		respondServiceError(c, log, err, "Move")
+		return
+	}
+
+	log.WithField("transfer_id", result.TransferID.String()).Info("Move completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Move successful",
+		Data:    result,
+	})
+}
+
+// ExchangeRequest converts money from one of a user's wallets into another of their
+// wallets holding a different currency
+type ExchangeRequest struct {
+	FromWalletID string       `json:"from_wallet_id" binding:"required"`
+	ToWalletID   string       `json:"to_wallet_id" binding:"required"`
+	Amount       money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"100.00"`
+}
+
+// ExchangeCurrency godoc
+// @Summary      Exchange money between currencies
+// @Description  Convert money from one of a user's wallets into another of their wallets holding a different currency. The amount is debited in the source currency and the target wallet is credited the converted amount, rounded to the target currency's minor units.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        exchange body ExchangeRequest true "Wallets and amount in the source currency"
+// @Success      200 {object} models.SuccessResponse{data=models.ExchangeResult}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/users/{id}/exchange [post]
+func ExchangeCurrency(c *gin.Context) {
+	userID := c.Param("id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_exchange")
+
+	log.Info("Exchange request received")
+
+	var req ExchangeRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: money.ErrTooManyDecimals.Error()})
+		return
+	} else if err != nil {
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error(), Details: validationDetails(err)})
+		return
+	}
+	if _, err := uuid.Parse(req.FromWalletID); err != nil {
+		log.WithField("from_wallet_id", req.FromWalletID).Warn("Invalid from_wallet_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid from_wallet_id format"})
+		return
+	}
+	if _, err := uuid.Parse(req.ToWalletID); err != nil {
+		log.WithField("to_wallet_id", req.ToWalletID).Warn("Invalid to_wallet_id format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid to_wallet_id format"})
+		return
+	}
+
+	result, err := services.Exchange(c.Request.Context(), userID, req.FromWalletID, req.ToWalletID, req.Amount)
+	if err != nil {
+		respondServiceError(c, log, err, "Exchange")
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"exchange_id":      result.ExchangeID.String(),
+		"rate":             result.Rate,
+		"converted_amount": result.ConvertedAmount,
+	}).Info("Exchange completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Exchange successful",
+		Data:    result,
+	})
+}
+
+// bindAmountRequest reads an AmountRequest body, responding 400 with the invalid fields
+// when it is invalid. The amount's sign and limits are left for the service to check.
+func bindAmountRequest(c *gin.Context, log *logrus.Entry) (*models.AmountRequest, bool) {
+	var req models.AmountRequest
+	err := bindJSON(c, &req)
+	switch {
+	case err == nil:
+		return &req, true
+	case errors.Is(err, money.ErrTooManyDecimals):
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   money.ErrTooManyDecimals.Error(),
+			Details: map[string]string{"amount": "must have at most 2 decimal places"},
+		})
+	case errors.Is(err, money.ErrInvalidAmount):
+		// Amounts decode themselves, so the JSON decoder does not say which field failed
+		log.WithField("error", err.Error()).Warn("Amount is not a number")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   "Invalid request body",
+			Details: map[string]string{"amount": "must be a decimal number, e.g. \"10.50\""},
+		})
+	default:
+		log.WithField("error", err.Error()).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:    models.CodeValidation,
+			Error:   "Invalid request body",
+			Details: validationDetails(err),
+		})
+	}
+	return nil, false
+}
+
+// userExists checks that userID is a known user, responding 400 or 404 when it is not
+func userExists(c *gin.Context, log *logrus.Entry, userID string) bool {
+	if _, err := uuid.Parse(userID); err != nil {
+		log.Warn("Invalid user ID format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid user ID format"})
+		return false
+	}
+	_, err := userRepo.GetUserByID(c.Request.Context(), userID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		log.Warn("User not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeUserNotFound, Error: "user not found"})
+		return false
+	}
+	if errors.Is(err, repositories.ErrQueryTimeout) {
+		respondQueryTimeout(c, log, err)
+		return false
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to look up user")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to look up user"})
+		return false
+	}
+	return true
+}
+
+// ownedWallet looks up a wallet of userID. A wallet of another user is reported as not
+// found, so wallet IDs of other users cannot be probed.
+func ownedWallet(c *gin.Context, log *logrus.Entry, userID, walletID string) (*models.Wallet, bool) {
+	wallet, err := walletOf(c.Request.Context(), userID, walletID)
+	switch {
+	case err == nil:
+		return wallet, true
+	case errors.Is(err, errInvalidWalletID):
+		log.Warn("Invalid wallet ID format")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: err.Error()})
+	case errors.Is(err, services.ErrWalletNotFound):
+		log.Warn("Wallet not found")
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Code: models.CodeWalletNotFound, Error: "wallet not found"})
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+	default:
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "Failed to get wallet"})
+	}
+	return nil, false
+}
+
+// errInvalidWalletID is returned by walletOf for a wallet ID that is not a UUID
+var errInvalidWalletID = errors.New("invalid wallet ID format")
+
+// walletOf returns the wallet walletID of userID, or services.ErrWalletNotFound when
+// it belongs to another user
+func walletOf(ctx context.Context, userID, walletID string) (*models.Wallet, error) {
+	if _, err := uuid.Parse(walletID); err != nil {
+		return nil, errInvalidWalletID
+	}
+	wallet, err := services.GetWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.UserID.String() != userID {
+		return nil, services.ErrWalletNotFound
+	}
+	return wallet, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Name fields in validation errors as clients send them, by their JSON name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// validationDetails explains, by field name, why the fields of a request body that
+// failed to bind are invalid. It returns nil when err is not about particular fields,
+// such as malformed JSON.
+func validationDetails(err error) map[string]string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return map[string]string{typeErr.Field: "must be a " + typeErr.Type.String()}
+	}
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		return map[string]string{"body": fmt.Sprintf("must be at most %d bytes", sizeErr.Limit)}
+	}
+	if errors.Is(err, errTrailingData) {
+		return map[string]string{"body": "must hold a single JSON value"}
+	}
+	// The decoder reports unknown fields only in its message
+	if quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if field, err := strconv.Unquote(quoted); err == nil {
+			return map[string]string{field: "is not a known field"}
+		}
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil
+	}
+	details := make(map[string]string, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		// The namespace starts with the request type, e.g. BatchTransferRequest.items[0].amount
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		details[field] = validationMessage(fe)
+	}
+	return details
+}
+
+// validationMessage explains the rule a field broke
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "max":
+		bound := "at least "
+		if fe.Tag() == "max" {
+			bound = "at most "
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be " + bound + fe.Param() + " characters"
+		case reflect.Slice, reflect.Map:
+			return "must have " + bound + fe.Param() + " items"
+		}
+		return "must be " + bound + fe.Param()
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
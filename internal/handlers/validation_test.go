@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationDetails(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{
+			name: "missing fields",
+			body: `{"amount": "10.00"}`,
+			want: map[string]string{"from_wallet_id": "is required", "to_wallet_id": "is required"},
+		},
+		{
+			name: "too long",
+			body: `{"from_wallet_id": "a", "to_wallet_id": "b", "amount": "10.00", "idempotency_key": "` + strings.Repeat("k", 256) + `"}`,
+			want: map[string]string{"idempotency_key": "must be at most 255 characters"},
+		},
+		{
+			name: "wrong type",
+			body: `{"from_wallet_id": 7, "to_wallet_id": "b", "amount": "10.00"}`,
+			want: map[string]string{"from_wallet_id": "must be a string"},
+		},
+		{
+			name: "malformed",
+			body: `{"from_wallet_id": `,
+			want: nil,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			var req MoveRequest
+			err := bindJSON(c, &req)
+
+			assert.Error(t, err)
+			assert.Equal(t, tt.want, validationDetails(err))
+		})
+	}
+}
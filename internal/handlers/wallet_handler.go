@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"walletapp/internal/logger"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
 	"walletapp/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // Deposit godoc
@@ -17,39 +24,101 @@ import (
 // @Produce      json
 // @Param        user_id path string true "User ID"
 // @Param        amount body models.AmountRequest true "Deposit amount"
-// @Success      200 {object} models.SuccessResponse
+// @Success      200 {object} models.SuccessResponse{data=models.BalanceChangeResponse}
 // @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
 // @Router       /v1/wallets/{user_id}/deposit [post]
 func Deposit(c *gin.Context) {
 	userID := c.Param("user_id")
-	log := logger.WithUser(userID).WithField("operation", "api_deposit")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_deposit")
 
 	log.Info("Deposit request received")
 
-	var req models.AmountRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	req, ok := bindAmountRequest(c, log)
+	if !ok {
+		return
+	}
+
+	log.WithField("amount", *req.Amount).Debug("Processing deposit request")
+
+	wallet, recorded, err := services.Deposit(c.Request.Context(), userID, *req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
+	if err != nil {
+		respondServiceError(c, log, err, "Deposit")
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"new_balance":    wallet.Balance,
+		"transaction_id": recorded.ID.String(),
+	}).Info("Deposit completed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Deposit successful",
+		Data:    balanceChangeResponse(userID, wallet, recorded),
+	})
+}
+
+// DepositExternal godoc
+// @Summary      Record an external deposit
+// @Description  Credit a deposit confirmed by a payment provider. Repeated notifications for the same reference return the original transaction without crediting the wallet again.
+// @Tags         wallet
+// @Accept       json
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        deposit body models.ExternalDepositRequest true "Deposit amount and provider reference"
+// @Success      200 {object} models.SuccessResponse{data=models.Transaction}
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/deposits/external [post]
+func DepositExternal(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_external_deposit")
+
+	log.Info("External deposit request received")
+
+	var req models.ExternalDepositRequest
+	if err := bindJSON(c, &req); errors.Is(err, money.ErrTooManyDecimals) {
+		log.WithField("error", err.Error()).Warn("Amount has more than 2 decimal places")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Code:  models.CodeValidation,
+			Error: money.ErrTooManyDecimals.Error(),
+		})
+		return
+	} else if err != nil {
 		log.WithField("error", err.Error()).Warn("Invalid request body")
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: "Invalid request body",
+			Code:    models.CodeValidation,
+			Error:   "Invalid request body",
+			Details: validationDetails(err),
 		})
 		return
 	}
 
-	log.WithField("amount", req.Amount).Debug("Processing deposit request")
+	log = log.WithField("external_reference", req.Reference)
+	log.WithField("amount", req.Amount).Debug("Processing external deposit request")
 
-	wallet, err := services.Deposit(c.Request.Context(), userID, req.Amount)
+	transaction, err := services.DepositExternal(c.Request.Context(), userID, req.Amount, req.Reference)
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Deposit operation failed")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: err.Error(),
-		})
+		respondServiceError(c, log, err, "Deposit")
 		return
 	}
 
-	log.WithField("new_balance", wallet.Balance).Info("Deposit completed successfully")
+	log.WithField("transaction_id", transaction.ID.String()).Info("External deposit processed successfully")
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Code:    200,
 		Message: "Deposit successful",
+		Data:    transaction,
 	})
 }
 
@@ -61,39 +130,44 @@ func Deposit(c *gin.Context) {
 // @Produce      json
 // @Param        user_id path string true "User ID"
 // @Param        amount body models.AmountRequest true "Withdrawal amount"
-// @Success      200 {object} models.SuccessResponse
+// @Success      200 {object} models.SuccessResponse{data=models.BalanceChangeResponse}
 // @Failure      400 {object} models.ErrorResponse
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      422 {object} models.ErrorResponse
+// @Failure      429 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
 // @Router       /v1/wallets/{user_id}/withdraw [post]
 func Withdraw(c *gin.Context) {
 	userID := c.Param("user_id")
-	log := logger.WithUser(userID).WithField("operation", "api_withdraw")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_withdraw")
 
 	log.Info("Withdrawal request received")
 
-	var req models.AmountRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.WithField("error", err.Error()).Warn("Invalid request body")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: "Invalid request body",
-		})
+	req, ok := bindAmountRequest(c, log)
+	if !ok {
 		return
 	}
 
-	log.WithField("amount", req.Amount).Debug("Processing withdrawal request")
+	log.WithField("amount", *req.Amount).Debug("Processing withdrawal request")
 
-	wallet, err := services.Withdraw(c.Request.Context(), userID, req.Amount)
+	wallet, recorded, err := services.Withdraw(c.Request.Context(), userID, *req.Amount, req.IdempotencyKey, req.Description, req.Metadata)
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Withdrawal operation failed")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: err.Error(),
-		})
+		respondServiceError(c, log, err, "Withdrawal")
 		return
 	}
 
-	log.WithField("new_balance", wallet.Balance).Info("Withdrawal completed successfully")
+	log.WithFields(logrus.Fields{
+		"new_balance":    wallet.Balance,
+		"transaction_id": recorded.ID.String(),
+	}).Info("Withdrawal completed successfully")
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Code:    200,
 		Message: "Withdrawal successful",
+		Data:    balanceChangeResponse(userID, wallet, recorded),
 	})
 }
 
@@ -105,19 +179,18 @@ func Withdraw(c *gin.Context) {
 // @Param        user_id path string true "User ID"
 // @Success      200 {object} models.SuccessResponse{data=models.BalanceResponse}
 // @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
 // @Router       /v1/wallets/{user_id}/balance [get]
 func GetBalance(c *gin.Context) {
 	userID := c.Param("user_id")
-	log := logger.WithUser(userID).WithField("operation", "api_get_balance")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_balance")
 
 	log.Info("Balance inquiry request received")
 
 	wallet, err := services.GetWallet(c.Request.Context(), userID)
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to get wallet balance")
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error: "Wallet not found",
-		})
+		respondServiceError(c, log, err, "Balance inquiry")
 		return
 	}
 
@@ -125,9 +198,215 @@ func GetBalance(c *gin.Context) {
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Code:    200,
 		Message: "Balance retrieved successfully",
-		Data: models.BalanceResponse{
-			UserID:  userID,
-			Balance: wallet.Balance,
-		},
+		Data:    balanceResponse(userID, wallet),
+	})
+}
+
+// GetWalletStats godoc
+// @Summary      Get wallet lifetime totals
+// @Description  Totals the wallet deposited, withdrew, transferred and was charged over its whole life, from its completed transactions. Reversed transfers are netted out of the totals.
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=models.WalletTotals}
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id}/stats [get]
+func GetWalletStats(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_get_wallet_stats")
+
+	log.Info("Wallet stats request received")
+
+	ctx := c.Request.Context()
+	wallet, err := services.GetWallet(ctx, userID)
+	if err != nil {
+		respondServiceError(c, log, err, "Wallet stats")
+		return
+	}
+	totals, err := services.GetWalletTotals(ctx, wallet.ID.String())
+	if err != nil {
+		respondServiceError(c, log, err, "Wallet stats")
+		return
+	}
+
+	log.WithField("transaction_count", totals.TransactionCount).Info("Wallet stats retrieved successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallet stats retrieved successfully",
+		Data:    totals,
+	})
+}
+
+// balanceResponse reports the balance of a user's default wallet in its currency
+func balanceResponse(userID string, wallet *models.Wallet) models.BalanceResponse {
+	return models.BalanceResponse{
+		UserID:           userID,
+		Currency:         wallet.Currency,
+		Balance:          wallet.Balance,
+		AvailableBalance: wallet.Balance - wallet.HeldAmount,
+	}
+}
+
+// balanceChangeResponse describes a deposit or withdrawal recorded as recorded, leaving
+// the user's wallet as wallet
+func balanceChangeResponse(userID string, wallet *models.Wallet, recorded *models.Transaction) models.BalanceChangeResponse {
+	return models.BalanceChangeResponse{
+		WalletID:         wallet.ID.String(),
+		UserID:           userID,
+		TransactionID:    recorded.ID.String(),
+		Currency:         wallet.Currency,
+		Amount:           recorded.Amount,
+		Balance:          wallet.Balance,
+		AvailableBalance: wallet.Balance - wallet.HeldAmount,
+	}
+}
+
+// CloseWallet godoc
+// @Summary      Close wallet
+// @Description  Permanently close a user's wallet. Only an empty wallet with nothing held can be closed; it stays readable along with its transaction history, but deposits, withdrawals, transfers and holds on it fail with 410.
+// @Tags         wallet
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Success      200 {object} models.SuccessResponse{data=models.Wallet}
+// @Failure      403 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      410 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Failure      504 {object} models.ErrorResponse
+// @Router       /v1/wallets/{user_id} [delete]
+func CloseWallet(c *gin.Context) {
+	userID := c.Param("user_id")
+	log := logger.WithUser(userID).WithContext(c.Request.Context()).WithField("operation", "api_close_wallet")
+
+	log.Info("Close wallet request received")
+
+	wallet, err := services.CloseWallet(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, log, err, "Close")
+		return
+	}
+
+	log.Info("Wallet closed successfully")
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Code:    200,
+		Message: "Wallet closed successfully",
+		Data:    wallet,
+	})
+}
+
+// serviceErrors are the statuses and codes the errors of the wallet operations are
+// answered with, checked in order so a more specific error wins
+var serviceErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{services.ErrWalletNotFound, http.StatusNotFound, models.CodeWalletNotFound},
+	{repositories.ErrUserNotFound, http.StatusNotFound, models.CodeUserNotFound},
+	{services.ErrHoldNotFound, http.StatusNotFound, models.CodeNotFound},
+	{services.ErrTooManyOperations, http.StatusTooManyRequests, models.CodeTooManyOperations},
+	{services.ErrWalletFrozen, http.StatusForbidden, models.CodeWalletFrozen},
+	{services.ErrWalletClosed, http.StatusGone, models.CodeWalletClosed},
+	{services.ErrEmailNotVerified, http.StatusForbidden, models.CodeEmailNotVerified},
+	{services.ErrInsufficientBalance, http.StatusUnprocessableEntity, models.CodeInsufficientBalance},
+	{services.ErrDailyLimitExceeded, http.StatusUnprocessableEntity, models.CodeLimitExceeded},
+	{services.ErrMonthlyLimitExceeded, http.StatusUnprocessableEntity, models.CodeLimitExceeded},
+	{services.ErrKycLimitExceeded, http.StatusUnprocessableEntity, models.CodeLimitExceeded},
+	{services.ErrRateUnavailable, http.StatusUnprocessableEntity, models.CodeBusinessRule},
+	{services.ErrSelfTransfer, http.StatusConflict, models.CodeSelfTransfer},
+	{services.ErrSameWallet, http.StatusConflict, models.CodeSelfTransfer},
+	{services.ErrWalletNotEmpty, http.StatusConflict, models.CodeConflict},
+	{services.ErrHoldNotActive, http.StatusConflict, models.CodeConflict},
+	{services.ErrIdempotencyKeyConflict, http.StatusConflict, models.CodeIdempotencyKey},
+	{services.ErrExternalReferenceConflict, http.StatusConflict, models.CodeConflict},
+	{services.ErrTxConflict, http.StatusConflict, models.CodeConcurrentUpdate},
+	{services.ErrCurrencyMismatch, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrSameCurrency, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrInvalidAmount, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrInvalidReference, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrInvalidDescription, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrInvalidMetadata, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrInvalidBatch, http.StatusBadRequest, models.CodeValidation},
+	{services.ErrDuplicateRecipient, http.StatusBadRequest, models.CodeValidation},
+	{repositories.ErrQueryTimeout, http.StatusGatewayTimeout, models.CodeTimeout},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, models.CodeTimeout},
+}
+
+// serviceError returns the status and code a wallet operation that failed with err is
+// answered with. Errors it does not know are unexpected and answered with 500.
+func serviceError(err error) (int, string) {
+	for _, e := range serviceErrors {
+		if errors.Is(err, e.err) {
+			return e.status, e.code
+		}
+	}
+	return http.StatusInternalServerError, models.CodeInternal
+}
+
+// respondServiceError responds to a wallet operation, named by operation as in
+// "Deposit", that failed with err. The errors of the client's request are explained
+// to it; unexpected errors are only logged, and the client is told to try again.
+func respondServiceError(c *gin.Context, log *logrus.Entry, err error, operation string) {
+	status, code := serviceError(err)
+	switch {
+	case status == http.StatusTooManyRequests:
+		log.WithField("error", err.Error()).Warn(operation + " rejected, too many operations")
+		rejectTooManyOperations(c, err)
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		respondQueryTimeout(c, log, err)
+	case status == http.StatusGatewayTimeout:
+		log.WithField("error", err.Error()).Error(operation + " ran past the request timeout")
+		c.JSON(status, models.ErrorResponse{Code: code, Error: "the request took too long, please try again"})
+	case errors.Is(err, services.ErrTxConflict):
+		log.WithField("error", err.Error()).Warn(operation + " aborted due to concurrent updates")
+		c.JSON(status, models.ErrorResponse{Code: code, Error: operation + " conflicted with concurrent updates, please try again"})
+	case errors.Is(err, services.ErrCommitFailed):
+		log.WithField("error", err.Error()).Error(operation + " could not be committed")
+		c.JSON(status, models.ErrorResponse{Code: code, Error: operation + " could not be completed, please try again"})
+	case status == http.StatusInternalServerError:
+		log.WithField("error", err.Error()).Error(operation + " operation failed")
+		c.JSON(status, models.ErrorResponse{Code: code, Error: operation + " failed, please try again"})
+	default:
+		log.WithField("error", err.Error()).Warn(operation + " rejected")
+		c.JSON(status, models.ErrorResponse{Code: code, Error: serviceErrorMessage(err)})
+	}
+}
+
+// serviceErrorMessage explains err to the client. Errors about a wallet are reported
+// without the wallets, users and keys they name, except for the item of a batch
+// transfer, which the client needs to know.
+func serviceErrorMessage(err error) string {
+	var itemErr *services.BatchItemError
+	if errors.As(err, &itemErr) {
+		return err.Error()
+	}
+	for _, sentinel := range []error{services.ErrWalletNotFound, services.ErrWalletFrozen, services.ErrWalletClosed,
+		services.ErrInsufficientBalance, services.ErrIdempotencyKeyConflict} {
+		if errors.Is(err, sentinel) {
+			return sentinel.Error()
+		}
+	}
+	return err.Error()
+}
+
+// respondQueryTimeout responds 504 to a request that failed with
+// repositories.ErrQueryTimeout, a database query that ran past the query timeout
+func respondQueryTimeout(c *gin.Context, log *logrus.Entry, err error) {
+	log.WithField("error", err.Error()).Error("Database query timed out")
+	c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{Code: models.CodeTimeout, Error: "the database took too long to respond, please try again"})
+}
+
+// rejectTooManyOperations responds 429 to an operation that failed with
+// services.ErrTooManyOperations, telling the client when to retry
+func rejectTooManyOperations(c *gin.Context, err error) {
+	if after, ok := services.RetryAfter(err); ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(after.Seconds()))))
+	}
+	c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+		Code:  models.CodeTooManyOperations,
+		Error: err.Error(),
 	})
 }
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+	"walletapp/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondServiceError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "wallet not found",
+			err:         fmt.Errorf("%w: wallet 42 does not belong to user 7", services.ErrWalletNotFound),
+			wantStatus:  http.StatusNotFound,
+			wantCode:    models.CodeWalletNotFound,
+			wantMessage: "wallet not found",
+		},
+		{
+			name:        "insufficient balance",
+			err:         fmt.Errorf("%w: user 7 cannot cover 50.00", services.ErrInsufficientBalance),
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    models.CodeInsufficientBalance,
+			wantMessage: "insufficient balance",
+		},
+		{
+			name:        "monthly limit",
+			err:         fmt.Errorf("%w: 10.00 of the 1000.00 monthly limit remains", services.ErrMonthlyLimitExceeded),
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    models.CodeLimitExceeded,
+			wantMessage: "monthly transfer limit exceeded: 10.00 of the 1000.00 monthly limit remains",
+		},
+		{
+			name:        "self transfer",
+			err:         services.ErrSelfTransfer,
+			wantStatus:  http.StatusConflict,
+			wantCode:    models.CodeSelfTransfer,
+			wantMessage: "cannot self transfer",
+		},
+		{
+			name:        "idempotency key reused",
+			err:         fmt.Errorf("%w: key %q was recorded for a DEPOSIT of 5.00", services.ErrIdempotencyKeyConflict, "k1"),
+			wantStatus:  http.StatusConflict,
+			wantCode:    models.CodeIdempotencyKey,
+			wantMessage: services.ErrIdempotencyKeyConflict.Error(),
+		},
+		{
+			name:        "concurrent updates",
+			err:         services.ErrTxConflict,
+			wantStatus:  http.StatusConflict,
+			wantCode:    models.CodeConcurrentUpdate,
+			wantMessage: "Transfer conflicted with concurrent updates, please try again",
+		},
+		{
+			name:        "too many operations",
+			err:         services.ErrTooManyOperations,
+			wantStatus:  http.StatusTooManyRequests,
+			wantCode:    models.CodeTooManyOperations,
+			wantMessage: "too many operations",
+		},
+		{
+			name:        "frozen",
+			err:         fmt.Errorf("%w: user 7", services.ErrWalletFrozen),
+			wantStatus:  http.StatusForbidden,
+			wantCode:    models.CodeWalletFrozen,
+			wantMessage: "wallet is frozen",
+		},
+		{
+			name:        "invalid amount",
+			err:         services.ErrInvalidAmount,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    models.CodeValidation,
+			wantMessage: "invalid amount",
+		},
+		{
+			name:        "batch item",
+			err:         &services.BatchItemError{Index: 2, ToUserID: "u3", Err: services.ErrWalletNotFound},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    models.CodeWalletNotFound,
+			wantMessage: "item 2 (user u3): wallet not found",
+		},
+		{
+			name:        "query timeout",
+			err:         fmt.Errorf("%w: %w", repositories.ErrQueryTimeout, context.DeadlineExceeded),
+			wantStatus:  http.StatusGatewayTimeout,
+			wantCode:    models.CodeTimeout,
+			wantMessage: "the database took too long to respond, please try again",
+		},
+		{
+			name:        "request deadline",
+			err:         fmt.Errorf("get wallet: %w", context.DeadlineExceeded),
+			wantStatus:  http.StatusGatewayTimeout,
+			wantCode:    models.CodeTimeout,
+			wantMessage: "the request took too long, please try again",
+		},
+		{
+			name:        "commit failed",
+			err:         fmt.Errorf("%w: connection reset", services.ErrCommitFailed),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    models.CodeInternal,
+			wantMessage: "Transfer could not be completed, please try again",
+		},
+		{
+			name:        "database down",
+			err:         errors.New("dial tcp 10.0.0.5:5432: connect: connection refused"),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    models.CodeInternal,
+			wantMessage: "Transfer failed, please try again",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := test.NewNullLogger()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			respondServiceError(c, logrus.NewEntry(log), tt.err, "Transfer")
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var resp models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantCode, resp.Code)
+			assert.Equal(t, tt.wantMessage, resp.Error)
+			if assert.NotNil(t, hook.LastEntry()) {
+				assert.Equal(t, tt.err.Error(), hook.LastEntry().Data["error"])
+			}
+		})
+	}
+}
+
+func TestDeposit_AmountValidation(t *testing.T) {
+	// Every case is rejected before the database is queried
+	services.SetDefaultService(services.NewWalletService(newWalletRepoImpl(), newTransactionRepoImpl(), services.NewDBImpl(), services.WalletServiceConfig{}))
+
+	tests := []struct {
+		name        string
+		body        string
+		wantMessage string
+		wantDetails map[string]string
+	}{
+		{
+			name:        "missing amount",
+			body:        `{"description": "salary"}`,
+			wantMessage: "Invalid request body",
+			wantDetails: map[string]string{"amount": "is required"},
+		},
+		{
+			name:        "misspelt amount",
+			body:        `{"ammount": "10.00"}`,
+			wantMessage: "Invalid request body",
+			wantDetails: map[string]string{"ammount": "is not a known field"},
+		},
+		{
+			name:        "zero",
+			body:        `{"amount": 0}`,
+			wantMessage: "invalid amount: amount must be positive",
+		},
+		{
+			name:        "negative",
+			body:        `{"amount": "-5.00"}`,
+			wantMessage: "invalid amount: amount must be positive",
+		},
+		{
+			name:        "not a number",
+			body:        `{"amount": "ten"}`,
+			wantMessage: "Invalid request body",
+			wantDetails: map[string]string{"amount": `must be a decimal number, e.g. "10.50"`},
+		},
+		{
+			name:        "not a number or string",
+			body:        `{"amount": true}`,
+			wantMessage: "Invalid request body",
+			wantDetails: map[string]string{"amount": `must be a decimal number, e.g. "10.50"`},
+		},
+		{
+			name:        "too many decimals",
+			body:        `{"amount": "10.001"}`,
+			wantMessage: "amount cannot have more than 2 decimal places",
+			wantDetails: map[string]string{"amount": "must have at most 2 decimal places"},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/wallets/:user_id/deposit", Deposit)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/wallets/7f1c1a52-5d0a-4b43-9f6e-2d7c1c1e8a01/deposit", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var resp models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, models.CodeValidation, resp.Code)
+			assert.Equal(t, tt.wantMessage, resp.Error)
+			assert.Equal(t, tt.wantDetails, resp.Details)
+		})
+	}
+}
+
+// cachedWallets is a services.WalletCache that serves fixed wallets and stores nothing
+type cachedWallets map[string]*models.Wallet
+
+func (c cachedWallets) Get(_ context.Context, userID string) (*models.Wallet, error) {
+	return c[userID], nil
+}
+
+func (cachedWallets) Set(context.Context, string, *models.Wallet, time.Duration) error { return nil }
+
+func (cachedWallets) Invalidate(context.Context, string) error { return nil }
+
+func TestGetBalance_ServedFromCache(t *testing.T) {
+	// Without repositories or a database, the balance can only come from the cache
+	userID := "7f1c1a52-5d0a-4b43-9f6e-2d7c1c1e8a01"
+	cache := cachedWallets{userID: {Currency: "USD", Balance: money.MustParse("100.00"), HeldAmount: money.MustParse("20.00")}}
+	services.SetDefaultService(services.NewWalletService(nil, nil, nil, services.WalletServiceConfig{Cache: cache}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/v1/wallets/:user_id/balance", GetBalance)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/wallets/"+userID+"/balance", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data models.BalanceResponse `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustParse("100.00"), resp.Data.Balance)
+	assert.Equal(t, money.MustParse("80.00"), resp.Data.AvailableBalance)
+}
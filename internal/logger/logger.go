@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"context"
 	"os"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -36,6 +38,47 @@ func Init() {
 			logrus.FieldKeyMsg:   "message",
 		},
 	})
+
+	log.AddHook(redactHook{})
+	log.AddHook(requestIDHook{})
+}
+
+// redacted replaces the values redactHook keeps out of the logs
+const redacted = "[REDACTED]"
+
+// redactHook replaces the value of every field whose name mentions a password, in any
+// case, so a password or its hash logged by mistake never reaches the logs
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	for key := range entry.Data {
+		if strings.Contains(strings.ToLower(key), "password") {
+			entry.Data[key] = redacted
+		}
+	}
+	return nil
+}
+
+// requestIDHook adds the ID of the request an entry was logged for, when the entry
+// carries the request's context, so the lines of one request can be found together
+type requestIDHook struct{}
+
+func (requestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (requestIDHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := RequestID(entry.Context); id != "" {
+		entry.Data["request_id"] = id
+	}
+	return nil
 }
 
 // Get returns the configured logger instance
@@ -103,3 +146,39 @@ func WithTransaction(txID string) *logrus.Entry {
 func WithOperation(operation string) *logrus.Entry {
 	return Get().WithField("operation", operation)
 }
+
+// contextKey is the context key NewContext stores a logger under
+type contextKey struct{}
+
+// requestIDKey is the context key WithRequestID stores a request ID under
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request it serves. Entries
+// logged with the context, see logrus.Entry.WithContext and FromContext, include it as
+// request_id, so helpers such as WithUser compose with it:
+//
+//	logger.WithUser(userID).WithContext(ctx).Info("Deposit completed")
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request ctx serves, or "" outside of a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewContext returns a copy of ctx carrying log, so code further down the call can log
+// with the same fields
+func NewContext(ctx context.Context, log *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// FromContext returns the logger carried by ctx, or one without fields when ctx carries
+// none. Either way it logs with ctx, so its entries include the request ID.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if log, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return log.WithContext(ctx)
+	}
+	return logrus.NewEntry(Get()).WithContext(ctx)
+}
@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit_RedactsPasswordFields(t *testing.T) {
+	Init()
+	var buf bytes.Buffer
+	Get().SetOutput(&buf)
+	t.Cleanup(Init)
+
+	WithFields(logrus.Fields{
+		"username":     "jane",
+		"password":     "hunter2",
+		"New_Password": "hunter3",
+	}).Info("Updating user")
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "jane", line["username"])
+	assert.Equal(t, redacted, line["password"])
+	assert.Equal(t, redacted, line["New_Password"])
+	assert.NotContains(t, buf.String(), "hunter")
+}
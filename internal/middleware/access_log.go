@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"walletapp/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSlowRequestThreshold is how long a request may take before it is logged at
+// warn level when SLOW_REQUEST_THRESHOLD is not set
+const DefaultSlowRequestThreshold = time.Second
+
+// quietPaths are polled by load balancers and monitoring, so they are left out of the
+// access log
+var quietPaths = map[string]bool{
+	"/health": true,
+}
+
+// SlowRequestThresholdFromEnv reads from SLOW_REQUEST_THRESHOLD how long a request may
+// take before the access log warns about it, a duration such as "500ms", where "0"
+// never warns. Unset, it is DefaultSlowRequestThreshold.
+func SlowRequestThresholdFromEnv() (time.Duration, error) {
+	raw := os.Getenv("SLOW_REQUEST_THRESHOLD")
+	if raw == "" {
+		return DefaultSlowRequestThreshold, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid SLOW_REQUEST_THRESHOLD %q: must be a duration of zero or more", raw)
+	}
+	return d, nil
+}
+
+// AccessLog logs one line for every request once it has been served, with its status,
+// latency, response size and client IP, at warn level when it took slow or longer. It
+// logs through the request's logger, so it must come after RequestID and RequestLogger
+// to carry their fields.
+func AccessLog(slow time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if quietPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		fields := logrus.Fields{
+			"method":        c.Request.Method,
+			"path":          routePath(c),
+			"status":        c.Writer.Status(),
+			"latency_ms":    latency.Milliseconds(),
+			"response_size": max(c.Writer.Size(), 0),
+			"client_ip":     c.ClientIP(),
+		}
+		if userID := requestUserID(c); userID != "" {
+			fields["user_id"] = userID
+		}
+		log := logger.FromContext(c.Request.Context()).WithFields(fields)
+		if slow > 0 && latency >= slow {
+			log.Warn("Slow request")
+			return
+		}
+		log.Info("Request completed")
+	}
+}
+
+// routePath is the route a request matched, such as /api/v1/wallets/:user_id/deposit,
+// or its path when it matched none
+func routePath(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return c.Request.URL.Path
+}
+
+// requestUserID is the user a request is about, when its route names one
+func requestUserID(c *gin.Context) string {
+	if userID := c.Param("user_id"); userID != "" {
+		return userID
+	}
+	if strings.Contains(c.FullPath(), "/users/:id") {
+		return c.Param("id")
+	}
+	return ""
+}
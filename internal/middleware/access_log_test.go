@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"walletapp/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		slow      time.Duration
+		target    string
+		wantLevel logrus.Level
+		wantMsg   string
+		wantPath  string
+		wantUser  any
+	}{
+		{
+			name:      "wallet request",
+			slow:      time.Minute,
+			target:    "/api/v1/wallets/42/balance",
+			wantLevel: logrus.InfoLevel,
+			wantMsg:   "Request completed",
+			wantPath:  "/api/v1/wallets/:user_id/balance",
+			wantUser:  "42",
+		},
+		{
+			name:      "user request",
+			slow:      time.Minute,
+			target:    "/api/v1/users/42",
+			wantLevel: logrus.InfoLevel,
+			wantMsg:   "Request completed",
+			wantPath:  "/api/v1/users/:id",
+			wantUser:  "42",
+		},
+		{
+			name:      "no route",
+			slow:      time.Minute,
+			target:    "/api/v1/nowhere",
+			wantLevel: logrus.InfoLevel,
+			wantMsg:   "Request completed",
+			wantPath:  "/api/v1/nowhere",
+		},
+		{
+			name:      "slow request",
+			slow:      time.Nanosecond,
+			target:    "/api/v1/wallets/42/balance",
+			wantLevel: logrus.WarnLevel,
+			wantMsg:   "Slow request",
+			wantPath:  "/api/v1/wallets/:user_id/balance",
+			wantUser:  "42",
+		},
+		{
+			name:      "no threshold",
+			slow:      0,
+			target:    "/api/v1/wallets/42/balance",
+			wantLevel: logrus.InfoLevel,
+			wantMsg:   "Request completed",
+			wantPath:  "/api/v1/wallets/:user_id/balance",
+			wantUser:  "42",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := test.NewLocal(logger.Get())
+			router := gin.New()
+			router.Use(RequestID(), RequestLogger(), AccessLog(tt.slow))
+			router.GET("/api/v1/wallets/:user_id/balance", func(c *gin.Context) {
+				c.String(http.StatusOK, "10.00")
+			})
+			router.GET("/api/v1/users/:id", func(c *gin.Context) {
+				c.String(http.StatusOK, "10.00")
+			})
+			router.NoRoute(func(c *gin.Context) {
+				c.String(http.StatusNotFound, "not found")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = "10.0.0.7:51234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if assert.Len(t, hook.AllEntries(), 1) {
+				entry := hook.LastEntry()
+				assert.Equal(t, tt.wantLevel, entry.Level)
+				assert.Equal(t, tt.wantMsg, entry.Message)
+				assert.Equal(t, http.MethodGet, entry.Data["method"])
+				assert.Equal(t, tt.wantPath, entry.Data["path"])
+				assert.Equal(t, w.Code, entry.Data["status"])
+				assert.Equal(t, w.Body.Len(), entry.Data["response_size"])
+				assert.Equal(t, "10.0.0.7", entry.Data["client_ip"])
+				assert.Contains(t, entry.Data, "latency_ms")
+				assert.Equal(t, w.Header().Get(RequestIDHeader), entry.Data["request_id"])
+				assert.Equal(t, tt.wantUser, entry.Data["user_id"])
+			}
+		})
+	}
+}
+
+func TestAccessLog_SkipsHealthChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hook := test.NewLocal(logger.Get())
+	router := gin.New()
+	router.Use(AccessLog(time.Nanosecond))
+	router.GET("/health", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestSlowRequestThresholdFromEnv(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{raw: "", want: DefaultSlowRequestThreshold},
+		{raw: "500ms", want: 500 * time.Millisecond},
+		{raw: "0", want: 0},
+		{raw: "-1s", wantErr: true},
+		{raw: "slow", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			t.Setenv("SLOW_REQUEST_THRESHOLD", tt.raw)
+
+			got, err := SlowRequestThresholdFromEnv()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		configured   string
+		provided     string
+		expectedCode int
+	}{
+		{"matching token", "s3cret", "s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "guess", http.StatusUnauthorized},
+		{"missing token", "s3cret", "", http.StatusUnauthorized},
+		{"admin endpoints disabled", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_TOKEN", tt.configured)
+
+			router := gin.New()
+			router.GET("/admin", AdminAuth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.provided != "" {
+				req.Header.Set(AdminTokenHeader, tt.provided)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
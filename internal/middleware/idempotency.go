@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader carries the key a client retries a request under
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from an earlier request
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// idempotencyTTL is how long a response is replayed to retries of its request
+const idempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength matches the idempotency_key column
+const maxIdempotencyKeyLength = 255
+
+// idempotencyPurgeInterval is how often RunIdempotencyPurge deletes expired records
+const idempotencyPurgeInterval = time.Hour
+
+// idempotencyLease is how long a request in flight keeps retries of it out. It outlasts
+// requests under DefaultRequestTimeout, and once it runs out a retry takes the key over,
+// so a request lost to a crash does not hold its key for the whole idempotencyTTL.
+const idempotencyLease = time.Minute
+
+// IdempotencyStore keeps the responses Idempotency replays
+type IdempotencyStore interface {
+	// Claim records that the request of record is being handled for the next lease,
+	// unless a request after expiredBefore claimed it first and completed it or still
+	// holds its lease, in which case it returns that one's record
+	Claim(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time, lease time.Duration) (bool, *models.IdempotencyRecord, error)
+	// Complete saves the response to a claimed request
+	Complete(ctx context.Context, record *models.IdempotencyRecord) error
+	// Release gives up the claim on a request, so it can be retried
+	Release(ctx context.Context, record *models.IdempotencyRecord) error
+	// DeleteExpired deletes the records claimed before expiredBefore
+	DeleteExpired(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+// idempotencyRepo is the IdempotencyStore on the idempotency_records table
+type idempotencyRepo struct {
+	records repositories.IdempotencyRepository
+}
+
+func (r idempotencyRepo) Claim(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time, lease time.Duration) (bool, *models.IdempotencyRecord, error) {
+	return r.records.ClaimIdempotencyRecord(ctx, record, expiredBefore, lease)
+}
+
+func (r idempotencyRepo) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.records.CompleteIdempotencyRecord(ctx, record)
+}
+
+func (r idempotencyRepo) Release(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.records.DeleteIdempotencyRecord(ctx, record)
+}
+
+func (r idempotencyRepo) DeleteExpired(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	return r.records.DeleteExpiredIdempotencyRecords(ctx, expiredBefore)
+}
+
+// Idempotency makes a POST sent with an Idempotency-Key header safe to retry. The
+// status and body of the first successful response are kept for a day, keyed by the
+// user, the route and the key, and replayed verbatim to retries, without running the
+// handler again. A retry while the first request is still being handled, or one whose
+// body differs from the first's, gets 409; a first request that has not responded
+// within a minute is given up on, and a retry handles the request instead. A request
+// that fails is forgotten, so it can be retried with its key. Bodies over maxBodySize
+// are rejected, as the handlers would.
+func Idempotency(records repositories.IdempotencyRepository, maxBodySize int64) gin.HandlerFunc {
+	return idempotency(idempotencyRepo{records: records}, maxBodySize)
+}
+
+func idempotency(store IdempotencyStore, maxBodySize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{
+				Code:    models.CodeValidation,
+				Error:   "Invalid request headers",
+				Details: map[string]string{IdempotencyKeyHeader: fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength)},
+			})
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{
+					Code:    models.CodeValidation,
+					Error:   "Invalid request body",
+					Details: map[string]string{"body": fmt.Sprintf("must be at most %d bytes", tooLarge.Limit)},
+				})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "Invalid request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		hash := sha256.Sum256(body)
+		record := &models.IdempotencyRecord{
+			UserID:      idempotencyUser(c, body),
+			Endpoint:    c.Request.Method + " " + c.FullPath(),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+		}
+
+		ctx := c.Request.Context()
+		log := logger.FromContext(ctx).WithField("idempotency_key", key)
+		claimed, existing, err := store.Claim(ctx, record, time.Now().Add(-idempotencyTTL), idempotencyLease)
+		switch {
+		case errors.Is(err, repositories.ErrIdempotencyRecordGone):
+			abortInFlight(c)
+			return
+		case err != nil:
+			log.WithField("error", err.Error()).Error("Failed to claim idempotency key")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{Code: models.CodeInternal, Error: "request failed, please try again"})
+			return
+		case !claimed && existing.RequestHash != record.RequestHash:
+			c.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{
+				Code:  models.CodeIdempotencyKey,
+				Error: "Idempotency-Key was already used for a different request",
+			})
+			return
+		case !claimed && existing.StatusCode == nil:
+			abortInFlight(c)
+			return
+		case !claimed:
+			log.Info("Replaying response to idempotent request")
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(*existing.StatusCode, gin.MIMEJSON+"; charset=utf-8", existing.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		// The outcome is saved even once the request's context is done, and the claim is
+		// given up if a handler panics, so the key does not stay in flight for a day
+		saveCtx := context.WithoutCancel(ctx)
+		saved := false
+		defer func() {
+			if saved {
+				return
+			}
+			if err := store.Release(saveCtx, record); err != nil {
+				log.WithField("error", err.Error()).Error("Failed to release idempotency key")
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		status := recorder.Status()
+		if !recorder.Written() || status < 200 || status >= 300 {
+			return
+		}
+		record.StatusCode = &status
+		record.ResponseBody = recorder.body.Bytes()
+		if err := store.Complete(saveCtx, record); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to save idempotent response")
+			return
+		}
+		saved = true
+	}
+}
+
+// abortInFlight answers a retry of a request that is still being handled
+func abortInFlight(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{
+		Code:  models.CodeIdempotencyKey,
+		Error: "a request with this Idempotency-Key is still being processed, please retry later",
+	})
+}
+
+// idempotencyUser is the user a request acts for: the one in its path, or for a
+// transfer, the sender in its body
+func idempotencyUser(c *gin.Context, body []byte) string {
+	if userID := c.Param("user_id"); userID != "" {
+		return userID
+	}
+	if userID := c.Param("id"); userID != "" {
+		return userID
+	}
+	var sender struct {
+		FromUserID string `json:"from_user_id"`
+	}
+	// A body that does not decode is the handler's to reject
+	_ = json.Unmarshal(body, &sender)
+	return sender.FromUserID
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// RunIdempotencyPurge deletes the idempotency records no longer replayed every hour
+// until ctx is cancelled
+func RunIdempotencyPurge(ctx context.Context, records repositories.IdempotencyRepository) {
+	var store IdempotencyStore = idempotencyRepo{records: records}
+	log := logger.WithOperation("idempotency_purge").WithContext(ctx)
+	log.WithField("interval", idempotencyPurgeInterval.String()).Info("Idempotency purge started")
+
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := store.DeleteExpired(ctx, time.Now().Add(-idempotencyTTL))
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to purge idempotency records")
+		} else if deleted > 0 {
+			log.WithField("count", deleted).Info("Purged idempotency records")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Idempotency purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
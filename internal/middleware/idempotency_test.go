@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore is an IdempotencyStore in a map, keyed like the table
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]models.IdempotencyRecord{}}
+}
+
+func (s *memoryIdempotencyStore) id(record *models.IdempotencyRecord) string {
+	return record.UserID + "|" + record.Endpoint + "|" + record.Key
+}
+
+func (s *memoryIdempotencyStore) Claim(_ context.Context, record *models.IdempotencyRecord, expiredBefore time.Time, lease time.Duration) (bool, *models.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	existing, ok := s.records[s.id(record)]
+	leaseHeld := existing.CompletedAt != nil || existing.LockedUntil.After(now)
+	if ok && !existing.CreatedAt.Before(expiredBefore) && leaseHeld {
+		return false, &existing, nil
+	}
+	record.CreatedAt = now
+	record.LockedUntil = now.Add(lease)
+	s.records[s.id(record)] = *record
+	return true, nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, record *models.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[s.id(record)] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, record *models.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, s.id(record))
+	return nil
+}
+
+func (s *memoryIdempotencyStore) DeleteExpired(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+// newIdempotentRouter serves a deposit that succeeds for positive amounts, counting how
+// often it ran
+func newIdempotentRouter(store IdempotencyStore, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/wallets/:user_id/deposit", idempotency(store, 1024), func(c *gin.Context) {
+		*calls++
+		var req struct {
+			Amount int `json:"amount"`
+		}
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil || req.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: models.CodeValidation, Error: "invalid amount"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"call": *calls, "amount": req.Amount})
+	})
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/wallets/42/deposit", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, &calls)
+
+	first := sendIdempotent(router, "k1", `{"amount": 10}`)
+	retry := sendIdempotent(router, "k1", `{"amount": 10}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, first.Code, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+
+	record := store.records["42|POST /wallets/:user_id/deposit|k1"]
+	if assert.NotNil(t, record.StatusCode) {
+		assert.Equal(t, http.StatusOK, *record.StatusCode)
+	}
+	assert.JSONEq(t, `{"call": 1, "amount": 10}`, string(record.ResponseBody))
+}
+
+func TestIdempotency_DifferentBodyConflicts(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyStore(), &calls)
+
+	sendIdempotent(router, "k1", `{"amount": 10}`)
+	w := sendIdempotent(router, "k1", `{"amount": 20}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.CodeIdempotencyKey, resp.Code)
+}
+
+func TestIdempotency_InFlightConflicts(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, &calls)
+	// A first request holding the key has claimed it but not responded yet
+	body := `{"amount": 10}`
+	hash := sha256.Sum256([]byte(body))
+	store.records["42|POST /wallets/:user_id/deposit|k1"] = models.IdempotencyRecord{
+		UserID:      "42",
+		Endpoint:    "POST /wallets/:user_id/deposit",
+		Key:         "k1",
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   time.Now(),
+		LockedUntil: time.Now().Add(idempotencyLease),
+	}
+
+	w := sendIdempotent(router, "k1", body)
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.CodeIdempotencyKey, resp.Code)
+	assert.Contains(t, resp.Error, "still being processed")
+}
+
+func TestIdempotency_LostRequestIsTakenOverAfterLease(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, &calls)
+	// A first request claimed the key, then the server stopped before it responded
+	body := `{"amount": 10}`
+	hash := sha256.Sum256([]byte(body))
+	store.records["42|POST /wallets/:user_id/deposit|k1"] = models.IdempotencyRecord{
+		UserID:      "42",
+		Endpoint:    "POST /wallets/:user_id/deposit",
+		Key:         "k1",
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   time.Now().Add(-2 * idempotencyLease),
+		LockedUntil: time.Now().Add(-idempotencyLease),
+	}
+
+	w := sendIdempotent(router, "k1", body)
+	replay := sendIdempotent(router, "k1", body)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotency_FailedRequestCanBeRetried(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, &calls)
+
+	failed := sendIdempotent(router, "k1", `{"amount": -10}`)
+	retry := sendIdempotent(router, "k1", `{"amount": -10}`)
+
+	assert.Equal(t, http.StatusBadRequest, failed.Code)
+	assert.Equal(t, http.StatusBadRequest, retry.Code)
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotency_ExpiredRecordIsReplaced(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, &calls)
+
+	sendIdempotent(router, "k1", `{"amount": 10}`)
+	record := store.records["42|POST /wallets/:user_id/deposit|k1"]
+	record.CreatedAt = time.Now().Add(-idempotencyTTL - time.Minute)
+	store.records["42|POST /wallets/:user_id/deposit|k1"] = record
+	w := sendIdempotent(router, "k1", `{"amount": 20}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"call": 2, "amount": 20}`, w.Body.String())
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, &calls)
+
+	sendIdempotent(router, "", `{"amount": 10}`)
+	sendIdempotent(router, "", `{"amount": 10}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotency_RejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		body        string
+		wantDetails map[string]string
+	}{
+		{
+			name:        "long key",
+			key:         strings.Repeat("k", maxIdempotencyKeyLength+1),
+			body:        `{"amount": 10}`,
+			wantDetails: map[string]string{IdempotencyKeyHeader: "must be at most 255 characters"},
+		},
+		{
+			name:        "large body",
+			key:         "k1",
+			body:        `{"amount": 10, "description": "` + strings.Repeat("x", 1024) + `"}`,
+			wantDetails: map[string]string{"body": "must be at most 1024 bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryIdempotencyStore()
+			calls := 0
+			router := newIdempotentRouter(store, &calls)
+
+			w := sendIdempotent(router, tt.key, tt.body)
+
+			assert.Equal(t, 0, calls)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var resp models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantDetails, resp.Details)
+			assert.Empty(t, store.records)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recoveredPanics counts the panics Recovery has recovered from since startup
+var recoveredPanics atomic.Int64
+
+// RecoveredPanics reports how many panics Recovery has recovered from since startup
+func RecoveredPanics() int64 {
+	return recoveredPanics.Load()
+}
+
+// Recovery turns a panic in a later handler into a 500 with an INTERNAL_ERROR body
+// carrying the request's ID, so the client can report it, and logs the panic with its
+// stack trace. It must come after RequestID for the ID to be known, and after AccessLog
+// for the 500 to be logged there.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			recoveredPanics.Add(1)
+			requestID := logger.RequestID(c.Request.Context())
+			logger.FromContext(c.Request.Context()).WithFields(map[string]interface{}{
+				"panic": fmt.Sprint(rec),
+				"stack": string(debug.Stack()),
+			}).Error("Recovered from panic")
+
+			// Once the response has started it can only be cut short
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+				Code:      models.CodeInternal,
+				Error:     "internal server error",
+				RequestID: requestID,
+			})
+		}()
+		c.Next()
+	}
+}
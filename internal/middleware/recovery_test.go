@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hook := test.NewLocal(logger.Get())
+	router := gin.New()
+	router.Use(RequestID(), RequestLogger(), Recovery())
+	router.GET("/wallets/:user_id", func(c *gin.Context) {
+		panic("wallet is nil")
+	})
+	before := RecoveredPanics()
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets/42", nil)
+	req.Header.Set(RequestIDHeader, "req-7f1c1a52")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var resp models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.ErrorResponse{Code: models.CodeInternal, Error: "internal server error", RequestID: "req-7f1c1a52"}, resp)
+	assert.Equal(t, before+1, RecoveredPanics())
+	if assert.NotNil(t, hook.LastEntry()) {
+		entry := hook.LastEntry()
+		assert.Equal(t, logrus.ErrorLevel, entry.Level)
+		assert.Equal(t, "Recovered from panic", entry.Message)
+		assert.Equal(t, "wallet is nil", entry.Data["panic"])
+		assert.Contains(t, entry.Data["stack"], "recovery_test.go")
+		assert.Equal(t, "req-7f1c1a52", entry.Data["request_id"])
+		assert.Equal(t, "/wallets/:user_id", entry.Data["path"])
+	}
+}
+
+func TestRecovery_AfterResponseStarted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	test.NewLocal(logger.Get())
+	router := gin.New()
+	router.Use(Recovery())
+	router.GET("/export", func(c *gin.Context) {
+		c.String(http.StatusOK, "id,amount\n")
+		panic("row is nil")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,amount\n", w.Body.String())
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"walletapp/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header a request's ID is read from and echoed in
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs taken from clients, which end up in every log line
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID, the client's X-Request-ID when it sends a usable
+// one and a new UUID otherwise. The ID is put in the request context, where the logger
+// picks it up for every line logged for the request, and echoed in the response header
+// so a client can quote it when reporting a problem.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID reports whether id is short and printable ASCII, so a client cannot
+// forge log lines or bloat them through it
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
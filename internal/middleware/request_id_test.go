@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"walletapp/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantEcho bool
+	}{
+		{name: "client ID", header: "req-7f1c1a52", wantEcho: true},
+		{name: "no ID", header: ""},
+		{name: "unprintable ID", header: "req\x1b[31m"},
+		{name: "long ID", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := test.NewLocal(logger.Get())
+			router := gin.New()
+			router.Use(RequestID(), RequestLogger())
+			router.GET("/wallets/:user_id", func(c *gin.Context) {
+				logger.WithUser(c.Param("user_id")).WithContext(c.Request.Context()).Info("Balance inquiry request received")
+				logger.FromContext(c.Request.Context()).Info("Query finished")
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/wallets/42", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.wantEcho {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.NoError(t, uuid.Validate(id))
+			}
+			if assert.Len(t, hook.AllEntries(), 2) {
+				for _, entry := range hook.AllEntries() {
+					assert.Equal(t, id, entry.Data["request_id"])
+				}
+				assert.Equal(t, "42", hook.AllEntries()[0].Data["user_id"])
+				assert.Equal(t, "/wallets/:user_id", hook.AllEntries()[1].Data["path"])
+			}
+		})
+	}
+}
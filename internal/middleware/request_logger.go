@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"walletapp/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestLogger puts a logger carrying the request's method and route in the request
+// context, so the database queries it runs are logged with them
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logger.WithFields(logrus.Fields{
+			"method": c.Request.Method,
+			"path":   c.FullPath(),
+		})
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), log))
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"walletapp/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var fields logrus.Fields
+	router := gin.New()
+	router.Use(RequestLogger())
+	router.GET("/wallets/:user_id", func(c *gin.Context) {
+		fields = logger.FromContext(c.Request.Context()).Data
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallets/42", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.MethodGet, fields["method"])
+	assert.Equal(t, "/wallets/:user_id", fields["path"])
+}
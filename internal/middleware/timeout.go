@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRequestTimeout is how long a request may take when REQUEST_TIMEOUT is not set
+const DefaultRequestTimeout = 30 * time.Second
+
+// RequestTimeoutFromEnv reads the request timeout from REQUEST_TIMEOUT, a duration such
+// as "15s", where "0" lifts the limit. Unset, it is DefaultRequestTimeout.
+func RequestTimeoutFromEnv() (time.Duration, error) {
+	raw := os.Getenv("REQUEST_TIMEOUT")
+	if raw == "" {
+		return DefaultRequestTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid REQUEST_TIMEOUT %q: must be a duration of zero or more", raw)
+	}
+	return d, nil
+}
+
+// Timeout gives every request a context that is done after d, so the queries of a
+// handler that runs too long are canceled instead of holding it forever. A handler
+// that has not responded by the time it returns past the deadline gets a 504. Zero
+// leaves requests unbounded.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			logger.FromContext(ctx).WithField("timeout_ms", d.Milliseconds()).Warn("Request timed out")
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, models.ErrorResponse{Code: models.CodeTimeout, Error: "the request took too long, please try again"})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout_SetsRequestDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var deadline time.Time
+	var ok bool
+	router := gin.New()
+	router.Use(Timeout(time.Minute))
+	router.GET("/wallets", func(c *gin.Context) {
+		deadline, ok = c.Request.Context().Deadline()
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallets", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
+
+func TestTimeout_RespondsWhenHandlerDidNot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Timeout(10 * time.Millisecond))
+	router.GET("/wallets", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallets", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestTimeout_ZeroLeavesRequestsUnbounded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var ok bool
+	router := gin.New()
+	router.Use(Timeout(0))
+	router.GET("/wallets", func(c *gin.Context) {
+		_, ok = c.Request.Context().Deadline()
+		c.Status(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wallets", nil))
+
+	assert.False(t, ok)
+}
+
+func TestRequestTimeoutFromEnv(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{raw: "", want: DefaultRequestTimeout},
+		{raw: "15s", want: 15 * time.Second},
+		{raw: "0", want: 0},
+		{raw: "-1s", wantErr: true},
+		{raw: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			t.Setenv("REQUEST_TIMEOUT", tt.raw)
+
+			got, err := RequestTimeoutFromEnv()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArchiveRunStatus is where a run of the transaction archive job stands
+type ArchiveRunStatus string
+
+const (
+	ArchiveRunRunning   ArchiveRunStatus = "RUNNING"
+	ArchiveRunCompleted ArchiveRunStatus = "COMPLETED"
+	ArchiveRunFailed    ArchiveRunStatus = "FAILED"
+)
+
+// ArchiveRun is one run of the job that moves transactions created before Cutoff into
+// the archive. A run stopped by a crash or shutdown stays RUNNING and is resumed.
+type ArchiveRun struct {
+	ID     uuid.UUID        `json:"id"`
+	Cutoff time.Time        `json:"cutoff" example:"2023-03-01T00:00:00Z"`
+	Status ArchiveRunStatus `json:"status" example:"RUNNING"`
+	// Archived counts the transactions the run moved so far
+	Archived int64 `json:"archived" example:"12000"`
+	// Remaining counts the transactions before Cutoff still to move, set when
+	// reporting progress
+	Remaining  *int64     `json:"remaining,omitempty" example:"3500"`
+	Error      *string    `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Beneficiary is a user OwnerUserID has saved to their contacts, under an optional
+// nickname
+type Beneficiary struct {
+	ID                uuid.UUID `json:"id"`
+	OwnerUserID       uuid.UUID `json:"owner_user_id"`
+	BeneficiaryUserID uuid.UUID `json:"beneficiary_user_id"`
+	Nickname          *string   `json:"nickname,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type CreateBeneficiaryRequest struct {
+	BeneficiaryUserID string `json:"beneficiary_user_id" binding:"required"`
+	Nickname          string `json:"nickname,omitempty" example:"Mum"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationToken proves, to whoever sends it back, that a user received it at
+// their email. It works once, before ExpiresAt, and only while the user's email is
+// still Email. Only TokenHash, the hex SHA-256 of the token, is stored.
+type EmailVerificationToken struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// Email is the address the token was sent to
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	// UsedAt is when the token, or another one of the same user, verified the email
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// VerifyEmailRequest verifies a user's email with the token they were emailed
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -1,5 +1,48 @@
 package models
 
+// Codes of ErrorResponse. Unlike the message, a code never changes once clients can see
+// it, so they should match on the code.
+const (
+	// CodeValidation is a request that is malformed or has invalid fields
+	CodeValidation = "VALIDATION_ERROR"
+	// CodeUnauthorized is a request without valid credentials
+	CodeUnauthorized = "UNAUTHORIZED"
+	// CodeForbidden is a request that is not allowed
+	CodeForbidden = "FORBIDDEN"
+	// CodeNotFound is a request for something that does not exist
+	CodeNotFound       = "NOT_FOUND"
+	CodeUserNotFound   = "USER_NOT_FOUND"
+	CodeWalletNotFound = "WALLET_NOT_FOUND"
+	CodeWalletFrozen   = "WALLET_FROZEN"
+	CodeWalletClosed   = "WALLET_CLOSED"
+	// CodeEmailNotVerified is an operation the user must verify their email for
+	CodeEmailNotVerified = "EMAIL_NOT_VERIFIED"
+	CodeSelfTransfer     = "SELF_TRANSFER"
+	CodeIdempotencyKey   = "IDEMPOTENCY_KEY_CONFLICT"
+	CodeConcurrentUpdate = "CONCURRENT_UPDATE"
+	// CodeConflict is a request that conflicts with the current state of what it changes
+	CodeConflict = "CONFLICT"
+	// CodeGone is a request for something that has expired or ended
+	CodeGone                = "GONE"
+	CodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+	// CodeLimitExceeded is an operation over a daily, monthly or KYC tier limit
+	CodeLimitExceeded = "LIMIT_EXCEEDED"
+	// CodeBusinessRule is a request a business rule refuses
+	CodeBusinessRule      = "BUSINESS_RULE_VIOLATION"
+	CodeTooManyOperations = "TOO_MANY_OPERATIONS"
+	// CodeTimeout is a request that ran past a query or request timeout
+	CodeTimeout = "TIMEOUT"
+	// CodeInternal is an unexpected error, whose details are only logged
+	CodeInternal = "INTERNAL_ERROR"
+)
+
 type ErrorResponse struct {
-	Error string `json:"error"`
+	// Code identifies the error for clients, see the Code constants
+	Code string `json:"code" enums:"VALIDATION_ERROR,UNAUTHORIZED,FORBIDDEN,NOT_FOUND,USER_NOT_FOUND,WALLET_NOT_FOUND,WALLET_FROZEN,WALLET_CLOSED,EMAIL_NOT_VERIFIED,SELF_TRANSFER,IDEMPOTENCY_KEY_CONFLICT,CONCURRENT_UPDATE,CONFLICT,GONE,INSUFFICIENT_BALANCE,LIMIT_EXCEEDED,BUSINESS_RULE_VIOLATION,TOO_MANY_OPERATIONS,TIMEOUT,INTERNAL_ERROR" example:"WALLET_NOT_FOUND"`
+	// Error explains the error to a person and may be reworded
+	Error string `json:"error" example:"wallet not found"`
+	// Details explains the invalid fields of a VALIDATION_ERROR, by field name
+	Details map[string]string `json:"details,omitempty"`
+	// RequestID identifies the request an INTERNAL_ERROR came from, to quote when reporting it
+	RequestID string `json:"request_id,omitempty" example:"0b6e3c9a-4f1d-4e8e-9a39-5c2f0d7e8b41"`
 }
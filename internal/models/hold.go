@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// HoldStatus is where an authorization hold is in its lifecycle. A hold starts ACTIVE
+// and ends either CAPTURED, when the funds are withdrawn, or RELEASED.
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "ACTIVE"
+	HoldStatusCaptured HoldStatus = "CAPTURED"
+	HoldStatusReleased HoldStatus = "RELEASED"
+)
+
+// Hold reserves part of a wallet's balance, so it can no longer be spent until the
+// hold is captured or released
+type Hold struct {
+	ID        uuid.UUID    `json:"id"`
+	WalletID  uuid.UUID    `json:"wallet_id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Amount    money.Amount `json:"amount" swaggertype:"string" example:"20.00"`
+	Status    HoldStatus   `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type HoldRequest struct {
+	Amount money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"20.00"`
+}
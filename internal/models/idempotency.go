@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the response to a request sent with an Idempotency-Key header,
+// kept to be replayed to retries of the request. StatusCode is nil while the first
+// request is still being handled, which keeps retries out until LockedUntil.
+type IdempotencyRecord struct {
+	UserID       string
+	Endpoint     string
+	Key          string
+	RequestHash  string
+	StatusCode   *int
+	ResponseBody []byte
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+	LockedUntil  time.Time
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// InterestAccrual is a day of interest credited to a wallet. A wallet accrues at most
+// once per date.
+type InterestAccrual struct {
+	WalletID      uuid.UUID `json:"wallet_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	Date          time.Time `json:"date" example:"2025-01-15T00:00:00Z"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	// Balance is what the interest was computed on, at RateBps a year
+	Balance   money.Amount `json:"balance" swaggertype:"string" example:"10000.00"`
+	RateBps   int          `json:"rate_bps" example:"250"`
+	Amount    money.Amount `json:"amount" swaggertype:"string" example:"0.68"`
+	Currency  string       `json:"currency" example:"USD"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// InterestRun summarizes one run of the interest accrual job
+type InterestRun struct {
+	Date time.Time `json:"date" example:"2025-01-15T00:00:00Z"`
+	// Accruals are the wallets credited by this run; wallets that already accrued for
+	// Date, or whose interest rounds to zero, are left out
+	Accruals []InterestAccrual `json:"accruals"`
+	// Failed is the number of wallets whose accrual failed and is retried on the next run
+	Failed int `json:"failed"`
+}
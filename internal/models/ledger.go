@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+type LedgerDirection string
+
+const (
+	LedgerDebit  LedgerDirection = "DEBIT"
+	LedgerCredit LedgerDirection = "CREDIT"
+)
+
+// Ledger accounts. User wallets share the WALLET account and are told apart by
+// WalletID, money entering or leaving the system goes through CASH, manual
+// corrections by admins through ADJUSTMENTS, currency exchanges through EXCHANGE,
+// interest paid to wallets through INTEREST and promotional credits through BONUS.
+const (
+	LedgerAccountWallet      = "WALLET"
+	LedgerAccountCash        = "CASH"
+	LedgerAccountAdjustments = "ADJUSTMENTS"
+	LedgerAccountExchange    = "EXCHANGE"
+	LedgerAccountInterest    = "INTEREST"
+	LedgerAccountBonus       = "BONUS"
+)
+
+// LedgerEntry is one side of a double-entry bookkeeping record. The entries of
+// one operation share a GroupID and their debits and credits sum to the same amount.
+type LedgerEntry struct {
+	ID        uuid.UUID       `json:"id"`
+	GroupID   uuid.UUID       `json:"group_id"`
+	Account   string          `json:"account"`
+	WalletID  *uuid.UUID      `json:"wallet_id,omitempty"`
+	Direction LedgerDirection `json:"direction"`
+	Amount    money.Amount    `json:"amount" swaggertype:"string" example:"100.00"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// LedgerReport compares a wallet's stored balance with the balance derived from
+// its ledger entries. Discrepancy is Balance minus LedgerBalance.
+type LedgerReport struct {
+	UserID        uuid.UUID    `json:"user_id"`
+	WalletID      uuid.UUID    `json:"wallet_id"`
+	Balance       money.Amount `json:"balance" swaggertype:"string" example:"100.00"`
+	LedgerBalance money.Amount `json:"ledger_balance" swaggertype:"string" example:"100.00"`
+	Discrepancy   money.Amount `json:"discrepancy" swaggertype:"string" example:"0.00"`
+	Balanced      bool         `json:"balanced"`
+}
+
+// Discrepancy describes a wallet whose stored balance disagrees with the net of its
+// transactions. Delta is Balance minus TransactionBalance.
+type Discrepancy struct {
+	WalletID           uuid.UUID    `json:"wallet_id"`
+	UserID             uuid.UUID    `json:"user_id"`
+	Balance            money.Amount `json:"balance" swaggertype:"string" example:"100.00"`
+	TransactionBalance money.Amount `json:"transaction_balance" swaggertype:"string" example:"95.00"`
+	Delta              money.Amount `json:"delta" swaggertype:"string" example:"5.00"`
+}
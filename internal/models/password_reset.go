@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetToken lets whoever holds the token emailed to a user set the user's
+// password, once, before ExpiresAt. Only TokenHash, the hex SHA-256 of the token, is
+// stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	// UsedAt is when the token, or a later one of the same user, set the password
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"johndoe@gmail.com"`
+}
+
+// ResetPasswordWithTokenRequest sets a user's password with the token they were
+// emailed after asking to reset it
+type ResetPasswordWithTokenRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// PaymentRequestStatus is where a payment request is in its lifecycle. A request
+// starts PENDING and ends PAID, DECLINED or, once its expiry passes, EXPIRED.
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestStatusPending  PaymentRequestStatus = "PENDING"
+	PaymentRequestStatusPaid     PaymentRequestStatus = "PAID"
+	PaymentRequestStatusDeclined PaymentRequestStatus = "DECLINED"
+	PaymentRequestStatusExpired  PaymentRequestStatus = "EXPIRED"
+)
+
+// PaymentRequest asks PayerID to send Amount to RequesterID. TransferID is the
+// transfer that paid it, once PAID.
+type PaymentRequest struct {
+	ID          uuid.UUID            `json:"id"`
+	RequesterID uuid.UUID            `json:"requester_id"`
+	PayerID     uuid.UUID            `json:"payer_id"`
+	Amount      money.Amount         `json:"amount" swaggertype:"string" example:"12.50"`
+	Message     *string              `json:"message,omitempty"`
+	Status      PaymentRequestStatus `json:"status"`
+	TransferID  *uuid.UUID           `json:"transfer_id,omitempty"`
+	ExpiresAt   time.Time            `json:"expires_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+type CreatePaymentRequestRequest struct {
+	RequesterID string       `json:"requester_id" binding:"required"`
+	PayerID     string       `json:"payer_id" binding:"required"`
+	Amount      money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"12.50"`
+	Message     string       `json:"message,omitempty" example:"pizza on Friday"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// Referral records that ReferrerID referred ReferredUserID at signup, and the bonus the
+// referrer was credited for it
+type Referral struct {
+	ReferredUserID   uuid.UUID `json:"referred_user_id"`
+	ReferredUsername string    `json:"referred_username,omitempty"`
+	ReferrerID       uuid.UUID `json:"referrer_id"`
+	// TransactionID is the referrer's BONUS transaction, nil when no bonus was paid
+	TransactionID *uuid.UUID   `json:"transaction_id,omitempty"`
+	Bonus         money.Amount `json:"bonus" swaggertype:"string" example:"5.00"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// ReferralSummary lists the users a user referred and the bonuses earned for them
+type ReferralSummary struct {
+	Referrals  []Referral   `json:"referrals"`
+	TotalBonus money.Amount `json:"total_bonus" swaggertype:"string" example:"15.00"`
+}
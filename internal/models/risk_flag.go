@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RiskFlag records that a transaction matched a risk rule. The operation behind it
+// still went through; flags only mark it for review.
+type RiskFlag struct {
+	ID            uuid.UUID `json:"id"`
+	WalletID      uuid.UUID `json:"wallet_id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Rule          string    `json:"rule" example:"large_amount"`
+	Details       string    `json:"details" example:"amount 15000.00 is over 10000.00"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RiskFlagFilter narrows a listing of risk flags. Empty fields match every flag; To
+// is exclusive.
+type RiskFlagFilter struct {
+	Rule string
+	From *time.Time
+	To   *time.Time
+}
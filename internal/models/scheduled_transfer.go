@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// ScheduledTransferStatus is where a scheduled transfer is in its lifecycle. It stays
+// PENDING through failed attempts that will be retried, and ends as COMPLETED or FAILED,
+// or CANCELLED when it belongs to a standing order that was cancelled.
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferStatusPending   ScheduledTransferStatus = "PENDING"
+	ScheduledTransferStatusCompleted ScheduledTransferStatus = "COMPLETED"
+	ScheduledTransferStatusFailed    ScheduledTransferStatus = "FAILED"
+	ScheduledTransferStatusCancelled ScheduledTransferStatus = "CANCELLED"
+)
+
+// ScheduledTransfer is a transfer that the background worker executes once ExecuteAt
+// has passed. StandingOrderID is set when it is an occurrence of a standing order.
+type ScheduledTransfer struct {
+	ID              uuid.UUID               `json:"id"`
+	FromUserID      uuid.UUID               `json:"from_user_id"`
+	ToUserID        uuid.UUID               `json:"to_user_id"`
+	Amount          money.Amount            `json:"amount" swaggertype:"string" example:"25.00"`
+	ExecuteAt       time.Time               `json:"execute_at"`
+	Status          ScheduledTransferStatus `json:"status"`
+	Attempts        int                     `json:"attempts"`
+	LastError       *string                 `json:"last_error,omitempty"`
+	TransferID      *uuid.UUID              `json:"transfer_id,omitempty"`
+	StandingOrderID *uuid.UUID              `json:"standing_order_id,omitempty"`
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+}
+
+type ScheduleTransferRequest struct {
+	FromUserID string       `json:"from_user_id" binding:"required"`
+	ToUserID   string       `json:"to_user_id" binding:"required"`
+	Amount     money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"25.00"`
+	ExecuteAt  time.Time    `json:"execute_at" binding:"required" example:"2025-01-31T09:00:00Z"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// StandingOrderInterval is how often a standing order repeats
+type StandingOrderInterval string
+
+const (
+	StandingOrderIntervalDaily   StandingOrderInterval = "DAILY"
+	StandingOrderIntervalWeekly  StandingOrderInterval = "WEEKLY"
+	StandingOrderIntervalMonthly StandingOrderInterval = "MONTHLY"
+)
+
+// StandingOrderStatus is where a standing order is in its lifecycle. An ACTIVE order
+// ends as COMPLETED when it reaches its end condition, CANCELLED by its owner, or
+// FAILED after too many consecutive failed occurrences.
+type StandingOrderStatus string
+
+const (
+	StandingOrderStatusActive    StandingOrderStatus = "ACTIVE"
+	StandingOrderStatusCompleted StandingOrderStatus = "COMPLETED"
+	StandingOrderStatusCancelled StandingOrderStatus = "CANCELLED"
+	StandingOrderStatusFailed    StandingOrderStatus = "FAILED"
+)
+
+// StandingOrder is a transfer repeated every interval from StartAt, until EndAt or
+// MaxOccurrences is reached. Each occurrence runs as a ScheduledTransfer.
+type StandingOrder struct {
+	ID                  uuid.UUID             `json:"id"`
+	FromUserID          uuid.UUID             `json:"from_user_id"`
+	ToUserID            uuid.UUID             `json:"to_user_id"`
+	Amount              money.Amount          `json:"amount" swaggertype:"string" example:"25.00"`
+	Interval            StandingOrderInterval `json:"interval"`
+	StartAt             time.Time             `json:"start_at"`
+	EndAt               *time.Time            `json:"end_at,omitempty"`
+	MaxOccurrences      *int                  `json:"max_occurrences,omitempty"`
+	Occurrences         int                   `json:"occurrences"`
+	ConsecutiveFailures int                   `json:"consecutive_failures"`
+	NextRunAt           time.Time             `json:"next_run_at"`
+	Status              StandingOrderStatus   `json:"status"`
+	CreatedAt           time.Time             `json:"created_at"`
+	UpdatedAt           time.Time             `json:"updated_at"`
+}
+
+type StandingOrderRequest struct {
+	ToUserID       string                `json:"to_user_id" binding:"required"`
+	Amount         money.Amount          `json:"amount" binding:"required" swaggertype:"string" example:"25.00"`
+	Interval       StandingOrderInterval `json:"interval" binding:"required" enums:"DAILY,WEEKLY,MONTHLY"`
+	StartAt        time.Time             `json:"start_at" binding:"required" example:"2025-01-31T09:00:00Z"`
+	EndAt          *time.Time            `json:"end_at,omitempty" example:"2025-12-31T09:00:00Z"`
+	MaxOccurrences *int                  `json:"max_occurrences,omitempty" binding:"omitempty,min=1"`
+}
@@ -4,4 +4,18 @@ type SuccessResponse struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	// NextCursor fetches the page after Data from paginated endpoints, when there may be one
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Pagination describes where the page in Data sits, for endpoints that page a list
+	// with limit and offset
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes a page of a list fetched with limit and offset
+type Pagination struct {
+	// Total counts the items matching the request across all pages
+	Total   int  `json:"total" example:"120"`
+	Limit   int  `json:"limit" example:"50"`
+	Offset  int  `json:"offset" example:"0"`
+	HasMore bool `json:"has_more" example:"true"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// TransactionTypeTotal is how many completed transactions of one type a wallet made in
+// a period, and their total amount. Adjustments and exchanges total their signed amounts.
+type TransactionTypeTotal struct {
+	Type  TransactionType `json:"type"`
+	Count int             `json:"count"`
+	Total money.Amount    `json:"total" swaggertype:"string" example:"250.00"`
+}
+
+// WalletSummary aggregates a wallet's completed transactions between From and To, both
+// UTC days included. Bonuses and interest are reported apart from deposits, and ByType
+// lists every type with transactions, including reversals, adjustments and exchanges.
+type WalletSummary struct {
+	WalletID         uuid.UUID              `json:"wallet_id"`
+	Currency         string                 `json:"currency"`
+	From             string                 `json:"from" example:"2024-03-01"`
+	To               string                 `json:"to" example:"2024-03-31"`
+	OpeningBalance   money.Amount           `json:"opening_balance" swaggertype:"string" example:"100.00"`
+	ClosingBalance   money.Amount           `json:"closing_balance" swaggertype:"string" example:"305.50"`
+	NetChange        money.Amount           `json:"net_change" swaggertype:"string" example:"205.50"`
+	Deposited        money.Amount           `json:"deposited" swaggertype:"string" example:"500.00"`
+	Withdrawn        money.Amount           `json:"withdrawn" swaggertype:"string" example:"200.00"`
+	TransferredIn    money.Amount           `json:"transferred_in" swaggertype:"string" example:"50.00"`
+	TransferredOut   money.Amount           `json:"transferred_out" swaggertype:"string" example:"150.00"`
+	Fees             money.Amount           `json:"fees" swaggertype:"string" example:"1.50"`
+	Bonuses          money.Amount           `json:"bonuses" swaggertype:"string" example:"5.00"`
+	Interest         money.Amount           `json:"interest" swaggertype:"string" example:"2.00"`
+	TransactionCount int                    `json:"transaction_count"`
+	ByType           []TransactionTypeTotal `json:"by_type"`
+}
+
+// WalletTotals is what a wallet moved over its whole life, from its completed
+// transactions. A reversed transfer counts for nothing: the reversal nets out the
+// transfer and its fee. Adjustments and exchanges total their signed amounts.
+type WalletTotals struct {
+	Deposited        money.Amount           `json:"deposited" swaggertype:"string" example:"500.00"`
+	Withdrawn        money.Amount           `json:"withdrawn" swaggertype:"string" example:"200.00"`
+	TransferredIn    money.Amount           `json:"transferred_in" swaggertype:"string" example:"50.00"`
+	TransferredOut   money.Amount           `json:"transferred_out" swaggertype:"string" example:"150.00"`
+	Fees             money.Amount           `json:"fees" swaggertype:"string" example:"1.50"`
+	Bonuses          money.Amount           `json:"bonuses" swaggertype:"string" example:"5.00"`
+	Interest         money.Amount           `json:"interest" swaggertype:"string" example:"2.00"`
+	Adjustments      money.Amount           `json:"adjustments" swaggertype:"string" example:"-10.00"`
+	Exchanged        money.Amount           `json:"exchanged" swaggertype:"string" example:"-92.00"`
+	TransactionCount int                    `json:"transaction_count"`
+	ByType           []TransactionTypeTotal `json:"by_type"`
+}
+
+// WalletStatement is a wallet's statement for a UTC calendar month: the balance it
+// opened and closed the month with, the totals of its completed transactions per type,
+// and a page of those transactions, oldest first. A month before the wallet was created
+// has no transactions and opens and closes at zero.
+type WalletStatement struct {
+	WalletID         uuid.UUID              `json:"wallet_id"`
+	Currency         string                 `json:"currency"`
+	Year             int                    `json:"year" example:"2024"`
+	Month            int                    `json:"month" example:"3"`
+	From             string                 `json:"from" example:"2024-03-01"`
+	To               string                 `json:"to" example:"2024-03-31"`
+	OpeningBalance   money.Amount           `json:"opening_balance" swaggertype:"string" example:"100.00"`
+	ClosingBalance   money.Amount           `json:"closing_balance" swaggertype:"string" example:"305.50"`
+	NetChange        money.Amount           `json:"net_change" swaggertype:"string" example:"205.50"`
+	TransactionCount int                    `json:"transaction_count"`
+	ByType           []TransactionTypeTotal `json:"by_type"`
+	Transactions     TransactionPage        `json:"transactions"`
+}
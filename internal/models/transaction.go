@@ -2,6 +2,7 @@ package models
 
 import (
 	"time"
+	"walletapp/internal/money"
 
 	"github.com/google/uuid"
 )
@@ -13,14 +14,155 @@ const (
 	TransactionTypeWithdraw    TransactionType = "WITHDRAW"
 	TransactionTypeTransferIn  TransactionType = "TRANSFER_IN"
 	TransactionTypeTransferOut TransactionType = "TRANSFER_OUT"
+	// TransactionTypeFee is a fee charged to the sender of a transfer, linked to the
+	// transfer by its transfer ID
+	TransactionTypeFee TransactionType = "FEE"
+	// Reversal legs undo a transfer, including its fee, and carry the original transfer ID
+	TransactionTypeTransferReversalIn  TransactionType = "TRANSFER_REVERSAL_IN"
+	TransactionTypeTransferReversalOut TransactionType = "TRANSFER_REVERSAL_OUT"
+	// TransactionTypeAdjustment is a manual correction by an admin. Its amount is signed:
+	// negative adjustments debit the wallet.
+	TransactionTypeAdjustment TransactionType = "ADJUSTMENT"
+	// TransactionTypeExchange is one leg of a currency exchange between a user's wallets.
+	// Its amount is signed: the source wallet's leg is negative.
+	TransactionTypeExchange TransactionType = "EXCHANGE"
+	// TransactionTypeInterest is a day of interest credited to a wallet that earns it
+	TransactionTypeInterest TransactionType = "INTEREST"
+	// TransactionTypeBonus is a promotional credit granted by a marketing campaign,
+	// which its metadata names. Bonuses are not deposits and are reported apart from them.
+	TransactionTypeBonus TransactionType = "BONUS"
+)
+
+// TransactionTypes lists every transaction type
+var TransactionTypes = []TransactionType{
+	TransactionTypeDeposit,
+	TransactionTypeWithdraw,
+	TransactionTypeTransferIn,
+	TransactionTypeTransferOut,
+	TransactionTypeFee,
+	TransactionTypeTransferReversalIn,
+	TransactionTypeTransferReversalOut,
+	TransactionTypeAdjustment,
+	TransactionTypeExchange,
+	TransactionTypeInterest,
+	TransactionTypeBonus,
+}
+
+// TransactionStatus is where a transaction is in its lifecycle. Synchronous operations
+// are COMPLETED as soon as they are recorded; asynchronous ones start as PENDING and
+// end as COMPLETED or FAILED.
+type TransactionStatus string
+
+const (
+	TransactionStatusPending   TransactionStatus = "PENDING"
+	TransactionStatusCompleted TransactionStatus = "COMPLETED"
+	TransactionStatusFailed    TransactionStatus = "FAILED"
 )
 
 type Transaction struct {
-	ID            uuid.UUID       `json:"id"`
-	WalletID      uuid.UUID       `json:"wallet_id"`
-	Type          TransactionType `json:"type"`
-	Amount        float64         `json:"amount"`
-	RelatedUserID *string         `json:"related_user_id,omitempty"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
+	ID       uuid.UUID         `json:"id"`
+	WalletID uuid.UUID         `json:"wallet_id"`
+	Type     TransactionType   `json:"type"`
+	Status   TransactionStatus `json:"status"`
+	Amount   money.Amount      `json:"amount" swaggertype:"string" example:"100.00"`
+	// Currency is the ISO 4217 code of the wallet the transaction belongs to
+	Currency          string            `json:"currency" example:"USD"`
+	RelatedUserID     *string           `json:"related_user_id,omitempty"`
+	TransferID        *uuid.UUID        `json:"transfer_id,omitempty"`
+	IdempotencyKey    *string           `json:"idempotency_key,omitempty"`
+	ExternalReference *string           `json:"external_reference,omitempty"`
+	Description       *string           `json:"description,omitempty" example:"rent for May"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	// PerformedBy is the admin who made an adjustment
+	PerformedBy *string `json:"performed_by,omitempty"`
+	// ExchangeRate and ConvertedAmount are set on exchange legs: the rate used and
+	// what the target wallet received
+	ExchangeRate    *money.Rate   `json:"exchange_rate,omitempty" swaggertype:"string" example:"0.92"`
+	ConvertedAmount *money.Amount `json:"converted_amount,omitempty" swaggertype:"string" example:"92.00"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// TransactionDetail is a single transaction as shown on a receipt
+type TransactionDetail struct {
+	Transaction
+	// CounterpartyUsername is the username of the other user of a transfer
+	CounterpartyUsername *string `json:"counterparty_username,omitempty" example:"janedoe"`
+	// BalanceAfter is the wallet's balance right after a completed transaction
+	BalanceAfter *money.Amount `json:"balance_after,omitempty" swaggertype:"string" example:"75.00"`
+}
+
+// TransactionPage is a page of a wallet's transaction history
+type TransactionPage struct {
+	Items []Transaction `json:"items"`
+	// Total counts the transactions matching the history's filters across all pages
+	Total   int  `json:"total" example:"120"`
+	Limit   int  `json:"limit" example:"50"`
+	Offset  int  `json:"offset" example:"0"`
+	HasMore bool `json:"has_more" example:"true"`
+}
+
+// TransferResult describes a completed transfer from the sender's side
+type TransferResult struct {
+	TransferID uuid.UUID `json:"transfer_id"`
+	// OutTransactionID and InTransactionID are the TRANSFER_OUT and TRANSFER_IN legs
+	OutTransactionID uuid.UUID    `json:"out_transaction_id"`
+	InTransactionID  uuid.UUID    `json:"in_transaction_id"`
+	Amount           money.Amount `json:"amount" swaggertype:"string" example:"25.00"`
+	// Fee is what the sender was charged on top of Amount
+	Fee money.Amount `json:"fee" swaggertype:"string" example:"0.50"`
+	// BalanceAfter is the sending wallet's balance once the transfer was applied
+	BalanceAfter money.Amount `json:"balance_after" swaggertype:"string" example:"74.50"`
+}
+
+// BatchTransferResult describes a completed batch transfer. Every transaction it
+// recorded carries BatchID as its transfer ID.
+type BatchTransferResult struct {
+	BatchID uuid.UUID `json:"batch_id"`
+	// Items is the number of recipients paid
+	Items int          `json:"items" example:"3"`
+	Total money.Amount `json:"total" swaggertype:"string" example:"1500.00"`
+	// Fee is what the sender was charged on top of Total
+	Fee money.Amount `json:"fee" swaggertype:"string" example:"1.50"`
+}
+
+// ExchangeResult describes a completed currency exchange. Both of its transactions
+// carry ExchangeID as their transfer ID.
+type ExchangeResult struct {
+	ExchangeID      uuid.UUID    `json:"exchange_id"`
+	Amount          money.Amount `json:"amount" swaggertype:"string" example:"100.00"`
+	FromCurrency    string       `json:"from_currency" example:"USD"`
+	ConvertedAmount money.Amount `json:"converted_amount" swaggertype:"string" example:"92.00"`
+	ToCurrency      string       `json:"to_currency" example:"EUR"`
+	Rate            money.Rate   `json:"rate" swaggertype:"string" example:"0.92"`
+}
+
+// TransferReversal describes a transfer that was undone by support
+type TransferReversal struct {
+	TransferID uuid.UUID `json:"transfer_id"`
+	// Amount is what the recipient returned to the sender
+	Amount money.Amount `json:"amount" swaggertype:"string" example:"25.00"`
+	// Fee is the transfer fee refunded to the sender
+	Fee       money.Amount `json:"fee" swaggertype:"string" example:"0.50"`
+	Reason    string       `json:"reason"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type ReversalRequest struct {
+	Reason string `json:"reason" binding:"required" example:"sent to the wrong account by mistake"`
+}
+
+// BonusRequest grants a promotional credit on behalf of a campaign
+type BonusRequest struct {
+	Amount   money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"10.00"`
+	Campaign string       `json:"campaign" binding:"required" example:"spring-2025"`
+}
+
+type AdjustmentRequest struct {
+	// Amount is credited when positive and debited when negative
+	Amount money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"-12.50"`
+	Reason string       `json:"reason" binding:"required" example:"bank reconciliation for March"`
+	// Force allows a negative adjustment to take the balance below zero, as far as the
+	// wallet's overdraft limit
+	Force bool `json:"force"`
 }
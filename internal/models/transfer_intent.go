@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// TransferIntentStatus is where a transfer intent is in its lifecycle. An intent starts
+// PENDING and ends CONFIRMED or, once its expiry passes, EXPIRED.
+type TransferIntentStatus string
+
+const (
+	TransferIntentStatusPending   TransferIntentStatus = "PENDING"
+	TransferIntentStatusConfirmed TransferIntentStatus = "CONFIRMED"
+	TransferIntentStatusExpired   TransferIntentStatus = "EXPIRED"
+)
+
+// TransferIntent is a validated transfer waiting to be confirmed. Fee is the fee quoted
+// when it was created; TransferID is the transfer that executed it, once CONFIRMED.
+type TransferIntent struct {
+	ID         uuid.UUID            `json:"id"`
+	FromUserID uuid.UUID            `json:"from_user_id"`
+	ToUserID   uuid.UUID            `json:"to_user_id"`
+	Amount     money.Amount         `json:"amount" swaggertype:"string" example:"12.50"`
+	Fee        money.Amount         `json:"fee" swaggertype:"string" example:"0.50"`
+	Status     TransferIntentStatus `json:"status"`
+	TransferID *uuid.UUID           `json:"transfer_id,omitempty"`
+	ExpiresAt  time.Time            `json:"expires_at"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+type CreateTransferIntentRequest struct {
+	FromUserID string       `json:"from_user_id" binding:"required"`
+	ToUserID   string       `json:"to_user_id" binding:"required"`
+	Amount     money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"12.50"`
+}
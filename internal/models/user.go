@@ -1,20 +1,58 @@
 package models
 
 import (
+	"fmt"
 	"time"
+	"walletapp/internal/money"
 
 	"github.com/google/uuid"
 )
 
+// KycTier is how far a user's identity was verified. Each tier has its own limits on
+// the amounts the user may move.
+type KycTier int
+
+const (
+	KycTierUnverified KycTier = 0
+	KycTierBasic      KycTier = 1
+	KycTierFull       KycTier = 2
+)
+
+// Valid reports whether t is one of the defined tiers
+func (t KycTier) Valid() bool {
+	return t >= KycTierUnverified && t <= KycTierFull
+}
+
+func (t KycTier) String() string {
+	switch t {
+	case KycTierUnverified:
+		return "unverified"
+	case KycTierBasic:
+		return "basic"
+	case KycTierFull:
+		return "full"
+	}
+	return fmt.Sprintf("tier %d", int(t))
+}
+
+// User is a user account. Password holds the bcrypt hash of the user's password, and
+// only when the user was read with their credentials; it is never serialized.
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Password  string    `json:"-"`
+	KycTier   KycTier   `json:"kyc_tier"`
+	// EmailVerifiedAt is when the user verified their email, nil until they do
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// ReferralCode is the code the user shares to refer others
+	ReferralCode string    `json:"referral_code"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// DeletedAt is when the user was soft-deleted, nil while the account is live
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type CreateUserRequest struct {
@@ -23,15 +61,56 @@ type CreateUserRequest struct {
 	LastName  string `json:"last_name" binding:"required"`
 	Email     string `json:"email" binding:"required,email"`
 	Password  string `json:"password" binding:"required"`
+	// InitialBalance is credited to the new wallet as a deposit when non-zero
+	InitialBalance money.Amount `json:"initial_balance,omitempty" swaggertype:"string" example:"50.00"`
+	// ReferralCode is the code of the existing user who referred the new one, if any
+	ReferralCode string `json:"referral_code,omitempty" example:"3F9A1C07B2"`
+}
+
+// ChangePasswordRequest changes a user's password, proving it is the user by their
+// current one
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ResetPasswordRequest sets a user's password on an admin's authority
+type ResetPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// UpdateUserRequest changes some of a user's details. Fields left out keep their
+// current value.
+type UpdateUserRequest struct {
+	FirstName *string `json:"first_name,omitempty" example:"John"`
+	LastName  *string `json:"last_name,omitempty" example:"Doe"`
+	Email     *string `json:"email,omitempty" example:"johndoe@gmail.com"`
 }
 
 type UserResponse struct {
-	ID        uuid.UUID       `json:"id"`
-	Username  string          `json:"username"`
-	FirstName string          `json:"first_name"`
-	LastName  string          `json:"last_name"`
-	Email     string          `json:"email"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
-	Wallet    *WalletResponse `json:"wallet"`
+	ID              uuid.UUID       `json:"id"`
+	Username        string          `json:"username"`
+	FirstName       string          `json:"first_name"`
+	LastName        string          `json:"last_name"`
+	Email           string          `json:"email"`
+	KycTier         KycTier         `json:"kyc_tier"`
+	EmailVerifiedAt *time.Time      `json:"email_verified_at"`
+	ReferralCode    string          `json:"referral_code"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	Wallet          *WalletResponse `json:"wallet"`
+}
+
+// KycTierRequest sets the KYC tier of a user
+type KycTierRequest struct {
+	KycTier *KycTier `json:"kyc_tier" binding:"required" example:"1"`
+}
+
+// KycTierLimits are the limits on the deposits, withdrawals and outgoing transfers of
+// users in a KYC tier. A nil limit does not apply.
+type KycTierLimits struct {
+	Tier            KycTier       `json:"tier"`
+	MaxSingleAmount *money.Amount `json:"max_single_amount,omitempty" swaggertype:"string" example:"100.00"`
+	DailyLimit      *money.Amount `json:"daily_limit,omitempty" swaggertype:"string"`
+	MonthlyLimit    *money.Amount `json:"monthly_limit,omitempty" swaggertype:"string" example:"500.00"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUser_MarshalOmitsPassword(t *testing.T) {
+	user := User{
+		ID:        uuid.New(),
+		Username:  "jane",
+		Email:     "jane@example.com",
+		Password:  "$2a$10$abcdefghijklmnopqrstuv",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	raw, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal(raw, &fields))
+	assert.NotContains(t, fields, "password")
+	assert.NotContains(t, string(raw), user.Password)
+	assert.Equal(t, "jane", fields["username"])
+}
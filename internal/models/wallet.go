@@ -2,30 +2,125 @@ package models
 
 import (
 	"time"
+	"walletapp/internal/money"
 
 	"github.com/google/uuid"
 )
 
+// WalletStatus says whether a wallet may move money
+type WalletStatus string
+
+const (
+	WalletStatusActive WalletStatus = "ACTIVE"
+	// WalletStatusFrozen wallets keep their balance but cannot deposit, withdraw or transfer
+	WalletStatusFrozen WalletStatus = "FROZEN"
+	// WalletStatusClosed wallets were emptied and closed by their owner for good
+	WalletStatusClosed WalletStatus = "CLOSED"
+)
+
 type Wallet struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Balance   float64   `json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name" example:"savings"`
+	// IsDefault marks the wallet the user-scoped endpoints operate on; every user has one
+	IsDefault bool         `json:"is_default"`
+	Status    WalletStatus `json:"status" example:"ACTIVE"`
+	// Currency is the ISO 4217 code of the money the wallet holds
+	Currency       string       `json:"currency" example:"USD"`
+	Balance        money.Amount `json:"balance" swaggertype:"string" example:"100.00"`
+	OverdraftLimit money.Amount `json:"overdraft_limit" swaggertype:"string" example:"0.00"`
+	HeldAmount     money.Amount `json:"held_amount" swaggertype:"string" example:"20.00"`
+	// DailyWithdrawalLimit overrides the configured daily withdrawal limit when set;
+	// a limit of zero blocks withdrawals
+	DailyWithdrawalLimit *money.Amount `json:"daily_withdrawal_limit,omitempty" swaggertype:"string" example:"1000.00"`
+	// MonthlyTransferLimit overrides the configured monthly transfer limit when set;
+	// a limit of zero blocks outgoing transfers
+	MonthlyTransferLimit *money.Amount `json:"monthly_transfer_limit,omitempty" swaggertype:"string" example:"5000.00"`
+	// InterestRateBps is the yearly interest the wallet earns on its balance, in basis
+	// points; zero for wallets that earn none
+	InterestRateBps int `json:"interest_rate_bps" example:"250"`
+	// AvailableBalance is the balance minus active holds, set when the service reads a wallet
+	AvailableBalance money.Amount `json:"available_balance" swaggertype:"string" example:"80.00"`
+	Version          int64        `json:"-"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	ClosedAt         *time.Time   `json:"closed_at,omitempty"`
+	// DeletedAt is when the wallet was soft-deleted, nil while it is live
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type AmountRequest struct {
-	Amount float64 `json:"amount" binding:"required"`
+	// Amount is a pointer so that only a missing amount fails binding; zero and negative
+	// amounts are left for the service to reject with its own message
+	Amount         *money.Amount     `json:"amount" binding:"required" swaggertype:"string" example:"100.00"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty" binding:"max=255" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	Description    string            `json:"description,omitempty" example:"rent for May"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+}
+
+// CreateWalletRequest names a new wallet for a user. Without a currency the wallet
+// holds the same currency as the user's default wallet.
+type CreateWalletRequest struct {
+	Name     string `json:"name" binding:"required" example:"savings"`
+	Currency string `json:"currency,omitempty" example:"EUR"`
+}
+
+// OverdraftRequest sets how far below zero a wallet may go
+type OverdraftRequest struct {
+	Limit *money.Amount `json:"limit" binding:"required" swaggertype:"string" example:"50.00"`
+}
+
+// InterestRateRequest sets the yearly interest a wallet earns, in basis points (250 is
+// 2.50%)
+type InterestRateRequest struct {
+	RateBps *int `json:"interest_rate_bps" binding:"required,min=0,max=10000" example:"250"`
+}
+
+// ExternalDepositRequest is a deposit confirmed by a payment provider, identified by
+// the provider's reference for the payment
+type ExternalDepositRequest struct {
+	Amount    money.Amount `json:"amount" binding:"required" swaggertype:"string" example:"100.00"`
+	Reference string       `json:"reference" binding:"required,max=255" example:"ch_3PqR8s2eZvKYlo2C1a2b3c4d"`
+}
+
+// WalletStatusChange is the audit record of a wallet being frozen or unfrozen
+type WalletStatusChange struct {
+	ID          uuid.UUID    `json:"id"`
+	WalletID    uuid.UUID    `json:"wallet_id"`
+	Status      WalletStatus `json:"status" example:"FROZEN"`
+	Reason      string       `json:"reason" example:"suspected account takeover"`
+	PerformedBy string       `json:"performed_by" example:"admin-42"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// WalletStatusRequest is the reason given for freezing or unfreezing a wallet
+type WalletStatusRequest struct {
+	Reason string `json:"reason" binding:"required" example:"suspected account takeover"`
 }
 
 type WalletResponse struct {
-	ID        string    `json:"id"`
-	Balance   float64   `json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string       `json:"id"`
+	Currency  string       `json:"currency" example:"USD"`
+	Balance   money.Amount `json:"balance" swaggertype:"string" example:"100.00"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
 }
 
 type BalanceResponse struct {
-	UserID  string  `json:"user_id"`
-	Balance float64 `json:"balance"`
+	UserID           string       `json:"user_id"`
+	Currency         string       `json:"currency" example:"USD"`
+	Balance          money.Amount `json:"balance" swaggertype:"string" example:"100.00"`
+	AvailableBalance money.Amount `json:"available_balance" swaggertype:"string" example:"80.00"`
+}
+
+// BalanceChangeResponse is the outcome of a deposit or withdrawal: the amount applied,
+// the transaction recorded for it and the wallet's balance afterwards
+type BalanceChangeResponse struct {
+	WalletID         string       `json:"wallet_id"`
+	UserID           string       `json:"user_id"`
+	TransactionID    string       `json:"transaction_id"`
+	Currency         string       `json:"currency" example:"USD"`
+	Amount           money.Amount `json:"amount" swaggertype:"string" example:"20.00"`
+	Balance          money.Amount `json:"balance" swaggertype:"string" example:"100.00"`
+	AvailableBalance money.Amount `json:"available_balance" swaggertype:"string" example:"80.00"`
 }
@@ -0,0 +1,67 @@
+package money
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnsupportedCurrency is returned for currency codes a wallet cannot hold
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// minorUnits is the number of decimal places of each supported ISO 4217 currency.
+// Amounts are counted in hundredths, so currencies with three decimals such as BHD
+// cannot be represented and are left out.
+var minorUnits = map[string]int{
+	"AUD": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"IDR": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"MYR": 2,
+	"NZD": 2,
+	"PHP": 2,
+	"SGD": 2,
+	"THB": 2,
+	"USD": 2,
+	"VND": 0,
+}
+
+// ParseCurrency upper-cases an ISO 4217 code such as "eur" and checks that it is
+// supported
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := minorUnits[code]; !ok {
+		return "", ErrUnsupportedCurrency
+	}
+	return code, nil
+}
+
+// MinorUnits returns how many decimal places amounts in currency may have
+func MinorUnits(currency string) (int, bool) {
+	units, ok := minorUnits[currency]
+	return units, ok
+}
+
+// FitsCurrency reports whether a has no more decimal places than currency allows,
+// e.g. yen amounts must be whole. Unknown currencies allow the usual two decimals.
+func (a Amount) FitsCurrency(currency string) bool {
+	return int64(a)%minorUnitStep(currency) == 0
+}
+
+// minorUnitStep is the number of hundredths in the smallest unit of currency, 100
+// for yen. Unknown currencies count in hundredths.
+func minorUnitStep(currency string) int64 {
+	step := int64(1)
+	if units, ok := minorUnits[currency]; ok {
+		for i := units; i < 2; i++ {
+			step *= 10
+		}
+	}
+	return step
+}
@@ -0,0 +1,57 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"USD", "USD", false},
+		{"eur", "EUR", false},
+		{" jpy ", "JPY", false},
+		{"BHD", "", true},
+		{"XXX", "", true},
+		{"US", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCurrency(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAmount_FitsCurrency(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		expected bool
+	}{
+		{"10.55", "USD", true},
+		{"10.00", "JPY", true},
+		{"1500", "JPY", true},
+		{"10.50", "JPY", false},
+		{"0.01", "KRW", false},
+		{"-3.00", "JPY", true},
+		{"10.55", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MustParse(tt.amount).FitsCurrency(tt.currency))
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package money
+
+import "math/big"
+
+// Interest accrues daily at a yearly rate given in basis points, spread evenly over a
+// 365 day year, leap years included
+const (
+	basisPointsPerUnit = 10000
+	daysPerYear        = 365
+)
+
+// DailyInterest returns one day of interest on a at a yearly rate of rateBps basis
+// points, rounding half away from zero to currency's minor units, so a day of 2.50%
+// on 10000.00 USD is 0.68
+func (a Amount) DailyInterest(rateBps int, currency string) Amount {
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(rateBps)))
+	return roundQuo(product, basisPointsPerUnit*daysPerYear, currency)
+}
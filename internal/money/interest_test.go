@@ -0,0 +1,33 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmount_DailyInterest(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		rateBps  int
+		currency string
+		expected string
+	}{
+		{"rounds down", "10000.00", 250, "USD", "0.68"},        // 0.6849...
+		{"rounds half up", "73.00", 2500, "USD", "0.05"},       // exactly 0.05
+		{"half a cent rounds up", "7.30", 2500, "USD", "0.01"}, // exactly 0.005
+		{"under half a cent", "7.29", 2500, "USD", "0.00"},     // 0.004993...
+		{"zero rate", "10000.00", 0, "USD", "0.00"},
+		{"whole yen", "1000000", 300, "JPY", "82"}, // 82.19...
+		{"unknown currency counts in cents", "10000.00", 250, "XYZ", "0.68"},
+		{"large balance does not overflow", "10000000000000000.00", 10000, "USD", "27397260273972.60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MustParse(tt.amount).DailyInterest(tt.rateBps, tt.currency)
+			assert.Equal(t, MustParse(tt.expected), got)
+		})
+	}
+}
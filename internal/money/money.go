@@ -0,0 +1,140 @@
+package money
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary value stored in minor units (cents), so that
+// arithmetic on balances never suffers from binary floating point drift.
+type Amount int64
+
+// centsPerUnit is the number of minor units in one major unit
+const centsPerUnit = 100
+
+var (
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrTooManyDecimals = errors.New("amount cannot have more than 2 decimal places")
+)
+
+// FromCents creates an Amount from a number of minor units
+func FromCents(cents int64) Amount {
+	return Amount(cents)
+}
+
+// Cents returns the amount in minor units
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Parse converts a decimal string such as "12.34" into an Amount.
+// Values that are not a whole number of cents are rejected with ErrTooManyDecimals.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" && (!hasFrac || frac == "") {
+		return 0, ErrInvalidAmount
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+	// Trailing zeros don't change the value, so "10.100" is still whole cents
+	if len(frac) > 2 {
+		if strings.TrimRight(frac[2:], "0") != "" {
+			return 0, ErrTooManyDecimals
+		}
+		frac = frac[:2]
+	}
+
+	var units int64
+	if whole != "" {
+		var err error
+		units, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	frac += strings.Repeat("0", 2-len(frac))
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+
+	if units > (1<<63-1-cents)/centsPerUnit {
+		return 0, ErrInvalidAmount
+	}
+
+	total := units*centsPerUnit + cents
+	if negative {
+		total = -total
+	}
+	return Amount(total), nil
+}
+
+// MustParse is like Parse but panics on invalid input. Intended for constants and tests.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic("money: cannot parse " + strconv.Quote(s))
+	}
+	return a
+}
+
+// String formats the amount with exactly two decimal places, e.g. "12.34"
+func (a Amount) String() string {
+	cents := int64(a)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	frac := strconv.FormatInt(cents%centsPerUnit, 10)
+	if len(frac) < 2 {
+		frac = "0" + frac
+	}
+	return sign + strconv.FormatInt(cents/centsPerUnit, 10) + "." + frac
+}
+
+// MarshalJSON encodes the amount as a two-decimal string, e.g. "12.34"
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// UnmarshalJSON accepts either a decimal string ("12.34") or a JSON number (12.34).
+// Numbers are parsed from their literal text so no float conversion takes place.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+		wantErr  bool
+	}{
+		{"0", 0, false},
+		{"0.01", 1, false},
+		{"10", 1000, false},
+		{"10.5", 1050, false},
+		{"10.50", 1050, false},
+		{".75", 75, false},
+		{"-3.20", -320, false},
+		{"1000000.00", 100000000, false},
+		{"10.100", 1010, false},
+		{"10.005", 0, true},
+		{"0.0001", 0, true},
+		{"", 0, true},
+		{".", 0, true},
+		{"abc", 0, true},
+		{"1e3", 0, true},
+		{"1.2.3", 0, true},
+		{"99999999999999999999", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParse_TooManyDecimals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+		wantErr  error
+	}{
+		{"10.001", 0, ErrTooManyDecimals},
+		{"0.015", 0, ErrTooManyDecimals},
+		{"99.999", 0, ErrTooManyDecimals},
+		{"10.10", 1010, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.expected, got)
+
+			// JSON numbers go through the same check
+			var fromJSON Amount
+			err = json.Unmarshal([]byte(tt.input), &fromJSON)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.expected, fromJSON)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0.00", Amount(0).String())
+	assert.Equal(t, "0.01", Amount(1).String())
+	assert.Equal(t, "12.34", Amount(1234).String())
+	assert.Equal(t, "-0.50", Amount(-50).String())
+}
+
+func TestJSON(t *testing.T) {
+	var req struct {
+		Amount Amount `json:"amount"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 12.34}`), &req))
+	assert.Equal(t, Amount(1234), req.Amount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "0.10"}`), &req))
+	assert.Equal(t, Amount(10), req.Amount)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.001}`), &req))
+
+	out, err := json.Marshal(req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": "0.10"}`, string(out))
+}
+
+func TestRepeatedCentsDoNotDrift(t *testing.T) {
+	var total Amount
+	for i := 0; i < 10000; i++ {
+		total += MustParse("0.01")
+	}
+	assert.Equal(t, "100.00", total.String())
+}
@@ -0,0 +1,148 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Rate is an exchange rate, the number of target currency units one source currency
+// unit buys, stored in hundred-millionths so that rates such as "151.23456789" are exact
+type Rate int64
+
+// rateDecimals is the number of decimal places a Rate keeps
+const rateDecimals = 8
+
+// rateScale is the number of Rate units in a rate of one
+const rateScale = 100000000
+
+// ErrInvalidRate is returned for exchange rates that are not positive or have more
+// than 8 decimal places
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// ParseRate converts a positive decimal string such as "0.92" into a Rate
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidRate
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > rateDecimals {
+		return 0, ErrInvalidRate
+	}
+	frac += strings.Repeat("0", rateDecimals-len(frac))
+
+	var units int64
+	if whole != "" {
+		var err error
+		units, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || units > (1<<63-1)/rateScale-1 {
+			return 0, ErrInvalidRate
+		}
+	}
+	fraction, _ := strconv.ParseInt(frac, 10, 64)
+	r := Rate(units*rateScale + fraction)
+	if r <= 0 {
+		return 0, ErrInvalidRate
+	}
+	return r, nil
+}
+
+// String formats the rate without trailing zeros, e.g. "0.92" or "151.2"
+func (r Rate) String() string {
+	frac := strconv.FormatInt(int64(r)%rateScale, 10)
+	frac = strings.Repeat("0", rateDecimals-len(frac)) + frac
+	frac = strings.TrimRight(frac, "0")
+	whole := strconv.FormatInt(int64(r)/rateScale, 10)
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// MarshalJSON encodes the rate as a decimal string, e.g. "0.92"
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON accepts either a decimal string ("0.92") or a JSON number (0.92)
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Convert exchanges a at rate r into currency, rounding half away from zero to the
+// currency's minor units, so 10.00 USD at 151.237 is 1512 JPY
+func (a Amount) Convert(r Rate, currency string) Amount {
+	// a is in hundredths and r in hundred-millionths, so a*r/rateScale is hundredths
+	// of the target currency
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(r)))
+	return roundQuo(product, rateScale, currency)
+}
+
+// roundQuo divides product, in hundredths, by divisor and rounds the result half away
+// from zero to currency's minor units
+func roundQuo(product *big.Int, divisor int64, currency string) Amount {
+	// step is how many hundredths the currency's smallest unit is
+	step := minorUnitStep(currency)
+	d := big.NewInt(divisor * step)
+	quotient, remainder := new(big.Int).QuoRem(product, d, new(big.Int))
+	if twice := new(big.Int).Abs(remainder); twice.Lsh(twice, 1).Cmp(d) >= 0 {
+		if product.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Amount(quotient.Int64() * step)
+}
+
+// ScanNumeric implements pgtype.NumericScanner
+func (r *Rate) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		return errors.New("money: cannot scan NULL into Rate")
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("money: cannot scan non-finite numeric into Rate: %w", ErrInvalidRate)
+	}
+
+	// The value is Int * 10^Exp, so the rate in hundred-millionths is Int * 10^(Exp+8)
+	units := new(big.Int).Set(n.Int)
+	scale := int64(n.Exp) + rateDecimals
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(abs(scale)), nil)
+	if scale >= 0 {
+		units.Mul(units, pow)
+	} else {
+		var rem big.Int
+		units.QuoRem(units, pow, &rem)
+		if rem.Sign() != 0 {
+			return ErrInvalidRate
+		}
+	}
+	if !units.IsInt64() {
+		return fmt.Errorf("money: numeric out of range: %w", ErrInvalidRate)
+	}
+	*r = Rate(units.Int64())
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (r Rate) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(r)), Exp: -rateDecimals, Valid: true}, nil
+}
@@ -0,0 +1,98 @@
+package money
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Rate
+		wantErr  bool
+	}{
+		{"1", 100000000, false},
+		{"0.92", 92000000, false},
+		{"151.23456789", 15123456789, false},
+		{"1.500000000", 150000000, false},
+		{".5", 50000000, false},
+		{"0", 0, true},
+		{"0.000000001", 0, true},
+		{"-1.08", 0, true},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"99999999999999", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRate)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRate_StringAndJSON(t *testing.T) {
+	assert.Equal(t, "0.92", Rate(92000000).String())
+	assert.Equal(t, "151.2", Rate(15120000000).String())
+	assert.Equal(t, "2", Rate(200000000).String())
+	assert.Equal(t, "0.00000001", Rate(1).String())
+
+	data, err := json.Marshal(Rate(108000000))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1.08"`, string(data))
+
+	var r Rate
+	assert.NoError(t, json.Unmarshal([]byte(`1.08`), &r))
+	assert.Equal(t, Rate(108000000), r)
+}
+
+func TestAmount_Convert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		rate     string
+		currency string
+		expected string
+	}{
+		{"exact", "100.00", "0.92", "EUR", "92.00"},
+		{"rounds down to cents", "10.00", "0.923456", "EUR", "9.23"},
+		{"below half rounds down", "0.10", "0.925", "EUR", "0.09"},
+		{"rounds half away from zero", "1.00", "0.925", "EUR", "0.93"},
+		{"rounds down to whole yen", "10.00", "151.237", "JPY", "1512.00"},
+		{"rounds up to whole yen", "10.05", "151.237", "JPY", "1520.00"},
+		{"into cents from yen", "1500", "0.0066", "USD", "9.90"},
+		{"too small to convert", "0.01", "0.0066", "USD", "0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := ParseRate(tt.rate)
+			assert.NoError(t, err)
+			assert.Equal(t, MustParse(tt.expected), MustParse(tt.amount).Convert(rate, tt.currency))
+		})
+	}
+}
+
+func TestRate_NumericRoundTrip(t *testing.T) {
+	rate := Rate(15123456789)
+	n, err := rate.NumericValue()
+	assert.NoError(t, err)
+
+	var scanned Rate
+	assert.NoError(t, scanned.ScanNumeric(n))
+	assert.Equal(t, rate, scanned)
+
+	assert.NoError(t, scanned.ScanNumeric(pgtype.Numeric{Int: big.NewInt(92), Exp: -2, Valid: true}))
+	assert.Equal(t, Rate(92000000), scanned)
+	assert.ErrorIs(t, scanned.ScanNumeric(pgtype.Numeric{Int: big.NewInt(1), Exp: -9, Valid: true}), ErrInvalidRate)
+}
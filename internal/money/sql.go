@@ -0,0 +1,92 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Amounts are stored in NUMERIC(18,2) columns. The pgx interfaces below move them
+// through pgtype.Numeric and the database/sql ones through their decimal text, so
+// no value ever passes through a float.
+
+var bigCentsPerUnit = big.NewInt(centsPerUnit)
+
+// ScanNumeric implements pgtype.NumericScanner
+func (a *Amount) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		return errors.New("money: cannot scan NULL into Amount")
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("money: cannot scan non-finite numeric into Amount: %w", ErrInvalidAmount)
+	}
+
+	// The value is Int * 10^Exp, so the number of cents is Int * 10^(Exp+2)
+	cents := new(big.Int).Set(n.Int)
+	scale := int64(n.Exp) + 2
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(abs(scale)), nil)
+	if scale >= 0 {
+		cents.Mul(cents, pow)
+	} else {
+		var rem big.Int
+		cents.QuoRem(cents, pow, &rem)
+		if rem.Sign() != 0 {
+			return ErrTooManyDecimals
+		}
+	}
+	if !cents.IsInt64() {
+		return fmt.Errorf("money: numeric out of range: %w", ErrInvalidAmount)
+	}
+	*a = Amount(cents.Int64())
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -2, Valid: true}, nil
+}
+
+// Scan implements sql.Scanner. NUMERIC columns arrive as decimal text, integers are
+// taken as whole units.
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return a.scanText(v)
+	case []byte:
+		return a.scanText(string(v))
+	case int64:
+		if v > (1<<63-1)/centsPerUnit || v < -(1<<63-1)/centsPerUnit {
+			return fmt.Errorf("money: integer out of range: %w", ErrInvalidAmount)
+		}
+		*a = Amount(v * centsPerUnit)
+		return nil
+	case nil:
+		return errors.New("money: cannot scan NULL into Amount")
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+}
+
+// Value implements driver.Valuer by sending the amount as decimal text
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *Amount) scanText(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
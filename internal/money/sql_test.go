@@ -0,0 +1,73 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanNumeric(t *testing.T) {
+	tests := []struct {
+		name     string
+		numeric  pgtype.Numeric
+		expected Amount
+		wantErr  error
+	}{
+		{"two decimals", pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Valid: true}, 12345, nil},
+		{"whole units", pgtype.Numeric{Int: big.NewInt(7), Exp: 0, Valid: true}, 700, nil},
+		{"positive exponent", pgtype.Numeric{Int: big.NewInt(5), Exp: 3, Valid: true}, 500000, nil},
+		{"trailing zeros", pgtype.Numeric{Int: big.NewInt(1010), Exp: -3, Valid: true}, 101, nil},
+		{"negative", pgtype.Numeric{Int: big.NewInt(-320), Exp: -2, Valid: true}, -320, nil},
+		{"sub-cent", pgtype.Numeric{Int: big.NewInt(10005), Exp: -3, Valid: true}, 0, ErrTooManyDecimals},
+		{"NaN", pgtype.Numeric{NaN: true, Valid: true}, 0, ErrInvalidAmount},
+		{"out of range", pgtype.Numeric{Int: big.NewInt(1), Exp: 30, Valid: true}, 0, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Amount
+			err := a.ScanNumeric(tt.numeric)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, a)
+		})
+	}
+
+	var a Amount
+	assert.Error(t, a.ScanNumeric(pgtype.Numeric{}))
+}
+
+func TestNumericValueRoundTrip(t *testing.T) {
+	for _, a := range []Amount{0, 1, 12345, -320, MustParse("1000000.00")} {
+		n, err := a.NumericValue()
+		assert.NoError(t, err)
+
+		var back Amount
+		assert.NoError(t, back.ScanNumeric(n))
+		assert.Equal(t, a, back)
+	}
+}
+
+func TestSQLScanAndValue(t *testing.T) {
+	var a Amount
+	assert.NoError(t, a.Scan([]byte("100.00")))
+	assert.Equal(t, Amount(10000), a)
+
+	assert.NoError(t, a.Scan("0.05"))
+	assert.Equal(t, Amount(5), a)
+
+	assert.NoError(t, a.Scan(int64(3)))
+	assert.Equal(t, Amount(300), a)
+
+	assert.Error(t, a.Scan(nil))
+	assert.Error(t, a.Scan(1.5))
+
+	v, err := MustParse("12.30").Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "12.30", v)
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrArchiveRunNotFound is returned when the archive job has never run
+var ErrArchiveRunNotFound = errors.New("archive run not found")
+
+// allTransactions reads transactions together with transactions_archive, for history
+// that reaches back past the retention and for reports over a wallet's whole life.
+// Conditions on it are pushed down into both tables.
+const allTransactions = `(
+            SELECT ` + transactionColumns + ` FROM transactions
+            UNION ALL
+            SELECT ` + transactionColumns + ` FROM transactions_archive
+        )`
+
+const archiveRunColumns = `id, cutoff, status, archived, error, started_at, finished_at`
+
+// CreateArchiveRun records the start of an archive run moving the transactions created
+// before cutoff
+func (r *PgTransactionRepository) CreateArchiveRun(ctx context.Context, cutoff time.Time) (*models.ArchiveRun, error) {
+	return scanArchiveRun(r.q.QueryRow(ctx, `
+        INSERT INTO transaction_archive_runs (cutoff)
+        VALUES ($1)
+        RETURNING `+archiveRunColumns, cutoff))
+}
+
+// GetLatestArchiveRun returns the archive run started last, or ErrArchiveRunNotFound
+func (r *PgTransactionRepository) GetLatestArchiveRun(ctx context.Context) (*models.ArchiveRun, error) {
+	run, err := scanArchiveRun(r.q.QueryRow(ctx, `
+        SELECT `+archiveRunColumns+`
+        FROM transaction_archive_runs
+        ORDER BY started_at DESC
+        LIMIT 1
+    `))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrArchiveRunNotFound
+	}
+	return run, err
+}
+
+// FinishArchiveRun marks an archive run COMPLETED, or FAILED with runErr when it is set
+func (r *PgTransactionRepository) FinishArchiveRun(ctx context.Context, id string, runErr error) (*models.ArchiveRun, error) {
+	status, message := models.ArchiveRunCompleted, (*string)(nil)
+	if runErr != nil {
+		text := runErr.Error()
+		status, message = models.ArchiveRunFailed, &text
+	}
+	run, err := scanArchiveRun(r.q.QueryRow(ctx, `
+        UPDATE transaction_archive_runs
+        SET status = $2, error = $3, finished_at = NOW()
+        WHERE id = $1
+        RETURNING `+archiveRunColumns, id, status, message))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveRunNotFound, id)
+	}
+	return run, err
+}
+
+// CountArchivableTransactions counts the transactions created before cutoff that are
+// still to be archived. Pending transactions are never archived.
+func (r *PgTransactionRepository) CountArchivableTransactions(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.q.QueryRow(ctx, `
+        SELECT COUNT(*)
+        FROM transactions
+        WHERE created_at < $1::timestamptz AND status <> 'PENDING'
+    `, cutoff).Scan(&count)
+	return count, err
+}
+
+// ArchiveTransactionsTx moves up to limit of the oldest transactions created before
+// cutoff into transactions_archive and adds them to the archived count of run runID,
+// within a transaction. The rows are deleted and inserted by one statement, so a batch
+// is archived whole or not at all, and rows locked by a concurrent batch are skipped
+// rather than moved twice. Pending transactions stay, as they are still to be
+// finalized. Returns how many transactions were moved.
+func ArchiveTransactionsTx(ctx context.Context, tx pgx.Tx, runID string, cutoff time.Time, limit int) (int64, error) {
+	var moved int64
+	err := tx.QueryRow(ctx, `
+        WITH batch AS (
+            SELECT id, created_at
+            FROM transactions
+            WHERE created_at < $1::timestamptz AND status <> 'PENDING'
+            ORDER BY created_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        ), moved AS (
+            DELETE FROM transactions t
+            USING batch
+            WHERE t.id = batch.id AND t.created_at = batch.created_at
+            RETURNING `+prefixColumns("t.", transactionColumns)+`
+        ), archived AS (
+            INSERT INTO transactions_archive (`+transactionColumns+`)
+            SELECT `+transactionColumns+` FROM moved
+            RETURNING id
+        )
+        SELECT COUNT(*) FROM archived
+    `, cutoff, limit).Scan(&moved)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `UPDATE transaction_archive_runs SET archived = archived + $2 WHERE id = $1`, runID, moved)
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// prefixColumns qualifies each column of a comma-separated list with prefix
+func prefixColumns(prefix, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, column := range parts {
+		parts[i] = prefix + strings.TrimSpace(column)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func scanArchiveRun(row pgx.Row) (*models.ArchiveRun, error) {
+	var run models.ArchiveRun
+	err := row.Scan(&run.ID, &run.Cutoff, &run.Status, &run.Archived, &run.Error, &run.StartedAt, &run.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
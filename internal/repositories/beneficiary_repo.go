@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Beneficiary lookup and creation failures
+var (
+	ErrBeneficiaryNotFound = errors.New("beneficiary not found")
+	ErrBeneficiaryExists   = errors.New("user is already a beneficiary")
+)
+
+// beneficiaryOwnerIndex is the unique index on beneficiaries (owner_user_id, beneficiary_user_id)
+const beneficiaryOwnerIndex = "idx_beneficiaries_owner_beneficiary"
+
+const beneficiaryColumns = `id, owner_user_id, beneficiary_user_id, nickname, created_at`
+
+// CreateBeneficiary saves a beneficiary. Returns ErrBeneficiaryExists when the owner
+// already saved the user, and ErrUserNotFound when either user does not exist.
+func CreateBeneficiary(ctx context.Context, b *models.Beneficiary) error {
+	err := pool().QueryRow(ctx, `
+        INSERT INTO beneficiaries (owner_user_id, beneficiary_user_id, nickname, created_at)
+        VALUES ($1, $2, $3, NOW())
+        RETURNING id, created_at
+    `, b.OwnerUserID, b.BeneficiaryUserID, b.Nickname).Scan(&b.ID, &b.CreatedAt)
+	var pgErr *pgconn.PgError
+	// 23505 is unique_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == beneficiaryOwnerIndex {
+		return fmt.Errorf("%w: %s", ErrBeneficiaryExists, b.BeneficiaryUserID)
+	}
+	return translateUserForeignKeyError(err)
+}
+
+// GetBeneficiariesByOwnerID returns a user's beneficiaries in the order they were saved
+func GetBeneficiariesByOwnerID(ctx context.Context, ownerID string) ([]models.Beneficiary, error) {
+	rows, err := readPool().Query(ctx, `
+        SELECT `+beneficiaryColumns+`
+        FROM beneficiaries
+        WHERE owner_user_id = $1
+        ORDER BY created_at
+    `, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var beneficiaries []models.Beneficiary
+	for rows.Next() {
+		b, err := scanBeneficiary(rows)
+		if err != nil {
+			return nil, err
+		}
+		beneficiaries = append(beneficiaries, *b)
+	}
+	return beneficiaries, rows.Err()
+}
+
+// GetBeneficiary returns one of ownerID's beneficiaries. A beneficiary of another user
+// is reported as ErrBeneficiaryNotFound.
+func GetBeneficiary(ctx context.Context, ownerID, id string) (*models.Beneficiary, error) {
+	b, err := scanBeneficiary(pool().QueryRow(ctx, `
+        SELECT `+beneficiaryColumns+`
+        FROM beneficiaries
+        WHERE id = $1 AND owner_user_id = $2
+    `, id, ownerID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrBeneficiaryNotFound, id)
+	}
+	return b, err
+}
+
+// DeleteBeneficiary removes one of ownerID's beneficiaries
+func DeleteBeneficiary(ctx context.Context, ownerID, id string) error {
+	tag, err := pool().Exec(ctx, `DELETE FROM beneficiaries WHERE id = $1 AND owner_user_id = $2`, id, ownerID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrBeneficiaryNotFound, id)
+	}
+	return nil
+}
+
+func scanBeneficiary(row pgx.Row) (*models.Beneficiary, error) {
+	var b models.Beneficiary
+	if err := row.Scan(&b.ID, &b.OwnerUserID, &b.BeneficiaryUserID, &b.Nickname, &b.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
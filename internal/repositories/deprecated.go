@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"walletapp/internal/db"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+)
+
+// Deprecated: use UserRepository.GetAllUsers.
+func GetAllUsers(ctx context.Context) ([]models.User, error) {
+	return defaultUserRepository().GetAllUsers(ctx)
+}
+
+// Deprecated: use UserRepository.GetUserByID.
+func GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	return defaultUserRepository().GetUserByID(ctx, id)
+}
+
+// Deprecated: use UserRepository.CreateUser.
+func CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	return defaultUserRepository().CreateUser(ctx, req)
+}
+
+// Deprecated: use UserRepository.SetUserKycTier.
+func SetUserKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error) {
+	return defaultUserRepository().SetUserKycTier(ctx, userID, tier)
+}
+
+// Deprecated: use WalletRepository.GetWalletByUserID.
+func GetWalletByUserID(ctx context.Context, userID string) (*models.Wallet, error) {
+	return defaultWalletRepository().GetWalletByUserID(ctx, userID)
+}
+
+// Deprecated: use WalletRepository.GetWalletByID.
+func GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error) {
+	return defaultWalletRepository().GetWalletByID(ctx, walletID)
+}
+
+// Deprecated: use WalletRepository.GetWalletsByUserID.
+func GetWalletsByUserID(ctx context.Context, userID string) ([]models.Wallet, error) {
+	return defaultWalletRepository().GetWalletsByUserID(ctx, userID)
+}
+
+// Deprecated: use WalletRepository.GetWalletsByUserIDs.
+func GetWalletsByUserIDs(ctx context.Context, userIDs []string) (map[string]*models.Wallet, error) {
+	return defaultWalletRepository().GetWalletsByUserIDs(ctx, userIDs)
+}
+
+// Deprecated: use WalletRepository.CreateWallet.
+func CreateWallet(ctx context.Context, userID string) (*models.Wallet, error) {
+	return defaultWalletRepository().CreateWallet(ctx, userID)
+}
+
+// Deprecated: use WalletRepository.CreateNamedWallet.
+func CreateNamedWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error) {
+	return defaultWalletRepository().CreateNamedWallet(ctx, userID, name, currency)
+}
+
+// Deprecated: use WalletRepository.SetOverdraftLimit.
+func SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error) {
+	return defaultWalletRepository().SetOverdraftLimit(ctx, userID, limit)
+}
+
+// Deprecated: use WalletRepository.SetInterestRate.
+func SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error) {
+	return defaultWalletRepository().SetInterestRate(ctx, userID, walletID, rateBps)
+}
+
+// Deprecated: use WalletRepository.GetInterestBearingWallets.
+func GetInterestBearingWallets(ctx context.Context) ([]models.Wallet, error) {
+	return defaultWalletRepository().GetInterestBearingWallets(ctx)
+}
+
+// Deprecated: use TransactionRepository.GetTransactionByID.
+func GetTransactionByID(ctx context.Context, id string) (*models.Transaction, error) {
+	return defaultTransactionRepository().GetTransactionByID(ctx, id)
+}
+
+// Deprecated: use TransactionRepository.GetBalanceAfterTransaction.
+func GetBalanceAfterTransaction(ctx context.Context, t *models.Transaction) (money.Amount, error) {
+	return defaultTransactionRepository().GetBalanceAfterTransaction(ctx, t)
+}
+
+// Deprecated: use TransactionRepository.GetTransactionsByWalletID.
+func GetTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter, sort TransactionSort, limit, offset int) ([]models.Transaction, int, error) {
+	return defaultTransactionRepository().GetTransactionsByWalletID(ctx, walletID, filter, sort, limit, offset)
+}
+
+// Deprecated: use TransactionRepository.GetTransactionsAfterCursor.
+func GetTransactionsAfterCursor(ctx context.Context, walletID string, filter TransactionFilter, cursorTime time.Time, cursorID string, limit int) ([]models.Transaction, int, error) {
+	return defaultTransactionRepository().GetTransactionsAfterCursor(ctx, walletID, filter, cursorTime, cursorID, limit)
+}
+
+// Deprecated: use TransactionRepository.CountTransactionsByWalletID.
+func CountTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter) (int, error) {
+	return defaultTransactionRepository().CountTransactionsByWalletID(ctx, walletID, filter)
+}
+
+// Deprecated: use TransactionRepository.GetTransactionsByTransferID.
+func GetTransactionsByTransferID(ctx context.Context, transferID string) ([]models.Transaction, error) {
+	return defaultTransactionRepository().GetTransactionsByTransferID(ctx, transferID)
+}
+
+// Deprecated: use TransactionRepository.GetTransactionSummary.
+func GetTransactionSummary(ctx context.Context, walletID string, from, to time.Time) (*models.WalletSummary, error) {
+	return defaultTransactionRepository().GetTransactionSummary(ctx, walletID, from, to)
+}
+
+// Deprecated: use TransactionRepository.GetBalanceDiscrepancies.
+func GetBalanceDiscrepancies(ctx context.Context) ([]models.Discrepancy, error) {
+	return defaultTransactionRepository().GetBalanceDiscrepancies(ctx)
+}
+
+// defaultUserRepository is the UserRepository on the pools the deprecated functions use
+func defaultUserRepository() *PgUserRepository {
+	return NewUserRepositoryWithReplica(db.GetPool(), db.GetReadPool())
+}
+
+// defaultWalletRepository is the WalletRepository on the pools the deprecated functions
+// use
+func defaultWalletRepository() *PgWalletRepository {
+	return NewWalletRepositoryWithReplica(db.GetPool(), db.GetReadPool())
+}
+
+// defaultTransactionRepository is the TransactionRepository on the pools the deprecated
+// functions use
+func defaultTransactionRepository() *PgTransactionRepository {
+	return NewTransactionRepositoryWithReplica(db.GetPool(), db.GetReadPool())
+}
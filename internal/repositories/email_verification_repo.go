@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrEmailVerificationTokenNotFound is returned when no email verification token has
+// the hash
+var ErrEmailVerificationTokenNotFound = errors.New("email verification token not found")
+
+// PgEmailVerificationRepository stores the tokens emailed to users to verify their
+// email, in Postgres
+type PgEmailVerificationRepository struct {
+	q Querier
+}
+
+// NewEmailVerificationRepository creates a PgEmailVerificationRepository running its
+// queries on q, usually a transaction, each under the query timeout
+func NewEmailVerificationRepository(q Querier) *PgEmailVerificationRepository {
+	return &PgEmailVerificationRepository{q: bound(q)}
+}
+
+// CreateEmailVerificationToken records an email verification token. Returns
+// ErrUserNotFound when the user does not exist.
+func (r *PgEmailVerificationRepository) CreateEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error {
+	err := r.q.QueryRow(ctx, `
+        INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        RETURNING id, created_at
+    `, token.UserID, token.Email, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// GetEmailVerificationTokenForUpdate returns the email verification token with
+// tokenHash and, run in a transaction, row-locks it for the rest of it, so it is used
+// only once
+func (r *PgEmailVerificationRepository) GetEmailVerificationTokenForUpdate(ctx context.Context, tokenHash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.q.QueryRow(ctx, `
+        SELECT id, user_id, email, token_hash, expires_at, used_at, created_at
+        FROM email_verification_tokens
+        WHERE token_hash = $1
+        FOR UPDATE
+    `, tokenHash).Scan(&token.ID, &token.UserID, &token.Email, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEmailVerificationTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UseEmailVerificationTokens marks every unused email verification token of a user
+// used, so none of the tokens they were sent works once one of them verified the email
+func (r *PgEmailVerificationRepository) UseEmailVerificationTokens(ctx context.Context, userID string) error {
+	var used int
+	return r.q.QueryRow(ctx, `
+        WITH used AS (
+            UPDATE email_verification_tokens SET used_at = NOW()
+            WHERE user_id = $1 AND used_at IS NULL
+            RETURNING 1
+        )
+        SELECT COUNT(*) FROM used
+    `, userID).Scan(&used)
+}
+
+// CountEmailVerificationTokensSince counts the email verification tokens a user was
+// sent since a time, and returns when the oldest of them was, or nil when there are none
+func (r *PgEmailVerificationRepository) CountEmailVerificationTokensSince(ctx context.Context, userID string, since time.Time) (int, *time.Time, error) {
+	var count int
+	var oldest *time.Time
+	err := r.q.QueryRow(ctx, `
+        SELECT COUNT(*), MIN(created_at)
+        FROM email_verification_tokens
+        WHERE user_id = $1 AND created_at > $2
+    `, userID, since).Scan(&count, &oldest)
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, oldest, nil
+}
+
+// CreateEmailVerificationTokenTx records an email verification token within a
+// transaction, so a user created in it is sent one
+func CreateEmailVerificationTokenTx(ctx context.Context, tx pgx.Tx, token *models.EmailVerificationToken) error {
+	return NewEmailVerificationRepository(tx).CreateEmailVerificationToken(ctx, token)
+}
+
+// GetEmailVerificationTokenForUpdateTx returns the email verification token with
+// tokenHash and row-locks it for the rest of the transaction
+func GetEmailVerificationTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.EmailVerificationToken, error) {
+	return NewEmailVerificationRepository(tx).GetEmailVerificationTokenForUpdate(ctx, tokenHash)
+}
+
+// UseEmailVerificationTokensTx marks every unused email verification token of a user
+// used within a transaction
+func UseEmailVerificationTokensTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	return NewEmailVerificationRepository(tx).UseEmailVerificationTokens(ctx, userID)
+}
+
+// CountEmailVerificationTokensSinceTx counts the email verification tokens a user was
+// sent since a time within a transaction
+func CountEmailVerificationTokensSinceTx(ctx context.Context, tx pgx.Tx, userID string, since time.Time) (int, *time.Time, error) {
+	return NewEmailVerificationRepository(tx).CountEmailVerificationTokensSince(ctx, userID, since)
+}
+
+// GetUserForUpdateTx returns the live user with id and row-locks them for the rest of
+// the transaction
+func GetUserForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error) {
+	return NewUserRepository(tx).GetUserForUpdate(ctx, id)
+}
+
+// MarkEmailVerifiedTx records that a live user verified their email within a
+// transaction. Like MarkEmailVerified, it returns ErrUserNotFound when the user does
+// not exist.
+func MarkEmailVerifiedTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error) {
+	return NewUserRepository(tx).MarkEmailVerified(ctx, id)
+}
+
+// IsUserEmailVerifiedTx reports whether a live user verified their email, within a
+// transaction
+func IsUserEmailVerifiedTx(ctx context.Context, tx pgx.Tx, userID string) (bool, error) {
+	return NewUserRepository(tx).IsUserEmailVerified(ctx, userID)
+}
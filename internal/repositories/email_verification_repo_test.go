@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailVerificationRepository_UseEmailVerificationTokens(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`UPDATE email_verification_tokens SET used_at = NOW\(\)\s+WHERE user_id = \$1 AND used_at IS NULL`).WithArgs("42").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+
+	err = NewEmailVerificationRepository(mockDB).UseEmailVerificationTokens(context.Background(), "42")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestUserRepository_IsUserEmailVerified_UnknownUser(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`SELECT email_verified_at IS NOT NULL FROM users`).WithArgs("42").
+		WillReturnError(pgx.ErrNoRows)
+
+	verified, err := NewUserRepository(mockDB).IsUserEmailVerified(context.Background(), "42")
+
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.False(t, verified)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
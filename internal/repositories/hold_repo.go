@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrHoldNotFound is returned when no hold matches a lookup
+var ErrHoldNotFound = errors.New("hold not found")
+
+// ErrHoldNotActive is returned when capturing or releasing a hold that was already
+// captured or released
+var ErrHoldNotActive = errors.New("hold is not active")
+
+// CreateHoldTx records an ACTIVE hold. The caller must already have moved the amount
+// into the wallet's held funds with HoldFundsTx.
+func CreateHoldTx(ctx context.Context, tx pgx.Tx, h *models.Hold) error {
+	return tx.QueryRow(ctx, `
+        INSERT INTO holds (wallet_id, amount, status, created_at, updated_at)
+        VALUES ($1, $2, $3, NOW(), NOW())
+        RETURNING id, created_at, updated_at
+    `, h.WalletID, h.Amount, h.Status).Scan(&h.ID, &h.CreatedAt, &h.UpdatedAt)
+}
+
+// GetHoldTx returns a hold together with the user owning its wallet
+func GetHoldTx(ctx context.Context, tx pgx.Tx, id string) (*models.Hold, error) {
+	var h models.Hold
+	err := tx.QueryRow(ctx, `
+        SELECT h.id, h.wallet_id, w.user_id, h.amount, h.status, h.created_at, h.updated_at
+        FROM holds h
+        JOIN wallets w ON w.id = h.wallet_id
+        WHERE h.id = $1
+    `, id).Scan(&h.ID, &h.WalletID, &h.UserID, &h.Amount, &h.Status, &h.CreatedAt, &h.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrHoldNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// FinalizeHoldTx moves an ACTIVE hold to CAPTURED or RELEASED. The status check and
+// the write are one statement, so when two requests finalize the same hold the second
+// waits for the first and then fails with ErrHoldNotActive.
+func FinalizeHoldTx(ctx context.Context, tx pgx.Tx, id string, status models.HoldStatus) (*models.Hold, error) {
+	var h models.Hold
+	err := tx.QueryRow(ctx, `
+        UPDATE holds h SET status = $1, updated_at = NOW()
+        FROM wallets w
+        WHERE h.id = $2 AND h.status = 'ACTIVE' AND w.id = h.wallet_id
+        RETURNING h.id, h.wallet_id, w.user_id, h.amount, h.status, h.created_at, h.updated_at
+    `, status, id).Scan(&h.ID, &h.WalletID, &h.UserID, &h.Amount, &h.Status, &h.CreatedAt, &h.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM holds WHERE id = $1)", id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrHoldNotActive
+		}
+		return nil, fmt.Errorf("%w: %s", ErrHoldNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
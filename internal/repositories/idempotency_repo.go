@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrIdempotencyRecordGone is returned when the record a claim lost to disappeared before
+// it could be read, because the request holding it failed in the meantime, and when a
+// request completes a claim that a retry took over after its lease ran out
+var ErrIdempotencyRecordGone = errors.New("idempotency record gone")
+
+const idempotencyRecordColumns = `user_id, endpoint, idempotency_key, request_hash, status_code, response_body, created_at, completed_at, locked_until`
+
+// PgIdempotencyRepository is the IdempotencyRepository backed by Postgres
+type PgIdempotencyRepository struct {
+	q Querier
+}
+
+// NewIdempotencyRepository creates a PgIdempotencyRepository running its queries on q,
+// usually the pool, each under the query timeout
+func NewIdempotencyRepository(q Querier) *PgIdempotencyRepository {
+	return &PgIdempotencyRepository{q: bound(q)}
+}
+
+// ClaimIdempotencyRecord records that the request of record is being handled for the
+// next lease, unless another request claimed its user, endpoint and key after
+// expiredBefore and either completed it or still holds its lease. It returns true when
+// record was claimed, and otherwise the record already there.
+func (r *PgIdempotencyRepository) ClaimIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time, lease time.Duration) (bool, *models.IdempotencyRecord, error) {
+	err := r.q.QueryRow(ctx, `
+        INSERT INTO idempotency_records (user_id, endpoint, idempotency_key, request_hash, created_at, locked_until)
+        VALUES ($1, $2, $3, $4, NOW(), NOW() + $6 * INTERVAL '1 millisecond')
+        ON CONFLICT (user_id, endpoint, idempotency_key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash, status_code = NULL, response_body = NULL,
+            created_at = EXCLUDED.created_at, completed_at = NULL, locked_until = EXCLUDED.locked_until
+        WHERE idempotency_records.created_at < $5
+           OR (idempotency_records.completed_at IS NULL AND idempotency_records.locked_until < NOW())
+        RETURNING created_at, locked_until
+    `, record.UserID, record.Endpoint, record.Key, record.RequestHash, expiredBefore, lease.Milliseconds()).Scan(&record.CreatedAt, &record.LockedUntil)
+	if err == nil {
+		return true, nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, nil, err
+	}
+
+	existing, err := scanIdempotencyRecord(r.q.QueryRow(ctx, `
+        SELECT `+idempotencyRecordColumns+`
+        FROM idempotency_records
+        WHERE user_id = $1 AND endpoint = $2 AND idempotency_key = $3
+    `, record.UserID, record.Endpoint, record.Key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil, ErrIdempotencyRecordGone
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	return false, existing, nil
+}
+
+// CompleteIdempotencyRecord saves the response to the claimed request of record.
+// Returns ErrIdempotencyRecordGone when the claim is no longer record's.
+func (r *PgIdempotencyRepository) CompleteIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	err := r.q.QueryRow(ctx, `
+        UPDATE idempotency_records SET status_code = $1, response_body = $2, completed_at = NOW()
+        WHERE user_id = $3 AND endpoint = $4 AND idempotency_key = $5 AND created_at = $6
+        RETURNING completed_at
+    `, record.StatusCode, record.ResponseBody, record.UserID, record.Endpoint, record.Key, record.CreatedAt).Scan(&record.CompletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrIdempotencyRecordGone
+	}
+	return err
+}
+
+// DeleteIdempotencyRecord gives up the claim on the request of record, so it can be
+// retried. A claim a retry already took over is left to it.
+func (r *PgIdempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	var deleted int
+	return r.q.QueryRow(ctx, `
+        WITH deleted AS (
+            DELETE FROM idempotency_records
+            WHERE user_id = $1 AND endpoint = $2 AND idempotency_key = $3 AND created_at = $4
+            RETURNING 1
+        )
+        SELECT COUNT(*) FROM deleted
+    `, record.UserID, record.Endpoint, record.Key, record.CreatedAt).Scan(&deleted)
+}
+
+// DeleteExpiredIdempotencyRecords deletes the records created before expiredBefore and
+// returns how many it deleted
+func (r *PgIdempotencyRepository) DeleteExpiredIdempotencyRecords(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	var deleted int64
+	err := r.q.QueryRow(ctx, `
+        WITH deleted AS (
+            DELETE FROM idempotency_records WHERE created_at < $1
+            RETURNING 1
+        )
+        SELECT COUNT(*) FROM deleted
+    `, expiredBefore).Scan(&deleted)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func scanIdempotencyRecord(row pgx.Row) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	err := row.Scan(&record.UserID, &record.Endpoint, &record.Key, &record.RequestHash, &record.StatusCode,
+		&record.ResponseBody, &record.CreatedAt, &record.CompletedAt, &record.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyRepository_ClaimIdempotencyRecord(t *testing.T) {
+	t.Run("claims a free or abandoned key for the lease", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+
+		now := time.Now()
+		expiredBefore := now.Add(-24 * time.Hour)
+		record := &models.IdempotencyRecord{UserID: "42", Endpoint: "POST /deposit", Key: "k1", RequestHash: "abc"}
+		mockDB.ExpectQuery(`INSERT INTO idempotency_records .+ OR \(idempotency_records.completed_at IS NULL AND idempotency_records.locked_until < NOW\(\)\)`).
+			WithArgs("42", "POST /deposit", "k1", "abc", expiredBefore, int64(60000)).
+			WillReturnRows(pgxmock.NewRows([]string{"created_at", "locked_until"}).AddRow(now, now.Add(time.Minute)))
+
+		claimed, existing, err := NewIdempotencyRepository(mockDB).ClaimIdempotencyRecord(context.Background(), record, expiredBefore, time.Minute)
+
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		assert.Nil(t, existing)
+		assert.Equal(t, now.Add(time.Minute), record.LockedUntil)
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+
+	t.Run("returns the record holding the key", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+
+		now := time.Now()
+		expiredBefore := now.Add(-24 * time.Hour)
+		record := &models.IdempotencyRecord{UserID: "42", Endpoint: "POST /deposit", Key: "k1", RequestHash: "abc"}
+		mockDB.ExpectQuery(`INSERT INTO idempotency_records`).
+			WithArgs("42", "POST /deposit", "k1", "abc", expiredBefore, int64(60000)).
+			WillReturnError(pgx.ErrNoRows)
+		mockDB.ExpectQuery(`SELECT .+ FROM idempotency_records`).WithArgs("42", "POST /deposit", "k1").
+			WillReturnRows(pgxmock.NewRows([]string{"user_id", "endpoint", "idempotency_key", "request_hash", "status_code",
+				"response_body", "created_at", "completed_at", "locked_until"}).
+				AddRow("42", "POST /deposit", "k1", "abc", nil, nil, now, nil, now.Add(time.Minute)))
+
+		claimed, existing, err := NewIdempotencyRepository(mockDB).ClaimIdempotencyRecord(context.Background(), record, expiredBefore, time.Minute)
+
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+		if assert.NotNil(t, existing) {
+			assert.Nil(t, existing.StatusCode)
+			assert.Equal(t, now.Add(time.Minute), existing.LockedUntil)
+		}
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+}
+
+func TestIdempotencyRepository_CompleteIdempotencyRecord_ClaimTakenOver(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	status := 200
+	record := &models.IdempotencyRecord{UserID: "42", Endpoint: "POST /deposit", Key: "k1", StatusCode: &status, CreatedAt: time.Now()}
+	mockDB.ExpectQuery(`UPDATE idempotency_records .+ AND created_at = \$6`).
+		WithArgs(&status, []byte(nil), "42", "POST /deposit", "k1", record.CreatedAt).
+		WillReturnError(pgx.ErrNoRows)
+
+	err = NewIdempotencyRepository(mockDB).CompleteIdempotencyRecord(context.Background(), record)
+
+	assert.ErrorIs(t, err, ErrIdempotencyRecordGone)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestIdempotencyRepository_DeleteExpiredIdempotencyRecords(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	expiredBefore := time.Now().Add(-24 * time.Hour)
+	mockDB.ExpectQuery(`DELETE FROM idempotency_records WHERE created_at < \$1`).WithArgs(expiredBefore).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+
+	deleted, err := NewIdempotencyRepository(mockDB).DeleteExpiredIdempotencyRecords(context.Background(), expiredBefore)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInterestAlreadyAccrued is returned when a wallet already has an accrual for the date
+var ErrInterestAlreadyAccrued = errors.New("interest already accrued for the date")
+
+// HasInterestAccrualTx reports whether a wallet already accrued interest for date
+func HasInterestAccrualTx(ctx context.Context, tx pgx.Tx, walletID string, date time.Time) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM interest_accruals WHERE wallet_id = $1 AND accrual_date = $2::date)
+    `, walletID, date.Format(time.DateOnly)).Scan(&exists)
+	return exists, err
+}
+
+// CreateInterestAccrualTx records the interest credited to a wallet for a date within a
+// transaction. Returns ErrInterestAlreadyAccrued when the wallet already has one.
+func CreateInterestAccrualTx(ctx context.Context, tx pgx.Tx, a *models.InterestAccrual) error {
+	err := tx.QueryRow(ctx, `
+        INSERT INTO interest_accruals (wallet_id, accrual_date, transaction_id, balance, rate_bps, amount, created_at)
+        VALUES ($1, $2::date, $3, $4, $5, $6, NOW())
+        ON CONFLICT (wallet_id, accrual_date) DO NOTHING
+        RETURNING created_at
+    `, a.WalletID, a.Date.Format(time.DateOnly), a.TransactionID, a.Balance, a.RateBps, a.Amount).Scan(&a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInterestAlreadyAccrued
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrUnbalancedLedgerEntries is returned when a group of ledger entries has
+// debits and credits that don't sum to the same amount
+var ErrUnbalancedLedgerEntries = errors.New("ledger entries are not balanced")
+
+// CreateLedgerEntriesTx inserts a balanced group of ledger entries within a transaction
+func CreateLedgerEntriesTx(ctx context.Context, tx pgx.Tx, entries []models.LedgerEntry) error {
+	var debits, credits money.Amount
+	for _, e := range entries {
+		switch e.Direction {
+		case models.LedgerDebit:
+			debits += e.Amount
+		case models.LedgerCredit:
+			credits += e.Amount
+		}
+	}
+	if len(entries) == 0 || debits != credits {
+		return ErrUnbalancedLedgerEntries
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		err := tx.QueryRow(ctx, `
+            INSERT INTO ledger_entries (group_id, account, wallet_id, direction, amount, created_at)
+            VALUES ($1, $2, $3, $4, $5, NOW())
+            RETURNING id, created_at
+        `, e.GroupID, e.Account, e.WalletID, e.Direction, e.Amount).Scan(&e.ID, &e.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLedgerReport reads a wallet's stored balance together with the balance derived
+// from its ledger entries in a single statement, so both come from the same snapshot
+func GetLedgerReport(ctx context.Context, userID string) (*models.LedgerReport, error) {
+	var r models.LedgerReport
+	err := readPool().QueryRow(ctx, `
+        SELECT w.user_id, w.id, w.balance,
+               COALESCE(SUM(CASE l.direction WHEN 'CREDIT' THEN l.amount ELSE -l.amount END), 0)
+        FROM wallets w
+        LEFT JOIN ledger_entries l ON l.wallet_id = w.id
+        WHERE w.user_id = $1 AND w.is_default
+        GROUP BY w.id
+    `, userID).Scan(&r.UserID, &r.WalletID, &r.Balance, &r.LedgerBalance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
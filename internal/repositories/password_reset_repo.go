@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPasswordResetTokenNotFound is returned when no password reset token has the hash
+var ErrPasswordResetTokenNotFound = errors.New("password reset token not found")
+
+// PgPasswordResetRepository is the PasswordResetRepository backed by Postgres
+type PgPasswordResetRepository struct {
+	q Querier
+}
+
+// NewPasswordResetRepository creates a PgPasswordResetRepository running its queries on
+// q, usually the pool, each under the query timeout
+func NewPasswordResetRepository(q Querier) *PgPasswordResetRepository {
+	return &PgPasswordResetRepository{q: bound(q)}
+}
+
+// CreatePasswordResetToken records a password reset token. Returns ErrUserNotFound
+// when the user does not exist.
+func (r *PgPasswordResetRepository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	err := r.q.QueryRow(ctx, `
+        INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, NOW())
+        RETURNING id, created_at
+    `, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// GetPasswordResetTokenForUpdate returns the password reset token with tokenHash and,
+// run in a transaction, row-locks it for the rest of it, so it is used only once
+func (r *PgPasswordResetRepository) GetPasswordResetTokenForUpdate(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.q.QueryRow(ctx, `
+        SELECT id, user_id, token_hash, expires_at, used_at, created_at
+        FROM password_reset_tokens
+        WHERE token_hash = $1
+        FOR UPDATE
+    `, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPasswordResetTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UsePasswordResetTokens marks every unused password reset token of a user used, so
+// none of the tokens they were sent works once one of them set the password
+func (r *PgPasswordResetRepository) UsePasswordResetTokens(ctx context.Context, userID string) error {
+	var used int
+	return r.q.QueryRow(ctx, `
+        WITH used AS (
+            UPDATE password_reset_tokens SET used_at = NOW()
+            WHERE user_id = $1 AND used_at IS NULL
+            RETURNING 1
+        )
+        SELECT COUNT(*) FROM used
+    `, userID).Scan(&used)
+}
+
+// GetPasswordResetTokenForUpdateTx returns the password reset token with tokenHash and
+// row-locks it for the rest of the transaction
+func GetPasswordResetTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.PasswordResetToken, error) {
+	return NewPasswordResetRepository(tx).GetPasswordResetTokenForUpdate(ctx, tokenHash)
+}
+
+// UsePasswordResetTokensTx marks every unused password reset token of a user used
+// within a transaction
+func UsePasswordResetTokensTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	return NewPasswordResetRepository(tx).UsePasswordResetTokens(ctx, userID)
+}
+
+// UpdateUserPasswordTx replaces the password hash of a live user within a transaction.
+// Like UpdateUserPassword, it returns ErrUserNotFound when the user does not exist.
+func UpdateUserPasswordTx(ctx context.Context, tx pgx.Tx, id, passwordHash string) error {
+	return NewUserRepository(tx).UpdateUserPassword(ctx, id, passwordHash)
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordResetRepository_GetPasswordResetTokenForUpdate_Unknown(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`SELECT .+ FROM password_reset_tokens\s+WHERE token_hash = \$1\s+FOR UPDATE`).WithArgs("abc").
+		WillReturnError(pgx.ErrNoRows)
+
+	token, err := NewPasswordResetRepository(mockDB).GetPasswordResetTokenForUpdate(context.Background(), "abc")
+
+	assert.ErrorIs(t, err, ErrPasswordResetTokenNotFound)
+	assert.Nil(t, token)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestPasswordResetRepository_UsePasswordResetTokens(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`UPDATE password_reset_tokens SET used_at = NOW\(\)\s+WHERE user_id = \$1 AND used_at IS NULL`).WithArgs("42").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(2))
+
+	err = NewPasswordResetRepository(mockDB).UsePasswordResetTokens(context.Background(), "42")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
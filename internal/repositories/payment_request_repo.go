@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPaymentRequestNotFound is returned when no payment request matches a lookup
+var ErrPaymentRequestNotFound = errors.New("payment request not found")
+
+const paymentRequestColumns = `id, requester_id, payer_id, amount, message, status, transfer_id, expires_at, created_at, updated_at`
+
+// CreatePaymentRequest records a PENDING payment request. Returns ErrUserNotFound when
+// either user does not exist.
+func CreatePaymentRequest(ctx context.Context, r *models.PaymentRequest) error {
+	err := pool().QueryRow(ctx, `
+        INSERT INTO payment_requests (requester_id, payer_id, amount, message, status, expires_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+        RETURNING id, created_at, updated_at
+    `, r.RequesterID, r.PayerID, r.Amount, r.Message, r.Status, r.ExpiresAt).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// GetPendingPaymentRequestsByPayerID returns the requests a user has yet to answer,
+// leaving out those past their expiry, newest first
+func GetPendingPaymentRequestsByPayerID(ctx context.Context, payerID string) ([]models.PaymentRequest, error) {
+	rows, err := readPool().Query(ctx, `
+        SELECT `+paymentRequestColumns+`
+        FROM payment_requests
+        WHERE payer_id = $1 AND status = 'PENDING' AND expires_at > NOW()
+        ORDER BY created_at DESC
+    `, payerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.PaymentRequest
+	for rows.Next() {
+		r, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *r)
+	}
+	return requests, rows.Err()
+}
+
+// GetPaymentRequestForUpdateTx returns a payment request addressed to payerID and
+// row-locks it for the rest of the transaction, so it is answered only once
+func GetPaymentRequestForUpdateTx(ctx context.Context, tx pgx.Tx, payerID, id string) (*models.PaymentRequest, error) {
+	row := tx.QueryRow(ctx, `
+        SELECT `+paymentRequestColumns+`
+        FROM payment_requests
+        WHERE id = $1 AND payer_id = $2
+        FOR UPDATE
+    `, id, payerID)
+	r, err := scanPaymentRequest(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentRequestNotFound, id)
+	}
+	return r, err
+}
+
+// UpdatePaymentRequestTx saves the answer to a payment request
+func UpdatePaymentRequestTx(ctx context.Context, tx pgx.Tx, r *models.PaymentRequest) error {
+	return tx.QueryRow(ctx, `
+        UPDATE payment_requests SET status = $1, transfer_id = $2, updated_at = NOW()
+        WHERE id = $3
+        RETURNING updated_at
+    `, r.Status, r.TransferID, r.ID).Scan(&r.UpdatedAt)
+}
+
+// ExpirePaymentRequests marks PENDING requests past their expiry as EXPIRED and returns
+// how many it expired. A request being accepted keeps its row lock until it is PAID, so
+// it is not expired underneath the payer.
+func ExpirePaymentRequests(ctx context.Context) (int64, error) {
+	tag, err := pool().Exec(ctx, `
+        UPDATE payment_requests SET status = 'EXPIRED', updated_at = NOW()
+        WHERE status = 'PENDING' AND expires_at <= NOW()
+    `)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanPaymentRequest(row pgx.Row) (*models.PaymentRequest, error) {
+	var r models.PaymentRequest
+	err := row.Scan(&r.ID, &r.RequesterID, &r.PayerID, &r.Amount, &r.Message, &r.Status, &r.TransferID,
+		&r.ExpiresAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
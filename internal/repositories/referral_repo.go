@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrAlreadyReferred is returned when the referred user already has a referral
+var ErrAlreadyReferred = errors.New("user was already referred")
+
+// CreateReferralTx records a referral within a transaction. Returns ErrAlreadyReferred
+// when the referred user was already referred.
+func CreateReferralTx(ctx context.Context, tx pgx.Tx, r *models.Referral) error {
+	err := tx.QueryRow(ctx, `
+        INSERT INTO referrals (referred_user_id, referrer_id, transaction_id, bonus, created_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        ON CONFLICT (referred_user_id) DO NOTHING
+        RETURNING created_at
+    `, r.ReferredUserID, r.ReferrerID, r.TransactionID, r.Bonus).Scan(&r.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyReferred
+	}
+	return err
+}
+
+// GetReferralsByReferrerID returns the users a user referred, oldest first, with the
+// bonus paid for each
+func GetReferralsByReferrerID(ctx context.Context, referrerID string) ([]models.Referral, error) {
+	rows, err := readPool().Query(ctx, `
+        SELECT r.referred_user_id, u.username, r.referrer_id, r.transaction_id, r.bonus, r.created_at
+        FROM referrals r
+        JOIN users u ON u.id = r.referred_user_id
+        WHERE r.referrer_id = $1
+        ORDER BY r.created_at
+    `, referrerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var referrals []models.Referral
+	for rows.Next() {
+		var r models.Referral
+		if err := rows.Scan(&r.ReferredUserID, &r.ReferredUsername, &r.ReferrerID, &r.TransactionID, &r.Bonus, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		referrals = append(referrals, r)
+	}
+	return referrals, rows.Err()
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier runs queries outside a transaction. The pool implements it, and so does a
+// mock in tests.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// UserRepository reads and writes users
+type UserRepository interface {
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.User, int, error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserCredentialsByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserCredentialsByID(ctx context.Context, id string) (*models.User, error)
+	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
+	IsEmailExists(ctx context.Context, email string) (bool, error)
+	IsUsernameExists(ctx context.Context, username string) (bool, error)
+	UpdateUser(ctx context.Context, id string, fields *models.UpdateUserRequest) (*models.User, error)
+	SetUserKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error)
+	RestoreUser(ctx context.Context, id string) (*models.User, error)
+	UpdateUserPassword(ctx context.Context, id, passwordHash string) error
+	RecordPasswordFailure(ctx context.Context, id string) error
+	CountPasswordFailuresSince(ctx context.Context, id string, since time.Time) (int, *time.Time, error)
+}
+
+// WalletRepository reads and writes wallets outside the balance-changing operations,
+// which run in a transaction through the Tx functions
+type WalletRepository interface {
+	GetWalletByUserID(ctx context.Context, userID string) (*models.Wallet, error)
+	GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error)
+	GetWalletsByUserID(ctx context.Context, userID string) ([]models.Wallet, error)
+	GetWalletsByUserIDs(ctx context.Context, userIDs []string) (map[string]*models.Wallet, error)
+	CreateWallet(ctx context.Context, userID string) (*models.Wallet, error)
+	CreateNamedWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error)
+	SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error)
+	SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error)
+	GetInterestBearingWallets(ctx context.Context) ([]models.Wallet, error)
+}
+
+// TransactionRepository reads recorded transactions and the reports built from them
+type TransactionRepository interface {
+	GetTransactionByID(ctx context.Context, id string) (*models.Transaction, error)
+	GetBalanceAfterTransaction(ctx context.Context, t *models.Transaction) (money.Amount, error)
+	GetTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter, sort TransactionSort, limit, offset int) ([]models.Transaction, int, error)
+	GetTransactionsAfterCursor(ctx context.Context, walletID string, filter TransactionFilter, cursorTime time.Time, cursorID string, limit int) ([]models.Transaction, int, error)
+	CountTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter) (int, error)
+	StreamTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter, fn func(models.Transaction) error) error
+	GetTransactionsByTransferID(ctx context.Context, transferID string) ([]models.Transaction, error)
+	GetTransactionSummary(ctx context.Context, walletID string, from, to time.Time) (*models.WalletSummary, error)
+	GetWalletTotals(ctx context.Context, walletID string) (*models.WalletTotals, error)
+	GetStatementTransactions(ctx context.Context, walletID string, from, to time.Time, limit, offset int) ([]models.Transaction, int, error)
+	CreateTransactionPartition(ctx context.Context, month time.Time) (string, error)
+	GetBalanceDiscrepancies(ctx context.Context) ([]models.Discrepancy, error)
+}
+
+// ArchiveRepository records the runs of the job that moves old transactions to
+// transactions_archive. The batches themselves move in a transaction, through
+// ArchiveTransactionsTx.
+type ArchiveRepository interface {
+	CreateArchiveRun(ctx context.Context, cutoff time.Time) (*models.ArchiveRun, error)
+	GetLatestArchiveRun(ctx context.Context) (*models.ArchiveRun, error)
+	FinishArchiveRun(ctx context.Context, id string, runErr error) (*models.ArchiveRun, error)
+	CountArchivableTransactions(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// IdempotencyRepository keeps the responses replayed to retries of requests sent with
+// an Idempotency-Key header
+type IdempotencyRepository interface {
+	ClaimIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time, lease time.Duration) (bool, *models.IdempotencyRecord, error)
+	CompleteIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error
+	DeleteIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error
+	DeleteExpiredIdempotencyRecords(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+// PasswordResetRepository stores the tokens emailed to users who forgot their password
+type PasswordResetRepository interface {
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	GetPasswordResetTokenForUpdate(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	UsePasswordResetTokens(ctx context.Context, userID string) error
+}
+
+// PgUserRepository is the UserRepository backed by Postgres
+type PgUserRepository struct {
+	q Querier
+	// read runs the reads whose results are only returned, which may lag behind q
+	read Querier
+}
+
+// NewUserRepository creates a PgUserRepository running its queries on q, usually the
+// pool, each under the query timeout
+func NewUserRepository(q Querier) *PgUserRepository {
+	return NewUserRepositoryWithReplica(q, q)
+}
+
+// NewUserRepositoryWithReplica creates a PgUserRepository running the reads whose results
+// it only returns on read, usually the replica's pool, and every other query on q
+func NewUserRepositoryWithReplica(q, read Querier) *PgUserRepository {
+	return &PgUserRepository{q: bound(q), read: bound(read)}
+}
+
+// PgWalletRepository is the WalletRepository backed by Postgres
+type PgWalletRepository struct {
+	q Querier
+	// read runs the reads whose results are only returned, which may lag behind q
+	read Querier
+}
+
+// NewWalletRepository creates a PgWalletRepository running its queries on q, usually
+// the pool, each under the query timeout
+func NewWalletRepository(q Querier) *PgWalletRepository {
+	return NewWalletRepositoryWithReplica(q, q)
+}
+
+// NewWalletRepositoryWithReplica creates a PgWalletRepository running the reads whose results
+// it only returns on read, usually the replica's pool, and every other query on q
+func NewWalletRepositoryWithReplica(q, read Querier) *PgWalletRepository {
+	return &PgWalletRepository{q: bound(q), read: bound(read)}
+}
+
+// PgTransactionRepository is the TransactionRepository backed by Postgres
+type PgTransactionRepository struct {
+	q Querier
+	// read runs the reads whose results are only returned, which may lag behind q
+	read Querier
+}
+
+// NewTransactionRepository creates a PgTransactionRepository running its queries on q,
+// usually the pool, each under the query timeout
+func NewTransactionRepository(q Querier) *PgTransactionRepository {
+	return NewTransactionRepositoryWithReplica(q, q)
+}
+
+// NewTransactionRepositoryWithReplica creates a PgTransactionRepository running the reads whose results
+// it only returns on read, usually the replica's pool, and every other query on q
+func NewTransactionRepositoryWithReplica(q, read Querier) *PgTransactionRepository {
+	return &PgTransactionRepository{q: bound(q), read: bound(read)}
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTransferAlreadyReversed is returned when recording the reversal of a transfer that
+// was already reversed
+var ErrTransferAlreadyReversed = errors.New("transfer was already reversed")
+
+// CreateTransferReversalTx records that a transfer was reversed. When a concurrent
+// transaction has recorded a reversal of the same transfer but not yet committed, the
+// insert waits for it to finish, so exactly one of them records the reversal and the
+// other fails with ErrTransferAlreadyReversed.
+func CreateTransferReversalTx(ctx context.Context, tx pgx.Tx, r *models.TransferReversal) error {
+	err := tx.QueryRow(ctx, `
+        INSERT INTO transfer_reversals (transfer_id, reason, created_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (transfer_id) DO NOTHING
+        RETURNING created_at
+    `, r.TransferID, r.Reason).Scan(&r.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrTransferAlreadyReversed, r.TransferID)
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const riskFlagColumns = `id, wallet_id, transaction_id, rule, details, created_at`
+
+// CreateRiskFlagTx records a risk flag within a transaction
+func CreateRiskFlagTx(ctx context.Context, tx pgx.Tx, f *models.RiskFlag) error {
+	return tx.QueryRow(ctx, `
+        INSERT INTO risk_flags (wallet_id, transaction_id, rule, details, created_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        RETURNING id, created_at
+    `, f.WalletID, f.TransactionID, f.Rule, f.Details).Scan(&f.ID, &f.CreatedAt)
+}
+
+// GetRiskFlags returns the risk flags matching filter, newest first
+func GetRiskFlags(ctx context.Context, filter models.RiskFlagFilter) ([]models.RiskFlag, error) {
+	// created_at has no time zone, so the bounds are compared as instants in the
+	// session's time zone, the one NOW() used when the rows were recorded
+	rows, err := readPool().Query(ctx, `
+        SELECT `+riskFlagColumns+`
+        FROM risk_flags
+        WHERE ($1::text = '' OR rule = $1)
+          AND ($2::timestamptz IS NULL OR created_at >= $2::timestamptz)
+          AND ($3::timestamptz IS NULL OR created_at < $3::timestamptz)
+        ORDER BY created_at DESC
+    `, filter.Rule, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []models.RiskFlag
+	for rows.Next() {
+		var f models.RiskFlag
+		if err := rows.Scan(&f.ID, &f.WalletID, &f.TransactionID, &f.Rule, &f.Details, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// CreateScheduledTransfer records a PENDING scheduled transfer. Returns ErrUserNotFound
+// when either user does not exist.
+func CreateScheduledTransfer(ctx context.Context, st *models.ScheduledTransfer) error {
+	err := pool().QueryRow(ctx, `
+        INSERT INTO scheduled_transfers (from_user_id, to_user_id, amount, execute_at, status, standing_order_id, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+        RETURNING id, attempts, created_at, updated_at
+    `, st.FromUserID, st.ToUserID, st.Amount, st.ExecuteAt, st.Status, st.StandingOrderID).Scan(&st.ID, &st.Attempts, &st.CreatedAt, &st.UpdatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// CreateScheduledTransferTx records a PENDING scheduled transfer within a transaction
+func CreateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	err := tx.QueryRow(ctx, `
+        INSERT INTO scheduled_transfers (from_user_id, to_user_id, amount, execute_at, status, standing_order_id, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+        RETURNING id, attempts, created_at, updated_at
+    `, st.FromUserID, st.ToUserID, st.Amount, st.ExecuteAt, st.Status, st.StandingOrderID).Scan(&st.ID, &st.Attempts, &st.CreatedAt, &st.UpdatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// translateUserForeignKeyError maps a violated reference to users to ErrUserNotFound
+func translateUserForeignKeyError(err error) error {
+	var pgErr *pgconn.PgError
+	// 23503 is foreign_key_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// GetScheduledTransfersByUserID returns the transfers a user has scheduled, soonest first
+func GetScheduledTransfersByUserID(ctx context.Context, userID string) ([]models.ScheduledTransfer, error) {
+	rows, err := readPool().Query(ctx, `
+        SELECT id, from_user_id, to_user_id, amount, execute_at, status, attempts, last_error, transfer_id, standing_order_id, created_at, updated_at
+        FROM scheduled_transfers
+        WHERE from_user_id = $1
+        ORDER BY execute_at, created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledTransfers(rows)
+}
+
+// ClaimDueScheduledTransfersTx locks up to limit PENDING transfers whose execution time
+// has passed. Rows already locked by another worker are skipped, so concurrent workers
+// never claim the same transfer; the claim lasts until tx ends.
+func ClaimDueScheduledTransfersTx(ctx context.Context, tx pgx.Tx, limit int) ([]models.ScheduledTransfer, error) {
+	rows, err := tx.Query(ctx, `
+        SELECT id, from_user_id, to_user_id, amount, execute_at, status, attempts, last_error, transfer_id, standing_order_id, created_at, updated_at
+        FROM scheduled_transfers
+        WHERE status = 'PENDING' AND execute_at <= NOW()
+        ORDER BY execute_at
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledTransfers(rows)
+}
+
+// CancelPendingOccurrencesTx cancels the PENDING occurrences of a standing order.
+// Occurrences a worker has already claimed are skipped rather than waited for; the
+// worker cancels those itself once it sees the standing order is no longer active.
+func CancelPendingOccurrencesTx(ctx context.Context, tx pgx.Tx, standingOrderID string) error {
+	_, err := tx.Exec(ctx, `
+        UPDATE scheduled_transfers SET status = 'CANCELLED', updated_at = NOW()
+        WHERE id IN (
+            SELECT id FROM scheduled_transfers
+            WHERE standing_order_id = $1 AND status = 'PENDING'
+            FOR UPDATE SKIP LOCKED
+        )
+    `, standingOrderID)
+	return err
+}
+
+// UpdateScheduledTransferTx saves the outcome of an execution attempt
+func UpdateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	return tx.QueryRow(ctx, `
+        UPDATE scheduled_transfers
+        SET status = $1, attempts = $2, last_error = $3, transfer_id = $4, execute_at = $5, updated_at = NOW()
+        WHERE id = $6
+        RETURNING updated_at
+    `, st.Status, st.Attempts, st.LastError, st.TransferID, st.ExecuteAt, st.ID).Scan(&st.UpdatedAt)
+}
+
+func scanScheduledTransfers(rows pgx.Rows) ([]models.ScheduledTransfer, error) {
+	var transfers []models.ScheduledTransfer
+	for rows.Next() {
+		var st models.ScheduledTransfer
+		if err := rows.Scan(&st.ID, &st.FromUserID, &st.ToUserID, &st.Amount, &st.ExecuteAt, &st.Status, &st.Attempts,
+			&st.LastError, &st.TransferID, &st.StandingOrderID, &st.CreatedAt, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, st)
+	}
+	return transfers, rows.Err()
+}
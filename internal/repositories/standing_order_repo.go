@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrStandingOrderNotFound is returned when no standing order matches a lookup
+var ErrStandingOrderNotFound = errors.New("standing order not found")
+
+// ErrStandingOrderNotActive is returned when cancelling a standing order that has
+// already ended
+var ErrStandingOrderNotActive = errors.New("standing order is not active")
+
+const standingOrderColumns = `id, from_user_id, to_user_id, amount, repeat_interval, start_at, end_at, max_occurrences,
+        occurrences, consecutive_failures, next_run_at, status, created_at, updated_at`
+
+// CreateStandingOrderTx records a standing order. Returns ErrUserNotFound when either
+// user does not exist.
+func CreateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error {
+	err := tx.QueryRow(ctx, `
+        INSERT INTO standing_orders (from_user_id, to_user_id, amount, repeat_interval, start_at, end_at, max_occurrences,
+            occurrences, next_run_at, status, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+        RETURNING id, consecutive_failures, created_at, updated_at
+    `, o.FromUserID, o.ToUserID, o.Amount, o.Interval, o.StartAt, o.EndAt, o.MaxOccurrences, o.Occurrences, o.NextRunAt, o.Status).
+		Scan(&o.ID, &o.ConsecutiveFailures, &o.CreatedAt, &o.UpdatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// GetStandingOrder returns one of a user's standing orders
+func GetStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error) {
+	row := readPool().QueryRow(ctx, `
+        SELECT `+standingOrderColumns+`
+        FROM standing_orders
+        WHERE id = $1 AND from_user_id = $2
+    `, id, userID)
+	o, err := scanStandingOrder(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrStandingOrderNotFound, id)
+	}
+	return o, err
+}
+
+// GetStandingOrderForUpdateTx returns a standing order and row-locks it for the rest of
+// the transaction, so it cannot be cancelled while an occurrence is being settled
+func GetStandingOrderForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.StandingOrder, error) {
+	row := tx.QueryRow(ctx, `
+        SELECT `+standingOrderColumns+`
+        FROM standing_orders
+        WHERE id = $1
+        FOR UPDATE
+    `, id)
+	o, err := scanStandingOrder(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrStandingOrderNotFound, id)
+	}
+	return o, err
+}
+
+// GetStandingOrdersByUserID returns a user's standing orders, newest first
+func GetStandingOrdersByUserID(ctx context.Context, userID string) ([]models.StandingOrder, error) {
+	rows, err := readPool().Query(ctx, `
+        SELECT `+standingOrderColumns+`
+        FROM standing_orders
+        WHERE from_user_id = $1
+        ORDER BY created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.StandingOrder
+	for rows.Next() {
+		o, err := scanStandingOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *o)
+	}
+	return orders, rows.Err()
+}
+
+// UpdateStandingOrderTx saves a standing order's progress after an occurrence
+func UpdateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error {
+	return tx.QueryRow(ctx, `
+        UPDATE standing_orders
+        SET occurrences = $1, consecutive_failures = $2, next_run_at = $3, status = $4, updated_at = NOW()
+        WHERE id = $5
+        RETURNING updated_at
+    `, o.Occurrences, o.ConsecutiveFailures, o.NextRunAt, o.Status, o.ID).Scan(&o.UpdatedAt)
+}
+
+// CancelStandingOrderTx cancels one of a user's ACTIVE standing orders. Returns
+// ErrStandingOrderNotActive when it has already ended.
+func CancelStandingOrderTx(ctx context.Context, tx pgx.Tx, userID, id string) (*models.StandingOrder, error) {
+	row := tx.QueryRow(ctx, `
+        UPDATE standing_orders SET status = 'CANCELLED', updated_at = NOW()
+        WHERE id = $1 AND from_user_id = $2 AND status = 'ACTIVE'
+        RETURNING `+standingOrderColumns, id, userID)
+	o, err := scanStandingOrder(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM standing_orders WHERE id = $1 AND from_user_id = $2)", id, userID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrStandingOrderNotActive
+		}
+		return nil, fmt.Errorf("%w: %s", ErrStandingOrderNotFound, id)
+	}
+	return o, err
+}
+
+func scanStandingOrder(row pgx.Row) (*models.StandingOrder, error) {
+	var o models.StandingOrder
+	err := row.Scan(&o.ID, &o.FromUserID, &o.ToUserID, &o.Amount, &o.Interval, &o.StartAt, &o.EndAt, &o.MaxOccurrences,
+		&o.Occurrences, &o.ConsecutiveFailures, &o.NextRunAt, &o.Status, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
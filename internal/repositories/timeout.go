@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"walletapp/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultQueryTimeout bounds every query unless SetQueryTimeout says otherwise
+const DefaultQueryTimeout = 10 * time.Second
+
+// ErrQueryTimeout is returned when a query runs past the query timeout. A query
+// stopped by the caller's own deadline or cancellation returns the context's error
+// instead.
+var ErrQueryTimeout = errors.New("query timed out")
+
+// queryTimeout is how long a single query may run, or zero for no limit
+var queryTimeout = DefaultQueryTimeout
+
+// SetQueryTimeout sets how long a single query may run from then on. Zero lifts the
+// limit. It must be called before the repositories serve requests.
+func SetQueryTimeout(d time.Duration) {
+	queryTimeout = d
+}
+
+// QueryTimeoutFromEnv reads the query timeout from DATABASE_QUERY_TIMEOUT, a duration
+// such as "5s", where "0" lifts the limit. Unset, it is DefaultQueryTimeout.
+func QueryTimeoutFromEnv() (time.Duration, error) {
+	raw := os.Getenv("DATABASE_QUERY_TIMEOUT")
+	if raw == "" {
+		return DefaultQueryTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid DATABASE_QUERY_TIMEOUT %q: must be a duration of zero or more", raw)
+	}
+	return d, nil
+}
+
+// withQueryTimeout derives the context a query runs under from the caller's ctx
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, queryTimeout)
+}
+
+// queryErr turns an error caused by the query timeout into ErrQueryTimeout. When ctx,
+// the caller's context, is done too, its deadline or cancellation stopped the query
+// and err is returned as it is.
+func queryErr(ctx context.Context, err error) error {
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+	return err
+}
+
+// execQuerier is a Querier that also runs statements returning no rows
+type execQuerier interface {
+	Querier
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// boundedQuerier runs every query of q under the query timeout
+type boundedQuerier struct {
+	q Querier
+}
+
+// bound wraps q so its queries run under the query timeout
+func bound(q Querier) Querier {
+	if b, ok := q.(boundedQuerier); ok {
+		return b
+	}
+	return boundedQuerier{q: q}
+}
+
+// unbounded returns the Querier q bounds, for the queries the query timeout must not
+// cut short
+func unbounded(q Querier) Querier {
+	if b, ok := q.(boundedQuerier); ok {
+		return b.q
+	}
+	return q
+}
+
+func (b boundedQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return boundedQuery(ctx, b.q, sql, args...)
+}
+
+func (b boundedQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return boundedQueryRow(ctx, b.q, sql, args...)
+}
+
+// boundedPool is the pool, with its queries under the query timeout, for the
+// repository functions that run on it directly
+type boundedPool struct {
+	q execQuerier
+}
+
+func (b boundedPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return boundedQuery(ctx, b.q, sql, args...)
+}
+
+func (b boundedPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return boundedQueryRow(ctx, b.q, sql, args...)
+}
+
+func (b boundedPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return boundedExec(ctx, b.q, sql, args...)
+}
+
+// BoundTx wraps tx so the statements run in it are each bounded by the query timeout.
+// The transaction as a whole is bounded only by the caller's context.
+func BoundTx(tx pgx.Tx) pgx.Tx {
+	if _, ok := tx.(boundedTx); ok {
+		return tx
+	}
+	return boundedTx{Tx: tx}
+}
+
+// boundedTx is a pgx.Tx running each query under the query timeout
+type boundedTx struct {
+	pgx.Tx
+}
+
+func (t boundedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return boundedQuery(ctx, t.Tx, sql, args...)
+}
+
+func (t boundedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return boundedQueryRow(ctx, t.Tx, sql, args...)
+}
+
+func (t boundedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return boundedExec(ctx, t.Tx, sql, args...)
+}
+
+func boundedQuery(ctx context.Context, q Querier, sql string, args ...any) (pgx.Rows, error) {
+	queryCtx, cancel := withQueryTimeout(ctx)
+	rows, err := q.Query(queryCtx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, queryErr(ctx, err)
+	}
+	return &boundedRows{Rows: rows, ctx: ctx, cancel: cancel}, nil
+}
+
+func boundedQueryRow(ctx context.Context, q Querier, sql string, args ...any) pgx.Row {
+	queryCtx, cancel := withQueryTimeout(ctx)
+	return &boundedRow{row: q.QueryRow(queryCtx, sql, args...), ctx: ctx, cancel: cancel}
+}
+
+func boundedExec(ctx context.Context, q execQuerier, sql string, args ...any) (pgconn.CommandTag, error) {
+	queryCtx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tag, err := q.Exec(queryCtx, sql, args...)
+	return tag, queryErr(ctx, err)
+}
+
+// boundedRows releases the query's timeout once the rows are read or closed
+type boundedRows struct {
+	pgx.Rows
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (r *boundedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	// The rows close themselves once read
+	r.cancel()
+	return false
+}
+
+func (r *boundedRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+func (r *boundedRows) Err() error {
+	return queryErr(r.ctx, r.Rows.Err())
+}
+
+// boundedRow releases the query's timeout once it is scanned
+type boundedRow struct {
+	row    pgx.Row
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (r *boundedRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return queryErr(r.ctx, r.row.Scan(dest...))
+}
+
+// pool returns the pool, with its queries under the query timeout
+func pool() boundedPool {
+	return boundedPool{q: db.DB}
+}
+
+// readPool returns the pool for read-only queries, with its queries under the query
+// timeout
+func readPool() boundedPool {
+	return boundedPool{q: db.GetReadPool()}
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// withTestQueryTimeout sets the query timeout for the rest of a test
+func withTestQueryTimeout(t *testing.T, d time.Duration) {
+	previous := queryTimeout
+	SetQueryTimeout(d)
+	t.Cleanup(func() { SetQueryTimeout(previous) })
+}
+
+func TestQueryTimeout_SlowQueryReturnsErrQueryTimeout(t *testing.T) {
+	withTestQueryTimeout(t, 20*time.Millisecond)
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	mockDB.ExpectQuery(`FROM transactions`).
+		WithArgs(walletID.String(), 20, 0).
+		WillReturnRows(transactionRows(walletID, 1, 1)).
+		WillDelayFor(time.Second)
+
+	start := time.Now()
+	_, _, err = NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), TransactionFilter{}, TransactionSort{}, 20, 0)
+
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestQueryTimeout_SlowQueryRowReturnsErrQueryTimeout(t *testing.T) {
+	withTestQueryTimeout(t, 20*time.Millisecond)
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`FROM transactions\s+WHERE id = \$1`).
+		WithArgs("tx1").
+		WillReturnRows(pgxmock.NewRows(transactionColumnNames)).
+		WillDelayFor(time.Second)
+
+	_, err = NewTransactionRepository(mockDB).GetTransactionByID(context.Background(), "tx1")
+
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+}
+
+func TestQueryTimeout_CallerCancellationIsNotATimeout(t *testing.T) {
+	withTestQueryTimeout(t, time.Second)
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`FROM transactions\s+WHERE id = \$1`).
+		WithArgs("tx1").
+		WillReturnRows(pgxmock.NewRows(transactionColumnNames)).
+		WillDelayFor(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = NewTransactionRepository(mockDB).GetTransactionByID(ctx, "tx1")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrQueryTimeout)
+}
+
+func TestQueryTimeout_BoundTxBoundsStatements(t *testing.T) {
+	withTestQueryTimeout(t, 20*time.Millisecond)
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec(`UPDATE transactions`).
+		WithArgs(models.TransactionStatusCompleted, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1)).
+		WillDelayFor(time.Second)
+
+	tx, err := mockDB.Begin(context.Background())
+	assert.NoError(t, err)
+	err = UpdateTransactionStatusTx(context.Background(), BoundTx(tx), uuid.NewString(), models.TransactionStatusCompleted)
+
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+}
+
+func TestQueryTimeoutFromEnv(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"", DefaultQueryTimeout, false},
+		{"2s", 2 * time.Second, false},
+		{"0", 0, false},
+		{"-1s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			t.Setenv("DATABASE_QUERY_TIMEOUT", tt.raw)
+			d, err := QueryTimeoutFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, d)
+		})
+	}
+}
@@ -2,40 +2,764 @@ package repositories
 
 import (
 	"context"
-	"walletapp/internal/db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// ErrTransactionNotFound is returned when no transaction matches a lookup
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrDuplicateIdempotencyKey is returned when a wallet already has a transaction
+// recorded under the same idempotency key
+var ErrDuplicateIdempotencyKey = errors.New("idempotency key already used")
+
+// ErrTransactionNotPending is returned when changing the status of a transaction that
+// has already been finalized
+var ErrTransactionNotPending = errors.New("transaction is not pending")
+
+// idempotencyKeyIndex is the primary key of transaction_idempotency_keys, which claims
+// each (wallet_id, idempotency_key) once across the monthly partitions of transactions
+const idempotencyKeyIndex = "transaction_idempotency_keys_pkey"
+
+// creditTypes are the transaction types that add their amount to a wallet's balance;
+// every other type takes it away. Adjustments and exchanges carry signed amounts.
+const creditTypes = `('DEPOSIT', 'TRANSFER_IN', 'TRANSFER_REVERSAL_IN', 'ADJUSTMENT', 'EXCHANGE', 'INTEREST', 'BONUS')`
+
+const transactionColumns = `id, wallet_id, type, status, amount, currency, related_user_id, transfer_id, idempotency_key,
+        external_reference, description, metadata, performed_by, exchange_rate, converted_amount, created_at, updated_at`
+
+// CreateTransactionTx records t in the currency of its wallet, which it sets on t. An
+// idempotency key is claimed for the wallet in the same statement, and a key the wallet
+// already used fails with ErrDuplicateIdempotencyKey.
 func CreateTransactionTx(ctx context.Context, tx pgx.Tx, t *models.Transaction) error {
-	return tx.QueryRow(ctx, `
-        INSERT INTO transactions (wallet_id, type, amount, related_user_id, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, NOW(), NOW())
-        RETURNING id, created_at, updated_at
-    `, t.WalletID, t.Type, t.Amount, t.RelatedUserID).
-		Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	metadata, err := marshalMetadata(t.Metadata)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, `
+        WITH idempotency_key AS (
+            INSERT INTO transaction_idempotency_keys (wallet_id, idempotency_key)
+            SELECT $1, $7::varchar WHERE $7::varchar IS NOT NULL
+        )
+        INSERT INTO transactions (wallet_id, type, status, amount, currency, related_user_id, transfer_id, idempotency_key, external_reference, description, metadata, performed_by, exchange_rate, converted_amount, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, (SELECT currency FROM wallets WHERE id = $1), $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW())
+        RETURNING id, currency, created_at, updated_at
+    `, t.WalletID, t.Type, t.Status, t.Amount, t.RelatedUserID, t.TransferID, t.IdempotencyKey, t.ExternalReference, t.Description, metadata, t.PerformedBy, t.ExchangeRate, t.ConvertedAmount).
+		Scan(&t.ID, &t.Currency, &t.CreatedAt, &t.UpdatedAt)
+	var pgErr *pgconn.PgError
+	// 23505 is unique_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == idempotencyKeyIndex {
+		return ErrDuplicateIdempotencyKey
+	}
+	return err
+}
+
+// CreateExternalTransactionTx records t unless a transaction with the same external
+// reference already exists, in which case it returns false and leaves t untouched. When
+// a concurrent transaction has claimed the reference but not yet committed, the claim
+// waits for it to finish, so exactly one of them records the reference.
+func CreateExternalTransactionTx(ctx context.Context, tx pgx.Tx, t *models.Transaction) (bool, error) {
+	metadata, err := marshalMetadata(t.Metadata)
+	if err != nil {
+		return false, err
+	}
+	// The transaction is only inserted when the reference was claimed
+	err = tx.QueryRow(ctx, `
+        WITH reference AS (
+            INSERT INTO transaction_external_references (external_reference, wallet_id)
+            VALUES ($8, $1)
+            ON CONFLICT DO NOTHING
+            RETURNING external_reference
+        )
+        INSERT INTO transactions (wallet_id, type, status, amount, currency, related_user_id, transfer_id, idempotency_key, external_reference, description, metadata, performed_by, exchange_rate, converted_amount, created_at, updated_at)
+        SELECT $1, $2, $3, $4, (SELECT currency FROM wallets WHERE id = $1), $5, $6, $7, reference.external_reference, $9, $10, $11, $12, $13, NOW(), NOW()
+        FROM reference
+        RETURNING id, currency, created_at, updated_at
+    `, t.WalletID, t.Type, t.Status, t.Amount, t.RelatedUserID, t.TransferID, t.IdempotencyKey, t.ExternalReference, t.Description, metadata, t.PerformedBy, t.ExchangeRate, t.ConvertedAmount).
+		Scan(&t.ID, &t.Currency, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetTransactionByID returns a transaction by its ID, or ErrTransactionNotFound
+func (r *PgTransactionRepository) GetTransactionByID(ctx context.Context, id string) (*models.Transaction, error) {
+	t, err := scanTransaction(r.read.QueryRow(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE id = $1
+    `, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTransactionNotFound, id)
+	}
+	return t, err
+}
+
+// GetBalanceAfterTransaction returns the balance of t's wallet right after the
+// operation that recorded t: its current balance less the net of the completed
+// transactions recorded since. Transactions recorded by the same operation, such as a
+// transfer and its fee, share a timestamp and are all counted as before.
+func (r *PgTransactionRepository) GetBalanceAfterTransaction(ctx context.Context, t *models.Transaction) (money.Amount, error) {
+	var balance money.Amount
+	err := r.read.QueryRow(ctx, `
+        SELECT w.balance - COALESCE(SUM(CASE WHEN t.type IN `+creditTypes+` THEN t.amount ELSE -t.amount END), 0)
+        FROM wallets w
+        LEFT JOIN transactions t ON t.wallet_id = w.id AND t.status = 'COMPLETED' AND t.created_at > $2
+        WHERE w.id = $1
+        GROUP BY w.balance
+    `, t.WalletID, t.CreatedAt).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, walletIDNotFound(t.WalletID.String())
+	}
+	return balance, err
+}
+
+// GetTransactionByExternalReferenceTx returns the transaction recorded for a payment
+// provider reference, or ErrTransactionNotFound when it has not been processed
+func GetTransactionByExternalReferenceTx(ctx context.Context, tx pgx.Tx, reference string) (*models.Transaction, error) {
+	t, err := scanTransaction(tx.QueryRow(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE external_reference = $1
+    `, reference))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	return t, err
 }
 
-func GetTransactionsByWalletID(ctx context.Context, walletID string) ([]models.Transaction, error) {
-	rows, err := db.DB.Query(ctx, `
-        SELECT id, wallet_id, type, amount, related_user_id, created_at, updated_at
+// GetTransactionByIdempotencyKeyTx returns the transaction recorded under key for
+// the user's default wallet, or ErrTransactionNotFound when the key has not been used
+func GetTransactionByIdempotencyKeyTx(ctx context.Context, tx pgx.Tx, userID, key string) (*models.Transaction, error) {
+	t, err := scanTransaction(tx.QueryRow(ctx, `
+        SELECT `+transactionColumns+`
         FROM transactions
-        WHERE wallet_id = $1
-        ORDER BY created_at DESC
+        WHERE wallet_id = (SELECT id FROM wallets WHERE user_id = $1 AND is_default) AND idempotency_key = $2
+    `, userID, key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	return t, err
+}
+
+// GetTransactionByWalletIdempotencyKeyTx returns the transaction recorded under key for
+// the wallet, or ErrTransactionNotFound when the key has not been used
+func GetTransactionByWalletIdempotencyKeyTx(ctx context.Context, tx pgx.Tx, walletID, key string) (*models.Transaction, error) {
+	t, err := scanTransaction(tx.QueryRow(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE wallet_id = $1 AND idempotency_key = $2
+    `, walletID, key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	return t, err
+}
+
+// UpdateTransactionStatusTx finalizes a PENDING transaction as COMPLETED or FAILED.
+// Returns ErrTransactionNotPending when the transaction was already finalized, so a
+// pending record can only ever be settled once.
+func UpdateTransactionStatusTx(ctx context.Context, tx pgx.Tx, id string, status models.TransactionStatus) error {
+	tag, err := tx.Exec(ctx, `
+        UPDATE transactions SET status = $1, updated_at = NOW()
+        WHERE id = $2 AND status = 'PENDING'
+    `, status, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM transactions WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrTransactionNotPending
+	}
+	return fmt.Errorf("%w: %s", ErrTransactionNotFound, id)
+}
+
+// TransactionFilter narrows a wallet's transaction history. Its zero value matches
+// every transaction.
+type TransactionFilter struct {
+	Status models.TransactionStatus
+	// Types matches transactions of any of the types
+	Types []models.TransactionType
+	// From and To match transactions created at or after From and at or before To
+	From time.Time
+	To   time.Time
+	// MinAmount and MaxAmount, when set, match transactions whose amount is at least
+	// MinAmount and at most MaxAmount. Signed amounts are compared by their size.
+	MinAmount *money.Amount
+	MaxAmount *money.Amount
+	// MetadataKey and MetadataValue match transactions whose metadata maps the key to
+	// the value
+	MetadataKey   string
+	MetadataValue string
+	// IncludeArchived also matches the transactions moved to transactions_archive
+	IncludeArchived bool
+}
+
+// source returns the table the transactions matching f are read from, under the name
+// transactions
+func (f TransactionFilter) source() string {
+	if f.IncludeArchived {
+		return allTransactions + " transactions"
+	}
+	return "transactions"
+}
+
+// where returns the condition selecting the transactions of walletID that match f,
+// and its arguments, numbered from $1
+func (f TransactionFilter) where(walletID string) (string, []any) {
+	conds := []string{"wallet_id = $1"}
+	args := []any{walletID}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if len(f.Types) > 0 {
+		names := make([]string, len(f.Types))
+		for i, t := range f.Types {
+			names[i] = string(t)
+		}
+		args = append(args, names)
+		conds = append(conds, fmt.Sprintf("type = ANY($%d)", len(args)))
+	}
+	// Bounds on created_at let Postgres skip the monthly partitions outside them
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d::timestamptz", len(args)))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		conds = append(conds, fmt.Sprintf("created_at <= $%d::timestamptz", len(args)))
+	}
+	if f.MinAmount != nil {
+		args = append(args, *f.MinAmount)
+		conds = append(conds, fmt.Sprintf("ABS(amount) >= $%d", len(args)))
+	}
+	if f.MaxAmount != nil {
+		args = append(args, *f.MaxAmount)
+		conds = append(conds, fmt.Sprintf("ABS(amount) <= $%d", len(args)))
+	}
+	if f.MetadataKey != "" {
+		args = append(args, f.MetadataKey, f.MetadataValue)
+		conds = append(conds, fmt.Sprintf("metadata @> jsonb_build_object($%d::text, $%d::text)", len(args)-1, len(args)))
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// ErrInvalidSort is returned when parsing a sort on a field history cannot be sorted by
+var ErrInvalidSort = errors.New("invalid sort")
+
+// sortColumns maps the fields transaction history can be sorted by to their columns.
+// Only these columns are ever written into ORDER BY.
+var sortColumns = map[string]string{
+	"created_at": "created_at",
+	"amount":     "amount",
+}
+
+// TransactionSort orders a wallet's transaction history. Its zero value orders it
+// newest first.
+type TransactionSort struct {
+	// Field is a key of sortColumns, or empty for created_at
+	Field string
+	Asc   bool
+}
+
+// ParseTransactionSort parses a sort of the form field or field:asc or field:desc. The
+// direction defaults to descending. It returns ErrInvalidSort for fields that are not
+// sortable and unknown directions.
+func ParseTransactionSort(s string) (TransactionSort, error) {
+	field, dir, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	if _, ok := sortColumns[field]; !ok {
+		return TransactionSort{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, field)
+	}
+	switch dir {
+	case "", "desc":
+		return TransactionSort{Field: field}, nil
+	case "asc":
+		return TransactionSort{Field: field, Asc: true}, nil
+	}
+	return TransactionSort{}, fmt.Errorf("%w: direction must be asc or desc", ErrInvalidSort)
+}
+
+// IsDefault reports whether s orders history newest first
+func (s TransactionSort) IsDefault() bool {
+	return (s.Field == "" || s.Field == "created_at") && !s.Asc
+}
+
+// orderBy returns the ORDER BY list for s. id breaks ties between transactions with the
+// same value, so pages neither repeat nor skip rows.
+func (s TransactionSort) orderBy() string {
+	column, ok := sortColumns[s.Field]
+	if !ok {
+		column = "created_at"
+	}
+	dir := "DESC"
+	if s.Asc {
+		dir = "ASC"
+	}
+	return fmt.Sprintf("%s %s, id %s", column, dir, dir)
+}
+
+// GetTransactionsByWalletID returns a page of a wallet's transactions matching filter,
+// ordered by sort: at most limit of them, after skipping offset. It also returns how
+// many transactions match filter in all.
+func (r *PgTransactionRepository) GetTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter, sort TransactionSort, limit, offset int) ([]models.Transaction, int, error) {
+	where, args := filter.where(walletID)
+	// The window counts the matches in the same round trip
+	rows, err := r.read.Query(ctx, fmt.Sprintf(`
+        SELECT %s, COUNT(*) OVER ()
+        FROM %s
+        WHERE %s
+        ORDER BY %s
+        LIMIT $%d OFFSET $%d
+    `, transactionColumns, filter.source(), where, sort.orderBy(), len(args)+1, len(args)+2), append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	txs, total, err := scanTransactionPage(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	// A page past the end has no rows to carry the count
+	if len(txs) == 0 && offset > 0 {
+		total, err = r.CountTransactionsByWalletID(ctx, walletID, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return txs, total, nil
+}
+
+// GetTransactionsAfterCursor returns up to limit of a wallet's transactions matching
+// filter that come after the one created at cursorTime with ID cursorID, newest first.
+// Unlike an offset, the cursor keeps its place when transactions are recorded between
+// pages. It also returns how many matching transactions come after the cursor in all.
+func (r *PgTransactionRepository) GetTransactionsAfterCursor(ctx context.Context, walletID string, filter TransactionFilter, cursorTime time.Time, cursorID string, limit int) ([]models.Transaction, int, error) {
+	where, args := filter.where(walletID)
+	rows, err := r.read.Query(ctx, fmt.Sprintf(`
+        SELECT %s, COUNT(*) OVER ()
+        FROM %s
+        WHERE %s AND (created_at, id) < ($%d, $%d::uuid)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $%d
+    `, transactionColumns, filter.source(), where, len(args)+1, len(args)+2, len(args)+3), append(args, cursorTime, cursorID, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scanTransactionPage(rows)
+}
+
+// StreamTransactionsByWalletID calls fn with each of a wallet's transactions matching
+// filter, oldest first, as it is read, so a history of any length is gone through
+// without holding it in memory. It stops at the first error fn returns and returns it.
+// The query timeout does not apply, since the rows are read at the pace of fn; ctx
+// bounds the stream instead.
+func (r *PgTransactionRepository) StreamTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter, fn func(models.Transaction) error) error {
+	where, args := filter.where(walletID)
+	rows, err := unbounded(r.read).Query(ctx, fmt.Sprintf(`
+        SELECT %s
+        FROM %s
+        WHERE %s
+        ORDER BY created_at, id
+    `, transactionColumns, filter.source(), where), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CountTransactionsByWalletID counts a wallet's transactions matching filter
+func (r *PgTransactionRepository) CountTransactionsByWalletID(ctx context.Context, walletID string, filter TransactionFilter) (int, error) {
+	where, args := filter.where(walletID)
+	var count int
+	err := r.read.QueryRow(ctx, `SELECT COUNT(*) FROM `+filter.source()+` WHERE `+where, args...).Scan(&count)
+	return count, err
+}
+
+// GetTransactionsByTransferID returns both legs of a transfer
+func (r *PgTransactionRepository) GetTransactionsByTransferID(ctx context.Context, transferID string) ([]models.Transaction, error) {
+	rows, err := r.read.Query(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE transfer_id = $1
+        ORDER BY created_at, type
+    `, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// GetTransactionsByTransferIDTx returns every transaction recorded for a transfer,
+// including its fee, within a transaction
+func GetTransactionsByTransferIDTx(ctx context.Context, tx pgx.Tx, transferID string) ([]models.Transaction, error) {
+	rows, err := tx.Query(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE transfer_id = $1
+        ORDER BY created_at, type
+    `, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// SumTransactionsSince adds up the amounts of a wallet's transactions of the given
+// types created at or after since, leaving out failed ones, within a transaction
+func SumTransactionsSince(ctx context.Context, tx pgx.Tx, walletID string, types []models.TransactionType, since time.Time) (money.Amount, error) {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	// created_at has no time zone, so since is compared as an instant in the
+	// session's time zone, the one NOW() used when the rows were recorded
+	var sum money.Amount
+	err := tx.QueryRow(ctx, `
+        SELECT COALESCE(SUM(amount), 0)
+        FROM transactions
+        WHERE wallet_id = $1 AND type = ANY($2) AND status <> 'FAILED' AND created_at >= $3::timestamptz
+    `, walletID, names, since).Scan(&sum)
+	if err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// CountTransactionsSince counts a wallet's transactions of the given types created at
+// or after since, leaving out failed ones, within a transaction. It also returns when
+// the oldest of them was created, or nil when there are none.
+func CountTransactionsSince(ctx context.Context, tx pgx.Tx, walletID string, types []models.TransactionType, since time.Time) (int, *time.Time, error) {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	var count int
+	var oldest *time.Time
+	err := tx.QueryRow(ctx, `
+        SELECT COUNT(*), MIN(created_at)::timestamptz
+        FROM transactions
+        WHERE wallet_id = $1 AND type = ANY($2) AND status <> 'FAILED' AND created_at >= $3::timestamptz
+    `, walletID, names, since).Scan(&count, &oldest)
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, oldest, nil
+}
+
+// SumUserTransactionsSince adds up the amounts of the transactions of the given types
+// created at or after since across all of a user's wallets, leaving out failed ones,
+// within a transaction
+func SumUserTransactionsSince(ctx context.Context, tx pgx.Tx, userID string, types []models.TransactionType, since time.Time) (money.Amount, error) {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	var sum money.Amount
+	err := tx.QueryRow(ctx, `
+        SELECT COALESCE(SUM(t.amount), 0)
+        FROM transactions t
+        JOIN wallets w ON w.id = t.wallet_id
+        WHERE w.user_id = $1 AND t.type = ANY($2) AND t.status <> 'FAILED' AND t.created_at >= $3::timestamptz
+    `, userID, names, since).Scan(&sum)
+	if err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// CountNewRecipientsSince counts the distinct users a wallet transferred money to at
+// or after since that it had never transferred to before, within a transaction
+func CountNewRecipientsSince(ctx context.Context, tx pgx.Tx, walletID string, since time.Time) (int, error) {
+	var count int
+	err := tx.QueryRow(ctx, `
+        SELECT COUNT(DISTINCT t.related_user_id)
+        FROM transactions t
+        WHERE t.wallet_id = $1 AND t.type = 'TRANSFER_OUT' AND t.status <> 'FAILED' AND t.created_at >= $2::timestamptz
+          AND NOT EXISTS (
+              SELECT 1 FROM transactions earlier
+              WHERE earlier.wallet_id = t.wallet_id AND earlier.type = 'TRANSFER_OUT' AND earlier.status <> 'FAILED'
+                AND earlier.related_user_id = t.related_user_id AND earlier.created_at < $2::timestamptz
+          )
+    `, walletID, since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetTransactionSummary totals a wallet's completed transactions created at or after
+// from and before to, per type, in one aggregate query. The closing balance is the
+// wallet's balance less the net of its transactions since to, and the opening balance
+// is the closing balance less the net of the period; both are read in the same
+// statement as the totals, so they agree with them. Archived transactions count, so
+// periods past the retention are totalled too. Returns ErrWalletNotFound when the
+// wallet does not exist.
+func (r *PgTransactionRepository) GetTransactionSummary(ctx context.Context, walletID string, from, to time.Time) (*models.WalletSummary, error) {
+	rows, err := r.read.Query(ctx, `
+        SELECT w.balance, t.type,
+               COUNT(*) FILTER (WHERE t.created_at < $3::timestamptz),
+               COALESCE(SUM(t.amount) FILTER (WHERE t.created_at < $3::timestamptz), 0),
+               COALESCE(SUM(CASE WHEN t.type IN `+creditTypes+` THEN t.amount ELSE -t.amount END) FILTER (WHERE t.created_at < $3::timestamptz), 0),
+               COALESCE(SUM(CASE WHEN t.type IN `+creditTypes+` THEN t.amount ELSE -t.amount END) FILTER (WHERE t.created_at >= $3::timestamptz), 0)
+        FROM wallets w
+        LEFT JOIN `+allTransactions+` t ON t.wallet_id = w.id AND t.status = 'COMPLETED' AND t.created_at >= $2::timestamptz
+        WHERE w.id = $1
+        GROUP BY w.balance, t.type
+        ORDER BY t.type
+    `, walletID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := &models.WalletSummary{ByType: []models.TransactionTypeTotal{}}
+	var balance, since money.Amount
+	found := false
+	for rows.Next() {
+		var txType *models.TransactionType
+		var total models.TransactionTypeTotal
+		var net, after money.Amount
+		if err := rows.Scan(&balance, &txType, &total.Count, &total.Total, &net, &after); err != nil {
+			return nil, err
+		}
+		found = true
+		since += after
+		summary.NetChange += net
+		if txType == nil || total.Count == 0 {
+			// No transactions at all, or only ones after the period
+			continue
+		}
+		total.Type = *txType
+		summary.TransactionCount += total.Count
+		summary.ByType = append(summary.ByType, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	summary.ClosingBalance = balance - since
+	summary.OpeningBalance = summary.ClosingBalance - summary.NetChange
+	return summary, nil
+}
+
+// GetStatementTransactions returns a page of a wallet's completed transactions created
+// at or after from and before to, oldest first, archived ones included: at most limit
+// of them, after skipping offset. It also returns how many there are in all.
+func (r *PgTransactionRepository) GetStatementTransactions(ctx context.Context, walletID string, from, to time.Time, limit, offset int) ([]models.Transaction, int, error) {
+	// The window counts the transactions in the same round trip
+	rows, err := r.read.Query(ctx, `
+        SELECT `+transactionColumns+`, COUNT(*) OVER ()
+        FROM `+allTransactions+` transactions
+        WHERE wallet_id = $1 AND status = 'COMPLETED'
+          AND created_at >= $2::timestamptz AND created_at < $3::timestamptz
+        ORDER BY created_at, id
+        LIMIT $4 OFFSET $5
+    `, walletID, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	txs, total, err := scanTransactionPage(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	// A page past the end has no rows to carry the count
+	if len(txs) == 0 && offset > 0 {
+		err = r.read.QueryRow(ctx, `
+            SELECT COUNT(*)
+            FROM `+allTransactions+` transactions
+            WHERE wallet_id = $1 AND status = 'COMPLETED'
+              AND created_at >= $2::timestamptz AND created_at < $3::timestamptz
+        `, walletID, from, to).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return txs, total, nil
+}
+
+// GetWalletTotals totals a wallet's completed transactions over its whole life, per
+// type, archived transactions included, in one aggregate query. The legs of a reversed transfer, its fee and the
+// reversal legs themselves are left out, so a reversal takes back what the transfer
+// added to each total. Returns ErrWalletNotFound when the wallet does not exist.
+func (r *PgTransactionRepository) GetWalletTotals(ctx context.Context, walletID string) (*models.WalletTotals, error) {
+	rows, err := r.read.Query(ctx, `
+        SELECT t.type, COUNT(t.id), COALESCE(SUM(t.amount), 0)
+        FROM wallets w
+        LEFT JOIN `+allTransactions+` t ON t.wallet_id = w.id AND t.status = 'COMPLETED'
+            AND t.type NOT IN ('TRANSFER_REVERSAL_IN', 'TRANSFER_REVERSAL_OUT')
+            AND NOT EXISTS (
+                SELECT 1 FROM `+allTransactions+` r
+                WHERE r.wallet_id = w.id AND r.transfer_id = t.transfer_id AND r.status = 'COMPLETED'
+                  AND r.type IN ('TRANSFER_REVERSAL_IN', 'TRANSFER_REVERSAL_OUT')
+            )
+        WHERE w.id = $1
+        GROUP BY t.type
+        ORDER BY t.type
     `, walletID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	totals := &models.WalletTotals{ByType: []models.TransactionTypeTotal{}}
+	found := false
+	for rows.Next() {
+		var txType *models.TransactionType
+		var total models.TransactionTypeTotal
+		if err := rows.Scan(&txType, &total.Count, &total.Total); err != nil {
+			return nil, err
+		}
+		found = true
+		if txType == nil {
+			// The wallet has no transactions to total
+			continue
+		}
+		total.Type = *txType
+		totals.TransactionCount += total.Count
+		totals.ByType = append(totals.ByType, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	return totals, nil
+}
+
+// CreateTransactionPartition makes the monthly partition of transactions for the
+// month of the given day unless it exists, and returns its name. Transactions of that
+// month that were recorded into the default partition move into it.
+func (r *PgTransactionRepository) CreateTransactionPartition(ctx context.Context, month time.Time) (string, error) {
+	var name string
+	err := r.q.QueryRow(ctx, `SELECT create_transactions_partition($1::date)`, month.Format(time.DateOnly)).Scan(&name)
+	return name, err
+}
+
+func scanTransactions(rows pgx.Rows) ([]models.Transaction, error) {
 	var txs []models.Transaction
 	for rows.Next() {
-		var tx models.Transaction
-		if err := rows.Scan(&tx.ID, &tx.WalletID, &tx.Type, &tx.Amount, &tx.RelatedUserID, &tx.CreatedAt, &tx.UpdatedAt); err != nil {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, *tx)
+	}
+	return txs, rows.Err()
+}
+
+// scanTransactionPage scans a page of transactions selected with a trailing
+// COUNT(*) OVER () column, and returns the count with them
+func scanTransactionPage(rows pgx.Rows) ([]models.Transaction, int, error) {
+	defer rows.Close()
+
+	var txs []models.Transaction
+	var total int
+	for rows.Next() {
+		tx, err := scanTransaction(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		txs = append(txs, *tx)
+	}
+	return txs, total, rows.Err()
+}
+
+// scanTransaction scans the transactionColumns of row, followed by any extra columns
+// into extra
+func scanTransaction(row pgx.Row, extra ...any) (*models.Transaction, error) {
+	var t models.Transaction
+	var metadata []byte
+	dest := []any{&t.ID, &t.WalletID, &t.Type, &t.Status, &t.Amount, &t.Currency, &t.RelatedUserID, &t.TransferID, &t.IdempotencyKey,
+		&t.ExternalReference, &t.Description, &metadata, &t.PerformedBy, &t.ExchangeRate, &t.ConvertedAmount, &t.CreatedAt, &t.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &t.Metadata); err != nil {
+			return nil, fmt.Errorf("decode metadata of transaction %s: %w", t.ID, err)
+		}
+	}
+	return &t, nil
+}
+
+// marshalMetadata encodes transaction metadata for the JSONB column. Empty metadata
+// is stored as NULL.
+func marshalMetadata(metadata map[string]string) ([]byte, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(metadata)
+}
+
+// GetBalanceDiscrepancies compares every wallet's balance with the net of its
+// completed transactions (deposits, incoming transfers, reversals returning money,
+// signed adjustments, exchange legs, interest and bonuses, minus everything else),
+// archived ones included, in one aggregate query and returns the wallets that disagree
+func (r *PgTransactionRepository) GetBalanceDiscrepancies(ctx context.Context) ([]models.Discrepancy, error) {
+	rows, err := r.read.Query(ctx, `
+        SELECT w.id, w.user_id, w.balance, COALESCE(t.net, 0)
+        FROM wallets w
+        LEFT JOIN (
+            SELECT wallet_id,
+                   SUM(CASE WHEN type IN `+creditTypes+` THEN amount ELSE -amount END) AS net
+            FROM `+allTransactions+` transactions
+            WHERE status = 'COMPLETED'
+            GROUP BY wallet_id
+        ) t ON t.wallet_id = w.id
+        WHERE w.balance <> COALESCE(t.net, 0)
+        ORDER BY w.id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []models.Discrepancy
+	for rows.Next() {
+		var d models.Discrepancy
+		if err := rows.Scan(&d.WalletID, &d.UserID, &d.Balance, &d.TransactionBalance); err != nil {
 			return nil, err
 		}
-		txs = append(txs, tx)
+		d.Delta = d.Balance - d.TransactionBalance
+		discrepancies = append(discrepancies, d)
 	}
-	return txs, nil
+	return discrepancies, rows.Err()
 }
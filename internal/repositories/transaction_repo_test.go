@@ -0,0 +1,516 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// transactionColumnNames are the columns scanTransaction reads
+var transactionColumnNames = []string{"id", "wallet_id", "type", "status", "amount", "currency", "related_user_id", "transfer_id",
+	"idempotency_key", "external_reference", "description", "metadata", "performed_by", "exchange_rate", "converted_amount",
+	"created_at", "updated_at"}
+
+// transactionRows returns n completed deposits into walletID, newest first, each
+// carrying total as its window count
+func transactionRows(walletID uuid.UUID, n, total int) *pgxmock.Rows {
+	rows := pgxmock.NewRows(append(slices.Clone(transactionColumnNames), "count"))
+	now := time.Now()
+	for i := range n {
+		createdAt := now.Add(-time.Duration(i) * time.Minute)
+		rows.AddRow(uuid.New(), walletID, models.TransactionTypeDeposit, models.TransactionStatusCompleted, "10.00", "USD", nil, nil,
+			nil, nil, nil, nil, nil, nil, nil, createdAt, createdAt, total)
+	}
+	return rows
+}
+
+func TestGetTransactionsByWalletID_FetchesOnlyThePage(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	mockDB.ExpectQuery(`COUNT\(\*\) OVER \(\)\s+FROM transactions\s+WHERE wallet_id = \$1 AND status = \$2\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3 OFFSET \$4`).
+		WithArgs(walletID.String(), models.TransactionStatusCompleted, 20, 40).
+		WillReturnRows(transactionRows(walletID, 20, 75))
+
+	filter := TransactionFilter{Status: models.TransactionStatusCompleted}
+	txs, total, err := NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), filter, TransactionSort{}, 20, 40)
+
+	assert.NoError(t, err)
+	assert.Len(t, txs, 20)
+	assert.Equal(t, 75, total)
+	for _, tx := range txs {
+		assert.Equal(t, walletID, tx.WalletID)
+	}
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestGetTransactionsByWalletID_MetadataFilter(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	mockDB.ExpectQuery(`WHERE wallet_id = \$1 AND metadata @> jsonb_build_object\(\$2::text, \$3::text\)\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$4 OFFSET \$5`).
+		WithArgs(walletID.String(), "order_id", "ord_1", 5, 0).
+		WillReturnRows(transactionRows(walletID, 5, 5))
+
+	filter := TransactionFilter{MetadataKey: "order_id", MetadataValue: "ord_1"}
+	txs, total, err := NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), filter, TransactionSort{}, 5, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, txs, 5)
+	assert.Equal(t, 5, total)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestGetTransactionsByWalletID_CountsPastTheEnd(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	mockDB.ExpectQuery(`LIMIT \$2 OFFSET \$3`).
+		WithArgs(walletID.String(), 20, 100).
+		WillReturnRows(transactionRows(walletID, 0, 0))
+	mockDB.ExpectQuery(`SELECT COUNT\(\*\) FROM transactions WHERE wallet_id = \$1$`).
+		WithArgs(walletID.String()).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(42))
+
+	txs, total, err := NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), TransactionFilter{}, TransactionSort{}, 20, 100)
+
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+	assert.Equal(t, 42, total)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestGetTransactionsByWalletID_IncludeArchived(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	from := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	mockDB.ExpectQuery(`FROM \( SELECT .+ FROM transactions UNION ALL SELECT .+ FROM transactions_archive \) transactions\s+WHERE wallet_id = \$1 AND created_at >= \$2::timestamptz\s+ORDER BY`).
+		WithArgs(walletID.String(), from, 20, 0).
+		WillReturnRows(transactionRows(walletID, 3, 3))
+
+	filter := TransactionFilter{From: from, IncludeArchived: true}
+	txs, total, err := NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), filter, TransactionSort{}, 20, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, txs, 3)
+	assert.Equal(t, 3, total)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestGetTransactionsAfterCursor_SeeksPastTheCursor(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID, cursorID := uuid.New(), uuid.New()
+	cursorTime := time.Date(2024, time.March, 15, 9, 30, 0, 123456000, time.UTC)
+	mockDB.ExpectQuery(`WHERE wallet_id = \$1 AND \(created_at, id\) < \(\$2, \$3::uuid\)\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$4`).
+		WithArgs(walletID.String(), cursorTime, cursorID.String(), 10).
+		WillReturnRows(transactionRows(walletID, 3, 3))
+
+	txs, remaining, err := NewTransactionRepository(mockDB).GetTransactionsAfterCursor(context.Background(), walletID.String(), TransactionFilter{}, cursorTime, cursorID.String(), 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, txs, 3)
+	assert.Equal(t, 3, remaining)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+// streamRows returns n completed deposits into walletID, oldest first, with the IDs
+// they carry in order
+func streamRows(walletID uuid.UUID, n int) (*pgxmock.Rows, []uuid.UUID) {
+	rows := pgxmock.NewRows(transactionColumnNames)
+	ids := make([]uuid.UUID, n)
+	start := time.Now().Add(-time.Duration(n) * time.Minute)
+	for i := range n {
+		ids[i] = uuid.New()
+		createdAt := start.Add(time.Duration(i) * time.Minute)
+		rows.AddRow(ids[i], walletID, models.TransactionTypeDeposit, models.TransactionStatusCompleted, "10.00", "USD", nil, nil,
+			nil, nil, nil, nil, nil, nil, nil, createdAt, createdAt)
+	}
+	return rows, ids
+}
+
+func TestStreamTransactionsByWalletID_CallsFnPerRow(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	rows, ids := streamRows(walletID, 3000)
+	mockDB.ExpectQuery(`FROM transactions\s+WHERE wallet_id = \$1 AND status = \$2\s+ORDER BY created_at, id\s*$`).
+		WithArgs(walletID.String(), models.TransactionStatusCompleted).
+		WillReturnRows(rows)
+
+	var seen []uuid.UUID
+	filter := TransactionFilter{Status: models.TransactionStatusCompleted}
+	err = NewTransactionRepository(mockDB).StreamTransactionsByWalletID(context.Background(), walletID.String(), filter, func(tx models.Transaction) error {
+		assert.Equal(t, walletID, tx.WalletID)
+		seen = append(seen, tx.ID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, ids, seen)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestStreamTransactionsByWalletID_StopsAtFnError(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	rows, _ := streamRows(walletID, 3000)
+	mockDB.ExpectQuery(`ORDER BY created_at, id`).
+		WithArgs(walletID.String()).
+		WillReturnRows(rows).
+		RowsWillBeClosed()
+
+	errStop := errors.New("client went away")
+	calls := 0
+	err = NewTransactionRepository(mockDB).StreamTransactionsByWalletID(context.Background(), walletID.String(), TransactionFilter{}, func(models.Transaction) error {
+		calls++
+		if calls == 10 {
+			return errStop
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 10, calls)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestStreamTransactionsByWalletID_OutlastsQueryTimeout(t *testing.T) {
+	withTestQueryTimeout(t, 20*time.Millisecond)
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	rows, _ := streamRows(walletID, 3)
+	mockDB.ExpectQuery(`ORDER BY created_at, id`).
+		WithArgs(walletID.String()).
+		WillReturnRows(rows).
+		WillDelayFor(50 * time.Millisecond)
+
+	calls := 0
+	err = NewTransactionRepository(mockDB).StreamTransactionsByWalletID(context.Background(), walletID.String(), TransactionFilter{}, func(models.Transaction) error {
+		calls++
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestTransactionFilter_Where(t *testing.T) {
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.March, 31, 23, 59, 59, 0, time.UTC)
+	types := []models.TransactionType{models.TransactionTypeDeposit, models.TransactionTypeWithdraw}
+	exact := money.MustParse("500.00")
+
+	tests := []struct {
+		name      string
+		filter    TransactionFilter
+		wantWhere string
+		wantArgs  []any
+	}{
+		{"no filter", TransactionFilter{}, "wallet_id = $1", []any{"w"}},
+		{"types", TransactionFilter{Types: types}, "wallet_id = $1 AND type = ANY($2)", []any{"w", []string{"DEPOSIT", "WITHDRAW"}}},
+		{"from", TransactionFilter{From: from}, "wallet_id = $1 AND created_at >= $2::timestamptz", []any{"w", from}},
+		{"to", TransactionFilter{To: to}, "wallet_id = $1 AND created_at <= $2::timestamptz", []any{"w", to}},
+		{"min amount", TransactionFilter{MinAmount: &exact}, "wallet_id = $1 AND ABS(amount) >= $2", []any{"w", exact}},
+		{"max amount", TransactionFilter{MaxAmount: &exact}, "wallet_id = $1 AND ABS(amount) <= $2", []any{"w", exact}},
+		{
+			"exact amount",
+			TransactionFilter{MinAmount: &exact, MaxAmount: &exact},
+			"wallet_id = $1 AND ABS(amount) >= $2 AND ABS(amount) <= $3",
+			[]any{"w", exact, exact},
+		},
+		{
+			"everything",
+			TransactionFilter{
+				Status: models.TransactionStatusCompleted, Types: types[:1], From: from, To: to,
+				MinAmount: &exact, MaxAmount: &exact, MetadataKey: "k", MetadataValue: "v",
+			},
+			"wallet_id = $1 AND status = $2 AND type = ANY($3) AND created_at >= $4::timestamptz AND created_at <= $5::timestamptz" +
+				" AND ABS(amount) >= $6 AND ABS(amount) <= $7 AND metadata @> jsonb_build_object($8::text, $9::text)",
+			[]any{"w", models.TransactionStatusCompleted, []string{"DEPOSIT"}, from, to, exact, exact, "k", "v"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.filter.where("w")
+			assert.Equal(t, tt.wantWhere, where)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestGetTransactionsByWalletID_FiltersWithPagination(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	mockDB.ExpectQuery(`WHERE wallet_id = \$1 AND type = ANY\(\$2\) AND created_at >= \$3::timestamptz AND created_at <= \$4::timestamptz\s+`+
+		`ORDER BY created_at DESC, id DESC\s+LIMIT \$5 OFFSET \$6`).
+		WithArgs(walletID.String(), []string{"DEPOSIT", "WITHDRAW"}, from, to, 10, 20).
+		WillReturnRows(transactionRows(walletID, 10, 35))
+
+	filter := TransactionFilter{
+		Types: []models.TransactionType{models.TransactionTypeDeposit, models.TransactionTypeWithdraw},
+		From:  from,
+		To:    to,
+	}
+	txs, total, err := NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), filter, TransactionSort{}, 10, 20)
+
+	assert.NoError(t, err)
+	assert.Len(t, txs, 10)
+	assert.Equal(t, 35, total)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestParseTransactionSort(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    TransactionSort
+		wantErr bool
+	}{
+		{"created_at", TransactionSort{Field: "created_at"}, false},
+		{"created_at:asc", TransactionSort{Field: "created_at", Asc: true}, false},
+		{"amount:desc", TransactionSort{Field: "amount"}, false},
+		{"AMOUNT:ASC", TransactionSort{Field: "amount", Asc: true}, false},
+		{"status", TransactionSort{}, true},
+		{"amount; DROP TABLE transactions", TransactionSort{}, true},
+		{"amount:sideways", TransactionSort{}, true},
+		{"", TransactionSort{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, err := ParseTransactionSort(tt.sort)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSort)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetTransactionsByWalletID_OrdersBySort(t *testing.T) {
+	tests := []struct {
+		sort    TransactionSort
+		orderBy string
+	}{
+		{TransactionSort{}, `ORDER BY created_at DESC, id DESC`},
+		{TransactionSort{Field: "created_at", Asc: true}, `ORDER BY created_at ASC, id ASC`},
+		{TransactionSort{Field: "amount"}, `ORDER BY amount DESC, id DESC`},
+		{TransactionSort{Field: "amount", Asc: true}, `ORDER BY amount ASC, id ASC`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.orderBy, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			mockDB.ExpectQuery(tt.orderBy+`\s+LIMIT \$2 OFFSET \$3`).
+				WithArgs(walletID.String(), 10, 0).
+				WillReturnRows(transactionRows(walletID, 2, 2))
+
+			txs, _, err := NewTransactionRepository(mockDB).GetTransactionsByWalletID(context.Background(), walletID.String(), TransactionFilter{}, tt.sort, 10, 0)
+
+			assert.NoError(t, err)
+			assert.Len(t, txs, 2)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTransactionRepository_GetTransactionByID(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID, found, missing := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	repo := NewTransactionRepository(mockDB)
+	mockDB.ExpectQuery(`FROM transactions\s+WHERE id = \$1`).
+		WithArgs(found.String()).
+		WillReturnRows(pgxmock.NewRows(transactionColumnNames).AddRow(found, walletID, models.TransactionTypeDeposit,
+			models.TransactionStatusCompleted, "10.00", "USD", nil, nil, nil, nil, nil, nil, nil, nil, nil, now, now))
+	mockDB.ExpectQuery(`FROM transactions\s+WHERE id = \$1`).
+		WithArgs(missing.String()).
+		WillReturnRows(pgxmock.NewRows(transactionColumnNames))
+
+	tx, err := repo.GetTransactionByID(context.Background(), found.String())
+	assert.NoError(t, err)
+	assert.Equal(t, found, tx.ID)
+	assert.Equal(t, walletID, tx.WalletID)
+
+	tx, err = repo.GetTransactionByID(context.Background(), missing.String())
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+	assert.Nil(t, tx)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestTransactionRepository_GetWalletTotals(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	deposit, fee := models.TransactionTypeDeposit, models.TransactionTypeFee
+	mockDB.ExpectQuery(`FROM wallets w\s+LEFT JOIN \(.+transactions_archive\s*\) t .+NOT EXISTS .+GROUP BY t.type`).
+		WithArgs("wallet1").
+		WillReturnRows(pgxmock.NewRows([]string{"type", "count", "sum"}).
+			AddRow(&deposit, 2, money.MustParse("250.00")).
+			AddRow(&fee, 1, money.MustParse("0.40")))
+	// A wallet without transactions has one row with no type, an unknown wallet none
+	mockDB.ExpectQuery(`FROM wallets w`).WithArgs("empty").
+		WillReturnRows(pgxmock.NewRows([]string{"type", "count", "sum"}).AddRow(nil, 0, money.Amount(0)))
+	mockDB.ExpectQuery(`FROM wallets w`).WithArgs("missing").
+		WillReturnRows(pgxmock.NewRows([]string{"type", "count", "sum"}))
+
+	repo := NewTransactionRepository(mockDB)
+	totals, err := repo.GetWalletTotals(context.Background(), "wallet1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, totals.TransactionCount)
+	assert.Equal(t, []models.TransactionTypeTotal{
+		{Type: deposit, Count: 2, Total: money.MustParse("250.00")},
+		{Type: fee, Count: 1, Total: money.MustParse("0.40")},
+	}, totals.ByType)
+
+	totals, err = repo.GetWalletTotals(context.Background(), "empty")
+	assert.NoError(t, err)
+	assert.Empty(t, totals.ByType)
+	assert.Zero(t, totals.TransactionCount)
+
+	_, err = repo.GetWalletTotals(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestTransactionRepository_GetStatementTransactions(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New()
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	mockDB.ExpectQuery(`status = 'COMPLETED'\s+AND created_at >= \$2::timestamptz AND created_at < \$3::timestamptz\s+ORDER BY created_at, id\s+LIMIT \$4 OFFSET \$5`).
+		WithArgs(walletID.String(), from, to, 10, 0).
+		WillReturnRows(transactionRows(walletID, 10, 12))
+	// A page past the end counts the month apart
+	mockDB.ExpectQuery(`LIMIT \$4 OFFSET \$5`).
+		WithArgs(walletID.String(), from, to, 10, 20).
+		WillReturnRows(transactionRows(walletID, 0, 0))
+	mockDB.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM \(.+FROM transactions_archive \) transactions WHERE`).
+		WithArgs(walletID.String(), from, to).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(12))
+
+	repo := NewTransactionRepository(mockDB)
+	txs, total, err := repo.GetStatementTransactions(context.Background(), walletID.String(), from, to, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, txs, 10)
+	assert.Equal(t, 12, total)
+
+	txs, total, err = repo.GetStatementTransactions(context.Background(), walletID.String(), from, to, 10, 20)
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+	assert.Equal(t, 12, total)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestTransactionRepository_CreateTransactionPartition(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`SELECT create_transactions_partition\(\$1::date\)`).
+		WithArgs("2024-03-15").
+		WillReturnRows(pgxmock.NewRows([]string{"name"}).AddRow("transactions_2024_03"))
+
+	name, err := NewTransactionRepository(mockDB).CreateTransactionPartition(context.Background(), time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, "transactions_2024_03", name)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestArchiveTransactionsTx_MovesBatchAndCountsIt(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	runID := uuid.NewString()
+	cutoff := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mockDB.ExpectBegin()
+	mockDB.ExpectQuery(`FOR UPDATE SKIP LOCKED .+DELETE FROM transactions t USING batch .+INSERT INTO transactions_archive`).
+		WithArgs(cutoff, 1000).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(640)))
+	mockDB.ExpectExec(`UPDATE transaction_archive_runs SET archived = archived \+ \$2 WHERE id = \$1`).
+		WithArgs(runID, int64(640)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	tx, err := mockDB.Begin(context.Background())
+	assert.NoError(t, err)
+	moved, err := ArchiveTransactionsTx(context.Background(), tx, runID, cutoff, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(640), moved)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestTransactionRepository_ArchiveRuns(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	runID := uuid.New()
+	cutoff := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	startedAt := time.Now()
+	columns := []string{"id", "cutoff", "status", "archived", "error", "started_at", "finished_at"}
+	mockDB.ExpectQuery(`FROM transaction_archive_runs\s+ORDER BY started_at DESC`).
+		WillReturnRows(pgxmock.NewRows(columns))
+	message := "connection reset"
+	mockDB.ExpectQuery(`UPDATE transaction_archive_runs\s+SET status = \$2, error = \$3, finished_at = NOW\(\)`).
+		WithArgs(runID.String(), models.ArchiveRunFailed, &message).
+		WillReturnRows(pgxmock.NewRows(columns).
+			AddRow(runID, cutoff, models.ArchiveRunFailed, int64(3000), &message, startedAt, &startedAt))
+
+	repo := NewTransactionRepository(mockDB)
+	_, err = repo.GetLatestArchiveRun(context.Background())
+	assert.ErrorIs(t, err, ErrArchiveRunNotFound)
+
+	run, err := repo.FinishArchiveRun(context.Background(), runID.String(), errors.New(message))
+	assert.NoError(t, err)
+	assert.Equal(t, models.ArchiveRunFailed, run.Status)
+	assert.Equal(t, int64(3000), run.Archived)
+	assert.Equal(t, &message, run.Error)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
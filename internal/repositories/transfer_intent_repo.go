@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTransferIntentNotFound is returned when no transfer intent matches a lookup
+var ErrTransferIntentNotFound = errors.New("transfer intent not found")
+
+const transferIntentColumns = `id, from_user_id, to_user_id, amount, fee, status, transfer_id, expires_at, created_at, updated_at`
+
+// CreateTransferIntent records a PENDING transfer intent. Returns ErrUserNotFound when
+// either user does not exist.
+func CreateTransferIntent(ctx context.Context, intent *models.TransferIntent) error {
+	err := pool().QueryRow(ctx, `
+        INSERT INTO transfer_intents (from_user_id, to_user_id, amount, fee, status, expires_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+        RETURNING id, created_at, updated_at
+    `, intent.FromUserID, intent.ToUserID, intent.Amount, intent.Fee, intent.Status, intent.ExpiresAt).Scan(&intent.ID, &intent.CreatedAt, &intent.UpdatedAt)
+	return translateUserForeignKeyError(err)
+}
+
+// GetTransferIntentForUpdateTx returns a transfer intent and row-locks it for the rest
+// of the transaction, so it is confirmed only once
+func GetTransferIntentForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.TransferIntent, error) {
+	row := tx.QueryRow(ctx, `
+        SELECT `+transferIntentColumns+`
+        FROM transfer_intents
+        WHERE id = $1
+        FOR UPDATE
+    `, id)
+	intent, err := scanTransferIntent(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTransferIntentNotFound, id)
+	}
+	return intent, err
+}
+
+// UpdateTransferIntentTx saves the outcome of a transfer intent
+func UpdateTransferIntentTx(ctx context.Context, tx pgx.Tx, intent *models.TransferIntent) error {
+	return tx.QueryRow(ctx, `
+        UPDATE transfer_intents SET status = $1, transfer_id = $2, updated_at = NOW()
+        WHERE id = $3
+        RETURNING updated_at
+    `, intent.Status, intent.TransferID, intent.ID).Scan(&intent.UpdatedAt)
+}
+
+// ExpireTransferIntents marks PENDING intents past their expiry as EXPIRED and returns
+// how many it expired. An intent being confirmed keeps its row lock until it is
+// CONFIRMED, so it is not expired underneath the sender.
+func ExpireTransferIntents(ctx context.Context) (int64, error) {
+	tag, err := pool().Exec(ctx, `
+        UPDATE transfer_intents SET status = 'EXPIRED', updated_at = NOW()
+        WHERE status = 'PENDING' AND expires_at <= NOW()
+    `)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanTransferIntent(row pgx.Row) (*models.TransferIntent, error) {
+	var intent models.TransferIntent
+	err := row.Scan(&intent.ID, &intent.FromUserID, &intent.ToUserID, &intent.Amount, &intent.Fee, &intent.Status,
+		&intent.TransferID, &intent.ExpiresAt, &intent.CreatedAt, &intent.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
@@ -2,12 +2,39 @@ package repositories
 
 import (
 	"context"
-	"walletapp/internal/db"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 	"walletapp/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrUserNotFound is returned when no user exists with the requested ID, or the user
+// was deleted
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrEmailTaken is returned when another user already has the requested email
+var ErrEmailTaken = errors.New("email already in use")
+
+// ErrUsernameTaken is returned when another user already has the requested username
+var ErrUsernameTaken = errors.New("username already in use")
+
+// userEmailKey and userUsernameKey are the unique indexes on the email and username
+// of live users
+const (
+	userEmailKey    = "users_email_key"
+	userUsernameKey = "users_username_key"
 )
 
-func GetAllUsers(ctx context.Context) ([]models.User, error) {
-	rows, err := db.DB.Query(ctx, "SELECT id, username, first_name, last_name, email, password, created_at, updated_at FROM users")
+// userColumns are the columns scanUser reads, in order. The password hash is left out;
+// only GetUserCredentialsByEmail reads it.
+const userColumns = `id, username, first_name, last_name, email, kyc_tier, email_verified_at, referral_code, created_at, updated_at, deleted_at`
+
+func (r *PgUserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := r.read.Query(ctx, "SELECT "+userColumns+" FROM users WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -15,36 +42,399 @@ func GetAllUsers(ctx context.Context) ([]models.User, error) {
 
 	var users []models.User
 	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		users = append(users, u)
+		users = append(users, *u)
 	}
 	return users, nil
 }
 
-func GetUserByID(ctx context.Context, id string) (*models.User, error) {
-	var user models.User
-	err := db.DB.QueryRow(ctx, "SELECT id, username, first_name, last_name, email, password, created_at, updated_at FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+// SearchUsers returns up to limit live users whose username, email, first or last name
+// contains query, ignoring case, newest first and skipping the first offset. An empty
+// query matches every user. It also returns how many users match in all.
+func (r *PgUserRepository) SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	pattern := "%" + escapeLike(query) + "%"
+	// The window counts the matches in the same round trip
+	rows, err := r.read.Query(ctx, `
+        SELECT `+userColumns+`, COUNT(*) OVER ()
+        FROM users
+        WHERE `+userSearchWhere+`
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2 OFFSET $3
+    `, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	var total int
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.Email, &u.KycTier, &u.EmailVerifiedAt, &u.ReferralCode, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	// A page past the end has no rows to carry the count
+	if len(users) == 0 && offset > 0 {
+		if err := r.read.QueryRow(ctx, `SELECT COUNT(*) FROM users WHERE `+userSearchWhere, pattern).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+	return users, total, nil
+}
+
+// userSearchWhere matches the live users with a column matching the ILIKE pattern $1
+const userSearchWhere = `deleted_at IS NULL
+          AND (username ILIKE $1 OR email ILIKE $1 OR first_name ILIKE $1 OR last_name ILIKE $1)`
+
+// likeEscaper escapes the characters LIKE patterns treat specially, with the default
+// escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike returns s as a LIKE pattern that matches s literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (r *PgUserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	user, err := scanUser(r.read.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return user, err
+}
+
+// GetUserByEmail returns the live user with email, ignoring case
+func (r *PgUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := scanUser(r.read.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL", email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no user has email %s", ErrUserNotFound, email)
+	}
+	return user, err
+}
+
+// GetUserCredentialsByEmail returns the live user with email, ignoring case, with the
+// password hash that no other lookup reads, for checking a login against. It reads
+// the primary, so a password changed a moment ago is not checked against a stale copy.
+func (r *PgUserRepository) GetUserCredentialsByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u models.User
+	err := r.q.QueryRow(ctx, "SELECT "+userColumns+", password FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL", email).
+		Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.Email, &u.KycTier, &u.EmailVerifiedAt, &u.ReferralCode, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.Password)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no user has email %s", ErrUserNotFound, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUserCredentialsByID returns the live user with id together with their password
+// hash, for checking a password against. Like GetUserCredentialsByEmail, it reads the
+// primary.
+func (r *PgUserRepository) GetUserCredentialsByID(ctx context.Context, id string) (*models.User, error) {
+	var u models.User
+	err := r.q.QueryRow(ctx, "SELECT "+userColumns+", password FROM users WHERE id = $1 AND deleted_at IS NULL", id).
+		Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.Email, &u.KycTier, &u.EmailVerifiedAt, &u.ReferralCode, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.Password)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return &u, nil
+}
+
+// UpdateUserPassword replaces the password hash of a live user. Returns
+// ErrUserNotFound when the user does not exist.
+func (r *PgUserRepository) UpdateUserPassword(ctx context.Context, id, passwordHash string) error {
+	var updatedAt time.Time
+	err := r.q.QueryRow(ctx, `
+        UPDATE users SET password = $2, updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING updated_at
+    `, id, passwordHash).Scan(&updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return err
+}
+
+// RecordPasswordFailure records that a wrong current password was given to change a
+// user's password. Returns ErrUserNotFound when the user does not exist.
+func (r *PgUserRepository) RecordPasswordFailure(ctx context.Context, id string) error {
+	var createdAt time.Time
+	err := r.q.QueryRow(ctx, `
+        INSERT INTO password_change_failures (user_id, created_at)
+        VALUES ($1, NOW())
+        RETURNING created_at
+    `, id).Scan(&createdAt)
+	return translateUserForeignKeyError(err)
+}
+
+// CountPasswordFailuresSince counts the wrong current passwords given for a user since a
+// time, and returns when the oldest of them was, or nil when there are none
+func (r *PgUserRepository) CountPasswordFailuresSince(ctx context.Context, id string, since time.Time) (int, *time.Time, error) {
+	var count int
+	var oldest *time.Time
+	err := r.q.QueryRow(ctx, `
+        SELECT COUNT(*), MIN(created_at)
+        FROM password_change_failures
+        WHERE user_id = $1 AND created_at > $2
+    `, id, since).Scan(&count, &oldest)
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, oldest, nil
 }
 
-func CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-	var user models.User
-	err := db.DB.QueryRow(ctx, `
+// CreateUser inserts a user. Returns ErrEmailTaken or ErrUsernameTaken when a live
+// user already has the email or username.
+func (r *PgUserRepository) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	user, err := scanUser(r.q.QueryRow(ctx, `
         INSERT INTO users (username, first_name, last_name, email, password, created_at, updated_at)
         VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-        RETURNING id, username, first_name, last_name, email, password, created_at, updated_at
+        RETURNING `+userColumns+`
     `,
 		req.Username, req.FirstName, req.LastName, req.Email, req.Password,
-	).Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	))
+	if err != nil {
+		return nil, translateUserConflict(err, req)
+	}
+	return user, nil
+}
+
+// IsEmailExists reports whether a live user has email, ignoring case. Deleted users
+// free theirs.
+func (r *PgUserRepository) IsEmailExists(ctx context.Context, email string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL)`, email).Scan(&exists)
+	return exists, err
+}
+
+// IsUsernameExists reports whether a live user has username. Deleted users free theirs.
+func (r *PgUserRepository) IsUsernameExists(ctx context.Context, username string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1 AND deleted_at IS NULL)`, username).Scan(&exists)
+	return exists, err
+}
+
+// UpdateUser sets the fields of a user that are present in fields and returns the
+// updated user. A new email is unverified until the user verifies it. Returns
+// ErrUserNotFound when the user does not exist, and ErrEmailTaken when another user
+// already has the new email.
+func (r *PgUserRepository) UpdateUser(ctx context.Context, id string, fields *models.UpdateUserRequest) (*models.User, error) {
+	// A missing field binds NULL and keeps the column's value
+	user, err := scanUser(r.q.QueryRow(ctx, `
+        UPDATE users
+        SET first_name = COALESCE($2, first_name),
+            last_name = COALESCE($3, last_name),
+            email = COALESCE($4, email),
+            email_verified_at = CASE WHEN LOWER($4) <> LOWER(email) THEN NULL ELSE email_verified_at END,
+            updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING `+userColumns+`
+    `, id, fields.FirstName, fields.LastName, fields.Email))
+	var pgErr *pgconn.PgError
+	// 23505 is unique_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == userEmailKey {
+		return nil, fmt.Errorf("%w: %s", ErrEmailTaken, *fields.Email)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return user, err
+}
+
+// CreateUserTx inserts a user within a transaction, so the insert can be rolled back
+// together with the rest of the caller's work. Like CreateUser, it returns
+// ErrEmailTaken or ErrUsernameTaken when a live user already has the email or username.
+func CreateUserTx(ctx context.Context, tx pgx.Tx, req *models.CreateUserRequest) (*models.User, error) {
+	return NewUserRepository(tx).CreateUser(ctx, req)
+}
+
+// IsEmailExistsTx reports whether a live user has email within a transaction
+func IsEmailExistsTx(ctx context.Context, tx pgx.Tx, email string) (bool, error) {
+	return NewUserRepository(tx).IsEmailExists(ctx, email)
+}
+
+// IsUsernameExistsTx reports whether a live user has username within a transaction
+func IsUsernameExistsTx(ctx context.Context, tx pgx.Tx, username string) (bool, error) {
+	return NewUserRepository(tx).IsUsernameExists(ctx, username)
+}
+
+// translateUserConflict maps a unique violation on a live user's email or username,
+// left by inserting req, to ErrEmailTaken or ErrUsernameTaken. Other errors are
+// returned as they are.
+func translateUserConflict(err error, req *models.CreateUserRequest) error {
+	var pgErr *pgconn.PgError
+	// 23505 is unique_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		switch pgErr.ConstraintName {
+		case userEmailKey:
+			return fmt.Errorf("%w: %s", ErrEmailTaken, req.Email)
+		case userUsernameKey:
+			return fmt.Errorf("%w: %s", ErrUsernameTaken, req.Username)
+		}
+	}
+	return err
+}
+
+// SetUserKycTier sets a user's KYC tier and returns the updated user
+func (r *PgUserRepository) SetUserKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error) {
+	user, err := scanUser(r.q.QueryRow(ctx, `
+        UPDATE users SET kyc_tier = $2, updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING `+userColumns+`
+    `, userID, tier))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	return user, err
+}
+
+// SoftDeleteUserTx marks a user deleted as of now within a transaction. The row is
+// kept, so the user's transactions keep their owner, but users are read as if it were
+// gone from then on. Returns ErrUserNotFound when the user does not exist or was
+// already deleted.
+func SoftDeleteUserTx(ctx context.Context, tx pgx.Tx, id string) error {
+	tag, err := tx.Exec(ctx, `UPDATE users SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return nil
+}
+
+// RestoreUser undoes the soft delete of a user and their wallets, in one statement, and
+// returns the restored user. The wallets stay closed. Returns ErrUserNotFound when no
+// deleted user has the ID, and ErrEmailTaken or ErrUsernameTaken when a live user took
+// the email or username since.
+func (r *PgUserRepository) RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	user, err := scanUser(r.q.QueryRow(ctx, `
+        WITH restored AS (
+            UPDATE users SET deleted_at = NULL, updated_at = NOW()
+            WHERE id = $1 AND deleted_at IS NOT NULL
+            RETURNING `+userColumns+`
+        ), wallets AS (
+            UPDATE wallets SET deleted_at = NULL, updated_at = NOW()
+            WHERE user_id IN (SELECT id FROM restored) AND deleted_at IS NOT NULL
+        )
+        SELECT `+userColumns+` FROM restored
+    `, id))
+	var pgErr *pgconn.PgError
+	// 23505 is unique_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		switch pgErr.ConstraintName {
+		case userEmailKey:
+			return nil, fmt.Errorf("%w: by another user", ErrEmailTaken)
+		case userUsernameKey:
+			return nil, fmt.Errorf("%w: by another user", ErrUsernameTaken)
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no deleted user %s", ErrUserNotFound, id)
+	}
+	return user, err
+}
+
+// GetUserForUpdate returns the live user with id and, run in a transaction, row-locks
+// them for the rest of it
+func (r *PgUserRepository) GetUserForUpdate(ctx context.Context, id string) (*models.User, error) {
+	user, err := scanUser(r.q.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return user, err
+}
+
+// MarkEmailVerified records that a live user verified their email, keeping the time of
+// an earlier verification, and returns the updated user. Returns ErrUserNotFound when
+// the user does not exist.
+func (r *PgUserRepository) MarkEmailVerified(ctx context.Context, id string) (*models.User, error) {
+	user, err := scanUser(r.q.QueryRow(ctx, `
+        UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING `+userColumns+`
+    `, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return user, err
+}
+
+// IsUserEmailVerified reports whether a live user verified their email
+func (r *PgUserRepository) IsUserEmailVerified(ctx context.Context, userID string) (bool, error) {
+	var verified bool
+	err := r.q.QueryRow(ctx, `SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1 AND deleted_at IS NULL`, userID).Scan(&verified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	return verified, err
+}
+
+// GetUserByReferralCodeTx returns the user whose referral code is code within a
+// transaction
+func GetUserByReferralCodeTx(ctx context.Context, tx pgx.Tx, code string) (*models.User, error) {
+	user, err := scanUser(tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE referral_code = $1 AND deleted_at IS NULL", code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no user has referral code %s", ErrUserNotFound, code)
+	}
+	return user, err
+}
+
+// GetUserKycTierTx returns a user's KYC tier within a transaction
+func GetUserKycTierTx(ctx context.Context, tx pgx.Tx, userID string) (models.KycTier, error) {
+	var tier models.KycTier
+	err := tx.QueryRow(ctx, `SELECT kyc_tier FROM users WHERE id = $1 AND deleted_at IS NULL`, userID).Scan(&tier)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return tier, nil
+}
+
+// GetKycTierLimitsTx returns the limits of every KYC tier, lowest tier first, within
+// a transaction
+func GetKycTierLimitsTx(ctx context.Context, tx pgx.Tx) ([]models.KycTierLimits, error) {
+	rows, err := tx.Query(ctx, `
+        SELECT tier, max_single_amount, daily_limit, monthly_limit
+        FROM kyc_tier_limits
+        ORDER BY tier
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var limits []models.KycTierLimits
+	for rows.Next() {
+		var l models.KycTierLimits
+		if err := rows.Scan(&l.Tier, &l.MaxSingleAmount, &l.DailyLimit, &l.MonthlyLimit); err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	return limits, rows.Err()
+}
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.Email, &u.KycTier, &u.EmailVerifiedAt, &u.ReferralCode, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return &u, nil
 }
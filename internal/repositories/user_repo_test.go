@@ -0,0 +1,361 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// userRows returns a row for each of ids
+func userRows(ids ...uuid.UUID) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{"id", "username", "first_name", "last_name", "email", "kyc_tier", "email_verified_at",
+		"referral_code", "created_at", "updated_at", "deleted_at"})
+	now := time.Now()
+	for _, id := range ids {
+		rows.AddRow(id, "user_"+id.String()[:8], "Test", "User", id.String()+"@example.com", models.KycTierBasic,
+			nil, nil, now, now, nil)
+	}
+	return rows
+}
+
+func TestUserRepository_GetAllUsers(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	first, second := uuid.New(), uuid.New()
+	mockDB.ExpectQuery(`SELECT .+ FROM users WHERE deleted_at IS NULL$`).WillReturnRows(userRows(first, second))
+
+	users, err := NewUserRepository(mockDB).GetAllUsers(context.Background())
+
+	assert.NoError(t, err)
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, first, users[0].ID)
+		assert.Equal(t, second, users[1].ID)
+	}
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetUserByID(t *testing.T) {
+	tests := []struct {
+		name    string
+		found   bool
+		wantErr error
+	}{
+		{"found", true, nil},
+		{"unknown user", false, ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			id := uuid.New()
+			rows := userRows()
+			if tt.found {
+				rows = userRows(id)
+			}
+			mockDB.ExpectQuery(`FROM users WHERE id = \$1 AND deleted_at IS NULL`).WithArgs(id.String()).WillReturnRows(rows)
+
+			user, err := NewUserRepository(mockDB).GetUserByID(context.Background(), id.String())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, id, user.ID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_SetUserKycTier_UnknownUser(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	id := uuid.New()
+	mockDB.ExpectQuery(`UPDATE users SET kyc_tier = \$2`).
+		WithArgs(id.String(), models.KycTierFull).
+		WillReturnRows(userRows())
+
+	user, err := NewUserRepository(mockDB).SetUserKycTier(context.Background(), id.String(), models.KycTierFull)
+
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.Nil(t, user)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdateUser(t *testing.T) {
+	firstName, email := "Jane", "taken@example.com"
+
+	tests := []struct {
+		name    string
+		fields  *models.UpdateUserRequest
+		args    []any
+		err     error
+		wantErr error
+	}{
+		{
+			name:   "missing fields bind NULL",
+			fields: &models.UpdateUserRequest{FirstName: &firstName},
+			args:   []any{&firstName, (*string)(nil), (*string)(nil)},
+		},
+		{
+			name:    "email of another user",
+			fields:  &models.UpdateUserRequest{Email: &email},
+			args:    []any{(*string)(nil), (*string)(nil), &email},
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: userEmailKey},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name:    "unknown user",
+			fields:  &models.UpdateUserRequest{FirstName: &firstName},
+			args:    []any{&firstName, (*string)(nil), (*string)(nil)},
+			err:     pgx.ErrNoRows,
+			wantErr: ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			id := uuid.New()
+			query := mockDB.ExpectQuery(`UPDATE users\s+SET first_name = COALESCE\(\$2, first_name\)`).
+				WithArgs(append([]any{id.String()}, tt.args...)...)
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(userRows(id))
+			}
+
+			user, err := NewUserRepository(mockDB).UpdateUser(context.Background(), id.String(), tt.fields)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, id, user.ID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_IsEmailExists(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM users WHERE LOWER\(email\) = LOWER\(\$1\) AND deleted_at IS NULL\)`).
+		WithArgs("jane@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+
+	exists, err := NewUserRepository(mockDB).IsEmailExists(context.Background(), "jane@example.com")
+
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestUserRepository_RestoreUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "deleted user"},
+		{name: "live or unknown user", err: pgx.ErrNoRows, wantErr: ErrUserNotFound},
+		{
+			name:    "email taken since",
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: userEmailKey},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name:    "username taken since",
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: userUsernameKey},
+			wantErr: ErrUsernameTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			id := uuid.New()
+			query := mockDB.ExpectQuery(`UPDATE users SET deleted_at = NULL.+WHERE id = \$1 AND deleted_at IS NOT NULL.+` +
+				`UPDATE wallets SET deleted_at = NULL.+WHERE user_id IN \(SELECT id FROM restored\)`).
+				WithArgs(id.String())
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(userRows(id))
+			}
+
+			user, err := NewUserRepository(mockDB).RestoreUser(context.Background(), id.String())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, id, user.ID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_GetUserByEmail(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	id := uuid.New()
+	mockDB.ExpectQuery(`FROM users WHERE LOWER\(email\) = LOWER\(\$1\) AND deleted_at IS NULL`).
+		WithArgs("Jane@Example.com").
+		WillReturnRows(userRows(id))
+	mockDB.ExpectQuery(`FROM users WHERE LOWER\(email\) = LOWER\(\$1\)`).
+		WithArgs("nobody@example.com").
+		WillReturnError(pgx.ErrNoRows)
+
+	repo := NewUserRepository(mockDB)
+	user, err := repo.GetUserByEmail(context.Background(), "Jane@Example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+
+	_, err = repo.GetUserByEmail(context.Background(), "nobody@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetUserCredentialsByEmail(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	id := uuid.New()
+	now := time.Now()
+	mockDB.ExpectQuery(`SELECT .+, password FROM users WHERE LOWER\(email\) = LOWER\(\$1\) AND deleted_at IS NULL`).
+		WithArgs("Jane@Example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "username", "first_name", "last_name", "email", "kyc_tier", "email_verified_at",
+			"referral_code", "created_at", "updated_at", "deleted_at", "password"}).
+			AddRow(id, "jane", "Jane", "Doe", "jane@example.com", models.KycTierBasic, nil, nil, now, now, nil, "hash"))
+	mockDB.ExpectQuery(`, password FROM users WHERE LOWER\(email\) = LOWER\(\$1\)`).
+		WithArgs("nobody@example.com").
+		WillReturnError(pgx.ErrNoRows)
+
+	repo := NewUserRepository(mockDB)
+	user, err := repo.GetUserCredentialsByEmail(context.Background(), "Jane@Example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "hash", user.Password)
+
+	_, err = repo.GetUserCredentialsByEmail(context.Background(), "nobody@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestUserRepository_SearchUsers(t *testing.T) {
+	// searchRows returns a row for each of ids, with total as the window count
+	searchRows := func(total int, ids ...uuid.UUID) *pgxmock.Rows {
+		rows := pgxmock.NewRows([]string{"id", "username", "first_name", "last_name", "email", "kyc_tier", "email_verified_at",
+			"referral_code", "created_at", "updated_at", "deleted_at", "count"})
+		now := time.Now()
+		for _, id := range ids {
+			rows.AddRow(id, "user_"+id.String()[:8], "Test", "User", id.String()+"@example.com", models.KycTierBasic,
+				nil, nil, now, now, nil, total)
+		}
+		return rows
+	}
+
+	t.Run("query is matched literally", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+
+		first, second := uuid.New(), uuid.New()
+		mockDB.ExpectQuery(`COUNT\(\*\) OVER \(\)\s+FROM users\s+WHERE deleted_at IS NULL\s+AND \(username ILIKE \$1 OR email ILIKE \$1`).
+			WithArgs(`%50\%\_off\\%`, 2, 0).
+			WillReturnRows(searchRows(7, first, second))
+
+		users, total, err := NewUserRepository(mockDB).SearchUsers(context.Background(), `50%_off\`, 2, 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, total)
+		if assert.Len(t, users, 2) {
+			assert.Equal(t, first, users[0].ID)
+			assert.Equal(t, second, users[1].ID)
+		}
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+
+	t.Run("empty query matches every user", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+
+		mockDB.ExpectQuery(`FROM users`).WithArgs("%%", 50, 0).WillReturnRows(searchRows(1, uuid.New()))
+
+		users, total, err := NewUserRepository(mockDB).SearchUsers(context.Background(), "", 50, 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Len(t, users, 1)
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+
+	t.Run("page past the end still counts", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+
+		mockDB.ExpectQuery(`COUNT\(\*\) OVER \(\)`).WithArgs("%jane%", 10, 40).WillReturnRows(searchRows(0))
+		mockDB.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE deleted_at IS NULL`).WithArgs("%jane%").
+			WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+
+		users, total, err := NewUserRepository(mockDB).SearchUsers(context.Background(), "jane", 10, 40)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Empty(t, users)
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+}
+
+func TestUserRepository_CountPasswordFailuresSince(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	id := uuid.New()
+	since := time.Now().Add(-15 * time.Minute)
+	oldest := since.Add(time.Minute)
+	mockDB.ExpectQuery(`SELECT COUNT\(\*\), MIN\(created_at\)\s+FROM password_change_failures`).
+		WithArgs(id.String(), since).
+		WillReturnRows(pgxmock.NewRows([]string{"count", "min"}).AddRow(2, &oldest))
+
+	count, first, err := NewUserRepository(mockDB).CountPasswordFailuresSince(context.Background(), id.String(), since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, &oldest, first)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
@@ -2,46 +2,532 @@ package repositories
 
 import (
 	"context"
-	"walletapp/internal/db"
+	"errors"
+	"fmt"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func GetWalletByUserID(ctx context.Context, userID string) (*models.Wallet, error) {
-	var w models.Wallet
-	err := db.DB.QueryRow(ctx, "SELECT id, user_id, balance, created_at, updated_at FROM wallets WHERE user_id = $1", userID).
-		Scan(&w.ID, &w.UserID, &w.Balance, &w.CreatedAt, &w.UpdatedAt)
+// ErrInsufficientBalance is returned when a debit would take a wallet below zero
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// ErrWalletNotFound is returned when a user has no wallet, or no wallet has the requested ID
+var ErrWalletNotFound = errors.New("wallet not found")
+
+// ErrWalletNameTaken is returned when a user already has a wallet with the requested name
+var ErrWalletNameTaken = errors.New("wallet name is already taken")
+
+// walletNotFound wraps ErrWalletNotFound with the user whose wallet is missing
+func walletNotFound(userID string) error {
+	return fmt.Errorf("%w: user %s", ErrWalletNotFound, userID)
+}
+
+// walletIDNotFound wraps ErrWalletNotFound with the ID of the missing wallet
+func walletIDNotFound(walletID string) error {
+	return fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+}
+
+// ErrVersionConflict is returned when a versioned balance update finds that the
+// wallet was modified after it was read
+var ErrVersionConflict = errors.New("wallet was modified concurrently")
+
+// walletNameIndex is the unique index on wallets (user_id, name)
+const walletNameIndex = "idx_wallets_user_id_name"
+
+// balanceCheckConstraint is the CHECK (balance - held_amount >= -overdraft_limit) constraint on wallets
+const balanceCheckConstraint = "wallets_balance_within_overdraft"
+
+// translateBalanceError maps a violation of the balance constraint
+// to ErrInsufficientBalance so raw SQL errors never reach API clients
+func translateBalanceError(err error) error {
+	var pgErr *pgconn.PgError
+	// 23514 is check_violation in Postgres
+	if errors.As(err, &pgErr) && pgErr.Code == "23514" && pgErr.ConstraintName == balanceCheckConstraint {
+		return ErrInsufficientBalance
+	}
+	return err
+}
+
+// walletColumns are the columns scanWallet reads, in order
+const walletColumns = `id, user_id, name, is_default, status, currency, balance, overdraft_limit, held_amount, daily_withdrawal_limit, monthly_transfer_limit, interest_rate_bps, version, created_at, updated_at, closed_at, deleted_at`
+
+func (r *PgWalletRepository) GetWalletByUserID(ctx context.Context, userID string) (*models.Wallet, error) {
+	w, err := scanWallet(r.read.QueryRow(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 AND is_default AND deleted_at IS NULL", userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &w, nil
+	return w, nil
 }
 
 func GetWalletByUserIDTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
-	var w models.Wallet
-	err := tx.QueryRow(ctx, "SELECT id, user_id, balance, created_at, updated_at FROM wallets WHERE user_id = $1 FOR UPDATE", userID).
-		Scan(&w.ID, &w.UserID, &w.Balance, &w.CreatedAt, &w.UpdatedAt)
+	w, err := scanWallet(tx.QueryRow(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 AND is_default AND deleted_at IS NULL", userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &w, nil
+	return w, nil
 }
 
-func CreateWallet(ctx context.Context, userID string) (*models.Wallet, error) {
-	var w models.Wallet
-	err := db.DB.QueryRow(ctx, `
+// GetWalletByID reads a wallet by its own ID rather than its owner's
+func (r *PgWalletRepository) GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error) {
+	w, err := scanWallet(r.read.QueryRow(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = $1", walletID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletIDNotFound(walletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func GetWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = $1", walletID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletIDNotFound(walletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetWalletsByUserID lists all of a user's wallets, the default wallet first
+func (r *PgWalletRepository) GetWalletsByUserID(ctx context.Context, userID string) ([]models.Wallet, error) {
+	rows, err := r.read.Query(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 AND deleted_at IS NULL ORDER BY is_default DESC, created_at, name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := []models.Wallet{}
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *w)
+	}
+	return wallets, rows.Err()
+}
+
+// GetWalletsByUserIDs returns the default wallets of the given users in one query,
+// keyed by user ID. Users without a default wallet are left out.
+func (r *PgWalletRepository) GetWalletsByUserIDs(ctx context.Context, userIDs []string) (map[string]*models.Wallet, error) {
+	rows, err := r.read.Query(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = ANY($1::uuid[]) AND is_default AND deleted_at IS NULL", userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := make(map[string]*models.Wallet, len(userIDs))
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets[w.UserID.String()] = w
+	}
+	return wallets, rows.Err()
+}
+
+// GetWalletForUpdateTx reads a wallet and locks its row until the transaction ends,
+// so concurrent balance changes on the same wallet are serialized
+func GetWalletForUpdateTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 AND is_default FOR UPDATE", userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// AcquireWalletLockTx takes a transaction-scoped Postgres advisory lock for a wallet,
+// blocking until any other transaction holding it commits or rolls back. The lock is
+// keyed off a hash of walletKey, so it can be taken before the wallet row is read.
+func AcquireWalletLockTx(ctx context.Context, tx pgx.Tx, walletKey string) error {
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtextextended('wallet:' || $1, 0))", walletKey)
+	return err
+}
+
+func (r *PgWalletRepository) CreateWallet(ctx context.Context, userID string) (*models.Wallet, error) {
+	w, err := scanWallet(r.q.QueryRow(ctx, `
         INSERT INTO wallets (user_id, balance, created_at, updated_at)
         VALUES ($1, 0, NOW(), NOW())
-        RETURNING id, user_id, balance, created_at, updated_at
-    `, userID).Scan(&w.ID, &w.UserID, &w.Balance, &w.CreatedAt, &w.UpdatedAt)
+        RETURNING `+walletColumns+`
+    `, userID))
 	if err != nil {
 		return nil, err
 	}
-	return &w, nil
+	return w, nil
 }
 
-func UpdateWalletBalanceTx(ctx context.Context, tx pgx.Tx, userID string, newBalance float64) error {
-	_, err := tx.Exec(ctx, "UPDATE wallets SET balance = $1, updated_at = NOW() WHERE user_id = $2", newBalance, userID)
-	return err
+// CreateNamedWallet creates an empty wallet holding currency for a user next to their
+// default wallet. Returns ErrWalletNameTaken when the user already has a wallet called
+// name, and ErrUserNotFound when the user does not exist.
+func (r *PgWalletRepository) CreateNamedWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error) {
+	w, err := scanWallet(r.q.QueryRow(ctx, `
+        INSERT INTO wallets (user_id, name, is_default, currency, balance, created_at, updated_at)
+        VALUES ($1, $2, FALSE, $3, 0, NOW(), NOW())
+        RETURNING `+walletColumns+`
+    `, userID, name, currency))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 23505 is unique_violation and 23503 is foreign_key_violation in Postgres
+		switch {
+		case pgErr.Code == "23505" && pgErr.ConstraintName == walletNameIndex:
+			return nil, fmt.Errorf("%w: %q", ErrWalletNameTaken, name)
+		case pgErr.Code == "23503":
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// CreateWalletTx creates an empty wallet for a user within a transaction
+func CreateWalletTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        INSERT INTO wallets (user_id, balance, created_at, updated_at)
+        VALUES ($1, 0, NOW(), NOW())
+        RETURNING `+walletColumns+`
+    `, userID))
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// UpdateWalletBalanceTx sets a wallet's balance, provided the wallet is still at
+// expectedVersion. Returns ErrVersionConflict when another transaction changed the
+// wallet first, and ErrInsufficientBalance when the new balance would exceed the
+// wallet's overdraft limit.
+func UpdateWalletBalanceTx(ctx context.Context, tx pgx.Tx, userID string, newBalance money.Amount, expectedVersion int64) error {
+	tag, err := tx.Exec(ctx, `
+        UPDATE wallets SET balance = $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default AND version = $3
+    `, newBalance, userID, expectedVersion)
+	if err != nil {
+		return translateBalanceError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// UpdateWalletBalanceByIDTx is UpdateWalletBalanceTx for a wallet identified by its own ID
+func UpdateWalletBalanceByIDTx(ctx context.Context, tx pgx.Tx, walletID string, newBalance money.Amount, expectedVersion int64) error {
+	tag, err := tx.Exec(ctx, `
+        UPDATE wallets SET balance = $1, version = version + 1, updated_at = NOW()
+        WHERE id = $2 AND version = $3
+    `, newBalance, walletID, expectedVersion)
+	if err != nil {
+		return translateBalanceError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// DebitWalletTx subtracts amount from a wallet in a single conditional UPDATE, so the
+// balance check and the write cannot race. Returns ErrInsufficientBalance when the
+// debit would take the available balance (the balance minus held funds) past the
+// wallet's overdraft limit, and ErrWalletNotFound when the wallet doesn't exist.
+func DebitWalletTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET balance = balance - $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default AND balance - held_amount - $1 >= -overdraft_limit
+        RETURNING `+walletColumns+`
+    `, amount, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, insufficientOrNotFound(ctx, tx, userID)
+	}
+	if err != nil {
+		return nil, translateBalanceError(err)
+	}
+	return w, nil
+}
+
+// insufficientOrNotFound explains why a conditional UPDATE of a user's wallet matched
+// no row: ErrInsufficientBalance when the wallet exists, ErrWalletNotFound otherwise
+func insufficientOrNotFound(ctx context.Context, tx pgx.Tx, userID string) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM wallets WHERE user_id = $1 AND is_default)", userID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrInsufficientBalance
+	}
+	return walletNotFound(userID)
+}
+
+// DebitWalletByIDTx is DebitWalletTx for a wallet identified by its own ID
+func DebitWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET balance = balance - $1, version = version + 1, updated_at = NOW()
+        WHERE id = $2 AND balance - held_amount - $1 >= -overdraft_limit
+        RETURNING `+walletColumns+`
+    `, amount, walletID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM wallets WHERE id = $1)", walletID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrInsufficientBalance
+		}
+		return nil, walletIDNotFound(walletID)
+	}
+	if err != nil {
+		return nil, translateBalanceError(err)
+	}
+	return w, nil
+}
+
+// HoldFundsTx moves amount of a wallet's available balance into held funds.
+// Returns ErrInsufficientBalance when the available balance, including any overdraft
+// limit, cannot cover amount.
+func HoldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET held_amount = held_amount + $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default AND balance - held_amount - $1 >= -overdraft_limit
+        RETURNING `+walletColumns+`
+    `, amount, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, insufficientOrNotFound(ctx, tx, userID)
+	}
+	if err != nil {
+		return nil, translateBalanceError(err)
+	}
+	return w, nil
+}
+
+// CaptureHeldFundsTx spends amount of a wallet's held funds, taking it off both the
+// balance and the held amount so the available balance is unchanged
+func CaptureHeldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET balance = balance - $1, held_amount = held_amount - $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default
+        RETURNING `+walletColumns+`
+    `, amount, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ReleaseHeldFundsTx returns amount of a wallet's held funds to its available balance
+func ReleaseHeldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET held_amount = held_amount - $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default
+        RETURNING `+walletColumns+`
+    `, amount, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetOverdraftLimit changes how far below zero a wallet may go. Returns
+// ErrInsufficientBalance when the wallet is already overdrawn by more than limit.
+func (r *PgWalletRepository) SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(r.q.QueryRow(ctx, `
+        UPDATE wallets SET overdraft_limit = $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default
+        RETURNING `+walletColumns+`
+    `, limit, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, translateBalanceError(err)
+	}
+	return w, nil
+}
+
+// SetInterestRate changes the yearly interest, in basis points, that one of a user's
+// wallets earns
+func (r *PgWalletRepository) SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error) {
+	w, err := scanWallet(r.q.QueryRow(ctx, `
+        UPDATE wallets SET interest_rate_bps = $1, version = version + 1, updated_at = NOW()
+        WHERE id = $2 AND user_id = $3
+        RETURNING `+walletColumns+`
+    `, rateBps, walletID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletIDNotFound(walletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetInterestBearingWallets lists the active wallets with a positive balance and
+// interest rate, the ones the interest accrual job credits
+func (r *PgWalletRepository) GetInterestBearingWallets(ctx context.Context) ([]models.Wallet, error) {
+	rows, err := r.q.Query(ctx, "SELECT "+walletColumns+" FROM wallets WHERE interest_rate_bps > 0 AND balance > 0 AND status = 'ACTIVE' ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []models.Wallet
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *w)
+	}
+	return wallets, rows.Err()
+}
+
+// SetWalletStatusTx changes a wallet's status within a transaction
+func SetWalletStatusTx(ctx context.Context, tx pgx.Tx, userID string, status models.WalletStatus) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET status = $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default
+        RETURNING `+walletColumns+`
+    `, status, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// CloseWalletTx marks a wallet CLOSED as of now within a transaction
+func CloseWalletTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET status = 'CLOSED', closed_at = NOW(), version = version + 1, updated_at = NOW()
+        WHERE user_id = $1 AND is_default
+        RETURNING `+walletColumns+`
+    `, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetWalletsByUserIDForUpdateTx lists all of a user's wallets, the default wallet
+// first, and locks their rows until the transaction ends
+func GetWalletsByUserIDForUpdateTx(ctx context.Context, tx pgx.Tx, userID string) ([]models.Wallet, error) {
+	rows, err := tx.Query(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 ORDER BY is_default DESC, created_at, name FOR UPDATE", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := []models.Wallet{}
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *w)
+	}
+	return wallets, rows.Err()
+}
+
+// CloseWalletsByUserIDTx closes and soft-deletes every wallet of a user being deleted
+// within a transaction, and returns the wallets it deleted. Wallets still open are
+// marked CLOSED as of now; all of them get deleted_at, which the wallet reads filter on.
+func CloseWalletsByUserIDTx(ctx context.Context, tx pgx.Tx, userID string) ([]models.Wallet, error) {
+	rows, err := tx.Query(ctx, `
+        UPDATE wallets
+        SET status = 'CLOSED', closed_at = COALESCE(closed_at, NOW()), deleted_at = NOW(),
+            version = version + 1, updated_at = NOW()
+        WHERE user_id = $1 AND deleted_at IS NULL
+        RETURNING `+walletColumns+`
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := []models.Wallet{}
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *w)
+	}
+	return wallets, rows.Err()
+}
+
+// CreateWalletStatusChangeTx records who changed a wallet's status, and why, within a
+// transaction
+func CreateWalletStatusChangeTx(ctx context.Context, tx pgx.Tx, c *models.WalletStatusChange) error {
+	return tx.QueryRow(ctx, `
+        INSERT INTO wallet_status_changes (wallet_id, status, reason, performed_by, created_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        RETURNING id, created_at
+    `, c.WalletID, c.Status, c.Reason, c.PerformedBy).Scan(&c.ID, &c.CreatedAt)
+}
+
+// CreditWalletTx adds amount to a wallet in a single UPDATE and returns the updated wallet
+func CreditWalletTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET balance = balance + $1, version = version + 1, updated_at = NOW()
+        WHERE user_id = $2 AND is_default
+        RETURNING `+walletColumns+`
+    `, amount, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletNotFound(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// CreditWalletByIDTx is CreditWalletTx for a wallet identified by its own ID
+func CreditWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string, amount money.Amount) (*models.Wallet, error) {
+	w, err := scanWallet(tx.QueryRow(ctx, `
+        UPDATE wallets SET balance = balance + $1, version = version + 1, updated_at = NOW()
+        WHERE id = $2
+        RETURNING `+walletColumns+`
+    `, amount, walletID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, walletIDNotFound(walletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func scanWallet(row pgx.Row) (*models.Wallet, error) {
+	var w models.Wallet
+	err := row.Scan(&w.ID, &w.UserID, &w.Name, &w.IsDefault, &w.Status, &w.Currency, &w.Balance, &w.OverdraftLimit, &w.HeldAmount, &w.DailyWithdrawalLimit, &w.MonthlyTransferLimit, &w.InterestRateBps, &w.Version, &w.CreatedAt, &w.UpdatedAt, &w.ClosedAt, &w.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
 }
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// walletRows returns one active default wallet for each of userIDs
+func walletRows(userIDs ...uuid.UUID) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{"id", "user_id", "name", "is_default", "status", "currency", "balance", "overdraft_limit",
+		"held_amount", "daily_withdrawal_limit", "monthly_transfer_limit", "interest_rate_bps", "version", "created_at", "updated_at", "closed_at", "deleted_at"})
+	now := time.Now()
+	for _, userID := range userIDs {
+		rows.AddRow(uuid.New(), userID, "main", true, models.WalletStatusActive, "USD", "10.00", "0.00",
+			"0.00", nil, nil, 0, int64(1), now, now, nil, nil)
+	}
+	return rows
+}
+
+func TestGetWalletsByUserIDs_LoadsAllUsersInOneQuery(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	withWallet, withoutWallet := uuid.New(), uuid.New()
+	ids := []string{withWallet.String(), withoutWallet.String()}
+	mockDB.ExpectQuery(`FROM wallets WHERE user_id = ANY\(\$1::uuid\[\]\) AND is_default AND deleted_at IS NULL`).
+		WithArgs(ids).
+		WillReturnRows(walletRows(withWallet))
+
+	wallets, err := NewWalletRepository(mockDB).GetWalletsByUserIDs(context.Background(), ids)
+
+	assert.NoError(t, err)
+	assert.Len(t, wallets, 1)
+	assert.Equal(t, withWallet, wallets[withWallet.String()].UserID)
+	assert.Nil(t, wallets[withoutWallet.String()])
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletRepository_ReadsFromReplica(t *testing.T) {
+	primary, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer primary.Close()
+	replica, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer replica.Close()
+
+	userID := uuid.New()
+	replica.ExpectQuery(`FROM wallets WHERE user_id = \$1 AND is_default AND deleted_at IS NULL`).
+		WithArgs(userID.String()).
+		WillReturnRows(walletRows(userID))
+	primary.ExpectQuery(`UPDATE wallets SET overdraft_limit = \$1`).
+		WithArgs(pgxmock.AnyArg(), userID.String()).
+		WillReturnRows(walletRows(userID))
+	repo := NewWalletRepositoryWithReplica(primary, replica)
+
+	_, err = repo.GetWalletByUserID(context.Background(), userID.String())
+	assert.NoError(t, err)
+	_, err = repo.SetOverdraftLimit(context.Background(), userID.String(), 5000)
+	assert.NoError(t, err)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestWalletRepository_GetWalletByUserID(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    func(userID uuid.UUID) *pgxmock.Rows
+		wantErr error
+	}{
+		{"found", func(userID uuid.UUID) *pgxmock.Rows { return walletRows(userID) }, nil},
+		{"no wallet", func(uuid.UUID) *pgxmock.Rows { return walletRows() }, ErrWalletNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			userID := uuid.New()
+			mockDB.ExpectQuery(`FROM wallets WHERE user_id = \$1 AND is_default AND deleted_at IS NULL`).
+				WithArgs(userID.String()).
+				WillReturnRows(tt.rows(userID))
+
+			wallet, err := NewWalletRepository(mockDB).GetWalletByUserID(context.Background(), userID.String())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, wallet)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, userID, wallet.UserID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletRepository_CreateNamedWallet(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{"created", nil, nil},
+		{"name taken", &pgconn.PgError{Code: "23505", ConstraintName: walletNameIndex}, ErrWalletNameTaken},
+		{"unknown user", &pgconn.PgError{Code: "23503"}, ErrUserNotFound},
+		{"other failure", pgx.ErrTxClosed, pgx.ErrTxClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			userID := uuid.New()
+			query := mockDB.ExpectQuery(`INSERT INTO wallets \(user_id, name, is_default, currency`).
+				WithArgs(userID.String(), "savings", "EUR")
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(walletRows(userID))
+			}
+
+			wallet, err := NewWalletRepository(mockDB).CreateNamedWallet(context.Background(), userID.String(), "savings", "EUR")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, wallet)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, userID, wallet.UserID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCloseWalletsByUserIDTx_SoftDeletesWallets(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	userID := uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectQuery(`UPDATE wallets\s+SET status = 'CLOSED', closed_at = COALESCE\(closed_at, NOW\(\)\), deleted_at = NOW\(\).+WHERE user_id = \$1 AND deleted_at IS NULL`).
+		WithArgs(userID.String()).
+		WillReturnRows(walletRows(userID, userID))
+
+	tx, err := mockDB.Begin(context.Background())
+	assert.NoError(t, err)
+	wallets, err := CloseWalletsByUserIDTx(context.Background(), tx, userID.String())
+	assert.NoError(t, err)
+	assert.Len(t, wallets, 2)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
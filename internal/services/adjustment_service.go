@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidAdjustment is returned when an adjustment does not say who performed it
+var ErrInvalidAdjustment = errors.New("invalid adjustment")
+
+// Adjust corrects a user's balance by amount, crediting the wallet when amount is
+// positive and debiting it when negative, for example after a bank reconciliation or
+// as a goodwill credit. The adjustment is recorded as an ADJUSTMENT transaction with
+// the signed amount, the reason as its description and performedBy, the acting admin,
+// for the audit trail. A negative adjustment may not take the available balance below
+// zero unless force is set, and never below the wallet's overdraft limit.
+func (s *WalletService) Adjust(ctx context.Context, userID string, amount money.Amount, reason, performedBy string, force bool) (*models.Transaction, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":    "adjust",
+		"amount":       amount,
+		"performed_by": performedBy,
+		"force":        force,
+	})
+	log.Info("Starting balance adjustment")
+
+	magnitude := amount
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	if err := s.ValidateAmount(magnitude); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Adjustment validation failed")
+		return nil, err
+	}
+	memo, err := normalizeDescription(reason)
+	if err != nil || memo == nil {
+		log.Warn("Adjustment without a valid reason rejected")
+		return nil, fmt.Errorf("%w: a reason of at most %d characters is required", ErrInvalidReason, maxDescriptionLength)
+	}
+	performedBy = strings.TrimSpace(performedBy)
+	if performedBy == "" {
+		log.Warn("Adjustment without an acting admin rejected")
+		return nil, fmt.Errorf("%w: the admin performing it is required", ErrInvalidAdjustment)
+	}
+
+	var adjustment *models.Transaction
+	err = s.retryTx(ctx, log, func() (err error) {
+		adjustment, err = s.adjust(ctx, log, userID, amount, *memo, performedBy, force)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return adjustment, nil
+}
+
+// adjust runs a single attempt of Adjust inside its own database transaction
+func (s *WalletService) adjust(ctx context.Context, log *logrus.Entry, userID string, amount money.Amount, reason, performedBy string, force bool) (adjustment *models.Transaction, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "adjust", err); err != nil {
+			adjustment = nil
+		}
+	}()
+
+	if err = s.lockWallets(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+
+	var wallet *models.Wallet
+	if amount > 0 {
+		wallet, err = s.credit(ctx, tx, defaultWallet(userID), amount)
+	} else {
+		if !force {
+			// Unlike a withdrawal, an unforced adjustment never dips into the overdraft
+			wallet, err = s.walletRepo.GetWalletForUpdateTx(ctx, tx, userID)
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to get wallet")
+				return nil, err
+			}
+			if wallet.Balance-wallet.HeldAmount+amount < 0 {
+				log.Warn("Adjustment would take the balance below zero")
+				return nil, fmt.Errorf("%w: adjustment of %s would take the balance of user %s below zero", ErrInsufficientBalance, amount, userID)
+			}
+		}
+		wallet, err = s.debit(ctx, tx, defaultWallet(userID), -amount)
+		if errors.Is(err, ErrInsufficientBalance) {
+			log.Warn("Adjustment would exceed the overdraft limit")
+			return nil, fmt.Errorf("%w: adjustment of %s would take user %s past the overdraft limit", ErrInsufficientBalance, amount, userID)
+		}
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update wallet balance")
+		return nil, err
+	}
+	if err = checkPrecision(wallet, amount); err != nil {
+		log.WithField("currency", wallet.Currency).Warn("Adjustment rejected, amount does not fit the wallet's currency")
+		return nil, err
+	}
+
+	adjustment = &models.Transaction{
+		WalletID:    wallet.ID,
+		Type:        models.TransactionTypeAdjustment,
+		Status:      models.TransactionStatusCompleted,
+		Amount:      amount,
+		Description: &reason,
+		PerformedBy: &performedBy,
+	}
+	if err = s.transactionRepo.CreateTransactionTx(ctx, tx, adjustment); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record adjustment transaction")
+		return nil, err
+	}
+	groupID := uuid.New()
+	entries := []models.LedgerEntry{
+		adjustmentLedgerEntry(groupID, models.LedgerDebit, amount),
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerCredit, amount),
+	}
+	if amount < 0 {
+		entries = []models.LedgerEntry{
+			walletLedgerEntry(groupID, wallet.ID, models.LedgerDebit, -amount),
+			adjustmentLedgerEntry(groupID, models.LedgerCredit, -amount),
+		}
+	}
+	if err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, entries); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record adjustment ledger entries")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"balance_before": wallet.Balance - amount,
+		"balance_after":  wallet.Balance,
+	}).Info("Balance adjustment completed successfully")
+	return adjustment, nil
+}
+
+// adjustmentLedgerEntry builds a ledger entry against the system adjustments account,
+// which balances manual corrections made by admins
+func adjustmentLedgerEntry(groupID uuid.UUID, direction models.LedgerDirection, amount money.Amount) models.LedgerEntry {
+	return models.LedgerEntry{
+		GroupID:   groupID,
+		Account:   models.LedgerAccountAdjustments,
+		Direction: direction,
+		Amount:    amount,
+	}
+}
+
+func Adjust(ctx context.Context, userID string, amount money.Amount, reason, performedBy string, force bool) (*models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Adjust(ctx, userID, amount, reason, performedBy, force)
+}
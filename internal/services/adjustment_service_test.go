@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_Adjust(t *testing.T) {
+	tests := []struct {
+		name          string
+		amount        money.Amount
+		balance       money.Amount
+		force         bool
+		debitErr      error
+		expectedErrIs error
+	}{
+		{"goodwill credit", money.MustParse("10.00"), 0, false, nil, nil},
+		{"debit within balance", money.MustParse("-10.00"), money.MustParse("10.00"), false, nil, nil},
+		{"debit below zero", money.MustParse("-10.01"), money.MustParse("10.00"), false, nil, ErrInsufficientBalance},
+		{"forced debit below zero", money.MustParse("-10.01"), money.MustParse("10.00"), true, nil, nil},
+		{"forced debit past the overdraft limit", money.MustParse("-10.01"), money.MustParse("10.00"), true, repositories.ErrInsufficientBalance, ErrInsufficientBalance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			var recorded *models.Transaction
+			var entries []models.LedgerEntry
+			mockTxRepo.ExpectedCalls = nil
+			mockDB.ExpectBegin()
+			if tt.expectedErrIs == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockWalletRepo.On("GetWalletForUpdateTx", mock.Anything, mock.Anything, "user1").
+				Return(&models.Wallet{ID: walletID, Balance: tt.balance}, nil).Maybe()
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", tt.amount).
+				Return(&models.Wallet{ID: walletID, Balance: tt.balance + tt.amount}, nil).Maybe()
+			var debited *models.Wallet
+			if tt.debitErr == nil {
+				debited = &models.Wallet{ID: walletID, Balance: tt.balance + tt.amount}
+			}
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", -tt.amount).Return(debited, tt.debitErr).Maybe()
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { recorded = args.Get(2).(*models.Transaction) }).Return(nil).Maybe()
+			mockTxRepo.On("CreateLedgerEntriesTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { entries = args.Get(2).([]models.LedgerEntry) }).Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			adjustment, err := service.Adjust(context.Background(), "user1", tt.amount, "bank reconciliation", "admin-42", tt.force)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, adjustment)
+				assert.Nil(t, recorded)
+			} else {
+				assert.NoError(t, err)
+				assert.Same(t, recorded, adjustment)
+				assert.Equal(t, models.TransactionTypeAdjustment, adjustment.Type)
+				assert.Equal(t, tt.amount, adjustment.Amount, "adjustments keep their sign")
+				assert.Equal(t, walletID, adjustment.WalletID)
+				if assert.NotNil(t, adjustment.Description) && assert.NotNil(t, adjustment.PerformedBy) {
+					assert.Equal(t, "bank reconciliation", *adjustment.Description)
+					assert.Equal(t, "admin-42", *adjustment.PerformedBy)
+				}
+				if assert.Len(t, entries, 2) {
+					assert.Equal(t, entries[0].Amount, entries[1].Amount)
+					assert.NotEqual(t, entries[0].Direction, entries[1].Direction)
+				}
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_Adjust_Validation(t *testing.T) {
+	service := NewWalletService(nil, nil, nil, WalletServiceConfig{})
+
+	tests := []struct {
+		name          string
+		amount        money.Amount
+		reason        string
+		performedBy   string
+		expectedErrIs error
+	}{
+		{"zero amount", 0, "correction", "admin-42", ErrInvalidAmount},
+		{"beyond the maximum", -MAX_AMOUNT - 1, "correction", "admin-42", ErrInvalidAmount},
+		{"missing reason", 1000, "  ", "admin-42", ErrInvalidReason},
+		{"missing admin", 1000, "correction", "", ErrInvalidAdjustment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Adjust(context.Background(), "user1", tt.amount, tt.reason, tt.performedBy, false)
+			assert.ErrorIs(t, err, tt.expectedErrIs)
+		})
+	}
+}
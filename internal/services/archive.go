@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for the transaction archive job
+const (
+	defaultArchiveRetentionMonths = 24
+	defaultArchiveBatchSize       = 1000
+	defaultArchiveBatchPause      = time.Second
+	defaultArchiveInterval        = 24 * time.Hour
+)
+
+// ErrArchiveInProgress is returned when the archive job is started while it is running
+var ErrArchiveInProgress = errors.New("transaction archive already in progress")
+
+// ArchiveRepo records the runs of the archive job and moves transactions into the
+// archive
+type ArchiveRepo interface {
+	CreateArchiveRun(ctx context.Context, cutoff time.Time) (*models.ArchiveRun, error)
+	GetLatestArchiveRun(ctx context.Context) (*models.ArchiveRun, error)
+	FinishArchiveRun(ctx context.Context, id string, runErr error) (*models.ArchiveRun, error)
+	CountArchivableTransactions(ctx context.Context, cutoff time.Time) (int64, error)
+	ArchiveTransactionsTx(ctx context.Context, tx pgx.Tx, runID string, cutoff time.Time, limit int) (int64, error)
+}
+
+// ArchiveConfig holds the tunable settings of the transaction archive job. Zero values
+// fall back to the defaults.
+type ArchiveConfig struct {
+	// RetentionMonths is how many months transactions stay in transactions before they
+	// are archived
+	RetentionMonths int
+	// BatchSize is how many transactions move per database transaction
+	BatchSize int
+	// BatchPause is how long the job waits between batches, to leave the database to
+	// the requests
+	BatchPause time.Duration
+	// Interval is how often the worker runs the job
+	Interval time.Duration
+}
+
+// ArchiveConfigFromEnv reads the job settings from
+// TRANSACTION_ARCHIVE_RETENTION_MONTHS, TRANSACTION_ARCHIVE_BATCH_SIZE,
+// TRANSACTION_ARCHIVE_BATCH_PAUSE and TRANSACTION_ARCHIVE_INTERVAL (durations such as
+// "1s"). Unset variables keep the defaults.
+func ArchiveConfigFromEnv() (ArchiveConfig, error) {
+	var config ArchiveConfig
+	for _, v := range []struct {
+		name   string
+		target *int
+	}{
+		{"TRANSACTION_ARCHIVE_RETENTION_MONTHS", &config.RetentionMonths},
+		{"TRANSACTION_ARCHIVE_BATCH_SIZE", &config.BatchSize},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return ArchiveConfig{}, fmt.Errorf("invalid %s %q: must be a positive integer", v.name, raw)
+		}
+		*v.target = n
+	}
+	for _, v := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"TRANSACTION_ARCHIVE_BATCH_PAUSE", &config.BatchPause},
+		{"TRANSACTION_ARCHIVE_INTERVAL", &config.Interval},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return ArchiveConfig{}, fmt.Errorf("invalid %s %q: must be a positive duration", v.name, raw)
+		}
+		*v.target = d
+	}
+	return config, nil
+}
+
+// ArchiveService moves transactions older than the retention from transactions to
+// transactions_archive, where history and reports can still read them
+type ArchiveService struct {
+	repo            ArchiveRepo
+	db              DB
+	retentionMonths int
+	batchSize       int
+	batchPause      time.Duration
+	interval        time.Duration
+	// running is set while a run is moving transactions in this process
+	running atomic.Bool
+	// now tells the time the retention is counted back from
+	now func() time.Time
+}
+
+// NewArchiveService creates an ArchiveService
+func NewArchiveService(repo ArchiveRepo, db DB, config ArchiveConfig) *ArchiveService {
+	if config.RetentionMonths <= 0 {
+		config.RetentionMonths = defaultArchiveRetentionMonths
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultArchiveBatchSize
+	}
+	if config.BatchPause <= 0 {
+		config.BatchPause = defaultArchiveBatchPause
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultArchiveInterval
+	}
+	return &ArchiveService{
+		repo:            repo,
+		db:              db,
+		retentionMonths: config.RetentionMonths,
+		batchSize:       config.BatchSize,
+		batchPause:      config.BatchPause,
+		interval:        config.Interval,
+		now:             time.Now,
+	}
+}
+
+// cutoff returns the time before which transactions are archived: the start of the
+// current UTC day, the retention ago
+func (s *ArchiveService) cutoff() time.Time {
+	return startOfDayUTC(s.now()).AddDate(0, -s.retentionMonths, 0)
+}
+
+// Reaches reports whether history starting at from may include archived transactions.
+// From the zero time, history has no start and always may.
+func (s *ArchiveService) Reaches(from time.Time) bool {
+	return from.IsZero() || from.Before(s.cutoff())
+}
+
+// Archive runs the archive job to the end: it resumes the run a crash or shutdown left
+// RUNNING, or starts one, and returns it once every transaction before its cutoff is
+// archived. Returns ErrArchiveInProgress when the job is already running.
+func (s *ArchiveService) Archive(ctx context.Context) (*models.ArchiveRun, error) {
+	run, err := s.claim(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.running.Store(false)
+	return s.archive(ctx, run)
+}
+
+// Start resumes or starts a run like Archive, but moves the transactions in the
+// background and returns the run at once. The run keeps going after ctx is done.
+func (s *ArchiveService) Start(ctx context.Context) (*models.ArchiveRun, error) {
+	run, err := s.claim(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer s.running.Store(false)
+		// archive logs its own failures
+		_, _ = s.archive(context.WithoutCancel(ctx), run)
+	}()
+	return run, nil
+}
+
+// claim marks the job running in this process and returns the run to carry on: the
+// latest one if it is still RUNNING, or a new one
+func (s *ArchiveService) claim(ctx context.Context) (*models.ArchiveRun, error) {
+	log := logger.WithOperation("archive_transactions").WithContext(ctx)
+	if !s.running.CompareAndSwap(false, true) {
+		log.Warn("Transaction archive already in progress")
+		return nil, ErrArchiveInProgress
+	}
+
+	run, err := s.repo.GetLatestArchiveRun(ctx)
+	if err == nil && run.Status == models.ArchiveRunRunning {
+		log.WithFields(logrus.Fields{
+			"run_id":   run.ID.String(),
+			"archived": run.Archived,
+		}).Info("Resuming transaction archive run")
+		return run, nil
+	}
+	if err != nil && !errors.Is(err, repositories.ErrArchiveRunNotFound) {
+		s.running.Store(false)
+		log.WithField("error", err.Error()).Error("Failed to get latest archive run")
+		return nil, err
+	}
+
+	run, err = s.repo.CreateArchiveRun(ctx, s.cutoff())
+	if err != nil {
+		s.running.Store(false)
+		log.WithField("error", err.Error()).Error("Failed to create archive run")
+		return nil, err
+	}
+	log.WithFields(logrus.Fields{
+		"run_id": run.ID.String(),
+		"cutoff": run.Cutoff,
+	}).Info("Transaction archive run started")
+	return run, nil
+}
+
+// archive moves the transactions before the run's cutoff a batch at a time, each batch
+// in its own database transaction together with the run's count, so a crash loses no
+// more than the batch in flight and that batch is moved whole by the next run. The run
+// is marked COMPLETED when a batch comes up short, or FAILED when one fails. When ctx
+// is done it is left RUNNING for the next run to resume.
+func (s *ArchiveService) archive(ctx context.Context, run *models.ArchiveRun) (*models.ArchiveRun, error) {
+	runID := run.ID.String()
+	log := logger.WithFields(logrus.Fields{
+		"operation": "archive_transactions",
+		"run_id":    runID,
+		"cutoff":    run.Cutoff,
+	}).WithContext(ctx)
+
+	for {
+		moved, err := s.archiveBatch(ctx, log, runID, run.Cutoff)
+		if ctx.Err() != nil {
+			log.WithField("archived", run.Archived).Warn("Transaction archive interrupted, will resume")
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Transaction archive failed")
+			if _, finishErr := s.repo.FinishArchiveRun(ctx, runID, err); finishErr != nil {
+				log.WithField("error", finishErr.Error()).Warn("Failed to mark archive run failed")
+			}
+			return nil, err
+		}
+		run.Archived += moved
+		log.WithFields(logrus.Fields{
+			"batch":    moved,
+			"archived": run.Archived,
+		}).Debug("Transaction batch archived")
+		if moved < int64(s.batchSize) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			log.WithField("archived", run.Archived).Warn("Transaction archive interrupted, will resume")
+			return nil, ctx.Err()
+		case <-time.After(s.batchPause):
+		}
+	}
+
+	finished, err := s.repo.FinishArchiveRun(ctx, runID, nil)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to mark archive run completed")
+		return nil, err
+	}
+	log.WithField("archived", finished.Archived).Info("Transaction archive run completed")
+	return finished, nil
+}
+
+// archiveBatch moves one batch of transactions in its own database transaction
+func (s *ArchiveService) archiveBatch(ctx context.Context, log *logrus.Entry, runID string, cutoff time.Time) (moved int64, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return 0, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "archive transactions", err); err != nil {
+			moved = 0
+		}
+	}()
+
+	return s.repo.ArchiveTransactionsTx(ctx, tx, runID, cutoff, s.batchSize)
+}
+
+// Progress returns the latest archive run, with the transactions it still has to move
+// counted while it is RUNNING. Returns repositories.ErrArchiveRunNotFound when the job
+// has never run.
+func (s *ArchiveService) Progress(ctx context.Context) (*models.ArchiveRun, error) {
+	run, err := s.repo.GetLatestArchiveRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == models.ArchiveRunRunning {
+		remaining, err := s.repo.CountArchivableTransactions(ctx, run.Cutoff)
+		if err != nil {
+			return nil, err
+		}
+		run.Remaining = &remaining
+	}
+	return run, nil
+}
+
+// RunWorker runs the archive job every interval until ctx is cancelled. A run left
+// RUNNING by a crash is resumed on start.
+func (s *ArchiveService) RunWorker(ctx context.Context) {
+	log := logger.WithOperation("archive_worker").WithContext(ctx)
+	log.WithFields(logrus.Fields{
+		"interval":         s.interval.String(),
+		"retention_months": s.retentionMonths,
+		"batch_size":       s.batchSize,
+	}).Info("Archive worker started")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		// Archive logs its own failures, and a run started by an admin is left to finish
+		_, _ = s.Archive(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info("Archive worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+var defaultArchiveService *ArchiveService
+
+// SetDefaultArchiveService sets the service used by StartTransactionArchive,
+// GetTransactionArchiveProgress and ArchiveReaches
+func SetDefaultArchiveService(service *ArchiveService) {
+	defaultArchiveService = service
+}
+
+func StartTransactionArchive(ctx context.Context) (*models.ArchiveRun, error) {
+	if defaultArchiveService == nil {
+		panic("default archive service not initialized - call SetDefaultArchiveService first")
+	}
+	return defaultArchiveService.Start(ctx)
+}
+
+func GetTransactionArchiveProgress(ctx context.Context) (*models.ArchiveRun, error) {
+	if defaultArchiveService == nil {
+		panic("default archive service not initialized - call SetDefaultArchiveService first")
+	}
+	return defaultArchiveService.Progress(ctx)
+}
+
+func ArchiveReaches(from time.Time) bool {
+	if defaultArchiveService == nil {
+		panic("default archive service not initialized - call SetDefaultArchiveService first")
+	}
+	return defaultArchiveService.Reaches(from)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockArchiveRepo struct {
+	mock.Mock
+}
+
+func (m *MockArchiveRepo) CreateArchiveRun(ctx context.Context, cutoff time.Time) (*models.ArchiveRun, error) {
+	args := m.Called(ctx, cutoff)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ArchiveRun), args.Error(1)
+}
+
+func (m *MockArchiveRepo) GetLatestArchiveRun(ctx context.Context) (*models.ArchiveRun, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ArchiveRun), args.Error(1)
+}
+
+func (m *MockArchiveRepo) FinishArchiveRun(ctx context.Context, id string, runErr error) (*models.ArchiveRun, error) {
+	args := m.Called(ctx, id, runErr)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ArchiveRun), args.Error(1)
+}
+
+func (m *MockArchiveRepo) CountArchivableTransactions(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockArchiveRepo) ArchiveTransactionsTx(ctx context.Context, tx pgx.Tx, runID string, cutoff time.Time, limit int) (int64, error) {
+	args := m.Called(ctx, tx, runID, cutoff, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func newTestArchiveService(repo *MockArchiveRepo, db pgxmock.PgxPoolIface) *ArchiveService {
+	service := NewArchiveService(repo, db, ArchiveConfig{RetentionMonths: 24, BatchSize: 100, BatchPause: time.Millisecond})
+	service.now = func() time.Time { return time.Date(2025, time.March, 10, 15, 30, 0, 0, time.UTC) }
+	return service
+}
+
+func TestArchiveService_Archive_MovesBatchesUntilShort(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	repo := new(MockArchiveRepo)
+
+	cutoff := time.Date(2023, time.March, 10, 0, 0, 0, 0, time.UTC)
+	run := &models.ArchiveRun{ID: uuid.New(), Cutoff: cutoff, Status: models.ArchiveRunRunning}
+	runID := run.ID.String()
+	repo.On("GetLatestArchiveRun", mock.Anything).Return(nil, repositories.ErrArchiveRunNotFound)
+	repo.On("CreateArchiveRun", mock.Anything, cutoff).Return(run, nil)
+	for _, moved := range []int64{100, 100, 40} {
+		mockDB.ExpectBegin()
+		repo.On("ArchiveTransactionsTx", mock.Anything, mock.Anything, runID, cutoff, 100).Return(moved, nil).Once()
+		mockDB.ExpectCommit()
+	}
+	repo.On("FinishArchiveRun", mock.Anything, runID, nil).
+		Return(&models.ArchiveRun{ID: run.ID, Cutoff: cutoff, Status: models.ArchiveRunCompleted, Archived: 240}, nil)
+
+	finished, err := newTestArchiveService(repo, mockDB).Archive(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.ArchiveRunCompleted, finished.Status)
+	assert.Equal(t, int64(240), finished.Archived)
+	repo.AssertNumberOfCalls(t, "ArchiveTransactionsTx", 3)
+	repo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestArchiveService_Archive_ResumesRunningRun(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	repo := new(MockArchiveRepo)
+
+	// A run cut short by a crash keeps the cutoff it started with
+	cutoff := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	run := &models.ArchiveRun{ID: uuid.New(), Cutoff: cutoff, Status: models.ArchiveRunRunning, Archived: 5000}
+	runID := run.ID.String()
+	repo.On("GetLatestArchiveRun", mock.Anything).Return(run, nil)
+	mockDB.ExpectBegin()
+	repo.On("ArchiveTransactionsTx", mock.Anything, mock.Anything, runID, cutoff, 100).Return(int64(12), nil).Once()
+	mockDB.ExpectCommit()
+	repo.On("FinishArchiveRun", mock.Anything, runID, nil).
+		Return(&models.ArchiveRun{ID: run.ID, Cutoff: cutoff, Status: models.ArchiveRunCompleted, Archived: 5012}, nil)
+
+	finished, err := newTestArchiveService(repo, mockDB).Archive(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5012), finished.Archived)
+	repo.AssertNotCalled(t, "CreateArchiveRun", mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestArchiveService_Archive_FailedBatchMarksRunFailed(t *testing.T) {
+	mockDB, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	repo := new(MockArchiveRepo)
+
+	run := &models.ArchiveRun{ID: uuid.New(), Cutoff: time.Date(2023, time.March, 10, 0, 0, 0, 0, time.UTC), Status: models.ArchiveRunRunning}
+	failure := errors.New("disk full")
+	repo.On("GetLatestArchiveRun", mock.Anything).Return(&models.ArchiveRun{Status: models.ArchiveRunCompleted}, nil)
+	repo.On("CreateArchiveRun", mock.Anything, run.Cutoff).Return(run, nil)
+	mockDB.ExpectBegin()
+	repo.On("ArchiveTransactionsTx", mock.Anything, mock.Anything, run.ID.String(), run.Cutoff, 100).Return(int64(0), failure)
+	mockDB.ExpectRollback()
+	repo.On("FinishArchiveRun", mock.Anything, run.ID.String(), failure).Return(&models.ArchiveRun{Status: models.ArchiveRunFailed}, nil)
+
+	service := newTestArchiveService(repo, mockDB)
+	_, err = service.Archive(context.Background())
+
+	assert.ErrorIs(t, err, failure)
+	repo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+	// The job can be started again once the run ended
+	assert.False(t, service.running.Load())
+}
+
+func TestArchiveService_Start_RejectsWhileRunning(t *testing.T) {
+	repo := new(MockArchiveRepo)
+	service := newTestArchiveService(repo, nil)
+	service.running.Store(true)
+
+	_, err := service.Start(context.Background())
+
+	assert.ErrorIs(t, err, ErrArchiveInProgress)
+	repo.AssertNotCalled(t, "GetLatestArchiveRun", mock.Anything)
+}
+
+func TestArchiveService_Progress(t *testing.T) {
+	repo := new(MockArchiveRepo)
+	cutoff := time.Date(2023, time.March, 10, 0, 0, 0, 0, time.UTC)
+	repo.On("GetLatestArchiveRun", mock.Anything).
+		Return(&models.ArchiveRun{ID: uuid.New(), Cutoff: cutoff, Status: models.ArchiveRunRunning, Archived: 3000}, nil)
+	repo.On("CountArchivableTransactions", mock.Anything, cutoff).Return(int64(700), nil)
+
+	run, err := newTestArchiveService(repo, nil).Progress(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3000), run.Archived)
+	if assert.NotNil(t, run.Remaining) {
+		assert.Equal(t, int64(700), *run.Remaining)
+	}
+}
+
+func TestArchiveService_Reaches(t *testing.T) {
+	service := newTestArchiveService(new(MockArchiveRepo), nil)
+
+	assert.True(t, service.Reaches(time.Time{}))
+	assert.True(t, service.Reaches(time.Date(2023, time.March, 9, 23, 0, 0, 0, time.UTC)))
+	assert.False(t, service.Reaches(time.Date(2023, time.March, 10, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxBatchItems is the most recipients a single batch transfer can pay
+const MaxBatchItems = 100
+
+// Failures specific to batch transfers
+var (
+	ErrInvalidBatch       = errors.New("invalid batch")
+	ErrDuplicateRecipient = errors.New("recipient appears more than once in the batch")
+)
+
+// BatchItem is one payment of a batch transfer
+type BatchItem struct {
+	ToUserID string
+	Amount   money.Amount
+}
+
+// BatchItemError identifies the item of a batch transfer that made it fail. It wraps
+// the cause, so callers can still match it with errors.Is.
+type BatchItemError struct {
+	Index    int
+	ToUserID string
+	Err      error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("item %d (user %s): %v", e.Index, e.ToUserID, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error { return e.Err }
+
+// BatchTransfer pays every item from the default wallet of fromUserID to the default
+// wallet of the item's recipient, all or nothing. Each item is charged the fee of a
+// single transfer of its amount, and the sender must cover all amounts and fees at
+// once. Every leg is recorded under one batch ID, returned as the result's BatchID.
+// When a recipient's wallet is missing, frozen, closed or in another currency, nothing
+// moves and the returned *BatchItemError names the item.
+func (s *WalletService) BatchTransfer(ctx context.Context, fromUserID string, items []BatchItem) (*models.BatchTransferResult, error) {
+	log := logger.WithFields(logrus.Fields{
+		"from_user_id": fromUserID,
+		"items":        len(items),
+		"operation":    "batch_transfer",
+	}).WithContext(ctx)
+	log.Info("Starting batch transfer")
+
+	if len(items) == 0 {
+		log.Warn("Batch transfer has no items")
+		return nil, fmt.Errorf("%w: no items", ErrInvalidBatch)
+	}
+	if len(items) > MaxBatchItems {
+		log.Warn("Batch transfer has too many items")
+		return nil, fmt.Errorf("%w: at most %d items are allowed, got %d", ErrInvalidBatch, MaxBatchItems, len(items))
+	}
+
+	var total, fee money.Amount
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		var err error
+		switch {
+		case item.ToUserID == fromUserID:
+			err = ErrSelfTransfer
+		case seen[item.ToUserID]:
+			err = ErrDuplicateRecipient
+		default:
+			err = s.ValidateAmount(item.Amount)
+		}
+		if err != nil {
+			log.WithFields(logrus.Fields{"item": i, "validation_error": err.Error()}).Warn("Batch transfer validation failed")
+			return nil, &BatchItemError{Index: i, ToUserID: item.ToUserID, Err: err}
+		}
+		seen[item.ToUserID] = true
+		total += item.Amount
+		if fromUserID != s.fees.WalletUserID {
+			// The fee wallet does not pay fees to itself
+			fee += s.fees.Fee(item.Amount)
+		}
+	}
+
+	// All legs share one batch ID, kept stable across retries
+	batchID := uuid.New()
+	log = log.WithFields(logrus.Fields{"batch_id": batchID.String(), "total": total, "fee": fee})
+
+	var result *models.BatchTransferResult
+	var events walletEvents
+	err := s.retryTx(ctx, log, func() (err error) {
+		events = walletEvents{}
+		result, err = s.batchTransfer(ctx, log, batchID, fromUserID, items, total, fee, &events)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, fromUserID)
+	for _, item := range items {
+		s.invalidateWallets(ctx, item.ToUserID)
+	}
+	if fee > 0 {
+		s.invalidateWallets(ctx, s.fees.WalletUserID)
+	}
+	s.notify(ctx, events)
+	return result, nil
+}
+
+// batchTransfer runs a single attempt of BatchTransfer inside its own database
+// transaction, collecting what it will notify in events
+func (s *WalletService) batchTransfer(ctx context.Context, log *logrus.Entry, batchID uuid.UUID, fromUserID string, items []BatchItem, total, fee money.Amount, events *walletEvents) (result *models.BatchTransferResult, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "batch transfer", err); err != nil {
+			result = nil
+		}
+	}()
+
+	from := defaultWallet(fromUserID)
+	feeRef := defaultWallet(s.fees.WalletUserID)
+	itemIndex := make(map[walletRef]int, len(items))
+	wallets := []walletRef{from}
+	for i, item := range items {
+		to := defaultWallet(item.ToUserID)
+		itemIndex[to] = i
+		wallets = append(wallets, to)
+	}
+	if _, paid := itemIndex[feeRef]; fee > 0 && !paid {
+		wallets = append(wallets, feeRef)
+	}
+	if err = s.lockWallets(ctx, tx, walletOwners(wallets)...); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallets")
+		return nil, err
+	}
+
+	// As in transfer, balances are updated in a consistent wallet order so that
+	// concurrent batches and transfers touching the same wallets cannot deadlock
+	var fromWallet, feeWallet *models.Wallet
+	toWallets := make([]*models.Wallet, len(items))
+	for _, ref := range walletOrder(wallets...) {
+		if ref == from {
+			fromWallet, err = s.debit(ctx, tx, from, total+fee)
+			if errors.Is(err, ErrInsufficientBalance) {
+				log.Warn("Insufficient balance for batch transfer")
+				return nil, fmt.Errorf("%w: %s cannot cover %s", ErrInsufficientBalance, from, total+fee)
+			}
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to update from user balance")
+				return nil, err
+			}
+			if err = checkActive(fromWallet, from); err != nil {
+				log.WithField("status", fromWallet.Status).Warn("Batch transfer rejected, from wallet is not active")
+				return nil, err
+			}
+			if err = s.checkVelocity(ctx, tx, fromWallet); err != nil {
+				log.WithField("error", err.Error()).Warn("Too many operations on the from wallet")
+				return nil, err
+			}
+			if err = s.checkMonthlyTransferLimit(ctx, tx, fromWallet, total); err != nil {
+				log.WithField("error", err.Error()).Warn("Monthly transfer limit exceeded")
+				return nil, err
+			}
+			// The batch counts as a single operation of its total
+			if err = s.checkKycLimits(ctx, tx, fromUserID, total); err != nil {
+				log.WithField("error", err.Error()).Warn("Batch transfer rejected by KYC tier limits")
+				return nil, err
+			}
+			if err = s.checkEmailVerified(ctx, tx, fromUserID, total); err != nil {
+				log.WithField("error", err.Error()).Warn("Batch transfer rejected, email not verified")
+				return nil, err
+			}
+			continue
+		}
+		if i, ok := itemIndex[ref]; ok {
+			toWallets[i], err = s.credit(ctx, tx, ref, items[i].Amount)
+			if err == nil {
+				err = checkActive(toWallets[i], ref)
+			}
+			if err != nil {
+				log.WithFields(logrus.Fields{"item": i, "error": err.Error()}).Warn("Batch transfer rejected, recipient cannot be paid")
+				return nil, &BatchItemError{Index: i, ToUserID: items[i].ToUserID, Err: err}
+			}
+		}
+		if fee > 0 && ref == feeRef {
+			feeWallet, err = s.credit(ctx, tx, feeRef, fee)
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to credit fee wallet")
+				return nil, err
+			}
+		}
+	}
+	if feeWallet != nil && feeWallet.Currency != fromWallet.Currency {
+		log.WithFields(logrus.Fields{"from_currency": fromWallet.Currency, "fee_currency": feeWallet.Currency}).Warn("Batch transfer rejected, fees are collected in another currency")
+		return nil, fmt.Errorf("%w: fees are collected in %s, %s holds %s", ErrCurrencyMismatch, feeWallet.Currency, from, fromWallet.Currency)
+	}
+
+	entries := make([]models.LedgerEntry, 0, 2*len(items)+2)
+	for i, item := range items {
+		toWallet := toWallets[i]
+		if err = checkPrecision(fromWallet, item.Amount); err != nil {
+			log.WithFields(logrus.Fields{"item": i, "currency": fromWallet.Currency}).Warn("Batch transfer rejected, amount does not fit the wallet's currency")
+			return nil, &BatchItemError{Index: i, ToUserID: item.ToUserID, Err: err}
+		}
+		if toWallet.Currency != fromWallet.Currency {
+			log.WithFields(logrus.Fields{"item": i, "from_currency": fromWallet.Currency, "to_currency": toWallet.Currency}).Warn("Batch transfer rejected, wallets hold different currencies")
+			return nil, &BatchItemError{Index: i, ToUserID: item.ToUserID, Err: fmt.Errorf("%w: %s holds %s, recipient holds %s", ErrCurrencyMismatch, from, fromWallet.Currency, toWallet.Currency)}
+		}
+
+		toUserID := item.ToUserID
+		out := &models.Transaction{
+			WalletID:      fromWallet.ID,
+			Type:          models.TransactionTypeTransferOut,
+			Status:        models.TransactionStatusCompleted,
+			Amount:        item.Amount,
+			RelatedUserID: &toUserID,
+			TransferID:    &batchID,
+		}
+		err = s.transactionRepo.CreateTransactionTx(ctx, tx, out)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to record transfer out transaction")
+			return nil, err
+		}
+		err = s.checkRisk(ctx, tx, log, RiskOperation{
+			Type:          models.TransactionTypeTransferOut,
+			UserID:        fromUserID,
+			Wallet:        fromWallet,
+			TransactionID: out.ID,
+			Amount:        item.Amount,
+			RelatedUserID: &toUserID,
+			At:            s.now(),
+		})
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to check batch transfer against risk rules")
+			return nil, err
+		}
+		err = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+			WalletID:      toWallet.ID,
+			Type:          models.TransactionTypeTransferIn,
+			Status:        models.TransactionStatusCompleted,
+			Amount:        item.Amount,
+			RelatedUserID: &fromUserID,
+			TransferID:    &batchID,
+		})
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to record transfer in transaction")
+			return nil, err
+		}
+		entries = append(entries,
+			walletLedgerEntry(batchID, fromWallet.ID, models.LedgerDebit, item.Amount),
+			walletLedgerEntry(batchID, toWallet.ID, models.LedgerCredit, item.Amount),
+		)
+	}
+	if fee > 0 {
+		if err = s.recordFee(ctx, tx, batchID, fromUserID, fromWallet.ID, feeWallet.ID, fee); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to record batch transfer fee")
+			return nil, err
+		}
+		entries = append(entries,
+			walletLedgerEntry(batchID, fromWallet.ID, models.LedgerDebit, fee),
+			walletLedgerEntry(batchID, feeWallet.ID, models.LedgerCredit, fee),
+		)
+	}
+	if err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, entries); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record batch transfer ledger entries")
+		return nil, err
+	}
+
+	log.WithField("from_balance_after", fromWallet.Balance).Info("Batch transfer completed successfully")
+	now := s.now()
+	for i, item := range items {
+		events.transferReceived(batchID, fromUserID, item.ToUserID, toWallets[i], item.Amount, nil, now)
+	}
+	events.debited(fromUserID, fromWallet, total+fee, s.lowBalance, now)
+	return &models.BatchTransferResult{BatchID: batchID, Items: len(items), Total: total, Fee: fee}, nil
+}
+
+func BatchTransfer(ctx context.Context, fromUserID string, items []BatchItem) (*models.BatchTransferResult, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.BatchTransfer(ctx, fromUserID, items)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_BatchTransfer(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	sender := uuid.New().String()
+	feeUserID := uuid.New().String()
+	alice, bob := uuid.New().String(), uuid.New().String()
+	senderWallet := &models.Wallet{ID: uuid.New(), Balance: 6900, Currency: "USD"}
+	feeWallet := &models.Wallet{ID: uuid.New(), Balance: 100, Currency: "USD"}
+	aliceWallet := &models.Wallet{ID: uuid.New(), Balance: 2000, Currency: "USD"}
+	bobWallet := &models.Wallet{ID: uuid.New(), Balance: 1000, Currency: "USD"}
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, sender, money.Amount(3100)).Return(senderWallet, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, alice, money.Amount(2000)).Return(aliceWallet, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, bob, money.Amount(1000)).Return(bobWallet, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, feeUserID, money.Amount(100)).Return(feeWallet, nil)
+	var recorded []*models.Transaction
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { recorded = append(recorded, args.Get(2).(*models.Transaction)) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		Fees: FeePolicy{Flat: 50, WalletUserID: feeUserID},
+	})
+	result, err := service.BatchTransfer(context.Background(), sender, []BatchItem{
+		{ToUserID: alice, Amount: 2000},
+		{ToUserID: bob, Amount: 1000},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Items)
+	assert.Equal(t, money.Amount(3000), result.Total)
+	assert.Equal(t, money.Amount(100), result.Fee)
+	// Two legs per recipient plus the fee and its collection
+	assert.Len(t, recorded, 6)
+	for _, txn := range recorded {
+		assert.Equal(t, result.BatchID, *txn.TransferID)
+	}
+	mockWalletRepo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_BatchTransfer_RecipientCannotBePaid(t *testing.T) {
+	sender := "00000000-0000-0000-0000-000000000001"
+	alice := "00000000-0000-0000-0000-000000000002"
+	bob := "00000000-0000-0000-0000-000000000003"
+
+	tests := []struct {
+		name        string
+		bobWallet   *models.Wallet
+		bobErr      error
+		expectedErr error
+	}{
+		{"frozen", &models.Wallet{ID: uuid.New(), Status: models.WalletStatusFrozen, Currency: "USD"}, nil, ErrWalletFrozen},
+		{"closed", &models.Wallet{ID: uuid.New(), Status: models.WalletStatusClosed, Currency: "USD"}, nil, ErrWalletClosed},
+		{"missing", nil, repositories.ErrWalletNotFound, ErrWalletNotFound},
+		{"other currency", &models.Wallet{ID: uuid.New(), Currency: "EUR"}, nil, ErrCurrencyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			mockDB.ExpectRollback()
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, sender, money.Amount(3000)).
+				Return(&models.Wallet{ID: uuid.New(), Balance: 0, Currency: "USD"}, nil)
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, alice, money.Amount(2000)).
+				Return(&models.Wallet{ID: uuid.New(), Currency: "USD"}, nil)
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, bob, money.Amount(1000)).
+				Return(tt.bobWallet, tt.bobErr)
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			result, err := service.BatchTransfer(context.Background(), sender, []BatchItem{
+				{ToUserID: alice, Amount: 2000},
+				{ToUserID: bob, Amount: 1000},
+			})
+
+			assert.Nil(t, result)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			var itemErr *BatchItemError
+			if assert.ErrorAs(t, err, &itemErr) {
+				assert.Equal(t, 1, itemErr.Index)
+				assert.Equal(t, bob, itemErr.ToUserID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet(), "nothing should move")
+		})
+	}
+}
+
+func TestWalletService_BatchTransfer_InsufficientBalance(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	sender := "00000000-0000-0000-0000-000000000001"
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, sender, money.Amount(3000)).
+		Return(nil, repositories.ErrInsufficientBalance)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	result, err := service.BatchTransfer(context.Background(), sender, []BatchItem{
+		{ToUserID: "00000000-0000-0000-0000-000000000002", Amount: 2000},
+		{ToUserID: "00000000-0000-0000-0000-000000000003", Amount: 1000},
+	})
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	mockWalletRepo.AssertNotCalled(t, "CreditWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_BatchTransfer_Validation(t *testing.T) {
+	sender := uuid.New().String()
+	recipient := uuid.New().String()
+	tooMany := make([]BatchItem, MaxBatchItems+1)
+	for i := range tooMany {
+		tooMany[i] = BatchItem{ToUserID: uuid.New().String(), Amount: 100}
+	}
+
+	tests := []struct {
+		name        string
+		items       []BatchItem
+		expectedErr error
+		failedItem  int
+	}{
+		{"no items", nil, ErrInvalidBatch, -1},
+		{"too many items", tooMany, ErrInvalidBatch, -1},
+		{"zero amount", []BatchItem{{ToUserID: recipient, Amount: 100}, {ToUserID: uuid.New().String(), Amount: 0}}, ErrInvalidAmount, 1},
+		{"pays the sender", []BatchItem{{ToUserID: sender, Amount: 100}}, ErrSelfTransfer, 0},
+		{"duplicate recipient", []BatchItem{{ToUserID: recipient, Amount: 100}, {ToUserID: recipient, Amount: 200}}, ErrDuplicateRecipient, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewWalletService(nil, nil, nil, WalletServiceConfig{})
+
+			_, err := service.BatchTransfer(context.Background(), sender, tt.items)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			var itemErr *BatchItemError
+			if tt.failedItem < 0 {
+				assert.False(t, errors.As(err, &itemErr))
+			} else if assert.ErrorAs(t, err, &itemErr) {
+				assert.Equal(t, tt.failedItem, itemErr.Index)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// maxNicknameLength matches the size of the beneficiaries.nickname column
+const maxNicknameLength = 100
+
+// Failures returned when managing beneficiaries
+var (
+	ErrBeneficiaryNotFound = repositories.ErrBeneficiaryNotFound
+	ErrBeneficiaryExists   = repositories.ErrBeneficiaryExists
+	ErrSelfBeneficiary     = errors.New("cannot add yourself as a beneficiary")
+	ErrInvalidNickname     = errors.New("invalid nickname")
+)
+
+// BeneficiaryRepo stores the beneficiaries users save
+type BeneficiaryRepo interface {
+	CreateBeneficiary(ctx context.Context, b *models.Beneficiary) error
+	GetBeneficiariesByOwnerID(ctx context.Context, ownerID string) ([]models.Beneficiary, error)
+	GetBeneficiary(ctx context.Context, ownerID, id string) (*models.Beneficiary, error)
+	DeleteBeneficiary(ctx context.Context, ownerID, id string) error
+}
+
+// BeneficiaryService manages the contacts users transfer money to
+type BeneficiaryService struct {
+	repo BeneficiaryRepo
+}
+
+// NewBeneficiaryService creates a BeneficiaryService storing beneficiaries in repo
+func NewBeneficiaryService(repo BeneficiaryRepo) *BeneficiaryService {
+	return &BeneficiaryService{repo: repo}
+}
+
+// Add saves beneficiaryUserID to ownerID's beneficiaries under an optional nickname.
+// Returns ErrSelfBeneficiary when both are the same user, ErrUserNotFound when either
+// user does not exist, and ErrBeneficiaryExists when the owner already saved the user.
+func (s *BeneficiaryService) Add(ctx context.Context, ownerID, beneficiaryUserID, nickname string) (*models.Beneficiary, error) {
+	log := logger.WithUser(ownerID).WithContext(ctx).WithFields(logrus.Fields{
+		"beneficiary_user_id": beneficiaryUserID,
+		"operation":           "add_beneficiary",
+	})
+	log.Info("Adding beneficiary")
+
+	if ownerID == beneficiaryUserID {
+		log.Warn("Adding yourself as a beneficiary blocked")
+		return nil, ErrSelfBeneficiary
+	}
+	owner, err := uuid.Parse(ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner_user_id: %w", err)
+	}
+	beneficiary, err := uuid.Parse(beneficiaryUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid beneficiary_user_id: %w", err)
+	}
+
+	b := &models.Beneficiary{OwnerUserID: owner, BeneficiaryUserID: beneficiary}
+	if nickname = strings.TrimSpace(nickname); nickname != "" {
+		if n := utf8.RuneCountInString(nickname); n > maxNicknameLength {
+			log.Warn("Beneficiary nickname too long")
+			return nil, fmt.Errorf("%w: nickname is %d characters, at most %d allowed", ErrInvalidNickname, n, maxNicknameLength)
+		}
+		b.Nickname = &nickname
+	}
+
+	if err = s.repo.CreateBeneficiary(ctx, b); err != nil {
+		if errors.Is(err, ErrBeneficiaryExists) || errors.Is(err, ErrUserNotFound) {
+			log.WithField("error", err.Error()).Warn("Beneficiary rejected")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to add beneficiary")
+		}
+		return nil, err
+	}
+
+	log.WithField("beneficiary_id", b.ID.String()).Info("Beneficiary added successfully")
+	return b, nil
+}
+
+// List returns ownerID's beneficiaries in the order they were saved
+func (s *BeneficiaryService) List(ctx context.Context, ownerID string) ([]models.Beneficiary, error) {
+	return s.repo.GetBeneficiariesByOwnerID(ctx, ownerID)
+}
+
+// Remove deletes one of ownerID's beneficiaries
+func (s *BeneficiaryService) Remove(ctx context.Context, ownerID, beneficiaryID string) error {
+	log := logger.WithUser(ownerID).WithContext(ctx).WithFields(logrus.Fields{
+		"beneficiary_id": beneficiaryID,
+		"operation":      "remove_beneficiary",
+	})
+	log.Info("Removing beneficiary")
+
+	if err := s.repo.DeleteBeneficiary(ctx, ownerID, beneficiaryID); err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to remove beneficiary")
+		return err
+	}
+
+	log.Info("Beneficiary removed successfully")
+	return nil
+}
+
+// Resolve returns the user ID saved as one of ownerID's beneficiaries, so a transfer
+// can name the beneficiary instead. A beneficiary of another user is reported as
+// ErrBeneficiaryNotFound.
+func (s *BeneficiaryService) Resolve(ctx context.Context, ownerID, beneficiaryID string) (string, error) {
+	b, err := s.repo.GetBeneficiary(ctx, ownerID, beneficiaryID)
+	if err != nil {
+		return "", err
+	}
+	return b.BeneficiaryUserID.String(), nil
+}
+
+var defaultBeneficiaryService *BeneficiaryService
+
+// SetDefaultBeneficiaryService sets the service used by the beneficiary functions
+func SetDefaultBeneficiaryService(service *BeneficiaryService) {
+	defaultBeneficiaryService = service
+}
+
+func AddBeneficiary(ctx context.Context, ownerID, beneficiaryUserID, nickname string) (*models.Beneficiary, error) {
+	if defaultBeneficiaryService == nil {
+		panic("default beneficiary service not initialized - call SetDefaultBeneficiaryService first")
+	}
+	return defaultBeneficiaryService.Add(ctx, ownerID, beneficiaryUserID, nickname)
+}
+
+func GetBeneficiaries(ctx context.Context, ownerID string) ([]models.Beneficiary, error) {
+	if defaultBeneficiaryService == nil {
+		panic("default beneficiary service not initialized - call SetDefaultBeneficiaryService first")
+	}
+	return defaultBeneficiaryService.List(ctx, ownerID)
+}
+
+func RemoveBeneficiary(ctx context.Context, ownerID, beneficiaryID string) error {
+	if defaultBeneficiaryService == nil {
+		panic("default beneficiary service not initialized - call SetDefaultBeneficiaryService first")
+	}
+	return defaultBeneficiaryService.Remove(ctx, ownerID, beneficiaryID)
+}
+
+func ResolveBeneficiary(ctx context.Context, ownerID, beneficiaryID string) (string, error) {
+	if defaultBeneficiaryService == nil {
+		panic("default beneficiary service not initialized - call SetDefaultBeneficiaryService first")
+	}
+	return defaultBeneficiaryService.Resolve(ctx, ownerID, beneficiaryID)
+}
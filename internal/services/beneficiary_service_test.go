@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBeneficiaryRepo struct {
+	mock.Mock
+}
+
+func (m *MockBeneficiaryRepo) CreateBeneficiary(ctx context.Context, b *models.Beneficiary) error {
+	args := m.Called(ctx, b)
+	return args.Error(0)
+}
+
+func (m *MockBeneficiaryRepo) GetBeneficiariesByOwnerID(ctx context.Context, ownerID string) ([]models.Beneficiary, error) {
+	args := m.Called(ctx, ownerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Beneficiary), args.Error(1)
+}
+
+func (m *MockBeneficiaryRepo) GetBeneficiary(ctx context.Context, ownerID, id string) (*models.Beneficiary, error) {
+	args := m.Called(ctx, ownerID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Beneficiary), args.Error(1)
+}
+
+func (m *MockBeneficiaryRepo) DeleteBeneficiary(ctx context.Context, ownerID, id string) error {
+	args := m.Called(ctx, ownerID, id)
+	return args.Error(0)
+}
+
+func TestBeneficiaryService_Add(t *testing.T) {
+	owner, other := uuid.New().String(), uuid.New().String()
+	mum := "Mum"
+
+	tests := []struct {
+		name             string
+		beneficiaryID    string
+		nickname         string
+		repoErr          error
+		expectedErr      error
+		expectedNickname *string
+	}{
+		{name: "success", beneficiaryID: other, nickname: "  Mum ", expectedNickname: &mum},
+		{name: "no nickname", beneficiaryID: other},
+		{name: "yourself", beneficiaryID: owner, expectedErr: ErrSelfBeneficiary},
+		{name: "nickname too long", beneficiaryID: other, nickname: strings.Repeat("a", maxNicknameLength+1), expectedErr: ErrInvalidNickname},
+		{name: "unknown user", beneficiaryID: other, repoErr: repositories.ErrUserNotFound, expectedErr: ErrUserNotFound},
+		{name: "already saved", beneficiaryID: other, repoErr: fmt.Errorf("%w: %s", repositories.ErrBeneficiaryExists, other), expectedErr: ErrBeneficiaryExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockBeneficiaryRepo)
+			repo.On("CreateBeneficiary", mock.Anything, mock.Anything).Return(tt.repoErr).Maybe()
+
+			service := NewBeneficiaryService(repo)
+			b, err := service.Add(context.Background(), owner, tt.beneficiaryID, tt.nickname)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, b)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, owner, b.OwnerUserID.String())
+			assert.Equal(t, other, b.BeneficiaryUserID.String())
+			assert.Equal(t, tt.expectedNickname, b.Nickname)
+		})
+	}
+}
+
+func TestBeneficiaryService_Add_SelfNeverReachesRepo(t *testing.T) {
+	repo := new(MockBeneficiaryRepo)
+	user := uuid.New().String()
+
+	_, err := NewBeneficiaryService(repo).Add(context.Background(), user, user, "me")
+
+	assert.ErrorIs(t, err, ErrSelfBeneficiary)
+	repo.AssertNotCalled(t, "CreateBeneficiary", mock.Anything, mock.Anything)
+}
+
+func TestBeneficiaryService_Resolve(t *testing.T) {
+	owner := uuid.New().String()
+	saved := &models.Beneficiary{ID: uuid.New(), BeneficiaryUserID: uuid.New()}
+	missing := uuid.New().String()
+
+	repo := new(MockBeneficiaryRepo)
+	repo.On("GetBeneficiary", mock.Anything, owner, saved.ID.String()).Return(saved, nil)
+	repo.On("GetBeneficiary", mock.Anything, owner, missing).Return(nil, repositories.ErrBeneficiaryNotFound)
+	service := NewBeneficiaryService(repo)
+
+	userID, err := service.Resolve(context.Background(), owner, saved.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, saved.BeneficiaryUserID.String(), userID)
+
+	_, err = service.Resolve(context.Background(), owner, missing)
+	assert.ErrorIs(t, err, ErrBeneficiaryNotFound)
+}
+
+func TestBeneficiaryService_Remove(t *testing.T) {
+	owner, id := uuid.New().String(), uuid.New().String()
+	repo := new(MockBeneficiaryRepo)
+	repo.On("DeleteBeneficiary", mock.Anything, owner, id).Return(repositories.ErrBeneficiaryNotFound)
+
+	err := NewBeneficiaryService(repo).Remove(context.Background(), owner, id)
+
+	assert.ErrorIs(t, err, ErrBeneficiaryNotFound)
+}
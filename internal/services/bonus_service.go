@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// BonusCampaignKey is the metadata key a bonus records its campaign under, so the
+// bonuses of a campaign can be found with the transaction history's metadata filter
+const BonusCampaignKey = "campaign"
+
+// maxCampaignLength is the longest campaign identifier, in characters, a bonus can carry
+const maxCampaignLength = 100
+
+// ErrInvalidCampaign is returned when a bonus has no campaign identifier, or one that
+// is too long
+var ErrInvalidCampaign = errors.New("invalid campaign")
+
+// GrantBonus credits a promotional bonus of amount to a user's wallet on behalf of a
+// marketing campaign. The bonus is recorded as a BONUS transaction, not a deposit, with
+// the campaign identifier in its metadata, and is balanced in the ledger by the BONUS
+// account rather than cash. Frozen and closed wallets cannot receive bonuses.
+func (s *WalletService) GrantBonus(ctx context.Context, userID string, amount money.Amount, campaign string) (*models.Transaction, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation": "grant_bonus",
+		"amount":    amount,
+		"campaign":  campaign,
+	})
+	log.Info("Starting bonus grant")
+
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Bonus validation failed")
+		return nil, err
+	}
+	campaign = strings.TrimSpace(campaign)
+	if campaign == "" || len([]rune(campaign)) > maxCampaignLength {
+		log.Warn("Bonus without a valid campaign rejected")
+		return nil, fmt.Errorf("%w: a campaign of at most %d characters is required", ErrInvalidCampaign, maxCampaignLength)
+	}
+
+	var bonus *models.Transaction
+	err := s.retryTx(ctx, log, func() (err error) {
+		bonus, err = s.grantBonus(ctx, log, defaultWallet(userID), amount, campaign)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return bonus, nil
+}
+
+// grantBonus runs a single attempt of GrantBonus inside its own database transaction
+func (s *WalletService) grantBonus(ctx context.Context, log *logrus.Entry, ref walletRef, amount money.Amount, campaign string) (bonus *models.Transaction, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "grant_bonus", err); err != nil {
+			bonus = nil
+		}
+	}()
+
+	if err = s.lockWallets(ctx, tx, ref.userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+
+	return s.creditBonus(ctx, tx, log, ref, amount, map[string]string{BonusCampaignKey: campaign})
+}
+
+// creditBonus credits a bonus to a wallet the caller has locked within tx and records
+// the BONUS transaction, carrying metadata, and its ledger entries. It fails with
+// ErrWalletFrozen or ErrWalletClosed unless the wallet is active.
+func (s *WalletService) creditBonus(ctx context.Context, tx pgx.Tx, log *logrus.Entry, ref walletRef, amount money.Amount, metadata map[string]string) (*models.Transaction, error) {
+	wallet, err := s.credit(ctx, tx, ref, amount)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update wallet balance")
+		return nil, err
+	}
+	if err := checkActive(wallet, ref); err != nil {
+		log.WithField("status", wallet.Status).Warn("Bonus rejected, wallet is not active")
+		return nil, err
+	}
+	if err := checkPrecision(wallet, amount); err != nil {
+		log.WithField("currency", wallet.Currency).Warn("Bonus rejected, amount does not fit the wallet's currency")
+		return nil, err
+	}
+
+	bonus := &models.Transaction{
+		WalletID: wallet.ID,
+		Type:     models.TransactionTypeBonus,
+		Status:   models.TransactionStatusCompleted,
+		Amount:   amount,
+		Metadata: metadata,
+	}
+	if err := s.transactionRepo.CreateTransactionTx(ctx, tx, bonus); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record bonus transaction")
+		return nil, err
+	}
+	groupID := uuid.New()
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		bonusLedgerEntry(groupID, models.LedgerDebit, amount),
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerCredit, amount),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record bonus ledger entries")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"balance_before": wallet.Balance - amount,
+		"balance_after":  wallet.Balance,
+	}).Info("Bonus granted successfully")
+	return bonus, nil
+}
+
+// bonusLedgerEntry builds a ledger entry against the system bonus account, which
+// balances the promotional credits granted by campaigns
+func bonusLedgerEntry(groupID uuid.UUID, direction models.LedgerDirection, amount money.Amount) models.LedgerEntry {
+	return models.LedgerEntry{
+		GroupID:   groupID,
+		Account:   models.LedgerAccountBonus,
+		Direction: direction,
+		Amount:    amount,
+	}
+}
+
+func GrantBonus(ctx context.Context, userID string, amount money.Amount, campaign string) (*models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GrantBonus(ctx, userID, amount, campaign)
+}
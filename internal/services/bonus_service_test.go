@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_GrantBonus(t *testing.T) {
+	tests := []struct {
+		name          string
+		amount        money.Amount
+		campaign      string
+		status        models.WalletStatus
+		expectedErrIs error
+	}{
+		{"active wallet", money.MustParse("10.00"), " spring-2025 ", models.WalletStatusActive, nil},
+		{"frozen wallet", money.MustParse("10.00"), "spring-2025", models.WalletStatusFrozen, ErrWalletFrozen},
+		{"closed wallet", money.MustParse("10.00"), "spring-2025", models.WalletStatusClosed, ErrWalletClosed},
+		{"no campaign", money.MustParse("10.00"), "  ", models.WalletStatusActive, ErrInvalidCampaign},
+		{"campaign too long", money.MustParse("10.00"), strings.Repeat("x", 101), models.WalletStatusActive, ErrInvalidCampaign},
+		{"zero amount", 0, "spring-2025", models.WalletStatusActive, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			var recorded *models.Transaction
+			var entries []models.LedgerEntry
+			mockTxRepo.ExpectedCalls = nil
+			validRequest := tt.expectedErrIs != ErrInvalidCampaign && tt.expectedErrIs != ErrInvalidAmount
+			if validRequest {
+				mockDB.ExpectBegin()
+				if tt.expectedErrIs == nil {
+					mockDB.ExpectCommit()
+				} else {
+					mockDB.ExpectRollback()
+				}
+			}
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", tt.amount).
+				Return(&models.Wallet{ID: walletID, Status: tt.status, Currency: "USD", Balance: tt.amount}, nil).Maybe()
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { recorded = args.Get(2).(*models.Transaction) }).Return(nil).Maybe()
+			mockTxRepo.On("CreateLedgerEntriesTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { entries = args.Get(2).([]models.LedgerEntry) }).Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			bonus, err := service.GrantBonus(context.Background(), "user1", tt.amount, tt.campaign)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, bonus)
+				assert.Nil(t, recorded, "nothing should be recorded for a rejected bonus")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, models.TransactionTypeBonus, bonus.Type)
+				assert.Equal(t, tt.amount, bonus.Amount)
+				assert.Equal(t, map[string]string{BonusCampaignKey: "spring-2025"}, bonus.Metadata)
+				if assert.Len(t, entries, 2) {
+					assert.Equal(t, models.LedgerAccountBonus, entries[0].Account)
+					assert.Equal(t, models.LedgerDebit, entries[0].Direction)
+					assert.Equal(t, models.LedgerAccountWallet, entries[1].Account)
+					assert.Equal(t, models.LedgerCredit, entries[1].Direction)
+				}
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
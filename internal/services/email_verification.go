@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// emailVerificationTTL is how long an email verification token can be used after
+	// it is sent
+	emailVerificationTTL = 24 * time.Hour
+	// verificationEmailWindow is the period maxVerificationEmails counts emails over
+	verificationEmailWindow = time.Hour
+	// maxVerificationEmails is how many verification emails, the one sent at signup
+	// included, a user is sent in any hour
+	maxVerificationEmails = 3
+)
+
+// Email verification failures
+var (
+	// ErrEmailNotVerified is returned for a withdrawal, transfer, batch transfer or hold
+	// over WalletServiceConfig.UnverifiedEmailLimit by a user who has not verified their
+	// email
+	ErrEmailNotVerified = errors.New("email not verified")
+	// ErrInvalidVerificationToken is returned when an email verification token is
+	// unknown, was already used, has expired or was sent to an email the user changed
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	// ErrEmailAlreadyVerified is returned when asking to verify an email that is
+	// verified already
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+	// ErrTooManyVerificationEmails is returned when a user was sent as many
+	// verification emails in the last hour as they may be
+	ErrTooManyVerificationEmails = errors.New("too many verification emails")
+)
+
+// EmailVerificationRepo stores the tokens emailed to users to verify their email, and
+// marks the users verified
+type EmailVerificationRepo interface {
+	CreateEmailVerificationTokenTx(ctx context.Context, tx pgx.Tx, token *models.EmailVerificationToken) error
+	GetEmailVerificationTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.EmailVerificationToken, error)
+	UseEmailVerificationTokensTx(ctx context.Context, tx pgx.Tx, userID string) error
+	CountEmailVerificationTokensSinceTx(ctx context.Context, tx pgx.Tx, userID string, since time.Time) (int, *time.Time, error)
+	GetUserForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error)
+	MarkEmailVerifiedTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error)
+}
+
+// EmailVerificationService has users prove they own their email with a single-use
+// token emailed to them at signup, or again on request
+type EmailVerificationService struct {
+	repo   EmailVerificationRepo
+	mailer Mailer
+	db     DB
+	// now tells the time tokens expire and resends are counted against
+	now func() time.Time
+}
+
+// NewEmailVerificationService creates an EmailVerificationService that emails tokens
+// through mailer
+func NewEmailVerificationService(repo EmailVerificationRepo, mailer Mailer, db DB) *EmailVerificationService {
+	return &EmailVerificationService{
+		repo:   repo,
+		mailer: mailer,
+		db:     db,
+		now:    time.Now,
+	}
+}
+
+// verificationEmail is a token saved for a user, to email them once its transaction
+// commits
+type verificationEmail struct {
+	to        string
+	token     string
+	expiresAt time.Time
+}
+
+// issueTx saves a new email verification token for user within tx, and returns the
+// email that carries it
+func (s *EmailVerificationService) issueTx(ctx context.Context, tx pgx.Tx, user *models.User) (*verificationEmail, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	record := &models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hashToken(token),
+		ExpiresAt: s.now().Add(emailVerificationTTL),
+	}
+	if err := s.repo.CreateEmailVerificationTokenTx(ctx, tx, record); err != nil {
+		return nil, err
+	}
+	return &verificationEmail{to: user.Email, token: token, expiresAt: record.ExpiresAt}, nil
+}
+
+// send emails a verification token to its user
+func (s *EmailVerificationService) send(ctx context.Context, email *verificationEmail) error {
+	return s.mailer.Send(ctx, Email{
+		To:      email.to,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Use this token to verify your email before %s: %s\n\nIf you did not sign up, ignore this email.",
+			email.expiresAt.UTC().Format(time.RFC1123), email.token),
+	})
+}
+
+// Verify marks the user a verification token was sent to verified, and uses up every
+// token they were sent. Returns the verified user, or ErrInvalidVerificationToken,
+// saying why, when the token is unknown, was already used, has expired or was sent to
+// an email the user has changed since.
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) (user *models.User, err error) {
+	log := logger.WithOperation("verify_email").WithContext(ctx)
+	log.Info("Verifying email with token")
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "verify email", err); err != nil {
+			user = nil
+		}
+	}()
+
+	record, err := s.repo.GetEmailVerificationTokenForUpdateTx(ctx, tx, hashToken(token))
+	if errors.Is(err, repositories.ErrEmailVerificationTokenNotFound) {
+		log.Warn("Email verification with an unknown token rejected")
+		return nil, fmt.Errorf("%w: unknown token", ErrInvalidVerificationToken)
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get email verification token")
+		return nil, err
+	}
+	log = log.WithField("user_id", record.UserID.String())
+	if record.UsedAt != nil {
+		log.Warn("Email verification with a used token rejected")
+		return nil, fmt.Errorf("%w: the token was already used", ErrInvalidVerificationToken)
+	}
+	if !s.now().Before(record.ExpiresAt) {
+		log.Warn("Email verification with an expired token rejected")
+		return nil, fmt.Errorf("%w: the token has expired", ErrInvalidVerificationToken)
+	}
+
+	// The user stays locked until the commit, so their email cannot change meanwhile
+	user, err = s.repo.GetUserForUpdateTx(ctx, tx, record.UserID.String())
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			// The user was deleted after the token was sent
+			log.Warn("Email verification for a deleted user rejected")
+			return nil, fmt.Errorf("%w: unknown token", ErrInvalidVerificationToken)
+		}
+		log.WithField("error", err.Error()).Error("Failed to get user")
+		return nil, err
+	}
+	// Changing only the case of an email keeps it verified, so it keeps the token too
+	if !strings.EqualFold(user.Email, record.Email) {
+		log.Warn("Email verification with a token sent to a previous email rejected")
+		return nil, fmt.Errorf("%w: the email changed since the token was sent", ErrInvalidVerificationToken)
+	}
+
+	user, err = s.repo.MarkEmailVerifiedTx(ctx, tx, record.UserID.String())
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to mark email verified")
+		return nil, err
+	}
+	if err = s.repo.UseEmailVerificationTokensTx(ctx, tx, record.UserID.String()); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to use email verification tokens")
+		return nil, err
+	}
+	log.Info("Email verified successfully")
+	return user, nil
+}
+
+// Resend emails a user a new verification token; the ones sent before keep working
+// until they expire. Returns ErrUserNotFound for an unknown user,
+// ErrEmailAlreadyVerified when there is nothing to verify, and ErrTooManyVerificationEmails,
+// with how long to wait as RetryAfter tells, once the user was sent 3 in the last hour.
+func (s *EmailVerificationService) Resend(ctx context.Context, userID string) error {
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "resend_verification_email")
+	log.Info("Resending verification email")
+
+	email, err := s.issueResend(ctx, log, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.send(ctx, email); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to send verification email")
+		return err
+	}
+	log.Info("Verification email sent")
+	return nil
+}
+
+// issueResend saves the token Resend emails inside its own database transaction. The
+// user's row stays locked until it commits, so concurrent resends are counted one
+// after the other.
+func (s *EmailVerificationService) issueResend(ctx context.Context, log *logrus.Entry, userID string) (email *verificationEmail, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "resend verification email", err); err != nil {
+			email = nil
+		}
+	}()
+
+	user, err := s.repo.GetUserForUpdateTx(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			log.Warn("User not found")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to get user")
+		}
+		return nil, err
+	}
+	if user.EmailVerifiedAt != nil {
+		log.Warn("Verification email for a verified user rejected")
+		return nil, ErrEmailAlreadyVerified
+	}
+
+	now := s.now()
+	count, oldest, err := s.repo.CountEmailVerificationTokensSinceTx(ctx, tx, userID, now.Add(-verificationEmailWindow))
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to count verification emails")
+		return nil, err
+	}
+	if count >= maxVerificationEmails {
+		// Another email may be sent once the oldest in the window leaves it
+		after := verificationEmailWindow
+		if oldest != nil {
+			after = oldest.Add(verificationEmailWindow).Sub(now)
+		}
+		if after < time.Second {
+			after = time.Second
+		}
+		log.WithField("count", count).Warn("Too many verification emails")
+		return nil, &retryAfterError{
+			err:   fmt.Errorf("%w: at most %d are sent per hour", ErrTooManyVerificationEmails, maxVerificationEmails),
+			after: after,
+		}
+	}
+
+	email, err = s.issueTx(ctx, tx, user)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to save email verification token")
+		return nil, err
+	}
+	return email, nil
+}
+
+// checkEmailVerified returns ErrEmailNotVerified when amount is over the unverified
+// email limit and the user has not verified their email. Users are only looked up for
+// amounts over the limit.
+func (s *WalletService) checkEmailVerified(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) error {
+	if s.unverifiedLimit <= 0 || amount <= s.unverifiedLimit {
+		return nil
+	}
+	verified, err := s.walletRepo.IsUserEmailVerifiedTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("%w: verify your email to move more than %s at once", ErrEmailNotVerified, s.unverifiedLimit)
+	}
+	return nil
+}
+
+var defaultEmailVerificationService *EmailVerificationService
+
+// SetDefaultEmailVerificationService sets the service used by VerifyEmail and
+// ResendVerificationEmail
+func SetDefaultEmailVerificationService(service *EmailVerificationService) {
+	defaultEmailVerificationService = service
+}
+
+func VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	if defaultEmailVerificationService == nil {
+		panic("default email verification service not initialized - call SetDefaultEmailVerificationService first")
+	}
+	return defaultEmailVerificationService.Verify(ctx, token)
+}
+
+func ResendVerificationEmail(ctx context.Context, userID string) error {
+	if defaultEmailVerificationService == nil {
+		panic("default email verification service not initialized - call SetDefaultEmailVerificationService first")
+	}
+	return defaultEmailVerificationService.Resend(ctx, userID)
+}
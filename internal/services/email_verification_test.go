@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEmailVerificationRepo struct {
+	mock.Mock
+}
+
+func (m *MockEmailVerificationRepo) CreateEmailVerificationTokenTx(ctx context.Context, tx pgx.Tx, token *models.EmailVerificationToken) error {
+	args := m.Called(ctx, tx, token)
+	return args.Error(0)
+}
+
+func (m *MockEmailVerificationRepo) GetEmailVerificationTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.EmailVerificationToken, error) {
+	args := m.Called(ctx, tx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.EmailVerificationToken), args.Error(1)
+}
+
+func (m *MockEmailVerificationRepo) UseEmailVerificationTokensTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	args := m.Called(ctx, tx, userID)
+	return args.Error(0)
+}
+
+func (m *MockEmailVerificationRepo) CountEmailVerificationTokensSinceTx(ctx context.Context, tx pgx.Tx, userID string, since time.Time) (int, *time.Time, error) {
+	args := m.Called(ctx, tx, userID, since)
+	if args.Get(1) == nil {
+		return args.Int(0), nil, args.Error(2)
+	}
+	return args.Int(0), args.Get(1).(*time.Time), args.Error(2)
+}
+
+func (m *MockEmailVerificationRepo) GetUserForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error) {
+	args := m.Called(ctx, tx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockEmailVerificationRepo) MarkEmailVerifiedTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error) {
+	args := m.Called(ctx, tx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func TestWalletService_CheckEmailVerified(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     string // empty when no limit is configured
+		amount    string
+		verified  bool
+		lookupErr error
+		wantErr   error
+		wantCheck bool
+	}{
+		{name: "no limit", amount: "1000000.00"},
+		{name: "unverified, up to the limit", limit: "50.00", amount: "50.00"},
+		{name: "unverified, over the limit", limit: "50.00", amount: "50.01", wantErr: ErrEmailNotVerified, wantCheck: true},
+		{name: "verified, over the limit", limit: "50.00", amount: "50.01", verified: true, wantCheck: true},
+		{name: "lookup failure", limit: "50.00", amount: "100.00", lookupErr: repositories.ErrUserNotFound, wantErr: repositories.ErrUserNotFound, wantCheck: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo := new(MockWalletRepo)
+			mockWalletRepo.On("IsUserEmailVerifiedTx", mock.Anything, mock.Anything, "user1").Return(tt.verified, tt.lookupErr).Maybe()
+			var config WalletServiceConfig
+			if tt.limit != "" {
+				config.UnverifiedEmailLimit = money.MustParse(tt.limit)
+			}
+
+			service := NewWalletService(mockWalletRepo, new(MockTransactionRepo), nil, config)
+			err := service.checkEmailVerified(context.Background(), nil, "user1", money.MustParse(tt.amount))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tt.wantCheck {
+				mockWalletRepo.AssertCalled(t, "IsUserEmailVerifiedTx", mock.Anything, mock.Anything, "user1")
+			} else {
+				mockWalletRepo.AssertNotCalled(t, "IsUserEmailVerifiedTx", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestWalletService_Withdraw_UnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  money.Amount
+		wantErr error
+	}{
+		{name: "up to the limit goes through", amount: money.MustParse("50.00")},
+		{name: "over the limit is rejected", amount: money.MustParse("50.01"), wantErr: ErrEmailNotVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", tt.amount).Return(&models.Wallet{ID: uuid.New(), Balance: 10000, Currency: "USD"}, nil)
+			mockWalletRepo.On("IsUserEmailVerifiedTx", mock.Anything, mock.Anything, "user1").Return(false, nil).Maybe()
+			if tt.wantErr != nil {
+				mockDB.ExpectRollback()
+			} else {
+				mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(nil)
+				mockDB.ExpectCommit()
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{UnverifiedEmailLimit: money.MustParse("50.00")})
+			wallet, _, err := service.Withdraw(context.Background(), "user1", tt.amount, "", "", nil)
+
+			if tt.wantErr != nil {
+				assert.Nil(t, wallet)
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, "verify your email to move more than 50.00 at once")
+				mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				mockWalletRepo.AssertNotCalled(t, "IsUserEmailVerifiedTx", mock.Anything, mock.Anything, mock.Anything)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_Transfer_UnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		wantErr  error
+	}{
+		{name: "unverified sender is rejected", wantErr: ErrEmailNotVerified},
+		{name: "verified sender goes through", verified: true},
+	}
+
+	amount := money.MustParse("100.00")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", amount).Return(&models.Wallet{ID: uuid.New(), Balance: 10000, Currency: "USD"}, nil)
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", amount).Return(&models.Wallet{ID: uuid.New(), Balance: 20000, Currency: "USD"}, nil).Maybe()
+			mockWalletRepo.On("IsUserEmailVerifiedTx", mock.Anything, mock.Anything, "user1").Return(tt.verified, nil)
+			if tt.wantErr != nil {
+				mockDB.ExpectRollback()
+			} else {
+				mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(nil).Twice()
+				mockDB.ExpectCommit()
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{UnverifiedEmailLimit: money.MustParse("50.00")})
+			_, err = service.Transfer(context.Background(), "user1", "user2", amount, "", "", nil)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockWalletRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_BatchTransfer_UnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		wantErr  error
+	}{
+		{name: "unverified sender is rejected", wantErr: ErrEmailNotVerified},
+		{name: "verified sender goes through", verified: true},
+	}
+
+	sender := "00000000-0000-0000-0000-000000000001"
+	alice := "00000000-0000-0000-0000-000000000002"
+	bob := "00000000-0000-0000-0000-000000000003"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			// Each item is under the limit, but the batch as a whole is over it
+			mockDB.ExpectBegin()
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, sender, money.MustParse("80.00")).Return(&models.Wallet{ID: uuid.New(), Balance: 10000, Currency: "USD"}, nil)
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, alice, money.MustParse("40.00")).Return(&models.Wallet{ID: uuid.New(), Currency: "USD"}, nil).Maybe()
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, bob, money.MustParse("40.00")).Return(&models.Wallet{ID: uuid.New(), Currency: "USD"}, nil).Maybe()
+			mockWalletRepo.On("IsUserEmailVerifiedTx", mock.Anything, mock.Anything, sender).Return(tt.verified, nil)
+			if tt.wantErr != nil {
+				mockDB.ExpectRollback()
+			} else {
+				mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(nil).Times(4)
+				mockDB.ExpectCommit()
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{UnverifiedEmailLimit: money.MustParse("50.00")})
+			result, err := service.BatchTransfer(context.Background(), sender, []BatchItem{
+				{ToUserID: alice, Amount: money.MustParse("40.00")},
+				{ToUserID: bob, Amount: money.MustParse("40.00")},
+			})
+
+			if tt.wantErr != nil {
+				assert.Nil(t, result)
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockWalletRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_Hold_UnverifiedEmail(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	amount := money.MustParse("100.00")
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, "user1", amount).
+		Return(&models.Wallet{ID: uuid.New(), Balance: 20000, HeldAmount: amount, Currency: "USD"}, nil)
+	mockWalletRepo.On("IsUserEmailVerifiedTx", mock.Anything, mock.Anything, "user1").Return(false, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{UnverifiedEmailLimit: money.MustParse("50.00")})
+	hold, err := service.Hold(context.Background(), "user1", amount)
+
+	assert.Nil(t, hold)
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	mockWalletRepo.AssertNotCalled(t, "CreateHoldTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestEmailVerificationService_Verify(t *testing.T) {
+	userID := uuid.New()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	usedAt := now.Add(-time.Hour)
+	current := &models.User{ID: userID, Email: "jane@example.com"}
+
+	tests := []struct {
+		name      string
+		token     *models.EmailVerificationToken
+		tokenErr  error
+		user      *models.User // the user the token was sent to, as they are now
+		userErr   error
+		wantErr   error
+		wantInErr string
+	}{
+		{
+			name:  "valid token",
+			token: &models.EmailVerificationToken{UserID: userID, Email: "jane@example.com", ExpiresAt: now.Add(time.Minute)},
+			user:  current,
+		},
+		{
+			name:  "email changed only in case",
+			token: &models.EmailVerificationToken{UserID: userID, Email: "Jane@Example.com", ExpiresAt: now.Add(time.Minute)},
+			user:  current,
+		},
+		{
+			name:      "unknown token",
+			tokenErr:  repositories.ErrEmailVerificationTokenNotFound,
+			wantErr:   ErrInvalidVerificationToken,
+			wantInErr: "unknown token",
+		},
+		{
+			name:      "reused token",
+			token:     &models.EmailVerificationToken{UserID: userID, Email: "jane@example.com", ExpiresAt: now.Add(time.Minute), UsedAt: &usedAt},
+			wantErr:   ErrInvalidVerificationToken,
+			wantInErr: "already used",
+		},
+		{
+			name:      "expired token",
+			token:     &models.EmailVerificationToken{UserID: userID, Email: "jane@example.com", ExpiresAt: now},
+			wantErr:   ErrInvalidVerificationToken,
+			wantInErr: "expired",
+		},
+		{
+			name:      "deleted user",
+			token:     &models.EmailVerificationToken{UserID: userID, Email: "jane@example.com", ExpiresAt: now.Add(time.Minute)},
+			userErr:   repositories.ErrUserNotFound,
+			wantErr:   ErrInvalidVerificationToken,
+			wantInErr: "unknown token",
+		},
+		{
+			name:      "token sent before the email changed",
+			token:     &models.EmailVerificationToken{UserID: userID, Email: "old@example.com", ExpiresAt: now.Add(time.Minute)},
+			user:      current,
+			wantErr:   ErrInvalidVerificationToken,
+			wantInErr: "email changed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+			mockDB.ExpectBegin()
+			if tt.wantErr == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			repo := new(MockEmailVerificationRepo)
+			if tt.token != nil {
+				repo.On("GetEmailVerificationTokenForUpdateTx", mock.Anything, mock.Anything, hashToken("t0k3n")).Return(tt.token, nil)
+			} else {
+				repo.On("GetEmailVerificationTokenForUpdateTx", mock.Anything, mock.Anything, hashToken("t0k3n")).Return(nil, tt.tokenErr)
+			}
+			if tt.userErr != nil {
+				repo.On("GetUserForUpdateTx", mock.Anything, mock.Anything, userID.String()).Return(nil, tt.userErr)
+			} else if tt.user != nil {
+				repo.On("GetUserForUpdateTx", mock.Anything, mock.Anything, userID.String()).Return(tt.user, nil)
+			}
+			verified := &models.User{ID: userID, Email: "jane@example.com", EmailVerifiedAt: &now}
+			if tt.wantErr == nil {
+				repo.On("MarkEmailVerifiedTx", mock.Anything, mock.Anything, userID.String()).Return(verified, nil)
+				repo.On("UseEmailVerificationTokensTx", mock.Anything, mock.Anything, userID.String()).Return(nil)
+			}
+
+			service := NewEmailVerificationService(repo, &recordingMailer{}, mockDB)
+			service.now = func() time.Time { return now }
+			user, err := service.Verify(context.Background(), "t0k3n")
+
+			if tt.wantErr != nil {
+				assert.Nil(t, user)
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Contains(t, err.Error(), tt.wantInErr)
+				repo.AssertNotCalled(t, "MarkEmailVerifiedTx", mock.Anything, mock.Anything, mock.Anything)
+				repo.AssertNotCalled(t, "UseEmailVerificationTokensTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, verified, user)
+			}
+			repo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEmailVerificationService_Resend(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	unverified := &models.User{ID: uuid.New(), Email: "jane@example.com"}
+	oldest := now.Add(-40 * time.Minute)
+
+	t.Run("sends a new token", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+		mockDB.ExpectBegin()
+		mockDB.ExpectCommit()
+		repo := new(MockEmailVerificationRepo)
+		repo.On("GetUserForUpdateTx", mock.Anything, mock.Anything, unverified.ID.String()).Return(unverified, nil)
+		repo.On("CountEmailVerificationTokensSinceTx", mock.Anything, mock.Anything, unverified.ID.String(), now.Add(-time.Hour)).Return(2, &oldest, nil)
+		var saved *models.EmailVerificationToken
+		repo.On("CreateEmailVerificationTokenTx", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(2).(*models.EmailVerificationToken)
+		}).Return(nil)
+		mailer := &recordingMailer{}
+
+		service := NewEmailVerificationService(repo, mailer, mockDB)
+		service.now = func() time.Time { return now }
+		err = service.Resend(context.Background(), unverified.ID.String())
+
+		assert.NoError(t, err)
+		if assert.Len(t, mailer.sent, 1) && assert.NotNil(t, saved) {
+			assert.Equal(t, "jane@example.com", mailer.sent[0].To)
+			assert.Equal(t, now.Add(24*time.Hour), saved.ExpiresAt)
+			assert.Equal(t, "jane@example.com", saved.Email)
+			token := mailer.sent[0].Body[strings.Index(mailer.sent[0].Body, ": ")+2 : strings.Index(mailer.sent[0].Body, "\n")]
+			assert.Equal(t, hashToken(token), saved.TokenHash)
+		}
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+		mockDB.ExpectBegin()
+		mockDB.ExpectRollback()
+		repo := new(MockEmailVerificationRepo)
+		repo.On("GetUserForUpdateTx", mock.Anything, mock.Anything, unverified.ID.String()).Return(unverified, nil)
+		repo.On("CountEmailVerificationTokensSinceTx", mock.Anything, mock.Anything, unverified.ID.String(), now.Add(-time.Hour)).Return(3, &oldest, nil)
+		mailer := &recordingMailer{}
+
+		service := NewEmailVerificationService(repo, mailer, mockDB)
+		service.now = func() time.Time { return now }
+		err = service.Resend(context.Background(), unverified.ID.String())
+
+		assert.ErrorIs(t, err, ErrTooManyVerificationEmails)
+		after, ok := RetryAfter(err)
+		assert.True(t, ok)
+		assert.Equal(t, 20*time.Minute, after)
+		assert.Empty(t, mailer.sent)
+		repo.AssertNotCalled(t, "CreateEmailVerificationTokenTx", mock.Anything, mock.Anything, mock.Anything)
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+
+	t.Run("already verified", func(t *testing.T) {
+		mockDB, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		defer mockDB.Close()
+		mockDB.ExpectBegin()
+		mockDB.ExpectRollback()
+		repo := new(MockEmailVerificationRepo)
+		repo.On("GetUserForUpdateTx", mock.Anything, mock.Anything, unverified.ID.String()).Return(&models.User{ID: unverified.ID, EmailVerifiedAt: &oldest}, nil)
+		mailer := &recordingMailer{}
+
+		err = NewEmailVerificationService(repo, mailer, mockDB).Resend(context.Background(), unverified.ID.String())
+
+		assert.ErrorIs(t, err, ErrEmailAlreadyVerified)
+		assert.Empty(t, mailer.sent)
+		assert.NoError(t, mockDB.ExpectationsWereMet())
+	})
+}
+
+func TestRegistrationService_CreateUserWithWallet_SendsVerificationEmail(t *testing.T) {
+	created := &models.User{ID: uuid.New(), Username: "testuser", Email: "test@example.com"}
+
+	tests := []struct {
+		name    string
+		mailErr error
+	}{
+		{name: "email sent"},
+		{name: "failing to send does not fail the signup", mailErr: errors.New("smtp down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.CreateUserRequest{Username: "testuser", FirstName: "Test", LastName: "User", Email: "test@example.com", Password: "password"}
+			mockUserRepo := new(MockUserRepo)
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			mockUserRepo.On("IsEmailExistsTx", mock.Anything, mock.Anything, "test@example.com").Return(false, nil)
+			mockUserRepo.On("IsUsernameExistsTx", mock.Anything, mock.Anything, "testuser").Return(false, nil)
+			mockUserRepo.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil)
+			mockWalletRepo.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(&models.Wallet{UserID: created.ID}, nil)
+			verifyRepo := new(MockEmailVerificationRepo)
+			verifyRepo.On("CreateEmailVerificationTokenTx", mock.Anything, mock.Anything, mock.MatchedBy(func(token *models.EmailVerificationToken) bool {
+				return token.UserID == created.ID
+			})).Return(nil)
+			mockDB.ExpectCommit()
+			mailer := &recordingMailer{err: tt.mailErr}
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			verification := NewEmailVerificationService(verifyRepo, mailer, mockDB)
+			user, _, err := NewRegistrationService(mockUserRepo, wallets, mockDB, verification).CreateUserWithWallet(context.Background(), req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, created, user)
+			if assert.Len(t, mailer.sent, 1) {
+				assert.Equal(t, "test@example.com", mailer.sent[0].To)
+				assert.Equal(t, "Verify your email", mailer.sent[0].Subject)
+			}
+			verifyRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
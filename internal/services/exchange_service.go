@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrSameCurrency is returned when exchanging between wallets that hold the same currency
+var ErrSameCurrency = errors.New("wallets hold the same currency")
+
+// Exchange converts amount from one of a user's wallets into another of their wallets
+// holding a different currency, at the rate the configured RateProvider quotes. The
+// source wallet is debited amount and the target wallet credited the converted amount,
+// rounded to the target currency's minor units, atomically. Both legs are recorded as
+// EXCHANGE transactions sharing the exchange ID as their transfer ID, each carrying
+// the rate and the converted amount.
+func (s *WalletService) Exchange(ctx context.Context, userID, fromWalletID, toWalletID string, amount money.Amount) (*models.ExchangeResult, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"from_wallet_id": fromWalletID,
+		"to_wallet_id":   toWalletID,
+		"amount":         amount,
+		"operation":      "exchange",
+	})
+	log.Info("Starting currency exchange")
+
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Exchange validation failed")
+		return nil, err
+	}
+	if fromWalletID == toWalletID {
+		log.Warn("Exchange into the same wallet blocked")
+		return nil, ErrSameWallet
+	}
+
+	// A wallet's currency never changes, so the rate can be settled before any lock is taken
+	var wallets [2]*models.Wallet
+	for i, walletID := range []string{fromWalletID, toWalletID} {
+		wallet, err := s.walletRepo.GetWalletByID(ctx, walletID)
+		if err != nil {
+			log.WithField("error", err.Error()).Warn("Failed to find wallet")
+			return nil, err
+		}
+		if wallet.UserID.String() != userID {
+			log.WithField("wallet_id", walletID).Warn("Exchange with another user's wallet blocked")
+			return nil, fmt.Errorf("%w: wallet %s does not belong to user %s", ErrWalletNotFound, walletID, userID)
+		}
+		wallets[i] = wallet
+	}
+	fromWallet, toWallet := wallets[0], wallets[1]
+	if fromWallet.Currency == toWallet.Currency {
+		log.WithField("currency", fromWallet.Currency).Warn("Exchange between wallets of the same currency blocked")
+		return nil, fmt.Errorf("%w: both wallets hold %s, move the money instead", ErrSameCurrency, fromWallet.Currency)
+	}
+	if err := checkPrecision(fromWallet, amount); err != nil {
+		log.WithField("currency", fromWallet.Currency).Warn("Exchange rejected, amount does not fit the wallet's currency")
+		return nil, err
+	}
+
+	if s.rates == nil {
+		log.Warn("Exchange rejected, no exchange rates are configured")
+		return nil, fmt.Errorf("%w: no exchange rates are configured", ErrRateUnavailable)
+	}
+	rate, err := s.rates.Rate(ctx, fromWallet.Currency, toWallet.Currency)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to get exchange rate")
+		return nil, err
+	}
+	converted := amount.Convert(rate, toWallet.Currency)
+	if converted <= 0 {
+		log.WithField("rate", rate).Warn("Exchange rejected, amount converts to nothing")
+		return nil, fmt.Errorf("%w: %s %s is worth less than the smallest %s amount", ErrInvalidAmount, amount, fromWallet.Currency, toWallet.Currency)
+	}
+
+	// Both legs of the exchange share one ID, kept stable across retries
+	exchangeID := uuid.New()
+	log = log.WithFields(logrus.Fields{
+		"exchange_id":      exchangeID.String(),
+		"rate":             rate,
+		"converted_amount": converted,
+	})
+
+	from := walletRef{userID: userID, walletID: fromWalletID}
+	to := walletRef{userID: userID, walletID: toWalletID}
+	err = s.retryTx(ctx, log, func() error {
+		return s.exchange(ctx, log, exchangeID, from, to, amount, converted, rate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return &models.ExchangeResult{
+		ExchangeID:      exchangeID,
+		Amount:          amount,
+		FromCurrency:    fromWallet.Currency,
+		ConvertedAmount: converted,
+		ToCurrency:      toWallet.Currency,
+		Rate:            rate,
+	}, nil
+}
+
+// exchange runs a single attempt of Exchange inside its own database transaction
+func (s *WalletService) exchange(ctx context.Context, log *logrus.Entry, exchangeID uuid.UUID, from, to walletRef, amount, converted money.Amount, rate money.Rate) (err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return err
+	}
+	defer func() {
+		err = finishTx(ctx, log, tx, "exchange", err)
+	}()
+
+	if err = s.lockWallets(ctx, tx, from.userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallets")
+		return err
+	}
+
+	var fromWallet, toWallet *models.Wallet
+	for _, ref := range walletOrder(from, to) {
+		switch ref {
+		case from:
+			fromWallet, err = s.debit(ctx, tx, from, amount)
+			if errors.Is(err, ErrInsufficientBalance) {
+				log.Warn("Insufficient balance for exchange")
+				return fmt.Errorf("%w: %s cannot cover %s", ErrInsufficientBalance, from, amount)
+			}
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to update from wallet balance")
+				return err
+			}
+			if err = checkActive(fromWallet, from); err != nil {
+				log.WithField("status", fromWallet.Status).Warn("Exchange rejected, from wallet is not active")
+				return err
+			}
+		case to:
+			toWallet, err = s.credit(ctx, tx, to, converted)
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to update to wallet balance")
+				return err
+			}
+			if err = checkActive(toWallet, to); err != nil {
+				log.WithField("status", toWallet.Status).Warn("Exchange rejected, to wallet is not active")
+				return err
+			}
+		}
+	}
+
+	err = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+		WalletID:        fromWallet.ID,
+		Type:            models.TransactionTypeExchange,
+		Status:          models.TransactionStatusCompleted,
+		Amount:          -amount,
+		TransferID:      &exchangeID,
+		ExchangeRate:    &rate,
+		ConvertedAmount: &converted,
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record exchange out transaction")
+		return err
+	}
+	err = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+		WalletID:        toWallet.ID,
+		Type:            models.TransactionTypeExchange,
+		Status:          models.TransactionStatusCompleted,
+		Amount:          converted,
+		TransferID:      &exchangeID,
+		ExchangeRate:    &rate,
+		ConvertedAmount: &converted,
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record exchange in transaction")
+		return err
+	}
+	// Each currency balances against the exchange account on its own
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		walletLedgerEntry(exchangeID, fromWallet.ID, models.LedgerDebit, amount),
+		exchangeLedgerEntry(exchangeID, models.LedgerCredit, amount),
+		exchangeLedgerEntry(exchangeID, models.LedgerDebit, converted),
+		walletLedgerEntry(exchangeID, toWallet.ID, models.LedgerCredit, converted),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record exchange ledger entries")
+		return err
+	}
+
+	log.WithFields(logrus.Fields{
+		"from_balance_after": fromWallet.Balance,
+		"to_balance_after":   toWallet.Balance,
+	}).Info("Exchange completed successfully")
+	return nil
+}
+
+// exchangeLedgerEntry builds a ledger entry against the system exchange account, which
+// takes in the source currency of an exchange and pays out the target currency
+func exchangeLedgerEntry(groupID uuid.UUID, direction models.LedgerDirection, amount money.Amount) models.LedgerEntry {
+	return models.LedgerEntry{
+		GroupID:   groupID,
+		Account:   models.LedgerAccountExchange,
+		Direction: direction,
+		Amount:    amount,
+	}
+}
+
+func Exchange(ctx context.Context, userID, fromWalletID, toWalletID string, amount money.Amount) (*models.ExchangeResult, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Exchange(ctx, userID, fromWalletID, toWalletID, amount)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_Exchange(t *testing.T) {
+	tests := []struct {
+		name              string
+		amount            string
+		rate              string
+		toCurrency        string
+		expectedConverted string
+	}{
+		{"rounds down to whole yen", "10.00", "151.237", "JPY", "1512"},
+		{"rounds up to whole yen", "10.05", "151.237", "JPY", "1520"},
+		{"rounds to the nearest cent", "33.33", "0.9215", "EUR", "30.71"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			owner, usdWallet, targetWallet := uuid.New(), uuid.New(), uuid.New()
+			amount, converted := money.MustParse(tt.amount), money.MustParse(tt.expectedConverted)
+			rate, err := money.ParseRate(tt.rate)
+			assert.NoError(t, err)
+
+			var legs []*models.Transaction
+			var entries []models.LedgerEntry
+			mockTxRepo.ExpectedCalls = nil
+			mockDB.ExpectBegin()
+			mockDB.ExpectCommit()
+			mockWalletRepo.On("GetWalletByID", mock.Anything, usdWallet.String()).Return(&models.Wallet{ID: usdWallet, UserID: owner, Currency: "USD"}, nil)
+			mockWalletRepo.On("GetWalletByID", mock.Anything, targetWallet.String()).Return(&models.Wallet{ID: targetWallet, UserID: owner, Currency: tt.toCurrency}, nil)
+			mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, usdWallet.String(), amount).
+				Return(&models.Wallet{ID: usdWallet, UserID: owner, Currency: "USD"}, nil)
+			mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, targetWallet.String(), converted).
+				Return(&models.Wallet{ID: targetWallet, UserID: owner, Currency: tt.toCurrency, Balance: converted}, nil)
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).
+				Run(func(args mock.Arguments) { legs = append(legs, args.Get(2).(*models.Transaction)) }).Return(nil)
+			mockTxRepo.On("CreateLedgerEntriesTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { entries = args.Get(2).([]models.LedgerEntry) }).Return(nil)
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+				Rates: NewStaticRateProvider(map[string]money.Rate{"USD/" + tt.toCurrency: rate}),
+			})
+			result, err := service.Exchange(context.Background(), owner.String(), usdWallet.String(), targetWallet.String(), amount)
+
+			assert.NoError(t, err)
+			if assert.NotNil(t, result) {
+				assert.Equal(t, amount, result.Amount)
+				assert.Equal(t, converted, result.ConvertedAmount)
+				assert.Equal(t, rate, result.Rate)
+				assert.Equal(t, "USD", result.FromCurrency)
+				assert.Equal(t, tt.toCurrency, result.ToCurrency)
+			}
+			if assert.Len(t, legs, 2) {
+				for _, leg := range legs {
+					assert.Equal(t, models.TransactionTypeExchange, leg.Type)
+					assert.Equal(t, &result.ExchangeID, leg.TransferID)
+					assert.Equal(t, &rate, leg.ExchangeRate)
+					assert.Equal(t, &converted, leg.ConvertedAmount)
+				}
+				assert.Equal(t, usdWallet, legs[0].WalletID)
+				assert.Equal(t, -amount, legs[0].Amount)
+				assert.Equal(t, targetWallet, legs[1].WalletID)
+				assert.Equal(t, converted, legs[1].Amount)
+			}
+			var debits, credits money.Amount
+			for _, entry := range entries {
+				if entry.Direction == models.LedgerDebit {
+					debits += entry.Amount
+				} else {
+					credits += entry.Amount
+				}
+			}
+			assert.Len(t, entries, 4)
+			assert.Equal(t, debits, credits)
+			mockWalletRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_Exchange_Rejected(t *testing.T) {
+	owner, stranger := uuid.New(), uuid.New()
+	usdWallet, eurWallet, jpyWallet, otherUSDWallet, strangerWallet := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	rates := NewStaticRateProvider(map[string]money.Rate{
+		"USD/EUR": money.Rate(92000000),
+		"JPY/USD": money.Rate(660000),
+		"JPY/EUR": money.Rate(450000),
+	})
+	tests := []struct {
+		name          string
+		from, to      uuid.UUID
+		amount        money.Amount
+		rates         RateProvider
+		expectedErrIs error
+	}{
+		{"same wallet", usdWallet, usdWallet, 1000, rates, ErrSameWallet},
+		{"same currency", usdWallet, otherUSDWallet, 1000, rates, ErrSameCurrency},
+		{"another user's wallet", usdWallet, strangerWallet, 1000, rates, ErrWalletNotFound},
+		{"no rate for the pair", usdWallet, jpyWallet, 1000, rates, ErrRateUnavailable},
+		{"no rates configured", usdWallet, eurWallet, 1000, nil, ErrRateUnavailable},
+		{"fractional yen", jpyWallet, usdWallet, money.MustParse("100.50"), rates, ErrInvalidAmount},
+		{"converts to nothing", jpyWallet, eurWallet, money.MustParse("1"), rates, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			for id, wallet := range map[uuid.UUID]*models.Wallet{
+				usdWallet:      {ID: usdWallet, UserID: owner, Currency: "USD"},
+				eurWallet:      {ID: eurWallet, UserID: owner, Currency: "EUR"},
+				jpyWallet:      {ID: jpyWallet, UserID: owner, Currency: "JPY"},
+				otherUSDWallet: {ID: otherUSDWallet, UserID: owner, Currency: "USD"},
+				strangerWallet: {ID: strangerWallet, UserID: stranger, Currency: "EUR"},
+			} {
+				mockWalletRepo.On("GetWalletByID", mock.Anything, id.String()).Return(wallet, nil).Maybe()
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{Rates: tt.rates})
+			result, err := service.Exchange(context.Background(), owner.String(), tt.from.String(), tt.to.String(), tt.amount)
+
+			assert.ErrorIs(t, err, tt.expectedErrIs)
+			assert.Nil(t, result)
+			assert.NoError(t, mockDB.ExpectationsWereMet(), "no database transaction should be started")
+		})
+	}
+}
+
+func TestParseStaticRates(t *testing.T) {
+	rates, err := ParseStaticRates("USD/EUR=0.92, eur/usd=1.08")
+	assert.NoError(t, err)
+
+	rate, err := rates.Rate(context.Background(), "EUR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, money.Rate(108000000), rate)
+	_, err = rates.Rate(context.Background(), "USD", "JPY")
+	assert.ErrorIs(t, err, ErrRateUnavailable)
+
+	for _, raw := range []string{"USD/EUR", "USDEUR=0.92", "USD/XAU=1", "USD/EUR=-1"} {
+		_, err := ParseStaticRates(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// FeePolicy sets the fee charged on transfers of more than Threshold: Flat plus
+// BasisPoints hundredths of a percent of the amount, but no less than Minimum. Fees
+// are credited to the wallet of WalletUserID. The zero value charges no fees.
+type FeePolicy struct {
+	Threshold   money.Amount
+	Flat        money.Amount
+	BasisPoints int64
+	Minimum     money.Amount
+	// WalletUserID owns the system wallet that collects fees
+	WalletUserID string
+}
+
+// Charges reports whether the policy can charge a fee at all
+func (p FeePolicy) Charges() bool {
+	return p.Flat > 0 || p.BasisPoints > 0 || p.Minimum > 0
+}
+
+// Fee returns the fee for a transfer of amount. The percentage part is rounded to the
+// nearest cent, halves rounding up.
+func (p FeePolicy) Fee(amount money.Amount) money.Amount {
+	if !p.Charges() || amount <= p.Threshold {
+		return 0
+	}
+	fee := p.Flat + money.FromCents((amount.Cents()*p.BasisPoints+5000)/10000)
+	if fee < p.Minimum {
+		fee = p.Minimum
+	}
+	return fee
+}
+
+// validate checks that a policy that charges fees has somewhere to credit them
+func (p FeePolicy) validate() error {
+	if p.BasisPoints < 0 || p.BasisPoints > 10000 {
+		return fmt.Errorf("fee percentage must be between 0 and 100")
+	}
+	if !p.Charges() {
+		return nil
+	}
+	if _, err := uuid.Parse(p.WalletUserID); err != nil {
+		return fmt.Errorf("fees are configured but the fee wallet user ID %q is not a valid UUID", p.WalletUserID)
+	}
+	return nil
+}
+
+// recordFee records the fee charged to the sender of a transfer and its collection by
+// the fee wallet, which sees it as an incoming transfer from the sender. Balances must
+// already have been updated.
+func (s *WalletService) recordFee(ctx context.Context, tx pgx.Tx, transferID uuid.UUID, fromUserID string, fromWalletID, feeWalletID uuid.UUID, fee money.Amount) error {
+	err := s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+		WalletID:      fromWalletID,
+		Type:          models.TransactionTypeFee,
+		Status:        models.TransactionStatusCompleted,
+		Amount:        fee,
+		RelatedUserID: &s.fees.WalletUserID,
+		TransferID:    &transferID,
+	})
+	if err != nil {
+		return err
+	}
+	return s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+		WalletID:      feeWalletID,
+		Type:          models.TransactionTypeTransferIn,
+		Status:        models.TransactionStatusCompleted,
+		Amount:        fee,
+		RelatedUserID: &fromUserID,
+		TransferID:    &transferID,
+	})
+}
+
+// recordedTransfer rebuilds the result of a transfer that was already recorded, from
+// its outgoing leg and the legs sharing its transfer ID, including the fee the sender
+// was charged. The balance reported is the sending wallet's current one.
+func (s *WalletService) recordedTransfer(ctx context.Context, tx pgx.Tx, from walletRef, out *models.Transaction) (*models.TransferResult, error) {
+	wallet, err := s.getWalletTx(ctx, tx, from)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.TransferResult{OutTransactionID: out.ID, Amount: out.Amount, BalanceAfter: wallet.Balance}
+	if out.TransferID == nil {
+		return result, nil
+	}
+	result.TransferID = *out.TransferID
+	legs, err := s.transactionRepo.GetTransactionsByTransferIDTx(ctx, tx, out.TransferID.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, leg := range legs {
+		switch {
+		case leg.Type == models.TransactionTypeFee && leg.WalletID == out.WalletID:
+			result.Fee += leg.Amount
+		// The fee wallet is credited with a TRANSFER_IN leg too, of the fee
+		case leg.Type == models.TransactionTypeTransferIn && leg.Amount == out.Amount && result.InTransactionID == uuid.Nil:
+			result.InTransactionID = leg.ID
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Hold reserves amount of a user's wallet, for example while a card payment is being
+// authorized. Held funds stay in the balance and the ledger but can no longer be
+// withdrawn, transferred or held again until the hold is captured or released.
+func (s *WalletService) Hold(ctx context.Context, userID string, amount money.Amount) (*models.Hold, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation": "hold",
+		"amount":    amount,
+	})
+	log.Info("Starting hold operation")
+
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Hold validation failed")
+		return nil, err
+	}
+
+	var hold *models.Hold
+	err := s.retryTx(ctx, log, func() (err error) {
+		hold, err = s.hold(ctx, log, userID, amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return hold, nil
+}
+
+// hold runs a single attempt of Hold inside its own database transaction
+func (s *WalletService) hold(ctx context.Context, log *logrus.Entry, userID string, amount money.Amount) (hold *models.Hold, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "hold", err); err != nil {
+			hold = nil
+		}
+	}()
+
+	if err = s.lockWallets(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+
+	// Like a debit, the reservation only applies when the available balance covers it
+	wallet, err := s.walletRepo.HoldFundsTx(ctx, tx, userID, amount)
+	if errors.Is(err, ErrInsufficientBalance) {
+		log.Warn("Insufficient available balance for hold")
+		return nil, fmt.Errorf("%w: user %s cannot cover %s", ErrInsufficientBalance, userID, amount)
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to reserve funds")
+		return nil, err
+	}
+	if err = checkActive(wallet, defaultWallet(userID)); err != nil {
+		log.WithField("status", wallet.Status).Warn("Hold rejected, wallet is not active")
+		return nil, err
+	}
+	if err = checkPrecision(wallet, amount); err != nil {
+		log.WithField("currency", wallet.Currency).Warn("Hold rejected, amount does not fit the wallet's currency")
+		return nil, err
+	}
+	// A hold is a withdrawal waiting to be captured, so it passes the same gates when
+	// it is placed. The daily limit counts every active hold, this one included, on top
+	// of the withdrawals made today, since capturing them does not check it again.
+	if err = s.checkVelocity(ctx, tx, wallet); err != nil {
+		log.WithField("error", err.Error()).Warn("Too many operations on the wallet")
+		return nil, err
+	}
+	if err = s.checkDailyWithdrawalLimit(ctx, tx, wallet, wallet.HeldAmount); err != nil {
+		log.WithField("error", err.Error()).Warn("Daily withdrawal limit exceeded")
+		return nil, err
+	}
+	if err = s.checkKycLimits(ctx, tx, userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Hold rejected by KYC tier limits")
+		return nil, err
+	}
+	if err = s.checkEmailVerified(ctx, tx, userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Hold rejected, email not verified")
+		return nil, err
+	}
+
+	hold = &models.Hold{
+		WalletID: wallet.ID,
+		UserID:   wallet.UserID,
+		Amount:   amount,
+		Status:   models.HoldStatusActive,
+	}
+	if err = s.walletRepo.CreateHoldTx(ctx, tx, hold); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record hold")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"hold_id":     hold.ID.String(),
+		"held_amount": wallet.HeldAmount,
+	}).Info("Hold created successfully")
+
+	return hold, nil
+}
+
+// Capture spends the funds reserved by an active hold, recording them as a withdrawal.
+// A hold can be captured or released only once; finalizing it again fails with
+// ErrHoldNotActive, even when two requests race.
+func (s *WalletService) Capture(ctx context.Context, holdID string) (*models.Hold, error) {
+	return s.finalizeHold(ctx, holdID, models.HoldStatusCaptured)
+}
+
+// Release cancels an active hold, returning its funds to the available balance
+func (s *WalletService) Release(ctx context.Context, holdID string) (*models.Hold, error) {
+	return s.finalizeHold(ctx, holdID, models.HoldStatusReleased)
+}
+
+// finalizeHold captures or releases a hold, retrying on transaction conflicts
+func (s *WalletService) finalizeHold(ctx context.Context, holdID string, status models.HoldStatus) (*models.Hold, error) {
+	log := logger.WithFields(logrus.Fields{
+		"operation": "finalize_hold",
+		"hold_id":   holdID,
+		"status":    status,
+	}).WithContext(ctx)
+	log.Info("Starting hold finalization")
+
+	var hold *models.Hold
+	err := s.retryTx(ctx, log, func() (err error) {
+		hold, err = s.finalize(ctx, log, holdID, status)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, hold.UserID.String())
+	return hold, nil
+}
+
+// finalize runs a single attempt of finalizeHold inside its own database transaction
+func (s *WalletService) finalize(ctx context.Context, log *logrus.Entry, holdID string, status models.HoldStatus) (hold *models.Hold, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "finalize hold", err); err != nil {
+			hold = nil
+		}
+	}()
+
+	hold, err = s.walletRepo.GetHoldTx(ctx, tx, holdID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to get hold")
+		return nil, err
+	}
+	if err = s.lockWallets(ctx, tx, hold.UserID.String()); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+
+	// Only one request can move the hold out of ACTIVE, so its funds are settled once
+	hold, err = s.walletRepo.FinalizeHoldTx(ctx, tx, holdID, status)
+	if errors.Is(err, ErrHoldNotActive) {
+		log.Warn("Hold was already captured or released")
+		return nil, fmt.Errorf("%w: %s", ErrHoldNotActive, holdID)
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update hold")
+		return nil, err
+	}
+	userID := hold.UserID.String()
+
+	if status == models.HoldStatusReleased {
+		if _, err = s.walletRepo.ReleaseHeldFundsTx(ctx, tx, userID, hold.Amount); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to release held funds")
+			return nil, err
+		}
+		log.Info("Hold released successfully")
+		return hold, nil
+	}
+
+	wallet, err := s.walletRepo.CaptureHeldFundsTx(ctx, tx, userID, hold.Amount)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to capture held funds")
+		return nil, err
+	}
+	if err = checkActive(wallet, defaultWallet(userID)); err != nil {
+		log.WithField("status", wallet.Status).Warn("Capture rejected, wallet is not active")
+		return nil, err
+	}
+	recorded := &models.Transaction{
+		WalletID: wallet.ID,
+		Type:     models.TransactionTypeWithdraw,
+		Status:   models.TransactionStatusCompleted,
+		Amount:   hold.Amount,
+	}
+	if err = s.transactionRepo.CreateTransactionTx(ctx, tx, recorded); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record capture transaction")
+		return nil, err
+	}
+	groupID := uuid.New()
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerDebit, hold.Amount),
+		cashLedgerEntry(groupID, models.LedgerCredit, hold.Amount),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record capture ledger entries")
+		return nil, err
+	}
+	// Each hold passed the KYC limits on its own when it was placed, so the user's
+	// other holds were not counted; the capture, now recorded, is counted with them
+	if err = s.checkRecordedKycLimits(ctx, tx, userID, hold.Amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Capture rejected by KYC tier limits")
+		return nil, err
+	}
+	err = s.checkRisk(ctx, tx, log, RiskOperation{
+		Type:          models.TransactionTypeWithdraw,
+		UserID:        userID,
+		Wallet:        wallet,
+		TransactionID: recorded.ID,
+		Amount:        hold.Amount,
+		At:            s.now(),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check capture against risk rules")
+		return nil, err
+	}
+
+	log.WithField("balance_after", wallet.Balance).Info("Hold captured successfully")
+	return hold, nil
+}
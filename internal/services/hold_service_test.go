@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_Hold(t *testing.T) {
+	tests := []struct {
+		name          string
+		amount        money.Amount
+		repoErr       error
+		expectedErrIs error
+	}{
+		{"reserves funds", money.MustParse("20.00"), nil, nil},
+		{"more than the available balance", money.MustParse("20.00"), repositories.ErrInsufficientBalance, ErrInsufficientBalance},
+		{"missing wallet", money.MustParse("20.00"), repositories.ErrWalletNotFound, ErrWalletNotFound},
+		{"invalid amount", 0, nil, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID, userID := uuid.New(), uuid.New()
+			if tt.expectedErrIs != ErrInvalidAmount {
+				mockDB.ExpectBegin()
+				var wallet *models.Wallet
+				if tt.repoErr == nil {
+					wallet = &models.Wallet{ID: walletID, UserID: userID, Balance: money.MustParse("100.00"), HeldAmount: tt.amount}
+					mockWalletRepo.On("CreateHoldTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Hold")).Return(nil)
+					mockDB.ExpectCommit()
+				} else {
+					mockDB.ExpectRollback()
+				}
+				mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, "user1", tt.amount).Return(wallet, tt.repoErr)
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			hold, err := service.Hold(context.Background(), "user1", tt.amount)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, hold)
+				mockWalletRepo.AssertNotCalled(t, "CreateHoldTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, walletID, hold.WalletID)
+				assert.Equal(t, userID, hold.UserID)
+				assert.Equal(t, tt.amount, hold.Amount)
+				assert.Equal(t, models.HoldStatusActive, hold.Status)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_CaptureHold(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID, userID := uuid.New(), uuid.New()
+	active := &models.Hold{ID: uuid.New(), WalletID: walletID, UserID: userID, Amount: money.MustParse("20.00"), Status: models.HoldStatusActive}
+	captured := *active
+	captured.Status = models.HoldStatusCaptured
+	holdID := active.ID.String()
+
+	var recorded *models.Transaction
+	var entries []models.LedgerEntry
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, holdID).Return(active, nil)
+	mockWalletRepo.On("FinalizeHoldTx", mock.Anything, mock.Anything, holdID, models.HoldStatusCaptured).Return(&captured, nil)
+	mockWalletRepo.On("CaptureHeldFundsTx", mock.Anything, mock.Anything, userID.String(), captured.Amount).
+		Return(&models.Wallet{ID: walletID, Balance: money.MustParse("80.00")}, nil)
+	mockTxRepo.ExpectedCalls = nil
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { recorded = args.Get(2).(*models.Transaction) }).
+		Return(nil)
+	mockTxRepo.On("CreateLedgerEntriesTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { entries = args.Get(2).([]models.LedgerEntry) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	hold, err := service.Capture(context.Background(), holdID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.HoldStatusCaptured, hold.Status)
+	assert.Equal(t, models.TransactionTypeWithdraw, recorded.Type)
+	assert.Equal(t, models.TransactionStatusCompleted, recorded.Status)
+	assert.Equal(t, captured.Amount, recorded.Amount)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, models.LedgerAccountWallet, entries[0].Account)
+		assert.Equal(t, models.LedgerDebit, entries[0].Direction)
+		assert.Equal(t, models.LedgerAccountCash, entries[1].Account)
+	}
+	mockWalletRepo.AssertNotCalled(t, "ReleaseHeldFundsTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_HoldDailyLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		withdrawn     money.Amount
+		held          money.Amount
+		expectedError string
+	}{
+		{"within the limit", money.MustParse("50.00"), money.MustParse("30.00"), ""},
+		{"past the limit with today's withdrawals", money.MustParse("80.00"), money.MustParse("30.00"), "20.00 of the 100.00 daily limit remains"},
+		{"past the limit with other active holds", 0, money.MustParse("130.00"), "100.00 of the 100.00 daily limit remains"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			amount := money.MustParse("30.00")
+			now := time.Date(2024, time.March, 9, 12, 0, 0, 0, time.UTC)
+			mockDB.ExpectBegin()
+			if tt.expectedError == "" {
+				mockWalletRepo.On("CreateHoldTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Hold")).Return(nil)
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, "user1", amount).
+				Return(&models.Wallet{ID: walletID, Balance: money.MustParse("500.00"), HeldAmount: tt.held}, nil)
+			mockTxRepo.On("SumTransactionsSince", mock.Anything, mock.Anything, walletID.String(),
+				[]models.TransactionType{models.TransactionTypeWithdraw}, time.Date(2024, time.March, 9, 0, 0, 0, 0, time.UTC)).
+				Return(tt.withdrawn, nil).Once()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{DailyWithdrawalLimit: money.MustParse("100.00")})
+			service.now = func() time.Time { return now }
+			hold, err := service.Hold(context.Background(), "user1", amount)
+
+			if tt.expectedError != "" {
+				assert.ErrorIs(t, err, ErrDailyLimitExceeded)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, hold)
+				mockWalletRepo.AssertNotCalled(t, "CreateHoldTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, hold)
+			}
+			mockTxRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_HoldThenWithdraw_DailyLimit(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	amount := money.MustParse("100.00")
+	now := time.Date(2024, time.March, 9, 12, 0, 0, 0, time.UTC)
+	wallet := &models.Wallet{ID: uuid.New(), UserID: uuid.New(), Balance: money.MustParse("500.00"), HeldAmount: amount, Currency: "USD"}
+	var hold *models.Hold
+	mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, "user1", amount).Return(wallet, nil)
+	mockWalletRepo.On("CreateHoldTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Hold")).
+		Run(func(args mock.Arguments) {
+			hold = args.Get(2).(*models.Hold)
+			hold.ID = uuid.New()
+		}).Return(nil)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", amount).Return(wallet, nil)
+	// Nothing was withdrawn yet today, so only the active hold stands in the way
+	mockTxRepo.On("SumTransactionsSince", mock.Anything, mock.Anything, wallet.ID.String(),
+		[]models.TransactionType{models.TransactionTypeWithdraw}, time.Date(2024, time.March, 9, 0, 0, 0, 0, time.UTC)).
+		Return(money.Amount(0), nil)
+	var withdrawn money.Amount
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { withdrawn += args.Get(2).(*models.Transaction).Amount }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{DailyWithdrawalLimit: amount})
+	service.now = func() time.Time { return now }
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	_, err = service.Hold(context.Background(), "user1", amount)
+	assert.NoError(t, err)
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	_, _, err = service.Withdraw(context.Background(), "user1", amount, "", "", nil)
+	assert.ErrorIs(t, err, ErrDailyLimitExceeded)
+	assert.Contains(t, err.Error(), "100.00 of the 100.00 daily limit remains")
+
+	captured := *hold
+	captured.Status = models.HoldStatusCaptured
+	mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, hold.ID.String()).Return(hold, nil)
+	mockWalletRepo.On("FinalizeHoldTx", mock.Anything, mock.Anything, hold.ID.String(), models.HoldStatusCaptured).Return(&captured, nil)
+	mockWalletRepo.On("CaptureHeldFundsTx", mock.Anything, mock.Anything, wallet.UserID.String(), amount).Return(wallet, nil)
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	_, err = service.Capture(context.Background(), hold.ID.String())
+	assert.NoError(t, err)
+
+	assert.Equal(t, amount, withdrawn, "a day's withdrawals stay within the limit")
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_CaptureHold_ChecksRisk(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	wallet := &models.Wallet{ID: uuid.New(), Balance: 200, Currency: "USD"}
+	active := &models.Hold{ID: uuid.New(), WalletID: wallet.ID, UserID: uuid.New(), Amount: 9800, Status: models.HoldStatusActive}
+	captured := *active
+	captured.Status = models.HoldStatusCaptured
+	holdID := active.ID.String()
+	transactionID := uuid.New()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, holdID).Return(active, nil)
+	mockWalletRepo.On("FinalizeHoldTx", mock.Anything, mock.Anything, holdID, models.HoldStatusCaptured).Return(&captured, nil)
+	mockWalletRepo.On("CaptureHeldFundsTx", mock.Anything, mock.Anything, captured.UserID.String(), captured.Amount).Return(wallet, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(2).(*models.Transaction).ID = transactionID }).
+		Return(nil)
+	var flags []*models.RiskFlag
+	mockTxRepo.On("CreateRiskFlagTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { flags = append(flags, args.Get(2).(*models.RiskFlag)) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		RiskRules: []RiskRule{BalanceDrainRule{Percent: 90}},
+	})
+	_, err = service.Capture(context.Background(), holdID)
+
+	assert.NoError(t, err)
+	if assert.Len(t, flags, 1) {
+		assert.Equal(t, RiskRuleBalanceDrain, flags[0].Rule)
+		assert.Equal(t, transactionID, flags[0].TransactionID)
+	}
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_ReleaseHold(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	userID := uuid.New()
+	active := &models.Hold{ID: uuid.New(), UserID: userID, Amount: money.MustParse("20.00"), Status: models.HoldStatusActive}
+	released := *active
+	released.Status = models.HoldStatusReleased
+	holdID := active.ID.String()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, holdID).Return(active, nil)
+	mockWalletRepo.On("FinalizeHoldTx", mock.Anything, mock.Anything, holdID, models.HoldStatusReleased).Return(&released, nil)
+	mockWalletRepo.On("ReleaseHeldFundsTx", mock.Anything, mock.Anything, userID.String(), released.Amount).Return(&models.Wallet{}, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	hold, err := service.Release(context.Background(), holdID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.HoldStatusReleased, hold.Status)
+	mockWalletRepo.AssertNotCalled(t, "CaptureHeldFundsTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_FinalizeHold_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		getErr        error
+		finalizeErr   error
+		expectedErrIs error
+	}{
+		{"unknown hold", repositories.ErrHoldNotFound, nil, ErrHoldNotFound},
+		{"already finalized", nil, repositories.ErrHoldNotActive, ErrHoldNotActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			holdID := uuid.New().String()
+			mockDB.ExpectBegin()
+			mockDB.ExpectRollback()
+			if tt.getErr != nil {
+				mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, holdID).Return(nil, tt.getErr)
+			} else {
+				mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, holdID).
+					Return(&models.Hold{UserID: uuid.New(), Amount: 1000, Status: models.HoldStatusCaptured}, nil)
+				mockWalletRepo.On("FinalizeHoldTx", mock.Anything, mock.Anything, holdID, models.HoldStatusCaptured).Return(nil, tt.finalizeErr)
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			hold, err := service.Capture(context.Background(), holdID)
+
+			assert.ErrorIs(t, err, tt.expectedErrIs)
+			assert.Nil(t, hold)
+			mockWalletRepo.AssertNotCalled(t, "CaptureHeldFundsTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
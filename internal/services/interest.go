@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultInterestAccrualInterval is how often the interest worker runs. Wallets are
+// credited at most once per UTC day however often it runs, so it only bounds how late
+// in the day interest arrives.
+const defaultInterestAccrualInterval = time.Hour
+
+// maxInterestRateBps is the highest yearly interest a wallet can earn, 100%
+const maxInterestRateBps = 10000
+
+// ErrInvalidInterestRate is returned for interest rates outside 0 to 10000 basis points
+var ErrInvalidInterestRate = errors.New("invalid interest rate")
+
+// SetInterestRate changes the yearly interest, in basis points, that one of a user's
+// wallets earns on its balance from the next accrual on. A rate of zero stops it
+// earning interest.
+func (s *WalletService) SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":         "set_interest_rate",
+		"wallet_id":         walletID,
+		"interest_rate_bps": rateBps,
+	})
+	log.Info("Setting interest rate")
+
+	if rateBps < 0 || rateBps > maxInterestRateBps {
+		log.Warn("Interest rate out of range rejected")
+		return nil, fmt.Errorf("%w: %d basis points is not between 0 and %d", ErrInvalidInterestRate, rateBps, maxInterestRateBps)
+	}
+
+	wallet, err := s.walletRepo.SetInterestRate(ctx, userID, walletID, rateBps)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to set interest rate")
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+
+	log.Info("Interest rate updated")
+	return wallet, nil
+}
+
+// AccrueInterest credits a day of interest to every active wallet with a positive
+// balance and interest rate, for the current UTC day. Each wallet is credited in its
+// own database transaction together with the interest_accruals row that marks it done
+// for the day, so running the job again the same day, or after a crash part way
+// through, never credits a wallet twice. A wallet that fails is counted in the run's
+// Failed and left for the next run.
+func (s *WalletService) AccrueInterest(ctx context.Context) (*models.InterestRun, error) {
+	date := startOfDayUTC(s.now())
+	log := logger.WithFields(logrus.Fields{
+		"operation": "accrue_interest",
+		"date":      date.Format(time.DateOnly),
+	}).WithContext(ctx)
+	log.Info("Starting interest accrual")
+
+	wallets, err := s.walletRepo.GetInterestBearingWallets(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to list interest bearing wallets")
+		return nil, err
+	}
+
+	run := &models.InterestRun{Date: date, Accruals: []models.InterestAccrual{}}
+	for _, wallet := range wallets {
+		if err := ctx.Err(); err != nil {
+			log.WithField("error", err.Error()).Warn("Interest accrual interrupted")
+			return nil, err
+		}
+		walletLog := log.WithFields(logrus.Fields{
+			"user_id":   wallet.UserID.String(),
+			"wallet_id": wallet.ID.String(),
+		})
+		ref := walletRef{userID: wallet.UserID.String(), walletID: wallet.ID.String()}
+
+		var accrual *models.InterestAccrual
+		err := s.retryTx(ctx, walletLog, func() (err error) {
+			accrual, err = s.accrueInterest(ctx, walletLog, ref, date)
+			return err
+		})
+		if errors.Is(err, repositories.ErrInterestAlreadyAccrued) {
+			// A concurrent run credited the wallet first
+			continue
+		}
+		if err != nil {
+			walletLog.WithField("error", err.Error()).Error("Failed to accrue interest")
+			run.Failed++
+			continue
+		}
+		s.invalidateWallets(ctx, ref.userID)
+		if accrual != nil {
+			run.Accruals = append(run.Accruals, *accrual)
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"credited": len(run.Accruals),
+		"failed":   run.Failed,
+	}).Info("Interest accrual completed")
+	return run, nil
+}
+
+// accrueInterest runs a single attempt of crediting a wallet's interest for date inside
+// its own database transaction. It returns nil when the wallet already accrued for
+// date or no longer earns interest, and when its interest rounds to zero.
+func (s *WalletService) accrueInterest(ctx context.Context, log *logrus.Entry, ref walletRef, date time.Time) (accrual *models.InterestAccrual, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "accrue_interest", err); err != nil {
+			accrual = nil
+		}
+	}()
+
+	if err = s.lockWallets(ctx, tx, ref.userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+	done, err := s.transactionRepo.HasInterestAccrualTx(ctx, tx, ref.walletID, date)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check earlier accruals")
+		return nil, err
+	}
+	if done {
+		log.Debug("Interest already accrued for the day")
+		return nil, nil
+	}
+
+	// The wallet was listed before the lock was taken, so its balance, rate and status
+	// are read again
+	wallet, err := s.walletRepo.GetWalletByIDTx(ctx, tx, ref.walletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+	if wallet.Status != models.WalletStatusActive || wallet.Balance <= 0 || wallet.InterestRateBps <= 0 {
+		return nil, nil
+	}
+	amount := wallet.Balance.DailyInterest(wallet.InterestRateBps, wallet.Currency)
+	if amount <= 0 {
+		log.WithField("balance", wallet.Balance).Debug("Interest rounds to zero")
+		return nil, nil
+	}
+
+	credited, err := s.credit(ctx, tx, ref, amount)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to credit interest")
+		return nil, err
+	}
+	description := "interest for " + date.Format(time.DateOnly)
+	interest := &models.Transaction{
+		WalletID:    wallet.ID,
+		Type:        models.TransactionTypeInterest,
+		Status:      models.TransactionStatusCompleted,
+		Amount:      amount,
+		Description: &description,
+	}
+	if err = s.transactionRepo.CreateTransactionTx(ctx, tx, interest); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record interest transaction")
+		return nil, err
+	}
+	groupID := uuid.New()
+	entries := []models.LedgerEntry{
+		interestLedgerEntry(groupID, models.LedgerDebit, amount),
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerCredit, amount),
+	}
+	if err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, entries); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record interest ledger entries")
+		return nil, err
+	}
+
+	accrual = &models.InterestAccrual{
+		WalletID:      wallet.ID,
+		UserID:        wallet.UserID,
+		Date:          date,
+		TransactionID: interest.ID,
+		Balance:       wallet.Balance,
+		RateBps:       wallet.InterestRateBps,
+		Amount:        amount,
+		Currency:      wallet.Currency,
+	}
+	if err = s.transactionRepo.CreateInterestAccrualTx(ctx, tx, accrual); err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to record interest accrual")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"amount":        amount,
+		"balance_after": credited.Balance,
+	}).Info("Interest credited")
+	return accrual, nil
+}
+
+// interestLedgerEntry builds a ledger entry against the system interest account, which
+// balances the interest paid to wallets
+func interestLedgerEntry(groupID uuid.UUID, direction models.LedgerDirection, amount money.Amount) models.LedgerEntry {
+	return models.LedgerEntry{
+		GroupID:   groupID,
+		Account:   models.LedgerAccountInterest,
+		Direction: direction,
+		Amount:    amount,
+	}
+}
+
+// RunInterestWorker accrues interest every accrual interval until ctx is cancelled
+func (s *WalletService) RunInterestWorker(ctx context.Context) {
+	log := logger.WithOperation("interest_worker").WithContext(ctx)
+	log.WithField("interval", s.accrualInterval.String()).Info("Interest worker started")
+
+	ticker := time.NewTicker(s.accrualInterval)
+	defer ticker.Stop()
+	for {
+		// AccrueInterest logs its own failures
+		_, _ = s.AccrueInterest(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info("Interest worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.SetInterestRate(ctx, userID, walletID, rateBps)
+}
+
+func AccrueInterest(ctx context.Context) (*models.InterestRun, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.AccrueInterest(ctx)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// interestWallet is an active wallet earning rateBps a year on balance
+func interestWallet(balance string, rateBps int, currency string) *models.Wallet {
+	return &models.Wallet{
+		ID:              uuid.New(),
+		UserID:          uuid.New(),
+		Status:          models.WalletStatusActive,
+		Currency:        currency,
+		Balance:         money.MustParse(balance),
+		InterestRateBps: rateBps,
+	}
+}
+
+func TestWalletService_AccrueInterest_RoundsToCents(t *testing.T) {
+	tests := []struct {
+		name     string
+		wallet   *models.Wallet
+		expected string // empty when nothing is credited
+	}{
+		{"rounds down", interestWallet("10000.00", 250, "USD"), "0.68"},
+		{"half a cent rounds up", interestWallet("7.30", 2500, "USD"), "0.01"},
+		{"under half a cent is not credited", interestWallet("7.29", 2500, "USD"), ""},
+		{"whole yen", interestWallet("1000000", 300, "JPY"), "82"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			now := time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+			walletID := tt.wallet.ID.String()
+			mockDB.ExpectBegin()
+			mockDB.ExpectCommit()
+			mockWalletRepo.On("GetInterestBearingWallets", mock.Anything).Return([]models.Wallet{*tt.wallet}, nil)
+			mockTxRepo.On("HasInterestAccrualTx", mock.Anything, mock.Anything, walletID, startOfDayUTC(now)).Return(false, nil)
+			mockWalletRepo.On("GetWalletByIDTx", mock.Anything, mock.Anything, walletID).Return(tt.wallet, nil)
+			var recorded *models.Transaction
+			if tt.expected != "" {
+				amount := money.MustParse(tt.expected)
+				credited := *tt.wallet
+				credited.Balance += amount
+				mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, walletID, amount).Return(&credited, nil)
+				mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { recorded = args.Get(2).(*models.Transaction) }).
+					Return(nil)
+				mockTxRepo.On("CreateInterestAccrualTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service.now = func() time.Time { return now }
+			run, err := service.AccrueInterest(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, startOfDayUTC(now), run.Date)
+			assert.Zero(t, run.Failed)
+			if tt.expected == "" {
+				assert.Empty(t, run.Accruals)
+				mockWalletRepo.AssertNotCalled(t, "CreditWalletByIDTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else if assert.Len(t, run.Accruals, 1) {
+				accrual := run.Accruals[0]
+				assert.Equal(t, money.MustParse(tt.expected), accrual.Amount)
+				assert.Equal(t, tt.wallet.Balance, accrual.Balance)
+				assert.Equal(t, tt.wallet.InterestRateBps, accrual.RateBps)
+				assert.Equal(t, models.TransactionTypeInterest, recorded.Type)
+				assert.Equal(t, accrual.Amount, recorded.Amount)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_AccrueInterest_SameDayRerunCreditsNothing(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	wallet := interestWallet("10000.00", 250, "USD")
+	walletID := wallet.ID.String()
+	now := time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetInterestBearingWallets", mock.Anything).Return([]models.Wallet{*wallet}, nil)
+	// The first run records the day's accrual, which the second run finds
+	mockTxRepo.On("HasInterestAccrualTx", mock.Anything, mock.Anything, walletID, startOfDayUTC(now)).Return(false, nil).Once()
+	mockTxRepo.On("HasInterestAccrualTx", mock.Anything, mock.Anything, walletID, startOfDayUTC(now)).Return(true, nil).Once()
+	mockWalletRepo.On("GetWalletByIDTx", mock.Anything, mock.Anything, walletID).Return(wallet, nil)
+	mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, walletID, money.Amount(68)).Return(wallet, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockTxRepo.On("CreateInterestAccrualTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	service.now = func() time.Time { return now }
+	first, err := service.AccrueInterest(context.Background())
+	assert.NoError(t, err)
+	service.now = func() time.Time { return now.Add(10 * time.Hour) }
+	second, err := service.AccrueInterest(context.Background())
+	assert.NoError(t, err)
+
+	assert.Len(t, first.Accruals, 1)
+	assert.Empty(t, second.Accruals)
+	assert.Zero(t, second.Failed)
+	mockWalletRepo.AssertNumberOfCalls(t, "CreditWalletByIDTx", 1)
+	mockTxRepo.AssertNumberOfCalls(t, "CreateInterestAccrualTx", 1)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_AccrueInterest_FailedWalletDoesNotStopRun(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	broken, concurrent, healthy := interestWallet("500.00", 250, "USD"), interestWallet("500.00", 250, "USD"), interestWallet("10000.00", 250, "USD")
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetInterestBearingWallets", mock.Anything).Return([]models.Wallet{*broken, *concurrent, *healthy}, nil)
+	mockTxRepo.On("HasInterestAccrualTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockWalletRepo.On("GetWalletByIDTx", mock.Anything, mock.Anything, broken.ID.String()).Return(nil, errors.New("connection reset"))
+	for _, w := range []*models.Wallet{concurrent, healthy} {
+		mockWalletRepo.On("GetWalletByIDTx", mock.Anything, mock.Anything, w.ID.String()).Return(w, nil)
+		mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, w.ID.String(), mock.Anything).Return(w, nil)
+	}
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	// Another run recorded the accrual of the second wallet first
+	mockTxRepo.On("CreateInterestAccrualTx", mock.Anything, mock.Anything, mock.MatchedBy(func(a *models.InterestAccrual) bool {
+		return a.WalletID == concurrent.ID
+	})).Return(repositories.ErrInterestAlreadyAccrued)
+	mockTxRepo.On("CreateInterestAccrualTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	run, err := service.AccrueInterest(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, run.Failed, "only the broken wallet should count as failed")
+	if assert.Len(t, run.Accruals, 1) {
+		assert.Equal(t, healthy.ID, run.Accruals[0].WalletID)
+	}
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_SetInterestRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		rateBps     int
+		expectedErr error
+	}{
+		{"zero stops interest", 0, nil},
+		{"highest rate", 10000, nil},
+		{"negative", -1, ErrInvalidInterestRate},
+		{"over 100%", 10001, ErrInvalidInterestRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo := new(MockWalletRepo)
+			mockWalletRepo.On("SetInterestRate", mock.Anything, "user1", "wallet1", tt.rateBps).Return(&models.Wallet{InterestRateBps: tt.rateBps}, nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, new(MockTransactionRepo), nil, WalletServiceConfig{})
+			wallet, err := service.SetInterestRate(context.Background(), "user1", "wallet1", tt.rateBps)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				mockWalletRepo.AssertNotCalled(t, "SetInterestRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.rateBps, wallet.InterestRateBps)
+		})
+	}
+}
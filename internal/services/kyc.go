@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// KYC tier failures
+var (
+	ErrKycLimitExceeded = errors.New("KYC tier limit exceeded")
+	ErrInvalidKycTier   = errors.New("invalid KYC tier")
+)
+
+// kycOperations are the transaction types counted towards the daily and monthly caps
+// of a KYC tier: the operations a user makes, not the money they receive
+var kycOperations = velocityOperations
+
+// KycLimitError reports which limit of the user's KYC tier an operation went over, and
+// the lowest tier that would have allowed it. It matches ErrKycLimitExceeded.
+type KycLimitError struct {
+	// Limit is "single operation", "daily" or "monthly"
+	Limit string
+	Max   money.Amount
+	Tier  models.KycTier
+	// AllowedBy is the lowest higher tier that would allow the operation, or nil when
+	// none would
+	AllowedBy *models.KycTier
+}
+
+func (e *KycLimitError) Error() string {
+	msg := fmt.Sprintf("%s: over the %s %s limit of tier %d (%s)", ErrKycLimitExceeded, e.Max, e.Limit, e.Tier, e.Tier)
+	if e.AllowedBy == nil {
+		return msg + "; no tier allows it"
+	}
+	return msg + fmt.Sprintf("; tier %d (%s) allows it", *e.AllowedBy, *e.AllowedBy)
+}
+
+func (e *KycLimitError) Unwrap() error { return ErrKycLimitExceeded }
+
+// checkKycLimits returns a *KycLimitError when amount is over the largest single
+// operation of the user's KYC tier, or would take the user's deposits, withdrawals
+// and outgoing transfers, across all of their wallets, past the tier's daily or
+// monthly cap. The caller must hold the user's wallet lock, like for checkVolumeLimit.
+func (s *WalletService) checkKycLimits(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) error {
+	return s.checkKycLimitsWith(ctx, tx, userID, amount, amount)
+}
+
+// checkRecordedKycLimits is checkKycLimits for an operation whose transaction was
+// already written in tx, so the daily and monthly sums include it
+func (s *WalletService) checkRecordedKycLimits(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) error {
+	return s.checkKycLimitsWith(ctx, tx, userID, amount, 0)
+}
+
+// checkKycLimitsWith checks amount against the user's KYC tier, adding unrecorded to
+// the operations the daily and monthly sums find
+func (s *WalletService) checkKycLimitsWith(ctx context.Context, tx pgx.Tx, userID string, amount, unrecorded money.Amount) error {
+	if !s.kycLimits {
+		return nil
+	}
+
+	tier, err := s.walletRepo.GetUserKycTierTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	tiers, err := s.walletRepo.GetKycTierLimitsTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	var limits *models.KycTierLimits
+	for i := range tiers {
+		if tiers[i].Tier == tier {
+			limits = &tiers[i]
+		}
+	}
+	if limits == nil {
+		return fmt.Errorf("no limits are defined for KYC tier %d", tier)
+	}
+
+	if limits.MaxSingleAmount != nil && amount > *limits.MaxSingleAmount {
+		return kycLimitError("single operation", *limits.MaxSingleAmount, tier, tiers, func(l models.KycTierLimits) *money.Amount { return l.MaxSingleAmount }, amount)
+	}
+
+	now := s.now()
+	for _, period := range []struct {
+		name    string
+		limitOf func(models.KycTierLimits) *money.Amount
+		since   time.Time
+	}{
+		{"daily", func(l models.KycTierLimits) *money.Amount { return l.DailyLimit }, startOfDayUTC(now)},
+		{"monthly", func(l models.KycTierLimits) *money.Amount { return l.MonthlyLimit }, startOfMonthUTC(now)},
+	} {
+		max := period.limitOf(*limits)
+		if max == nil {
+			continue
+		}
+		used, err := s.transactionRepo.SumUserTransactionsSince(ctx, tx, userID, kycOperations, period.since)
+		if err != nil {
+			return err
+		}
+		if used+unrecorded > *max {
+			return kycLimitError(period.name, *max, tier, tiers, period.limitOf, used+unrecorded)
+		}
+	}
+	return nil
+}
+
+// kycLimitError builds the error for going over limit, finding the lowest tier above
+// tier whose limit, as read by limitOf, covers needed
+func kycLimitError(name string, max money.Amount, tier models.KycTier, tiers []models.KycTierLimits, limitOf func(models.KycTierLimits) *money.Amount, needed money.Amount) *KycLimitError {
+	e := &KycLimitError{Limit: name, Max: max, Tier: tier}
+	for _, l := range tiers {
+		if l.Tier <= tier {
+			continue
+		}
+		if limit := limitOf(l); limit == nil || needed <= *limit {
+			allowed := l.Tier
+			e.AllowedBy = &allowed
+			break
+		}
+	}
+	return e
+}
+
+// SetKycTier changes the KYC tier of a user, and with it the limits that apply to them
+func (s *WalletService) SetKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "set_kyc_tier")
+
+	if !tier.Valid() {
+		log.WithField("kyc_tier", int(tier)).Warn("KYC tier change rejected, unknown tier")
+		return nil, fmt.Errorf("%w: %d is not one of 0 (unverified), 1 (basic) or 2 (full)", ErrInvalidKycTier, tier)
+	}
+	user, err := s.walletRepo.SetUserKycTier(ctx, userID, tier)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to set KYC tier")
+		return nil, err
+	}
+	log.WithField("kyc_tier", int(tier)).Info("KYC tier changed")
+	return user, nil
+}
+
+func SetKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.SetKycTier(ctx, userID, tier)
+}
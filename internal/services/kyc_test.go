@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// seededKycLimits are the tier limits the kyc_tier_limits migration seeds
+func seededKycLimits() []models.KycTierLimits {
+	amount := func(s string) *money.Amount {
+		a := money.MustParse(s)
+		return &a
+	}
+	return []models.KycTierLimits{
+		{Tier: models.KycTierUnverified, MaxSingleAmount: amount("100.00"), MonthlyLimit: amount("500.00")},
+		{Tier: models.KycTierBasic, MaxSingleAmount: amount("1000.00"), DailyLimit: amount("2000.00"), MonthlyLimit: amount("10000.00")},
+		{Tier: models.KycTierFull},
+	}
+}
+
+func TestWalletService_CheckKycLimits(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	basic, full := models.KycTierBasic, models.KycTierFull
+
+	tests := []struct {
+		name      string
+		tier      models.KycTier
+		amount    string
+		usedToday string
+		usedMonth string
+		limit     string // empty when the operation is allowed
+		allowedBy *models.KycTier
+	}{
+		{name: "unverified, largest single amount", tier: models.KycTierUnverified, amount: "100.00"},
+		{name: "unverified, over the single amount", tier: models.KycTierUnverified, amount: "100.01", limit: "single operation", allowedBy: &basic},
+		{name: "unverified, up to the monthly cap", tier: models.KycTierUnverified, amount: "50.00", usedMonth: "450.00"},
+		{name: "unverified, over the monthly cap", tier: models.KycTierUnverified, amount: "50.01", usedMonth: "450.00", limit: "monthly", allowedBy: &basic},
+		{name: "basic, largest single amount", tier: models.KycTierBasic, amount: "1000.00"},
+		{name: "basic, over the single amount", tier: models.KycTierBasic, amount: "1000.01", limit: "single operation", allowedBy: &full},
+		{name: "basic, up to the daily cap", tier: models.KycTierBasic, amount: "500.00", usedToday: "1500.00", usedMonth: "1500.00"},
+		{name: "basic, over the daily cap", tier: models.KycTierBasic, amount: "500.01", usedToday: "1500.00", usedMonth: "1500.00", limit: "daily", allowedBy: &full},
+		{name: "basic, up to the monthly cap", tier: models.KycTierBasic, amount: "500.00", usedMonth: "9500.00"},
+		{name: "basic, over the monthly cap", tier: models.KycTierBasic, amount: "500.01", usedMonth: "9500.00", limit: "monthly", allowedBy: &full},
+		{name: "full, no limits", tier: models.KycTierFull, amount: "1000000.00", usedToday: "50000.00", usedMonth: "500000.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+			parse := func(s string) money.Amount {
+				if s == "" {
+					return 0
+				}
+				return money.MustParse(s)
+			}
+			mockWalletRepo.On("GetUserKycTierTx", mock.Anything, mock.Anything, "user1").Return(tt.tier, nil)
+			mockWalletRepo.On("GetKycTierLimitsTx", mock.Anything, mock.Anything).Return(seededKycLimits(), nil)
+			mockTxRepo.On("SumUserTransactionsSince", mock.Anything, mock.Anything, "user1", kycOperations, startOfDayUTC(now)).Return(parse(tt.usedToday), nil).Maybe()
+			mockTxRepo.On("SumUserTransactionsSince", mock.Anything, mock.Anything, "user1", kycOperations, startOfMonthUTC(now)).Return(parse(tt.usedMonth), nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{EnforceKycLimits: true})
+			service.now = func() time.Time { return now }
+			err := service.checkKycLimits(context.Background(), nil, "user1", money.MustParse(tt.amount))
+
+			if tt.limit == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrKycLimitExceeded)
+			var limitErr *KycLimitError
+			if assert.ErrorAs(t, err, &limitErr) {
+				assert.Equal(t, tt.limit, limitErr.Limit)
+				assert.Equal(t, tt.tier, limitErr.Tier)
+				assert.Equal(t, tt.allowedBy, limitErr.AllowedBy)
+			}
+		})
+	}
+}
+
+func TestKycLimitError_Error(t *testing.T) {
+	basic := models.KycTierBasic
+	err := &KycLimitError{Limit: "single operation", Max: 10000, Tier: models.KycTierUnverified, AllowedBy: &basic}
+	assert.Equal(t, "KYC tier limit exceeded: over the 100.00 single operation limit of tier 0 (unverified); tier 1 (basic) allows it", err.Error())
+
+	err = &KycLimitError{Limit: "monthly", Max: 1000000, Tier: models.KycTierFull}
+	assert.Equal(t, "KYC tier limit exceeded: over the 10000.00 monthly limit of tier 2 (full); no tier allows it", err.Error())
+}
+
+func TestWalletService_Deposit_RejectedByKycTier(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(15000)).Return(&models.Wallet{ID: uuid.New(), Balance: 15000, Currency: "USD"}, nil)
+	mockWalletRepo.On("GetUserKycTierTx", mock.Anything, mock.Anything, "user1").Return(models.KycTierUnverified, nil)
+	mockWalletRepo.On("GetKycTierLimitsTx", mock.Anything, mock.Anything).Return(seededKycLimits(), nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{EnforceKycLimits: true})
+	wallet, _, err := service.Deposit(context.Background(), "user1", 15000, "", "", nil)
+
+	assert.Nil(t, wallet)
+	assert.ErrorIs(t, err, ErrKycLimitExceeded)
+	assert.ErrorContains(t, err, "tier 1 (basic) allows it")
+	mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_DepositExternal_KycLimits(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		usedMonth string // includes the deposit, which is recorded before the check
+		wantErr   bool
+	}{
+		{"up to the monthly cap", "500.00", false},
+		{"over the monthly cap", "500.01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			mockDB.ExpectBegin()
+			if tt.wantErr {
+				mockDB.ExpectRollback()
+			} else {
+				mockDB.ExpectCommit()
+			}
+			mockWalletRepo.On("GetWalletByUserIDTx", mock.Anything, mock.Anything, "user1").Return(&models.Wallet{ID: walletID}, nil)
+			mockTxRepo.On("CreateExternalTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(10000)).
+				Return(&models.Wallet{ID: walletID, Balance: 10000, Currency: "USD"}, nil)
+			mockWalletRepo.On("GetUserKycTierTx", mock.Anything, mock.Anything, "user1").Return(models.KycTierUnverified, nil)
+			mockWalletRepo.On("GetKycTierLimitsTx", mock.Anything, mock.Anything).Return(seededKycLimits(), nil)
+			mockTxRepo.On("SumUserTransactionsSince", mock.Anything, mock.Anything, "user1", kycOperations, startOfMonthUTC(now)).
+				Return(money.MustParse(tt.usedMonth), nil)
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{EnforceKycLimits: true})
+			service.now = func() time.Time { return now }
+			recorded, err := service.DepositExternal(context.Background(), "user1", 10000, "ch_123")
+
+			if tt.wantErr {
+				assert.Nil(t, recorded)
+				assert.ErrorIs(t, err, ErrKycLimitExceeded)
+				assert.ErrorContains(t, err, "monthly")
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, recorded)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_Hold_RejectedByKycTier(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, "user1", money.Amount(15000)).
+		Return(&models.Wallet{ID: uuid.New(), Balance: 20000, HeldAmount: 15000, Currency: "USD"}, nil)
+	mockWalletRepo.On("GetUserKycTierTx", mock.Anything, mock.Anything, "user1").Return(models.KycTierUnverified, nil)
+	mockWalletRepo.On("GetKycTierLimitsTx", mock.Anything, mock.Anything).Return(seededKycLimits(), nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{EnforceKycLimits: true})
+	hold, err := service.Hold(context.Background(), "user1", 15000)
+
+	assert.Nil(t, hold)
+	assert.ErrorIs(t, err, ErrKycLimitExceeded)
+	assert.ErrorContains(t, err, "single operation")
+	mockWalletRepo.AssertNotCalled(t, "CreateHoldTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_CaptureHolds_KycLimits(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	amount := money.MustParse("100.00")
+	userID := uuid.New()
+	wallet := &models.Wallet{ID: uuid.New(), UserID: userID, Balance: money.MustParse("1000.00"), HeldAmount: amount, Currency: "USD"}
+	var holds []*models.Hold
+	mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, userID.String(), amount).Return(wallet, nil)
+	mockWalletRepo.On("CreateHoldTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Hold")).
+		Run(func(args mock.Arguments) {
+			hold := args.Get(2).(*models.Hold)
+			hold.ID = uuid.New()
+			holds = append(holds, hold)
+		}).Return(nil)
+	mockWalletRepo.On("GetUserKycTierTx", mock.Anything, mock.Anything, userID.String()).Return(models.KycTierUnverified, nil)
+	mockWalletRepo.On("GetKycTierLimitsTx", mock.Anything, mock.Anything).Return(seededKycLimits(), nil)
+	mockWalletRepo.On("CaptureHeldFundsTx", mock.Anything, mock.Anything, userID.String(), amount).Return(wallet, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	// 350.00 of the 500.00 monthly cap is used, so each hold fits on its own, and the
+	// sums then grow by each capture recorded
+	mockTxRepo.On("SumUserTransactionsSince", mock.Anything, mock.Anything, userID.String(), kycOperations, startOfMonthUTC(now)).
+		Return(money.MustParse("350.00"), nil).Twice()
+	mockTxRepo.On("SumUserTransactionsSince", mock.Anything, mock.Anything, userID.String(), kycOperations, startOfMonthUTC(now)).
+		Return(money.MustParse("450.00"), nil).Once()
+	mockTxRepo.On("SumUserTransactionsSince", mock.Anything, mock.Anything, userID.String(), kycOperations, startOfMonthUTC(now)).
+		Return(money.MustParse("550.00"), nil).Once()
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{EnforceKycLimits: true})
+	service.now = func() time.Time { return now }
+
+	for range 2 {
+		mockDB.ExpectBegin()
+		mockDB.ExpectCommit()
+		_, err := service.Hold(context.Background(), userID.String(), amount)
+		assert.NoError(t, err)
+	}
+	if !assert.Len(t, holds, 2) {
+		return
+	}
+	for i, hold := range holds {
+		captured := *hold
+		captured.Status = models.HoldStatusCaptured
+		mockWalletRepo.On("GetHoldTx", mock.Anything, mock.Anything, hold.ID.String()).Return(hold, nil)
+		mockWalletRepo.On("FinalizeHoldTx", mock.Anything, mock.Anything, hold.ID.String(), models.HoldStatusCaptured).Return(&captured, nil)
+		mockDB.ExpectBegin()
+		if i == 0 {
+			mockDB.ExpectCommit()
+		} else {
+			mockDB.ExpectRollback()
+		}
+
+		_, err := service.Capture(context.Background(), hold.ID.String())
+
+		if i == 0 {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorIs(t, err, ErrKycLimitExceeded)
+			assert.ErrorContains(t, err, "monthly")
+		}
+	}
+	mockTxRepo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_CheckKycLimits_NotEnforced(t *testing.T) {
+	mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{})
+	err := service.checkKycLimits(context.Background(), nil, "user1", money.MustParse("1000000.00"))
+
+	assert.NoError(t, err)
+	mockWalletRepo.AssertNotCalled(t, "GetUserKycTierTx", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWalletService_SetKycTier(t *testing.T) {
+	tests := []struct {
+		name        string
+		tier        models.KycTier
+		repoErr     error
+		expectedErr error
+	}{
+		{name: "success", tier: models.KycTierFull},
+		{name: "back to unverified", tier: models.KycTierUnverified},
+		{name: "unknown tier", tier: 3, expectedErr: ErrInvalidKycTier},
+		{name: "negative tier", tier: -1, expectedErr: ErrInvalidKycTier},
+		{name: "unknown user", tier: models.KycTierBasic, repoErr: repositories.ErrUserNotFound, expectedErr: ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo := new(MockWalletRepo)
+			var user *models.User
+			if tt.repoErr == nil {
+				user = &models.User{ID: uuid.New(), KycTier: tt.tier}
+			}
+			mockWalletRepo.On("SetUserKycTier", mock.Anything, "user1", tt.tier).Return(user, tt.repoErr).Maybe()
+
+			service := NewWalletService(mockWalletRepo, new(MockTransactionRepo), nil, WalletServiceConfig{})
+			got, err := service.SetKycTier(context.Background(), "user1", tt.tier)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				if errors.Is(err, ErrInvalidKycTier) {
+					mockWalletRepo.AssertNotCalled(t, "SetUserKycTier", mock.Anything, mock.Anything, mock.Anything)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.tier, got.KycTier)
+		})
+	}
+}
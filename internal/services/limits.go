@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// velocityWindow is the period MaxOperationsPerMinute counts operations over
+const velocityWindow = time.Minute
+
+// velocityOperations are the transaction types a wallet records for the operations it
+// makes itself; money it receives does not count towards its velocity limit
+var velocityOperations = []models.TransactionType{
+	models.TransactionTypeDeposit,
+	models.TransactionTypeWithdraw,
+	models.TransactionTypeTransferOut,
+}
+
+// retryAfterError wraps an error with how long the caller should wait before retrying
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter reports how long to wait before retrying an operation that failed with
+// err, such as ErrTooManyOperations, and whether err says so at all
+func RetryAfter(err error) (time.Duration, bool) {
+	var r *retryAfterError
+	if errors.As(err, &r) {
+		return r.after, true
+	}
+	return 0, false
+}
+
+// checkVelocity returns ErrTooManyOperations when the wallet already made as many
+// deposits, withdrawals and outgoing transfers in the last minute as it may. Only
+// recorded operations count, so requests rejected before they ran are not held
+// against the wallet. The caller must hold the wallet's lock, or have written to its
+// row under optimistic locking, like for checkVolumeLimit.
+func (s *WalletService) checkVelocity(ctx context.Context, tx pgx.Tx, wallet *models.Wallet) error {
+	if s.maxOperations <= 0 {
+		return nil
+	}
+
+	now := s.now()
+	count, oldest, err := s.transactionRepo.CountTransactionsSince(ctx, tx, wallet.ID.String(), velocityOperations, now.Add(-velocityWindow))
+	if err != nil {
+		return err
+	}
+	if count < s.maxOperations {
+		return nil
+	}
+	// A slot frees up once the oldest operation in the window leaves it
+	after := velocityWindow
+	if oldest != nil {
+		after = oldest.Add(velocityWindow).Sub(now)
+	}
+	if after < time.Second {
+		after = time.Second
+	}
+	return &retryAfterError{
+		err:   fmt.Errorf("%w: at most %d operations per minute are allowed", ErrTooManyOperations, s.maxOperations),
+		after: after,
+	}
+}
+
+// checkDailyWithdrawalLimit returns ErrDailyLimitExceeded when withdrawing amount
+// would take the wallet's withdrawals since UTC midnight past its daily limit: the
+// wallet's own limit when set, otherwise the configured default
+func (s *WalletService) checkDailyWithdrawalLimit(ctx context.Context, tx pgx.Tx, wallet *models.Wallet, amount money.Amount) error {
+	return s.checkVolumeLimit(ctx, tx, wallet, models.TransactionTypeWithdraw, startOfDayUTC(s.now()),
+		s.dailyLimit, wallet.DailyWithdrawalLimit, amount, ErrDailyLimitExceeded, "daily")
+}
+
+// checkMonthlyTransferLimit returns ErrMonthlyLimitExceeded when transferring amount
+// would take the wallet's outgoing transfers since the start of the UTC calendar
+// month past its monthly limit: the wallet's own limit when set, otherwise the
+// configured default. Fees do not count towards the limit.
+func (s *WalletService) checkMonthlyTransferLimit(ctx context.Context, tx pgx.Tx, wallet *models.Wallet, amount money.Amount) error {
+	return s.checkVolumeLimit(ctx, tx, wallet, models.TransactionTypeTransferOut, startOfMonthUTC(s.now()),
+		s.monthlyLimit, wallet.MonthlyTransferLimit, amount, ErrMonthlyLimitExceeded, "monthly")
+}
+
+// checkVolumeLimit returns limitErr, with the remaining allowance, when amount on top
+// of the wallet's transactions of type txType since since would exceed its limit. A
+// wallet override of zero blocks the operation; a configured default of zero means
+// no limit. The caller must hold the wallet's lock, or have written to its row under
+// optimistic locking, so that concurrent operations cannot both pass the check.
+func (s *WalletService) checkVolumeLimit(ctx context.Context, tx pgx.Tx, wallet *models.Wallet, txType models.TransactionType, since time.Time, configured money.Amount, override *money.Amount, amount money.Amount, limitErr error, period string) error {
+	limit := configured
+	if override != nil {
+		limit = *override
+	} else if limit <= 0 {
+		return nil
+	}
+
+	used, err := s.transactionRepo.SumTransactionsSince(ctx, tx, wallet.ID.String(), []models.TransactionType{txType}, since)
+	if err != nil {
+		return err
+	}
+	if used+amount > limit {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		return fmt.Errorf("%w: %s of the %s %s limit remains", limitErr, remaining, limit, period)
+	}
+	return nil
+}
+
+// startOfDayUTC returns the UTC midnight that starts the day t falls on
+func startOfDayUTC(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// startOfMonthUTC returns the UTC midnight that starts the calendar month t falls on
+func startOfMonthUTC(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_WithdrawDailyLimit(t *testing.T) {
+	override := money.MustParse("50.00")
+	tests := []struct {
+		name          string
+		configLimit   money.Amount
+		walletLimit   *money.Amount
+		withdrawn     money.Amount
+		amount        money.Amount
+		expectedError string
+	}{
+		{"within the limit", money.MustParse("1000.00"), nil, money.MustParse("900.00"), money.MustParse("100.00"), ""},
+		{"past the limit", money.MustParse("1000.00"), nil, money.MustParse("900.00"), money.MustParse("150.00"), "100.00 of the 1000.00 daily limit remains"},
+		{"wallet override", money.MustParse("1000.00"), &override, money.MustParse("0.00"), money.MustParse("100.00"), "50.00 of the 50.00 daily limit remains"},
+		{"limit already used up", money.MustParse("1000.00"), nil, money.MustParse("1000.00"), money.MustParse("0.01"), "0.00 of the 1000.00 daily limit remains"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			now := time.Date(2024, time.March, 9, 23, 30, 0, 0, time.FixedZone("UTC-5", -5*3600))
+			mockDB.ExpectBegin()
+			if tt.expectedError == "" {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", tt.amount).
+				Return(&models.Wallet{ID: walletID, DailyWithdrawalLimit: tt.walletLimit}, nil)
+			mockTxRepo.On("SumTransactionsSince", mock.Anything, mock.Anything, walletID.String(),
+				[]models.TransactionType{models.TransactionTypeWithdraw}, time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)).
+				Return(tt.withdrawn, nil).Once()
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{DailyWithdrawalLimit: tt.configLimit})
+			service.now = func() time.Time { return now }
+			wallet, _, err := service.Withdraw(context.Background(), "user1", tt.amount, "", "", nil)
+
+			if tt.expectedError != "" {
+				assert.ErrorIs(t, err, ErrDailyLimitExceeded)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, wallet)
+				mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, wallet)
+			}
+			mockTxRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_WithdrawWithoutDailyLimit(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(&models.Wallet{Balance: 7000}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	_, _, err = service.Withdraw(context.Background(), "user1", 3000, "", "", nil)
+
+	assert.NoError(t, err)
+	mockTxRepo.AssertNotCalled(t, "SumTransactionsSince", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStartOfDayUTC(t *testing.T) {
+	tests := []struct {
+		name     string
+		t        time.Time
+		expected time.Time
+	}{
+		{"midnight", time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"just before midnight", time.Date(2024, time.March, 9, 23, 59, 59, 999999999, time.UTC), time.Date(2024, time.March, 9, 0, 0, 0, 0, time.UTC)},
+		{"ahead of UTC", time.Date(2024, time.March, 10, 7, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)), time.Date(2024, time.March, 9, 0, 0, 0, 0, time.UTC)},
+		{"behind UTC", time.Date(2024, time.March, 9, 20, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)), time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, startOfDayUTC(tt.t))
+		})
+	}
+}
+
+func TestWalletService_TransferMonthlyLimit(t *testing.T) {
+	override := money.MustParse("10000.00")
+	tests := []struct {
+		name          string
+		now           time.Time
+		walletLimit   *money.Amount
+		sent          money.Amount
+		amount        money.Amount
+		expectedSince time.Time
+		expectedError string
+	}{
+		{
+			name:          "within the limit",
+			now:           time.Date(2024, time.January, 31, 23, 59, 59, 0, time.UTC),
+			sent:          money.MustParse("4900.00"),
+			amount:        money.MustParse("100.00"),
+			expectedSince: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "past the limit on the last second of the month",
+			now:           time.Date(2024, time.January, 31, 23, 59, 59, 0, time.UTC),
+			sent:          money.MustParse("4900.00"),
+			amount:        money.MustParse("100.01"),
+			expectedSince: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			expectedError: "100.00 of the 5000.00 monthly limit remains",
+		},
+		{
+			name:          "new month counts from its first midnight",
+			now:           time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+			amount:        money.MustParse("5000.00"),
+			expectedSince: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "local evening already in the next UTC month",
+			now:           time.Date(2024, time.December, 31, 20, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)),
+			amount:        money.MustParse("5000.00"),
+			expectedSince: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "wallet override",
+			now:           time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC),
+			walletLimit:   &override,
+			sent:          money.MustParse("9000.00"),
+			amount:        money.MustParse("1000.00"),
+			expectedSince: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			senderWallet := uuid.New()
+			mockDB.ExpectBegin()
+			if tt.expectedError == "" {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", tt.amount).
+				Return(&models.Wallet{ID: senderWallet, MonthlyTransferLimit: tt.walletLimit}, nil)
+			mockTxRepo.On("SumTransactionsSince", mock.Anything, mock.Anything, senderWallet.String(),
+				[]models.TransactionType{models.TransactionTypeTransferOut}, tt.expectedSince).
+				Return(tt.sent, nil).Once()
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", tt.amount).Return(&models.Wallet{ID: uuid.New()}, nil).Maybe()
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{MonthlyTransferLimit: money.MustParse("5000.00")})
+			service.now = func() time.Time { return tt.now }
+			result, err := service.Transfer(context.Background(), "user1", "user2", tt.amount, "", "", nil)
+
+			if tt.expectedError != "" {
+				assert.ErrorIs(t, err, ErrMonthlyLimitExceeded)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				assert.Nil(t, result)
+				mockWalletRepo.AssertNotCalled(t, "CreditWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, result)
+			}
+			mockTxRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStartOfMonthUTC(t *testing.T) {
+	tests := []struct {
+		name     string
+		t        time.Time
+		expected time.Time
+	}{
+		{"first midnight", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"last instant of the month", time.Date(2024, time.February, 29, 23, 59, 59, 999999999, time.UTC), time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"ahead of UTC", time.Date(2024, time.March, 1, 7, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)), time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"new year", time.Date(2024, time.December, 31, 20, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, startOfMonthUTC(tt.t))
+		})
+	}
+}
+
+func TestWalletService_Velocity(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 30, 0, time.UTC)
+	oldest := now.Add(-20 * time.Second)
+	tests := []struct {
+		name          string
+		count         int
+		expectedAfter time.Duration
+	}{
+		{"below the limit", 9, 0},
+		{"at the limit", 10, 40 * time.Second},
+		{"past a lowered limit", 12, 40 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			mockDB.ExpectBegin()
+			if tt.expectedAfter == 0 {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(&models.Wallet{ID: walletID}, nil)
+			mockTxRepo.On("CountTransactionsSince", mock.Anything, mock.Anything, walletID.String(), velocityOperations, now.Add(-time.Minute)).
+				Return(tt.count, &oldest, nil).Once()
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{MaxOperationsPerMinute: 10})
+			service.now = func() time.Time { return now }
+			_, _, err = service.Deposit(context.Background(), "user1", 1000, "", "", nil)
+
+			if tt.expectedAfter == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrTooManyOperations)
+				after, ok := RetryAfter(err)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedAfter, after)
+				mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+			}
+			mockTxRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_VelocityAppliesToWithdrawalsAndTransfers(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	senderWallet := uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(&models.Wallet{ID: senderWallet}, nil)
+	mockTxRepo.On("CountTransactionsSince", mock.Anything, mock.Anything, senderWallet.String(), velocityOperations, mock.Anything).
+		Return(10, nil, nil).Twice()
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{MaxOperationsPerMinute: 10})
+	_, _, err = service.Withdraw(context.Background(), "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrTooManyOperations)
+	_, err = service.Transfer(context.Background(), "user1", "user2", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrTooManyOperations)
+
+	after, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, after, "without a recorded oldest operation the whole window applies")
+	mockWalletRepo.AssertNotCalled(t, "CreditWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_VelocityIgnoresRejectedRequests(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	// Requests failing validation never open a transaction, let alone count
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{MaxOperationsPerMinute: 1})
+	_, _, err = service.Deposit(context.Background(), "user1", 0, "", "", nil)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = service.Transfer(context.Background(), "user1", "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrSelfTransfer)
+
+	mockTxRepo.AssertNotCalled(t, "CountTransactionsSince", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	_, ok := RetryAfter(err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"walletapp/internal/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Email is a message to one user
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// LogMailer is a Mailer that writes emails to the log instead of sending them, until
+// the service is connected to a mail provider. The log then holds whatever the emails
+// carry, such as password reset tokens, so it is meant for development only.
+type LogMailer struct{}
+
+// Send logs email at info level
+func (LogMailer) Send(ctx context.Context, email Email) error {
+	logger.FromContext(ctx).WithFields(logrus.Fields{
+		"to":      email.To,
+		"subject": email.Subject,
+		"body":    email.Body,
+	}).Info("Email not sent, logged instead")
+	return nil
+}
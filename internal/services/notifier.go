@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// webhookTimeout bounds each request WebhookNotifier makes
+const webhookTimeout = 5 * time.Second
+
+// TransferReceivedEvent reports money arriving in a user's wallet through a transfer
+type TransferReceivedEvent struct {
+	TransferID  uuid.UUID    `json:"transfer_id"`
+	FromUserID  string       `json:"from_user_id"`
+	ToUserID    string       `json:"to_user_id"`
+	ToWalletID  uuid.UUID    `json:"to_wallet_id"`
+	Amount      money.Amount `json:"amount"`
+	Currency    string       `json:"currency"`
+	Description *string      `json:"description,omitempty"`
+	OccurredAt  time.Time    `json:"occurred_at"`
+}
+
+// LowBalanceEvent reports a wallet's balance dropping below the low balance threshold
+type LowBalanceEvent struct {
+	UserID     string       `json:"user_id"`
+	WalletID   uuid.UUID    `json:"wallet_id"`
+	Balance    money.Amount `json:"balance"`
+	Threshold  money.Amount `json:"threshold"`
+	Currency   string       `json:"currency"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// Notifier is told about wallet events once the operation behind them has committed.
+// Calls are made from their own goroutine, and an error is only logged: a notifier
+// can never fail or slow down the operation it reports.
+type Notifier interface {
+	TransferReceived(ctx context.Context, event TransferReceivedEvent) error
+	LowBalance(ctx context.Context, event LowBalanceEvent) error
+}
+
+// noopNotifier is the Notifier used when none is configured
+type noopNotifier struct{}
+
+func (noopNotifier) TransferReceived(context.Context, TransferReceivedEvent) error { return nil }
+
+func (noopNotifier) LowBalance(context.Context, LowBalanceEvent) error { return nil }
+
+// LogNotifier writes wallet events to the application log
+type LogNotifier struct{}
+
+// TransferReceived logs a received transfer
+func (LogNotifier) TransferReceived(_ context.Context, event TransferReceivedEvent) error {
+	logger.WithUser(event.ToUserID).WithFields(logrus.Fields{
+		"operation":    "notify_transfer_received",
+		"transfer_id":  event.TransferID.String(),
+		"from_user_id": event.FromUserID,
+		"amount":       event.Amount,
+		"currency":     event.Currency,
+	}).Info("Transfer received")
+	return nil
+}
+
+// LowBalance logs a wallet falling below the low balance threshold
+func (LogNotifier) LowBalance(_ context.Context, event LowBalanceEvent) error {
+	logger.WithUser(event.UserID).WithFields(logrus.Fields{
+		"operation": "notify_low_balance",
+		"wallet_id": event.WalletID.String(),
+		"balance":   event.Balance,
+		"threshold": event.Threshold,
+		"currency":  event.Currency,
+	}).Info("Wallet balance is low")
+	return nil
+}
+
+// WebhookNotifier POSTs each wallet event as JSON to a URL, wrapped as
+// {"event": "transfer_received", "data": {...}}
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// TransferReceived posts a transfer_received event
+func (n *WebhookNotifier) TransferReceived(ctx context.Context, event TransferReceivedEvent) error {
+	return n.post(ctx, "transfer_received", event)
+}
+
+// LowBalance posts a low_balance event
+func (n *WebhookNotifier) LowBalance(ctx context.Context, event LowBalanceEvent) error {
+	return n.post(ctx, "low_balance", event)
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, name string, data any) error {
+	body, err := json.Marshal(struct {
+		Event string `json:"event"`
+		Data  any    `json:"data"`
+	}{name, data})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// walletEvents collects the events of one attempt of an operation, so they are only
+// sent once it commits. A nil *walletEvents discards them.
+type walletEvents struct {
+	transfers  []TransferReceivedEvent
+	lowBalance []LowBalanceEvent
+}
+
+// transferReceived records that toWallet was credited amount by a transfer
+func (e *walletEvents) transferReceived(transferID uuid.UUID, fromUserID, toUserID string, toWallet *models.Wallet, amount money.Amount, description *string, at time.Time) {
+	if e == nil {
+		return
+	}
+	e.transfers = append(e.transfers, TransferReceivedEvent{
+		TransferID:  transferID,
+		FromUserID:  fromUserID,
+		ToUserID:    toUserID,
+		ToWalletID:  toWallet.ID,
+		Amount:      amount,
+		Currency:    toWallet.Currency,
+		Description: description,
+		OccurredAt:  at,
+	})
+}
+
+// debited records a low balance event when taking amount off wallet, whose balance is
+// already reduced, took it from at or above threshold to below it. Wallets that were
+// already low are not reported again.
+func (e *walletEvents) debited(userID string, wallet *models.Wallet, amount, threshold money.Amount, at time.Time) {
+	if e == nil || threshold <= 0 {
+		return
+	}
+	if wallet.Balance >= threshold || wallet.Balance+amount < threshold {
+		return
+	}
+	e.lowBalance = append(e.lowBalance, LowBalanceEvent{
+		UserID:     userID,
+		WalletID:   wallet.ID,
+		Balance:    wallet.Balance,
+		Threshold:  threshold,
+		Currency:   wallet.Currency,
+		OccurredAt: at,
+	})
+}
+
+// notify hands committed events to the notifier in the background. The notifier gets
+// a context that keeps the caller's values but is not cancelled with it, and its
+// failures and panics are logged and otherwise ignored.
+func (s *WalletService) notify(ctx context.Context, events walletEvents) {
+	if len(events.transfers) == 0 && len(events.lowBalance) == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		log := logger.WithOperation("notify").WithContext(ctx)
+		defer func() {
+			if r := recover(); r != nil {
+				log.WithField("panic", r).Error("Notifier panicked")
+			}
+		}()
+		for _, event := range events.transfers {
+			if err := s.notifier.TransferReceived(ctx, event); err != nil {
+				log.WithFields(logrus.Fields{"transfer_id": event.TransferID.String(), "error": err.Error()}).Warn("Failed to notify transfer received")
+			}
+		}
+		for _, event := range events.lowBalance {
+			if err := s.notifier.LowBalance(ctx, event); err != nil {
+				log.WithFields(logrus.Fields{"wallet_id": event.WalletID.String(), "error": err.Error()}).Warn("Failed to notify low balance")
+			}
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingNotifier passes every event it receives to its channels, then returns err
+type recordingNotifier struct {
+	transfers  chan TransferReceivedEvent
+	lowBalance chan LowBalanceEvent
+	err        error
+}
+
+func newRecordingNotifier(err error) *recordingNotifier {
+	return &recordingNotifier{
+		transfers:  make(chan TransferReceivedEvent, 10),
+		lowBalance: make(chan LowBalanceEvent, 10),
+		err:        err,
+	}
+}
+
+func (n *recordingNotifier) TransferReceived(_ context.Context, event TransferReceivedEvent) error {
+	n.transfers <- event
+	return n.err
+}
+
+func (n *recordingNotifier) LowBalance(_ context.Context, event LowBalanceEvent) error {
+	n.lowBalance <- event
+	return n.err
+}
+
+func TestWalletService_Transfer_NotifiesAfterCommit(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	toWallet := &models.Wallet{ID: uuid.New(), Balance: 8000, Currency: "USD"}
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New(), Balance: 500, Currency: "USD"}, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(3000)).Return(toWallet, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// The notifier failing must not fail the transfer
+	notifier := newRecordingNotifier(errors.New("smtp down"))
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		Notifier:            notifier,
+		LowBalanceThreshold: 1000,
+	})
+	result, err := service.Transfer(context.Background(), "user1", "user2", 3000, "", "rent", nil)
+	assert.NoError(t, err)
+
+	select {
+	case event := <-notifier.transfers:
+		assert.Equal(t, result.TransferID, event.TransferID)
+		assert.Equal(t, "user1", event.FromUserID)
+		assert.Equal(t, "user2", event.ToUserID)
+		assert.Equal(t, toWallet.ID, event.ToWalletID)
+		assert.Equal(t, money.Amount(3000), event.Amount)
+		assert.Equal(t, "USD", event.Currency)
+		if assert.NotNil(t, event.Description) {
+			assert.Equal(t, "rent", *event.Description)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transfer received was not notified")
+	}
+	select {
+	case event := <-notifier.lowBalance:
+		assert.Equal(t, "user1", event.UserID)
+		assert.Equal(t, money.Amount(500), event.Balance)
+		assert.Equal(t, money.Amount(1000), event.Threshold)
+	case <-time.After(time.Second):
+		t.Fatal("low balance was not notified")
+	}
+}
+
+func TestWalletService_Transfer_FailureNotifiesNothing(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(nil, repositories.ErrInsufficientBalance)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New()}, nil).Maybe()
+
+	notifier := newRecordingNotifier(nil)
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{Notifier: notifier, LowBalanceThreshold: 1000})
+	_, err = service.Transfer(context.Background(), "user1", "user2", 3000, "", "", nil)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+	select {
+	case event := <-notifier.transfers:
+		t.Fatalf("failed transfer was notified: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWalletEvents_Debited(t *testing.T) {
+	tests := []struct {
+		name      string
+		after     money.Amount
+		amount    money.Amount
+		threshold money.Amount
+		reported  bool
+	}{
+		{"crosses the threshold", 900, 200, 1000, true},
+		{"from exactly the threshold", 999, 1, 1000, true},
+		{"stays above", 1000, 500, 1000, false},
+		{"was already low", 500, 100, 1000, false},
+		{"no threshold", 0, 5000, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events walletEvents
+			events.debited("user1", &models.Wallet{ID: uuid.New(), Balance: tt.after}, tt.amount, tt.threshold, time.Now())
+			assert.Equal(t, tt.reported, len(events.lowBalance) == 1)
+		})
+	}
+
+	// A nil collector, as used by moves between a user's own wallets, discards events
+	var discard *walletEvents
+	discard.debited("user1", &models.Wallet{Balance: 900}, 200, 1000, time.Now())
+	discard.transferReceived(uuid.New(), "user1", "user1", &models.Wallet{}, 200, nil, time.Now())
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got struct {
+		Event string                `json:"event"`
+		Data  TransferReceivedEvent `json:"data"`
+	}
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL)
+	event := TransferReceivedEvent{TransferID: uuid.New(), FromUserID: "user1", ToUserID: "user2", Amount: 1250, Currency: "USD"}
+
+	assert.NoError(t, notifier.TransferReceived(context.Background(), event))
+	assert.Equal(t, "transfer_received", got.Event)
+	assert.Equal(t, event.TransferID, got.Data.TransferID)
+	assert.Equal(t, money.Amount(1250), got.Data.Amount)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, notifier.LowBalance(context.Background(), LowBalanceEvent{UserID: "user1"}))
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"time"
+	"walletapp/internal/logger"
+)
+
+// partitionInterval is how often the partition worker makes sure transactions has a
+// partition for the current and the next month. A month is made well before it starts,
+// so a missed run costs nothing.
+const partitionInterval = 6 * time.Hour
+
+// CreateTransactionPartitions makes the monthly partitions of transactions for the
+// current and the next month, those that do not exist yet. Transactions recorded in a
+// month without a partition land in the default partition and move into the month's
+// own once it is made.
+func (s *WalletService) CreateTransactionPartitions(ctx context.Context) error {
+	log := logger.WithOperation("create_transaction_partitions").WithContext(ctx)
+
+	month := startOfMonthUTC(s.now())
+	for _, m := range []time.Time{month, month.AddDate(0, 1, 0)} {
+		name, err := s.transactionRepo.CreateTransactionPartition(ctx, m)
+		if err != nil {
+			log.WithField("error", err.Error()).WithField("month", m.Format("2006-01")).Error("Failed to create transaction partition")
+			return err
+		}
+		log.WithField("partition", name).Debug("Transaction partition ready")
+	}
+	return nil
+}
+
+// RunPartitionWorker makes the upcoming monthly partitions of transactions every
+// partition interval until ctx is cancelled
+func (s *WalletService) RunPartitionWorker(ctx context.Context) {
+	log := logger.WithOperation("partition_worker").WithContext(ctx)
+	log.WithField("interval", partitionInterval.String()).Info("Partition worker started")
+
+	ticker := time.NewTicker(partitionInterval)
+	defer ticker.Stop()
+	for {
+		// CreateTransactionPartitions logs its own failures
+		_ = s.CreateTransactionPartitions(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info("Partition worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_CreateTransactionPartitions(t *testing.T) {
+	mockTxRepo := new(MockTransactionRepo)
+	mockTxRepo.On("CreateTransactionPartition", mock.Anything, time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)).Return("transactions_2024_12", nil)
+	mockTxRepo.On("CreateTransactionPartition", mock.Anything, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)).Return("transactions_2025_01", nil)
+
+	service := NewWalletService(new(MockWalletRepo), mockTxRepo, nil, WalletServiceConfig{})
+	service.now = func() time.Time { return time.Date(2024, time.December, 31, 23, 30, 0, 0, time.UTC) }
+
+	assert.NoError(t, service.CreateTransactionPartitions(context.Background()))
+	mockTxRepo.AssertExpectations(t)
+}
+
+func TestWalletService_CreateTransactionPartitions_StopsOnError(t *testing.T) {
+	mockTxRepo := new(MockTransactionRepo)
+	failure := errors.New("connection reset")
+	mockTxRepo.On("CreateTransactionPartition", mock.Anything, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)).Return("", failure)
+
+	service := NewWalletService(new(MockWalletRepo), mockTxRepo, nil, WalletServiceConfig{})
+	service.now = func() time.Time { return time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC) }
+
+	assert.ErrorIs(t, service.CreateTransactionPartitions(context.Background()), failure)
+	mockTxRepo.AssertNumberOfCalls(t, "CreateTransactionPartition", 1)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"walletapp/internal/logger"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrWrongPassword is returned when the current password given to change a password
+	// is not the user's
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrWeakPassword is returned for a new password that does not meet the strength rules
+	ErrWeakPassword = errors.New("password is too weak")
+	// ErrPasswordUnchanged is returned when a new password is the user's current one
+	ErrPasswordUnchanged = errors.New("new password must differ from the current password")
+	// ErrTooManyPasswordAttempts is returned when a user gave as many wrong current
+	// passwords in the last 15 minutes as they may
+	ErrTooManyPasswordAttempts = errors.New("too many wrong current passwords")
+)
+
+const (
+	// minPasswordLength is the fewest characters a new password may have
+	minPasswordLength = 8
+	// maxPasswordLength is the most bytes a password may have, as bcrypt ignores the rest
+	maxPasswordLength = 72
+	// passwordFailureWindow is the period maxPasswordFailures counts wrong current
+	// passwords over
+	passwordFailureWindow = 15 * time.Minute
+	// maxPasswordFailures is how many wrong current passwords a user may give in any
+	// 15 minutes
+	maxPasswordFailures = 5
+)
+
+// ValidatePassword checks a new password against the strength rules: 8 to 72 bytes,
+// with at least one letter and one digit, and not only whitespace at either end.
+// Returns ErrWeakPassword naming the rule it breaks.
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%w: it must be at least %d characters", ErrWeakPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("%w: it must be at most %d bytes", ErrWeakPassword, maxPasswordLength)
+	}
+	if strings.TrimSpace(password) != password {
+		return fmt.Errorf("%w: it must not start or end with whitespace", ErrWeakPassword)
+	}
+	if !strings.ContainsFunc(password, unicode.IsLetter) || !strings.ContainsFunc(password, unicode.IsDigit) {
+		return fmt.Errorf("%w: it must contain a letter and a digit", ErrWeakPassword)
+	}
+	return nil
+}
+
+// ChangePassword replaces a user's password with newPassword, once currentPassword is
+// checked against theirs. Returns ErrWrongPassword when it is not, ErrWeakPassword when
+// newPassword breaks the strength rules, ErrPasswordUnchanged when it is the current
+// password and ErrUserNotFound for an unknown user. Once 5 wrong current passwords were
+// given in the last 15 minutes, returns ErrTooManyPasswordAttempts, with how long to
+// wait as RetryAfter tells, without checking the password. The change is logged, never
+// the passwords.
+func (s *UserService) ChangePassword(ctx context.Context, id, currentPassword, newPassword string) error {
+	log := logger.WithUser(id).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":    "change_password",
+		"performed_by": id,
+	})
+	return s.setPassword(ctx, log, id, &currentPassword, newPassword)
+}
+
+// ResetPassword replaces a user's password with newPassword without checking their
+// current one, for admins helping a user who lost it. performedBy, the acting admin,
+// is logged with the change. Returns ErrAdminRequired without performedBy, and
+// otherwise the errors of ChangePassword.
+func (s *UserService) ResetPassword(ctx context.Context, id, newPassword, performedBy string) error {
+	performedBy = strings.TrimSpace(performedBy)
+	log := logger.WithUser(id).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":    "reset_password",
+		"performed_by": performedBy,
+	})
+	if performedBy == "" {
+		log.Warn("Password reset without an acting admin rejected")
+		return ErrAdminRequired
+	}
+	return s.setPassword(ctx, log, id, nil, newPassword)
+}
+
+// setPassword replaces a user's password with newPassword, checking currentPassword
+// first unless it is nil. The current password is checked before the new one, so the
+// strength rules cannot be used to tell whether a guess was right.
+func (s *UserService) setPassword(ctx context.Context, log *logrus.Entry, id string, currentPassword *string, newPassword string) error {
+	log.Info("Changing password")
+
+	user, err := s.repo.GetUserCredentialsByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			log.Warn("User not found")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to get user credentials")
+		}
+		return err
+	}
+	if currentPassword != nil {
+		if err := s.checkCurrentPassword(ctx, log, id, user.Password, *currentPassword); err != nil {
+			return err
+		}
+	}
+
+	if err := ValidatePassword(newPassword); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Weak password rejected")
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(newPassword)) == nil {
+		log.Warn("Password change to the current password rejected")
+		return ErrPasswordUnchanged
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to hash password")
+		return err
+	}
+	if err := s.repo.UpdateUserPassword(ctx, id, string(hash)); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update password")
+		return err
+	}
+	log.Info("Password changed successfully")
+	return nil
+}
+
+// checkCurrentPassword returns ErrWrongPassword, and records the failure, when
+// currentPassword does not match the user's passwordHash, and ErrTooManyPasswordAttempts
+// once they gave maxPasswordFailures wrong ones within passwordFailureWindow
+func (s *UserService) checkCurrentPassword(ctx context.Context, log *logrus.Entry, id, passwordHash, currentPassword string) error {
+	now := s.now()
+	count, oldest, err := s.repo.CountPasswordFailuresSince(ctx, id, now.Add(-passwordFailureWindow))
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to count password failures")
+		return err
+	}
+	if count >= maxPasswordFailures {
+		// Another attempt may be made once the oldest failure in the window leaves it
+		after := passwordFailureWindow
+		if oldest != nil {
+			after = oldest.Add(passwordFailureWindow).Sub(now)
+		}
+		if after < time.Second {
+			after = time.Second
+		}
+		log.WithField("count", count).Warn("Too many wrong current passwords")
+		return &retryAfterError{
+			err:   fmt.Errorf("%w: at most %d are allowed per 15 minutes", ErrTooManyPasswordAttempts, maxPasswordFailures),
+			after: after,
+		}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(currentPassword)) != nil {
+		if err := s.repo.RecordPasswordFailure(ctx, id); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to record password failure")
+			return err
+		}
+		log.Warn("Password change with a wrong current password rejected")
+		return ErrWrongPassword
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetTTL is how long a password reset token can be used after it is sent
+const passwordResetTTL = 30 * time.Minute
+
+// ErrInvalidResetToken is returned when a password reset token is unknown, was already
+// used or has expired
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordResetRepo finds users by email and stores their password reset tokens
+type PasswordResetRepo interface {
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	GetPasswordResetTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.PasswordResetToken, error)
+	UsePasswordResetTokensTx(ctx context.Context, tx pgx.Tx, userID string) error
+	UpdateUserPasswordTx(ctx context.Context, tx pgx.Tx, id, passwordHash string) error
+}
+
+// PasswordResetService lets users who forgot their password set a new one with a
+// single-use token emailed to them
+type PasswordResetService struct {
+	repo   PasswordResetRepo
+	mailer Mailer
+	db     DB
+	// now tells the time tokens expire against
+	now func() time.Time
+}
+
+// NewPasswordResetService creates a PasswordResetService that emails tokens through mailer
+func NewPasswordResetService(repo PasswordResetRepo, mailer Mailer, db DB) *PasswordResetService {
+	return &PasswordResetService{
+		repo:   repo,
+		mailer: mailer,
+		db:     db,
+		now:    time.Now,
+	}
+}
+
+// RequestReset emails the user with email, ignoring case, a token that resets their
+// password within 30 minutes. An email no user has is not an error, so callers cannot
+// tell which emails have accounts; neither is failing to send the email, which is
+// logged.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	log := logger.WithOperation("request_password_reset").WithContext(ctx).WithField("email", email)
+	log.Info("Password reset requested")
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		log.Info("Password reset requested for an unknown email")
+		return nil
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get user")
+		return err
+	}
+	log = log.WithField("user_id", user.ID.String())
+
+	token, err := newToken()
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to generate password reset token")
+		return err
+	}
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: s.now().Add(passwordResetTTL),
+	}
+	if err := s.repo.CreatePasswordResetToken(ctx, record); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to save password reset token")
+		return err
+	}
+
+	err = s.mailer.Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use this token to reset your password before %s: %s\n\nIf you did not ask to reset your password, ignore this email.",
+			record.ExpiresAt.UTC().Format(time.RFC1123), token),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to send password reset email")
+		return nil
+	}
+	log.Info("Password reset email sent")
+	return nil
+}
+
+// Reset sets the password of the user a reset token was sent to, and uses up every
+// token they were sent. Returns ErrWeakPassword when newPassword breaks the strength
+// rules, and ErrInvalidResetToken, saying why, when the token is unknown, was already
+// used or has expired.
+func (s *PasswordResetService) Reset(ctx context.Context, token, newPassword string) error {
+	log := logger.WithOperation("reset_password_with_token").WithContext(ctx)
+	log.Info("Resetting password with token")
+
+	if err := ValidatePassword(newPassword); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Weak password rejected")
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to hash password")
+		return err
+	}
+	return s.reset(ctx, log, hashToken(token), string(hash))
+}
+
+// reset uses the token with tokenHash to set its user's password hash, inside its own
+// database transaction
+func (s *PasswordResetService) reset(ctx context.Context, log *logrus.Entry, tokenHash, passwordHash string) (err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return err
+	}
+	defer func() {
+		err = finishTx(ctx, log, tx, "reset password", err)
+	}()
+
+	record, err := s.repo.GetPasswordResetTokenForUpdateTx(ctx, tx, tokenHash)
+	if errors.Is(err, repositories.ErrPasswordResetTokenNotFound) {
+		log.Warn("Password reset with an unknown token rejected")
+		return fmt.Errorf("%w: unknown token", ErrInvalidResetToken)
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get password reset token")
+		return err
+	}
+	log = log.WithField("user_id", record.UserID.String())
+	if record.UsedAt != nil {
+		log.Warn("Password reset with a used token rejected")
+		return fmt.Errorf("%w: the token was already used", ErrInvalidResetToken)
+	}
+	if !s.now().Before(record.ExpiresAt) {
+		log.Warn("Password reset with an expired token rejected")
+		return fmt.Errorf("%w: the token has expired", ErrInvalidResetToken)
+	}
+
+	if err = s.repo.UpdateUserPasswordTx(ctx, tx, record.UserID.String(), passwordHash); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			// The user was deleted after asking for the token
+			log.Warn("Password reset for a deleted user rejected")
+			return fmt.Errorf("%w: unknown token", ErrInvalidResetToken)
+		}
+		log.WithField("error", err.Error()).Error("Failed to update password")
+		return err
+	}
+	if err = s.repo.UsePasswordResetTokensTx(ctx, tx, record.UserID.String()); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to use password reset tokens")
+		return err
+	}
+	log.Info("Password reset successfully")
+	return nil
+}
+
+// newToken returns a random token to email a user, 32 bytes encoded for URLs
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken returns the hex SHA-256 of a token emailed to a user, the form it is
+// stored and looked up in. Tokens are random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+var defaultPasswordResetService *PasswordResetService
+
+// SetDefaultPasswordResetService sets the service used by RequestPasswordReset and
+// ResetPasswordWithToken
+func SetDefaultPasswordResetService(service *PasswordResetService) {
+	defaultPasswordResetService = service
+}
+
+func RequestPasswordReset(ctx context.Context, email string) error {
+	if defaultPasswordResetService == nil {
+		panic("default password reset service not initialized - call SetDefaultPasswordResetService first")
+	}
+	return defaultPasswordResetService.RequestReset(ctx, email)
+}
+
+func ResetPasswordWithToken(ctx context.Context, token, newPassword string) error {
+	if defaultPasswordResetService == nil {
+		panic("default password reset service not initialized - call SetDefaultPasswordResetService first")
+	}
+	return defaultPasswordResetService.Reset(ctx, token, newPassword)
+}
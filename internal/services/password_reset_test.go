@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type MockPasswordResetRepo struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockPasswordResetRepo) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetRepo) GetPasswordResetTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.PasswordResetToken, error) {
+	args := m.Called(ctx, tx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetRepo) UsePasswordResetTokensTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	args := m.Called(ctx, tx, userID)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetRepo) UpdateUserPasswordTx(ctx context.Context, tx pgx.Tx, id, passwordHash string) error {
+	args := m.Called(ctx, tx, id, passwordHash)
+	return args.Error(0)
+}
+
+// recordingMailer keeps the emails sent through it
+type recordingMailer struct {
+	sent []Email
+	err  error
+}
+
+func (m *recordingMailer) Send(_ context.Context, email Email) error {
+	m.sent = append(m.sent, email)
+	return m.err
+}
+
+func TestPasswordResetService_RequestReset(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "jane@example.com"}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("known email", func(t *testing.T) {
+		repo := new(MockPasswordResetRepo)
+		repo.On("GetUserByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		var saved *models.PasswordResetToken
+		repo.On("CreatePasswordResetToken", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(1).(*models.PasswordResetToken)
+		}).Return(nil)
+		mailer := &recordingMailer{}
+
+		service := NewPasswordResetService(repo, mailer, nil)
+		service.now = func() time.Time { return now }
+		err := service.RequestReset(context.Background(), " Jane@Example.com")
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, saved) && assert.Len(t, mailer.sent, 1) {
+			assert.Equal(t, user.ID, saved.UserID)
+			assert.Equal(t, now.Add(30*time.Minute), saved.ExpiresAt)
+			assert.Equal(t, "jane@example.com", mailer.sent[0].To)
+			// The email carries the token, and only its hash is stored
+			line, _, _ := strings.Cut(mailer.sent[0].Body, "\n")
+			token := line[strings.LastIndex(line, " ")+1:]
+			assert.Len(t, token, 43)
+			assert.Equal(t, hashToken(token), saved.TokenHash)
+		}
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		repo := new(MockPasswordResetRepo)
+		repo.On("GetUserByEmail", mock.Anything, "nobody@example.com").Return(nil, repositories.ErrUserNotFound)
+		mailer := &recordingMailer{}
+
+		err := NewPasswordResetService(repo, mailer, nil).RequestReset(context.Background(), "nobody@example.com")
+
+		assert.NoError(t, err)
+		assert.Empty(t, mailer.sent)
+		repo.AssertNotCalled(t, "CreatePasswordResetToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("email not sent", func(t *testing.T) {
+		repo := new(MockPasswordResetRepo)
+		repo.On("GetUserByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		repo.On("CreatePasswordResetToken", mock.Anything, mock.Anything).Return(nil)
+		mailer := &recordingMailer{err: errors.New("smtp: connection refused")}
+
+		err := NewPasswordResetService(repo, mailer, nil).RequestReset(context.Background(), "jane@example.com")
+
+		assert.NoError(t, err)
+		assert.Len(t, mailer.sent, 1)
+	})
+}
+
+func TestPasswordResetService_Reset(t *testing.T) {
+	userID := uuid.New()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	usedAt := now.Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		token     *models.PasswordResetToken
+		tokenErr  error
+		wantErr   error
+		wantInErr string
+	}{
+		{
+			name:  "valid token",
+			token: &models.PasswordResetToken{UserID: userID, ExpiresAt: now.Add(time.Minute)},
+		},
+		{
+			name:      "invalid token",
+			tokenErr:  repositories.ErrPasswordResetTokenNotFound,
+			wantErr:   ErrInvalidResetToken,
+			wantInErr: "unknown token",
+		},
+		{
+			name:      "reused token",
+			token:     &models.PasswordResetToken{UserID: userID, ExpiresAt: now.Add(time.Minute), UsedAt: &usedAt},
+			wantErr:   ErrInvalidResetToken,
+			wantInErr: "already used",
+		},
+		{
+			name:      "expired token",
+			token:     &models.PasswordResetToken{UserID: userID, ExpiresAt: now},
+			wantErr:   ErrInvalidResetToken,
+			wantInErr: "expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+			mockDB.ExpectBegin()
+			if tt.wantErr == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			repo := new(MockPasswordResetRepo)
+			if tt.token != nil {
+				repo.On("GetPasswordResetTokenForUpdateTx", mock.Anything, mock.Anything, hashToken("t0k3n")).Return(tt.token, nil)
+			} else {
+				repo.On("GetPasswordResetTokenForUpdateTx", mock.Anything, mock.Anything, hashToken("t0k3n")).Return(nil, tt.tokenErr)
+			}
+			if tt.wantErr == nil {
+				repo.On("UpdateUserPasswordTx", mock.Anything, mock.Anything, userID.String(), mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpass456")) == nil
+				})).Return(nil)
+				repo.On("UsePasswordResetTokensTx", mock.Anything, mock.Anything, userID.String()).Return(nil)
+			}
+
+			service := NewPasswordResetService(repo, &recordingMailer{}, mockDB)
+			service.now = func() time.Time { return now }
+			err = service.Reset(context.Background(), "t0k3n", "newpass456")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Contains(t, err.Error(), tt.wantInErr)
+				repo.AssertNotCalled(t, "UpdateUserPasswordTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+			}
+			repo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPasswordResetService_Reset_WeakPassword(t *testing.T) {
+	repo := new(MockPasswordResetRepo)
+
+	err := NewPasswordResetService(repo, &recordingMailer{}, nil).Reset(context.Background(), "t0k3n", "short")
+
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	repo.AssertNotCalled(t, "GetPasswordResetTokenForUpdateTx", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidatePassword(t *testing.T) {
+	cases := []struct {
+		password string
+		wantErr  bool
+	}{
+		{password: "hunter22"},
+		{password: "correct horse 42"},
+		{password: "short1", wantErr: true},
+		{password: "lettersonly", wantErr: true},
+		{password: "1234567890", wantErr: true},
+		{password: " padded99", wantErr: true},
+		{password: strings.Repeat("a1", 37), wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.password, func(t *testing.T) {
+			err := ValidatePassword(tc.password)
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrWeakPassword)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	id := uuid.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("oldpass123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	existing := &models.User{ID: id, Password: string(hash)}
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	since := now.Add(-15 * time.Minute)
+	oldest := now.Add(-10 * time.Minute)
+
+	cases := []struct {
+		name        string
+		current     string
+		newPassword string
+		setupMock   func(*MockUserRepo)
+		wantErr     error
+		// wantRetryAfter is how long RetryAfter tells to wait on wantErr
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:        "changed",
+			current:     "oldpass123",
+			newPassword: "newpass456",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("CountPasswordFailuresSince", mock.Anything, id.String(), since).Return(0, (*time.Time)(nil), nil)
+				m.On("UpdateUserPassword", mock.Anything, id.String(), mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newpass456")) == nil
+				})).Return(nil)
+			},
+		},
+		{
+			name:        "wrong current password",
+			current:     "guess12345",
+			newPassword: "newpass456",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("CountPasswordFailuresSince", mock.Anything, id.String(), since).Return(0, (*time.Time)(nil), nil)
+				m.On("RecordPasswordFailure", mock.Anything, id.String()).Return(nil)
+			},
+			wantErr: ErrWrongPassword,
+		},
+		{
+			name:        "same password",
+			current:     "oldpass123",
+			newPassword: "oldpass123",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("CountPasswordFailuresSince", mock.Anything, id.String(), since).Return(0, (*time.Time)(nil), nil)
+			},
+			wantErr: ErrPasswordUnchanged,
+		},
+		{
+			name:        "weak password",
+			current:     "oldpass123",
+			newPassword: "password",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("CountPasswordFailuresSince", mock.Anything, id.String(), since).Return(0, (*time.Time)(nil), nil)
+			},
+			wantErr: ErrWeakPassword,
+		},
+		{
+			name:        "weak password with a wrong current password",
+			current:     "guess12345",
+			newPassword: "password",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("CountPasswordFailuresSince", mock.Anything, id.String(), since).Return(0, (*time.Time)(nil), nil)
+				m.On("RecordPasswordFailure", mock.Anything, id.String()).Return(nil)
+			},
+			wantErr: ErrWrongPassword,
+		},
+		{
+			name:        "too many wrong current passwords",
+			current:     "oldpass123",
+			newPassword: "newpass456",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("CountPasswordFailuresSince", mock.Anything, id.String(), since).Return(5, &oldest, nil)
+			},
+			wantErr:        ErrTooManyPasswordAttempts,
+			wantRetryAfter: 5 * time.Minute,
+		},
+		{
+			name:        "unknown user",
+			current:     "oldpass123",
+			newPassword: "newpass456",
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(nil, ErrUserNotFound)
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			if tc.setupMock != nil {
+				tc.setupMock(mockRepo)
+			}
+
+			service := NewUserService(mockRepo, nil)
+			service.now = func() time.Time { return now }
+
+			err := service.ChangePassword(context.Background(), id.String(), tc.current, tc.newPassword)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				mockRepo.AssertNotCalled(t, "UpdateUserPassword", mock.Anything, mock.Anything, mock.Anything)
+				if tc.wantRetryAfter > 0 {
+					after, ok := RetryAfter(err)
+					assert.True(t, ok)
+					assert.Equal(t, tc.wantRetryAfter, after)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserService_ResetPassword(t *testing.T) {
+	id := uuid.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("oldpass123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	t.Run("no current password needed", func(t *testing.T) {
+		mockRepo := new(MockUserRepo)
+		mockRepo.On("GetUserCredentialsByID", mock.Anything, id.String()).Return(&models.User{ID: id, Password: string(hash)}, nil)
+		mockRepo.On("UpdateUserPassword", mock.Anything, id.String(), mock.Anything).Return(nil)
+
+		err := NewUserService(mockRepo, nil).ResetPassword(context.Background(), id.String(), "newpass456", "admin-1")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("acting admin required", func(t *testing.T) {
+		mockRepo := new(MockUserRepo)
+
+		err := NewUserService(mockRepo, nil).ResetPassword(context.Background(), id.String(), "newpass456", " ")
+
+		assert.ErrorIs(t, err, ErrAdminRequired)
+		mockRepo.AssertNotCalled(t, "UpdateUserPassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for payment requests
+const (
+	defaultPaymentRequestTTL            = 7 * 24 * time.Hour
+	defaultPaymentRequestExpiryInterval = time.Minute
+)
+
+// Failures returned when answering a payment request
+var (
+	ErrPaymentRequestNotFound    = repositories.ErrPaymentRequestNotFound
+	ErrPaymentRequestAlreadyPaid = errors.New("payment request was already paid")
+	ErrPaymentRequestDeclined    = errors.New("payment request was declined")
+	ErrPaymentRequestExpired     = errors.New("payment request has expired")
+)
+
+// PaymentRequestRepo stores payment requests and their answers
+type PaymentRequestRepo interface {
+	CreatePaymentRequest(ctx context.Context, r *models.PaymentRequest) error
+	GetPendingPaymentRequestsByPayerID(ctx context.Context, payerID string) ([]models.PaymentRequest, error)
+	GetPaymentRequestForUpdateTx(ctx context.Context, tx pgx.Tx, payerID, id string) (*models.PaymentRequest, error)
+	UpdatePaymentRequestTx(ctx context.Context, tx pgx.Tx, r *models.PaymentRequest) error
+	ExpirePaymentRequests(ctx context.Context) (int64, error)
+}
+
+// PaymentRequestConfig holds the tunable settings of payment requests. Zero values
+// fall back to the defaults.
+type PaymentRequestConfig struct {
+	// TTL is how long a request can be answered after it is made
+	TTL time.Duration
+	// ExpiryInterval is how often the worker expires requests past their TTL
+	ExpiryInterval time.Duration
+}
+
+// PaymentRequestConfigFromEnv reads PAYMENT_REQUEST_TTL and
+// PAYMENT_REQUEST_EXPIRY_INTERVAL (durations such as "72h"). Unset variables keep
+// the defaults.
+func PaymentRequestConfigFromEnv() (PaymentRequestConfig, error) {
+	var config PaymentRequestConfig
+	for _, v := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"PAYMENT_REQUEST_TTL", &config.TTL},
+		{"PAYMENT_REQUEST_EXPIRY_INTERVAL", &config.ExpiryInterval},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return PaymentRequestConfig{}, fmt.Errorf("invalid %s %q: must be a positive duration", v.name, raw)
+		}
+		*v.target = d
+	}
+	return config, nil
+}
+
+// PaymentRequestService lets users ask each other for money. An accepted request is
+// paid with an ordinary transfer from the payer to the requester.
+type PaymentRequestService struct {
+	repo           PaymentRequestRepo
+	wallets        *WalletService
+	db             DB
+	ttl            time.Duration
+	expiryInterval time.Duration
+	// now tells the time requests expire against
+	now func() time.Time
+}
+
+// NewPaymentRequestService creates a PaymentRequestService that pays accepted requests
+// through wallets
+func NewPaymentRequestService(repo PaymentRequestRepo, wallets *WalletService, db DB, config PaymentRequestConfig) *PaymentRequestService {
+	if config.TTL <= 0 {
+		config.TTL = defaultPaymentRequestTTL
+	}
+	if config.ExpiryInterval <= 0 {
+		config.ExpiryInterval = defaultPaymentRequestExpiryInterval
+	}
+	return &PaymentRequestService{
+		repo:           repo,
+		wallets:        wallets,
+		db:             db,
+		ttl:            config.TTL,
+		expiryInterval: config.ExpiryInterval,
+		now:            time.Now,
+	}
+}
+
+// Create records a request from requesterID asking payerID for amount, with an
+// optional message shown to the payer. The payer's balance is only checked when the
+// request is accepted.
+func (s *PaymentRequestService) Create(ctx context.Context, requesterID, payerID string, amount money.Amount, message string) (*models.PaymentRequest, error) {
+	log := logger.WithFields(logrus.Fields{
+		"requester_id": requesterID,
+		"payer_id":     payerID,
+		"amount":       amount,
+		"operation":    "create_payment_request",
+	}).WithContext(ctx)
+	log.Info("Creating payment request")
+
+	if err := s.wallets.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Payment request validation failed")
+		return nil, err
+	}
+	if requesterID == payerID {
+		log.Warn("Payment request to self blocked")
+		return nil, ErrSelfTransfer
+	}
+	memo, err := normalizeDescription(message)
+	if err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Payment request validation failed")
+		return nil, err
+	}
+	requester, err := uuid.Parse(requesterID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid requester_id: %w", err)
+	}
+	payer, err := uuid.Parse(payerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payer_id: %w", err)
+	}
+
+	r := &models.PaymentRequest{
+		RequesterID: requester,
+		PayerID:     payer,
+		Amount:      amount,
+		Message:     memo,
+		Status:      models.PaymentRequestStatusPending,
+		ExpiresAt:   s.now().Add(s.ttl),
+	}
+	if err := s.repo.CreatePaymentRequest(ctx, r); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record payment request")
+		return nil, err
+	}
+
+	log.WithField("payment_request_id", r.ID.String()).Info("Payment request created successfully")
+	return r, nil
+}
+
+// GetPending returns the requests payerID has yet to accept or decline
+func (s *PaymentRequestService) GetPending(ctx context.Context, payerID string) ([]models.PaymentRequest, error) {
+	requests, err := s.repo.GetPendingPaymentRequestsByPayerID(ctx, payerID)
+	if err != nil {
+		logger.WithUser(payerID).WithContext(ctx).WithField("error", err.Error()).Error("Failed to get payment requests")
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Accept pays a pending request addressed to payerID with a transfer to the requester
+// and marks it PAID. The request stays row-locked while it is paid, so a second accept,
+// such as a double click, waits and then fails with ErrPaymentRequestAlreadyPaid. The
+// transfer runs under an idempotency key derived from the request ID, so if marking
+// the request PAID fails after the money has moved, accepting again does not pay twice.
+func (s *PaymentRequestService) Accept(ctx context.Context, payerID, requestID string) (request *models.PaymentRequest, err error) {
+	log := logger.WithFields(logrus.Fields{
+		"payer_id":           payerID,
+		"payment_request_id": requestID,
+		"operation":          "accept_payment_request",
+	}).WithContext(ctx)
+	log.Info("Accepting payment request")
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "accept payment request", err); err != nil {
+			request = nil
+		}
+	}()
+
+	request, err = s.pendingRequest(ctx, tx, log, payerID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var message string
+	if request.Message != nil {
+		message = *request.Message
+	}
+	key := "payment-request:" + request.ID.String()
+	result, err := s.wallets.Transfer(ctx, payerID, request.RequesterID.String(), request.Amount, key, message, nil)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Payment request could not be paid")
+		return nil, err
+	}
+
+	request.Status = models.PaymentRequestStatusPaid
+	request.TransferID = &result.TransferID
+	if err = s.repo.UpdatePaymentRequestTx(ctx, tx, request); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to mark payment request paid")
+		return nil, err
+	}
+
+	log.WithField("transfer_id", result.TransferID.String()).Info("Payment request paid successfully")
+	return request, nil
+}
+
+// Decline marks a pending request addressed to payerID as DECLINED, without moving money
+func (s *PaymentRequestService) Decline(ctx context.Context, payerID, requestID string) (request *models.PaymentRequest, err error) {
+	log := logger.WithFields(logrus.Fields{
+		"payer_id":           payerID,
+		"payment_request_id": requestID,
+		"operation":          "decline_payment_request",
+	}).WithContext(ctx)
+	log.Info("Declining payment request")
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "decline payment request", err); err != nil {
+			request = nil
+		}
+	}()
+
+	request, err = s.pendingRequest(ctx, tx, log, payerID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	request.Status = models.PaymentRequestStatusDeclined
+	if err = s.repo.UpdatePaymentRequestTx(ctx, tx, request); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to mark payment request declined")
+		return nil, err
+	}
+
+	log.Info("Payment request declined successfully")
+	return request, nil
+}
+
+// pendingRequest locks a request addressed to payerID and checks it can still be
+// answered. A PENDING request past its expiry counts as expired even before the
+// worker has marked it.
+func (s *PaymentRequestService) pendingRequest(ctx context.Context, tx pgx.Tx, log *logrus.Entry, payerID, requestID string) (*models.PaymentRequest, error) {
+	request, err := s.repo.GetPaymentRequestForUpdateTx(ctx, tx, payerID, requestID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to get payment request")
+		return nil, err
+	}
+
+	switch {
+	case request.Status == models.PaymentRequestStatusPaid:
+		err = ErrPaymentRequestAlreadyPaid
+	case request.Status == models.PaymentRequestStatusDeclined:
+		err = ErrPaymentRequestDeclined
+	case request.Status == models.PaymentRequestStatusExpired, !s.now().Before(request.ExpiresAt):
+		err = ErrPaymentRequestExpired
+	}
+	if err != nil {
+		log.WithField("status", request.Status).Warn("Payment request can no longer be answered")
+		return nil, fmt.Errorf("%w: %s", err, requestID)
+	}
+	return request, nil
+}
+
+// RunWorker expires requests past their TTL every expiry interval until ctx is cancelled
+func (s *PaymentRequestService) RunWorker(ctx context.Context) {
+	log := logger.WithOperation("payment_request_worker").WithContext(ctx)
+	log.WithField("expiry_interval", s.expiryInterval.String()).Info("Payment request worker started")
+
+	ticker := time.NewTicker(s.expiryInterval)
+	defer ticker.Stop()
+	for {
+		expired, err := s.repo.ExpirePaymentRequests(ctx)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to expire payment requests")
+		} else if expired > 0 {
+			log.WithField("count", expired).Info("Expired payment requests")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Payment request worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+var defaultPaymentRequestService *PaymentRequestService
+
+// SetDefaultPaymentRequestService sets the service used by the payment request functions
+func SetDefaultPaymentRequestService(service *PaymentRequestService) {
+	defaultPaymentRequestService = service
+}
+
+func CreatePaymentRequest(ctx context.Context, requesterID, payerID string, amount money.Amount, message string) (*models.PaymentRequest, error) {
+	if defaultPaymentRequestService == nil {
+		panic("default payment request service not initialized - call SetDefaultPaymentRequestService first")
+	}
+	return defaultPaymentRequestService.Create(ctx, requesterID, payerID, amount, message)
+}
+
+func GetPendingPaymentRequests(ctx context.Context, payerID string) ([]models.PaymentRequest, error) {
+	if defaultPaymentRequestService == nil {
+		panic("default payment request service not initialized - call SetDefaultPaymentRequestService first")
+	}
+	return defaultPaymentRequestService.GetPending(ctx, payerID)
+}
+
+func AcceptPaymentRequest(ctx context.Context, payerID, requestID string) (*models.PaymentRequest, error) {
+	if defaultPaymentRequestService == nil {
+		panic("default payment request service not initialized - call SetDefaultPaymentRequestService first")
+	}
+	return defaultPaymentRequestService.Accept(ctx, payerID, requestID)
+}
+
+func DeclinePaymentRequest(ctx context.Context, payerID, requestID string) (*models.PaymentRequest, error) {
+	if defaultPaymentRequestService == nil {
+		panic("default payment request service not initialized - call SetDefaultPaymentRequestService first")
+	}
+	return defaultPaymentRequestService.Decline(ctx, payerID, requestID)
+}
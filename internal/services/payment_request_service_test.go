@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPaymentRequestRepo struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRequestRepo) CreatePaymentRequest(ctx context.Context, r *models.PaymentRequest) error {
+	args := m.Called(ctx, r)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRequestRepo) GetPendingPaymentRequestsByPayerID(ctx context.Context, payerID string) ([]models.PaymentRequest, error) {
+	args := m.Called(ctx, payerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.PaymentRequest), args.Error(1)
+}
+
+func (m *MockPaymentRequestRepo) GetPaymentRequestForUpdateTx(ctx context.Context, tx pgx.Tx, payerID, id string) (*models.PaymentRequest, error) {
+	args := m.Called(ctx, tx, payerID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PaymentRequest), args.Error(1)
+}
+
+func (m *MockPaymentRequestRepo) UpdatePaymentRequestTx(ctx context.Context, tx pgx.Tx, r *models.PaymentRequest) error {
+	args := m.Called(ctx, tx, r)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRequestRepo) ExpirePaymentRequests(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestPaymentRequestService_Create(t *testing.T) {
+	requester, payer := uuid.New().String(), uuid.New().String()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		payerID       string
+		amount        money.Amount
+		message       string
+		repoErr       error
+		expectedErrIs error
+	}{
+		{"records a pending request", payer, 1250, "pizza on Friday", nil, nil},
+		{"request to self", requester, 1250, "", nil, ErrSelfTransfer},
+		{"invalid amount", payer, 0, "", nil, ErrInvalidAmount},
+		{"message too long", payer, 1250, strings.Repeat("x", maxDescriptionLength+1), nil, ErrInvalidDescription},
+		{"unknown user", payer, 1250, "", repositories.ErrUserNotFound, ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockPaymentRequestRepo)
+			repo.On("CreatePaymentRequest", mock.Anything, mock.Anything).Return(tt.repoErr).Maybe()
+
+			service := NewPaymentRequestService(repo, NewWalletService(nil, nil, nil, WalletServiceConfig{}), nil, PaymentRequestConfig{TTL: 48 * time.Hour})
+			service.now = func() time.Time { return now }
+			request, err := service.Create(context.Background(), requester, tt.payerID, tt.amount, tt.message)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, request)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, models.PaymentRequestStatusPending, request.Status)
+			assert.Equal(t, now.Add(48*time.Hour), request.ExpiresAt)
+			if assert.NotNil(t, request.Message) {
+				assert.Equal(t, tt.message, *request.Message)
+			}
+		})
+	}
+}
+
+func TestPaymentRequestService_Accept(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	message := "pizza on Friday"
+	request := &models.PaymentRequest{
+		ID:          uuid.New(),
+		RequesterID: uuid.New(),
+		PayerID:     uuid.New(),
+		Amount:      1250,
+		Message:     &message,
+		Status:      models.PaymentRequestStatusPending,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	payer, requester := request.PayerID.String(), request.RequesterID.String()
+
+	// The accepting transaction keeps the request locked around the transfer's own
+	mockDB.ExpectBegin()
+	mockDB.ExpectBegin()
+	mockTxRepo.On("GetTransactionByIdempotencyKeyTx", mock.Anything, mock.Anything, payer, "payment-request:"+request.ID.String()).
+		Return(nil, repositories.ErrTransactionNotFound)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, payer, money.Amount(1250)).Return(&models.Wallet{ID: uuid.New()}, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, requester, money.Amount(1250)).Return(&models.Wallet{ID: uuid.New()}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.MatchedBy(func(txn *models.Transaction) bool {
+		return txn.Description != nil && *txn.Description == message
+	})).Return(nil)
+	mockDB.ExpectCommit()
+	mockDB.ExpectCommit()
+
+	repo := new(MockPaymentRequestRepo)
+	repo.On("GetPaymentRequestForUpdateTx", mock.Anything, mock.Anything, payer, request.ID.String()).Return(request, nil)
+	repo.On("UpdatePaymentRequestTx", mock.Anything, mock.Anything, request).Return(nil)
+
+	wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	service := NewPaymentRequestService(repo, wallets, mockDB, PaymentRequestConfig{})
+	paid, err := service.Accept(context.Background(), payer, request.ID.String())
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentRequestStatusPaid, paid.Status)
+	assert.NotNil(t, paid.TransferID)
+	repo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestPaymentRequestService_Accept_Rejected(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        models.PaymentRequestStatus
+		expiresIn     time.Duration
+		expectedErrIs error
+	}{
+		{"already paid", models.PaymentRequestStatusPaid, time.Hour, ErrPaymentRequestAlreadyPaid},
+		{"declined", models.PaymentRequestStatusDeclined, time.Hour, ErrPaymentRequestDeclined},
+		{"expired by the worker", models.PaymentRequestStatusExpired, -time.Hour, ErrPaymentRequestExpired},
+		{"past its expiry before the worker ran", models.PaymentRequestStatusPending, -time.Second, ErrPaymentRequestExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			request := &models.PaymentRequest{
+				ID:          uuid.New(),
+				RequesterID: uuid.New(),
+				PayerID:     uuid.New(),
+				Amount:      1250,
+				Status:      tt.status,
+				ExpiresAt:   time.Now().Add(tt.expiresIn),
+			}
+			payer := request.PayerID.String()
+			mockDB.ExpectBegin()
+			mockDB.ExpectRollback()
+			repo := new(MockPaymentRequestRepo)
+			repo.On("GetPaymentRequestForUpdateTx", mock.Anything, mock.Anything, payer, request.ID.String()).Return(request, nil)
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service := NewPaymentRequestService(repo, wallets, mockDB, PaymentRequestConfig{})
+			accepted, err := service.Accept(context.Background(), payer, request.ID.String())
+
+			assert.ErrorIs(t, err, tt.expectedErrIs)
+			assert.Nil(t, accepted)
+			mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			repo.AssertNotCalled(t, "UpdatePaymentRequestTx", mock.Anything, mock.Anything, mock.Anything)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPaymentRequestService_Accept_InsufficientBalanceLeavesRequestPending(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	request := &models.PaymentRequest{
+		ID:          uuid.New(),
+		RequesterID: uuid.New(),
+		PayerID:     uuid.New(),
+		Amount:      1250,
+		Status:      models.PaymentRequestStatusPending,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	payer := request.PayerID.String()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectBegin()
+	mockTxRepo.On("GetTransactionByIdempotencyKeyTx", mock.Anything, mock.Anything, payer, mock.Anything).
+		Return(nil, repositories.ErrTransactionNotFound)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, payer, money.Amount(1250)).Return(nil, repositories.ErrInsufficientBalance)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&models.Wallet{ID: uuid.New()}, nil).Maybe()
+	mockDB.ExpectRollback()
+	mockDB.ExpectRollback()
+
+	repo := new(MockPaymentRequestRepo)
+	repo.On("GetPaymentRequestForUpdateTx", mock.Anything, mock.Anything, payer, request.ID.String()).Return(request, nil)
+
+	wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	service := NewPaymentRequestService(repo, wallets, mockDB, PaymentRequestConfig{})
+	accepted, err := service.Accept(context.Background(), payer, request.ID.String())
+
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Nil(t, accepted)
+	repo.AssertNotCalled(t, "UpdatePaymentRequestTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestPaymentRequestService_Decline(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	request := &models.PaymentRequest{
+		ID:        uuid.New(),
+		PayerID:   uuid.New(),
+		Amount:    1250,
+		Status:    models.PaymentRequestStatusPending,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	payer := request.PayerID.String()
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	repo := new(MockPaymentRequestRepo)
+	repo.On("GetPaymentRequestForUpdateTx", mock.Anything, mock.Anything, payer, request.ID.String()).Return(request, nil)
+	repo.On("UpdatePaymentRequestTx", mock.Anything, mock.Anything, request).Return(nil)
+
+	wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	service := NewPaymentRequestService(repo, wallets, mockDB, PaymentRequestConfig{})
+	declined, err := service.Decline(context.Background(), payer, request.ID.String())
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentRequestStatusDeclined, declined.Status)
+	assert.Nil(t, declined.TransferID)
+	mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestPaymentRequestConfigFromEnv(t *testing.T) {
+	t.Setenv("PAYMENT_REQUEST_TTL", "72h")
+	t.Setenv("PAYMENT_REQUEST_EXPIRY_INTERVAL", "30s")
+
+	config, err := PaymentRequestConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentRequestConfig{TTL: 72 * time.Hour, ExpiryInterval: 30 * time.Second}, config)
+
+	t.Setenv("PAYMENT_REQUEST_TTL", "-1h")
+	_, err = PaymentRequestConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"walletapp/internal/money"
+)
+
+// ErrRateUnavailable is returned when no exchange rate is known for a currency pair
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// RateProvider quotes exchange rates: how many units of currency to one unit of from
+// buys. It returns ErrRateUnavailable for pairs it does not quote.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (money.Rate, error)
+}
+
+// StaticRateProvider quotes a fixed set of rates held in memory. Each direction of a
+// pair is quoted separately, so the spread between them is kept.
+type StaticRateProvider struct {
+	rates map[string]money.Rate
+}
+
+// NewStaticRateProvider creates a provider quoting rates keyed by currency pair, such
+// as "USD/EUR" for the euros one dollar buys
+func NewStaticRateProvider(rates map[string]money.Rate) *StaticRateProvider {
+	p := &StaticRateProvider{rates: make(map[string]money.Rate, len(rates))}
+	for pair, rate := range rates {
+		p.rates[strings.ToUpper(pair)] = rate
+	}
+	return p
+}
+
+// ParseStaticRates reads rates written as comma-separated pairs, such as
+// "USD/EUR=0.92,EUR/USD=1.08"
+func ParseStaticRates(raw string) (*StaticRateProvider, error) {
+	rates := make(map[string]money.Rate)
+	for _, quote := range strings.Split(raw, ",") {
+		pair, value, ok := strings.Cut(strings.TrimSpace(quote), "=")
+		from, to, isPair := strings.Cut(pair, "/")
+		if !ok || !isPair {
+			return nil, fmt.Errorf("rate %q must look like USD/EUR=0.92", quote)
+		}
+		for _, code := range []string{from, to} {
+			if _, err := money.ParseCurrency(code); err != nil {
+				return nil, fmt.Errorf("rate %q: %w: %q", quote, err, code)
+			}
+		}
+		rate, err := money.ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate %q: %w", quote, err)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(from)+"/"+strings.TrimSpace(to))] = rate
+	}
+	return NewStaticRateProvider(rates), nil
+}
+
+// Rate implements RateProvider
+func (p *StaticRateProvider) Rate(ctx context.Context, from, to string) (money.Rate, error) {
+	rate, ok := p.rates[from+"/"+to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s to %s", ErrRateUnavailable, from, to)
+	}
+	return rate, nil
+}
@@ -2,24 +2,30 @@ package services
 
 import (
 	"context"
+	"time"
 	"walletapp/internal/db"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
 	"walletapp/internal/repositories"
 
 	"github.com/jackc/pgx/v5"
 )
 
 // WalletRepoImpl implements WalletRepo interface
-type WalletRepoImpl struct{}
+type WalletRepoImpl struct {
+	wallets repositories.WalletRepository
+	users   repositories.UserRepository
+}
 
-// NewWalletRepoImpl creates a new WalletRepoImpl
-func NewWalletRepoImpl() *WalletRepoImpl {
-	return &WalletRepoImpl{}
+// NewWalletRepoImpl creates a new WalletRepoImpl reading outside transactions through
+// wallets and users
+func NewWalletRepoImpl(wallets repositories.WalletRepository, users repositories.UserRepository) *WalletRepoImpl {
+	return &WalletRepoImpl{wallets: wallets, users: users}
 }
 
 // GetWalletByUserID retrieves a wallet by user ID
 func (r *WalletRepoImpl) GetWalletByUserID(ctx context.Context, userID string) (*models.Wallet, error) {
-	return repositories.GetWalletByUserID(ctx, userID)
+	return r.wallets.GetWalletByUserID(ctx, userID)
 }
 
 // GetWalletByUserIDTx retrieves a wallet by user ID within a transaction
@@ -27,17 +33,213 @@ func (r *WalletRepoImpl) GetWalletByUserIDTx(ctx context.Context, tx pgx.Tx, use
 	return repositories.GetWalletByUserIDTx(ctx, tx, userID)
 }
 
-// UpdateWalletBalanceTx updates a wallet balance within a transaction
-func (r *WalletRepoImpl) UpdateWalletBalanceTx(ctx context.Context, tx pgx.Tx, userID string, newBalance float64) error {
-	return repositories.UpdateWalletBalanceTx(ctx, tx, userID, newBalance)
+// GetWalletForUpdateTx retrieves and row-locks a wallet by user ID within a transaction
+func (r *WalletRepoImpl) GetWalletForUpdateTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
+	return repositories.GetWalletForUpdateTx(ctx, tx, userID)
+}
+
+// GetWalletByID retrieves a wallet by its own ID
+func (r *WalletRepoImpl) GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error) {
+	return r.wallets.GetWalletByID(ctx, walletID)
+}
+
+// GetUserByID retrieves a user by ID
+func (r *WalletRepoImpl) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	return r.users.GetUserByID(ctx, userID)
+}
+
+// GetWalletByIDTx retrieves a wallet by its own ID within a transaction
+func (r *WalletRepoImpl) GetWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string) (*models.Wallet, error) {
+	return repositories.GetWalletByIDTx(ctx, tx, walletID)
+}
+
+// GetWalletsByUserID lists a user's wallets, the default wallet first
+func (r *WalletRepoImpl) GetWalletsByUserID(ctx context.Context, userID string) ([]models.Wallet, error) {
+	return r.wallets.GetWalletsByUserID(ctx, userID)
+}
+
+// AcquireWalletLockTx takes a transaction-scoped advisory lock for a wallet
+func (r *WalletRepoImpl) AcquireWalletLockTx(ctx context.Context, tx pgx.Tx, walletKey string) error {
+	return repositories.AcquireWalletLockTx(ctx, tx, walletKey)
+}
+
+// UpdateWalletBalanceTx updates a wallet balance within a transaction if the wallet is still at expectedVersion
+func (r *WalletRepoImpl) UpdateWalletBalanceTx(ctx context.Context, tx pgx.Tx, userID string, newBalance money.Amount, expectedVersion int64) error {
+	return repositories.UpdateWalletBalanceTx(ctx, tx, userID, newBalance, expectedVersion)
+}
+
+// UpdateWalletBalanceByIDTx is UpdateWalletBalanceTx for a wallet identified by its own ID
+func (r *WalletRepoImpl) UpdateWalletBalanceByIDTx(ctx context.Context, tx pgx.Tx, walletID string, newBalance money.Amount, expectedVersion int64) error {
+	return repositories.UpdateWalletBalanceByIDTx(ctx, tx, walletID, newBalance, expectedVersion)
+}
+
+// DebitWalletTx subtracts amount from a wallet if it has sufficient funds
+func (r *WalletRepoImpl) DebitWalletTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.DebitWalletTx(ctx, tx, userID, amount)
+}
+
+// DebitWalletByIDTx is DebitWalletTx for a wallet identified by its own ID
+func (r *WalletRepoImpl) DebitWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.DebitWalletByIDTx(ctx, tx, walletID, amount)
+}
+
+// CreditWalletTx adds amount to a wallet
+func (r *WalletRepoImpl) CreditWalletTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.CreditWalletTx(ctx, tx, userID, amount)
+}
+
+// CreditWalletByIDTx is CreditWalletTx for a wallet identified by its own ID
+func (r *WalletRepoImpl) CreditWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.CreditWalletByIDTx(ctx, tx, walletID, amount)
+}
+
+// CreateWalletTx creates an empty wallet for a user within a transaction
+func (r *WalletRepoImpl) CreateWalletTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
+	return repositories.CreateWalletTx(ctx, tx, userID)
+}
+
+// CreateNamedWallet creates an additional, non-default wallet for a user
+func (r *WalletRepoImpl) CreateNamedWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error) {
+	return r.wallets.CreateNamedWallet(ctx, userID, name, currency)
+}
+
+// SetOverdraftLimit changes how far below zero a wallet may go
+func (r *WalletRepoImpl) SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error) {
+	return r.wallets.SetOverdraftLimit(ctx, userID, limit)
+}
+
+// HoldFundsTx moves part of a wallet's available balance into held funds
+func (r *WalletRepoImpl) HoldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.HoldFundsTx(ctx, tx, userID, amount)
+}
+
+// CaptureHeldFundsTx takes held funds off a wallet's balance
+func (r *WalletRepoImpl) CaptureHeldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.CaptureHeldFundsTx(ctx, tx, userID, amount)
+}
+
+// ReleaseHeldFundsTx returns held funds to a wallet's available balance
+func (r *WalletRepoImpl) ReleaseHeldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	return repositories.ReleaseHeldFundsTx(ctx, tx, userID, amount)
+}
+
+// CreateHoldTx records an active hold within a transaction
+func (r *WalletRepoImpl) CreateHoldTx(ctx context.Context, tx pgx.Tx, h *models.Hold) error {
+	return repositories.CreateHoldTx(ctx, tx, h)
+}
+
+// GetHoldTx retrieves a hold by ID within a transaction
+func (r *WalletRepoImpl) GetHoldTx(ctx context.Context, tx pgx.Tx, id string) (*models.Hold, error) {
+	return repositories.GetHoldTx(ctx, tx, id)
+}
+
+// FinalizeHoldTx captures or releases an active hold within a transaction
+func (r *WalletRepoImpl) FinalizeHoldTx(ctx context.Context, tx pgx.Tx, id string, status models.HoldStatus) (*models.Hold, error) {
+	return repositories.FinalizeHoldTx(ctx, tx, id, status)
+}
+
+// SetWalletStatusTx freezes or unfreezes a wallet within a transaction
+func (r *WalletRepoImpl) SetWalletStatusTx(ctx context.Context, tx pgx.Tx, userID string, status models.WalletStatus) (*models.Wallet, error) {
+	return repositories.SetWalletStatusTx(ctx, tx, userID, status)
+}
+
+// CreateWalletStatusChangeTx records the audit trail of a wallet status change within a transaction
+func (r *WalletRepoImpl) CreateWalletStatusChangeTx(ctx context.Context, tx pgx.Tx, change *models.WalletStatusChange) error {
+	return repositories.CreateWalletStatusChangeTx(ctx, tx, change)
+}
+
+// SetUserKycTier changes a user's KYC tier
+func (r *WalletRepoImpl) SetUserKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error) {
+	return r.users.SetUserKycTier(ctx, userID, tier)
+}
+
+// GetUserKycTierTx reads a user's KYC tier within a transaction
+func (r *WalletRepoImpl) GetUserKycTierTx(ctx context.Context, tx pgx.Tx, userID string) (models.KycTier, error) {
+	return repositories.GetUserKycTierTx(ctx, tx, userID)
+}
+
+// IsUserEmailVerifiedTx reports whether a user verified their email within a transaction
+func (r *WalletRepoImpl) IsUserEmailVerifiedTx(ctx context.Context, tx pgx.Tx, userID string) (bool, error) {
+	return repositories.IsUserEmailVerifiedTx(ctx, tx, userID)
+}
+
+// GetKycTierLimitsTx reads the limits of every KYC tier within a transaction
+func (r *WalletRepoImpl) GetKycTierLimitsTx(ctx context.Context, tx pgx.Tx) ([]models.KycTierLimits, error) {
+	return repositories.GetKycTierLimitsTx(ctx, tx)
+}
+
+// SetInterestRate changes the yearly interest one of a user's wallets earns
+func (r *WalletRepoImpl) SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error) {
+	return r.wallets.SetInterestRate(ctx, userID, walletID, rateBps)
+}
+
+// GetInterestBearingWallets lists the wallets the interest accrual job credits
+func (r *WalletRepoImpl) GetInterestBearingWallets(ctx context.Context) ([]models.Wallet, error) {
+	return r.wallets.GetInterestBearingWallets(ctx)
+}
+
+// CloseWalletTx marks a wallet closed within a transaction
+func (r *WalletRepoImpl) CloseWalletTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error) {
+	return repositories.CloseWalletTx(ctx, tx, userID)
+}
+
+// GetWalletsByUserIDForUpdateTx retrieves and row-locks all of a user's wallets within a transaction
+func (r *WalletRepoImpl) GetWalletsByUserIDForUpdateTx(ctx context.Context, tx pgx.Tx, userID string) ([]models.Wallet, error) {
+	return repositories.GetWalletsByUserIDForUpdateTx(ctx, tx, userID)
+}
+
+// CloseWalletsByUserIDTx marks all of a user's open wallets closed within a transaction
+func (r *WalletRepoImpl) CloseWalletsByUserIDTx(ctx context.Context, tx pgx.Tx, userID string) ([]models.Wallet, error) {
+	return repositories.CloseWalletsByUserIDTx(ctx, tx, userID)
+}
+
+// UserRepoImpl implements UserTxRepo interface
+type UserRepoImpl struct{}
+
+// NewUserRepoImpl creates a new UserRepoImpl
+func NewUserRepoImpl() *UserRepoImpl {
+	return &UserRepoImpl{}
+}
+
+// IsEmailExistsTx reports whether a live user has an email within a transaction
+func (r *UserRepoImpl) IsEmailExistsTx(ctx context.Context, tx pgx.Tx, email string) (bool, error) {
+	return repositories.IsEmailExistsTx(ctx, tx, email)
+}
+
+// IsUsernameExistsTx reports whether a live user has a username within a transaction
+func (r *UserRepoImpl) IsUsernameExistsTx(ctx context.Context, tx pgx.Tx, username string) (bool, error) {
+	return repositories.IsUsernameExistsTx(ctx, tx, username)
+}
+
+// CreateUserTx inserts a user within a transaction
+func (r *UserRepoImpl) CreateUserTx(ctx context.Context, tx pgx.Tx, req *models.CreateUserRequest) (*models.User, error) {
+	return repositories.CreateUserTx(ctx, tx, req)
+}
+
+// GetUserByReferralCodeTx retrieves the user with a referral code within a transaction
+func (r *UserRepoImpl) GetUserByReferralCodeTx(ctx context.Context, tx pgx.Tx, code string) (*models.User, error) {
+	return repositories.GetUserByReferralCodeTx(ctx, tx, code)
+}
+
+// SoftDeleteUserTx marks a user deleted within a transaction
+func (r *UserRepoImpl) SoftDeleteUserTx(ctx context.Context, tx pgx.Tx, id string) error {
+	return repositories.SoftDeleteUserTx(ctx, tx, id)
+}
+
+// CreateReferralTx records a referral within a transaction
+func (r *UserRepoImpl) CreateReferralTx(ctx context.Context, tx pgx.Tx, referral *models.Referral) error {
+	return repositories.CreateReferralTx(ctx, tx, referral)
 }
 
 // TransactionRepoImpl implements TransactionRepo interface
-type TransactionRepoImpl struct{}
+type TransactionRepoImpl struct {
+	transactions repositories.TransactionRepository
+}
 
-// NewTransactionRepoImpl creates a new TransactionRepoImpl
-func NewTransactionRepoImpl() *TransactionRepoImpl {
-	return &TransactionRepoImpl{}
+// NewTransactionRepoImpl creates a new TransactionRepoImpl reading outside transactions
+// through transactions
+func NewTransactionRepoImpl(transactions repositories.TransactionRepository) *TransactionRepoImpl {
+	return &TransactionRepoImpl{transactions: transactions}
 }
 
 // CreateTransactionTx creates a transaction record within a transaction
@@ -45,6 +247,305 @@ func (r *TransactionRepoImpl) CreateTransactionTx(ctx context.Context, tx pgx.Tx
 	return repositories.CreateTransactionTx(ctx, tx, t)
 }
 
+// CreateLedgerEntriesTx records a balanced group of ledger entries within a transaction
+func (r *TransactionRepoImpl) CreateLedgerEntriesTx(ctx context.Context, tx pgx.Tx, entries []models.LedgerEntry) error {
+	return repositories.CreateLedgerEntriesTx(ctx, tx, entries)
+}
+
+// GetLedgerReport compares a wallet's stored balance with its ledger balance
+func (r *TransactionRepoImpl) GetLedgerReport(ctx context.Context, userID string) (*models.LedgerReport, error) {
+	return repositories.GetLedgerReport(ctx, userID)
+}
+
+// GetBalanceDiscrepancies returns wallets whose balance disagrees with their transactions
+func (r *TransactionRepoImpl) GetBalanceDiscrepancies(ctx context.Context) ([]models.Discrepancy, error) {
+	return r.transactions.GetBalanceDiscrepancies(ctx)
+}
+
+// GetTransactionSummary totals a wallet's completed transactions in a period
+func (r *TransactionRepoImpl) GetTransactionSummary(ctx context.Context, walletID string, from, to time.Time) (*models.WalletSummary, error) {
+	return r.transactions.GetTransactionSummary(ctx, walletID, from, to)
+}
+
+// GetWalletTotals totals a wallet's completed transactions over its whole life
+func (r *TransactionRepoImpl) GetWalletTotals(ctx context.Context, walletID string) (*models.WalletTotals, error) {
+	return r.transactions.GetWalletTotals(ctx, walletID)
+}
+
+// GetStatementTransactions returns a page of a wallet's completed transactions in a period
+func (r *TransactionRepoImpl) GetStatementTransactions(ctx context.Context, walletID string, from, to time.Time, limit, offset int) ([]models.Transaction, int, error) {
+	return r.transactions.GetStatementTransactions(ctx, walletID, from, to, limit, offset)
+}
+
+// CreateTransactionPartition makes the monthly partition of transactions for a month
+func (r *TransactionRepoImpl) CreateTransactionPartition(ctx context.Context, month time.Time) (string, error) {
+	return r.transactions.CreateTransactionPartition(ctx, month)
+}
+
+// GetTransactionByID retrieves a transaction by ID
+func (r *TransactionRepoImpl) GetTransactionByID(ctx context.Context, id string) (*models.Transaction, error) {
+	return r.transactions.GetTransactionByID(ctx, id)
+}
+
+// GetBalanceAfterTransaction derives the balance of a transaction's wallet right after it
+func (r *TransactionRepoImpl) GetBalanceAfterTransaction(ctx context.Context, t *models.Transaction) (money.Amount, error) {
+	return r.transactions.GetBalanceAfterTransaction(ctx, t)
+}
+
+// GetTransactionByIdempotencyKeyTx returns the transaction recorded under key for a user's wallet
+func (r *TransactionRepoImpl) GetTransactionByIdempotencyKeyTx(ctx context.Context, tx pgx.Tx, userID, key string) (*models.Transaction, error) {
+	return repositories.GetTransactionByIdempotencyKeyTx(ctx, tx, userID, key)
+}
+
+// GetTransactionByWalletIdempotencyKeyTx finds the transaction recorded under an idempotency key for a wallet
+func (r *TransactionRepoImpl) GetTransactionByWalletIdempotencyKeyTx(ctx context.Context, tx pgx.Tx, walletID, key string) (*models.Transaction, error) {
+	return repositories.GetTransactionByWalletIdempotencyKeyTx(ctx, tx, walletID, key)
+}
+
+// CreateExternalTransactionTx records a transaction unless its external reference was already recorded
+func (r *TransactionRepoImpl) CreateExternalTransactionTx(ctx context.Context, tx pgx.Tx, t *models.Transaction) (bool, error) {
+	return repositories.CreateExternalTransactionTx(ctx, tx, t)
+}
+
+// GetTransactionByExternalReferenceTx returns the transaction recorded for a payment provider reference
+func (r *TransactionRepoImpl) GetTransactionByExternalReferenceTx(ctx context.Context, tx pgx.Tx, reference string) (*models.Transaction, error) {
+	return repositories.GetTransactionByExternalReferenceTx(ctx, tx, reference)
+}
+
+// GetTransactionsByTransferIDTx returns every transaction recorded for a transfer
+func (r *TransactionRepoImpl) GetTransactionsByTransferIDTx(ctx context.Context, tx pgx.Tx, transferID string) ([]models.Transaction, error) {
+	return repositories.GetTransactionsByTransferIDTx(ctx, tx, transferID)
+}
+
+// CreateTransferReversalTx records that a transfer was reversed within a transaction
+func (r *TransactionRepoImpl) CreateTransferReversalTx(ctx context.Context, tx pgx.Tx, reversal *models.TransferReversal) error {
+	return repositories.CreateTransferReversalTx(ctx, tx, reversal)
+}
+
+// SumTransactionsSince adds up a wallet's transactions of the given types since a time
+func (r *TransactionRepoImpl) SumTransactionsSince(ctx context.Context, tx pgx.Tx, walletID string, types []models.TransactionType, since time.Time) (money.Amount, error) {
+	return repositories.SumTransactionsSince(ctx, tx, walletID, types, since)
+}
+
+// CountTransactionsSince counts a wallet's transactions of the given types since a time
+func (r *TransactionRepoImpl) CountTransactionsSince(ctx context.Context, tx pgx.Tx, walletID string, types []models.TransactionType, since time.Time) (int, *time.Time, error) {
+	return repositories.CountTransactionsSince(ctx, tx, walletID, types, since)
+}
+
+// CreateTransferIntent records a pending transfer intent
+func (r *TransactionRepoImpl) CreateTransferIntent(ctx context.Context, intent *models.TransferIntent) error {
+	return repositories.CreateTransferIntent(ctx, intent)
+}
+
+// GetTransferIntentForUpdateTx row-locks and returns a transfer intent
+func (r *TransactionRepoImpl) GetTransferIntentForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.TransferIntent, error) {
+	return repositories.GetTransferIntentForUpdateTx(ctx, tx, id)
+}
+
+// UpdateTransferIntentTx saves the outcome of a transfer intent within a transaction
+func (r *TransactionRepoImpl) UpdateTransferIntentTx(ctx context.Context, tx pgx.Tx, intent *models.TransferIntent) error {
+	return repositories.UpdateTransferIntentTx(ctx, tx, intent)
+}
+
+// ExpireTransferIntents expires pending transfer intents past their expiry
+func (r *TransactionRepoImpl) ExpireTransferIntents(ctx context.Context) (int64, error) {
+	return repositories.ExpireTransferIntents(ctx)
+}
+
+// SumUserTransactionsSince adds up a user's transactions of the given types since a time
+func (r *TransactionRepoImpl) SumUserTransactionsSince(ctx context.Context, tx pgx.Tx, userID string, types []models.TransactionType, since time.Time) (money.Amount, error) {
+	return repositories.SumUserTransactionsSince(ctx, tx, userID, types, since)
+}
+
+// CountNewRecipientsSince counts the users a wallet first transferred to since a time
+func (r *TransactionRepoImpl) CountNewRecipientsSince(ctx context.Context, tx pgx.Tx, walletID string, since time.Time) (int, error) {
+	return repositories.CountNewRecipientsSince(ctx, tx, walletID, since)
+}
+
+// CreateRiskFlagTx records a risk flag within a transaction
+func (r *TransactionRepoImpl) CreateRiskFlagTx(ctx context.Context, tx pgx.Tx, flag *models.RiskFlag) error {
+	return repositories.CreateRiskFlagTx(ctx, tx, flag)
+}
+
+// GetRiskFlags lists the risk flags matching a filter
+func (r *TransactionRepoImpl) GetRiskFlags(ctx context.Context, filter models.RiskFlagFilter) ([]models.RiskFlag, error) {
+	return repositories.GetRiskFlags(ctx, filter)
+}
+
+// HasInterestAccrualTx reports whether a wallet already accrued interest for a date
+func (r *TransactionRepoImpl) HasInterestAccrualTx(ctx context.Context, tx pgx.Tx, walletID string, date time.Time) (bool, error) {
+	return repositories.HasInterestAccrualTx(ctx, tx, walletID, date)
+}
+
+// CreateInterestAccrualTx records a day of interest credited to a wallet within a transaction
+func (r *TransactionRepoImpl) CreateInterestAccrualTx(ctx context.Context, tx pgx.Tx, accrual *models.InterestAccrual) error {
+	return repositories.CreateInterestAccrualTx(ctx, tx, accrual)
+}
+
+// ArchiveRepoImpl implements ArchiveRepo interface
+type ArchiveRepoImpl struct {
+	runs repositories.ArchiveRepository
+}
+
+// NewArchiveRepoImpl creates a new ArchiveRepoImpl recording archive runs through runs
+func NewArchiveRepoImpl(runs repositories.ArchiveRepository) *ArchiveRepoImpl {
+	return &ArchiveRepoImpl{runs: runs}
+}
+
+// CreateArchiveRun records the start of an archive run
+func (r *ArchiveRepoImpl) CreateArchiveRun(ctx context.Context, cutoff time.Time) (*models.ArchiveRun, error) {
+	return r.runs.CreateArchiveRun(ctx, cutoff)
+}
+
+// GetLatestArchiveRun retrieves the archive run started last
+func (r *ArchiveRepoImpl) GetLatestArchiveRun(ctx context.Context) (*models.ArchiveRun, error) {
+	return r.runs.GetLatestArchiveRun(ctx)
+}
+
+// FinishArchiveRun marks an archive run completed, or failed with runErr
+func (r *ArchiveRepoImpl) FinishArchiveRun(ctx context.Context, id string, runErr error) (*models.ArchiveRun, error) {
+	return r.runs.FinishArchiveRun(ctx, id, runErr)
+}
+
+// CountArchivableTransactions counts the transactions before cutoff still to be archived
+func (r *ArchiveRepoImpl) CountArchivableTransactions(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.runs.CountArchivableTransactions(ctx, cutoff)
+}
+
+// ArchiveTransactionsTx moves a batch of transactions before cutoff into the archive within a transaction
+func (r *ArchiveRepoImpl) ArchiveTransactionsTx(ctx context.Context, tx pgx.Tx, runID string, cutoff time.Time, limit int) (int64, error) {
+	return repositories.ArchiveTransactionsTx(ctx, tx, runID, cutoff, limit)
+}
+
+// ScheduledTransferRepoImpl implements ScheduledTransferRepo interface
+type ScheduledTransferRepoImpl struct{}
+
+// NewScheduledTransferRepoImpl creates a new ScheduledTransferRepoImpl
+func NewScheduledTransferRepoImpl() *ScheduledTransferRepoImpl {
+	return &ScheduledTransferRepoImpl{}
+}
+
+// CreateScheduledTransfer records a pending scheduled transfer
+func (r *ScheduledTransferRepoImpl) CreateScheduledTransfer(ctx context.Context, st *models.ScheduledTransfer) error {
+	return repositories.CreateScheduledTransfer(ctx, st)
+}
+
+// GetScheduledTransfersByUserID returns the transfers a user has scheduled
+func (r *ScheduledTransferRepoImpl) GetScheduledTransfersByUserID(ctx context.Context, userID string) ([]models.ScheduledTransfer, error) {
+	return repositories.GetScheduledTransfersByUserID(ctx, userID)
+}
+
+// ClaimDueScheduledTransfersTx locks a batch of due transfers not claimed by another worker
+func (r *ScheduledTransferRepoImpl) ClaimDueScheduledTransfersTx(ctx context.Context, tx pgx.Tx, limit int) ([]models.ScheduledTransfer, error) {
+	return repositories.ClaimDueScheduledTransfersTx(ctx, tx, limit)
+}
+
+// UpdateScheduledTransferTx saves the outcome of an execution attempt
+func (r *ScheduledTransferRepoImpl) UpdateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	return repositories.UpdateScheduledTransferTx(ctx, tx, st)
+}
+
+// CreateScheduledTransferTx records a pending scheduled transfer within a transaction
+func (r *ScheduledTransferRepoImpl) CreateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	return repositories.CreateScheduledTransferTx(ctx, tx, st)
+}
+
+// CancelPendingOccurrencesTx cancels a standing order's pending occurrences
+func (r *ScheduledTransferRepoImpl) CancelPendingOccurrencesTx(ctx context.Context, tx pgx.Tx, standingOrderID string) error {
+	return repositories.CancelPendingOccurrencesTx(ctx, tx, standingOrderID)
+}
+
+// CreateStandingOrderTx records a standing order within a transaction
+func (r *ScheduledTransferRepoImpl) CreateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error {
+	return repositories.CreateStandingOrderTx(ctx, tx, o)
+}
+
+// GetStandingOrder retrieves one of a user's standing orders
+func (r *ScheduledTransferRepoImpl) GetStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error) {
+	return repositories.GetStandingOrder(ctx, userID, id)
+}
+
+// GetStandingOrderForUpdateTx retrieves and locks a standing order
+func (r *ScheduledTransferRepoImpl) GetStandingOrderForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.StandingOrder, error) {
+	return repositories.GetStandingOrderForUpdateTx(ctx, tx, id)
+}
+
+// GetStandingOrdersByUserID retrieves a user's standing orders
+func (r *ScheduledTransferRepoImpl) GetStandingOrdersByUserID(ctx context.Context, userID string) ([]models.StandingOrder, error) {
+	return repositories.GetStandingOrdersByUserID(ctx, userID)
+}
+
+// UpdateStandingOrderTx saves a standing order's progress
+func (r *ScheduledTransferRepoImpl) UpdateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error {
+	return repositories.UpdateStandingOrderTx(ctx, tx, o)
+}
+
+// CancelStandingOrderTx marks one of a user's active standing orders cancelled
+func (r *ScheduledTransferRepoImpl) CancelStandingOrderTx(ctx context.Context, tx pgx.Tx, userID, id string) (*models.StandingOrder, error) {
+	return repositories.CancelStandingOrderTx(ctx, tx, userID, id)
+}
+
+// PaymentRequestRepoImpl implements PaymentRequestRepo interface
+type PaymentRequestRepoImpl struct{}
+
+// NewPaymentRequestRepoImpl creates a new PaymentRequestRepoImpl
+func NewPaymentRequestRepoImpl() *PaymentRequestRepoImpl {
+	return &PaymentRequestRepoImpl{}
+}
+
+// CreatePaymentRequest records a pending payment request
+func (r *PaymentRequestRepoImpl) CreatePaymentRequest(ctx context.Context, req *models.PaymentRequest) error {
+	return repositories.CreatePaymentRequest(ctx, req)
+}
+
+// GetPendingPaymentRequestsByPayerID retrieves the requests a user has yet to answer
+func (r *PaymentRequestRepoImpl) GetPendingPaymentRequestsByPayerID(ctx context.Context, payerID string) ([]models.PaymentRequest, error) {
+	return repositories.GetPendingPaymentRequestsByPayerID(ctx, payerID)
+}
+
+// GetPaymentRequestForUpdateTx retrieves and locks a payment request addressed to payerID
+func (r *PaymentRequestRepoImpl) GetPaymentRequestForUpdateTx(ctx context.Context, tx pgx.Tx, payerID, id string) (*models.PaymentRequest, error) {
+	return repositories.GetPaymentRequestForUpdateTx(ctx, tx, payerID, id)
+}
+
+// UpdatePaymentRequestTx saves the answer to a payment request
+func (r *PaymentRequestRepoImpl) UpdatePaymentRequestTx(ctx context.Context, tx pgx.Tx, req *models.PaymentRequest) error {
+	return repositories.UpdatePaymentRequestTx(ctx, tx, req)
+}
+
+// ExpirePaymentRequests expires pending requests past their expiry
+func (r *PaymentRequestRepoImpl) ExpirePaymentRequests(ctx context.Context) (int64, error) {
+	return repositories.ExpirePaymentRequests(ctx)
+}
+
+// BeneficiaryRepoImpl implements BeneficiaryRepo interface
+type BeneficiaryRepoImpl struct{}
+
+// NewBeneficiaryRepoImpl creates a new BeneficiaryRepoImpl
+func NewBeneficiaryRepoImpl() *BeneficiaryRepoImpl {
+	return &BeneficiaryRepoImpl{}
+}
+
+// CreateBeneficiary saves a beneficiary
+func (r *BeneficiaryRepoImpl) CreateBeneficiary(ctx context.Context, b *models.Beneficiary) error {
+	return repositories.CreateBeneficiary(ctx, b)
+}
+
+// GetBeneficiariesByOwnerID retrieves a user's beneficiaries
+func (r *BeneficiaryRepoImpl) GetBeneficiariesByOwnerID(ctx context.Context, ownerID string) ([]models.Beneficiary, error) {
+	return repositories.GetBeneficiariesByOwnerID(ctx, ownerID)
+}
+
+// GetBeneficiary retrieves one of a user's beneficiaries
+func (r *BeneficiaryRepoImpl) GetBeneficiary(ctx context.Context, ownerID, id string) (*models.Beneficiary, error) {
+	return repositories.GetBeneficiary(ctx, ownerID, id)
+}
+
+// DeleteBeneficiary removes one of a user's beneficiaries
+func (r *BeneficiaryRepoImpl) DeleteBeneficiary(ctx context.Context, ownerID, id string) error {
+	return repositories.DeleteBeneficiary(ctx, ownerID, id)
+}
+
 // DBImpl implements DB interface
 type DBImpl struct{}
 
@@ -53,7 +554,87 @@ func NewDBImpl() *DBImpl {
 	return &DBImpl{}
 }
 
-// Begin starts a new transaction
+// Begin starts a new transaction on the pool, bounding each of its statements by the
+// query timeout
 func (d *DBImpl) Begin(ctx context.Context) (pgx.Tx, error) {
-	return db.GetPool().Begin(ctx)
+	tx, err := db.GetPool().Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.BoundTx(tx), nil
+}
+
+// PasswordResetRepoImpl implements PasswordResetRepo interface
+type PasswordResetRepoImpl struct {
+	users  repositories.UserRepository
+	tokens repositories.PasswordResetRepository
+}
+
+// NewPasswordResetRepoImpl creates a new PasswordResetRepoImpl finding users through
+// users and storing their tokens in tokens
+func NewPasswordResetRepoImpl(users repositories.UserRepository, tokens repositories.PasswordResetRepository) *PasswordResetRepoImpl {
+	return &PasswordResetRepoImpl{users: users, tokens: tokens}
+}
+
+// GetUserByEmail retrieves the live user with an email, ignoring case
+func (r *PasswordResetRepoImpl) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.users.GetUserByEmail(ctx, email)
+}
+
+// CreatePasswordResetToken records a password reset token
+func (r *PasswordResetRepoImpl) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.tokens.CreatePasswordResetToken(ctx, token)
+}
+
+// GetPasswordResetTokenForUpdateTx retrieves and locks a password reset token by its hash
+func (r *PasswordResetRepoImpl) GetPasswordResetTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.PasswordResetToken, error) {
+	return repositories.GetPasswordResetTokenForUpdateTx(ctx, tx, tokenHash)
+}
+
+// UsePasswordResetTokensTx marks a user's unused password reset tokens used
+func (r *PasswordResetRepoImpl) UsePasswordResetTokensTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	return repositories.UsePasswordResetTokensTx(ctx, tx, userID)
+}
+
+// UpdateUserPasswordTx replaces a user's password hash within a transaction
+func (r *PasswordResetRepoImpl) UpdateUserPasswordTx(ctx context.Context, tx pgx.Tx, id, passwordHash string) error {
+	return repositories.UpdateUserPasswordTx(ctx, tx, id, passwordHash)
+}
+
+// EmailVerificationRepoImpl implements EmailVerificationRepo interface
+type EmailVerificationRepoImpl struct{}
+
+// NewEmailVerificationRepoImpl creates a new EmailVerificationRepoImpl
+func NewEmailVerificationRepoImpl() *EmailVerificationRepoImpl {
+	return &EmailVerificationRepoImpl{}
+}
+
+// CreateEmailVerificationTokenTx records an email verification token within a transaction
+func (r *EmailVerificationRepoImpl) CreateEmailVerificationTokenTx(ctx context.Context, tx pgx.Tx, token *models.EmailVerificationToken) error {
+	return repositories.CreateEmailVerificationTokenTx(ctx, tx, token)
+}
+
+// GetEmailVerificationTokenForUpdateTx retrieves and locks an email verification token by its hash
+func (r *EmailVerificationRepoImpl) GetEmailVerificationTokenForUpdateTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*models.EmailVerificationToken, error) {
+	return repositories.GetEmailVerificationTokenForUpdateTx(ctx, tx, tokenHash)
+}
+
+// UseEmailVerificationTokensTx marks a user's unused email verification tokens used
+func (r *EmailVerificationRepoImpl) UseEmailVerificationTokensTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	return repositories.UseEmailVerificationTokensTx(ctx, tx, userID)
+}
+
+// CountEmailVerificationTokensSinceTx counts the email verification tokens a user was sent since a time
+func (r *EmailVerificationRepoImpl) CountEmailVerificationTokensSinceTx(ctx context.Context, tx pgx.Tx, userID string, since time.Time) (int, *time.Time, error) {
+	return repositories.CountEmailVerificationTokensSinceTx(ctx, tx, userID, since)
+}
+
+// GetUserForUpdateTx retrieves and row-locks a live user within a transaction
+func (r *EmailVerificationRepoImpl) GetUserForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error) {
+	return repositories.GetUserForUpdateTx(ctx, tx, id)
+}
+
+// MarkEmailVerifiedTx records that a user verified their email within a transaction
+func (r *EmailVerificationRepoImpl) MarkEmailVerifiedTx(ctx context.Context, tx pgx.Tx, id string) (*models.User, error) {
+	return repositories.MarkEmailVerifiedTx(ctx, tx, id)
 }
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Transfer reversal failures, matched with errors.Is
+var (
+	ErrTransferNotFound        = errors.New("transfer not found")
+	ErrTransferAlreadyReversed = repositories.ErrTransferAlreadyReversed
+	// ErrReversalFundsSpent is returned when a wallet credited by a transfer no longer
+	// has the funds to give back
+	ErrReversalFundsSpent = errors.New("funds to reverse were already spent")
+)
+
+// ReverseTransfer undoes a completed transfer in one database transaction: the recipient
+// returns the amount to the sender and a fee charged on the transfer is refunded from
+// the fee wallet. Each movement is recorded as a TRANSFER_REVERSAL_IN or
+// TRANSFER_REVERSAL_OUT transaction carrying the original transfer ID and reason. When
+// the recipient has spent the money, nothing is reversed and ErrReversalFundsSpent is
+// returned. A transfer is reversed at most once; reversing it again fails with
+// ErrTransferAlreadyReversed, even when two requests race.
+func (s *WalletService) ReverseTransfer(ctx context.Context, transferID, reason string) (*models.TransferReversal, error) {
+	log := logger.WithFields(logrus.Fields{
+		"operation":   "reverse_transfer",
+		"transfer_id": transferID,
+	}).WithContext(ctx)
+	log.Info("Starting transfer reversal")
+
+	id, err := uuid.Parse(transferID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+	}
+	memo, err := normalizeDescription(reason)
+	if err != nil || memo == nil {
+		log.Warn("Transfer reversal without a valid reason rejected")
+		return nil, fmt.Errorf("%w: a reason of at most %d characters is required", ErrInvalidReason, maxDescriptionLength)
+	}
+
+	var reversal *models.TransferReversal
+	var users []string
+	err = s.retryTx(ctx, log, func() (err error) {
+		users = nil
+		reversal, err = s.reverseTransfer(ctx, log, id, *memo, &users)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, users...)
+	return reversal, nil
+}
+
+// reverseTransfer runs a single attempt of ReverseTransfer inside its own database
+// transaction, collecting the owners of the wallets it changes in users
+func (s *WalletService) reverseTransfer(ctx context.Context, log *logrus.Entry, transferID uuid.UUID, reason string, users *[]string) (reversal *models.TransferReversal, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "reverse transfer", err); err != nil {
+			reversal = nil
+		}
+	}()
+
+	legs, err := s.transactionRepo.GetTransactionsByTransferIDTx(ctx, tx, transferID.String())
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get transfer transactions")
+		return nil, err
+	}
+	original, err := recordedTransferLegs(transferID, legs)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Transfer cannot be reversed")
+		return nil, err
+	}
+
+	// How much each wallet has to give back (positive) or gets back (negative). The
+	// money goes back to the exact wallets the transfer moved it between.
+	from := walletRef{userID: original.fromUserID, walletID: original.fromWalletID.String()}
+	owed := map[walletRef]money.Amount{
+		{userID: original.toUserID, walletID: original.toWalletID.String()}: original.amount,
+		from: -(original.amount + original.fee),
+	}
+	if original.fee > 0 {
+		owed[walletRef{userID: original.feeUserID, walletID: original.feeWalletID.String()}] += original.fee
+	}
+	wallets := make([]walletRef, 0, len(owed))
+	for ref := range owed {
+		wallets = append(wallets, ref)
+	}
+	*users = walletOwners(wallets)
+	if err = s.lockWallets(ctx, tx, *users...); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallets")
+		return nil, err
+	}
+
+	// Recording the reversal first makes a concurrent reversal of the same transfer
+	// wait here, then fail, before either has moved any money
+	reversal = &models.TransferReversal{
+		TransferID: transferID,
+		Amount:     original.amount,
+		Fee:        original.fee,
+		Reason:     reason,
+	}
+	err = s.transactionRepo.CreateTransferReversalTx(ctx, tx, reversal)
+	if errors.Is(err, ErrTransferAlreadyReversed) {
+		log.Warn("Transfer was already reversed")
+		return nil, err
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record transfer reversal")
+		return nil, err
+	}
+
+	groupID := uuid.New()
+	var entries []models.LedgerEntry
+	for _, ref := range walletOrder(wallets...) {
+		amount := owed[ref]
+		counterparty := original.fromUserID
+		if ref == from {
+			counterparty = original.toUserID
+		}
+
+		var wallet *models.Wallet
+		leg := &models.Transaction{
+			Status:        models.TransactionStatusCompleted,
+			RelatedUserID: &counterparty,
+			TransferID:    &transferID,
+			Description:   &reason,
+		}
+		if amount > 0 {
+			wallet, err = s.debit(ctx, tx, ref, amount)
+			if errors.Is(err, ErrInsufficientBalance) {
+				log.WithField("wallet_id", ref.walletID).Warn("Funds to reverse were already spent")
+				return nil, fmt.Errorf("%w: %s cannot return %s", ErrReversalFundsSpent, ref, amount)
+			}
+			leg.Type = models.TransactionTypeTransferReversalOut
+			leg.Amount = amount
+		} else {
+			wallet, err = s.credit(ctx, tx, ref, -amount)
+			leg.Type = models.TransactionTypeTransferReversalIn
+			leg.Amount = -amount
+		}
+		if err != nil {
+			log.WithFields(logrus.Fields{"wallet_id": ref.walletID, "error": err.Error()}).Error("Failed to update balance")
+			return nil, err
+		}
+
+		leg.WalletID = wallet.ID
+		if err = s.transactionRepo.CreateTransactionTx(ctx, tx, leg); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to record reversal transaction")
+			return nil, err
+		}
+		direction := models.LedgerCredit
+		if leg.Type == models.TransactionTypeTransferReversalOut {
+			direction = models.LedgerDebit
+		}
+		entries = append(entries, walletLedgerEntry(groupID, wallet.ID, direction, leg.Amount))
+	}
+	if err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, entries); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record reversal ledger entries")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"amount": original.amount,
+		"fee":    original.fee,
+	}).Info("Transfer reversed successfully")
+	return reversal, nil
+}
+
+// transferLegs summarizes a recorded transfer
+type transferLegs struct {
+	fromUserID   string
+	toUserID     string
+	feeUserID    string
+	fromWalletID uuid.UUID
+	toWalletID   uuid.UUID
+	feeWalletID  uuid.UUID
+	amount       money.Amount
+	fee          money.Amount
+}
+
+// recordedTransferLegs works out who sent what to whom, and between which wallets, from
+// the transactions recorded for transferID. Returns ErrTransferNotFound when they are
+// not the legs of a completed transfer, and ErrTransferAlreadyReversed when they include
+// reversal legs.
+func recordedTransferLegs(transferID uuid.UUID, legs []models.Transaction) (*transferLegs, error) {
+	var t transferLegs
+	var sent bool
+	var credited []models.Transaction
+	for _, leg := range legs {
+		if leg.Status != models.TransactionStatusCompleted || leg.RelatedUserID == nil {
+			continue
+		}
+		switch leg.Type {
+		case models.TransactionTypeTransferOut:
+			if sent {
+				// Batch transfers share one ID across all their payments
+				return nil, fmt.Errorf("%w: %s is a batch transfer", ErrTransferNotFound, transferID)
+			}
+			sent = true
+			t.toUserID = *leg.RelatedUserID
+			t.fromWalletID = leg.WalletID
+			t.amount = leg.Amount
+		case models.TransactionTypeTransferIn:
+			// Both the recipient and the fee wallet are credited from the sender
+			t.fromUserID = *leg.RelatedUserID
+			credited = append(credited, leg)
+		case models.TransactionTypeFee:
+			t.feeUserID = *leg.RelatedUserID
+			t.fee += leg.Amount
+		case models.TransactionTypeTransferReversalIn, models.TransactionTypeTransferReversalOut:
+			return nil, fmt.Errorf("%w: %s", ErrTransferAlreadyReversed, transferID)
+		}
+	}
+	if !sent || t.fromUserID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+	}
+	// The recipient is credited the amount, the fee wallet the fee
+	for _, leg := range credited {
+		if leg.Amount == t.amount && t.toWalletID == uuid.Nil {
+			t.toWalletID = leg.WalletID
+		} else {
+			t.feeWalletID = leg.WalletID
+		}
+	}
+	return &t, nil
+}
+
+func ReverseTransfer(ctx context.Context, transferID, reason string) (*models.TransferReversal, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.ReverseTransfer(ctx, transferID, reason)
+}
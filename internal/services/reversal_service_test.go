@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Wallets the legs returned by transferLegsFixture are recorded against
+var (
+	senderWalletID    = uuid.New()
+	recipientWalletID = uuid.New()
+	feeWalletID       = uuid.New()
+)
+
+// transferLegsFixture returns the legs Transfer records for a transfer of amount from
+// user1 to user2 with fee credited to the fee user
+func transferLegsFixture(transferID uuid.UUID, amount, fee money.Amount, feeUserID string) []models.Transaction {
+	from, to := "user1", "user2"
+	legs := []models.Transaction{
+		{WalletID: senderWalletID, Type: models.TransactionTypeTransferOut, Status: models.TransactionStatusCompleted, Amount: amount, RelatedUserID: &to, TransferID: &transferID},
+		{WalletID: recipientWalletID, Type: models.TransactionTypeTransferIn, Status: models.TransactionStatusCompleted, Amount: amount, RelatedUserID: &from, TransferID: &transferID},
+	}
+	if fee > 0 {
+		legs = append(legs,
+			models.Transaction{WalletID: senderWalletID, Type: models.TransactionTypeFee, Status: models.TransactionStatusCompleted, Amount: fee, RelatedUserID: &feeUserID, TransferID: &transferID},
+			models.Transaction{WalletID: feeWalletID, Type: models.TransactionTypeTransferIn, Status: models.TransactionStatusCompleted, Amount: fee, RelatedUserID: &from, TransferID: &transferID},
+		)
+	}
+	return legs
+}
+
+func TestWalletService_ReverseTransfer(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	transferID := uuid.New()
+	feeUserID := uuid.New().String()
+	senderWallet, recipientWallet, feeWallet := senderWalletID, recipientWalletID, feeWalletID
+	var recorded []*models.Transaction
+	var entries []models.LedgerEntry
+	mockTxRepo.ExpectedCalls = nil
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockTxRepo.On("GetTransactionsByTransferIDTx", mock.Anything, mock.Anything, transferID.String()).
+		Return(transferLegsFixture(transferID, 2500, 50, feeUserID), nil)
+	mockTxRepo.On("CreateTransferReversalTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.TransferReversal")).Return(nil).Once()
+	mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, senderWallet.String(), money.Amount(2550)).Return(&models.Wallet{ID: senderWallet}, nil)
+	mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, recipientWallet.String(), money.Amount(2500)).Return(&models.Wallet{ID: recipientWallet}, nil)
+	mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, feeWallet.String(), money.Amount(50)).Return(&models.Wallet{ID: feeWallet}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { recorded = append(recorded, args.Get(2).(*models.Transaction)) }).
+		Return(nil)
+	mockTxRepo.On("CreateLedgerEntriesTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { entries = args.Get(2).([]models.LedgerEntry) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	reversal, err := service.ReverseTransfer(context.Background(), transferID.String(), " sent to the wrong account ")
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, reversal) {
+		assert.Equal(t, transferID, reversal.TransferID)
+		assert.Equal(t, money.Amount(2500), reversal.Amount)
+		assert.Equal(t, money.Amount(50), reversal.Fee)
+		assert.Equal(t, "sent to the wrong account", reversal.Reason)
+	}
+	byWallet := make(map[uuid.UUID]*models.Transaction)
+	for _, leg := range recorded {
+		byWallet[leg.WalletID] = leg
+		assert.Equal(t, &transferID, leg.TransferID, "reversal legs should carry the original transfer ID")
+		if assert.NotNil(t, leg.Description) {
+			assert.Equal(t, "sent to the wrong account", *leg.Description)
+		}
+	}
+	if assert.Len(t, byWallet, 3) {
+		assert.Equal(t, models.TransactionTypeTransferReversalIn, byWallet[senderWallet].Type)
+		assert.Equal(t, money.Amount(2550), byWallet[senderWallet].Amount)
+		assert.Equal(t, models.TransactionTypeTransferReversalOut, byWallet[recipientWallet].Type)
+		assert.Equal(t, models.TransactionTypeTransferReversalOut, byWallet[feeWallet].Type)
+	}
+	var credits, debits money.Amount
+	for _, entry := range entries {
+		if entry.Direction == models.LedgerCredit {
+			credits += entry.Amount
+		} else {
+			debits += entry.Amount
+		}
+	}
+	assert.Equal(t, credits, debits, "reversal ledger entries should balance")
+	mockWalletRepo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_ReverseTransfer_FundsSpent(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	transferID := uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockTxRepo.On("GetTransactionsByTransferIDTx", mock.Anything, mock.Anything, transferID.String()).
+		Return(transferLegsFixture(transferID, 2500, 0, ""), nil)
+	mockTxRepo.On("CreateTransferReversalTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, senderWalletID.String(), money.Amount(2500)).Return(&models.Wallet{ID: senderWalletID}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, recipientWalletID.String(), money.Amount(2500)).Return(nil, repositories.ErrInsufficientBalance)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	reversal, err := service.ReverseTransfer(context.Background(), transferID.String(), "duplicate payment")
+
+	assert.ErrorIs(t, err, ErrReversalFundsSpent)
+	assert.Nil(t, reversal)
+	assert.NoError(t, mockDB.ExpectationsWereMet(), "the whole reversal should be rolled back")
+}
+
+func TestWalletService_ReverseTransfer_AlreadyReversed(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	// A concurrent reversal committed after this one read the transfer's legs
+	transferID := uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockTxRepo.On("GetTransactionsByTransferIDTx", mock.Anything, mock.Anything, transferID.String()).
+		Return(transferLegsFixture(transferID, 2500, 0, ""), nil)
+	mockTxRepo.On("CreateTransferReversalTx", mock.Anything, mock.Anything, mock.Anything).
+		Return(fmt.Errorf("%w: %s", repositories.ErrTransferAlreadyReversed, transferID))
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	reversal, err := service.ReverseTransfer(context.Background(), transferID.String(), "duplicate payment")
+
+	assert.ErrorIs(t, err, ErrTransferAlreadyReversed)
+	assert.Nil(t, reversal)
+	mockWalletRepo.AssertNotCalled(t, "CreditWalletByIDTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockWalletRepo.AssertNotCalled(t, "DebitWalletByIDTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestRecordedTransferLegs(t *testing.T) {
+	transferID := uuid.New()
+	sender := "user1"
+	reversed := append(transferLegsFixture(transferID, 2500, 0, ""), models.Transaction{
+		Type: models.TransactionTypeTransferReversalIn, Status: models.TransactionStatusCompleted, Amount: 2500, RelatedUserID: &sender,
+	})
+	deposit := []models.Transaction{{Type: models.TransactionTypeDeposit, Status: models.TransactionStatusCompleted, Amount: 2500}}
+	batch := append(transferLegsFixture(transferID, 2500, 0, ""), transferLegsFixture(transferID, 1000, 0, "")...)
+
+	tests := []struct {
+		name        string
+		legs        []models.Transaction
+		expected    *transferLegs
+		expectedErr error
+	}{
+		{"transfer", transferLegsFixture(transferID, 2500, 0, ""), &transferLegs{
+			fromUserID: "user1", toUserID: "user2", fromWalletID: senderWalletID, toWalletID: recipientWalletID, amount: 2500,
+		}, nil},
+		{"transfer with fee", transferLegsFixture(transferID, 2500, 50, "fees"), &transferLegs{
+			fromUserID: "user1", toUserID: "user2", feeUserID: "fees",
+			fromWalletID: senderWalletID, toWalletID: recipientWalletID, feeWalletID: feeWalletID, amount: 2500, fee: 50,
+		}, nil},
+		{"no legs", nil, nil, ErrTransferNotFound},
+		{"not a transfer", deposit, nil, ErrTransferNotFound},
+		{"already reversed", reversed, nil, ErrTransferAlreadyReversed},
+		{"batch transfer", batch, nil, ErrTransferNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legs, err := recordedTransferLegs(transferID, tt.legs)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, legs)
+			}
+		})
+	}
+}
+
+func TestWalletService_ReverseTransfer_RequiresReason(t *testing.T) {
+	service := NewWalletService(nil, nil, nil, WalletServiceConfig{})
+
+	_, err := service.ReverseTransfer(context.Background(), uuid.New().String(), " \n")
+	assert.ErrorIs(t, err, ErrInvalidReason)
+
+	_, err = service.ReverseTransfer(context.Background(), "not-a-transfer", "duplicate payment")
+	assert.ErrorIs(t, err, ErrTransferNotFound)
+}
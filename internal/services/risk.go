@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the built-in risk rules, as recorded on their flags
+const (
+	RiskRuleLargeAmount   = "large_amount"
+	RiskRuleNewRecipients = "new_recipients"
+	RiskRuleBalanceDrain  = "balance_drain"
+)
+
+// defaultNewRecipientsWindow is the period NewRecipientsRule counts over when it sets none
+const defaultNewRecipientsWindow = time.Hour
+
+// RiskOperation is a deposit, withdrawal or outgoing transfer that was just recorded,
+// as risk rules see it
+type RiskOperation struct {
+	Type          models.TransactionType
+	UserID        string
+	Wallet        *models.Wallet // with the operation already applied
+	TransactionID uuid.UUID
+	Amount        money.Amount
+	RelatedUserID *string // the recipient of a transfer
+	At            time.Time
+}
+
+// RiskHistory looks up a wallet's earlier operations for risk rules
+type RiskHistory interface {
+	CountNewRecipientsSince(ctx context.Context, tx pgx.Tx, walletID string, since time.Time) (int, error)
+}
+
+// RiskRule flags operations matching a heuristic. Check runs inside the operation's
+// database transaction, after the operation was recorded, and returns why op was
+// flagged, or "" when it was not. Flagged operations still go through.
+type RiskRule interface {
+	Name() string
+	Check(ctx context.Context, tx pgx.Tx, history RiskHistory, op RiskOperation) (string, error)
+}
+
+// LargeAmountRule flags deposits, withdrawals and transfers of more than Threshold
+type LargeAmountRule struct {
+	Threshold money.Amount
+}
+
+func (LargeAmountRule) Name() string { return RiskRuleLargeAmount }
+
+// Check flags op when its amount is over the threshold
+func (r LargeAmountRule) Check(_ context.Context, _ pgx.Tx, _ RiskHistory, op RiskOperation) (string, error) {
+	if op.Amount <= r.Threshold {
+		return "", nil
+	}
+	return fmt.Sprintf("amount %s is over %s", op.Amount, r.Threshold), nil
+}
+
+// NewRecipientsRule flags outgoing transfers once a wallet sent money to more than Max
+// users within Window that it had never sent money to before. Window defaults to an hour.
+type NewRecipientsRule struct {
+	Max    int
+	Window time.Duration
+}
+
+func (NewRecipientsRule) Name() string { return RiskRuleNewRecipients }
+
+// Check flags a transfer made while the wallet is over the new recipient count,
+// counting the transfer itself
+func (r NewRecipientsRule) Check(ctx context.Context, tx pgx.Tx, history RiskHistory, op RiskOperation) (string, error) {
+	if op.Type != models.TransactionTypeTransferOut {
+		return "", nil
+	}
+	window := r.Window
+	if window <= 0 {
+		window = defaultNewRecipientsWindow
+	}
+	count, err := history.CountNewRecipientsSince(ctx, tx, op.Wallet.ID.String(), op.At.Add(-window))
+	if err != nil {
+		return "", err
+	}
+	if count <= r.Max {
+		return "", nil
+	}
+	return fmt.Sprintf("%d new recipients within %s, more than %d", count, window, r.Max), nil
+}
+
+// BalanceDrainRule flags withdrawals taking more than Percent of the balance the
+// wallet had before them
+type BalanceDrainRule struct {
+	Percent int
+}
+
+func (BalanceDrainRule) Name() string { return RiskRuleBalanceDrain }
+
+// Check flags a withdrawal over the share of the balance before it
+func (r BalanceDrainRule) Check(_ context.Context, _ pgx.Tx, _ RiskHistory, op RiskOperation) (string, error) {
+	if op.Type != models.TransactionTypeWithdraw {
+		return "", nil
+	}
+	before := op.Wallet.Balance + op.Amount
+	if before <= 0 || op.Amount*100 <= before*money.Amount(r.Percent) {
+		return "", nil
+	}
+	return fmt.Sprintf("withdrawal of %s takes more than %d%% of the %s balance", op.Amount, r.Percent, before), nil
+}
+
+// checkRisk runs the risk rules over a recorded operation and flags it for each rule it
+// matches. The flags are written in the operation's transaction, so they exist exactly
+// when the operation does; a rule failing to run fails the attempt like any other query.
+func (s *WalletService) checkRisk(ctx context.Context, tx pgx.Tx, log *logrus.Entry, op RiskOperation) error {
+	for _, rule := range s.riskRules {
+		details, err := rule.Check(ctx, tx, s.transactionRepo, op)
+		if err != nil {
+			return fmt.Errorf("risk rule %s: %w", rule.Name(), err)
+		}
+		if details == "" {
+			continue
+		}
+		flag := &models.RiskFlag{
+			WalletID:      op.Wallet.ID,
+			TransactionID: op.TransactionID,
+			Rule:          rule.Name(),
+			Details:       details,
+		}
+		if err := s.transactionRepo.CreateRiskFlagTx(ctx, tx, flag); err != nil {
+			return fmt.Errorf("record %s risk flag: %w", rule.Name(), err)
+		}
+		log.WithFields(logrus.Fields{
+			"risk_rule":      flag.Rule,
+			"transaction_id": op.TransactionID.String(),
+			"details":        details,
+		}).Warn("Operation flagged by risk rule")
+	}
+	return nil
+}
+
+// GetRiskFlags lists the risk flags matching filter, newest first
+func (s *WalletService) GetRiskFlags(ctx context.Context, filter models.RiskFlagFilter) ([]models.RiskFlag, error) {
+	log := logger.WithFields(logrus.Fields{
+		"operation": "get_risk_flags",
+		"rule":      filter.Rule,
+	}).WithContext(ctx)
+	flags, err := s.transactionRepo.GetRiskFlags(ctx, filter)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to list risk flags")
+		return nil, err
+	}
+	return flags, nil
+}
+
+func GetRiskFlags(ctx context.Context, filter models.RiskFlagFilter) ([]models.RiskFlag, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GetRiskFlags(ctx, filter)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// stubRiskHistory reports a fixed number of new recipients
+type stubRiskHistory struct {
+	count int
+	since time.Time
+}
+
+func (h *stubRiskHistory) CountNewRecipientsSince(_ context.Context, _ pgx.Tx, _ string, since time.Time) (int, error) {
+	h.since = since
+	return h.count, nil
+}
+
+func TestLargeAmountRule(t *testing.T) {
+	rule := LargeAmountRule{Threshold: 10000}
+
+	for _, tt := range []struct {
+		amount  money.Amount
+		flagged bool
+	}{
+		{9999, false},
+		{10000, false},
+		{10001, true},
+	} {
+		details, err := rule.Check(context.Background(), nil, nil, RiskOperation{Type: models.TransactionTypeDeposit, Amount: tt.amount})
+		assert.NoError(t, err)
+		assert.Equal(t, tt.flagged, details != "", "amount %s", tt.amount)
+	}
+}
+
+func TestNewRecipientsRule(t *testing.T) {
+	at := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	wallet := &models.Wallet{ID: uuid.New()}
+
+	tests := []struct {
+		name    string
+		txType  models.TransactionType
+		count   int
+		flagged bool
+	}{
+		{"at the limit", models.TransactionTypeTransferOut, 3, false},
+		{"over the limit", models.TransactionTypeTransferOut, 4, true},
+		{"withdrawals are not transfers", models.TransactionTypeWithdraw, 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &stubRiskHistory{count: tt.count}
+			details, err := NewRecipientsRule{Max: 3}.Check(context.Background(), nil, history, RiskOperation{Type: tt.txType, Wallet: wallet, Amount: 100, At: at})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.flagged, details != "")
+			if tt.txType == models.TransactionTypeTransferOut {
+				assert.Equal(t, at.Add(-time.Hour), history.since, "the window should default to an hour")
+			}
+		})
+	}
+}
+
+func TestBalanceDrainRule(t *testing.T) {
+	rule := BalanceDrainRule{Percent: 90}
+
+	tests := []struct {
+		name    string
+		txType  models.TransactionType
+		after   money.Amount
+		amount  money.Amount
+		flagged bool
+	}{
+		{"exactly 90%", models.TransactionTypeWithdraw, 1000, 9000, false},
+		{"over 90%", models.TransactionTypeWithdraw, 999, 9001, true},
+		{"empties the wallet", models.TransactionTypeWithdraw, 0, 500, true},
+		{"small withdrawal", models.TransactionTypeWithdraw, 9000, 1000, false},
+		{"transfers are not withdrawals", models.TransactionTypeTransferOut, 0, 500, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := RiskOperation{Type: tt.txType, Wallet: &models.Wallet{Balance: tt.after}, Amount: tt.amount}
+			details, err := rule.Check(context.Background(), nil, nil, op)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.flagged, details != "")
+		})
+	}
+}
+
+func TestWalletService_Withdraw_FlagsAndStillExecutes(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	wallet := &models.Wallet{ID: uuid.New(), Balance: 200, Currency: "USD"}
+	transactionID := uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(9800)).Return(wallet, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(2).(*models.Transaction).ID = transactionID }).
+		Return(nil)
+	var flags []*models.RiskFlag
+	mockTxRepo.On("CreateRiskFlagTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { flags = append(flags, args.Get(2).(*models.RiskFlag)) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		RiskRules: []RiskRule{LargeAmountRule{Threshold: 50000}, BalanceDrainRule{Percent: 90}},
+	})
+	result, _, err := service.Withdraw(context.Background(), "user1", 9800, "", "", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, wallet, result)
+	if assert.Len(t, flags, 1, "only the drain rule should match") {
+		assert.Equal(t, RiskRuleBalanceDrain, flags[0].Rule)
+		assert.Equal(t, wallet.ID, flags[0].WalletID)
+		assert.Equal(t, transactionID, flags[0].TransactionID)
+		assert.NotEmpty(t, flags[0].Details)
+	}
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_Transfer_RiskRuleErrorFailsAttempt(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New(), Balance: 500, Currency: "USD"}, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New(), Currency: "USD"}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockTxRepo.On("CountNewRecipientsSince", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(0, errors.New("connection reset"))
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		RiskRules: []RiskRule{NewRecipientsRule{Max: 5}},
+	})
+	_, err = service.Transfer(context.Background(), "user1", "user2", 3000, "", "", nil)
+
+	assert.ErrorContains(t, err, "connection reset")
+	mockTxRepo.AssertNotCalled(t, "CreateRiskFlagTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for the scheduled transfer worker
+const (
+	defaultSchedulePollInterval  = 30 * time.Second
+	defaultScheduleMaxAttempts   = 3
+	defaultScheduleRetryDelay    = 5 * time.Minute
+	defaultStandingOrderFailures = 3
+	scheduleBatchSize            = 50
+)
+
+// ScheduledTransferRepo stores scheduled transfers and the standing orders that repeat
+// them, and lets the worker claim due transfers
+type ScheduledTransferRepo interface {
+	CreateScheduledTransfer(ctx context.Context, st *models.ScheduledTransfer) error
+	CreateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error
+	GetScheduledTransfersByUserID(ctx context.Context, userID string) ([]models.ScheduledTransfer, error)
+	ClaimDueScheduledTransfersTx(ctx context.Context, tx pgx.Tx, limit int) ([]models.ScheduledTransfer, error)
+	UpdateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error
+	CancelPendingOccurrencesTx(ctx context.Context, tx pgx.Tx, standingOrderID string) error
+	CreateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error
+	GetStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error)
+	GetStandingOrderForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.StandingOrder, error)
+	GetStandingOrdersByUserID(ctx context.Context, userID string) ([]models.StandingOrder, error)
+	UpdateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error
+	CancelStandingOrderTx(ctx context.Context, tx pgx.Tx, userID, id string) (*models.StandingOrder, error)
+}
+
+// ScheduledTransferConfig holds the tunable settings of the scheduled transfer worker.
+// Zero values fall back to the defaults.
+type ScheduledTransferConfig struct {
+	// PollInterval is how often the worker looks for due transfers
+	PollInterval time.Duration
+	// MaxAttempts is how many times a transfer is tried before it is marked FAILED
+	MaxAttempts int
+	// RetryDelay is how long a failed transfer waits before its next attempt
+	RetryDelay time.Duration
+	// StandingOrderMaxFailures is how many occurrences of a standing order may fail in
+	// a row before the order itself is marked FAILED
+	StandingOrderMaxFailures int
+}
+
+// ScheduledTransferConfigFromEnv reads the worker settings from
+// SCHEDULED_TRANSFER_POLL_INTERVAL and SCHEDULED_TRANSFER_RETRY_DELAY (durations such
+// as "30s"), SCHEDULED_TRANSFER_MAX_ATTEMPTS and STANDING_ORDER_MAX_FAILURES. Unset
+// variables keep the defaults.
+func ScheduledTransferConfigFromEnv() (ScheduledTransferConfig, error) {
+	var config ScheduledTransferConfig
+	for _, v := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"SCHEDULED_TRANSFER_POLL_INTERVAL", &config.PollInterval},
+		{"SCHEDULED_TRANSFER_RETRY_DELAY", &config.RetryDelay},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return ScheduledTransferConfig{}, fmt.Errorf("invalid %s %q: must be a positive duration", v.name, raw)
+		}
+		*v.target = d
+	}
+	for _, v := range []struct {
+		name   string
+		target *int
+	}{
+		{"SCHEDULED_TRANSFER_MAX_ATTEMPTS", &config.MaxAttempts},
+		{"STANDING_ORDER_MAX_FAILURES", &config.StandingOrderMaxFailures},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return ScheduledTransferConfig{}, fmt.Errorf("invalid %s %q: must be a positive integer", v.name, raw)
+		}
+		*v.target = n
+	}
+	return config, nil
+}
+
+// ScheduledTransferService records transfers requested for a later time, including
+// the occurrences of standing orders, and executes them once they are due
+type ScheduledTransferService struct {
+	repo                     ScheduledTransferRepo
+	wallets                  *WalletService
+	db                       DB
+	pollInterval             time.Duration
+	maxAttempts              int
+	retryDelay               time.Duration
+	standingOrderMaxFailures int
+}
+
+// NewScheduledTransferService creates a ScheduledTransferService that executes due
+// transfers through wallets
+func NewScheduledTransferService(repo ScheduledTransferRepo, wallets *WalletService, db DB, config ScheduledTransferConfig) *ScheduledTransferService {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultSchedulePollInterval
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultScheduleMaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultScheduleRetryDelay
+	}
+	if config.StandingOrderMaxFailures <= 0 {
+		config.StandingOrderMaxFailures = defaultStandingOrderFailures
+	}
+	return &ScheduledTransferService{
+		repo:                     repo,
+		wallets:                  wallets,
+		db:                       db,
+		pollInterval:             config.PollInterval,
+		maxAttempts:              config.MaxAttempts,
+		retryDelay:               config.RetryDelay,
+		standingOrderMaxFailures: config.StandingOrderMaxFailures,
+	}
+}
+
+// Schedule records a transfer to be executed at executeAt. The amount is validated now
+// but the balance is only checked when the transfer runs.
+func (s *ScheduledTransferService) Schedule(ctx context.Context, fromUserID, toUserID string, amount money.Amount, executeAt time.Time) (*models.ScheduledTransfer, error) {
+	log := logger.WithFields(logrus.Fields{
+		"from_user_id": fromUserID,
+		"to_user_id":   toUserID,
+		"amount":       amount,
+		"execute_at":   executeAt,
+		"operation":    "schedule_transfer",
+	}).WithContext(ctx)
+	log.Info("Scheduling transfer")
+
+	if err := s.wallets.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Scheduled transfer validation failed")
+		return nil, err
+	}
+	if fromUserID == toUserID {
+		log.Warn("Scheduled self-transfer blocked")
+		return nil, ErrSelfTransfer
+	}
+	from, err := uuid.Parse(fromUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from_user_id: %w", err)
+	}
+	to, err := uuid.Parse(toUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to_user_id: %w", err)
+	}
+
+	st := &models.ScheduledTransfer{
+		FromUserID: from,
+		ToUserID:   to,
+		Amount:     amount,
+		ExecuteAt:  executeAt,
+		Status:     models.ScheduledTransferStatusPending,
+	}
+	if err := s.repo.CreateScheduledTransfer(ctx, st); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record scheduled transfer")
+		return nil, err
+	}
+
+	log.WithField("scheduled_transfer_id", st.ID.String()).Info("Transfer scheduled successfully")
+	return st, nil
+}
+
+// GetScheduledTransfers returns the transfers a user has scheduled, in every status
+func (s *ScheduledTransferService) GetScheduledTransfers(ctx context.Context, userID string) ([]models.ScheduledTransfer, error) {
+	transfers, err := s.repo.GetScheduledTransfersByUserID(ctx, userID)
+	if err != nil {
+		logger.WithUser(userID).WithContext(ctx).WithField("error", err.Error()).Error("Failed to get scheduled transfers")
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// RunWorker executes due transfers, and expires transfer intents past their expiry,
+// every poll interval until ctx is cancelled. Several workers may run at once, for
+// example one per API instance; each due transfer is claimed by exactly one of them.
+func (s *ScheduledTransferService) RunWorker(ctx context.Context) {
+	log := logger.WithOperation("scheduled_transfer_worker").WithContext(ctx)
+	log.WithField("poll_interval", s.pollInterval.String()).Info("Scheduled transfer worker started")
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		// Keep going while batches come back full, so a backlog is cleared promptly
+		for {
+			processed, err := s.ProcessDue(ctx)
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to process scheduled transfers")
+				break
+			}
+			if processed < scheduleBatchSize {
+				break
+			}
+		}
+		// Transfer intents are short-lived, so their expiry sweep shares this tick
+		expired, err := s.wallets.ExpireTransferIntents(ctx)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to expire transfer intents")
+		} else if expired > 0 {
+			log.WithField("count", expired).Info("Expired transfer intents")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Scheduled transfer worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue claims a batch of due transfers, executes each through WalletService.Transfer
+// and records the outcome, returning how many were processed. Each transfer runs under
+// an idempotency key derived from its ID, so if recording an outcome fails after the
+// money has moved, the next attempt finds the transfer already done instead of
+// repeating it.
+func (s *ScheduledTransferService) ProcessDue(ctx context.Context) (processed int, err error) {
+	log := logger.WithOperation("process_scheduled_transfers").WithContext(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return 0, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "process scheduled transfers", err); err != nil {
+			processed = 0
+		}
+	}()
+
+	due, err := s.repo.ClaimDueScheduledTransfersTx(ctx, tx, scheduleBatchSize)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to claim due scheduled transfers")
+		return 0, err
+	}
+	for i := range due {
+		if due[i].StandingOrderID != nil {
+			err = s.executeOccurrence(ctx, tx, &due[i])
+		} else {
+			err = s.execute(ctx, tx, &due[i])
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// execute runs one attempt of a claimed transfer and saves its outcome in tx. A failed
+// attempt is rescheduled after the retry delay until the transfer runs out of attempts.
+func (s *ScheduledTransferService) execute(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	fromUserID := st.FromUserID.String()
+	key := "scheduled-transfer:" + st.ID.String()
+	log := logger.WithFields(logrus.Fields{
+		"scheduled_transfer_id": st.ID.String(),
+		"from_user_id":          fromUserID,
+		"to_user_id":            st.ToUserID.String(),
+		"amount":                st.Amount,
+		"attempt":               st.Attempts + 1,
+		"operation":             "execute_scheduled_transfer",
+	}).WithContext(ctx)
+
+	result, transferErr := s.wallets.Transfer(ctx, fromUserID, st.ToUserID.String(), st.Amount, key, "", nil)
+	if ctx.Err() != nil {
+		// Shutting down: leave the attempt unrecorded so it is retried in full
+		return ctx.Err()
+	}
+	st.Attempts++
+
+	if transferErr == nil {
+		st.Status = models.ScheduledTransferStatusCompleted
+		st.TransferID = &result.TransferID
+		st.LastError = nil
+		log.Info("Scheduled transfer executed successfully")
+	} else {
+		message := transferErr.Error()
+		st.LastError = &message
+		if st.Attempts >= s.maxAttempts {
+			st.Status = models.ScheduledTransferStatusFailed
+			log.WithField("error", message).Error("Scheduled transfer failed, no attempts left")
+		} else {
+			st.ExecuteAt = time.Now().Add(s.retryDelay)
+			log.WithFields(logrus.Fields{
+				"error":        message,
+				"retry_at":     st.ExecuteAt,
+				"max_attempts": s.maxAttempts,
+			}).Warn("Scheduled transfer failed, will retry")
+		}
+	}
+
+	if err := s.repo.UpdateScheduledTransferTx(ctx, tx, st); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record scheduled transfer outcome")
+		return err
+	}
+	return nil
+}
+
+var defaultScheduledTransferService *ScheduledTransferService
+
+// SetDefaultScheduledTransferService sets the service used by ScheduleTransfer and GetScheduledTransfers
+func SetDefaultScheduledTransferService(service *ScheduledTransferService) {
+	defaultScheduledTransferService = service
+}
+
+func ScheduleTransfer(ctx context.Context, fromUserID, toUserID string, amount money.Amount, executeAt time.Time) (*models.ScheduledTransfer, error) {
+	if defaultScheduledTransferService == nil {
+		panic("default scheduled transfer service not initialized - call SetDefaultScheduledTransferService first")
+	}
+	return defaultScheduledTransferService.Schedule(ctx, fromUserID, toUserID, amount, executeAt)
+}
+
+func GetScheduledTransfers(ctx context.Context, userID string) ([]models.ScheduledTransfer, error) {
+	if defaultScheduledTransferService == nil {
+		panic("default scheduled transfer service not initialized - call SetDefaultScheduledTransferService first")
+	}
+	return defaultScheduledTransferService.GetScheduledTransfers(ctx, userID)
+}
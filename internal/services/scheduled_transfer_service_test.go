@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduledTransferRepo struct {
+	mock.Mock
+}
+
+func (m *MockScheduledTransferRepo) CreateScheduledTransfer(ctx context.Context, st *models.ScheduledTransfer) error {
+	args := m.Called(ctx, st)
+	return args.Error(0)
+}
+
+func (m *MockScheduledTransferRepo) GetScheduledTransfersByUserID(ctx context.Context, userID string) ([]models.ScheduledTransfer, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepo) ClaimDueScheduledTransfersTx(ctx context.Context, tx pgx.Tx, limit int) ([]models.ScheduledTransfer, error) {
+	args := m.Called(ctx, tx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepo) UpdateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	args := m.Called(ctx, tx, st)
+	return args.Error(0)
+}
+
+func (m *MockScheduledTransferRepo) CreateScheduledTransferTx(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	args := m.Called(ctx, tx, st)
+	return args.Error(0)
+}
+
+func (m *MockScheduledTransferRepo) CancelPendingOccurrencesTx(ctx context.Context, tx pgx.Tx, standingOrderID string) error {
+	args := m.Called(ctx, tx, standingOrderID)
+	return args.Error(0)
+}
+
+func (m *MockScheduledTransferRepo) CreateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error {
+	args := m.Called(ctx, tx, o)
+	return args.Error(0)
+}
+
+func (m *MockScheduledTransferRepo) GetStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StandingOrder), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepo) GetStandingOrderForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.StandingOrder, error) {
+	args := m.Called(ctx, tx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StandingOrder), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepo) GetStandingOrdersByUserID(ctx context.Context, userID string) ([]models.StandingOrder, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.StandingOrder), args.Error(1)
+}
+
+func (m *MockScheduledTransferRepo) UpdateStandingOrderTx(ctx context.Context, tx pgx.Tx, o *models.StandingOrder) error {
+	args := m.Called(ctx, tx, o)
+	return args.Error(0)
+}
+
+func (m *MockScheduledTransferRepo) CancelStandingOrderTx(ctx context.Context, tx pgx.Tx, userID, id string) (*models.StandingOrder, error) {
+	args := m.Called(ctx, tx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StandingOrder), args.Error(1)
+}
+
+func TestScheduledTransferService_Schedule(t *testing.T) {
+	from, to := uuid.New().String(), uuid.New().String()
+	executeAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		fromUserID    string
+		toUserID      string
+		amount        money.Amount
+		repoErr       error
+		expectedErrIs error
+	}{
+		{"records a pending transfer", from, to, 2500, nil, nil},
+		{"self transfer", from, from, 2500, nil, ErrSelfTransfer},
+		{"invalid amount", from, to, 0, nil, ErrInvalidAmount},
+		{"unknown user", from, to, 2500, repositories.ErrUserNotFound, ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			repo := new(MockScheduledTransferRepo)
+			if tt.expectedErrIs == nil || tt.repoErr != nil {
+				repo.On("CreateScheduledTransfer", mock.Anything, mock.AnythingOfType("*models.ScheduledTransfer")).Return(tt.repoErr)
+			}
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service := NewScheduledTransferService(repo, wallets, mockDB, ScheduledTransferConfig{})
+			st, err := service.Schedule(context.Background(), tt.fromUserID, tt.toUserID, tt.amount, executeAt)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, st)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, models.ScheduledTransferStatusPending, st.Status)
+				assert.Equal(t, executeAt, st.ExecuteAt)
+				assert.Equal(t, tt.toUserID, st.ToUserID.String())
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestScheduledTransferService_ProcessDue(t *testing.T) {
+	tests := []struct {
+		name             string
+		previousAttempts int
+		debitErr         error
+		expectedStatus   models.ScheduledTransferStatus
+	}{
+		{"executes the transfer", 0, nil, models.ScheduledTransferStatusCompleted},
+		{"failure is retried later", 0, repositories.ErrInsufficientBalance, models.ScheduledTransferStatusPending},
+		{"failure on the last attempt", 2, repositories.ErrInsufficientBalance, models.ScheduledTransferStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			due := models.ScheduledTransfer{
+				ID:         uuid.New(),
+				FromUserID: uuid.New(),
+				ToUserID:   uuid.New(),
+				Amount:     2500,
+				ExecuteAt:  time.Now().Add(-time.Minute),
+				Status:     models.ScheduledTransferStatusPending,
+				Attempts:   tt.previousAttempts,
+			}
+			from, to := due.FromUserID.String(), due.ToUserID.String()
+			key := "scheduled-transfer:" + due.ID.String()
+
+			// The worker's claiming transaction wraps the transfer's own transaction
+			mockDB.ExpectBegin()
+			mockDB.ExpectBegin()
+			mockTxRepo.On("GetTransactionByIdempotencyKeyTx", mock.Anything, mock.Anything, from, key).
+				Return(nil, repositories.ErrTransactionNotFound).Once()
+			if tt.debitErr == nil {
+				mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, from, due.Amount).Return(&models.Wallet{ID: uuid.New()}, nil)
+				mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, to, due.Amount).Return(&models.Wallet{ID: uuid.New()}, nil)
+				mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				mockDB.ExpectCommit()
+			} else {
+				mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, from, due.Amount).Return(nil, tt.debitErr)
+				mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, to, due.Amount).Return(&models.Wallet{ID: uuid.New()}, nil).Maybe()
+				mockDB.ExpectRollback()
+			}
+			mockDB.ExpectCommit()
+
+			repo := new(MockScheduledTransferRepo)
+			var saved *models.ScheduledTransfer
+			repo.On("ClaimDueScheduledTransfersTx", mock.Anything, mock.Anything, scheduleBatchSize).Return([]models.ScheduledTransfer{due}, nil)
+			repo.On("UpdateScheduledTransferTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { saved = args.Get(2).(*models.ScheduledTransfer) }).
+				Return(nil)
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service := NewScheduledTransferService(repo, wallets, mockDB, ScheduledTransferConfig{MaxAttempts: 3, RetryDelay: time.Hour})
+			processed, err := service.ProcessDue(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, processed)
+			if assert.NotNil(t, saved) {
+				assert.Equal(t, tt.expectedStatus, saved.Status)
+				assert.Equal(t, tt.previousAttempts+1, saved.Attempts)
+				if tt.debitErr == nil {
+					assert.NotNil(t, saved.TransferID)
+					assert.Nil(t, saved.LastError)
+				} else {
+					assert.Nil(t, saved.TransferID)
+					if assert.NotNil(t, saved.LastError) {
+						assert.Contains(t, *saved.LastError, "insufficient balance")
+					}
+				}
+				if tt.expectedStatus == models.ScheduledTransferStatusPending {
+					assert.True(t, saved.ExecuteAt.After(time.Now().Add(30*time.Minute)), "expected the retry to wait for the retry delay")
+				}
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestScheduledTransferConfigFromEnv(t *testing.T) {
+	t.Setenv("SCHEDULED_TRANSFER_POLL_INTERVAL", "10s")
+	t.Setenv("SCHEDULED_TRANSFER_RETRY_DELAY", "2m")
+	t.Setenv("SCHEDULED_TRANSFER_MAX_ATTEMPTS", "5")
+
+	config, err := ScheduledTransferConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, ScheduledTransferConfig{PollInterval: 10 * time.Second, MaxAttempts: 5, RetryDelay: 2 * time.Minute}, config)
+
+	t.Setenv("STANDING_ORDER_MAX_FAILURES", "4")
+	config, err = ScheduledTransferConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, config.StandingOrderMaxFailures)
+	t.Setenv("STANDING_ORDER_MAX_FAILURES", "")
+
+	t.Setenv("SCHEDULED_TRANSFER_MAX_ATTEMPTS", "0")
+	_, err = ScheduledTransferConfigFromEnv()
+	assert.Error(t, err)
+	t.Setenv("SCHEDULED_TRANSFER_MAX_ATTEMPTS", "")
+
+	t.Setenv("SCHEDULED_TRANSFER_POLL_INTERVAL", "soon")
+	_, err = ScheduledTransferConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidSchedule is returned when a standing order's interval or end condition is invalid
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Standing order failures, matched with errors.Is
+var (
+	ErrStandingOrderNotFound  = repositories.ErrStandingOrderNotFound
+	ErrStandingOrderNotActive = repositories.ErrStandingOrderNotActive
+)
+
+// CreateStandingOrder sets up a transfer from fromUserID repeated every req.Interval from
+// req.StartAt, until req.EndAt or req.MaxOccurrences is reached, whichever comes first.
+// The first occurrence is scheduled together with the order.
+func (s *ScheduledTransferService) CreateStandingOrder(ctx context.Context, fromUserID string, req *models.StandingOrderRequest) (order *models.StandingOrder, err error) {
+	log := logger.WithUser(fromUserID).WithContext(ctx).WithFields(logrus.Fields{
+		"to_user_id": req.ToUserID,
+		"amount":     req.Amount,
+		"interval":   req.Interval,
+		"operation":  "create_standing_order",
+	})
+	log.Info("Creating standing order")
+
+	if err := s.wallets.ValidateAmount(req.Amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Standing order validation failed")
+		return nil, err
+	}
+	if fromUserID == req.ToUserID {
+		log.Warn("Standing order to self blocked")
+		return nil, ErrSelfTransfer
+	}
+	switch req.Interval {
+	case models.StandingOrderIntervalDaily, models.StandingOrderIntervalWeekly, models.StandingOrderIntervalMonthly:
+	default:
+		return nil, fmt.Errorf("%w: interval must be one of DAILY, WEEKLY, MONTHLY", ErrInvalidSchedule)
+	}
+	if req.EndAt != nil && req.EndAt.Before(req.StartAt) {
+		return nil, fmt.Errorf("%w: end_at is before start_at", ErrInvalidSchedule)
+	}
+	if req.MaxOccurrences != nil && *req.MaxOccurrences <= 0 {
+		return nil, fmt.Errorf("%w: max_occurrences must be positive", ErrInvalidSchedule)
+	}
+	from, err := uuid.Parse(fromUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from_user_id: %w", err)
+	}
+	to, err := uuid.Parse(req.ToUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to_user_id: %w", err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "create standing order", err); err != nil {
+			order = nil
+		}
+	}()
+
+	order = &models.StandingOrder{
+		FromUserID:     from,
+		ToUserID:       to,
+		Amount:         req.Amount,
+		Interval:       req.Interval,
+		StartAt:        req.StartAt,
+		EndAt:          req.EndAt,
+		MaxOccurrences: req.MaxOccurrences,
+		Occurrences:    1,
+		NextRunAt:      req.StartAt,
+		Status:         models.StandingOrderStatusActive,
+	}
+	if err = s.repo.CreateStandingOrderTx(ctx, tx, order); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record standing order")
+		return nil, err
+	}
+	if err = s.scheduleOccurrence(ctx, tx, order); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to schedule first occurrence")
+		return nil, err
+	}
+
+	log.WithField("standing_order_id", order.ID.String()).Info("Standing order created successfully")
+	return order, nil
+}
+
+// GetStandingOrders returns a user's standing orders, in every status
+func (s *ScheduledTransferService) GetStandingOrders(ctx context.Context, userID string) ([]models.StandingOrder, error) {
+	orders, err := s.repo.GetStandingOrdersByUserID(ctx, userID)
+	if err != nil {
+		logger.WithUser(userID).WithContext(ctx).WithField("error", err.Error()).Error("Failed to get standing orders")
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetStandingOrder returns one of a user's standing orders
+func (s *ScheduledTransferService) GetStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error) {
+	return s.repo.GetStandingOrder(ctx, userID, id)
+}
+
+// CancelStandingOrder stops one of a user's active standing orders and cancels its
+// pending occurrence. An occurrence the worker is executing at that moment completes,
+// but no further occurrence is scheduled.
+func (s *ScheduledTransferService) CancelStandingOrder(ctx context.Context, userID, id string) (order *models.StandingOrder, err error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"standing_order_id": id,
+		"operation":         "cancel_standing_order",
+	})
+	log.Info("Cancelling standing order")
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "cancel standing order", err); err != nil {
+			order = nil
+		}
+	}()
+
+	// The order is locked before its occurrences, the reverse of the worker, which is
+	// why occurrences the worker holds are skipped instead of waited for
+	order, err = s.repo.CancelStandingOrderTx(ctx, tx, userID, id)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to cancel standing order")
+		return nil, err
+	}
+	if err = s.repo.CancelPendingOccurrencesTx(ctx, tx, id); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to cancel pending occurrences")
+		return nil, err
+	}
+
+	log.Info("Standing order cancelled successfully")
+	return order, nil
+}
+
+// executeOccurrence runs a claimed occurrence of a standing order and, once it has
+// completed or run out of attempts, advances the order. The order is locked first, so
+// an occurrence of an order cancelled after it was scheduled is cancelled, not executed.
+func (s *ScheduledTransferService) executeOccurrence(ctx context.Context, tx pgx.Tx, st *models.ScheduledTransfer) error {
+	order, err := s.repo.GetStandingOrderForUpdateTx(ctx, tx, st.StandingOrderID.String())
+	if err != nil {
+		return err
+	}
+	if order.Status != models.StandingOrderStatusActive {
+		st.Status = models.ScheduledTransferStatusCancelled
+		return s.repo.UpdateScheduledTransferTx(ctx, tx, st)
+	}
+
+	if err := s.execute(ctx, tx, st); err != nil {
+		return err
+	}
+	if st.Status == models.ScheduledTransferStatusPending {
+		// The occurrence will be retried before the series moves on
+		return nil
+	}
+	return s.advanceStandingOrder(ctx, tx, order, st.Status == models.ScheduledTransferStatusCompleted)
+}
+
+// advanceStandingOrder records the outcome of an order's latest occurrence and schedules
+// the next one, or ends the order when it has reached its end condition or failed
+// standingOrderMaxFailures times in a row. A single failed occurrence never ends the series.
+func (s *ScheduledTransferService) advanceStandingOrder(ctx context.Context, tx pgx.Tx, order *models.StandingOrder, succeeded bool) error {
+	log := logger.WithUser(order.FromUserID.String()).WithContext(ctx).WithFields(logrus.Fields{
+		"standing_order_id": order.ID.String(),
+		"operation":         "advance_standing_order",
+	})
+
+	if succeeded {
+		order.ConsecutiveFailures = 0
+	} else {
+		order.ConsecutiveFailures++
+	}
+
+	next := occurrenceTime(order.StartAt, order.Interval, order.Occurrences)
+	switch {
+	case order.ConsecutiveFailures >= s.standingOrderMaxFailures:
+		order.Status = models.StandingOrderStatusFailed
+		log.WithField("consecutive_failures", order.ConsecutiveFailures).Error("Standing order failed too many times in a row, stopping it")
+	case order.MaxOccurrences != nil && order.Occurrences >= *order.MaxOccurrences,
+		order.EndAt != nil && next.After(*order.EndAt):
+		order.Status = models.StandingOrderStatusCompleted
+		log.WithField("occurrences", order.Occurrences).Info("Standing order reached its end")
+	default:
+		order.Occurrences++
+		order.NextRunAt = next
+		if err := s.scheduleOccurrence(ctx, tx, order); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to schedule next occurrence")
+			return err
+		}
+		log.WithField("next_run_at", next).Info("Scheduled next standing order occurrence")
+	}
+
+	return s.repo.UpdateStandingOrderTx(ctx, tx, order)
+}
+
+// scheduleOccurrence records the order's occurrence due at order.NextRunAt
+func (s *ScheduledTransferService) scheduleOccurrence(ctx context.Context, tx pgx.Tx, order *models.StandingOrder) error {
+	return s.repo.CreateScheduledTransferTx(ctx, tx, &models.ScheduledTransfer{
+		FromUserID:      order.FromUserID,
+		ToUserID:        order.ToUserID,
+		Amount:          order.Amount,
+		ExecuteAt:       order.NextRunAt,
+		Status:          models.ScheduledTransferStatusPending,
+		StandingOrderID: &order.ID,
+	})
+}
+
+// occurrenceTime returns when the n-th occurrence (counting from 0) of a standing order
+// starting at start is due. Monthly orders keep the start's day of the month, moving
+// to the last day in shorter months, so an order starting on the 31st never drifts.
+func occurrenceTime(start time.Time, interval models.StandingOrderInterval, n int) time.Time {
+	switch interval {
+	case models.StandingOrderIntervalDaily:
+		return start.AddDate(0, 0, n)
+	case models.StandingOrderIntervalWeekly:
+		return start.AddDate(0, 0, 7*n)
+	}
+	year, month, day := start.Date()
+	first := time.Date(year, month+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func CreateStandingOrder(ctx context.Context, fromUserID string, req *models.StandingOrderRequest) (*models.StandingOrder, error) {
+	if defaultScheduledTransferService == nil {
+		panic("default scheduled transfer service not initialized - call SetDefaultScheduledTransferService first")
+	}
+	return defaultScheduledTransferService.CreateStandingOrder(ctx, fromUserID, req)
+}
+
+func GetStandingOrders(ctx context.Context, userID string) ([]models.StandingOrder, error) {
+	if defaultScheduledTransferService == nil {
+		panic("default scheduled transfer service not initialized - call SetDefaultScheduledTransferService first")
+	}
+	return defaultScheduledTransferService.GetStandingOrders(ctx, userID)
+}
+
+func GetStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error) {
+	if defaultScheduledTransferService == nil {
+		panic("default scheduled transfer service not initialized - call SetDefaultScheduledTransferService first")
+	}
+	return defaultScheduledTransferService.GetStandingOrder(ctx, userID, id)
+}
+
+func CancelStandingOrder(ctx context.Context, userID, id string) (*models.StandingOrder, error) {
+	if defaultScheduledTransferService == nil {
+		panic("default scheduled transfer service not initialized - call SetDefaultScheduledTransferService first")
+	}
+	return defaultScheduledTransferService.CancelStandingOrder(ctx, userID, id)
+}
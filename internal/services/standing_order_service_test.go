@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestScheduledTransferService_CreateStandingOrder(t *testing.T) {
+	from, to := uuid.New().String(), uuid.New().String()
+	start := time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Hour)
+	zero := 0
+
+	tests := []struct {
+		name          string
+		fromUserID    string
+		req           models.StandingOrderRequest
+		expectedErrIs error
+	}{
+		{"schedules the first occurrence", from, models.StandingOrderRequest{ToUserID: to, Amount: 1000, Interval: models.StandingOrderIntervalMonthly, StartAt: start}, nil},
+		{"self transfer", from, models.StandingOrderRequest{ToUserID: from, Amount: 1000, Interval: models.StandingOrderIntervalDaily, StartAt: start}, ErrSelfTransfer},
+		{"invalid amount", from, models.StandingOrderRequest{ToUserID: to, Amount: 0, Interval: models.StandingOrderIntervalDaily, StartAt: start}, ErrInvalidAmount},
+		{"unknown interval", from, models.StandingOrderRequest{ToUserID: to, Amount: 1000, Interval: "HOURLY", StartAt: start}, ErrInvalidSchedule},
+		{"ends before it starts", from, models.StandingOrderRequest{ToUserID: to, Amount: 1000, Interval: models.StandingOrderIntervalDaily, StartAt: start, EndAt: &before}, ErrInvalidSchedule},
+		{"no occurrences", from, models.StandingOrderRequest{ToUserID: to, Amount: 1000, Interval: models.StandingOrderIntervalDaily, StartAt: start, MaxOccurrences: &zero}, ErrInvalidSchedule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			repo := new(MockScheduledTransferRepo)
+			var first *models.ScheduledTransfer
+			orderID := uuid.New()
+			if tt.expectedErrIs == nil {
+				mockDB.ExpectBegin()
+				repo.On("CreateStandingOrderTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.StandingOrder")).
+					Run(func(args mock.Arguments) { args.Get(2).(*models.StandingOrder).ID = orderID }).
+					Return(nil)
+				repo.On("CreateScheduledTransferTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.ScheduledTransfer")).
+					Run(func(args mock.Arguments) { first = args.Get(2).(*models.ScheduledTransfer) }).
+					Return(nil)
+				mockDB.ExpectCommit()
+			}
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service := NewScheduledTransferService(repo, wallets, mockDB, ScheduledTransferConfig{})
+			order, err := service.CreateStandingOrder(context.Background(), tt.fromUserID, &tt.req)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, order)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, models.StandingOrderStatusActive, order.Status)
+				assert.Equal(t, 1, order.Occurrences)
+				if assert.NotNil(t, first) {
+					assert.Equal(t, start, first.ExecuteAt)
+					assert.Equal(t, &orderID, first.StandingOrderID)
+					assert.Equal(t, models.ScheduledTransferStatusPending, first.Status)
+				}
+			}
+			repo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestScheduledTransferService_AdvanceStandingOrder(t *testing.T) {
+	start := time.Date(2025, time.March, 1, 9, 0, 0, 0, time.UTC)
+	two := 2
+	endAt := start.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name                string
+		occurrences         int
+		consecutiveFailures int
+		maxOccurrences      *int
+		endAt               *time.Time
+		succeeded           bool
+		expectedStatus      models.StandingOrderStatus
+		expectedFailures    int
+		expectNext          bool
+	}{
+		{"success schedules the next occurrence", 1, 0, nil, nil, true, models.StandingOrderStatusActive, 0, true},
+		{"success resets the failure count", 1, 2, nil, nil, true, models.StandingOrderStatusActive, 0, true},
+		{"a failure keeps the series going", 1, 0, nil, nil, false, models.StandingOrderStatusActive, 1, true},
+		{"too many failures in a row", 3, 2, nil, nil, false, models.StandingOrderStatusFailed, 3, false},
+		{"max occurrences reached", 2, 0, &two, nil, true, models.StandingOrderStatusCompleted, 0, false},
+		{"next occurrence after the end date", 1, 0, nil, &endAt, true, models.StandingOrderStatusActive, 0, true},
+		{"end date reached", 2, 0, nil, &endAt, true, models.StandingOrderStatusCompleted, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.StandingOrder{
+				ID:                  uuid.New(),
+				FromUserID:          uuid.New(),
+				ToUserID:            uuid.New(),
+				Amount:              1000,
+				Interval:            models.StandingOrderIntervalDaily,
+				StartAt:             start,
+				EndAt:               tt.endAt,
+				MaxOccurrences:      tt.maxOccurrences,
+				Occurrences:         tt.occurrences,
+				ConsecutiveFailures: tt.consecutiveFailures,
+				NextRunAt:           start.AddDate(0, 0, tt.occurrences-1),
+				Status:              models.StandingOrderStatusActive,
+			}
+
+			repo := new(MockScheduledTransferRepo)
+			var next *models.ScheduledTransfer
+			if tt.expectNext {
+				repo.On("CreateScheduledTransferTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.ScheduledTransfer")).
+					Run(func(args mock.Arguments) { next = args.Get(2).(*models.ScheduledTransfer) }).
+					Return(nil)
+			}
+			repo.On("UpdateStandingOrderTx", mock.Anything, mock.Anything, order).Return(nil)
+
+			service := NewScheduledTransferService(repo, nil, nil, ScheduledTransferConfig{StandingOrderMaxFailures: 3})
+			err := service.advanceStandingOrder(context.Background(), nil, order, tt.succeeded)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, order.Status)
+			assert.Equal(t, tt.expectedFailures, order.ConsecutiveFailures)
+			if tt.expectNext {
+				if assert.NotNil(t, next) {
+					assert.Equal(t, start.AddDate(0, 0, tt.occurrences), next.ExecuteAt)
+					assert.Equal(t, &order.ID, next.StandingOrderID)
+				}
+				assert.Equal(t, tt.occurrences+1, order.Occurrences)
+			} else {
+				assert.Equal(t, tt.occurrences, order.Occurrences)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestScheduledTransferService_ProcessDue_CancelledStandingOrder(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	orderID := uuid.New()
+	due := models.ScheduledTransfer{
+		ID:              uuid.New(),
+		FromUserID:      uuid.New(),
+		ToUserID:        uuid.New(),
+		Amount:          1000,
+		ExecuteAt:       time.Now().Add(-time.Minute),
+		Status:          models.ScheduledTransferStatusPending,
+		StandingOrderID: &orderID,
+	}
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+
+	repo := new(MockScheduledTransferRepo)
+	var saved *models.ScheduledTransfer
+	repo.On("ClaimDueScheduledTransfersTx", mock.Anything, mock.Anything, scheduleBatchSize).Return([]models.ScheduledTransfer{due}, nil)
+	repo.On("GetStandingOrderForUpdateTx", mock.Anything, mock.Anything, orderID.String()).
+		Return(&models.StandingOrder{ID: orderID, Status: models.StandingOrderStatusCancelled}, nil)
+	repo.On("UpdateScheduledTransferTx", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(2).(*models.ScheduledTransfer) }).
+		Return(nil)
+
+	wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	service := NewScheduledTransferService(repo, wallets, mockDB, ScheduledTransferConfig{})
+	processed, err := service.ProcessDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	if assert.NotNil(t, saved) {
+		assert.Equal(t, models.ScheduledTransferStatusCancelled, saved.Status)
+		assert.Equal(t, 0, saved.Attempts)
+	}
+	mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestScheduledTransferService_CancelStandingOrder(t *testing.T) {
+	userID, orderID := uuid.New().String(), uuid.New().String()
+
+	tests := []struct {
+		name          string
+		cancelErr     error
+		expectedErrIs error
+	}{
+		{"cancels the order and its pending occurrence", nil, nil},
+		{"already finished", repositories.ErrStandingOrderNotActive, ErrStandingOrderNotActive},
+		{"not found", repositories.ErrStandingOrderNotFound, ErrStandingOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			repo := new(MockScheduledTransferRepo)
+			mockDB.ExpectBegin()
+			if tt.cancelErr == nil {
+				repo.On("CancelStandingOrderTx", mock.Anything, mock.Anything, userID, orderID).
+					Return(&models.StandingOrder{Status: models.StandingOrderStatusCancelled}, nil)
+				repo.On("CancelPendingOccurrencesTx", mock.Anything, mock.Anything, orderID).Return(nil)
+				mockDB.ExpectCommit()
+			} else {
+				repo.On("CancelStandingOrderTx", mock.Anything, mock.Anything, userID, orderID).Return(nil, tt.cancelErr)
+				mockDB.ExpectRollback()
+			}
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service := NewScheduledTransferService(repo, wallets, mockDB, ScheduledTransferConfig{})
+			order, err := service.CancelStandingOrder(context.Background(), userID, orderID)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, order)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, models.StandingOrderStatusCancelled, order.Status)
+			}
+			repo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOccurrenceTime(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		interval models.StandingOrderInterval
+		n        int
+		expected time.Time
+	}{
+		{"first occurrence", models.StandingOrderIntervalMonthly, 0, start},
+		{"daily", models.StandingOrderIntervalDaily, 3, time.Date(2024, time.February, 3, 9, 30, 0, 0, time.UTC)},
+		{"weekly", models.StandingOrderIntervalWeekly, 2, time.Date(2024, time.February, 14, 9, 30, 0, 0, time.UTC)},
+		{"monthly into a leap February", models.StandingOrderIntervalMonthly, 1, time.Date(2024, time.February, 29, 9, 30, 0, 0, time.UTC)},
+		{"monthly returns to the 31st", models.StandingOrderIntervalMonthly, 2, time.Date(2024, time.March, 31, 9, 30, 0, 0, time.UTC)},
+		{"monthly into a 30 day month", models.StandingOrderIntervalMonthly, 3, time.Date(2024, time.April, 30, 9, 30, 0, 0, time.UTC)},
+		{"monthly across the year", models.StandingOrderIntervalMonthly, 13, time.Date(2025, time.February, 28, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, occurrenceTime(start, tt.interval, tt.n))
+		})
+	}
+}
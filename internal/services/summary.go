@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidDateRange is returned for summary periods that end before they start or
+// span more than a year
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+// GetWalletSummary totals the completed transactions of a user's default wallet from
+// the UTC day from through the UTC day to, both included, with the balance the wallet
+// had at the start and the end of the period. A zero from defaults to the first day of
+// the current month and a zero to to today. The period may span at most a year.
+func (s *WalletService) GetWalletSummary(ctx context.Context, userID string, from, to time.Time) (*models.WalletSummary, error) {
+	now := s.now()
+	if from.IsZero() {
+		from = startOfMonthUTC(now)
+	}
+	if to.IsZero() {
+		to = now
+	}
+	from, to = startOfDayUTC(from), startOfDayUTC(to)
+
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation": "get_wallet_summary",
+		"from":      from.Format(time.DateOnly),
+		"to":        to.Format(time.DateOnly),
+	})
+	log.Info("Getting wallet summary")
+
+	// The period runs up to the midnight that ends its last day
+	end := to.AddDate(0, 0, 1)
+	if to.Before(from) {
+		log.Warn("Summary period ends before it starts")
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidDateRange)
+	}
+	if end.After(from.AddDate(1, 0, 0)) {
+		log.Warn("Summary period longer than a year")
+		return nil, fmt.Errorf("%w: the period can span at most a year", ErrInvalidDateRange)
+	}
+
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+	summary, err := s.transactionRepo.GetTransactionSummary(ctx, wallet.ID.String(), from, end)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to summarize transactions")
+		return nil, err
+	}
+
+	summary.WalletID = wallet.ID
+	summary.Currency = wallet.Currency
+	summary.From = from.Format(time.DateOnly)
+	summary.To = to.Format(time.DateOnly)
+	for _, total := range summary.ByType {
+		switch total.Type {
+		case models.TransactionTypeDeposit:
+			summary.Deposited = total.Total
+		case models.TransactionTypeWithdraw:
+			summary.Withdrawn = total.Total
+		case models.TransactionTypeTransferIn:
+			summary.TransferredIn = total.Total
+		case models.TransactionTypeTransferOut:
+			summary.TransferredOut = total.Total
+		case models.TransactionTypeFee:
+			summary.Fees = total.Total
+		case models.TransactionTypeBonus:
+			summary.Bonuses = total.Total
+		case models.TransactionTypeInterest:
+			summary.Interest = total.Total
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"transaction_count": summary.TransactionCount,
+		"net_change":        summary.NetChange,
+	}).Info("Wallet summary retrieved")
+	return summary, nil
+}
+
+// GetWalletTotals totals what a wallet moved over its whole life, such as everything
+// ever deposited to it. Reversed transfers are netted out of the totals.
+func (s *WalletService) GetWalletTotals(ctx context.Context, walletID string) (*models.WalletTotals, error) {
+	log := logger.WithOperation("get_wallet_totals").WithContext(ctx).WithField("wallet_id", walletID)
+
+	totals, err := s.transactionRepo.GetWalletTotals(ctx, walletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to total transactions")
+		return nil, err
+	}
+	for _, total := range totals.ByType {
+		switch total.Type {
+		case models.TransactionTypeDeposit:
+			totals.Deposited = total.Total
+		case models.TransactionTypeWithdraw:
+			totals.Withdrawn = total.Total
+		case models.TransactionTypeTransferIn:
+			totals.TransferredIn = total.Total
+		case models.TransactionTypeTransferOut:
+			totals.TransferredOut = total.Total
+		case models.TransactionTypeFee:
+			totals.Fees = total.Total
+		case models.TransactionTypeBonus:
+			totals.Bonuses = total.Total
+		case models.TransactionTypeInterest:
+			totals.Interest = total.Total
+		case models.TransactionTypeAdjustment:
+			totals.Adjustments = total.Total
+		case models.TransactionTypeExchange:
+			totals.Exchanged = total.Total
+		}
+	}
+
+	log.WithField("transaction_count", totals.TransactionCount).Debug("Wallet totals retrieved")
+	return totals, nil
+}
+
+// GetWalletStatement builds the statement of a user's default wallet for a UTC calendar
+// month, with a page of its completed transactions of at most limit after skipping
+// offset. The balances are derived from the wallet's balance less the transactions
+// recorded since, as for summaries. Months that have not started yet are rejected with
+// ErrInvalidDateRange; months that ended before the wallet was created get an empty
+// statement that opens and closes at zero.
+func (s *WalletService) GetWalletStatement(ctx context.Context, userID string, year int, month time.Month, limit, offset int) (*models.WalletStatement, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation": "get_wallet_statement",
+		"year":      year,
+		"month":     int(month),
+	})
+	log.Info("Getting wallet statement")
+
+	if month < time.January || month > time.December {
+		log.Warn("Invalid statement month")
+		return nil, fmt.Errorf("%w: month must be between 1 and 12", ErrInvalidDateRange)
+	}
+	from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if from.After(startOfMonthUTC(s.now())) {
+		log.Warn("Statement month in the future")
+		return nil, fmt.Errorf("%w: the month has not started yet", ErrInvalidDateRange)
+	}
+	// The month runs up to the midnight that starts the next one
+	end := from.AddDate(0, 1, 0)
+
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+	statement := &models.WalletStatement{
+		WalletID:     wallet.ID,
+		Currency:     wallet.Currency,
+		Year:         year,
+		Month:        int(month),
+		From:         from.Format(time.DateOnly),
+		To:           end.AddDate(0, 0, -1).Format(time.DateOnly),
+		ByType:       []models.TransactionTypeTotal{},
+		Transactions: models.TransactionPage{Items: []models.Transaction{}, Limit: limit, Offset: offset},
+	}
+	if !end.After(wallet.CreatedAt) {
+		log.Debug("Statement month ended before the wallet was created")
+		return statement, nil
+	}
+
+	summary, err := s.transactionRepo.GetTransactionSummary(ctx, wallet.ID.String(), from, end)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to summarize transactions")
+		return nil, err
+	}
+	txs, total, err := s.transactionRepo.GetStatementTransactions(ctx, wallet.ID.String(), from, end, limit, offset)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get statement transactions")
+		return nil, err
+	}
+
+	statement.OpeningBalance = summary.OpeningBalance
+	statement.ClosingBalance = summary.ClosingBalance
+	statement.NetChange = summary.NetChange
+	statement.TransactionCount = summary.TransactionCount
+	statement.ByType = summary.ByType
+	if txs != nil {
+		statement.Transactions.Items = txs
+	}
+	statement.Transactions.Total = total
+	statement.Transactions.HasMore = offset+len(txs) < total
+
+	log.WithFields(logrus.Fields{
+		"transaction_count": statement.TransactionCount,
+		"net_change":        statement.NetChange,
+	}).Info("Wallet statement retrieved")
+	return statement, nil
+}
+
+func GetWalletSummary(ctx context.Context, userID string, from, to time.Time) (*models.WalletSummary, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GetWalletSummary(ctx, userID, from, to)
+}
+
+func GetWalletTotals(ctx context.Context, walletID string) (*models.WalletTotals, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GetWalletTotals(ctx, walletID)
+}
+
+func GetWalletStatement(ctx context.Context, userID string, year int, month time.Month, limit, offset int) (*models.WalletStatement, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GetWalletStatement(ctx, userID, year, month, limit, offset)
+}
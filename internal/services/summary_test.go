@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_GetWalletSummary_Period(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 18, 30, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		assert.NoError(t, err)
+		return d
+	}
+
+	tests := []struct {
+		name        string
+		from, to    time.Time
+		wantFrom    string
+		wantEnd     string // exclusive end passed to the repository
+		expectedErr error
+	}{
+		{name: "defaults to the current month", wantFrom: "2024-03-01", wantEnd: "2024-03-16"},
+		{name: "single day", from: day("2024-02-10"), to: day("2024-02-10"), wantFrom: "2024-02-10", wantEnd: "2024-02-11"},
+		{name: "a whole year", from: day("2023-01-01"), to: day("2023-12-31"), wantFrom: "2023-01-01", wantEnd: "2024-01-01"},
+		{name: "over a year", from: day("2023-01-01"), to: day("2024-01-01"), expectedErr: ErrInvalidDateRange},
+		{name: "ends before it starts", from: day("2024-03-10"), to: day("2024-03-09"), expectedErr: ErrInvalidDateRange},
+		{name: "from after today without to", from: day("2024-04-01"), expectedErr: ErrInvalidDateRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+			wallet := &models.Wallet{ID: uuid.New(), Currency: "USD"}
+			mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(wallet, nil).Maybe()
+			if tt.expectedErr == nil {
+				mockTxRepo.On("GetTransactionSummary", mock.Anything, wallet.ID.String(), day(tt.wantFrom), day(tt.wantEnd)).
+					Return(&models.WalletSummary{ByType: []models.TransactionTypeTotal{}}, nil)
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{})
+			service.now = func() time.Time { return now }
+			summary, err := service.GetWalletSummary(context.Background(), "user1", tt.from, tt.to)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				mockTxRepo.AssertNotCalled(t, "GetTransactionSummary", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFrom, summary.From)
+			assert.Equal(t, day(tt.wantEnd).AddDate(0, 0, -1).Format(time.DateOnly), summary.To)
+			assert.Equal(t, wallet.ID, summary.WalletID)
+			mockTxRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletService_GetWalletSummary_ReportsBonusesAndInterestApart(t *testing.T) {
+	mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+	wallet := &models.Wallet{ID: uuid.New(), Currency: "USD"}
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(wallet, nil)
+	mockTxRepo.On("GetTransactionSummary", mock.Anything, wallet.ID.String(), mock.Anything, mock.Anything).Return(&models.WalletSummary{
+		OpeningBalance:   money.MustParse("100.00"),
+		ClosingBalance:   money.MustParse("337.50"),
+		NetChange:        money.MustParse("237.50"),
+		TransactionCount: 6,
+		ByType: []models.TransactionTypeTotal{
+			{Type: models.TransactionTypeBonus, Count: 1, Total: money.MustParse("5.00")},
+			{Type: models.TransactionTypeDeposit, Count: 2, Total: money.MustParse("300.00")},
+			{Type: models.TransactionTypeFee, Count: 1, Total: money.MustParse("0.50")},
+			{Type: models.TransactionTypeInterest, Count: 1, Total: money.MustParse("3.00")},
+			{Type: models.TransactionTypeTransferOut, Count: 1, Total: money.MustParse("70.00")},
+		},
+	}, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{})
+	summary, err := service.GetWalletSummary(context.Background(), "user1", time.Time{}, time.Time{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustParse("300.00"), summary.Deposited)
+	assert.Equal(t, money.MustParse("5.00"), summary.Bonuses)
+	assert.Equal(t, money.MustParse("3.00"), summary.Interest)
+	assert.Equal(t, money.MustParse("70.00"), summary.TransferredOut)
+	assert.Equal(t, money.MustParse("0.50"), summary.Fees)
+	assert.Zero(t, summary.Withdrawn)
+	assert.Zero(t, summary.TransferredIn)
+	assert.Equal(t, "USD", summary.Currency)
+}
+
+func TestWalletService_GetWalletSummary_WalletNotFound(t *testing.T) {
+	mockWalletRepo := new(MockWalletRepo)
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(nil, ErrWalletNotFound)
+
+	service := NewWalletService(mockWalletRepo, new(MockTransactionRepo), nil, WalletServiceConfig{})
+	summary, err := service.GetWalletSummary(context.Background(), "user1", time.Time{}, time.Time{})
+
+	assert.Nil(t, summary)
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+}
+
+func TestWalletService_GetWalletTotals(t *testing.T) {
+	mockTxRepo := new(MockTransactionRepo)
+	mockTxRepo.On("GetWalletTotals", mock.Anything, "wallet1").Return(&models.WalletTotals{
+		TransactionCount: 5,
+		ByType: []models.TransactionTypeTotal{
+			{Type: models.TransactionTypeAdjustment, Count: 2, Total: money.MustParse("-2.50")},
+			{Type: models.TransactionTypeDeposit, Count: 2, Total: money.MustParse("250.00")},
+			{Type: models.TransactionTypeExchange, Count: 1, Total: money.MustParse("-25.00")},
+		},
+	}, nil)
+
+	service := NewWalletService(new(MockWalletRepo), mockTxRepo, nil, WalletServiceConfig{})
+	totals, err := service.GetWalletTotals(context.Background(), "wallet1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustParse("250.00"), totals.Deposited)
+	assert.Equal(t, money.MustParse("-2.50"), totals.Adjustments)
+	assert.Equal(t, money.MustParse("-25.00"), totals.Exchanged)
+	assert.Zero(t, totals.Withdrawn)
+	assert.Zero(t, totals.TransferredOut)
+	assert.Equal(t, 5, totals.TransactionCount)
+}
+
+func TestWalletService_GetWalletStatement_Month(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 18, 30, 0, 0, time.UTC)
+	createdAt := time.Date(2023, time.June, 20, 9, 0, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		assert.NoError(t, err)
+		return d
+	}
+
+	tests := []struct {
+		name        string
+		year        int
+		month       time.Month
+		wantFrom    string
+		wantEnd     string // exclusive end passed to the repository, empty when it is not queried
+		wantTo      string
+		expectedErr error
+	}{
+		{name: "current month", year: 2024, month: time.March, wantFrom: "2024-03-01", wantEnd: "2024-04-01", wantTo: "2024-03-31"},
+		{name: "leap february", year: 2024, month: time.February, wantFrom: "2024-02-01", wantEnd: "2024-03-01", wantTo: "2024-02-29"},
+		{name: "december ends at the new year", year: 2023, month: time.December, wantFrom: "2023-12-01", wantEnd: "2024-01-01", wantTo: "2023-12-31"},
+		{name: "month the wallet was created in", year: 2023, month: time.June, wantFrom: "2023-06-01", wantEnd: "2023-07-01", wantTo: "2023-06-30"},
+		{name: "before the wallet was created", year: 2023, month: time.May, wantFrom: "2023-05-01", wantTo: "2023-05-31"},
+		{name: "next month", year: 2024, month: time.April, expectedErr: ErrInvalidDateRange},
+		{name: "next year", year: 2025, month: time.January, expectedErr: ErrInvalidDateRange},
+		{name: "no such month", year: 2024, month: 13, expectedErr: ErrInvalidDateRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+			wallet := &models.Wallet{ID: uuid.New(), Currency: "USD", CreatedAt: createdAt}
+			mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(wallet, nil).Maybe()
+			if tt.wantEnd != "" {
+				mockTxRepo.On("GetTransactionSummary", mock.Anything, wallet.ID.String(), day(tt.wantFrom), day(tt.wantEnd)).
+					Return(&models.WalletSummary{ByType: []models.TransactionTypeTotal{}}, nil)
+				mockTxRepo.On("GetStatementTransactions", mock.Anything, wallet.ID.String(), day(tt.wantFrom), day(tt.wantEnd), 50, 0).
+					Return(nil, 0, nil)
+			}
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{})
+			service.now = func() time.Time { return now }
+			statement, err := service.GetWalletStatement(context.Background(), "user1", tt.year, tt.month, 50, 0)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				mockWalletRepo.AssertNotCalled(t, "GetWalletByUserID", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFrom, statement.From)
+			assert.Equal(t, tt.wantTo, statement.To)
+			assert.Equal(t, statement.OpeningBalance, statement.ClosingBalance)
+			assert.NotNil(t, statement.Transactions.Items)
+			if tt.wantEnd == "" {
+				mockTxRepo.AssertNotCalled(t, "GetTransactionSummary", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			mockTxRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletService_GetWalletStatement_PagesTransactions(t *testing.T) {
+	mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+	wallet := &models.Wallet{ID: uuid.New(), Currency: "USD"}
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(wallet, nil)
+	mockTxRepo.On("GetTransactionSummary", mock.Anything, wallet.ID.String(), mock.Anything, mock.Anything).Return(&models.WalletSummary{
+		OpeningBalance:   money.MustParse("100.00"),
+		ClosingBalance:   money.MustParse("130.00"),
+		NetChange:        money.MustParse("30.00"),
+		TransactionCount: 3,
+		ByType: []models.TransactionTypeTotal{
+			{Type: models.TransactionTypeDeposit, Count: 2, Total: money.MustParse("50.00")},
+			{Type: models.TransactionTypeWithdraw, Count: 1, Total: money.MustParse("20.00")},
+		},
+	}, nil)
+	page := []models.Transaction{
+		{ID: uuid.New(), Type: models.TransactionTypeDeposit, Amount: money.MustParse("20.00")},
+		{ID: uuid.New(), Type: models.TransactionTypeWithdraw, Amount: money.MustParse("20.00")},
+	}
+	mockTxRepo.On("GetStatementTransactions", mock.Anything, wallet.ID.String(), mock.Anything, mock.Anything, 2, 0).Return(page, 3, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{})
+	service.now = func() time.Time { return time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC) }
+	statement, err := service.GetWalletStatement(context.Background(), "user1", 2024, time.February, 2, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustParse("100.00"), statement.OpeningBalance)
+	assert.Equal(t, money.MustParse("130.00"), statement.ClosingBalance)
+	assert.Equal(t, 3, statement.TransactionCount)
+	assert.Len(t, statement.ByType, 2)
+	assert.Equal(t, page, statement.Transactions.Items)
+	assert.Equal(t, 3, statement.Transactions.Total)
+	assert.True(t, statement.Transactions.HasMore)
+	assert.Equal(t, 2024, statement.Year)
+	assert.Equal(t, 2, statement.Month)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/repositories"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrTransactionNotFound is returned when no transaction has the requested ID
+var ErrTransactionNotFound = repositories.ErrTransactionNotFound
+
+// ErrNotTransactionOwner is returned when a user asks for a transaction of a wallet
+// that belongs to another user
+var ErrNotTransactionOwner = errors.New("transaction belongs to another user")
+
+// GetTransaction returns a transaction of one of userID's wallets for its receipt. A
+// transfer comes with the username of the other user, and a completed transaction with
+// its wallet's balance right after it. Returns ErrNotTransactionOwner when the
+// transaction's wallet belongs to another user.
+func (s *WalletService) GetTransaction(ctx context.Context, userID, id string) (*models.TransactionDetail, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":      "get_transaction",
+		"transaction_id": id,
+	})
+	log.Info("Getting transaction")
+
+	t, err := s.transactionRepo.GetTransactionByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrTransactionNotFound) {
+			log.Warn("Transaction not found")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to get transaction")
+		}
+		return nil, err
+	}
+	wallet, err := s.walletRepo.GetWalletByID(ctx, t.WalletID.String())
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get the transaction's wallet")
+		return nil, err
+	}
+	if wallet.UserID.String() != userID {
+		log.WithField("owner_id", wallet.UserID).Warn("Transaction requested by another user")
+		return nil, fmt.Errorf("%w: %s", ErrNotTransactionOwner, id)
+	}
+
+	detail := &models.TransactionDetail{Transaction: *t}
+	if isTransferLeg(t.Type) && t.RelatedUserID != nil {
+		counterparty, err := s.walletRepo.GetUserByID(ctx, *t.RelatedUserID)
+		switch {
+		case err == nil:
+			detail.CounterpartyUsername = &counterparty.Username
+		case errors.Is(err, ErrUserNotFound):
+			// The counterparty's account was deleted; the receipt still stands
+			log.WithField("related_user_id", *t.RelatedUserID).Warn("Counterparty not found")
+		default:
+			log.WithField("error", err.Error()).Error("Failed to get counterparty")
+			return nil, err
+		}
+	}
+	if t.Status == models.TransactionStatusCompleted {
+		balance, err := s.transactionRepo.GetBalanceAfterTransaction(ctx, t)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to derive balance after transaction")
+			return nil, err
+		}
+		detail.BalanceAfter = &balance
+	}
+
+	log.Info("Transaction retrieved")
+	return detail, nil
+}
+
+// isTransferLeg reports whether transactions of type t move money between two users
+func isTransferLeg(t models.TransactionType) bool {
+	switch t {
+	case models.TransactionTypeTransferIn, models.TransactionTypeTransferOut,
+		models.TransactionTypeTransferReversalIn, models.TransactionTypeTransferReversalOut:
+		return true
+	}
+	return false
+}
+
+func GetTransaction(ctx context.Context, userID, id string) (*models.TransactionDetail, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GetTransaction(ctx, userID, id)
+}
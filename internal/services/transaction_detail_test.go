@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_GetTransaction(t *testing.T) {
+	owner, counterparty := uuid.New(), uuid.New()
+	wallet := &models.Wallet{ID: uuid.New(), UserID: owner}
+	related := counterparty.String()
+
+	tests := []struct {
+		name             string
+		requester        uuid.UUID
+		tx               *models.Transaction
+		lookupErr        error
+		counterpartyErr  error
+		wantUsername     string
+		wantBalanceAfter bool
+		expectedErr      error
+	}{
+		{
+			name:             "transfer",
+			requester:        owner,
+			tx:               &models.Transaction{Type: models.TransactionTypeTransferOut, Status: models.TransactionStatusCompleted, RelatedUserID: &related},
+			wantUsername:     "janedoe",
+			wantBalanceAfter: true,
+		},
+		{
+			name:             "deposit",
+			requester:        owner,
+			tx:               &models.Transaction{Type: models.TransactionTypeDeposit, Status: models.TransactionStatusCompleted},
+			wantBalanceAfter: true,
+		},
+		{
+			name:      "pending deposit",
+			requester: owner,
+			tx:        &models.Transaction{Type: models.TransactionTypeDeposit, Status: models.TransactionStatusPending},
+		},
+		{
+			name:             "transfer from a deleted user",
+			requester:        owner,
+			tx:               &models.Transaction{Type: models.TransactionTypeTransferIn, Status: models.TransactionStatusCompleted, RelatedUserID: &related},
+			counterpartyErr:  repositories.ErrUserNotFound,
+			wantBalanceAfter: true,
+		},
+		{
+			name:        "another user's transaction",
+			requester:   counterparty,
+			tx:          &models.Transaction{Type: models.TransactionTypeDeposit, Status: models.TransactionStatusCompleted},
+			expectedErr: ErrNotTransactionOwner,
+		},
+		{
+			name:        "unknown transaction",
+			requester:   owner,
+			lookupErr:   repositories.ErrTransactionNotFound,
+			expectedErr: ErrTransactionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo := new(MockWalletRepo), new(MockTransactionRepo)
+			id := uuid.New()
+			if tt.tx != nil {
+				tt.tx.ID, tt.tx.WalletID = id, wallet.ID
+				mockTxRepo.On("GetTransactionByID", mock.Anything, id.String()).Return(tt.tx, nil)
+			} else {
+				mockTxRepo.On("GetTransactionByID", mock.Anything, id.String()).Return(nil, tt.lookupErr)
+			}
+			mockWalletRepo.On("GetWalletByID", mock.Anything, wallet.ID.String()).Return(wallet, nil).Maybe()
+			if tt.counterpartyErr != nil {
+				mockWalletRepo.On("GetUserByID", mock.Anything, related).Return(nil, tt.counterpartyErr).Maybe()
+			} else {
+				mockWalletRepo.On("GetUserByID", mock.Anything, related).Return(&models.User{Username: "janedoe"}, nil).Maybe()
+			}
+			mockTxRepo.On("GetBalanceAfterTransaction", mock.Anything, mock.Anything).Return(money.MustParse("75.00"), nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, nil, WalletServiceConfig{})
+			detail, err := service.GetTransaction(context.Background(), tt.requester.String(), id.String())
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, detail)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, id, detail.ID)
+			if tt.wantUsername != "" {
+				if assert.NotNil(t, detail.CounterpartyUsername) {
+					assert.Equal(t, tt.wantUsername, *detail.CounterpartyUsername)
+				}
+			} else {
+				assert.Nil(t, detail.CounterpartyUsername)
+			}
+			if tt.wantBalanceAfter {
+				if assert.NotNil(t, detail.BalanceAfter) {
+					assert.Equal(t, money.MustParse("75.00"), *detail.BalanceAfter)
+				}
+			} else {
+				assert.Nil(t, detail.BalanceAfter)
+				mockTxRepo.AssertNotCalled(t, "GetBalanceAfterTransaction", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTransferIntentTTL is how long a transfer intent can be confirmed unless
+// configured otherwise
+const defaultTransferIntentTTL = 5 * time.Minute
+
+// Failures returned when confirming a transfer intent
+var (
+	ErrTransferIntentNotFound  = repositories.ErrTransferIntentNotFound
+	ErrTransferIntentConfirmed = errors.New("transfer intent was already confirmed")
+	ErrTransferIntentExpired   = errors.New("transfer intent has expired")
+)
+
+// CreateTransferIntent checks that a transfer of amount from the default wallet of
+// fromUserID to the default wallet of toUserID would go through right now, and stores
+// it as a PENDING intent that ConfirmTransferIntent can execute until it expires. The
+// checks are the ones Transfer makes: both wallets exist, are active and hold the same
+// currency, and the sender can cover the amount and fee within its velocity and
+// monthly limits. Nothing is reserved, so confirming checks everything again.
+func (s *WalletService) CreateTransferIntent(ctx context.Context, fromUserID, toUserID string, amount money.Amount) (*models.TransferIntent, error) {
+	log := logger.WithFields(logrus.Fields{
+		"from_user_id": fromUserID,
+		"to_user_id":   toUserID,
+		"amount":       amount,
+		"operation":    "create_transfer_intent",
+	}).WithContext(ctx)
+	log.Info("Creating transfer intent")
+
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Transfer intent validation failed")
+		return nil, err
+	}
+	if fromUserID == toUserID {
+		log.Warn("Self-transfer intent blocked")
+		return nil, ErrSelfTransfer
+	}
+	fromID, err := uuid.Parse(fromUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from_user_id: %w", err)
+	}
+	toID, err := uuid.Parse(toUserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to_user_id: %w", err)
+	}
+
+	fee := s.transferFee(fromUserID, amount)
+	if err = s.checkTransfer(ctx, log, defaultWallet(fromUserID), defaultWallet(toUserID), amount, fee); err != nil {
+		return nil, err
+	}
+
+	intent := &models.TransferIntent{
+		FromUserID: fromID,
+		ToUserID:   toID,
+		Amount:     amount,
+		Fee:        fee,
+		Status:     models.TransferIntentStatusPending,
+		ExpiresAt:  s.now().Add(s.intentTTL),
+	}
+	if err = s.transactionRepo.CreateTransferIntent(ctx, intent); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to create transfer intent")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"transfer_intent_id": intent.ID.String(),
+		"expires_at":         intent.ExpiresAt,
+	}).Info("Transfer intent created successfully")
+	return intent, nil
+}
+
+// checkTransfer reads the wallets a transfer would touch, without changing or locking
+// them, and returns the error the transfer would fail with now, if any
+func (s *WalletService) checkTransfer(ctx context.Context, log *logrus.Entry, from, to walletRef, amount, fee money.Amount) (err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return err
+	}
+	defer func() {
+		err = finishTx(ctx, log, tx, "check transfer", err)
+	}()
+
+	fromWallet, err := s.getWalletTx(ctx, tx, from)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to get from wallet")
+		return err
+	}
+	if err = checkActive(fromWallet, from); err != nil {
+		log.WithField("status", fromWallet.Status).Warn("Transfer intent rejected, from wallet is not active")
+		return err
+	}
+	if err = checkPrecision(fromWallet, amount); err != nil {
+		log.WithField("currency", fromWallet.Currency).Warn("Transfer intent rejected, amount does not fit the wallet's currency")
+		return err
+	}
+	// The same rule debit applies: held funds cannot be spent, the overdraft can
+	if fromWallet.Balance-amount-fee-fromWallet.HeldAmount < -fromWallet.OverdraftLimit {
+		log.Warn("Insufficient balance for transfer intent")
+		return fmt.Errorf("%w: %s cannot cover %s", ErrInsufficientBalance, from, amount+fee)
+	}
+	if err = s.checkVelocity(ctx, tx, fromWallet); err != nil {
+		log.WithField("error", err.Error()).Warn("Too many operations on the from wallet")
+		return err
+	}
+	if err = s.checkMonthlyTransferLimit(ctx, tx, fromWallet, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Monthly transfer limit exceeded")
+		return err
+	}
+	if err = s.checkKycLimits(ctx, tx, from.userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Transfer intent rejected by KYC tier limits")
+		return err
+	}
+
+	toWallet, err := s.getWalletTx(ctx, tx, to)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to get to wallet")
+		return err
+	}
+	if err = checkActive(toWallet, to); err != nil {
+		log.WithField("status", toWallet.Status).Warn("Transfer intent rejected, to wallet is not active")
+		return err
+	}
+	if toWallet.Currency != fromWallet.Currency {
+		log.WithFields(logrus.Fields{"from_currency": fromWallet.Currency, "to_currency": toWallet.Currency}).Warn("Transfer intent rejected, wallets hold different currencies")
+		return fmt.Errorf("%w: %s holds %s, %s holds %s", ErrCurrencyMismatch, from, fromWallet.Currency, to, toWallet.Currency)
+	}
+	if feeRef := defaultWallet(s.fees.WalletUserID); fee > 0 && feeRef != to {
+		feeWallet, err := s.getWalletTx(ctx, tx, feeRef)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to get fee wallet")
+			return err
+		}
+		if feeWallet.Currency != fromWallet.Currency {
+			log.WithFields(logrus.Fields{"from_currency": fromWallet.Currency, "fee_currency": feeWallet.Currency}).Warn("Transfer intent rejected, fees are collected in another currency")
+			return fmt.Errorf("%w: fees are collected in %s, %s holds %s", ErrCurrencyMismatch, feeWallet.Currency, from, fromWallet.Currency)
+		}
+	}
+	return nil
+}
+
+// ConfirmTransferIntent executes a PENDING transfer intent before it expires, running
+// every check of Transfer again, and marks it CONFIRMED. The transfer and the marking
+// happen in one database transaction, with the intent row-locked from the start, and
+// the transfer runs under an idempotency key derived from the intent's ID, so
+// confirming an intent twice transfers once: the second attempt returns
+// ErrTransferIntentConfirmed.
+func (s *WalletService) ConfirmTransferIntent(ctx context.Context, intentID string) (*models.TransferIntent, error) {
+	log := logger.WithFields(logrus.Fields{
+		"transfer_intent_id": intentID,
+		"operation":          "confirm_transfer_intent",
+	}).WithContext(ctx)
+	log.Info("Confirming transfer intent")
+
+	// Both legs of the transfer share one ID, kept stable across retries
+	transferID := uuid.New()
+	log = log.WithField("transfer_id", transferID.String())
+	ctx = withLogger(ctx, log)
+
+	var intent *models.TransferIntent
+	var events walletEvents
+	err := s.retryTx(ctx, log, func() error {
+		events = walletEvents{}
+		return withTx(ctx, s.db, log, "confirm transfer intent", func(tx pgx.Tx) (err error) {
+			intent, err = s.confirmTransferIntent(ctx, tx, log, intentID, transferID, &events)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, intent.FromUserID.String(), intent.ToUserID.String())
+	if intent.Fee > 0 {
+		s.invalidateWallets(ctx, s.fees.WalletUserID)
+	}
+	s.notify(ctx, events)
+
+	log.Info("Transfer intent confirmed successfully")
+	return intent, nil
+}
+
+// confirmTransferIntent runs a single attempt of ConfirmTransferIntent in tx,
+// collecting what it will notify in events
+func (s *WalletService) confirmTransferIntent(ctx context.Context, tx pgx.Tx, log *logrus.Entry, intentID string, transferID uuid.UUID, events *walletEvents) (*models.TransferIntent, error) {
+	intent, err := s.transactionRepo.GetTransferIntentForUpdateTx(ctx, tx, intentID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to get transfer intent")
+		return nil, err
+	}
+	switch {
+	case intent.Status == models.TransferIntentStatusConfirmed:
+		err = ErrTransferIntentConfirmed
+	case intent.Status == models.TransferIntentStatusExpired, !s.now().Before(intent.ExpiresAt):
+		err = ErrTransferIntentExpired
+	}
+	if err != nil {
+		log.WithField("status", intent.Status).Warn("Transfer intent can no longer be confirmed")
+		return nil, fmt.Errorf("%w: %s", err, intentID)
+	}
+	// The amount limits may have changed since the intent was created
+	if err = s.ValidateAmount(intent.Amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Transfer intent validation failed")
+		return nil, err
+	}
+
+	from, to := defaultWallet(intent.FromUserID.String()), defaultWallet(intent.ToUserID.String())
+	key := "transfer-intent:" + intent.ID.String()
+	result, err := s.transfer(ctx, tx, log, transferID, from, to, intent.Amount, s.transferFee(from.userID, intent.Amount), key, nil, nil, events)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Transfer intent could not be executed")
+		return nil, err
+	}
+
+	intent.Status = models.TransferIntentStatusConfirmed
+	intent.TransferID = &result.TransferID
+	intent.Fee = result.Fee
+	if err = s.transactionRepo.UpdateTransferIntentTx(ctx, tx, intent); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to mark transfer intent confirmed")
+		return nil, err
+	}
+	return intent, nil
+}
+
+// ExpireTransferIntents marks pending transfer intents past their expiry as EXPIRED
+// and returns how many it expired
+func (s *WalletService) ExpireTransferIntents(ctx context.Context) (int64, error) {
+	return s.transactionRepo.ExpireTransferIntents(ctx)
+}
+
+func CreateTransferIntent(ctx context.Context, fromUserID, toUserID string, amount money.Amount) (*models.TransferIntent, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.CreateTransferIntent(ctx, fromUserID, toUserID, amount)
+}
+
+func ConfirmTransferIntent(ctx context.Context, intentID string) (*models.TransferIntent, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.ConfirmTransferIntent(ctx, intentID)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_CreateTransferIntent(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	sender, recipient := uuid.New(), uuid.New()
+	feeUserID := uuid.New().String()
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetWalletByUserIDTx", mock.Anything, mock.Anything, sender.String()).
+		Return(&models.Wallet{ID: uuid.New(), Balance: 1050, Currency: "USD"}, nil)
+	mockWalletRepo.On("GetWalletByUserIDTx", mock.Anything, mock.Anything, recipient.String()).
+		Return(&models.Wallet{ID: uuid.New(), Currency: "USD"}, nil)
+	mockWalletRepo.On("GetWalletByUserIDTx", mock.Anything, mock.Anything, feeUserID).
+		Return(&models.Wallet{ID: uuid.New(), Currency: "USD"}, nil)
+	mockTxRepo.On("CreateTransferIntent", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(1).(*models.TransferIntent).ID = uuid.New() }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		Fees:              FeePolicy{Flat: 50, WalletUserID: feeUserID},
+		TransferIntentTTL: 2 * time.Minute,
+	})
+	service.now = func() time.Time { return now }
+	intent, err := service.CreateTransferIntent(context.Background(), sender.String(), recipient.String(), 1000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.TransferIntentStatusPending, intent.Status)
+	assert.Equal(t, sender, intent.FromUserID)
+	assert.Equal(t, recipient, intent.ToUserID)
+	assert.Equal(t, money.Amount(1000), intent.Amount)
+	assert.Equal(t, money.Amount(50), intent.Fee)
+	assert.Equal(t, now.Add(2*time.Minute), intent.ExpiresAt)
+	mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_CreateTransferIntent_Rejected(t *testing.T) {
+	sender := uuid.New().String()
+	recipient := uuid.New().String()
+
+	tests := []struct {
+		name            string
+		senderWallet    *models.Wallet
+		recipientWallet *models.Wallet
+		recipientErr    error
+		expectedErr     error
+	}{
+		{"insufficient balance", &models.Wallet{ID: uuid.New(), Balance: 999, Currency: "USD"}, &models.Wallet{ID: uuid.New(), Currency: "USD"}, nil, ErrInsufficientBalance},
+		{"held funds", &models.Wallet{ID: uuid.New(), Balance: 1500, HeldAmount: 600, Currency: "USD"}, &models.Wallet{ID: uuid.New(), Currency: "USD"}, nil, ErrInsufficientBalance},
+		{"frozen sender", &models.Wallet{ID: uuid.New(), Balance: 5000, Status: models.WalletStatusFrozen, Currency: "USD"}, &models.Wallet{ID: uuid.New(), Currency: "USD"}, nil, ErrWalletFrozen},
+		{"closed recipient", &models.Wallet{ID: uuid.New(), Balance: 5000, Currency: "USD"}, &models.Wallet{ID: uuid.New(), Status: models.WalletStatusClosed, Currency: "USD"}, nil, ErrWalletClosed},
+		{"missing recipient", &models.Wallet{ID: uuid.New(), Balance: 5000, Currency: "USD"}, nil, repositories.ErrWalletNotFound, ErrWalletNotFound},
+		{"other currency", &models.Wallet{ID: uuid.New(), Balance: 5000, Currency: "USD"}, &models.Wallet{ID: uuid.New(), Currency: "EUR"}, nil, ErrCurrencyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			mockDB.ExpectRollback()
+			mockWalletRepo.On("GetWalletByUserIDTx", mock.Anything, mock.Anything, sender).Return(tt.senderWallet, nil)
+			mockWalletRepo.On("GetWalletByUserIDTx", mock.Anything, mock.Anything, recipient).Return(tt.recipientWallet, tt.recipientErr).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			intent, err := service.CreateTransferIntent(context.Background(), sender, recipient, 1000)
+
+			assert.Nil(t, intent)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			mockTxRepo.AssertNotCalled(t, "CreateTransferIntent", mock.Anything, mock.Anything)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_CreateTransferIntent_Validation(t *testing.T) {
+	user := uuid.New().String()
+	service := NewWalletService(nil, nil, nil, WalletServiceConfig{})
+
+	_, err := service.CreateTransferIntent(context.Background(), user, user, 1000)
+	assert.ErrorIs(t, err, ErrSelfTransfer)
+
+	_, err = service.CreateTransferIntent(context.Background(), user, uuid.New().String(), 0)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestWalletService_ConfirmTransferIntent(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	intent := &models.TransferIntent{
+		ID:         uuid.New(),
+		FromUserID: uuid.New(),
+		ToUserID:   uuid.New(),
+		Amount:     1250,
+		Status:     models.TransferIntentStatusPending,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}
+	sender, recipient := intent.FromUserID.String(), intent.ToUserID.String()
+
+	// The transfer runs in the transaction that locks and marks the intent
+	mockDB.ExpectBegin()
+	mockTxRepo.On("GetTransferIntentForUpdateTx", mock.Anything, mock.Anything, intent.ID.String()).Return(intent, nil)
+	mockTxRepo.On("GetTransactionByIdempotencyKeyTx", mock.Anything, mock.Anything, sender, "transfer-intent:"+intent.ID.String()).
+		Return(nil, repositories.ErrTransactionNotFound)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, sender, money.Amount(1250)).Return(&models.Wallet{ID: uuid.New()}, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, recipient, money.Amount(1250)).Return(&models.Wallet{ID: uuid.New()}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockTxRepo.On("UpdateTransferIntentTx", mock.Anything, mock.Anything, intent).Return(nil)
+	mockDB.ExpectCommit()
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	confirmed, err := service.ConfirmTransferIntent(context.Background(), intent.ID.String())
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.TransferIntentStatusConfirmed, confirmed.Status)
+	assert.NotNil(t, confirmed.TransferID)
+	mockTxRepo.AssertExpectations(t)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_ConfirmTransferIntent_MarkFails(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	intent := &models.TransferIntent{
+		ID:         uuid.New(),
+		FromUserID: uuid.New(),
+		ToUserID:   uuid.New(),
+		Amount:     1250,
+		Status:     models.TransferIntentStatusPending,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}
+	sender, recipient := intent.FromUserID.String(), intent.ToUserID.String()
+
+	// Failing to mark the intent rolls the transfer back with it
+	mockDB.ExpectBegin()
+	mockTxRepo.On("GetTransferIntentForUpdateTx", mock.Anything, mock.Anything, intent.ID.String()).Return(intent, nil)
+	mockTxRepo.On("GetTransactionByIdempotencyKeyTx", mock.Anything, mock.Anything, sender, "transfer-intent:"+intent.ID.String()).
+		Return(nil, repositories.ErrTransactionNotFound)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, sender, money.Amount(1250)).Return(&models.Wallet{ID: uuid.New()}, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, recipient, money.Amount(1250)).Return(&models.Wallet{ID: uuid.New()}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockTxRepo.On("UpdateTransferIntentTx", mock.Anything, mock.Anything, intent).Return(errors.New("connection lost"))
+	mockDB.ExpectRollback()
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	confirmed, err := service.ConfirmTransferIntent(context.Background(), intent.ID.String())
+
+	assert.Nil(t, confirmed)
+	assert.ErrorContains(t, err, "connection lost")
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_ConfirmTransferIntent_Rejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      models.TransferIntentStatus
+		expiresIn   time.Duration
+		expectedErr error
+	}{
+		{"already confirmed", models.TransferIntentStatusConfirmed, time.Minute, ErrTransferIntentConfirmed},
+		{"expired", models.TransferIntentStatusExpired, -time.Minute, ErrTransferIntentExpired},
+		{"past expiry, not yet swept", models.TransferIntentStatusPending, -time.Second, ErrTransferIntentExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			intent := &models.TransferIntent{
+				ID:         uuid.New(),
+				FromUserID: uuid.New(),
+				ToUserID:   uuid.New(),
+				Amount:     1250,
+				Status:     tt.status,
+				ExpiresAt:  time.Now().Add(tt.expiresIn),
+			}
+			mockDB.ExpectBegin()
+			mockDB.ExpectRollback()
+			mockTxRepo.On("GetTransferIntentForUpdateTx", mock.Anything, mock.Anything, intent.ID.String()).Return(intent, nil)
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			confirmed, err := service.ConfirmTransferIntent(context.Background(), intent.ID.String())
+
+			assert.Nil(t, confirmed)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockTxRepo.AssertNotCalled(t, "UpdateTransferIntentTx", mock.Anything, mock.Anything, mock.Anything)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_ConfirmTransferIntent_NotFound(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	id := uuid.New().String()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockTxRepo.On("GetTransferIntentForUpdateTx", mock.Anything, mock.Anything, id).Return(nil, repositories.ErrTransferIntentNotFound)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	_, err = service.ConfirmTransferIntent(context.Background(), id)
+
+	assert.ErrorIs(t, err, ErrTransferIntentNotFound)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
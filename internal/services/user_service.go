@@ -2,45 +2,609 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
 	"walletapp/internal/logger"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
 	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Metadata recorded on the BONUS transaction paid for a referral
+const (
+	// ReferralCampaign is the campaign of referral bonuses
+	ReferralCampaign = "referral"
+	// ReferredUserKey links a referral bonus to the user who signed up
+	ReferredUserKey = "referred_user_id"
 )
 
-func CreateUserWithWallet(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-	log := logger.Get()
+// ErrInvalidReferralCode is returned when a signup names a referral code that no user
+// has, or whose owner cannot be paid the referral bonus
+var ErrInvalidReferralCode = errors.New("invalid referral code")
+
+// ErrEmailTaken is returned when another user already has the requested email
+var ErrEmailTaken = repositories.ErrEmailTaken
+
+// ErrUsernameTaken is returned when another user already has the requested username
+var ErrUsernameTaken = repositories.ErrUsernameTaken
+
+// ErrUserHasBalance is returned when deleting a user with money in, or owed by, any of
+// their wallets
+var ErrUserHasBalance = errors.New("user has a non-zero balance")
+
+// ErrInvalidUserUpdate is returned for user updates that change nothing or set a
+// field to an invalid value
+var ErrInvalidUserUpdate = errors.New("invalid user update")
+
+// UserRepo reads, creates, updates and restores users, and changes their passwords,
+// outside a database transaction
+type UserRepo interface {
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	IsEmailExists(ctx context.Context, email string) (bool, error)
+	IsUsernameExists(ctx context.Context, username string) (bool, error)
+	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
+	UpdateUser(ctx context.Context, id string, fields *models.UpdateUserRequest) (*models.User, error)
+	RestoreUser(ctx context.Context, id string) (*models.User, error)
+	GetUserCredentialsByID(ctx context.Context, id string) (*models.User, error)
+	UpdateUserPassword(ctx context.Context, id, passwordHash string) error
+	RecordPasswordFailure(ctx context.Context, id string) error
+	CountPasswordFailuresSince(ctx context.Context, id string, since time.Time) (int, *time.Time, error)
+}
+
+// UserTxRepo creates and deletes users, and records who referred them, inside a
+// caller-owned database transaction
+type UserTxRepo interface {
+	IsEmailExistsTx(ctx context.Context, tx pgx.Tx, email string) (bool, error)
+	IsUsernameExistsTx(ctx context.Context, tx pgx.Tx, username string) (bool, error)
+	CreateUserTx(ctx context.Context, tx pgx.Tx, req *models.CreateUserRequest) (*models.User, error)
+	GetUserByReferralCodeTx(ctx context.Context, tx pgx.Tx, code string) (*models.User, error)
+	CreateReferralTx(ctx context.Context, tx pgx.Tx, referral *models.Referral) error
+	SoftDeleteUserTx(ctx context.Context, tx pgx.Tx, id string) error
+}
+
+// RegistrationService creates new users together with their wallets, and deletes them
+// again
+type RegistrationService struct {
+	userRepo     UserTxRepo
+	wallets      *WalletService
+	db           DB
+	verification *EmailVerificationService
+}
+
+// NewRegistrationService creates a new RegistrationService. Wallets are created and
+// funded through the wallet service's repositories and amount limits, and new users
+// are emailed a token to verify their email through verification; with a nil
+// verification they are sent none.
+func NewRegistrationService(userRepo UserTxRepo, wallets *WalletService, db DB, verification *EmailVerificationService) *RegistrationService {
+	return &RegistrationService{
+		userRepo:     userRepo,
+		wallets:      wallets,
+		db:           db,
+		verification: verification,
+	}
+}
+
+// CreateUserWithWallet inserts a user and their wallet in one database transaction,
+// so a failure at any step leaves no rows behind. A non-zero req.InitialBalance is
+// credited to the new wallet as a DEPOSIT within the same transaction. When
+// req.ReferralCode is set, the referral is recorded and the referrer is credited the
+// referral bonus in that transaction too, so the signup fails with
+// ErrInvalidReferralCode unless the referrer can be paid. The email is stored
+// lowercase, and ErrEmailTaken or ErrUsernameTaken is returned when another user
+// already has the email, in any case, or the username. Once the user is created they
+// are emailed a token to verify their email; failing to send it is only logged, as
+// they can ask for another.
+func (s *RegistrationService) CreateUserWithWallet(ctx context.Context, req *models.CreateUserRequest) (user *models.User, wallet *models.Wallet, err error) {
+	normalized := *req
+	normalized.Email = normalizeEmail(req.Email)
+	req = &normalized
+
+	log := logger.WithOperation("create_user_with_wallet").WithContext(ctx).WithFields(logrus.Fields{
+		"username":        req.Username,
+		"email":           req.Email,
+		"initial_balance": req.InitialBalance.String(),
+		"referral_code":   req.ReferralCode,
+	})
+	log.Info("Creating new user with wallet")
+
+	if req.InitialBalance != 0 {
+		if err := s.wallets.ValidateAmount(req.InitialBalance); err != nil {
+			log.WithField("error", err.Error()).Warn("Invalid initial balance")
+			return nil, nil, err
+		}
+	}
+	referralCode := strings.ToUpper(strings.TrimSpace(req.ReferralCode))
+
+	// Paying a referrer changes an existing wallet, which can conflict with concurrent
+	// updates to it
+	var verify *verificationEmail
+	var referrerID string
+	err = s.wallets.retryTx(ctx, log, func() (err error) {
+		referrerID = ""
+		user, wallet, verify, err = s.createUserWithWallet(ctx, log, req, referralCode, &referrerID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.wallets.invalidateWallets(ctx, referrerID)
+
+	if verify != nil {
+		log = log.WithField("user_id", user.ID.String())
+		if err := s.verification.send(ctx, verify); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to send verification email")
+		} else {
+			log.Info("Verification email sent")
+		}
+	}
+	return user, wallet, nil
+}
+
+// createUserWithWallet runs a single attempt of CreateUserWithWallet inside its own
+// database transaction, and returns the verification email to send once it committed.
+// The referrer, if any, is recorded in referrerID, so their wallet is invalidated.
+func (s *RegistrationService) createUserWithWallet(ctx context.Context, log *logrus.Entry, req *models.CreateUserRequest, referralCode string, referrerID *string) (user *models.User, wallet *models.Wallet, verify *verificationEmail, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, nil, nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "create user", err); err != nil {
+			user, wallet, verify = nil, nil, nil
+		}
+	}()
+
+	exists, err := s.userRepo.IsEmailExistsTx(ctx, tx, req.Email)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check email")
+		return nil, nil, nil, err
+	}
+	if exists {
+		log.Warn("Email already in use")
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrEmailTaken, req.Email)
+	}
+	exists, err = s.userRepo.IsUsernameExistsTx(ctx, tx, req.Username)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check username")
+		return nil, nil, nil, err
+	}
+	if exists {
+		log.Warn("Username already in use")
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrUsernameTaken, req.Username)
+	}
+
+	var referrer *models.User
+	if referralCode != "" {
+		referrer, err = s.userRepo.GetUserByReferralCodeTx(ctx, tx, referralCode)
+		if errors.Is(err, ErrUserNotFound) {
+			log.Warn("Unknown referral code")
+			return nil, nil, nil, fmt.Errorf("%w: %s", ErrInvalidReferralCode, referralCode)
+		}
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to look up referral code")
+			return nil, nil, nil, err
+		}
+	}
+
+	// The unique indexes still catch a user signing up with the same email or username
+	// after the checks above
+	user, err = s.userRepo.CreateUserTx(ctx, tx, req)
+	if err != nil {
+		if errors.Is(err, ErrEmailTaken) || errors.Is(err, ErrUsernameTaken) {
+			log.WithField("error", err.Error()).Warn("User created concurrently with the same email or username")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to create user")
+		}
+		return nil, nil, nil, err
+	}
+
+	log = log.WithField("user_id", user.ID.String())
+	log.Info("User created, creating wallet")
+
+	wallet, err = s.wallets.walletRepo.CreateWalletTx(ctx, tx, user.ID.String())
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to create wallet for user")
+		return nil, nil, nil, err
+	}
+
+	if req.InitialBalance != 0 {
+		wallet, err = s.fundWallet(ctx, tx, user.ID.String(), req.InitialBalance)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to credit initial balance")
+			return nil, nil, nil, err
+		}
+	}
+
+	if referrer != nil {
+		if err = s.recordReferral(ctx, log.WithField("referrer_id", referrer.ID.String()), tx, referrer, user); err != nil {
+			return nil, nil, nil, err
+		}
+		*referrerID = referrer.ID.String()
+	}
+
+	if s.verification != nil {
+		verify, err = s.verification.issueTx(ctx, tx, user)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to save email verification token")
+			return nil, nil, nil, err
+		}
+	}
+
+	log.Info("User and wallet created successfully")
+	return user, wallet, verify, nil
+}
+
+// recordReferral records that referrer referred the just-created user and credits the
+// referrer's default wallet the referral bonus, if one is configured, as a BONUS
+// transaction naming the new user in its metadata
+func (s *RegistrationService) recordReferral(ctx context.Context, log *logrus.Entry, tx pgx.Tx, referrer, user *models.User) error {
+	// A new user has no code anyone could have shared, but a user must never be paid
+	// for referring themselves
+	if referrer.ID == user.ID {
+		log.Warn("Self referral rejected")
+		return fmt.Errorf("%w: users cannot refer themselves", ErrInvalidReferralCode)
+	}
+
+	referral := &models.Referral{ReferredUserID: user.ID, ReferrerID: referrer.ID}
+	if bonus := s.wallets.referralBonus; bonus > 0 {
+		ref := defaultWallet(referrer.ID.String())
+		if err := s.wallets.lockWallets(ctx, tx, ref.userID); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to lock referrer wallet")
+			return err
+		}
+		credited, err := s.wallets.creditBonus(ctx, tx, log, ref, bonus, map[string]string{
+			BonusCampaignKey: ReferralCampaign,
+			ReferredUserKey:  user.ID.String(),
+		})
+		if errors.Is(err, ErrWalletFrozen) || errors.Is(err, ErrWalletClosed) {
+			return fmt.Errorf("%w: the referrer cannot receive the referral bonus", ErrInvalidReferralCode)
+		}
+		if err != nil {
+			return err
+		}
+		referral.TransactionID = &credited.ID
+		referral.Bonus = bonus
+	}
+
+	if err := s.userRepo.CreateReferralTx(ctx, tx, referral); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record referral")
+		return err
+	}
+	log.WithField("bonus", referral.Bonus).Info("Referral recorded")
+	return nil
+}
+
+// fundWallet credits a just-created wallet with its initial balance and records the
+// DEPOSIT and its ledger entries. The wallet is only visible to tx, so it is not locked.
+func (s *RegistrationService) fundWallet(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error) {
+	wallet, err := s.wallets.walletRepo.CreditWalletTx(ctx, tx, userID, amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrecision(wallet, amount); err != nil {
+		return nil, err
+	}
+
+	description := "Initial balance"
+	err = s.wallets.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+		WalletID:    wallet.ID,
+		Type:        models.TransactionTypeDeposit,
+		Status:      models.TransactionStatusCompleted,
+		Amount:      amount,
+		Description: &description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	groupID := uuid.New()
+	err = s.wallets.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		cashLedgerEntry(groupID, models.LedgerDebit, amount),
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerCredit, amount),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// DeleteUser deletes a user and closes all their wallets in one database transaction.
+// The user and the wallets are soft-deleted, and their transactions are kept for audit. Returns
+// ErrUserHasBalance, naming the balance, while any of the user's wallets has a balance
+// or held funds, and ErrUserNotFound for an unknown or already deleted user.
+func (s *RegistrationService) DeleteUser(ctx context.Context, userID string) error {
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "delete_user")
+	log.Info("Deleting user")
 
-	log.WithFields(map[string]interface{}{
+	err := s.wallets.retryTx(ctx, log, func() error {
+		return s.deleteUser(ctx, log, userID)
+	})
+	if err != nil {
+		return err
+	}
+	s.wallets.invalidateWallets(ctx, userID)
+	return nil
+}
+
+// deleteUser runs a single attempt of DeleteUser inside its own database transaction
+func (s *RegistrationService) deleteUser(ctx context.Context, log *logrus.Entry, userID string) (err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return err
+	}
+	defer func() {
+		err = finishTx(ctx, log, tx, "delete user", err)
+	}()
+
+	if err = s.wallets.lockWallets(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallets")
+		return err
+	}
+	if err = s.userRepo.SoftDeleteUserTx(ctx, tx, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			log.Warn("User not found")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to delete user")
+		}
+		return err
+	}
+
+	wallets, err := s.wallets.walletRepo.GetWalletsByUserIDForUpdateTx(ctx, tx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallets")
+		return err
+	}
+	for _, w := range wallets {
+		if w.Balance != 0 || w.HeldAmount != 0 {
+			log.WithFields(logrus.Fields{
+				"wallet_id":   w.ID.String(),
+				"balance":     w.Balance,
+				"held_amount": w.HeldAmount,
+			}).Warn("Deleting a user with a non-zero balance rejected")
+			return fmt.Errorf("%w: wallet %q has a balance of %s %s with %s held", ErrUserHasBalance, w.Name, w.Balance, w.Currency, w.HeldAmount)
+		}
+	}
+
+	closed, err := s.wallets.walletRepo.CloseWalletsByUserIDTx(ctx, tx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to close wallets")
+		return err
+	}
+
+	log.WithField("closed_wallets", len(closed)).Info("User deleted successfully")
+	return nil
+}
+
+// UserService manages the details of existing users
+type UserService struct {
+	repo    UserRepo
+	wallets *WalletService
+	// now tells the time password failures are counted against
+	now func() time.Time
+}
+
+// NewUserService creates a new UserService. The cached wallets of restored users are
+// dropped through wallets.
+func NewUserService(repo UserRepo, wallets *WalletService) *UserService {
+	return &UserService{repo: repo, wallets: wallets, now: time.Now}
+}
+
+// CreateUser inserts a user on its own, without a wallet. The email is stored
+// lowercase. Returns ErrEmailTaken or ErrUsernameTaken when another user already has
+// the email, in any case, or the username.
+func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	normalized := *req
+	normalized.Email = normalizeEmail(req.Email)
+	req = &normalized
+
+	log := logger.WithOperation("create_user").WithContext(ctx).WithFields(logrus.Fields{
 		"username": req.Username,
 		"email":    req.Email,
-	}).Info("Creating new user with wallet")
+	})
+
+	exists, err := s.repo.IsEmailExists(ctx, req.Email)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check email")
+		return nil, err
+	}
+	if exists {
+		log.Warn("Email already in use")
+		return nil, fmt.Errorf("%w: %s", ErrEmailTaken, req.Email)
+	}
+	exists, err = s.repo.IsUsernameExists(ctx, req.Username)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check username")
+		return nil, err
+	}
+	if exists {
+		log.Warn("Username already in use")
+		return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, req.Username)
+	}
 
-	user, err := repositories.CreateUser(ctx, req)
+	user, err := s.repo.CreateUser(ctx, req)
 	if err != nil {
-		log.WithError(err).WithFields(map[string]interface{}{
-			"username": req.Username,
-			"email":    req.Email,
-		}).Error("Failed to create user")
+		log.WithField("error", err.Error()).Error("Failed to create user")
 		return nil, err
 	}
+	return user, nil
+}
 
-	log.WithFields(map[string]interface{}{
-		"user_id":  user.ID.String(),
-		"username": user.Username,
-	}).Info("User created successfully, creating wallet")
+// UpdateUser changes the first name, last name and email of a user to those set in
+// req, leaving the others as they are, and returns the updated user. Values are
+// trimmed and the email lowercased; names must not be empty and the email must be a
+// plain address. Returns
+// ErrInvalidUserUpdate when req sets nothing or an invalid value, ErrUserNotFound for
+// an unknown user and ErrEmailTaken when another user already has the email.
+func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
+	log := logger.WithUser(id).WithContext(ctx).WithField("operation", "update_user")
+	log.Info("Updating user")
 
-	// Create wallet for the new user
-	_, err = repositories.CreateWallet(ctx, user.ID.String())
+	fields, err := normalizeUserUpdate(req)
 	if err != nil {
-		log.WithError(err).WithFields(map[string]interface{}{
-			"user_id": user.ID.String(),
-		}).Error("Failed to create wallet for user")
+		log.WithField("validation_error", err.Error()).Warn("User update validation failed")
 		return nil, err
 	}
 
-	log.WithFields(map[string]interface{}{
-		"user_id": user.ID.String(),
-	}).Info("User and wallet created successfully")
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			log.Warn("User not found")
+		} else {
+			log.WithField("error", err.Error()).Error("Failed to get user")
+		}
+		return nil, err
+	}
+	// Keeping one's own email is not a conflict, even in another case
+	if fields.Email != nil && !strings.EqualFold(*fields.Email, user.Email) {
+		exists, err := s.repo.IsEmailExists(ctx, *fields.Email)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to check email")
+			return nil, err
+		}
+		if exists {
+			log.WithField("email", *fields.Email).Warn("Email already in use")
+			return nil, fmt.Errorf("%w: %s", ErrEmailTaken, *fields.Email)
+		}
+	}
 
+	user, err = s.repo.UpdateUser(ctx, id, fields)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update user")
+		return nil, err
+	}
+	log.Info("User updated successfully")
 	return user, nil
 }
+
+// RestoreUser undoes the deletion of a user, for admins reverting a mistaken delete,
+// and returns the restored user. The user's wallets are restored too, but stay closed.
+// Returns ErrUserNotFound when no deleted user has the ID, and ErrEmailTaken or
+// ErrUsernameTaken when another user took the email or username in the meantime.
+func (s *UserService) RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	log := logger.WithUser(id).WithContext(ctx).WithField("operation", "restore_user")
+	log.Info("Restoring user")
+
+	user, err := s.repo.RestoreUser(ctx, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrEmailTaken), errors.Is(err, ErrUsernameTaken):
+		log.WithField("error", err.Error()).Warn("User restore rejected")
+		return nil, err
+	default:
+		log.WithField("error", err.Error()).Error("Failed to restore user")
+		return nil, err
+	}
+	s.wallets.invalidateWallets(ctx, id)
+	log.Info("User restored successfully")
+	return user, nil
+}
+
+// normalizeUserUpdate returns a copy of req with its values trimmed, or
+// ErrInvalidUserUpdate when it sets nothing or an invalid value
+func normalizeUserUpdate(req *models.UpdateUserRequest) (*models.UpdateUserRequest, error) {
+	if req.FirstName == nil && req.LastName == nil && req.Email == nil {
+		return nil, fmt.Errorf("%w: set at least one of first_name, last_name and email", ErrInvalidUserUpdate)
+	}
+	trim := func(v *string) *string {
+		if v == nil {
+			return nil
+		}
+		t := strings.TrimSpace(*v)
+		return &t
+	}
+	fields := &models.UpdateUserRequest{
+		FirstName: trim(req.FirstName),
+		LastName:  trim(req.LastName),
+		Email:     trim(req.Email),
+	}
+	if fields.Email != nil {
+		*fields.Email = normalizeEmail(*fields.Email)
+	}
+	if fields.FirstName != nil && *fields.FirstName == "" {
+		return nil, fmt.Errorf("%w: first_name must not be empty", ErrInvalidUserUpdate)
+	}
+	if fields.LastName != nil && *fields.LastName == "" {
+		return nil, fmt.Errorf("%w: last_name must not be empty", ErrInvalidUserUpdate)
+	}
+	if fields.Email != nil {
+		// Reject display names and other forms ParseAddress accepts but that are not
+		// a bare address
+		addr, err := mail.ParseAddress(*fields.Email)
+		if err != nil || addr.Address != *fields.Email {
+			return nil, fmt.Errorf("%w: email %q is not a valid address", ErrInvalidUserUpdate, *fields.Email)
+		}
+	}
+	return fields, nil
+}
+
+// normalizeEmail returns email trimmed and lowercased, the form emails are stored and
+// compared in
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+var defaultUserService *UserService
+
+// SetDefaultUserService sets the user service used by UpdateUser, RestoreUser,
+// ChangePassword and ResetPassword
+func SetDefaultUserService(service *UserService) {
+	defaultUserService = service
+}
+
+func UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
+	if defaultUserService == nil {
+		panic("default user service not initialized - call SetDefaultUserService first")
+	}
+	return defaultUserService.UpdateUser(ctx, id, req)
+}
+
+func RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	if defaultUserService == nil {
+		panic("default user service not initialized - call SetDefaultUserService first")
+	}
+	return defaultUserService.RestoreUser(ctx, id)
+}
+
+func ChangePassword(ctx context.Context, id, currentPassword, newPassword string) error {
+	if defaultUserService == nil {
+		panic("default user service not initialized - call SetDefaultUserService first")
+	}
+	return defaultUserService.ChangePassword(ctx, id, currentPassword, newPassword)
+}
+
+func ResetPassword(ctx context.Context, id, newPassword, performedBy string) error {
+	if defaultUserService == nil {
+		panic("default user service not initialized - call SetDefaultUserService first")
+	}
+	return defaultUserService.ResetPassword(ctx, id, newPassword, performedBy)
+}
+
+var defaultRegistrationService *RegistrationService
+
+// SetDefaultRegistrationService sets the registration service used by
+// CreateUserWithWallet and DeleteUser
+func SetDefaultRegistrationService(service *RegistrationService) {
+	defaultRegistrationService = service
+}
+
+func DeleteUser(ctx context.Context, userID string) error {
+	if defaultRegistrationService == nil {
+		panic("default registration service not initialized - call SetDefaultRegistrationService first")
+	}
+	return defaultRegistrationService.DeleteUser(ctx, userID)
+}
+
+func CreateUserWithWallet(ctx context.Context, req *models.CreateUserRequest) (*models.User, *models.Wallet, error) {
+	if defaultRegistrationService == nil {
+		panic("default registration service not initialized - call SetDefaultRegistrationService first")
+	}
+	return defaultRegistrationService.CreateUserWithWallet(ctx, req)
+}
@@ -3,22 +3,20 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-// UserRepo interface for dependency injection
-// (needed for UserService and MockUserRepo)
-type UserRepo interface {
-	IsEmailExists(ctx context.Context, email string) (bool, error)
-	IsUsernameExists(ctx context.Context, username string) (bool, error)
-	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
-}
-
 type MockUserRepo struct {
 	mock.Mock
 }
@@ -39,27 +37,87 @@ func (m *MockUserRepo) CreateUser(ctx context.Context, req *models.CreateUserReq
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-// UserService struct with dependency injection
-type UserService struct {
-	repo UserRepo
+func (m *MockUserRepo) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func NewUserService(repo UserRepo) *UserService {
-	return &UserService{repo: repo}
+func (m *MockUserRepo) UpdateUser(ctx context.Context, id string, fields *models.UpdateUserRequest) (*models.User, error) {
+	args := m.Called(ctx, id, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-	if exists, _ := s.repo.IsEmailExists(ctx, req.Email); exists {
-		return nil, errors.New("email already in use")
+func (m *MockUserRepo) RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
-	if exists, _ := s.repo.IsUsernameExists(ctx, req.Username); exists {
-		return nil, errors.New("username already in use")
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetUserCredentialsByID(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepo) UpdateUserPassword(ctx context.Context, id, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) RecordPasswordFailure(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) CountPasswordFailuresSince(ctx context.Context, id string, since time.Time) (int, *time.Time, error) {
+	args := m.Called(ctx, id, since)
+	return args.Int(0), args.Get(1).(*time.Time), args.Error(2)
+}
+
+func (m *MockUserRepo) IsEmailExistsTx(ctx context.Context, tx pgx.Tx, email string) (bool, error) {
+	args := m.Called(ctx, tx, email)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepo) IsUsernameExistsTx(ctx context.Context, tx pgx.Tx, username string) (bool, error) {
+	args := m.Called(ctx, tx, username)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepo) CreateUserTx(ctx context.Context, tx pgx.Tx, req *models.CreateUserRequest) (*models.User, error) {
+	args := m.Called(ctx, tx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
-	user, err := s.repo.CreateUser(ctx, req)
-	if err != nil {
-		return nil, err
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepo) GetUserByReferralCodeTx(ctx context.Context, tx pgx.Tx, code string) (*models.User, error) {
+	args := m.Called(ctx, tx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
-	return user, nil
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepo) SoftDeleteUserTx(ctx context.Context, tx pgx.Tx, id string) error {
+	args := m.Called(ctx, tx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) CreateReferralTx(ctx context.Context, tx pgx.Tx, referral *models.Referral) error {
+	args := m.Called(ctx, tx, referral)
+	return args.Error(0)
 }
 
 func TestUserService_CreateUser(t *testing.T) {
@@ -123,7 +181,7 @@ func TestUserService_CreateUser(t *testing.T) {
 			if tc.setupMock != nil {
 				tc.setupMock(mockRepo)
 			}
-			service := NewUserService(mockRepo)
+			service := NewUserService(mockRepo, nil)
 			user, err := service.CreateUser(context.Background(), tc.req)
 			if tc.wantErr == "" && err != nil {
 				t.Errorf("unexpected error: %v", err)
@@ -141,3 +199,483 @@ func TestUserService_CreateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_UpdateUser(t *testing.T) {
+	id := uuid.New()
+	existing := &models.User{ID: id, FirstName: "Test", LastName: "User", Email: "test@example.com"}
+	str := func(s string) *string { return &s }
+
+	cases := []struct {
+		name       string
+		req        *models.UpdateUserRequest
+		setupMock  func(*MockUserRepo)
+		wantFields *models.UpdateUserRequest
+		wantErr    error
+	}{
+		{
+			name: "first name only",
+			req:  &models.UpdateUserRequest{FirstName: str("  Jane ")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(existing, nil)
+			},
+			wantFields: &models.UpdateUserRequest{FirstName: str("Jane")},
+		},
+		{
+			name: "new email",
+			req:  &models.UpdateUserRequest{Email: str("jane@example.com")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("IsEmailExists", mock.Anything, "jane@example.com").Return(false, nil)
+			},
+			wantFields: &models.UpdateUserRequest{Email: str("jane@example.com")},
+		},
+		{
+			name: "own email is not a conflict",
+			req:  &models.UpdateUserRequest{Email: str("test@example.com"), LastName: str("Doe")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(existing, nil)
+			},
+			wantFields: &models.UpdateUserRequest{Email: str("test@example.com"), LastName: str("Doe")},
+		},
+		{
+			name: "email is stored lowercase",
+			req:  &models.UpdateUserRequest{Email: str(" Jane@Example.COM")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("IsEmailExists", mock.Anything, "jane@example.com").Return(false, nil)
+			},
+			wantFields: &models.UpdateUserRequest{Email: str("jane@example.com")},
+		},
+		{
+			name: "own email in another case is not a conflict",
+			req:  &models.UpdateUserRequest{Email: str("Test@Example.com")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(existing, nil)
+			},
+			wantFields: &models.UpdateUserRequest{Email: str("test@example.com")},
+		},
+		{
+			name: "email of another user",
+			req:  &models.UpdateUserRequest{Email: str("taken@example.com")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(existing, nil)
+				m.On("IsEmailExists", mock.Anything, "taken@example.com").Return(true, nil)
+			},
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name:    "invalid email",
+			req:     &models.UpdateUserRequest{Email: str("not-an-email")},
+			wantErr: ErrInvalidUserUpdate,
+		},
+		{
+			name:    "email with a display name",
+			req:     &models.UpdateUserRequest{Email: str("Jane <jane@example.com>")},
+			wantErr: ErrInvalidUserUpdate,
+		},
+		{
+			name:    "blank last name",
+			req:     &models.UpdateUserRequest{LastName: str("   ")},
+			wantErr: ErrInvalidUserUpdate,
+		},
+		{
+			name:    "nothing to update",
+			req:     &models.UpdateUserRequest{},
+			wantErr: ErrInvalidUserUpdate,
+		},
+		{
+			name: "unknown user",
+			req:  &models.UpdateUserRequest{FirstName: str("Jane")},
+			setupMock: func(m *MockUserRepo) {
+				m.On("GetUserByID", mock.Anything, id.String()).Return(nil, ErrUserNotFound)
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			if tc.setupMock != nil {
+				tc.setupMock(mockRepo)
+			}
+			updated := &models.User{ID: id, FirstName: "Jane"}
+			if tc.wantFields != nil {
+				mockRepo.On("UpdateUser", mock.Anything, id.String(), tc.wantFields).Return(updated, nil)
+			}
+
+			user, err := NewUserService(mockRepo, nil).UpdateUser(context.Background(), id.String(), tc.req)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, user)
+				mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, updated, user)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRegistrationService_CreateUserWithWallet(t *testing.T) {
+	created := &models.User{ID: uuid.New(), Username: "testuser"}
+	emptyWallet := &models.Wallet{ID: uuid.New(), UserID: created.ID, Currency: "USD"}
+	fundedWallet := &models.Wallet{ID: emptyWallet.ID, UserID: created.ID, Currency: "USD", Balance: money.MustParse("50.00")}
+
+	cases := []struct {
+		name           string
+		initialBalance money.Amount
+		setupMock      func(*MockUserRepo, *MockWalletRepo, *MockTransactionRepo, pgxmock.PgxPoolIface)
+		wantWallet     *models.Wallet
+		wantErr        string
+	}{
+		{
+			name: "success commits both inserts",
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil)
+				w.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(emptyWallet, nil)
+				db.ExpectCommit()
+			},
+			wantWallet: emptyWallet,
+		},
+		{
+			name:           "initial balance is deposited in the same transaction",
+			initialBalance: money.MustParse("50.00"),
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil)
+				w.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(emptyWallet, nil)
+				w.On("CreditWalletTx", mock.Anything, mock.Anything, created.ID.String(), money.MustParse("50.00")).Return(fundedWallet, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.MatchedBy(func(txn *models.Transaction) bool {
+					return txn.WalletID == emptyWallet.ID && txn.Type == models.TransactionTypeDeposit && txn.Amount == money.MustParse("50.00")
+				})).Return(nil)
+				tr.On("CreateLedgerEntriesTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				db.ExpectCommit()
+			},
+			wantWallet: fundedWallet,
+		},
+		{
+			name:           "negative initial balance is rejected before the transaction",
+			initialBalance: money.MustParse("-5.00"),
+			setupMock:      func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {},
+			wantErr:        "amount must be positive",
+		},
+		{
+			name:           "initial balance above the maximum is rejected",
+			initialBalance: MAX_AMOUNT + 1,
+			setupMock:      func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {},
+			wantErr:        "exceeds maximum limit",
+		},
+		{
+			name:           "deposit failure rolls back the user and wallet",
+			initialBalance: money.MustParse("50.00"),
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil)
+				w.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(emptyWallet, nil)
+				w.On("CreditWalletTx", mock.Anything, mock.Anything, created.ID.String(), money.MustParse("50.00")).Return(fundedWallet, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("insert failed"))
+				db.ExpectRollback()
+			},
+			wantErr: "insert failed",
+		},
+		{
+			name: "user insert failure rolls back",
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("duplicate username"))
+				db.ExpectRollback()
+			},
+			wantErr: "duplicate username",
+		},
+		{
+			name: "taken email is rejected before the insert",
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("IsEmailExistsTx", mock.Anything, mock.Anything, "test@example.com").Return(true, nil)
+				db.ExpectRollback()
+			},
+			wantErr: "email already in use: test@example.com",
+		},
+		{
+			name: "taken username is rejected before the insert",
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("IsUsernameExistsTx", mock.Anything, mock.Anything, "testuser").Return(true, nil)
+				db.ExpectRollback()
+			},
+			wantErr: "username already in use: testuser",
+		},
+		{
+			name: "email taken after the check is caught by the insert",
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: test@example.com", ErrEmailTaken))
+				db.ExpectRollback()
+			},
+			wantErr: "email already in use",
+		},
+		{
+			name: "wallet insert failure rolls back the user",
+			setupMock: func(u *MockUserRepo, w *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				u.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil)
+				w.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(nil, errors.New("wallet insert failed"))
+				db.ExpectRollback()
+			},
+			wantErr: "wallet insert failed",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &models.CreateUserRequest{Username: "testuser", FirstName: "Test", LastName: "User", Email: "test@example.com", Password: "password", InitialBalance: tc.initialBalance}
+			mockUserRepo := new(MockUserRepo)
+			mockWalletRepo := new(MockWalletRepo)
+			mockTxRepo := new(MockTransactionRepo)
+			mockDB, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+			tc.setupMock(mockUserRepo, mockWalletRepo, mockTxRepo, mockDB)
+			// Cases that need a taken email or username stub the checks first
+			mockUserRepo.On("IsEmailExistsTx", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Maybe()
+			mockUserRepo.On("IsUsernameExistsTx", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Maybe()
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			service := NewRegistrationService(mockUserRepo, wallets, mockDB, nil)
+			user, wallet, err := service.CreateUserWithWallet(context.Background(), req)
+
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Nil(t, user)
+				assert.Nil(t, wallet)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, created, user)
+				assert.Equal(t, tc.wantWallet, wallet)
+			}
+			mockUserRepo.AssertExpectations(t)
+			mockWalletRepo.AssertExpectations(t)
+			mockTxRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRegistrationService_CreateUserWithWallet_EmailCase(t *testing.T) {
+	created := &models.User{ID: uuid.New(), Username: "testuser", Email: "test@example.com"}
+
+	cases := []struct {
+		name    string
+		taken   bool
+		wantErr error
+	}{
+		{name: "email is stored lowercase"},
+		{name: "same email in another case is taken", taken: true, wantErr: ErrEmailTaken},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &models.CreateUserRequest{Username: "testuser", FirstName: "Test", LastName: "User", Email: " Test@Example.COM", Password: "password"}
+			mockUserRepo := new(MockUserRepo)
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			mockUserRepo.On("IsEmailExistsTx", mock.Anything, mock.Anything, "test@example.com").Return(tc.taken, nil)
+			if tc.wantErr != nil {
+				mockDB.ExpectRollback()
+			} else {
+				mockUserRepo.On("IsUsernameExistsTx", mock.Anything, mock.Anything, "testuser").Return(false, nil)
+				mockUserRepo.On("CreateUserTx", mock.Anything, mock.Anything, mock.MatchedBy(func(r *models.CreateUserRequest) bool {
+					return r.Email == "test@example.com"
+				})).Return(created, nil)
+				mockWalletRepo.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(&models.Wallet{UserID: created.ID}, nil)
+				mockDB.ExpectCommit()
+			}
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			user, _, err := NewRegistrationService(mockUserRepo, wallets, mockDB, nil).CreateUserWithWallet(context.Background(), req)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, user)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, created, user)
+			}
+			assert.Equal(t, " Test@Example.COM", req.Email, "the caller's request must not change")
+			mockUserRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRegistrationService_CreateUserWithWallet_Referral(t *testing.T) {
+	referrer := &models.User{ID: uuid.New(), Username: "referrer", ReferralCode: "3F9A1C07B2"}
+	created := &models.User{ID: uuid.New(), Username: "testuser"}
+	newWallet := &models.Wallet{ID: uuid.New(), UserID: created.ID, Currency: "USD"}
+	bonus := money.MustParse("5.00")
+
+	cases := []struct {
+		name           string
+		code           string
+		bonus          money.Amount
+		referrerStatus models.WalletStatus
+		lookupErr      error
+		wantErr        error
+		wantPaid       bool
+	}{
+		{name: "referrer is paid the bonus", code: "3F9A1C07B2", bonus: bonus, referrerStatus: models.WalletStatusActive, wantPaid: true},
+		{name: "code is trimmed and case insensitive", code: " 3f9a1c07b2 ", bonus: bonus, referrerStatus: models.WalletStatusActive, wantPaid: true},
+		{name: "no bonus configured still records the referral", code: "3F9A1C07B2"},
+		{name: "unknown code", code: "NOPE", lookupErr: ErrUserNotFound, wantErr: ErrInvalidReferralCode},
+		{name: "frozen referrer", code: "3F9A1C07B2", bonus: bonus, referrerStatus: models.WalletStatusFrozen, wantErr: ErrInvalidReferralCode},
+		{name: "closed referrer", code: "3F9A1C07B2", bonus: bonus, referrerStatus: models.WalletStatusClosed, wantErr: ErrInvalidReferralCode},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(MockUserRepo)
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			if tc.wantErr == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			code := strings.ToUpper(strings.TrimSpace(tc.code))
+			if tc.lookupErr != nil {
+				mockUserRepo.On("GetUserByReferralCodeTx", mock.Anything, mock.Anything, code).Return(nil, tc.lookupErr)
+			} else {
+				mockUserRepo.On("GetUserByReferralCodeTx", mock.Anything, mock.Anything, code).Return(referrer, nil)
+			}
+			mockUserRepo.On("IsEmailExistsTx", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Maybe()
+			mockUserRepo.On("IsUsernameExistsTx", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Maybe()
+			mockUserRepo.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil).Maybe()
+			mockWalletRepo.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(newWallet, nil).Maybe()
+			mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, referrer.ID.String(), bonus).
+				Return(&models.Wallet{ID: uuid.New(), UserID: referrer.ID, Status: tc.referrerStatus, Currency: "USD", Balance: bonus}, nil).Maybe()
+			var paid *models.Transaction
+			mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					paid = args.Get(2).(*models.Transaction)
+					paid.ID = uuid.New()
+				}).Return(nil).Maybe()
+			var referral *models.Referral
+			mockUserRepo.On("CreateReferralTx", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { referral = args.Get(2).(*models.Referral) }).Return(nil).Maybe()
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{ReferralBonus: tc.bonus})
+			service := NewRegistrationService(mockUserRepo, wallets, mockDB, nil)
+			req := &models.CreateUserRequest{Username: "testuser", FirstName: "Test", LastName: "User", Email: "test@example.com", Password: "password", ReferralCode: tc.code}
+			user, wallet, err := service.CreateUserWithWallet(context.Background(), req)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, user)
+				assert.Nil(t, wallet)
+				assert.Nil(t, referral, "no referral should be recorded")
+				if tc.lookupErr != nil {
+					mockUserRepo.AssertNotCalled(t, "CreateUserTx", mock.Anything, mock.Anything, mock.Anything)
+				}
+				assert.NoError(t, mockDB.ExpectationsWereMet())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, created, user)
+			if assert.NotNil(t, referral) {
+				assert.Equal(t, created.ID, referral.ReferredUserID)
+				assert.Equal(t, referrer.ID, referral.ReferrerID)
+				assert.Equal(t, tc.bonus, referral.Bonus)
+			}
+			if tc.wantPaid {
+				assert.Equal(t, models.TransactionTypeBonus, paid.Type)
+				assert.Equal(t, bonus, paid.Amount)
+				assert.Equal(t, map[string]string{BonusCampaignKey: ReferralCampaign, ReferredUserKey: created.ID.String()}, paid.Metadata)
+				assert.Equal(t, &paid.ID, referral.TransactionID)
+			} else {
+				assert.Nil(t, paid)
+				assert.Nil(t, referral.TransactionID)
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRegistrationService_DeleteUser(t *testing.T) {
+	userID := uuid.New()
+	empty := models.Wallet{ID: uuid.New(), UserID: userID, Name: "main", Currency: "USD"}
+
+	cases := []struct {
+		name      string
+		deleteErr error
+		wallets   []models.Wallet
+		wantErr   error
+		wantMsg   string
+	}{
+		{name: "empty wallets are closed", wallets: []models.Wallet{empty, {ID: uuid.New(), UserID: userID, Name: "savings", Currency: "EUR"}}},
+		{name: "user without wallets", wallets: []models.Wallet{}},
+		{
+			name:    "balance left in a wallet",
+			wallets: []models.Wallet{empty, {ID: uuid.New(), UserID: userID, Name: "savings", Currency: "EUR", Balance: money.MustParse("12.50")}},
+			wantErr: ErrUserHasBalance,
+			wantMsg: `wallet "savings" has a balance of 12.50 EUR with 0.00 held`,
+		},
+		{
+			name:    "overdrawn wallet",
+			wallets: []models.Wallet{{ID: empty.ID, UserID: userID, Name: "main", Currency: "USD", Balance: money.MustParse("-3.00")}},
+			wantErr: ErrUserHasBalance,
+		},
+		{
+			name:    "held funds",
+			wallets: []models.Wallet{{ID: empty.ID, UserID: userID, Name: "main", Currency: "USD", HeldAmount: money.MustParse("1.00")}},
+			wantErr: ErrUserHasBalance,
+		},
+		{name: "unknown user", deleteErr: ErrUserNotFound, wantErr: ErrUserNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(MockUserRepo)
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			if tc.wantErr == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockUserRepo.On("SoftDeleteUserTx", mock.Anything, mock.Anything, userID.String()).Return(tc.deleteErr)
+			mockWalletRepo.On("GetWalletsByUserIDForUpdateTx", mock.Anything, mock.Anything, userID.String()).Return(tc.wallets, nil).Maybe()
+			if tc.wantErr == nil {
+				mockWalletRepo.On("CloseWalletsByUserIDTx", mock.Anything, mock.Anything, userID.String()).Return(tc.wallets, nil)
+			}
+
+			wallets := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			err = NewRegistrationService(mockUserRepo, wallets, mockDB, nil).DeleteUser(context.Background(), userID.String())
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				if tc.wantMsg != "" {
+					assert.ErrorContains(t, err, tc.wantMsg)
+				}
+				mockWalletRepo.AssertNotCalled(t, "CloseWalletsByUserIDTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockUserRepo.AssertExpectations(t)
+			mockWalletRepo.AssertExpectations(t)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
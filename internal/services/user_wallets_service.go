@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// maxWalletNameLength matches the size of the wallets.name column
+const maxWalletNameLength = 50
+
+// ErrInvalidWalletName is returned when a wallet name is empty or too long
+var ErrInvalidWalletName = errors.New("invalid wallet name")
+
+// ErrWalletNameTaken is returned when a user already has a wallet with the requested name
+var ErrWalletNameTaken = repositories.ErrWalletNameTaken
+
+// ErrSameWallet is returned when money is moved from a wallet to itself
+var ErrSameWallet = errors.New("cannot move money to the same wallet")
+
+// walletRef identifies the wallet an operation applies to: a specific wallet when
+// walletID is set, otherwise the default wallet of userID. userID is always set, as
+// the wallet lock is keyed by the owner.
+type walletRef struct {
+	userID   string
+	walletID string
+}
+
+// defaultWallet refers to a user's default wallet
+func defaultWallet(userID string) walletRef {
+	return walletRef{userID: userID}
+}
+
+func (r walletRef) String() string {
+	if r.walletID != "" {
+		return "wallet " + r.walletID
+	}
+	return "user " + r.userID
+}
+
+// walletOrder sorts wallets by owner and then by wallet ID, so wallet rows are always
+// updated in the same order
+func walletOrder(wallets ...walletRef) []walletRef {
+	sorted := append([]walletRef(nil), wallets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].userID != sorted[j].userID {
+			return sorted[i].userID < sorted[j].userID
+		}
+		return sorted[i].walletID < sorted[j].walletID
+	})
+	return sorted
+}
+
+// walletOwners returns the distinct owners of wallets, for lockWallets
+func walletOwners(wallets []walletRef) []string {
+	userIDs := make([]string, 0, len(wallets))
+	seen := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		if !seen[wallet.userID] {
+			seen[wallet.userID] = true
+			userIDs = append(userIDs, wallet.userID)
+		}
+	}
+	return userIDs
+}
+
+// resolveWallet looks up the owner of a wallet. Wallets never change owner, so this
+// can happen before the owner's lock is taken.
+func (s *WalletService) resolveWallet(ctx context.Context, walletID string) (walletRef, error) {
+	wallet, err := s.walletRepo.GetWalletByID(ctx, walletID)
+	if err != nil {
+		return walletRef{}, err
+	}
+	return walletRef{userID: wallet.UserID.String(), walletID: walletID}, nil
+}
+
+// getWalletTx reads the wallet ref refers to within a transaction
+func (s *WalletService) getWalletTx(ctx context.Context, tx pgx.Tx, ref walletRef) (*models.Wallet, error) {
+	if ref.walletID != "" {
+		return s.walletRepo.GetWalletByIDTx(ctx, tx, ref.walletID)
+	}
+	return s.walletRepo.GetWalletByUserIDTx(ctx, tx, ref.userID)
+}
+
+// GetWalletByID retrieves a wallet by its ID
+func (s *WalletService) GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error) {
+	log := logger.WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "get_wallet_by_id",
+	}).WithContext(ctx)
+	log.Info("Getting wallet")
+
+	wallet, err := s.walletRepo.GetWalletByID(ctx, walletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+	wallet.AvailableBalance = wallet.Balance - wallet.HeldAmount
+
+	log.WithField("balance", wallet.Balance).Info("Successfully retrieved wallet")
+	return wallet, nil
+}
+
+// ListWallets returns all of a user's wallets, the default wallet first
+func (s *WalletService) ListWallets(ctx context.Context, userID string) ([]models.Wallet, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "list_wallets")
+	log.Info("Listing wallets for user")
+
+	wallets, err := s.walletRepo.GetWalletsByUserID(ctx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to list wallets")
+		return nil, err
+	}
+	for i := range wallets {
+		wallets[i].AvailableBalance = wallets[i].Balance - wallets[i].HeldAmount
+	}
+
+	log.WithField("wallet_count", len(wallets)).Info("Successfully listed wallets")
+	return wallets, nil
+}
+
+// CreateWallet opens an additional, non-default wallet for a user under a name that is
+// unique among the user's wallets. The wallet holds currency, an ISO 4217 code, or the
+// currency of the user's default wallet when currency is empty.
+func (s *WalletService) CreateWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation": "create_wallet",
+		"name":      name,
+		"currency":  currency,
+	})
+	log.Info("Creating wallet")
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		log.Warn("Wallet name is empty")
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidWalletName)
+	}
+	if n := utf8.RuneCountInString(name); n > maxWalletNameLength {
+		log.Warn("Wallet name is too long")
+		return nil, fmt.Errorf("%w: name is %d characters, at most %d allowed", ErrInvalidWalletName, n, maxWalletNameLength)
+	}
+
+	if currency == "" {
+		primary, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+		if err != nil {
+			log.WithField("error", err.Error()).Warn("Failed to get default wallet")
+			return nil, err
+		}
+		currency = primary.Currency
+	} else {
+		code, err := money.ParseCurrency(currency)
+		if err != nil {
+			log.Warn("Wallet currency is not supported")
+			return nil, fmt.Errorf("%w: %q", err, currency)
+		}
+		currency = code
+	}
+
+	wallet, err := s.walletRepo.CreateNamedWallet(ctx, userID, name, currency)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to create wallet")
+		return nil, err
+	}
+
+	log.WithField("wallet_id", wallet.ID.String()).Info("Wallet created successfully")
+	return wallet, nil
+}
+
+// DepositToWallet adds money to a specific wallet. It behaves like Deposit, with
+// idempotency keys scoped to the wallet.
+func (s *WalletService) DepositToWallet(ctx context.Context, walletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	log := logger.WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "deposit",
+		"amount":    amount,
+	}).WithContext(ctx)
+
+	ref, err := s.resolveWallet(ctx, walletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to find wallet")
+		return nil, nil, err
+	}
+	return s.depositInto(ctx, log.WithField("user_id", ref.userID), ref, amount, idempotencyKey, description, metadata)
+}
+
+// WithdrawFromWallet takes money out of a specific wallet. It behaves like Withdraw,
+// with idempotency keys scoped to the wallet.
+func (s *WalletService) WithdrawFromWallet(ctx context.Context, walletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	log := logger.WithFields(logrus.Fields{
+		"wallet_id": walletID,
+		"operation": "withdraw",
+		"amount":    amount,
+	}).WithContext(ctx)
+
+	ref, err := s.resolveWallet(ctx, walletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to find wallet")
+		return nil, nil, err
+	}
+	return s.withdrawFrom(ctx, log.WithField("user_id", ref.userID), ref, amount, idempotencyKey, description, metadata)
+}
+
+// TransferWallets transfers money between specific wallets of two different users. It
+// behaves like Transfer, with the idempotency key scoped to the sending wallet.
+func (s *WalletService) TransferWallets(ctx context.Context, fromWalletID, toWalletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
+	log := logger.WithFields(logrus.Fields{
+		"from_wallet_id": fromWalletID,
+		"to_wallet_id":   toWalletID,
+		"amount":         amount,
+		"operation":      "transfer",
+	}).WithContext(ctx)
+
+	from, err := s.resolveWallet(ctx, fromWalletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to find sending wallet")
+		return nil, err
+	}
+	to, err := s.resolveWallet(ctx, toWalletID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to find receiving wallet")
+		return nil, err
+	}
+	log = log.WithFields(logrus.Fields{
+		"from_user_id": from.userID,
+		"to_user_id":   to.userID,
+	})
+	return s.transferFunds(ctx, log, from, to, amount, idempotencyKey, description, metadata)
+}
+
+// TransferBetweenWallets moves money between two wallets of the same user. Unlike
+// Transfer it charges no fee, but it is recorded the same way, as TRANSFER_OUT and
+// TRANSFER_IN legs sharing a transfer ID, and counts towards the sending wallet's
+// limits. A wallet that does not belong to userID is reported as ErrWalletNotFound.
+func (s *WalletService) TransferBetweenWallets(ctx context.Context, userID, fromWalletID, toWalletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"from_wallet_id": fromWalletID,
+		"to_wallet_id":   toWalletID,
+		"amount":         amount,
+		"operation":      "move",
+	})
+	log.Info("Starting move between wallets")
+
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Move validation failed")
+		return nil, err
+	}
+	if fromWalletID == toWalletID {
+		log.Warn("Move to the same wallet blocked")
+		return nil, ErrSameWallet
+	}
+	memo, err := normalizeDescription(description)
+	if err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Move validation failed")
+		return nil, err
+	}
+	if err := validateMetadata(metadata); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Move validation failed")
+		return nil, err
+	}
+
+	var wallets [2]walletRef
+	for i, walletID := range []string{fromWalletID, toWalletID} {
+		wallets[i], err = s.resolveWallet(ctx, walletID)
+		if err != nil {
+			log.WithField("error", err.Error()).Warn("Failed to find wallet")
+			return nil, err
+		}
+		if wallets[i].userID != userID {
+			log.WithField("wallet_id", walletID).Warn("Move with another user's wallet blocked")
+			return nil, fmt.Errorf("%w: wallet %s does not belong to user %s", ErrWalletNotFound, walletID, userID)
+		}
+	}
+
+	// Both legs of the move share one ID, kept stable across retries
+	transferID := uuid.New()
+	log = log.WithField("transfer_id", transferID.String())
+
+	var result *models.TransferResult
+	err = s.retryTx(ctx, log, func() error {
+		return withTx(ctx, s.db, log, "transfer", func(tx pgx.Tx) (err error) {
+			// Moving money between your own wallets is not worth a notification
+			result, err = s.transfer(ctx, tx, log, transferID, wallets[0], wallets[1], amount, 0, idempotencyKey, memo, metadata, nil)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return result, nil
+}
+
+func GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.GetWalletByID(ctx, walletID)
+}
+
+func ListWallets(ctx context.Context, userID string) ([]models.Wallet, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.ListWallets(ctx, userID)
+}
+
+func CreateWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.CreateWallet(ctx, userID, name, currency)
+}
+
+func DepositToWallet(ctx context.Context, walletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.DepositToWallet(ctx, walletID, amount, idempotencyKey, description, metadata)
+}
+
+func WithdrawFromWallet(ctx context.Context, walletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.WithdrawFromWallet(ctx, walletID, amount, idempotencyKey, description, metadata)
+}
+
+func TransferWallets(ctx context.Context, fromWalletID, toWalletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.TransferWallets(ctx, fromWalletID, toWalletID, amount, idempotencyKey, description, metadata)
+}
+
+func TransferBetweenWallets(ctx context.Context, userID, fromWalletID, toWalletID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.TransferBetweenWallets(ctx, userID, fromWalletID, toWalletID, amount, idempotencyKey, description, metadata)
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_DepositToWallet(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	owner, walletID := uuid.New(), uuid.New()
+	var recorded *models.Transaction
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, walletID.String()).Return(&models.Wallet{ID: walletID, UserID: owner, Name: "savings"}, nil)
+	mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, walletID.String(), money.Amount(5000)).
+		Return(&models.Wallet{ID: walletID, UserID: owner, Name: "savings", Balance: 5000}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).
+		Run(func(args mock.Arguments) { recorded = args.Get(2).(*models.Transaction) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	wallet, _, err := service.DepositToWallet(context.Background(), walletID.String(), 5000, "", "", nil)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, wallet) {
+		assert.Equal(t, money.Amount(5000), wallet.Balance)
+	}
+	if assert.NotNil(t, recorded) {
+		assert.Equal(t, walletID, recorded.WalletID)
+	}
+	mockWalletRepo.AssertCalled(t, "AcquireWalletLockTx", mock.Anything, mock.Anything, owner.String())
+	mockWalletRepo.AssertNotCalled(t, "CreditWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_DepositToWallet_IdempotencyKeyScopedToWallet(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	owner, walletID := uuid.New(), uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, walletID.String()).Return(&models.Wallet{ID: walletID, UserID: owner}, nil)
+	mockTxRepo.On("GetTransactionByWalletIdempotencyKeyTx", mock.Anything, mock.Anything, walletID.String(), "key-1").
+		Return(&models.Transaction{ID: uuid.New(), WalletID: walletID, Type: models.TransactionTypeDeposit, Amount: 5000}, nil)
+	mockWalletRepo.On("GetWalletByIDTx", mock.Anything, mock.Anything, walletID.String()).
+		Return(&models.Wallet{ID: walletID, UserID: owner, Balance: 5000}, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	wallet, _, err := service.DepositToWallet(context.Background(), walletID.String(), 5000, "key-1", "", nil)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, wallet) {
+		assert.Equal(t, money.Amount(5000), wallet.Balance)
+	}
+	mockTxRepo.AssertNotCalled(t, "GetTransactionByIdempotencyKeyTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockWalletRepo.AssertNotCalled(t, "CreditWalletByIDTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_WithdrawFromWallet_InsufficientBalance(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	owner, walletID := uuid.New(), uuid.New()
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, walletID.String()).Return(&models.Wallet{ID: walletID, UserID: owner}, nil)
+	mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, walletID.String(), money.Amount(5000)).
+		Return(nil, repositories.ErrInsufficientBalance)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	wallet, _, err := service.WithdrawFromWallet(context.Background(), walletID.String(), 5000, "", "", nil)
+
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Contains(t, err.Error(), "wallet "+walletID.String())
+	assert.Nil(t, wallet)
+	mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_WithdrawFromWallet_WalletNotFound(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	walletID := uuid.New().String()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, walletID).Return(nil, repositories.ErrWalletNotFound)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	wallet, _, err := service.WithdrawFromWallet(context.Background(), walletID, 5000, "", "", nil)
+
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+	assert.Nil(t, wallet)
+	assert.NoError(t, mockDB.ExpectationsWereMet(), "no database transaction should be started")
+}
+
+func TestWalletService_TransferWallets(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	sender, recipient := uuid.New(), uuid.New()
+	fromWalletID, toWalletID := uuid.New(), uuid.New()
+	var recorded []*models.Transaction
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, fromWalletID.String()).Return(&models.Wallet{ID: fromWalletID, UserID: sender}, nil)
+	mockWalletRepo.On("GetWalletByID", mock.Anything, toWalletID.String()).Return(&models.Wallet{ID: toWalletID, UserID: recipient}, nil)
+	mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, fromWalletID.String(), money.Amount(2500)).
+		Return(&models.Wallet{ID: fromWalletID, UserID: sender, Balance: 7500}, nil)
+	mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, toWalletID.String(), money.Amount(2500)).
+		Return(&models.Wallet{ID: toWalletID, UserID: recipient, Balance: 2500}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).
+		Run(func(args mock.Arguments) { recorded = append(recorded, args.Get(2).(*models.Transaction)) }).
+		Return(nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	result, err := service.TransferWallets(context.Background(), fromWalletID.String(), toWalletID.String(), 2500, "", "", nil)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, money.Amount(2500), result.Amount)
+	}
+	if assert.Len(t, recorded, 2) {
+		for _, leg := range recorded {
+			switch leg.Type {
+			case models.TransactionTypeTransferOut:
+				assert.Equal(t, fromWalletID, leg.WalletID)
+				assert.Equal(t, recipient.String(), *leg.RelatedUserID)
+			case models.TransactionTypeTransferIn:
+				assert.Equal(t, toWalletID, leg.WalletID)
+				assert.Equal(t, sender.String(), *leg.RelatedUserID)
+			}
+		}
+	}
+	mockWalletRepo.AssertNotCalled(t, "DebitWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockWalletRepo.AssertNotCalled(t, "CreditWalletTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_TransferWallets_SameOwnerRejected(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	owner := uuid.New()
+	mainWallet, savingsWallet := uuid.New(), uuid.New()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, mainWallet.String()).Return(&models.Wallet{ID: mainWallet, UserID: owner}, nil)
+	mockWalletRepo.On("GetWalletByID", mock.Anything, savingsWallet.String()).Return(&models.Wallet{ID: savingsWallet, UserID: owner}, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	result, err := service.TransferWallets(context.Background(), mainWallet.String(), savingsWallet.String(), 2500, "", "", nil)
+
+	assert.ErrorIs(t, err, ErrSelfTransfer)
+	assert.Nil(t, result)
+	assert.NoError(t, mockDB.ExpectationsWereMet(), "no database transaction should be started")
+}
+
+func TestWalletService_CreateWallet(t *testing.T) {
+	tests := []struct {
+		name          string
+		walletName    string
+		currency      string
+		setupMocks    func(*MockWalletRepo)
+		expectedName  string
+		expectedErrIs error
+	}{
+		{
+			name:       "creates a named wallet in the default wallet's currency",
+			walletName: "  savings ",
+			setupMocks: func(wr *MockWalletRepo) {
+				wr.On("GetWalletByUserID", mock.Anything, "user1").Return(&models.Wallet{ID: uuid.New(), Currency: "SGD"}, nil)
+				wr.On("CreateNamedWallet", mock.Anything, "user1", "savings", "SGD").Return(&models.Wallet{ID: uuid.New(), Name: "savings", Currency: "SGD"}, nil)
+			},
+			expectedName: "savings",
+		},
+		{
+			name:       "creates a named wallet in the requested currency",
+			walletName: "travel",
+			currency:   "jpy",
+			setupMocks: func(wr *MockWalletRepo) {
+				wr.On("CreateNamedWallet", mock.Anything, "user1", "travel", "JPY").Return(&models.Wallet{ID: uuid.New(), Name: "travel", Currency: "JPY"}, nil)
+			},
+			expectedName: "travel",
+		},
+		{
+			name:          "unsupported currency",
+			walletName:    "gold",
+			currency:      "XAU",
+			expectedErrIs: ErrUnsupportedCurrency,
+		},
+		{
+			name:          "empty name",
+			walletName:    "   ",
+			expectedErrIs: ErrInvalidWalletName,
+		},
+		{
+			name:          "name too long",
+			walletName:    strings.Repeat("a", maxWalletNameLength+1),
+			expectedErrIs: ErrInvalidWalletName,
+		},
+		{
+			name:       "name already used by the user",
+			walletName: "main",
+			currency:   "USD",
+			setupMocks: func(wr *MockWalletRepo) {
+				wr.On("CreateNamedWallet", mock.Anything, "user1", "main", "USD").Return(nil, repositories.ErrWalletNameTaken)
+			},
+			expectedErrIs: ErrWalletNameTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo := new(MockWalletRepo)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockWalletRepo)
+			}
+
+			service := NewWalletService(mockWalletRepo, nil, nil, WalletServiceConfig{})
+			wallet, err := service.CreateWallet(context.Background(), "user1", tt.walletName, tt.currency)
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, wallet)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedName, wallet.Name)
+			}
+			mockWalletRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletService_TransferBetweenWallets(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	owner := uuid.New()
+	mainWallet, savingsWallet := uuid.New(), uuid.New()
+	var recorded []*models.Transaction
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("GetWalletByID", mock.Anything, mainWallet.String()).Return(&models.Wallet{ID: mainWallet, UserID: owner}, nil)
+	mockWalletRepo.On("GetWalletByID", mock.Anything, savingsWallet.String()).Return(&models.Wallet{ID: savingsWallet, UserID: owner}, nil)
+	mockWalletRepo.On("DebitWalletByIDTx", mock.Anything, mock.Anything, mainWallet.String(), money.Amount(2500)).
+		Return(&models.Wallet{ID: mainWallet, UserID: owner, Balance: 7500}, nil)
+	mockWalletRepo.On("CreditWalletByIDTx", mock.Anything, mock.Anything, savingsWallet.String(), money.Amount(2500)).
+		Return(&models.Wallet{ID: savingsWallet, UserID: owner, Balance: 2500}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Transaction")).
+		Run(func(args mock.Arguments) { recorded = append(recorded, args.Get(2).(*models.Transaction)) }).
+		Return(nil)
+
+	// Moves are free even when transfers between users are charged
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{
+		Fees: FeePolicy{Flat: 50, WalletUserID: uuid.New().String()},
+	})
+	result, err := service.TransferBetweenWallets(context.Background(), owner.String(), mainWallet.String(), savingsWallet.String(), 2500, "", "", nil)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, money.Amount(2500), result.Amount)
+		assert.Zero(t, result.Fee)
+	}
+	if assert.Len(t, recorded, 2, "a move should record exactly two legs and no fee") {
+		assert.Equal(t, models.TransactionTypeTransferOut, recorded[0].Type)
+		assert.Equal(t, mainWallet, recorded[0].WalletID)
+		assert.Equal(t, models.TransactionTypeTransferIn, recorded[1].Type)
+		assert.Equal(t, savingsWallet, recorded[1].WalletID)
+		assert.Equal(t, result.TransferID, *recorded[0].TransferID)
+		assert.Equal(t, recorded[0].TransferID, recorded[1].TransferID, "both legs should share the transfer ID")
+	}
+	mockWalletRepo.AssertNumberOfCalls(t, "AcquireWalletLockTx", 1)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_TransferBetweenWallets_Rejected(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	mainWallet, otherWallet := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name          string
+		fromWalletID  string
+		toWalletID    string
+		expectedErrIs error
+	}{
+		{"same wallet", mainWallet.String(), mainWallet.String(), ErrSameWallet},
+		{"to another user's wallet", mainWallet.String(), otherWallet.String(), ErrWalletNotFound},
+		{"from another user's wallet", otherWallet.String(), mainWallet.String(), ErrWalletNotFound},
+		{"unknown wallet", mainWallet.String(), uuid.New().String(), ErrWalletNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+			mockWalletRepo.On("GetWalletByID", mock.Anything, mainWallet.String()).Return(&models.Wallet{ID: mainWallet, UserID: owner}, nil).Maybe()
+			mockWalletRepo.On("GetWalletByID", mock.Anything, otherWallet.String()).Return(&models.Wallet{ID: otherWallet, UserID: other}, nil).Maybe()
+			mockWalletRepo.On("GetWalletByID", mock.Anything, mock.Anything).Return(nil, repositories.ErrWalletNotFound).Maybe()
+
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+			result, err := service.TransferBetweenWallets(context.Background(), owner.String(), tt.fromWalletID, tt.toWalletID, 2500, "", "", nil)
+
+			assert.ErrorIs(t, err, tt.expectedErrIs)
+			assert.Nil(t, result)
+			assert.NoError(t, mockDB.ExpectationsWereMet(), "no database transaction should be started")
+		})
+	}
+}
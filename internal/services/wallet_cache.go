@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultWalletCacheTTL is how long a cached wallet is served when no TTL is configured
+const defaultWalletCacheTTL = 5 * time.Second
+
+// walletCacheTimeout bounds each call RedisWalletCache makes, so a slow cache costs a
+// read no more than this before it goes to the database
+const walletCacheTimeout = 100 * time.Millisecond
+
+// WalletCache keeps users' default wallets, as GetWallet returns them, between reads.
+// Every balance-changing operation invalidates the wallets it changed once it has
+// committed; an invalidation lost to a crash leaves a stale wallet for at most the TTL
+// it was set with. A failing cache is logged and otherwise skipped, so it can slow
+// reads down but never fail them.
+type WalletCache interface {
+	// Get returns the cached wallet of userID, or nil when none is cached
+	Get(ctx context.Context, userID string) (*models.Wallet, error)
+	Set(ctx context.Context, userID string, wallet *models.Wallet, ttl time.Duration) error
+	Invalidate(ctx context.Context, userID string) error
+}
+
+// noopWalletCache is the WalletCache used when none is configured
+type noopWalletCache struct{}
+
+func (noopWalletCache) Get(context.Context, string) (*models.Wallet, error) { return nil, nil }
+
+func (noopWalletCache) Set(context.Context, string, *models.Wallet, time.Duration) error {
+	return nil
+}
+
+func (noopWalletCache) Invalidate(context.Context, string) error { return nil }
+
+// RedisWalletCache is the WalletCache kept in Redis, with each wallet stored as JSON
+// under "wallet:<user ID>". The JSON leaves out the wallet's version, which cached
+// wallets are never updated with.
+type RedisWalletCache struct {
+	client *redis.Client
+}
+
+// NewRedisWalletCache creates a RedisWalletCache on the Redis server at url, such as
+// "redis://localhost:6379/0"
+func NewRedisWalletCache(url string) (*RedisWalletCache, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisWalletCache{client: redis.NewClient(options)}, nil
+}
+
+func walletCacheKey(userID string) string {
+	return "wallet:" + userID
+}
+
+// Get returns the cached wallet of userID, or nil when none is cached
+func (c *RedisWalletCache) Get(ctx context.Context, userID string) (*models.Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, walletCacheTimeout)
+	defer cancel()
+	data, err := c.client.Get(ctx, walletCacheKey(userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var wallet models.Wallet
+	if err := json.Unmarshal(data, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Set caches the wallet of userID for ttl
+func (c *RedisWalletCache) Set(ctx context.Context, userID string, wallet *models.Wallet, ttl time.Duration) error {
+	data, err := json.Marshal(wallet)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, walletCacheTimeout)
+	defer cancel()
+	return c.client.Set(ctx, walletCacheKey(userID), data, ttl).Err()
+}
+
+// Invalidate drops the cached wallet of userID
+func (c *RedisWalletCache) Invalidate(ctx context.Context, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, walletCacheTimeout)
+	defer cancel()
+	return c.client.Del(ctx, walletCacheKey(userID)).Err()
+}
+
+// invalidateWallets drops the cached wallets of userIDs once an operation changing
+// them has committed. It runs even when the caller has given up, since the change
+// went through.
+func (s *WalletService) invalidateWallets(ctx context.Context, userIDs ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, userID := range userIDs {
+		if userID == "" {
+			continue
+		}
+		if err := s.cache.Invalidate(ctx, userID); err != nil {
+			logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+				"operation": "invalidate_wallet_cache",
+				"error":     err.Error(),
+			}).Warn("Failed to invalidate cached wallet")
+		}
+	}
+}
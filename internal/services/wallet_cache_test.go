@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeWalletCache keeps wallets in a map and records what was invalidated
+type fakeWalletCache struct {
+	mu          sync.Mutex
+	wallets     map[string]*models.Wallet
+	ttls        map[string]time.Duration
+	invalidated []string
+	err         error
+}
+
+func newFakeWalletCache() *fakeWalletCache {
+	return &fakeWalletCache{wallets: map[string]*models.Wallet{}, ttls: map[string]time.Duration{}}
+}
+
+func (c *fakeWalletCache) Get(_ context.Context, userID string) (*models.Wallet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.wallets[userID], nil
+}
+
+func (c *fakeWalletCache) Set(_ context.Context, userID string, wallet *models.Wallet, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.wallets[userID] = wallet
+	c.ttls[userID] = ttl
+	return nil
+}
+
+func (c *fakeWalletCache) Invalidate(_ context.Context, userID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidated = append(c.invalidated, userID)
+	delete(c.wallets, userID)
+	return c.err
+}
+
+func TestWalletService_GetWallet_ServesFromCache(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").
+		Return(&models.Wallet{ID: uuid.New(), Balance: 10000, HeldAmount: 2500}, nil).Once()
+	cache := newFakeWalletCache()
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{Cache: cache, CacheTTL: 3 * time.Second})
+
+	first, err := service.GetWallet(context.Background(), "user1")
+	assert.NoError(t, err)
+	second, err := service.GetWallet(context.Background(), "user1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, money.Amount(7500), first.AvailableBalance)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 3*time.Second, cache.ttls["user1"])
+	mockWalletRepo.AssertNumberOfCalls(t, "GetWalletByUserID", 1)
+}
+
+func TestWalletService_GetWallet_FailingCacheFallsBackToDatabase(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(&models.Wallet{Balance: 10000}, nil)
+	cache := newFakeWalletCache()
+	cache.err = errors.New("connection refused")
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{Cache: cache})
+
+	wallet, err := service.GetWallet(context.Background(), "user1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(10000), wallet.Balance)
+}
+
+func TestWalletService_GetWallet_DefaultCacheTTL(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(&models.Wallet{Balance: 10000}, nil)
+	cache := newFakeWalletCache()
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{Cache: cache})
+
+	_, err = service.GetWallet(context.Background(), "user1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, defaultWalletCacheTTL, cache.ttls["user1"])
+}
+
+func TestWalletService_InvalidatesCacheAfterCommit(t *testing.T) {
+	referrer := &models.User{ID: uuid.New()}
+	created := &models.User{ID: uuid.New()}
+	deleted := uuid.New()
+	// users is shared by the cases on users, each setting up the calls it makes
+	users := new(MockUserRepo)
+
+	tests := []struct {
+		name        string
+		config      WalletServiceConfig
+		setupMocks  func(*MockWalletRepo, *MockTransactionRepo, pgxmock.PgxPoolIface)
+		run         func(*WalletService) error
+		invalidated []string
+	}{
+		{
+			name: "deposit",
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectCommit()
+				wr.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(5000)).Return(&models.Wallet{Balance: 15000}, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			run: func(s *WalletService) error {
+				_, _, err := s.Deposit(context.Background(), "user1", 5000, "", "", nil)
+				return err
+			},
+			invalidated: []string{"user1"},
+		},
+		{
+			name: "withdraw",
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectCommit()
+				wr.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(&models.Wallet{Balance: 7000}, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			run: func(s *WalletService) error {
+				_, _, err := s.Withdraw(context.Background(), "user1", 3000, "", "", nil)
+				return err
+			},
+			invalidated: []string{"user1"},
+		},
+		{
+			name: "transfer",
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectCommit()
+				wr.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New(), Balance: 7000}, nil)
+				wr.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New(), Balance: 8000}, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			run: func(s *WalletService) error {
+				_, err := s.Transfer(context.Background(), "user1", "user2", 3000, "", "", nil)
+				return err
+			},
+			invalidated: []string{"user1", "user2"},
+		},
+		{
+			name:   "transfer with a fee",
+			config: WalletServiceConfig{Fees: FeePolicy{Flat: 100, WalletUserID: "fees"}},
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectCommit()
+				wr.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3100)).Return(&models.Wallet{ID: uuid.New(), Balance: 6900}, nil)
+				wr.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New(), Balance: 8000}, nil)
+				wr.On("CreditWalletTx", mock.Anything, mock.Anything, "fees", money.Amount(100)).Return(&models.Wallet{ID: uuid.New(), Balance: 100}, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			run: func(s *WalletService) error {
+				_, err := s.Transfer(context.Background(), "user1", "user2", 3000, "", "", nil)
+				return err
+			},
+			invalidated: []string{"user1", "user2", "fees"},
+		},
+		{
+			name:   "signup paying a referral bonus",
+			config: WalletServiceConfig{ReferralBonus: 500},
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectCommit()
+				users.On("IsEmailExistsTx", mock.Anything, mock.Anything, "new@example.com").Return(false, nil)
+				users.On("IsUsernameExistsTx", mock.Anything, mock.Anything, "newuser").Return(false, nil)
+				users.On("GetUserByReferralCodeTx", mock.Anything, mock.Anything, "3F9A1C07B2").Return(referrer, nil)
+				users.On("CreateUserTx", mock.Anything, mock.Anything, mock.Anything).Return(created, nil)
+				users.On("CreateReferralTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				wr.On("CreateWalletTx", mock.Anything, mock.Anything, created.ID.String()).Return(&models.Wallet{ID: uuid.New(), UserID: created.ID}, nil)
+				wr.On("CreditWalletTx", mock.Anything, mock.Anything, referrer.ID.String(), money.Amount(500)).Return(&models.Wallet{ID: uuid.New(), UserID: referrer.ID, Balance: 500}, nil)
+				tr.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			run: func(s *WalletService) error {
+				req := &models.CreateUserRequest{Username: "newuser", FirstName: "New", LastName: "User", Email: "new@example.com", Password: "password", ReferralCode: "3F9A1C07B2"}
+				_, _, err := NewRegistrationService(users, s, s.db, nil).CreateUserWithWallet(context.Background(), req)
+				return err
+			},
+			invalidated: []string{referrer.ID.String()},
+		},
+		{
+			name: "user deletion",
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectCommit()
+				users.On("SoftDeleteUserTx", mock.Anything, mock.Anything, deleted.String()).Return(nil)
+				wr.On("GetWalletsByUserIDForUpdateTx", mock.Anything, mock.Anything, deleted.String()).Return([]models.Wallet{{ID: uuid.New(), UserID: deleted}}, nil)
+				wr.On("CloseWalletsByUserIDTx", mock.Anything, mock.Anything, deleted.String()).Return([]models.Wallet{{ID: uuid.New(), UserID: deleted}}, nil)
+			},
+			run: func(s *WalletService) error {
+				return NewRegistrationService(users, s, s.db, nil).DeleteUser(context.Background(), deleted.String())
+			},
+			invalidated: []string{deleted.String()},
+		},
+		{
+			name: "user restore",
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				users.On("RestoreUser", mock.Anything, deleted.String()).Return(&models.User{ID: deleted}, nil)
+			},
+			run: func(s *WalletService) error {
+				_, err := NewUserService(users, s).RestoreUser(context.Background(), deleted.String())
+				return err
+			},
+			invalidated: []string{deleted.String()},
+		},
+		{
+			name: "failed transfer",
+			setupMocks: func(wr *MockWalletRepo, tr *MockTransactionRepo, db pgxmock.PgxPoolIface) {
+				db.ExpectBegin()
+				db.ExpectRollback()
+				wr.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(3000)).Return(nil, repositories.ErrInsufficientBalance)
+				wr.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(3000)).Return(&models.Wallet{ID: uuid.New()}, nil).Maybe()
+			},
+			run: func(s *WalletService) error {
+				_, err := s.Transfer(context.Background(), "user1", "user2", 3000, "", "", nil)
+				if !errors.Is(err, ErrInsufficientBalance) {
+					return err
+				}
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+			tt.setupMocks(mockWalletRepo, mockTxRepo, mockDB)
+
+			cache := newFakeWalletCache()
+			tt.config.Cache = cache
+			service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, tt.config)
+
+			assert.NoError(t, tt.run(service))
+			assert.ElementsMatch(t, tt.invalidated, cache.invalidated)
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_FailedInvalidationKeepsOperation(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectCommit()
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(5000)).Return(&models.Wallet{Balance: 15000}, nil)
+	mockTxRepo.On("CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	cache := newFakeWalletCache()
+	cache.err = errors.New("connection refused")
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{Cache: cache})
+
+	wallet, _, err := service.Deposit(context.Background(), "user1", 5000, "", "", nil)
+
+	// The deposit committed, so it succeeds; the stale wallet expires with its TTL
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(15000), wallet.Balance)
+	assert.Equal(t, []string{"user1"}, cache.invalidated)
+}
+
+func TestWalletServiceConfigFromEnv_WalletCache(t *testing.T) {
+	t.Setenv("WALLET_CACHE", "redis")
+	t.Setenv("REDIS_URL", "redis://localhost:6379/0")
+	t.Setenv("WALLET_CACHE_TTL", "2s")
+
+	config, err := WalletServiceConfigFromEnv()
+
+	assert.NoError(t, err)
+	assert.IsType(t, &RedisWalletCache{}, config.Cache)
+	assert.Equal(t, 2*time.Second, config.CacheTTL)
+
+	t.Setenv("REDIS_URL", "")
+	_, err = WalletServiceConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("WALLET_CACHE", "memcached")
+	_, err = WalletServiceConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -2,30 +2,387 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"math"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 	"walletapp/internal/logger"
 	"walletapp/internal/models"
+	"walletapp/internal/money"
+	"walletapp/internal/repositories"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
 )
 
-// Maximum amount of money that can be transferred or deposited/withdrawn
-const MAX_AMOUNT = 1000000
+// Default maximum amount of money that can be transferred or deposited/withdrawn ($1,000,000)
+const MAX_AMOUNT money.Amount = 100000000
 
-// Minimum amount of money that can be transferred or deposited/withdrawn
-const MIN_AMOUNT = 0.01
+// Default minimum amount of money that can be transferred or deposited/withdrawn ($0.01)
+const MIN_AMOUNT money.Amount = 1
+
+// WalletServiceConfig holds the tunable settings of a WalletService.
+// Zero amounts fall back to MIN_AMOUNT and MAX_AMOUNT.
+type WalletServiceConfig struct {
+	MinAmount money.Amount
+	MaxAmount money.Amount
+	// OptimisticLocking applies balance changes with versioned read-then-write
+	// updates instead of conditional UPDATEs that hold row locks
+	OptimisticLocking bool
+	// Fees is charged on transfers; the zero value charges no fees
+	Fees FeePolicy
+	// DailyWithdrawalLimit caps how much a wallet may withdraw per UTC day unless
+	// the wallet sets its own limit; zero means no limit
+	DailyWithdrawalLimit money.Amount
+	// MonthlyTransferLimit caps how much a wallet may transfer out per UTC calendar
+	// month unless the wallet sets its own limit; zero means no limit
+	MonthlyTransferLimit money.Amount
+	// MaxOperationsPerMinute caps the deposits, withdrawals and outgoing transfers a
+	// wallet may make in any minute; zero means no limit
+	MaxOperationsPerMinute int
+	// Rates quotes the exchange rates currency exchanges use; without it no
+	// exchange rate is available
+	Rates RateProvider
+	// TransferIntentTTL is how long a transfer intent can be confirmed; zero falls
+	// back to defaultTransferIntentTTL
+	TransferIntentTTL time.Duration
+	// Notifier is told about received transfers and low balances once they commit;
+	// nil sends no notifications
+	Notifier Notifier
+	// LowBalanceThreshold is the balance below which a debited wallet is reported to
+	// the Notifier; zero reports no low balances
+	LowBalanceThreshold money.Amount
+	// EnforceKycLimits applies the limits of each user's KYC tier, read from the
+	// kyc_tier_limits table, to deposits, withdrawals and outgoing transfers
+	EnforceKycLimits bool
+	// UnverifiedEmailLimit is the largest withdrawal, outgoing transfer, batch transfer
+	// total or hold a user who has not verified their email may make; zero lets them
+	// move any amount
+	UnverifiedEmailLimit money.Amount
+	// RiskRules are checked against every deposit, withdrawal and outgoing transfer;
+	// matching operations still go through but are recorded as risk flags
+	RiskRules []RiskRule
+	// InterestAccrualInterval is how often the interest worker runs; wallets are still
+	// credited at most once per UTC day
+	InterestAccrualInterval time.Duration
+	// ReferralBonus is credited to a referrer's wallet when a user signs up with their
+	// referral code; zero pays no bonus
+	ReferralBonus money.Amount
+	// Cache serves GetWallet and is invalidated by deposits, withdrawals and transfers;
+	// nil caches nothing
+	Cache WalletCache
+	// CacheTTL is how long Cache keeps a wallet, bounding how stale it can be when an
+	// invalidation is lost; zero falls back to defaultWalletCacheTTL
+	CacheTTL time.Duration
+}
+
+// WalletServiceConfigFromEnv reads the amount limits from WALLET_MIN_AMOUNT and
+// WALLET_MAX_AMOUNT (decimal strings such as "0.01"), the locking strategy from
+// WALLET_OPTIMISTIC_LOCKING, the default daily withdrawal and monthly transfer limits
+// from WALLET_DAILY_WITHDRAWAL_LIMIT and WALLET_MONTHLY_TRANSFER_LIMIT, the velocity
+// limit from WALLET_MAX_OPERATIONS_PER_MINUTE, whether KYC tier limits apply from
+// WALLET_ENFORCE_KYC_LIMITS, the largest withdrawal, transfer or hold of users with an
+// unverified email from WALLET_UNVERIFIED_EMAIL_LIMIT, the transfer fee policy from
+// TRANSFER_FEE_THRESHOLD, TRANSFER_FEE_FLAT, TRANSFER_FEE_PERCENT (such as "1.5"),
+// TRANSFER_FEE_MINIMUM and TRANSFER_FEE_WALLET_USER_ID, and static exchange rates from
+// EXCHANGE_RATES (such as "USD/EUR=0.92,EUR/USD=1.08"), and how long transfer intents
+// stay confirmable from TRANSFER_INTENT_TTL (such as "5m"). Notifications are
+// sent to NOTIFIER, "log" or "webhook" (posting to NOTIFIER_WEBHOOK_URL), with low
+// balances reported below WALLET_LOW_BALANCE_THRESHOLD. Operations are flagged for
+// review when over RISK_LARGE_AMOUNT, when a wallet pays more than
+// RISK_MAX_NEW_RECIPIENTS_PER_HOUR first-time recipients in an hour, or when a
+// withdrawal takes more than RISK_BALANCE_DRAIN_PERCENT of the balance. The interest
+// worker runs every INTEREST_ACCRUAL_INTERVAL (such as "1h"), and referrers are paid
+// REFERRAL_BONUS_AMOUNT for each user who signs up with their code. Unset variables keep
+// the defaults; without fee variables no fees are charged, without limits
+// withdrawals and transfers are only bounded by the balance, and without rates
+// currencies cannot be exchanged.
+func WalletServiceConfigFromEnv() (WalletServiceConfig, error) {
+	var config WalletServiceConfig
+	if raw := os.Getenv("WALLET_OPTIMISTIC_LOCKING"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return WalletServiceConfig{}, fmt.Errorf("invalid WALLET_OPTIMISTIC_LOCKING %q: must be a boolean", raw)
+		}
+		config.OptimisticLocking = enabled
+	}
+	if raw := os.Getenv("WALLET_ENFORCE_KYC_LIMITS"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return WalletServiceConfig{}, fmt.Errorf("invalid WALLET_ENFORCE_KYC_LIMITS %q: must be a boolean", raw)
+		}
+		config.EnforceKycLimits = enabled
+	}
+	if raw := os.Getenv("WALLET_MAX_OPERATIONS_PER_MINUTE"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid WALLET_MAX_OPERATIONS_PER_MINUTE %q: must be a non-negative integer", raw)
+		}
+		config.MaxOperationsPerMinute = limit
+	}
+	for _, v := range []struct {
+		name   string
+		target *money.Amount
+	}{
+		{"WALLET_MIN_AMOUNT", &config.MinAmount},
+		{"WALLET_MAX_AMOUNT", &config.MaxAmount},
+		{"WALLET_DAILY_WITHDRAWAL_LIMIT", &config.DailyWithdrawalLimit},
+		{"WALLET_MONTHLY_TRANSFER_LIMIT", &config.MonthlyTransferLimit},
+		{"WALLET_LOW_BALANCE_THRESHOLD", &config.LowBalanceThreshold},
+		{"WALLET_UNVERIFIED_EMAIL_LIMIT", &config.UnverifiedEmailLimit},
+		{"REFERRAL_BONUS_AMOUNT", &config.ReferralBonus},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		amount, err := money.Parse(raw)
+		if err != nil || amount <= 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid %s %q: must be a positive amount", v.name, raw)
+		}
+		*v.target = amount
+	}
+	if config.MinAmount > 0 && config.MaxAmount > 0 && config.MinAmount > config.MaxAmount {
+		return WalletServiceConfig{}, fmt.Errorf("WALLET_MIN_AMOUNT %s exceeds WALLET_MAX_AMOUNT %s", config.MinAmount, config.MaxAmount)
+	}
+
+	var percent money.Amount
+	for _, v := range []struct {
+		name   string
+		target *money.Amount
+	}{
+		{"TRANSFER_FEE_THRESHOLD", &config.Fees.Threshold},
+		{"TRANSFER_FEE_FLAT", &config.Fees.Flat},
+		{"TRANSFER_FEE_MINIMUM", &config.Fees.Minimum},
+		// A percentage with two decimals parses to a whole number of basis points
+		{"TRANSFER_FEE_PERCENT", &percent},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		amount, err := money.Parse(raw)
+		if err != nil || amount < 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid %s %q: must be a non-negative amount with at most 2 decimals", v.name, raw)
+		}
+		*v.target = amount
+	}
+	config.Fees.BasisPoints = percent.Cents()
+	config.Fees.WalletUserID = os.Getenv("TRANSFER_FEE_WALLET_USER_ID")
+	if err := config.Fees.validate(); err != nil {
+		return WalletServiceConfig{}, fmt.Errorf("invalid transfer fee settings: %w", err)
+	}
+	if raw := os.Getenv("EXCHANGE_RATES"); raw != "" {
+		rates, err := ParseStaticRates(raw)
+		if err != nil {
+			return WalletServiceConfig{}, fmt.Errorf("invalid EXCHANGE_RATES: %w", err)
+		}
+		config.Rates = rates
+	}
+	if raw := os.Getenv("TRANSFER_INTENT_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid TRANSFER_INTENT_TTL %q: must be a positive duration", raw)
+		}
+		config.TransferIntentTTL = ttl
+	}
+	if raw := os.Getenv("INTEREST_ACCRUAL_INTERVAL"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid INTEREST_ACCRUAL_INTERVAL %q: must be a positive duration", raw)
+		}
+		config.InterestAccrualInterval = interval
+	}
+	switch raw := os.Getenv("WALLET_CACHE"); raw {
+	case "", "none":
+	case "redis":
+		url := os.Getenv("REDIS_URL")
+		if url == "" {
+			return WalletServiceConfig{}, fmt.Errorf("WALLET_CACHE=redis requires REDIS_URL")
+		}
+		cache, err := NewRedisWalletCache(url)
+		if err != nil {
+			return WalletServiceConfig{}, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		config.Cache = cache
+	default:
+		return WalletServiceConfig{}, fmt.Errorf("invalid WALLET_CACHE %q: must be redis", raw)
+	}
+	if raw := os.Getenv("WALLET_CACHE_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid WALLET_CACHE_TTL %q: must be a positive duration", raw)
+		}
+		config.CacheTTL = ttl
+	}
+	switch raw := os.Getenv("NOTIFIER"); raw {
+	case "", "none":
+	case "log":
+		config.Notifier = LogNotifier{}
+	case "webhook":
+		url := os.Getenv("NOTIFIER_WEBHOOK_URL")
+		if url == "" {
+			return WalletServiceConfig{}, fmt.Errorf("NOTIFIER=webhook requires NOTIFIER_WEBHOOK_URL")
+		}
+		config.Notifier = NewWebhookNotifier(url)
+	default:
+		return WalletServiceConfig{}, fmt.Errorf("invalid NOTIFIER %q: must be log or webhook", raw)
+	}
+	if raw := os.Getenv("RISK_LARGE_AMOUNT"); raw != "" {
+		threshold, err := money.Parse(raw)
+		if err != nil || threshold <= 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid RISK_LARGE_AMOUNT %q: must be a positive amount", raw)
+		}
+		config.RiskRules = append(config.RiskRules, LargeAmountRule{Threshold: threshold})
+	}
+	if raw := os.Getenv("RISK_MAX_NEW_RECIPIENTS_PER_HOUR"); raw != "" {
+		max, err := strconv.Atoi(raw)
+		if err != nil || max <= 0 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid RISK_MAX_NEW_RECIPIENTS_PER_HOUR %q: must be a positive integer", raw)
+		}
+		config.RiskRules = append(config.RiskRules, NewRecipientsRule{Max: max, Window: time.Hour})
+	}
+	if raw := os.Getenv("RISK_BALANCE_DRAIN_PERCENT"); raw != "" {
+		percent, err := strconv.Atoi(raw)
+		if err != nil || percent <= 0 || percent >= 100 {
+			return WalletServiceConfig{}, fmt.Errorf("invalid RISK_BALANCE_DRAIN_PERCENT %q: must be an integer between 1 and 99", raw)
+		}
+		config.RiskRules = append(config.RiskRules, BalanceDrainRule{Percent: percent})
+	}
+	return config, nil
+}
+
+// Business failures returned by wallet operations. Callers should match them
+// with errors.Is, as they are usually wrapped with details about the request.
+var (
+	ErrInsufficientBalance  = repositories.ErrInsufficientBalance
+	ErrSelfTransfer         = errors.New("cannot self transfer")
+	ErrWalletNotFound       = repositories.ErrWalletNotFound
+	ErrUserNotFound         = repositories.ErrUserNotFound
+	ErrInvalidAmount        = errors.New("invalid amount")
+	ErrInvalidReference     = errors.New("invalid external reference")
+	ErrInvalidDescription   = errors.New("invalid description")
+	ErrInvalidMetadata      = errors.New("invalid metadata")
+	ErrInvalidReason        = errors.New("invalid reason")
+	ErrDailyLimitExceeded   = errors.New("daily withdrawal limit exceeded")
+	ErrMonthlyLimitExceeded = errors.New("monthly transfer limit exceeded")
+	ErrTooManyOperations    = errors.New("too many operations")
+	ErrHoldNotFound         = repositories.ErrHoldNotFound
+	ErrHoldNotActive        = repositories.ErrHoldNotActive
+	ErrUnsupportedCurrency  = money.ErrUnsupportedCurrency
+	ErrCurrencyMismatch     = errors.New("wallets hold different currencies")
+)
+
+// ErrCommitFailed is returned when all steps of an operation succeeded but the
+// database transaction could not be committed, so no balance was changed
+var ErrCommitFailed = errors.New("failed to commit transaction")
+
+// ErrTxConflict is returned when an operation kept failing due to concurrent
+// updates (serialization failures or deadlocks) and retries were exhausted
+var ErrTxConflict = errors.New("operation aborted due to concurrent updates")
+
+// ErrIdempotencyKeyConflict is returned when an idempotency key is reused for an
+// operation that differs from the one first recorded under it
+var ErrIdempotencyKeyConflict = errors.New("idempotency key was already used for a different request")
+
+// ErrExternalReferenceConflict is returned when a payment provider reference that was
+// already processed arrives again for a different wallet or amount
+var ErrExternalReferenceConflict = errors.New("external reference was already used for a different deposit")
+
+// Default retry policy for transactions that hit serialization failures or deadlocks
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 20 * time.Millisecond
+)
+
+// maxDescriptionLength is the longest description, in characters, a transaction can carry
+const maxDescriptionLength = 255
+
+// Limits on the metadata attached to a transaction
+const (
+	maxMetadataKeys = 10
+	maxMetadataSize = 4096 // bytes, once encoded as JSON
+)
+
+// rollbackTimeout bounds how long a rollback may take once the caller's context is gone
+const rollbackTimeout = 5 * time.Second
 
 // Interfaces for dependency injection
 type WalletRepo interface {
 	GetWalletByUserID(ctx context.Context, userID string) (*models.Wallet, error)
 	GetWalletByUserIDTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error)
-	UpdateWalletBalanceTx(ctx context.Context, tx pgx.Tx, userID string, newBalance float64) error
+	GetWalletForUpdateTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error)
+	GetWalletByID(ctx context.Context, walletID string) (*models.Wallet, error)
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+	GetWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string) (*models.Wallet, error)
+	GetWalletsByUserID(ctx context.Context, userID string) ([]models.Wallet, error)
+	UpdateWalletBalanceTx(ctx context.Context, tx pgx.Tx, userID string, newBalance money.Amount, expectedVersion int64) error
+	UpdateWalletBalanceByIDTx(ctx context.Context, tx pgx.Tx, walletID string, newBalance money.Amount, expectedVersion int64) error
+	DebitWalletTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error)
+	DebitWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string, amount money.Amount) (*models.Wallet, error)
+	CreditWalletTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error)
+	CreditWalletByIDTx(ctx context.Context, tx pgx.Tx, walletID string, amount money.Amount) (*models.Wallet, error)
+	AcquireWalletLockTx(ctx context.Context, tx pgx.Tx, walletKey string) error
+	CreateWalletTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error)
+	CreateNamedWallet(ctx context.Context, userID, name, currency string) (*models.Wallet, error)
+	SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error)
+	HoldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error)
+	CaptureHeldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error)
+	ReleaseHeldFundsTx(ctx context.Context, tx pgx.Tx, userID string, amount money.Amount) (*models.Wallet, error)
+	CreateHoldTx(ctx context.Context, tx pgx.Tx, h *models.Hold) error
+	GetHoldTx(ctx context.Context, tx pgx.Tx, id string) (*models.Hold, error)
+	FinalizeHoldTx(ctx context.Context, tx pgx.Tx, id string, status models.HoldStatus) (*models.Hold, error)
+	SetWalletStatusTx(ctx context.Context, tx pgx.Tx, userID string, status models.WalletStatus) (*models.Wallet, error)
+	CreateWalletStatusChangeTx(ctx context.Context, tx pgx.Tx, change *models.WalletStatusChange) error
+	CloseWalletTx(ctx context.Context, tx pgx.Tx, userID string) (*models.Wallet, error)
+	GetWalletsByUserIDForUpdateTx(ctx context.Context, tx pgx.Tx, userID string) ([]models.Wallet, error)
+	CloseWalletsByUserIDTx(ctx context.Context, tx pgx.Tx, userID string) ([]models.Wallet, error)
+	SetUserKycTier(ctx context.Context, userID string, tier models.KycTier) (*models.User, error)
+	GetUserKycTierTx(ctx context.Context, tx pgx.Tx, userID string) (models.KycTier, error)
+	GetKycTierLimitsTx(ctx context.Context, tx pgx.Tx) ([]models.KycTierLimits, error)
+	IsUserEmailVerifiedTx(ctx context.Context, tx pgx.Tx, userID string) (bool, error)
+	SetInterestRate(ctx context.Context, userID, walletID string, rateBps int) (*models.Wallet, error)
+	GetInterestBearingWallets(ctx context.Context) ([]models.Wallet, error)
 }
 
 type TransactionRepo interface {
 	CreateTransactionTx(ctx context.Context, tx pgx.Tx, t *models.Transaction) error
+	CreateLedgerEntriesTx(ctx context.Context, tx pgx.Tx, entries []models.LedgerEntry) error
+	GetLedgerReport(ctx context.Context, userID string) (*models.LedgerReport, error)
+	GetBalanceDiscrepancies(ctx context.Context) ([]models.Discrepancy, error)
+	GetTransactionSummary(ctx context.Context, walletID string, from, to time.Time) (*models.WalletSummary, error)
+	GetWalletTotals(ctx context.Context, walletID string) (*models.WalletTotals, error)
+	GetStatementTransactions(ctx context.Context, walletID string, from, to time.Time, limit, offset int) ([]models.Transaction, int, error)
+	CreateTransactionPartition(ctx context.Context, month time.Time) (string, error)
+	GetTransactionByID(ctx context.Context, id string) (*models.Transaction, error)
+	GetBalanceAfterTransaction(ctx context.Context, t *models.Transaction) (money.Amount, error)
+	GetTransactionByIdempotencyKeyTx(ctx context.Context, tx pgx.Tx, userID, key string) (*models.Transaction, error)
+	GetTransactionByWalletIdempotencyKeyTx(ctx context.Context, tx pgx.Tx, walletID, key string) (*models.Transaction, error)
+	CreateExternalTransactionTx(ctx context.Context, tx pgx.Tx, t *models.Transaction) (bool, error)
+	GetTransactionByExternalReferenceTx(ctx context.Context, tx pgx.Tx, reference string) (*models.Transaction, error)
+	GetTransactionsByTransferIDTx(ctx context.Context, tx pgx.Tx, transferID string) ([]models.Transaction, error)
+	CreateTransferReversalTx(ctx context.Context, tx pgx.Tx, reversal *models.TransferReversal) error
+	SumTransactionsSince(ctx context.Context, tx pgx.Tx, walletID string, types []models.TransactionType, since time.Time) (money.Amount, error)
+	CountTransactionsSince(ctx context.Context, tx pgx.Tx, walletID string, types []models.TransactionType, since time.Time) (int, *time.Time, error)
+	SumUserTransactionsSince(ctx context.Context, tx pgx.Tx, userID string, types []models.TransactionType, since time.Time) (money.Amount, error)
+	CreateTransferIntent(ctx context.Context, intent *models.TransferIntent) error
+	GetTransferIntentForUpdateTx(ctx context.Context, tx pgx.Tx, id string) (*models.TransferIntent, error)
+	UpdateTransferIntentTx(ctx context.Context, tx pgx.Tx, intent *models.TransferIntent) error
+	ExpireTransferIntents(ctx context.Context) (int64, error)
+	CountNewRecipientsSince(ctx context.Context, tx pgx.Tx, walletID string, since time.Time) (int, error)
+	CreateRiskFlagTx(ctx context.Context, tx pgx.Tx, flag *models.RiskFlag) error
+	GetRiskFlags(ctx context.Context, filter models.RiskFlagFilter) ([]models.RiskFlag, error)
+	HasInterestAccrualTx(ctx context.Context, tx pgx.Tx, walletID string, date time.Time) (bool, error)
+	CreateInterestAccrualTx(ctx context.Context, tx pgx.Tx, accrual *models.InterestAccrual) error
 }
 
 type DB interface {
@@ -37,176 +394,499 @@ type WalletService struct {
 	walletRepo      WalletRepo
 	transactionRepo TransactionRepo
 	db              DB
+	minAmount       money.Amount
+	maxAmount       money.Amount
+	optimistic      bool
+	fees            FeePolicy
+	dailyLimit      money.Amount
+	monthlyLimit    money.Amount
+	maxOperations   int
+	rates           RateProvider
+	intentTTL       time.Duration
+	notifier        Notifier
+	lowBalance      money.Amount
+	riskRules       []RiskRule
+	kycLimits       bool
+	unverifiedLimit money.Amount
+	// accrualInterval is how often RunInterestWorker accrues interest
+	accrualInterval time.Duration
+	referralBonus   money.Amount
+	cache           WalletCache
+	cacheTTL        time.Duration
+	retryAttempts   int
+	retryBaseDelay  time.Duration
+	// now tells the time daily and monthly limits are counted from
+	now func() time.Time
 }
 
-// NewWalletService creates a new WalletService with the given dependencies
-func NewWalletService(walletRepo WalletRepo, transactionRepo TransactionRepo, db DB) *WalletService {
+// NewWalletService creates a new WalletService with the given dependencies and limits
+func NewWalletService(walletRepo WalletRepo, transactionRepo TransactionRepo, db DB, config WalletServiceConfig) *WalletService {
+	if config.MinAmount <= 0 {
+		config.MinAmount = MIN_AMOUNT
+	}
+	if config.MaxAmount <= 0 {
+		config.MaxAmount = MAX_AMOUNT
+	}
+	if config.TransferIntentTTL <= 0 {
+		config.TransferIntentTTL = defaultTransferIntentTTL
+	}
+	if config.Notifier == nil {
+		config.Notifier = noopNotifier{}
+	}
+	if config.InterestAccrualInterval <= 0 {
+		config.InterestAccrualInterval = defaultInterestAccrualInterval
+	}
+	if config.Cache == nil {
+		config.Cache = noopWalletCache{}
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = defaultWalletCacheTTL
+	}
 	return &WalletService{
 		walletRepo:      walletRepo,
 		transactionRepo: transactionRepo,
 		db:              db,
+		minAmount:       config.MinAmount,
+		maxAmount:       config.MaxAmount,
+		optimistic:      config.OptimisticLocking,
+		fees:            config.Fees,
+		dailyLimit:      config.DailyWithdrawalLimit,
+		monthlyLimit:    config.MonthlyTransferLimit,
+		maxOperations:   config.MaxOperationsPerMinute,
+		rates:           config.Rates,
+		intentTTL:       config.TransferIntentTTL,
+		notifier:        config.Notifier,
+		lowBalance:      config.LowBalanceThreshold,
+		riskRules:       config.RiskRules,
+		kycLimits:       config.EnforceKycLimits,
+		unverifiedLimit: config.UnverifiedEmailLimit,
+		accrualInterval: config.InterestAccrualInterval,
+		referralBonus:   config.ReferralBonus,
+		cache:           config.Cache,
+		cacheTTL:        config.CacheTTL,
+		retryAttempts:   defaultRetryAttempts,
+		retryBaseDelay:  defaultRetryBaseDelay,
+		now:             time.Now,
 	}
 }
 
-// GetWallet retrieves a wallet by user ID
+// GetWallet retrieves a wallet by user ID, with its available balance (the balance
+// minus funds reserved by active holds) filled in. The wallet may come from the
+// service's WalletCache, up to its TTL old.
 func (s *WalletService) GetWallet(ctx context.Context, userID string) (*models.Wallet, error) {
-	log := logger.WithUser(userID).WithField("operation", "get_wallet")
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "get_wallet")
 	log.Info("Getting wallet for user")
 
-	wallet, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+	wallet, err := s.cache.Get(ctx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to read cached wallet")
+	}
+	if wallet != nil {
+		log.WithField("balance", wallet.Balance).Info("Successfully retrieved cached wallet")
+		return wallet, nil
+	}
+
+	wallet, err = s.walletRepo.GetWalletByUserID(ctx, userID)
 	if err != nil {
 		log.WithField("error", err.Error()).Error("Failed to get wallet")
 		return nil, err
 	}
+	wallet.AvailableBalance = wallet.Balance - wallet.HeldAmount
+	if err := s.cache.Set(ctx, userID, wallet, s.cacheTTL); err != nil {
+		log.WithField("error", err.Error()).Warn("Failed to cache wallet")
+	}
 
 	log.WithField("balance", wallet.Balance).Info("Successfully retrieved wallet")
 	return wallet, nil
 }
 
-// Transfer transfers money from one user to another
-func (s *WalletService) Transfer(ctx context.Context, fromUserID, toUserID string, amount float64) (err error) {
+// Transfer transfers money from one user to another, charging the sender the fee set by
+// the service's FeePolicy on top of amount. A non-empty idempotencyKey makes retries
+// safe: a transfer already recorded under the key is not applied again, and the result
+// of the original transfer is returned. The optional description and metadata are
+// recorded on both legs of the transfer.
+func (s *WalletService) Transfer(ctx context.Context, fromUserID, toUserID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
 	log := logger.WithFields(logrus.Fields{
 		"from_user_id": fromUserID,
 		"to_user_id":   toUserID,
 		"amount":       amount,
 		"operation":    "transfer",
-	})
+	}).WithContext(ctx)
+	return s.transferFunds(ctx, log, defaultWallet(fromUserID), defaultWallet(toUserID), amount, idempotencyKey, description, metadata)
+}
 
+// transferFunds validates a transfer between the wallets of two different users, works
+// out its fee and applies it, re-running it when it hits a transaction conflict
+func (s *WalletService) transferFunds(ctx context.Context, log *logrus.Entry, from, to walletRef, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
 	log.Info("Starting transfer operation")
 
-	if err := ValidateAmount(amount); err != nil {
+	if err := s.ValidateAmount(amount); err != nil {
 		log.WithField("validation_error", err.Error()).Warn("Transfer validation failed")
-		return err
+		return nil, err
 	}
 
-	if fromUserID == toUserID {
+	if from.userID == to.userID {
 		log.Warn("Self-transfer attempt blocked")
-		return errors.New("cannot self transfer")
+		return nil, ErrSelfTransfer
 	}
-
-	tx, err := s.db.Begin(ctx)
+	memo, err := normalizeDescription(description)
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to begin transaction")
-		return err
+		log.WithField("validation_error", err.Error()).Warn("Transfer validation failed")
+		return nil, err
+	}
+	if err := validateMetadata(metadata); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Transfer validation failed")
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			log.WithField("error", err.Error()).Error("Transfer failed, rolling back transaction")
-			tx.Rollback(ctx)
-		} else {
-			log.Info("Transfer successful, committing transaction")
-			tx.Commit(ctx)
-		}
-	}()
 
-	fromWallet, err := s.walletRepo.GetWalletByUserIDTx(ctx, tx, fromUserID)
-	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to get from user wallet")
-		return err
+	// Both legs of the transfer share one ID, kept stable across retries
+	transferID := uuid.New()
+	log = log.WithField("transfer_id", transferID.String())
+	ctx = withLogger(ctx, log)
+
+	fee := s.transferFee(from.userID, amount)
+	if fee > 0 {
+		log = log.WithField("fee", fee)
 	}
 
-	toWallet, err := s.walletRepo.GetWalletByUserIDTx(ctx, tx, toUserID)
+	var result *models.TransferResult
+	var events walletEvents
+	err = s.retryTx(ctx, log, func() error {
+		events = walletEvents{}
+		return withTx(ctx, s.db, log, "transfer", func(tx pgx.Tx) (err error) {
+			result, err = s.transfer(ctx, tx, log, transferID, from, to, amount, fee, idempotencyKey, memo, metadata, &events)
+			return err
+		})
+	})
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to get to user wallet")
-		return err
+		return nil, err
 	}
-
-	if fromWallet.Balance < amount {
-		log.WithFields(logrus.Fields{
-			"from_balance": fromWallet.Balance,
-			"amount":       amount,
-		}).Warn("Insufficient balance for transfer")
-		return errors.New("insufficient balance")
+	s.invalidateWallets(ctx, from.userID, to.userID)
+	if fee > 0 {
+		s.invalidateWallets(ctx, s.fees.WalletUserID)
 	}
+	s.notify(ctx, events)
+	return result, nil
+}
 
-	log.WithFields(logrus.Fields{
-		"from_balance_before": fromWallet.Balance,
-		"to_balance_before":   toWallet.Balance,
-	}).Debug("Updating wallet balances")
-
-	err = s.walletRepo.UpdateWalletBalanceTx(ctx, tx, fromUserID, fromWallet.Balance-amount)
-	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to update from user balance")
-		return err
+// transferFee is the fee fromUserID pays to transfer amount
+func (s *WalletService) transferFee(fromUserID string, amount money.Amount) money.Amount {
+	if fromUserID == s.fees.WalletUserID {
+		// The fee wallet does not pay fees to itself
+		return 0
 	}
+	return s.fees.Fee(amount)
+}
 
-	err = s.walletRepo.UpdateWalletBalanceTx(ctx, tx, toUserID, toWallet.Balance+amount)
-	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to update to user balance")
-		return err
+// transfer runs a single attempt of Transfer in tx, collecting what it will notify in events
+func (s *WalletService) transfer(ctx context.Context, tx pgx.Tx, log *logrus.Entry, transferID uuid.UUID, from, to walletRef, amount, fee money.Amount, idempotencyKey string, description *string, metadata map[string]string, events *walletEvents) (result *models.TransferResult, err error) {
+	fromUserID, toUserID := from.userID, to.userID
+	// Fees are collected in the default wallet of the fee user
+	feeRef := defaultWallet(s.fees.WalletUserID)
+	wallets := []walletRef{from, to}
+	if fee > 0 && feeRef != to {
+		wallets = append(wallets, feeRef)
+	}
+	if err = s.lockWallets(ctx, tx, walletOwners(wallets)...); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallets")
+		return nil, err
 	}
 
-	// Record transactions
-	_ = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
-		WalletID:      fromWallet.ID,
+	recorded, err := s.findIdempotentTransaction(ctx, tx, from, idempotencyKey, models.Transaction{
 		Type:          models.TransactionTypeTransferOut,
 		Amount:        amount,
 		RelatedUserID: &toUserID,
 	})
-	_ = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Idempotency key check failed")
+		return nil, err
+	}
+	if recorded != nil {
+		log.WithField("original_transfer_id", recorded.TransferID).Info("Transfer already recorded under idempotency key, not applying again")
+		return s.recordedTransfer(ctx, tx, from, recorded)
+	}
+
+	// Apply the debits and credits in a consistent wallet order regardless of the
+	// transfer direction, so the row locks taken by the UPDATEs are always acquired
+	// in the same order and opposing transfers (A->B and B->A) cannot deadlock. The
+	// sender is debited the amount and the fee at once, so the balance check covers both.
+	var fromWallet, toWallet, feeWallet *models.Wallet
+	for _, ref := range walletOrder(wallets...) {
+		switch ref {
+		case from:
+			fromWallet, err = s.debit(ctx, tx, from, amount+fee)
+			if errors.Is(err, ErrInsufficientBalance) {
+				log.Warn("Insufficient balance for transfer")
+				return nil, fmt.Errorf("%w: %s cannot cover %s", ErrInsufficientBalance, from, amount+fee)
+			}
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to update from user balance")
+				return nil, err
+			}
+			if err = checkActive(fromWallet, from); err != nil {
+				log.WithField("status", fromWallet.Status).Warn("Transfer rejected, from wallet is not active")
+				return nil, err
+			}
+			if err = checkPrecision(fromWallet, amount); err != nil {
+				log.WithField("currency", fromWallet.Currency).Warn("Transfer rejected, amount does not fit the wallet's currency")
+				return nil, err
+			}
+			if err = s.checkVelocity(ctx, tx, fromWallet); err != nil {
+				log.WithField("error", err.Error()).Warn("Too many operations on the from wallet")
+				return nil, err
+			}
+			if err = s.checkMonthlyTransferLimit(ctx, tx, fromWallet, amount); err != nil {
+				log.WithField("error", err.Error()).Warn("Monthly transfer limit exceeded")
+				return nil, err
+			}
+			if err = s.checkKycLimits(ctx, tx, fromUserID, amount); err != nil {
+				log.WithField("error", err.Error()).Warn("Transfer rejected by KYC tier limits")
+				return nil, err
+			}
+			if err = s.checkEmailVerified(ctx, tx, fromUserID, amount); err != nil {
+				log.WithField("error", err.Error()).Warn("Transfer rejected, email not verified")
+				return nil, err
+			}
+		case to:
+			toWallet, err = s.credit(ctx, tx, to, amount)
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to update to user balance")
+				return nil, err
+			}
+			if err = checkActive(toWallet, to); err != nil {
+				log.WithField("status", toWallet.Status).Warn("Transfer rejected, to wallet is not active")
+				return nil, err
+			}
+		}
+		if fee > 0 && ref == feeRef {
+			feeWallet, err = s.credit(ctx, tx, feeRef, fee)
+			if err != nil {
+				log.WithField("error", err.Error()).Error("Failed to credit fee wallet")
+				return nil, err
+			}
+		}
+	}
+	// Amounts are not converted between currencies yet, so every wallet taking part,
+	// including the one collecting the fee, must hold the sender's currency
+	if toWallet.Currency != fromWallet.Currency {
+		log.WithFields(logrus.Fields{"from_currency": fromWallet.Currency, "to_currency": toWallet.Currency}).Warn("Transfer rejected, wallets hold different currencies")
+		return nil, fmt.Errorf("%w: %s holds %s, %s holds %s", ErrCurrencyMismatch, from, fromWallet.Currency, to, toWallet.Currency)
+	}
+	if feeWallet != nil && feeWallet.Currency != fromWallet.Currency {
+		log.WithFields(logrus.Fields{"from_currency": fromWallet.Currency, "fee_currency": feeWallet.Currency}).Warn("Transfer rejected, fees are collected in another currency")
+		return nil, fmt.Errorf("%w: fees are collected in %s, %s holds %s", ErrCurrencyMismatch, feeWallet.Currency, from, fromWallet.Currency)
+	}
+
+	// Record transactions
+	out := &models.Transaction{
+		WalletID:       fromWallet.ID,
+		Type:           models.TransactionTypeTransferOut,
+		Status:         models.TransactionStatusCompleted,
+		Amount:         amount,
+		RelatedUserID:  &toUserID,
+		TransferID:     &transferID,
+		IdempotencyKey: optionalKey(idempotencyKey),
+		Description:    description,
+		Metadata:       metadata,
+	}
+	err = s.transactionRepo.CreateTransactionTx(ctx, tx, out)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record transfer out transaction")
+		return nil, err
+	}
+	in := &models.Transaction{
 		WalletID:      toWallet.ID,
 		Type:          models.TransactionTypeTransferIn,
+		Status:        models.TransactionStatusCompleted,
 		Amount:        amount,
 		RelatedUserID: &fromUserID,
+		TransferID:    &transferID,
+		Description:   description,
+		Metadata:      metadata,
+	}
+	err = s.transactionRepo.CreateTransactionTx(ctx, tx, in)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record transfer in transaction")
+		return nil, err
+	}
+	entries := []models.LedgerEntry{
+		walletLedgerEntry(transferID, fromWallet.ID, models.LedgerDebit, amount),
+		walletLedgerEntry(transferID, toWallet.ID, models.LedgerCredit, amount),
+	}
+	if fee > 0 {
+		if err = s.recordFee(ctx, tx, transferID, fromUserID, fromWallet.ID, feeWallet.ID, fee); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to record transfer fee")
+			return nil, err
+		}
+		entries = append(entries,
+			walletLedgerEntry(transferID, fromWallet.ID, models.LedgerDebit, fee),
+			walletLedgerEntry(transferID, feeWallet.ID, models.LedgerCredit, fee),
+		)
+	}
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, entries)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record transfer ledger entries")
+		return nil, err
+	}
+	now := s.now()
+	err = s.checkRisk(ctx, tx, log, RiskOperation{
+		Type:          models.TransactionTypeTransferOut,
+		UserID:        fromUserID,
+		Wallet:        fromWallet,
+		TransactionID: out.ID,
+		Amount:        amount,
+		RelatedUserID: &toUserID,
+		At:            now,
 	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check transfer against risk rules")
+		return nil, err
+	}
 
 	log.WithFields(logrus.Fields{
-		"from_balance_after": fromWallet.Balance - amount,
-		"to_balance_after":   toWallet.Balance + amount,
+		"from_balance_after": fromWallet.Balance,
+		"to_balance_after":   toWallet.Balance,
 	}).Info("Transfer completed successfully")
 
-	return nil
+	events.transferReceived(transferID, fromUserID, toUserID, toWallet, amount, description, now)
+	events.debited(fromUserID, fromWallet, amount+fee, s.lowBalance, now)
+	return &models.TransferResult{
+		TransferID:       transferID,
+		OutTransactionID: out.ID,
+		InTransactionID:  in.ID,
+		Amount:           amount,
+		Fee:              fee,
+		BalanceAfter:     fromWallet.Balance,
+	}, nil
 }
 
-// Deposit adds money to a user's wallet
-func (s *WalletService) Deposit(ctx context.Context, userID string, amount float64) (*models.Wallet, error) {
-	log := logger.WithUser(userID).WithFields(logrus.Fields{
+// Deposit adds money to a user's wallet, returning the wallet and the transaction
+// recorded for the deposit. When idempotencyKey is non-empty and a deposit was already
+// recorded under it, the wallet and that transaction are returned without crediting it again.
+func (s *WalletService) Deposit(ctx context.Context, userID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
 		"operation": "deposit",
 		"amount":    amount,
 	})
+	return s.depositInto(ctx, log, defaultWallet(userID), amount, idempotencyKey, description, metadata)
+}
+
+// depositInto validates a deposit into a wallet and applies it, re-running it when it
+// hits a transaction conflict
+func (s *WalletService) depositInto(ctx context.Context, log *logrus.Entry, ref walletRef, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
 	log.Info("Starting deposit operation")
+	ctx = withLogger(ctx, log)
 
-	if err := ValidateAmount(amount); err != nil {
+	if err := s.ValidateAmount(amount); err != nil {
 		log.WithField("validation_error", err.Error()).Warn("Deposit validation failed")
-		return nil, err
+		return nil, nil, err
 	}
-
-	tx, err := s.db.Begin(ctx)
+	memo, err := normalizeDescription(description)
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to begin transaction")
-		return nil, err
+		log.WithField("validation_error", err.Error()).Warn("Deposit validation failed")
+		return nil, nil, err
+	}
+	if err := validateMetadata(metadata); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Deposit validation failed")
+		return nil, nil, err
 	}
-	defer func() {
-		if err != nil {
-			log.WithField("error", err.Error()).Error("Deposit failed, rolling back transaction")
-			tx.Rollback(ctx)
-		} else {
-			log.Info("Deposit successful, committing transaction")
-			tx.Commit(ctx)
-		}
-	}()
 
-	wallet, err := s.walletRepo.GetWalletByUserIDTx(ctx, tx, userID)
+	var wallet *models.Wallet
+	var recorded *models.Transaction
+	err = s.retryTx(ctx, log, func() error {
+		return withTx(ctx, s.db, log, "deposit", func(tx pgx.Tx) (err error) {
+			wallet, recorded, err = s.deposit(ctx, tx, log, ref, amount, idempotencyKey, memo, metadata)
+			return err
+		})
+	})
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to get wallet for deposit")
-		return nil, err
+		return nil, nil, err
 	}
+	s.invalidateWallets(ctx, ref.userID)
+	return wallet, recorded, nil
+}
 
-	log.WithField("balance_before", wallet.Balance).Debug("Processing deposit")
+// deposit runs a single attempt of Deposit in tx
+func (s *WalletService) deposit(ctx context.Context, tx pgx.Tx, log *logrus.Entry, ref walletRef, amount money.Amount, idempotencyKey string, description *string, metadata map[string]string) (wallet *models.Wallet, recorded *models.Transaction, err error) {
+	if err = s.lockWallets(ctx, tx, ref.userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, nil, err
+	}
 
-	newBalance := wallet.Balance + amount
-	err = s.walletRepo.UpdateWalletBalanceTx(ctx, tx, userID, newBalance)
+	recorded, err = s.findIdempotentTransaction(ctx, tx, ref, idempotencyKey, models.Transaction{
+		Type:   models.TransactionTypeDeposit,
+		Amount: amount,
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Idempotency key check failed")
+		return nil, nil, err
+	}
+	if recorded != nil {
+		log.WithField("original_transaction_id", recorded.ID.String()).Info("Deposit already recorded under idempotency key, not applying again")
+		wallet, err = s.getWalletTx(ctx, tx, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wallet, recorded, nil
+	}
+
+	wallet, err = s.credit(ctx, tx, ref, amount)
 	if err != nil {
 		log.WithField("error", err.Error()).Error("Failed to update wallet balance")
-		return nil, err
+		return nil, nil, err
+	}
+	if err = checkActive(wallet, ref); err != nil {
+		log.WithField("status", wallet.Status).Warn("Deposit rejected, wallet is not active")
+		return nil, nil, err
+	}
+	if err = checkPrecision(wallet, amount); err != nil {
+		log.WithField("currency", wallet.Currency).Warn("Deposit rejected, amount does not fit the wallet's currency")
+		return nil, nil, err
+	}
+	if err = s.checkVelocity(ctx, tx, wallet); err != nil {
+		log.WithField("error", err.Error()).Warn("Too many operations on the wallet")
+		return nil, nil, err
+	}
+	if err = s.checkKycLimits(ctx, tx, ref.userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Deposit rejected by KYC tier limits")
+		return nil, nil, err
 	}
 
-	wallet.Balance = newBalance
-	_ = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
-		WalletID: wallet.ID,
-		Type:     models.TransactionTypeDeposit,
-		Amount:   amount,
+	recorded = &models.Transaction{
+		WalletID:       wallet.ID,
+		Type:           models.TransactionTypeDeposit,
+		Status:         models.TransactionStatusCompleted,
+		Amount:         amount,
+		IdempotencyKey: optionalKey(idempotencyKey),
+		Description:    description,
+		Metadata:       metadata,
+	}
+	err = s.transactionRepo.CreateTransactionTx(ctx, tx, recorded)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record deposit transaction")
+		return nil, nil, err
+	}
+	groupID := uuid.New()
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		cashLedgerEntry(groupID, models.LedgerDebit, amount),
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerCredit, amount),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record deposit ledger entries")
+		return nil, nil, err
+	}
+	err = s.checkRisk(ctx, tx, log, RiskOperation{
+		Type:          models.TransactionTypeDeposit,
+		UserID:        ref.userID,
+		Wallet:        wallet,
+		TransactionID: recorded.ID,
+		Amount:        amount,
+		At:            s.now(),
 	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check deposit against risk rules")
+		return nil, nil, err
+	}
 
 	log.WithFields(logrus.Fields{
 		"balance_before": wallet.Balance - amount,
@@ -214,66 +894,284 @@ func (s *WalletService) Deposit(ctx context.Context, userID string, amount float
 		"deposit_amount": amount,
 	}).Info("Deposit completed successfully")
 
-	return wallet, nil
+	return wallet, recorded, nil
 }
 
-// Withdraw removes money from a user's wallet
-func (s *WalletService) Withdraw(ctx context.Context, userID string, amount float64) (*models.Wallet, error) {
-	log := logger.WithUser(userID).WithFields(logrus.Fields{
-		"operation": "withdraw",
-		"amount":    amount,
+// DepositExternal credits a deposit confirmed by a payment provider, identified by the
+// provider's reference for the payment. A reference that was already processed returns
+// the transaction recorded for it and leaves the balance untouched, even when duplicate
+// notifications are handled in parallel.
+func (s *WalletService) DepositExternal(ctx context.Context, userID string, amount money.Amount, reference string) (*models.Transaction, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":          "external_deposit",
+		"amount":             amount,
+		"external_reference": reference,
 	})
-	log.Info("Starting withdrawal operation")
+	log.Info("Starting external deposit operation")
+	ctx = withLogger(ctx, log)
 
-	if err := ValidateAmount(amount); err != nil {
-		log.WithField("validation_error", err.Error()).Warn("Withdrawal validation failed")
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("External deposit validation failed")
 		return nil, err
 	}
+	if reference == "" {
+		log.Warn("External deposit rejected, missing reference")
+		return nil, fmt.Errorf("%w: reference is required", ErrInvalidReference)
+	}
 
-	tx, err := s.db.Begin(ctx)
+	var recorded *models.Transaction
+	err := s.retryTx(ctx, log, func() error {
+		return withTx(ctx, s.db, log, "external deposit", func(tx pgx.Tx) (err error) {
+			recorded, err = s.depositExternal(ctx, tx, log, userID, amount, reference)
+			return err
+		})
+	})
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to begin transaction")
 		return nil, err
 	}
-	defer func() {
+	s.invalidateWallets(ctx, userID)
+	return recorded, nil
+}
+
+// depositExternal runs a single attempt of DepositExternal in tx. The transaction row is inserted before the wallet is credited, so the
+// unique external reference decides which of two parallel notifications credits it.
+func (s *WalletService) depositExternal(ctx context.Context, tx pgx.Tx, log *logrus.Entry, userID string, amount money.Amount, reference string) (recorded *models.Transaction, err error) {
+	if err = s.lockWallets(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+
+	wallet, err := s.walletRepo.GetWalletByUserIDTx(ctx, tx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+
+	recorded = &models.Transaction{
+		WalletID:          wallet.ID,
+		Type:              models.TransactionTypeDeposit,
+		Status:            models.TransactionStatusCompleted,
+		Amount:            amount,
+		ExternalReference: &reference,
+	}
+	created, err := s.transactionRepo.CreateExternalTransactionTx(ctx, tx, recorded)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record external deposit transaction")
+		return nil, err
+	}
+	if !created {
+		existing, err := s.transactionRepo.GetTransactionByExternalReferenceTx(ctx, tx, reference)
 		if err != nil {
-			log.WithField("error", err.Error()).Error("Withdrawal failed, rolling back transaction")
-			tx.Rollback(ctx)
-		} else {
-			log.Info("Withdrawal successful, committing transaction")
-			tx.Commit(ctx)
+			log.WithField("error", err.Error()).Error("Failed to read previously recorded external deposit")
+			return nil, err
 		}
-	}()
+		if existing.WalletID != wallet.ID || existing.Type != models.TransactionTypeDeposit || existing.Amount != amount {
+			log.WithField("original_transaction_id", existing.ID.String()).Warn("External reference reused for a different deposit")
+			return nil, fmt.Errorf("%w: reference %q", ErrExternalReferenceConflict, reference)
+		}
+		log.WithField("original_transaction_id", existing.ID.String()).Info("External deposit already processed, not crediting again")
+		return existing, nil
+	}
 
-	wallet, err := s.walletRepo.GetWalletByUserIDTx(ctx, tx, userID)
+	wallet, err = s.credit(ctx, tx, defaultWallet(userID), amount)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update wallet balance")
+		return nil, err
+	}
+	// Funds the provider already received still land in a frozen wallet, but a closed
+	// one must stay empty
+	if wallet.Status == models.WalletStatusClosed {
+		log.Warn("External deposit to a closed wallet rejected")
+		return nil, fmt.Errorf("%w: user %s", ErrWalletClosed, userID)
+	}
+	if err = checkPrecision(wallet, amount); err != nil {
+		log.WithField("currency", wallet.Currency).Warn("External deposit rejected, amount does not fit the wallet's currency")
+		return nil, err
+	}
+	// The deposit's transaction is already recorded, so the tier's caps count it
+	if err = s.checkRecordedKycLimits(ctx, tx, userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("External deposit rejected by KYC tier limits")
+		return nil, err
+	}
+	groupID := uuid.New()
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		cashLedgerEntry(groupID, models.LedgerDebit, amount),
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerCredit, amount),
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record external deposit ledger entries")
+		return nil, err
+	}
+	err = s.checkRisk(ctx, tx, log, RiskOperation{
+		Type:          models.TransactionTypeDeposit,
+		UserID:        userID,
+		Wallet:        wallet,
+		TransactionID: recorded.ID,
+		Amount:        amount,
+		At:            s.now(),
+	})
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to get wallet for withdrawal")
+		log.WithField("error", err.Error()).Error("Failed to check external deposit against risk rules")
 		return nil, err
 	}
 
-	log.WithField("balance_before", wallet.Balance).Debug("Processing withdrawal")
+	log.WithFields(logrus.Fields{
+		"transaction_id": recorded.ID.String(),
+		"balance_after":  wallet.Balance,
+	}).Info("External deposit completed successfully")
 
-	if wallet.Balance < amount {
-		log.WithFields(logrus.Fields{
-			"balance": wallet.Balance,
-			"amount":  amount,
-		}).Warn("Insufficient balance for withdrawal")
-		return nil, errors.New("insufficient balance")
+	return recorded, nil
+}
+
+// Withdraw removes money from a user's wallet, returning the wallet and the transaction
+// recorded for the withdrawal. When idempotencyKey is non-empty and a withdrawal was already
+// recorded under it, the wallet and that transaction are returned without debiting it again.
+func (s *WalletService) Withdraw(ctx context.Context, userID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation": "withdraw",
+		"amount":    amount,
+	})
+	return s.withdrawFrom(ctx, log, defaultWallet(userID), amount, idempotencyKey, description, metadata)
+}
+
+// withdrawFrom validates a withdrawal from a wallet and applies it, re-running it when
+// it hits a transaction conflict
+func (s *WalletService) withdrawFrom(ctx context.Context, log *logrus.Entry, ref walletRef, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	log.Info("Starting withdrawal operation")
+	ctx = withLogger(ctx, log)
+
+	if err := s.ValidateAmount(amount); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Withdrawal validation failed")
+		return nil, nil, err
+	}
+	memo, err := normalizeDescription(description)
+	if err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Withdrawal validation failed")
+		return nil, nil, err
+	}
+	if err := validateMetadata(metadata); err != nil {
+		log.WithField("validation_error", err.Error()).Warn("Withdrawal validation failed")
+		return nil, nil, err
+	}
+
+	var wallet *models.Wallet
+	var recorded *models.Transaction
+	var events walletEvents
+	err = s.retryTx(ctx, log, func() error {
+		events = walletEvents{}
+		return withTx(ctx, s.db, log, "withdrawal", func(tx pgx.Tx) (err error) {
+			wallet, recorded, err = s.withdraw(ctx, tx, log, ref, amount, idempotencyKey, memo, metadata, &events)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.invalidateWallets(ctx, ref.userID)
+	s.notify(ctx, events)
+	return wallet, recorded, nil
+}
+
+// withdraw runs a single attempt of Withdraw in tx, collecting what it will notify in events
+func (s *WalletService) withdraw(ctx context.Context, tx pgx.Tx, log *logrus.Entry, ref walletRef, amount money.Amount, idempotencyKey string, description *string, metadata map[string]string, events *walletEvents) (wallet *models.Wallet, recorded *models.Transaction, err error) {
+	if err = s.lockWallets(ctx, tx, ref.userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, nil, err
 	}
 
-	newBalance := wallet.Balance - amount
-	err = s.walletRepo.UpdateWalletBalanceTx(ctx, tx, userID, newBalance)
+	recorded, err = s.findIdempotentTransaction(ctx, tx, ref, idempotencyKey, models.Transaction{
+		Type:   models.TransactionTypeWithdraw,
+		Amount: amount,
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Idempotency key check failed")
+		return nil, nil, err
+	}
+	if recorded != nil {
+		log.WithField("original_transaction_id", recorded.ID.String()).Info("Withdrawal already recorded under idempotency key, not applying again")
+		wallet, err = s.getWalletTx(ctx, tx, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wallet, recorded, nil
+	}
+
+	// The debit only applies when the balance covers the amount, so the check
+	// and the write happen atomically in one statement
+	wallet, err = s.debit(ctx, tx, ref, amount)
+	if errors.Is(err, ErrInsufficientBalance) {
+		log.Warn("Insufficient balance for withdrawal")
+		return nil, nil, fmt.Errorf("%w: %s cannot cover %s", ErrInsufficientBalance, ref, amount)
+	}
 	if err != nil {
 		log.WithField("error", err.Error()).Error("Failed to update wallet balance")
-		return nil, err
+		return nil, nil, err
+	}
+	if err = checkActive(wallet, ref); err != nil {
+		log.WithField("status", wallet.Status).Warn("Withdrawal rejected, wallet is not active")
+		return nil, nil, err
+	}
+	if err = checkPrecision(wallet, amount); err != nil {
+		log.WithField("currency", wallet.Currency).Warn("Withdrawal rejected, amount does not fit the wallet's currency")
+		return nil, nil, err
+	}
+	// The wallet is locked, so operations counted here cannot race this one; under
+	// optimistic locking a concurrent operation fails the debit's version check instead
+	if err = s.checkVelocity(ctx, tx, wallet); err != nil {
+		log.WithField("error", err.Error()).Warn("Too many operations on the wallet")
+		return nil, nil, err
+	}
+	// Active holds count as withdrawn, like when they are placed, since capturing them
+	// does not check the limit again
+	if err = s.checkDailyWithdrawalLimit(ctx, tx, wallet, amount+wallet.HeldAmount); err != nil {
+		log.WithField("error", err.Error()).Warn("Daily withdrawal limit exceeded")
+		return nil, nil, err
+	}
+	if err = s.checkKycLimits(ctx, tx, ref.userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Withdrawal rejected by KYC tier limits")
+		return nil, nil, err
+	}
+	if err = s.checkEmailVerified(ctx, tx, ref.userID, amount); err != nil {
+		log.WithField("error", err.Error()).Warn("Withdrawal rejected, email not verified")
+		return nil, nil, err
 	}
 
-	wallet.Balance = newBalance
-	_ = s.transactionRepo.CreateTransactionTx(ctx, tx, &models.Transaction{
-		WalletID: wallet.ID,
-		Type:     models.TransactionTypeWithdraw,
-		Amount:   amount,
+	recorded = &models.Transaction{
+		WalletID:       wallet.ID,
+		Type:           models.TransactionTypeWithdraw,
+		Status:         models.TransactionStatusCompleted,
+		Amount:         amount,
+		IdempotencyKey: optionalKey(idempotencyKey),
+		Description:    description,
+		Metadata:       metadata,
+	}
+	err = s.transactionRepo.CreateTransactionTx(ctx, tx, recorded)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record withdrawal transaction")
+		return nil, nil, err
+	}
+	groupID := uuid.New()
+	err = s.transactionRepo.CreateLedgerEntriesTx(ctx, tx, []models.LedgerEntry{
+		walletLedgerEntry(groupID, wallet.ID, models.LedgerDebit, amount),
+		cashLedgerEntry(groupID, models.LedgerCredit, amount),
 	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record withdrawal ledger entries")
+		return nil, nil, err
+	}
+	now := s.now()
+	err = s.checkRisk(ctx, tx, log, RiskOperation{
+		Type:          models.TransactionTypeWithdraw,
+		UserID:        ref.userID,
+		Wallet:        wallet,
+		TransactionID: recorded.ID,
+		Amount:        amount,
+		At:            now,
+	})
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to check withdrawal against risk rules")
+		return nil, nil, err
+	}
 
 	log.WithFields(logrus.Fields{
 		"balance_before":  wallet.Balance + amount,
@@ -281,22 +1179,395 @@ func (s *WalletService) Withdraw(ctx context.Context, userID string, amount floa
 		"withdraw_amount": amount,
 	}).Info("Withdrawal completed successfully")
 
+	events.debited(ref.userID, wallet, amount, s.lowBalance, now)
+	return wallet, recorded, nil
+}
+
+// lockWallets takes the advisory locks of the given users' wallets in sorted order,
+// so operations on the same wallet are serialized for the rest of the transaction
+// and opposing transfers cannot deadlock. All of a user's wallets share one lock
+// keyed by the user ID, which is known before any wallet row is read. The optimistic
+// locking strategy deliberately runs without these locks.
+func (s *WalletService) lockWallets(ctx context.Context, tx pgx.Tx, userIDs ...string) error {
+	if s.optimistic {
+		return nil
+	}
+	for _, userID := range lockOrder(userIDs...) {
+		if err := s.walletRepo.AcquireWalletLockTx(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetOverdraftLimit lets a wallet go up to limit below zero; a limit of zero restores
+// the ordinary non-negative balance rule. Lowering the limit below what the wallet
+// already owes fails with ErrInsufficientBalance.
+func (s *WalletService) SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":       "set_overdraft_limit",
+		"overdraft_limit": limit,
+	})
+	log.Info("Setting overdraft limit")
+
+	if limit < 0 {
+		log.Warn("Negative overdraft limit rejected")
+		return nil, fmt.Errorf("%w: overdraft limit cannot be negative", ErrInvalidAmount)
+	}
+
+	wallet, err := s.walletRepo.SetOverdraftLimit(ctx, userID, limit)
+	if errors.Is(err, ErrInsufficientBalance) {
+		log.Warn("Overdraft limit is below the wallet's current overdraft")
+		return nil, fmt.Errorf("%w: wallet of user %s is overdrawn by more than %s", ErrInsufficientBalance, userID, limit)
+	}
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to set overdraft limit")
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+
+	log.WithField("balance", wallet.Balance).Info("Overdraft limit updated")
 	return wallet, nil
 }
 
-// ValidateAmount validates that an amount is within acceptable bounds
-func ValidateAmount(amount float64) error {
-	if math.IsNaN(amount) || math.IsInf(amount, 0) {
-		return errors.New("amount cannot be NaN or infinity")
+// VerifyLedger recomputes a wallet's balance from its ledger entries and compares it
+// with the stored balance. A report with Balanced false means the two have drifted.
+func (s *WalletService) VerifyLedger(ctx context.Context, userID string) (*models.LedgerReport, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "verify_ledger")
+
+	report, err := s.transactionRepo.GetLedgerReport(ctx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to read ledger balance")
+		return nil, err
+	}
+	report.Discrepancy = report.Balance - report.LedgerBalance
+	report.Balanced = report.Discrepancy == 0
+
+	if !report.Balanced {
+		log.WithFields(logrus.Fields{
+			"wallet_id":      report.WalletID.String(),
+			"balance":        report.Balance,
+			"ledger_balance": report.LedgerBalance,
+			"discrepancy":    report.Discrepancy,
+		}).Error("Wallet balance does not match ledger")
+	}
+	return report, nil
+}
+
+// Reconcile compares every wallet's balance with the net of its recorded transactions
+// and returns the wallets where they disagree. Each discrepancy is logged at Error level.
+func (s *WalletService) Reconcile(ctx context.Context) ([]models.Discrepancy, error) {
+	log := logger.WithField("operation", "reconcile").WithContext(ctx)
+	log.Info("Starting balance reconciliation")
+
+	discrepancies, err := s.transactionRepo.GetBalanceDiscrepancies(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to reconcile balances")
+		return nil, err
+	}
+
+	for _, d := range discrepancies {
+		log.WithFields(logrus.Fields{
+			"wallet_id":           d.WalletID.String(),
+			"user_id":             d.UserID.String(),
+			"balance":             d.Balance,
+			"transaction_balance": d.TransactionBalance,
+			"delta":               d.Delta,
+		}).Error("Wallet balance does not match its transactions")
+	}
+
+	log.WithField("discrepancies", len(discrepancies)).Info("Balance reconciliation completed")
+	return discrepancies, nil
+}
+
+// walletLedgerEntry builds a ledger entry against a user's wallet
+func walletLedgerEntry(groupID, walletID uuid.UUID, direction models.LedgerDirection, amount money.Amount) models.LedgerEntry {
+	return models.LedgerEntry{
+		GroupID:   groupID,
+		Account:   models.LedgerAccountWallet,
+		WalletID:  &walletID,
+		Direction: direction,
+		Amount:    amount,
+	}
+}
+
+// cashLedgerEntry builds a ledger entry against the system cash account, which
+// balances money entering (deposits) or leaving (withdrawals) the system
+func cashLedgerEntry(groupID uuid.UUID, direction models.LedgerDirection, amount money.Amount) models.LedgerEntry {
+	return models.LedgerEntry{
+		GroupID:   groupID,
+		Account:   models.LedgerAccountCash,
+		Direction: direction,
+		Amount:    amount,
+	}
+}
+
+// debit subtracts amount from a wallet using the configured locking strategy
+func (s *WalletService) debit(ctx context.Context, tx pgx.Tx, ref walletRef, amount money.Amount) (*models.Wallet, error) {
+	if s.optimistic {
+		return s.applyVersioned(ctx, tx, ref, -amount)
+	}
+	if ref.walletID != "" {
+		return s.walletRepo.DebitWalletByIDTx(ctx, tx, ref.walletID, amount)
 	}
+	return s.walletRepo.DebitWalletTx(ctx, tx, ref.userID, amount)
+}
+
+// credit adds amount to a wallet using the configured locking strategy
+func (s *WalletService) credit(ctx context.Context, tx pgx.Tx, ref walletRef, amount money.Amount) (*models.Wallet, error) {
+	if s.optimistic {
+		return s.applyVersioned(ctx, tx, ref, amount)
+	}
+	if ref.walletID != "" {
+		return s.walletRepo.CreditWalletByIDTx(ctx, tx, ref.walletID, amount)
+	}
+	return s.walletRepo.CreditWalletTx(ctx, tx, ref.userID, amount)
+}
+
+// applyVersioned reads a wallet without locking it and writes the adjusted balance
+// only if the wallet's version is unchanged. A concurrent modification surfaces as
+// repositories.ErrVersionConflict, which retryTx handles by re-running the operation.
+func (s *WalletService) applyVersioned(ctx context.Context, tx pgx.Tx, ref walletRef, delta money.Amount) (*models.Wallet, error) {
+	wallet, err := s.getWalletTx(ctx, tx, ref)
+	if err != nil {
+		return nil, err
+	}
+	newBalance := wallet.Balance + delta
+	// Funds reserved by holds cannot be spent
+	if newBalance-wallet.HeldAmount < -wallet.OverdraftLimit {
+		return nil, ErrInsufficientBalance
+	}
+	if ref.walletID != "" {
+		err = s.walletRepo.UpdateWalletBalanceByIDTx(ctx, tx, ref.walletID, newBalance, wallet.Version)
+	} else {
+		err = s.walletRepo.UpdateWalletBalanceTx(ctx, tx, ref.userID, newBalance, wallet.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	wallet.Balance = newBalance
+	wallet.Version++
+	return wallet, nil
+}
+
+// withTx runs fn in a transaction begun on db and ends it with finishTx, so it commits
+// when fn succeeds and rolls back when fn fails or panics. A panic is passed on once
+// the transaction is rolled back.
+func withTx(ctx context.Context, db DB, log *logrus.Entry, operation string, fn func(pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			finishTx(ctx, log, tx, operation, fmt.Errorf("%s panicked: %v", operation, p))
+			panic(p)
+		}
+	}()
+	return finishTx(ctx, log, tx, operation, fn(tx))
+}
+
+// finishTx ends a wallet operation's transaction: it commits when the operation
+// succeeded and the caller is still waiting, and rolls back otherwise. Rollback uses
+// a short context detached from the caller's, so a cancelled request still releases
+// its locks promptly. Cancellation is reported as context.Canceled or
+// context.DeadlineExceeded wrapped with the operation name.
+func finishTx(ctx context.Context, log *logrus.Entry, tx pgx.Tx, operation string, err error) error {
+	if err == nil && ctx.Err() != nil {
+		// Never commit work the caller has given up on
+		err = fmt.Errorf("%s aborted before commit: %w", operation, ctx.Err())
+	} else if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		if !errors.Is(err, ctxErr) {
+			err = fmt.Errorf("%w: %w", ctxErr, err)
+		}
+		err = fmt.Errorf("%s aborted: %w", operation, err)
+	}
+
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Operation failed, rolling back transaction")
+		rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+		defer cancel()
+		if rbErr := tx.Rollback(rollbackCtx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			log.WithField("error", rbErr.Error()).Warn("Failed to roll back transaction")
+		}
+		return err
+	}
+
+	log.Info("Operation successful, committing transaction")
+	if commitErr := tx.Commit(ctx); commitErr != nil {
+		log.WithField("error", commitErr.Error()).Error("Failed to commit transaction")
+		return fmt.Errorf("%w: %w", ErrCommitFailed, commitErr)
+	}
+	return nil
+}
+
+// withLogger returns a copy of ctx whose logger also carries the fields of log, so the
+// queries run under it are logged with the operation they belong to
+func withLogger(ctx context.Context, log *logrus.Entry) context.Context {
+	return logger.NewContext(ctx, logger.FromContext(ctx).WithFields(log.Data))
+}
+
+// retryTx runs fn, which must perform one complete database transaction, and re-runs
+// it from scratch when it fails with a serialization failure, deadlock or version conflict. Retries use
+// jittered exponential backoff and give up with ErrTxConflict after retryAttempts tries.
+func (s *WalletService) retryTx(ctx context.Context, log *logrus.Entry, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= s.retryAttempts; attempt++ {
+		err = fn()
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+		if attempt == s.retryAttempts {
+			break
+		}
+
+		backoff := s.retryBaseDelay << (attempt - 1)
+		backoff += time.Duration(rand.Int63n(int64(backoff) + 1))
+		log.WithFields(logrus.Fields{
+			"attempt": attempt,
+			"backoff": backoff.String(),
+			"error":   err.Error(),
+		}).Warn("Transaction conflict, retrying operation")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+
+	log.WithField("attempts", s.retryAttempts).Error("Giving up after repeated transaction conflicts")
+	return fmt.Errorf("%w after %d attempts: %w", ErrTxConflict, s.retryAttempts, err)
+}
+
+// isRetryableTxError reports whether err is a Postgres serialization failure (40001),
+// a deadlock (40P01), an optimistic version conflict or a concurrent request that
+// recorded the same idempotency key first, all of which can succeed when the
+// transaction is simply re-run
+func isRetryableTxError(err error) bool {
+	if errors.Is(err, repositories.ErrVersionConflict) || errors.Is(err, repositories.ErrDuplicateIdempotencyKey) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	// 40001 is serialization_failure and 40P01 is deadlock_detected in Postgres
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
+
+// findIdempotentTransaction looks up the transaction recorded under key for the
+// wallet. It returns nil when key is empty or unused, the recorded transaction when it
+// matches want, and ErrIdempotencyKeyConflict when the key was used for a different
+// operation, amount or counterparty.
+func (s *WalletService) findIdempotentTransaction(ctx context.Context, tx pgx.Tx, ref walletRef, key string, want models.Transaction) (*models.Transaction, error) {
+	if key == "" {
+		return nil, nil
+	}
+	var recorded *models.Transaction
+	var err error
+	if ref.walletID != "" {
+		recorded, err = s.transactionRepo.GetTransactionByWalletIdempotencyKeyTx(ctx, tx, ref.walletID, key)
+	} else {
+		recorded, err = s.transactionRepo.GetTransactionByIdempotencyKeyTx(ctx, tx, ref.userID, key)
+	}
+	if errors.Is(err, repositories.ErrTransactionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recorded.Type != want.Type || recorded.Amount != want.Amount || !sameUser(recorded.RelatedUserID, want.RelatedUserID) {
+		return nil, fmt.Errorf("%w: key %q was recorded for a %s of %s", ErrIdempotencyKeyConflict, key, recorded.Type, recorded.Amount)
+	}
+	return recorded, nil
+}
+
+// sameUser reports whether two optional user IDs are equal
+func sameUser(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// optionalKey returns nil for an empty idempotency key, so it is stored as NULL
+func optionalKey(key string) *string {
+	if key == "" {
+		return nil
+	}
+	return &key
+}
+
+// normalizeDescription strips control characters and surrounding whitespace from a
+// user-supplied description and checks its length. An empty description is not recorded.
+func normalizeDescription(description string) (*string, error) {
+	cleaned := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, description))
+	if cleaned == "" {
+		return nil, nil
+	}
+	if n := utf8.RuneCountInString(cleaned); n > maxDescriptionLength {
+		return nil, fmt.Errorf("%w: description is %d characters, at most %d allowed", ErrInvalidDescription, n, maxDescriptionLength)
+	}
+	return &cleaned, nil
+}
+
+// validateMetadata checks that metadata attached to an operation is within the size
+// limits and has no empty keys
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataKeys {
+		return fmt.Errorf("%w: %d keys, at most %d allowed", ErrInvalidMetadata, len(metadata), maxMetadataKeys)
+	}
+	for key := range metadata {
+		if key == "" {
+			return fmt.Errorf("%w: keys must not be empty", ErrInvalidMetadata)
+		}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	if len(encoded) > maxMetadataSize {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", ErrInvalidMetadata, len(encoded), maxMetadataSize)
+	}
+	return nil
+}
+
+// lockOrder returns the user IDs sorted so that wallet locks are always
+// acquired in the same order
+func lockOrder(userIDs ...string) []string {
+	sorted := append([]string(nil), userIDs...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// ValidateAmount validates that an amount is within the service's configured bounds
+func (s *WalletService) ValidateAmount(amount money.Amount) error {
 	if amount <= 0 {
-		return errors.New("amount must be positive")
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidAmount)
 	}
-	if amount < MIN_AMOUNT {
-		return errors.New("amount must be at least 0.01")
+	if amount < s.minAmount {
+		return fmt.Errorf("%w: amount must be at least %s", ErrInvalidAmount, s.minAmount)
 	}
-	if amount > MAX_AMOUNT {
-		return errors.New("amount exceeds maximum limit")
+	if amount > s.maxAmount {
+		return fmt.Errorf("%w: amount exceeds maximum limit of %s", ErrInvalidAmount, s.maxAmount)
+	}
+	return nil
+}
+
+// checkPrecision returns ErrInvalidAmount when amount has more decimal places than the
+// wallet's currency allows, such as yen amounts with cents. ValidateAmount runs before
+// the wallet is read, so operations call this on the wallet their balance update returns.
+func checkPrecision(wallet *models.Wallet, amount money.Amount) error {
+	if !amount.FitsCurrency(wallet.Currency) {
+		units, _ := money.MinorUnits(wallet.Currency)
+		return fmt.Errorf("%w: %s amounts cannot have more than %d decimal places", ErrInvalidAmount, wallet.Currency, units)
 	}
 	return nil
 }
@@ -322,23 +1593,65 @@ func GetWallet(ctx context.Context, userID string) (*models.Wallet, error) {
 	return defaultService.GetWallet(ctx, userID)
 }
 
-func Transfer(ctx context.Context, fromUserID, toUserID string, amount float64) error {
+func Transfer(ctx context.Context, fromUserID, toUserID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.TransferResult, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Transfer(ctx, fromUserID, toUserID, amount, idempotencyKey, description, metadata)
+}
+
+func Deposit(ctx context.Context, userID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Deposit(ctx, userID, amount, idempotencyKey, description, metadata)
+}
+
+func DepositExternal(ctx context.Context, userID string, amount money.Amount, reference string) (*models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.DepositExternal(ctx, userID, amount, reference)
+}
+
+func Withdraw(ctx context.Context, userID string, amount money.Amount, idempotencyKey, description string, metadata map[string]string) (*models.Wallet, *models.Transaction, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Withdraw(ctx, userID, amount, idempotencyKey, description, metadata)
+}
+
+func Hold(ctx context.Context, userID string, amount money.Amount) (*models.Hold, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Hold(ctx, userID, amount)
+}
+
+func Capture(ctx context.Context, holdID string) (*models.Hold, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.Capture(ctx, holdID)
+}
+
+func Release(ctx context.Context, holdID string) (*models.Hold, error) {
 	if defaultService == nil {
 		panic("default service not initialized - call SetDefaultService first")
 	}
-	return defaultService.Transfer(ctx, fromUserID, toUserID, amount)
+	return defaultService.Release(ctx, holdID)
 }
 
-func Deposit(ctx context.Context, userID string, amount float64) (*models.Wallet, error) {
+func SetOverdraftLimit(ctx context.Context, userID string, limit money.Amount) (*models.Wallet, error) {
 	if defaultService == nil {
 		panic("default service not initialized - call SetDefaultService first")
 	}
-	return defaultService.Deposit(ctx, userID, amount)
+	return defaultService.SetOverdraftLimit(ctx, userID, limit)
 }
 
-func Withdraw(ctx context.Context, userID string, amount float64) (*models.Wallet, error) {
+func Reconcile(ctx context.Context) ([]models.Discrepancy, error) {
 	if defaultService == nil {
 		panic("default service not initialized - call SetDefaultService first")
 	}
-	return defaultService.Withdraw(ctx, userID, amount)
+	return defaultService.Reconcile(ctx)
 }
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"walletapp/internal/logger"
+	"walletapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Wallet status failures, matched with errors.Is
+var (
+	// ErrWalletFrozen is returned by deposits, withdrawals, transfers and holds touching
+	// a frozen wallet, and when freezing a wallet that is already frozen
+	ErrWalletFrozen = errors.New("wallet is frozen")
+	// ErrWalletNotFrozen is returned when unfreezing a wallet that is not frozen
+	ErrWalletNotFrozen = errors.New("wallet is not frozen")
+	// ErrAdminRequired is returned when an audited admin action does not say who performed it
+	ErrAdminRequired = errors.New("acting admin is required")
+	// ErrWalletClosed is returned by every operation touching a closed wallet
+	ErrWalletClosed = errors.New("wallet is closed")
+	// ErrWalletNotEmpty is returned when closing a wallet that still has a balance or held funds
+	ErrWalletNotEmpty = errors.New("wallet is not empty")
+)
+
+// FreezeWallet stops a wallet from depositing, withdrawing, transferring or holding
+// funds until it is unfrozen; its balance can still be read. The freeze is recorded
+// with the reason and performedBy, the acting admin, for the audit trail.
+func (s *WalletService) FreezeWallet(ctx context.Context, userID, reason, performedBy string) (*models.WalletStatusChange, error) {
+	return s.setWalletStatus(ctx, userID, models.WalletStatusFrozen, reason, performedBy)
+}
+
+// UnfreezeWallet lets a frozen wallet move money again, recording the reason and
+// performedBy like FreezeWallet
+func (s *WalletService) UnfreezeWallet(ctx context.Context, userID, reason, performedBy string) (*models.WalletStatusChange, error) {
+	return s.setWalletStatus(ctx, userID, models.WalletStatusActive, reason, performedBy)
+}
+
+func (s *WalletService) setWalletStatus(ctx context.Context, userID string, status models.WalletStatus, reason, performedBy string) (*models.WalletStatusChange, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithFields(logrus.Fields{
+		"operation":    "set_wallet_status",
+		"status":       status,
+		"performed_by": performedBy,
+	})
+	log.Info("Changing wallet status")
+
+	memo, err := normalizeDescription(reason)
+	if err != nil || memo == nil {
+		log.Warn("Wallet status change without a valid reason rejected")
+		return nil, fmt.Errorf("%w: a reason of at most %d characters is required", ErrInvalidReason, maxDescriptionLength)
+	}
+	performedBy = strings.TrimSpace(performedBy)
+	if performedBy == "" {
+		log.Warn("Wallet status change without an acting admin rejected")
+		return nil, ErrAdminRequired
+	}
+
+	var change *models.WalletStatusChange
+	err = s.retryTx(ctx, log, func() (err error) {
+		change, err = s.changeWalletStatus(ctx, log, userID, status, *memo, performedBy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return change, nil
+}
+
+// changeWalletStatus runs a single attempt of setWalletStatus inside its own database transaction
+func (s *WalletService) changeWalletStatus(ctx context.Context, log *logrus.Entry, userID string, status models.WalletStatus, reason, performedBy string) (change *models.WalletStatusChange, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "wallet status change", err); err != nil {
+			change = nil
+		}
+	}()
+
+	if err = s.lockWallets(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+	wallet, err := s.walletRepo.GetWalletForUpdateTx(ctx, tx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+	if wallet.Status == models.WalletStatusClosed {
+		log.Warn("Status change on a closed wallet rejected")
+		return nil, fmt.Errorf("%w: user %s", ErrWalletClosed, userID)
+	}
+	if wallet.Status == status {
+		log.Warn("Wallet already has the requested status")
+		if status == models.WalletStatusFrozen {
+			return nil, fmt.Errorf("%w: user %s", ErrWalletFrozen, userID)
+		}
+		return nil, fmt.Errorf("%w: user %s", ErrWalletNotFrozen, userID)
+	}
+
+	if _, err = s.walletRepo.SetWalletStatusTx(ctx, tx, userID, status); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to update wallet status")
+		return nil, err
+	}
+	change = &models.WalletStatusChange{
+		WalletID:    wallet.ID,
+		Status:      status,
+		Reason:      reason,
+		PerformedBy: performedBy,
+	}
+	if err = s.walletRepo.CreateWalletStatusChangeTx(ctx, tx, change); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to record wallet status change")
+		return nil, err
+	}
+
+	log.Info("Wallet status changed successfully")
+	return change, nil
+}
+
+// CloseWallet permanently closes the wallet of userID. Only a wallet whose balance is
+// exactly zero with nothing held can be closed, and a frozen wallet must be unfrozen
+// first. A closed wallet can no longer deposit, withdraw, transfer or hold funds, but
+// it and its transaction history can still be read.
+func (s *WalletService) CloseWallet(ctx context.Context, userID string) (*models.Wallet, error) {
+	log := logger.WithUser(userID).WithContext(ctx).WithField("operation", "close_wallet")
+	log.Info("Closing wallet")
+
+	var wallet *models.Wallet
+	err := s.retryTx(ctx, log, func() (err error) {
+		wallet, err = s.closeWallet(ctx, log, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateWallets(ctx, userID)
+	return wallet, nil
+}
+
+// closeWallet runs a single attempt of CloseWallet inside its own database transaction
+func (s *WalletService) closeWallet(ctx context.Context, log *logrus.Entry, userID string) (wallet *models.Wallet, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to begin transaction")
+		return nil, err
+	}
+	defer func() {
+		if err = finishTx(ctx, log, tx, "close wallet", err); err != nil {
+			wallet = nil
+		}
+	}()
+
+	if err = s.lockWallets(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to lock wallet")
+		return nil, err
+	}
+	wallet, err = s.walletRepo.GetWalletForUpdateTx(ctx, tx, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Failed to get wallet")
+		return nil, err
+	}
+	if err = checkActive(wallet, defaultWallet(userID)); err != nil {
+		log.Warn("Closing an inactive wallet rejected")
+		return nil, err
+	}
+	if wallet.Balance != 0 || wallet.HeldAmount != 0 {
+		log.WithFields(logrus.Fields{
+			"balance":     wallet.Balance,
+			"held_amount": wallet.HeldAmount,
+		}).Warn("Closing a non-empty wallet rejected")
+		return nil, fmt.Errorf("%w: user %s has a balance of %s with %s held", ErrWalletNotEmpty, userID, wallet.Balance, wallet.HeldAmount)
+	}
+
+	if wallet, err = s.walletRepo.CloseWalletTx(ctx, tx, userID); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to close wallet")
+		return nil, err
+	}
+
+	log.Info("Wallet closed successfully")
+	return wallet, nil
+}
+
+// checkActive returns ErrWalletFrozen or ErrWalletClosed when the wallet ref refers to
+// is frozen or closed. Operations call it on the wallet returned by their balance update,
+// whose row lock keeps the status from changing before they commit.
+func checkActive(wallet *models.Wallet, ref walletRef) error {
+	switch wallet.Status {
+	case models.WalletStatusFrozen:
+		return fmt.Errorf("%w: %s", ErrWalletFrozen, ref)
+	case models.WalletStatusClosed:
+		return fmt.Errorf("%w: %s", ErrWalletClosed, ref)
+	}
+	return nil
+}
+
+func FreezeWallet(ctx context.Context, userID, reason, performedBy string) (*models.WalletStatusChange, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.FreezeWallet(ctx, userID, reason, performedBy)
+}
+
+func UnfreezeWallet(ctx context.Context, userID, reason, performedBy string) (*models.WalletStatusChange, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.UnfreezeWallet(ctx, userID, reason, performedBy)
+}
+
+func CloseWallet(ctx context.Context, userID string) (*models.Wallet, error) {
+	if defaultService == nil {
+		panic("default service not initialized - call SetDefaultService first")
+	}
+	return defaultService.CloseWallet(ctx, userID)
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"walletapp/internal/models"
+	"walletapp/internal/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWalletService_FreezeWallet(t *testing.T) {
+	tests := []struct {
+		name          string
+		current       models.WalletStatus
+		freeze        bool
+		reason        string
+		expectedErrIs error
+	}{
+		{"freeze", models.WalletStatusActive, true, " suspected account takeover ", nil},
+		{"freeze a frozen wallet", models.WalletStatusFrozen, true, "suspected account takeover", ErrWalletFrozen},
+		{"unfreeze", models.WalletStatusFrozen, false, "identity verified", nil},
+		{"unfreeze an active wallet", models.WalletStatusActive, false, "identity verified", ErrWalletNotFrozen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, _, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			walletID := uuid.New()
+			target := models.WalletStatusActive
+			if tt.freeze {
+				target = models.WalletStatusFrozen
+			}
+			mockDB.ExpectBegin()
+			if tt.expectedErrIs == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			mockWalletRepo.On("GetWalletForUpdateTx", mock.Anything, mock.Anything, "user1").
+				Return(&models.Wallet{ID: walletID, Status: tt.current}, nil)
+			mockWalletRepo.On("SetWalletStatusTx", mock.Anything, mock.Anything, "user1", target).
+				Return(&models.Wallet{ID: walletID, Status: target}, nil).Maybe()
+			mockWalletRepo.On("CreateWalletStatusChangeTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.WalletStatusChange")).
+				Return(nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, nil, mockDB, WalletServiceConfig{})
+			var change *models.WalletStatusChange
+			if tt.freeze {
+				change, err = service.FreezeWallet(context.Background(), "user1", tt.reason, "admin-42")
+			} else {
+				change, err = service.UnfreezeWallet(context.Background(), "user1", tt.reason, "admin-42")
+			}
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, change)
+				mockWalletRepo.AssertNotCalled(t, "SetWalletStatusTx", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				if assert.NotNil(t, change) {
+					assert.Equal(t, walletID, change.WalletID)
+					assert.Equal(t, target, change.Status)
+					assert.Equal(t, "admin-42", change.PerformedBy)
+					assert.Equal(t, strings.TrimSpace(tt.reason), change.Reason)
+				}
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_FreezeWallet_Validation(t *testing.T) {
+	service := NewWalletService(nil, nil, nil, WalletServiceConfig{})
+
+	_, err := service.FreezeWallet(context.Background(), "user1", "  ", "admin-42")
+	assert.ErrorIs(t, err, ErrInvalidReason)
+	_, err = service.FreezeWallet(context.Background(), "user1", "suspected fraud", " ")
+	assert.ErrorIs(t, err, ErrAdminRequired)
+}
+
+func TestWalletService_TransferToFrozenRecipient(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).
+		Return(&models.Wallet{ID: uuid.New(), Status: models.WalletStatusActive}, nil).Maybe()
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(1000)).
+		Return(&models.Wallet{ID: uuid.New(), Status: models.WalletStatusFrozen}, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	result, err := service.Transfer(context.Background(), "user1", "user2", 1000, "", "", nil)
+
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+	assert.Contains(t, err.Error(), "user2")
+	assert.Nil(t, result)
+	mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet(), "the sender's debit should be rolled back")
+}
+
+func TestWalletService_FrozenWalletRejectsOperations(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	frozen := &models.Wallet{ID: uuid.New(), Balance: 5000, Status: models.WalletStatusFrozen}
+	for i := 0; i < 4; i++ {
+		mockDB.ExpectBegin()
+		mockDB.ExpectRollback()
+	}
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(frozen, nil)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(frozen, nil)
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(1000)).Return(&models.Wallet{ID: uuid.New()}, nil).Maybe()
+	mockWalletRepo.On("HoldFundsTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(frozen, nil)
+	mockWalletRepo.On("GetWalletByUserID", mock.Anything, "user1").Return(frozen, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	ctx := context.Background()
+	_, _, err = service.Deposit(ctx, "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+	_, _, err = service.Withdraw(ctx, "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+	_, err = service.Transfer(ctx, "user1", "user2", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+	_, err = service.Hold(ctx, "user1", 1000)
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+
+	wallet, err := service.GetWallet(ctx, "user1")
+	assert.NoError(t, err, "a frozen wallet's balance can still be read")
+	assert.Equal(t, money.Amount(5000), wallet.Balance)
+	mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
+
+func TestWalletService_CloseWallet(t *testing.T) {
+	tests := []struct {
+		name          string
+		wallet        models.Wallet
+		expectedErrIs error
+	}{
+		{"empty wallet", models.Wallet{Status: models.WalletStatusActive}, nil},
+		{"remaining balance", models.Wallet{Status: models.WalletStatusActive, Balance: 1}, ErrWalletNotEmpty},
+		{"overdrawn", models.Wallet{Status: models.WalletStatusActive, Balance: -500}, ErrWalletNotEmpty},
+		{"funds on hold", models.Wallet{Status: models.WalletStatusActive, HeldAmount: 500}, ErrWalletNotEmpty},
+		{"frozen", models.Wallet{Status: models.WalletStatusFrozen}, ErrWalletFrozen},
+		{"already closed", models.Wallet{Status: models.WalletStatusClosed}, ErrWalletClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWalletRepo, _, mockDB, err := setupMocks()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mockDB.ExpectBegin()
+			if tt.expectedErrIs == nil {
+				mockDB.ExpectCommit()
+			} else {
+				mockDB.ExpectRollback()
+			}
+			wallet := tt.wallet
+			wallet.ID = uuid.New()
+			mockWalletRepo.On("GetWalletForUpdateTx", mock.Anything, mock.Anything, "user1").Return(&wallet, nil)
+			closedAt := time.Now()
+			mockWalletRepo.On("CloseWalletTx", mock.Anything, mock.Anything, "user1").
+				Return(&models.Wallet{ID: wallet.ID, Status: models.WalletStatusClosed, ClosedAt: &closedAt}, nil).Maybe()
+
+			service := NewWalletService(mockWalletRepo, nil, mockDB, WalletServiceConfig{})
+			closed, err := service.CloseWallet(context.Background(), "user1")
+
+			if tt.expectedErrIs != nil {
+				assert.ErrorIs(t, err, tt.expectedErrIs)
+				assert.Nil(t, closed)
+				mockWalletRepo.AssertNotCalled(t, "CloseWalletTx", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				if assert.NotNil(t, closed) {
+					assert.Equal(t, models.WalletStatusClosed, closed.Status)
+					assert.NotNil(t, closed.ClosedAt)
+				}
+			}
+			assert.NoError(t, mockDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletService_ClosedWalletRejectsOperations(t *testing.T) {
+	mockWalletRepo, mockTxRepo, mockDB, err := setupMocks()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	closed := &models.Wallet{ID: uuid.New(), Status: models.WalletStatusClosed}
+	for i := 0; i < 4; i++ {
+		mockDB.ExpectBegin()
+		mockDB.ExpectRollback()
+	}
+	mockWalletRepo.On("CreditWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(closed, nil)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user1", money.Amount(1000)).Return(closed, nil)
+	mockWalletRepo.On("DebitWalletTx", mock.Anything, mock.Anything, "user2", money.Amount(1000)).
+		Return(&models.Wallet{ID: uuid.New(), Status: models.WalletStatusActive}, nil).Maybe()
+	mockWalletRepo.On("GetWalletForUpdateTx", mock.Anything, mock.Anything, "user1").Return(closed, nil)
+
+	service := NewWalletService(mockWalletRepo, mockTxRepo, mockDB, WalletServiceConfig{})
+	ctx := context.Background()
+	_, _, err = service.Deposit(ctx, "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, _, err = service.Withdraw(ctx, "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, err = service.Transfer(ctx, "user2", "user1", 1000, "", "", nil)
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, err = service.FreezeWallet(ctx, "user1", "suspected fraud", "admin-42")
+	assert.ErrorIs(t, err, ErrWalletClosed, "a closed wallet cannot be frozen")
+
+	mockTxRepo.AssertNotCalled(t, "CreateTransactionTx", mock.Anything, mock.Anything, mock.Anything)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
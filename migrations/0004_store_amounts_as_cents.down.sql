@@ -0,0 +1,7 @@
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE NUMERIC(20,2) USING amount / 100.0;
+
+ALTER TABLE wallets
+    ALTER COLUMN balance DROP DEFAULT,
+    ALTER COLUMN balance TYPE NUMERIC(20,2) USING balance / 100.0,
+    ALTER COLUMN balance SET DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Store monetary values as integer minor units (cents) to avoid rounding drift
+ALTER TABLE wallets
+    ALTER COLUMN balance DROP DEFAULT,
+    ALTER COLUMN balance TYPE BIGINT USING ROUND(balance * 100)::BIGINT,
+    ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100)::BIGINT;
@@ -0,0 +1 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_non_negative;
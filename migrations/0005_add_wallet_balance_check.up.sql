@@ -0,0 +1,3 @@
+-- Last line of defence against negative balances
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_non_negative CHECK (balance >= 0);
@@ -0,0 +1,4 @@
+-- Links the TRANSFER_OUT and TRANSFER_IN legs of a transfer
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS transfer_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_id ON transactions (transfer_id) WHERE transfer_id IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Incremented on every balance change, used for optimistic concurrency control
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
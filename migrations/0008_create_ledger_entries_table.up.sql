@@ -0,0 +1,28 @@
+-- Double-entry ledger. Every operation writes a group of entries whose debits and
+-- credits sum to the same amount. A wallet's balance is its credits minus its debits.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_id UUID NOT NULL, -- shared by the balanced entries of one operation
+    account VARCHAR(20) NOT NULL, -- 'WALLET' or a system account such as 'CASH'
+    wallet_id UUID REFERENCES wallets(id) ON DELETE CASCADE, -- set for 'WALLET' entries only
+    direction VARCHAR(6) NOT NULL, -- 'DEBIT' or 'CREDIT'
+    amount BIGINT NOT NULL, -- stored in cents
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT ledger_entries_amount_positive CHECK (amount > 0),
+    CONSTRAINT ledger_entries_direction_valid CHECK (direction IN ('DEBIT', 'CREDIT')),
+    CONSTRAINT ledger_entries_wallet_account CHECK ((account = 'WALLET') = (wallet_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_wallet_id ON ledger_entries (wallet_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_group_id ON ledger_entries (group_id);
+
+-- Open the ledger with the balances wallets already hold
+WITH opening AS (
+    SELECT uuid_generate_v4() AS group_id, id AS wallet_id, balance
+    FROM wallets
+    WHERE balance > 0
+)
+INSERT INTO ledger_entries (group_id, account, wallet_id, direction, amount)
+SELECT group_id, 'CASH', NULL, 'DEBIT', balance FROM opening
+UNION ALL
+SELECT group_id, 'WALLET', wallet_id, 'CREDIT', balance FROM opening;
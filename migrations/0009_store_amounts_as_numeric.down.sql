@@ -0,0 +1,10 @@
+ALTER TABLE ledger_entries
+    ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100)::BIGINT;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100)::BIGINT;
+
+ALTER TABLE wallets
+    ALTER COLUMN balance DROP DEFAULT,
+    ALTER COLUMN balance TYPE BIGINT USING ROUND(balance * 100)::BIGINT,
+    ALTER COLUMN balance SET DEFAULT 0;
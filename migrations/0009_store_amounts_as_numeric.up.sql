@@ -0,0 +1,12 @@
+-- Store monetary values as exact decimals. The application scans them into
+-- money.Amount (integer cents) through pgtype.Numeric, never through float64.
+ALTER TABLE wallets
+    ALTER COLUMN balance DROP DEFAULT,
+    ALTER COLUMN balance TYPE NUMERIC(18,2) USING balance / 100.0,
+    ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE NUMERIC(18,2) USING amount / 100.0;
+
+ALTER TABLE ledger_entries
+    ALTER COLUMN amount TYPE NUMERIC(18,2) USING amount / 100.0;
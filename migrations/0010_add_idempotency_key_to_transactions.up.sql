@@ -0,0 +1,6 @@
+-- Client-supplied key that makes a retried deposit, withdrawal or transfer a no-op.
+-- Keys are scoped to the wallet that initiated the operation.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_wallet_idempotency_key
+    ON transactions (wallet_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
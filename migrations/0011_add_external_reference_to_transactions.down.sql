@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_external_reference;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS external_reference;
@@ -0,0 +1,6 @@
+-- Payment provider reference (e.g. a PSP charge ID) of an external deposit. Unique, so
+-- a provider notification can only ever be credited once.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS external_reference VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_external_reference
+    ON transactions (external_reference) WHERE external_reference IS NOT NULL;
@@ -0,0 +1,6 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_within_overdraft;
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_non_negative CHECK (balance >= 0);
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_overdraft_limit_non_negative;
+ALTER TABLE wallets DROP COLUMN IF EXISTS overdraft_limit;
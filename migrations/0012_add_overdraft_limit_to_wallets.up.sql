@@ -0,0 +1,10 @@
+-- How far below zero a wallet may go. Ordinary wallets keep the default of 0.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS overdraft_limit NUMERIC(18,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_overdraft_limit_non_negative CHECK (overdraft_limit >= 0);
+
+-- Last line of defence: a balance may only go negative within the wallet's overdraft limit
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_non_negative;
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_within_overdraft CHECK (balance >= -overdraft_limit);
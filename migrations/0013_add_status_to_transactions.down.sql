@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_status_valid;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS status;
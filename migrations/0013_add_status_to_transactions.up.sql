@@ -0,0 +1,6 @@
+-- Lifecycle of a transaction. Synchronous operations are recorded as COMPLETED, while
+-- asynchronous flows record a PENDING row first and finalize it later.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'COMPLETED';
+
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_status_valid CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED'));
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_within_overdraft;
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_within_overdraft CHECK (balance >= -overdraft_limit);
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_held_amount_non_negative;
+ALTER TABLE wallets DROP COLUMN IF EXISTS held_amount;
@@ -0,0 +1,24 @@
+-- Funds reserved by authorization holds. held_amount is the sum of a wallet's ACTIVE
+-- holds; it reduces the available balance without touching the balance or the ledger.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS held_amount NUMERIC(18,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_held_amount_non_negative CHECK (held_amount >= 0);
+
+-- Held funds can be neither spent nor held again
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_within_overdraft;
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_within_overdraft CHECK (balance - held_amount >= -overdraft_limit);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    amount NUMERIC(18,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE', -- 'ACTIVE', 'CAPTURED', 'RELEASED'
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT holds_amount_positive CHECK (amount > 0),
+    CONSTRAINT holds_status_valid CHECK (status IN ('ACTIVE', 'CAPTURED', 'RELEASED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_wallet_id_active ON holds (wallet_id) WHERE status = 'ACTIVE';
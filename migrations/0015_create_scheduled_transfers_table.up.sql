@@ -0,0 +1,20 @@
+-- Transfers requested for a later time and executed by the background worker
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(18,2) NOT NULL,
+    execute_at TIMESTAMPTZ NOT NULL, -- when the next attempt is due
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- 'PENDING', 'COMPLETED', 'FAILED'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT, -- why the latest attempt failed
+    transfer_id UUID, -- the executed transfer, once COMPLETED
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT scheduled_transfers_amount_positive CHECK (amount > 0),
+    CONSTRAINT scheduled_transfers_distinct_users CHECK (from_user_id <> to_user_id),
+    CONSTRAINT scheduled_transfers_status_valid CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers (execute_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_from_user_id ON scheduled_transfers (from_user_id);
@@ -0,0 +1,8 @@
+DELETE FROM scheduled_transfers WHERE status = 'CANCELLED';
+ALTER TABLE scheduled_transfers DROP CONSTRAINT IF EXISTS scheduled_transfers_status_valid;
+ALTER TABLE scheduled_transfers
+    ADD CONSTRAINT scheduled_transfers_status_valid CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED'));
+
+ALTER TABLE scheduled_transfers DROP COLUMN IF EXISTS standing_order_id;
+
+DROP TABLE IF EXISTS standing_orders;
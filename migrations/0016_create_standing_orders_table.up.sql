@@ -0,0 +1,35 @@
+-- Recurring transfers. Each occurrence is executed as a scheduled transfer; when one
+-- finishes, the worker schedules the next until the order's end condition is reached.
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(18,2) NOT NULL,
+    repeat_interval VARCHAR(10) NOT NULL, -- 'DAILY', 'WEEKLY', 'MONTHLY'
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ, -- no occurrence is scheduled after this time
+    max_occurrences INTEGER, -- stop after this many occurrences
+    occurrences INTEGER NOT NULL DEFAULT 0, -- occurrences scheduled so far
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMPTZ NOT NULL, -- when the latest occurrence is due
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE', -- 'ACTIVE', 'COMPLETED', 'CANCELLED', 'FAILED'
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT standing_orders_amount_positive CHECK (amount > 0),
+    CONSTRAINT standing_orders_distinct_users CHECK (from_user_id <> to_user_id),
+    CONSTRAINT standing_orders_repeat_interval_valid CHECK (repeat_interval IN ('DAILY', 'WEEKLY', 'MONTHLY')),
+    CONSTRAINT standing_orders_max_occurrences_positive CHECK (max_occurrences > 0),
+    CONSTRAINT standing_orders_status_valid CHECK (status IN ('ACTIVE', 'COMPLETED', 'CANCELLED', 'FAILED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_standing_orders_from_user_id ON standing_orders (from_user_id);
+
+ALTER TABLE scheduled_transfers
+    ADD COLUMN IF NOT EXISTS standing_order_id UUID REFERENCES standing_orders(id) ON DELETE SET NULL;
+
+-- Occurrences of a cancelled standing order are cancelled rather than executed
+ALTER TABLE scheduled_transfers DROP CONSTRAINT IF EXISTS scheduled_transfers_status_valid;
+ALTER TABLE scheduled_transfers
+    ADD CONSTRAINT scheduled_transfers_status_valid CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED', 'CANCELLED'));
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_standing_order_id ON scheduled_transfers (standing_order_id);
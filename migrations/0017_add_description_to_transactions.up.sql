@@ -0,0 +1,3 @@
+-- Free-text note attached by the user, e.g. "rent for May". Both legs of a transfer
+-- carry the same description.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS description VARCHAR(255);
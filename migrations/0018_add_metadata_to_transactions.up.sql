@@ -0,0 +1,6 @@
+-- Structured data attached by integrators, such as order IDs or invoice numbers
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- Serves lookups of transactions by a metadata value (metadata @> '{"key": "value"}')
+CREATE INDEX IF NOT EXISTS idx_transactions_metadata
+    ON transactions USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS transfer_reversals;
+
+-- Fails while reversal transactions remain, as their types no longer fit
+ALTER TABLE transactions ALTER COLUMN type TYPE VARCHAR(20);
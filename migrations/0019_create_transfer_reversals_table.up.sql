@@ -0,0 +1,11 @@
+-- Room for the TRANSFER_REVERSAL_IN and TRANSFER_REVERSAL_OUT transaction types
+ALTER TABLE transactions ALTER COLUMN type TYPE VARCHAR(30);
+
+-- One row per reversed transfer, with the reason support gave for it. The primary key
+-- makes reversing a transfer twice impossible: a concurrent second reversal waits for
+-- the first to commit and then conflicts.
+CREATE TABLE IF NOT EXISTS transfer_reversals (
+    transfer_id UUID PRIMARY KEY,
+    reason VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- The admin who made a manual adjustment, kept as an audit trail. Adjustments store a
+-- signed amount: positive when they credit the wallet, negative when they debit it.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS performed_by VARCHAR(255);
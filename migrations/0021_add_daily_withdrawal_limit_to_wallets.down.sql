@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_wallet_id_created_at;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_daily_withdrawal_limit_non_negative;
+ALTER TABLE wallets DROP COLUMN IF EXISTS daily_withdrawal_limit;
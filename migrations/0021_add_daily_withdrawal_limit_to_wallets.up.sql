@@ -0,0 +1,8 @@
+-- How much a wallet may withdraw per UTC day. NULL falls back to the configured default.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS daily_withdrawal_limit NUMERIC(18,2);
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_daily_withdrawal_limit_non_negative CHECK (daily_withdrawal_limit >= 0);
+
+-- Serves the sum of a wallet's withdrawals since midnight
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at ON transactions (wallet_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_monthly_transfer_limit_non_negative;
+ALTER TABLE wallets DROP COLUMN IF EXISTS monthly_transfer_limit;
@@ -0,0 +1,6 @@
+-- How much a wallet may transfer out per UTC calendar month, for example a higher limit
+-- for verified users. NULL falls back to the configured default.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS monthly_transfer_limit NUMERIC(18,2);
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_monthly_transfer_limit_non_negative CHECK (monthly_transfer_limit >= 0);
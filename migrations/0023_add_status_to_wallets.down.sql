@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS wallet_status_changes;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_status_valid;
+ALTER TABLE wallets DROP COLUMN IF EXISTS status;
@@ -0,0 +1,17 @@
+-- Frozen wallets keep their balance but cannot deposit, withdraw or transfer
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE';
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_status_valid CHECK (status IN ('ACTIVE', 'FROZEN'));
+
+-- Audit trail of who froze or unfroze a wallet, when and why
+CREATE TABLE IF NOT EXISTS wallet_status_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    performed_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_status_changes_wallet_id ON wallet_status_changes (wallet_id);
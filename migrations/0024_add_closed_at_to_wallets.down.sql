@@ -0,0 +1,7 @@
+UPDATE wallets SET status = 'FROZEN' WHERE status = 'CLOSED';
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_status_valid;
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_status_valid CHECK (status IN ('ACTIVE', 'FROZEN'));
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS closed_at;
@@ -0,0 +1,6 @@
+-- Closed wallets keep their history but can no longer move money
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_status_valid;
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_status_valid CHECK (status IN ('ACTIVE', 'FROZEN', 'CLOSED'));
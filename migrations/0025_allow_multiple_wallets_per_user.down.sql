@@ -0,0 +1,9 @@
+-- Only the default wallets survive going back to one wallet per user
+DELETE FROM wallets WHERE NOT is_default;
+
+DROP INDEX IF EXISTS idx_wallets_user_id_name;
+DROP INDEX IF EXISTS idx_wallets_user_id_default;
+ALTER TABLE wallets ADD CONSTRAINT wallets_user_id_key UNIQUE (user_id);
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS is_default;
+ALTER TABLE wallets DROP COLUMN IF EXISTS name;
@@ -0,0 +1,7 @@
+-- Users may own several named wallets; the default one backs the user-scoped endpoints
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS name VARCHAR(50) NOT NULL DEFAULT 'main';
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_user_id_default ON wallets (user_id) WHERE is_default;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_user_id_name ON wallets (user_id, name);
@@ -0,0 +1,15 @@
+-- Wallets hold a single ISO 4217 currency. Existing wallets, and new wallets that don't
+-- name one, use the base currency, taken from the walletapp.base_currency setting when
+-- it is set (ALTER DATABASE walletdb SET walletapp.base_currency = 'EUR') and USD otherwise.
+DO $$
+DECLARE
+    base_currency CHAR(3) := UPPER(COALESCE(NULLIF(current_setting('walletapp.base_currency', true), ''), 'USD'));
+BEGIN
+    EXECUTE format('ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT %L', base_currency);
+END
+$$;
+
+-- Transactions are in the currency of their wallet
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency CHAR(3);
+UPDATE transactions t SET currency = w.currency FROM wallets w WHERE w.id = t.wallet_id AND t.currency IS NULL;
+ALTER TABLE transactions ALTER COLUMN currency SET NOT NULL;
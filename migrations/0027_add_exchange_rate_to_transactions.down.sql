@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS converted_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS exchange_rate;
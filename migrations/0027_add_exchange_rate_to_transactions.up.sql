@@ -0,0 +1,4 @@
+-- Both legs of a currency exchange record the rate used and the amount the target
+-- wallet received, for audit
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC(18,8);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS converted_amount NUMERIC(18,2);
@@ -0,0 +1,20 @@
+-- Money one user asks another to send. The payer accepts (paying it with an ordinary
+-- transfer) or declines it; the background worker expires it after expires_at.
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- who gets paid
+    payer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(18,2) NOT NULL,
+    message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- 'PENDING', 'PAID', 'DECLINED', 'EXPIRED'
+    transfer_id UUID, -- the transfer that paid it, once PAID
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT payment_requests_amount_positive CHECK (amount > 0),
+    CONSTRAINT payment_requests_distinct_users CHECK (requester_id <> payer_id),
+    CONSTRAINT payment_requests_status_valid CHECK (status IN ('PENDING', 'PAID', 'DECLINED', 'EXPIRED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_requests_payer_id_pending ON payment_requests (payer_id) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_payment_requests_expiry ON payment_requests (expires_at) WHERE status = 'PENDING';
@@ -0,0 +1,19 @@
+-- Transfers a client has checked but not yet confirmed. Confirming a PENDING intent
+-- before expires_at executes the transfer; the background worker expires the rest.
+CREATE TABLE IF NOT EXISTS transfer_intents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(18,2) NOT NULL,
+    fee NUMERIC(18,2) NOT NULL DEFAULT 0, -- the fee quoted when the intent was created
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- 'PENDING', 'CONFIRMED', 'EXPIRED'
+    transfer_id UUID, -- the transfer that executed it, once CONFIRMED
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT transfer_intents_amount_positive CHECK (amount > 0),
+    CONSTRAINT transfer_intents_distinct_users CHECK (from_user_id <> to_user_id),
+    CONSTRAINT transfer_intents_status_valid CHECK (status IN ('PENDING', 'CONFIRMED', 'EXPIRED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_intents_expiry ON transfer_intents (expires_at) WHERE status = 'PENDING';
@@ -0,0 +1,12 @@
+-- A user's saved contacts, so transfers can name a beneficiary instead of a user ID.
+-- Each user can be saved once per owner.
+CREATE TABLE IF NOT EXISTS beneficiaries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    beneficiary_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    nickname VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT beneficiaries_distinct_users CHECK (owner_user_id <> beneficiary_user_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_beneficiaries_owner_beneficiary ON beneficiaries (owner_user_id, beneficiary_user_id);
@@ -0,0 +1,13 @@
+-- Operations that matched a risk rule. Flagged operations still go through; the flags
+-- are kept for review through the admin API.
+CREATE TABLE IF NOT EXISTS risk_flags (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    rule VARCHAR(50) NOT NULL, -- e.g. 'large_amount', 'new_recipients', 'balance_drain'
+    details TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_flags_created_at ON risk_flags (created_at);
+CREATE INDEX IF NOT EXISTS idx_risk_flags_rule_created_at ON risk_flags (rule, created_at);
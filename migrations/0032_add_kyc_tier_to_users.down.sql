@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_kyc_tier_valid;
+
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_tier;
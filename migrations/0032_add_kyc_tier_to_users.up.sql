@@ -0,0 +1,6 @@
+-- How far a user's identity was verified: 0 unverified, 1 basic, 2 full. The tier
+-- decides which row of kyc_tier_limits applies to the user's operations.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_tier SMALLINT NOT NULL DEFAULT 0;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_kyc_tier_valid CHECK (kyc_tier BETWEEN 0 AND 2);
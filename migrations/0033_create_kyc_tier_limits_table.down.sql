@@ -0,0 +1 @@
+DROP TABLE IF EXISTS kyc_tier_limits;
@@ -0,0 +1,15 @@
+-- Limits on the deposits, withdrawals and outgoing transfers of users in each KYC
+-- tier. A NULL limit does not apply; the global amount limits always do.
+CREATE TABLE IF NOT EXISTS kyc_tier_limits (
+    tier SMALLINT PRIMARY KEY,
+    max_single_amount NUMERIC(18,2), -- the largest single operation
+    daily_limit NUMERIC(18,2), -- the total per UTC day
+    monthly_limit NUMERIC(18,2), -- the total per UTC calendar month
+    CONSTRAINT kyc_tier_limits_tier_valid CHECK (tier BETWEEN 0 AND 2)
+);
+
+INSERT INTO kyc_tier_limits (tier, max_single_amount, daily_limit, monthly_limit) VALUES
+    (0, 100.00, NULL, 500.00),
+    (1, 1000.00, 2000.00, 10000.00),
+    (2, NULL, NULL, NULL)
+ON CONFLICT (tier) DO NOTHING;
@@ -0,0 +1,3 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_interest_rate_valid;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS interest_rate_bps;
@@ -0,0 +1,6 @@
+-- Yearly interest paid on a wallet's balance, in basis points (250 is 2.50%). Wallets
+-- earn nothing by default.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS interest_rate_bps INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_interest_rate_valid CHECK (interest_rate_bps BETWEEN 0 AND 10000);
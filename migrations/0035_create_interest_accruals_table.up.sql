@@ -0,0 +1,12 @@
+-- The interest credited to a wallet for a day. The primary key makes the accrual job
+-- credit each wallet at most once per day, however often it runs.
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    accrual_date DATE NOT NULL,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    balance NUMERIC(18,2) NOT NULL,
+    rate_bps INTEGER NOT NULL,
+    amount NUMERIC(18,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, accrual_date)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS referrals;
+
+DROP INDEX IF EXISTS idx_users_referral_code;
+
+ALTER TABLE users DROP COLUMN IF EXISTS referral_code;
@@ -0,0 +1,19 @@
+-- The code each user shares to refer others, generated when the user is created.
+-- Adding the column generates a code for every existing user as well.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code VARCHAR(16) NOT NULL
+    DEFAULT upper(substr(md5(uuid_generate_v4()::text), 1, 10));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_referral_code ON users (referral_code);
+
+-- Who referred whom. The primary key lets a user be referred only once, and nobody can
+-- refer themselves.
+CREATE TABLE IF NOT EXISTS referrals (
+    referred_user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL, -- the referrer's BONUS, NULL when none was paid
+    bonus NUMERIC(18,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT referrals_distinct_users CHECK (referrer_id <> referred_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals (referrer_id, created_at);
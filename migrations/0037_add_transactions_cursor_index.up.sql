@@ -0,0 +1,2 @@
+-- Serves keyset pagination of a wallet's history, which orders by (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at_id ON transactions (wallet_id, created_at DESC, id DESC);
@@ -0,0 +1,3 @@
+-- When the user was deleted, NULL while the account is live. Deleted users keep their
+-- row so their wallets and transactions still have an owner for audit.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,8 @@
+-- Fails while a deleted user's email or username was taken by another user
+DROP INDEX IF EXISTS users_username_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+DROP INDEX IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users ALTER COLUMN deleted_at TYPE TIMESTAMP;
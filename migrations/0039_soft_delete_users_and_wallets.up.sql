@@ -0,0 +1,11 @@
+ALTER TABLE users ALTER COLUMN deleted_at TYPE TIMESTAMPTZ;
+-- When the wallet was deleted, NULL while it is live. Like users, deleted wallets keep
+-- their row so their transactions still point at it.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Only live users need a unique email and username, so a deleted user's can be reused.
+-- The indexes keep the constraints' names, which the repositories match errors on.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (email) WHERE deleted_at IS NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_key ON users (username) WHERE deleted_at IS NULL;
@@ -0,0 +1,7 @@
+-- Emails are stored lowercase from now on; bring existing ones in line
+DROP INDEX IF EXISTS users_email_key;
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
+
+-- Emails that differ only in case belong to the same user. Fails while two live users
+-- have such emails, which have to be resolved by hand first.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (LOWER(email)) WHERE deleted_at IS NULL;
@@ -0,0 +1,55 @@
+-- Back to a single transactions table, with the unique indexes and foreign keys it had
+CREATE TABLE IF NOT EXISTS transactions_unpartitioned (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    amount NUMERIC(18,2) NOT NULL,
+    related_user_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    transfer_id UUID,
+    idempotency_key VARCHAR(255),
+    external_reference VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'COMPLETED',
+    description VARCHAR(255),
+    metadata JSONB,
+    performed_by VARCHAR(255),
+    currency CHAR(3) NOT NULL,
+    exchange_rate NUMERIC(18,8),
+    converted_amount NUMERIC(18,2),
+    CONSTRAINT transactions_status_valid CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED'))
+);
+
+INSERT INTO transactions_unpartitioned (id, wallet_id, type, amount, related_user_id, created_at, updated_at, transfer_id,
+                                        idempotency_key, external_reference, status, description, metadata, performed_by,
+                                        currency, exchange_rate, converted_amount)
+SELECT id, wallet_id, type, amount, related_user_id, created_at, updated_at, transfer_id,
+       idempotency_key, external_reference, status, description, metadata, performed_by,
+       currency, exchange_rate, converted_amount
+FROM transactions;
+
+DROP TABLE transactions;
+DROP FUNCTION IF EXISTS create_transactions_partition(DATE);
+DROP TABLE IF EXISTS transaction_external_references;
+DROP TABLE IF EXISTS transaction_idempotency_keys;
+
+ALTER TABLE transactions_unpartitioned RENAME TO transactions;
+ALTER TABLE transactions RENAME CONSTRAINT transactions_unpartitioned_pkey TO transactions_pkey;
+ALTER TABLE transactions RENAME CONSTRAINT transactions_unpartitioned_wallet_id_fkey TO transactions_wallet_id_fkey;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_id ON transactions (transfer_id) WHERE transfer_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_wallet_idempotency_key
+    ON transactions (wallet_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_external_reference
+    ON transactions (external_reference) WHERE external_reference IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_metadata ON transactions USING GIN (metadata jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at ON transactions (wallet_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at_id ON transactions (wallet_id, created_at DESC, id DESC);
+
+-- Fails while a risk flag, accrual or referral names a transaction that no longer exists
+ALTER TABLE risk_flags
+    ADD CONSTRAINT risk_flags_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE;
+ALTER TABLE interest_accruals
+    ADD CONSTRAINT interest_accruals_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE;
+ALTER TABLE referrals
+    ADD CONSTRAINT referrals_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE SET NULL;
@@ -0,0 +1,145 @@
+-- Partitions transactions by month of created_at, so history and reports over a period
+-- only scan the months they cover. Every step checks what is already done, so a run
+-- that stopped halfway can be repeated.
+
+-- Unique constraints of a partitioned table must include created_at, so the keys that
+-- have to be unique across all months are claimed in their own tables, in the same
+-- statement that records the transaction. They go with the wallet, like its transactions.
+CREATE TABLE IF NOT EXISTS transaction_idempotency_keys (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    PRIMARY KEY (wallet_id, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_external_references (
+    external_reference VARCHAR(255) PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE
+);
+
+-- Set the existing table aside under another name, with the names of its indexes freed
+-- for the partitioned table. Foreign keys cannot point at the partitioned table's
+-- (id, created_at) key, so the tables that referenced transactions(id) keep the IDs
+-- without the constraint.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_class WHERE relname = 'transactions' AND relkind = 'r') THEN
+        ALTER TABLE risk_flags DROP CONSTRAINT IF EXISTS risk_flags_transaction_id_fkey;
+        ALTER TABLE interest_accruals DROP CONSTRAINT IF EXISTS interest_accruals_transaction_id_fkey;
+        ALTER TABLE referrals DROP CONSTRAINT IF EXISTS referrals_transaction_id_fkey;
+
+        ALTER TABLE transactions RENAME TO transactions_unpartitioned;
+        ALTER TABLE transactions_unpartitioned RENAME CONSTRAINT transactions_pkey TO transactions_unpartitioned_pkey;
+        DROP INDEX IF EXISTS idx_transactions_transfer_id;
+        DROP INDEX IF EXISTS idx_transactions_wallet_idempotency_key;
+        DROP INDEX IF EXISTS idx_transactions_external_reference;
+        DROP INDEX IF EXISTS idx_transactions_metadata;
+        DROP INDEX IF EXISTS idx_transactions_wallet_id_created_at;
+        DROP INDEX IF EXISTS idx_transactions_wallet_id_created_at_id;
+    END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id UUID NOT NULL DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    amount NUMERIC(18,2) NOT NULL,
+    related_user_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    transfer_id UUID,
+    idempotency_key VARCHAR(255),
+    external_reference VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'COMPLETED',
+    description VARCHAR(255),
+    metadata JSONB,
+    performed_by VARCHAR(255),
+    currency CHAR(3) NOT NULL,
+    exchange_rate NUMERIC(18,8),
+    converted_amount NUMERIC(18,2),
+    PRIMARY KEY (id, created_at),
+    CONSTRAINT transactions_status_valid CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED'))
+) PARTITION BY RANGE (created_at);
+
+-- Catches transactions of months without a partition of their own until one is made
+CREATE TABLE IF NOT EXISTS transactions_default PARTITION OF transactions DEFAULT;
+
+-- The indexes are made on every partition, including the ones attached later
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_id ON transactions (transfer_id) WHERE transfer_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_idempotency_key
+    ON transactions (wallet_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_external_reference
+    ON transactions (external_reference) WHERE external_reference IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_metadata ON transactions USING GIN (metadata jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at ON transactions (wallet_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at_id ON transactions (wallet_id, created_at DESC, id DESC);
+
+-- Makes the partition for the month of the given day, named transactions_YYYY_MM, and
+-- returns its name. Transactions of the month already caught by the default partition
+-- move into it. Does nothing when the partition exists, so the partition worker can
+-- call it as often as it likes.
+CREATE OR REPLACE FUNCTION create_transactions_partition(in_month DATE) RETURNS TEXT AS $$
+DECLARE
+    start_at TIMESTAMP := date_trunc('month', in_month);
+    end_at TIMESTAMP := date_trunc('month', in_month) + INTERVAL '1 month';
+    partition_name TEXT := 'transactions_' || to_char(in_month, 'YYYY_MM');
+BEGIN
+    -- Serializes the workers of several servers
+    PERFORM pg_advisory_xact_lock(hashtext('create_transactions_partition'));
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN partition_name;
+    END IF;
+
+    EXECUTE format('CREATE TABLE %I (LIKE transactions INCLUDING DEFAULTS INCLUDING CONSTRAINTS)', partition_name);
+    EXECUTE format('WITH moved AS (DELETE FROM transactions_default WHERE created_at >= %L AND created_at < %L RETURNING *)
+                    INSERT INTO %I SELECT * FROM moved', start_at, end_at, partition_name);
+    EXECUTE format('ALTER TABLE transactions ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)', partition_name, start_at, end_at);
+    RETURN partition_name;
+END
+$$ LANGUAGE plpgsql;
+
+-- One partition for every month with transactions, and for this month and the next
+DO $$
+DECLARE
+    month_start DATE;
+BEGIN
+    IF to_regclass('transactions_unpartitioned') IS NOT NULL THEN
+        FOR month_start IN
+            SELECT DISTINCT date_trunc('month', created_at)::date FROM transactions_unpartitioned
+        LOOP
+            PERFORM create_transactions_partition(month_start);
+        END LOOP;
+    END IF;
+    PERFORM create_transactions_partition(CURRENT_DATE);
+    PERFORM create_transactions_partition((CURRENT_DATE + INTERVAL '1 month')::date);
+END
+$$;
+
+-- Move the existing transactions over, claiming their keys. Rows are deleted as they
+-- are copied, so a repeated run only moves what is left.
+DO $$
+BEGIN
+    IF to_regclass('transactions_unpartitioned') IS NOT NULL THEN
+        WITH moved AS (
+            DELETE FROM transactions_unpartitioned RETURNING *
+        ), keys AS (
+            INSERT INTO transaction_idempotency_keys (wallet_id, idempotency_key)
+            SELECT wallet_id, idempotency_key FROM moved WHERE idempotency_key IS NOT NULL
+            ON CONFLICT DO NOTHING
+        ), refs AS (
+            INSERT INTO transaction_external_references (external_reference, wallet_id)
+            SELECT external_reference, wallet_id FROM moved WHERE external_reference IS NOT NULL
+            ON CONFLICT DO NOTHING
+        )
+        INSERT INTO transactions (id, wallet_id, type, amount, related_user_id, created_at, updated_at, transfer_id,
+                                  idempotency_key, external_reference, status, description, metadata, performed_by,
+                                  currency, exchange_rate, converted_amount)
+        SELECT id, wallet_id, type, amount, related_user_id, created_at, updated_at, transfer_id,
+               idempotency_key, external_reference, status, description, metadata, performed_by,
+               currency, exchange_rate, converted_amount
+        FROM moved;
+
+        DROP TABLE transactions_unpartitioned;
+    END IF;
+END
+$$;
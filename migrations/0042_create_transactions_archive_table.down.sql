@@ -0,0 +1,5 @@
+-- Archived transactions go back to transactions before the archive is dropped
+INSERT INTO transactions SELECT * FROM transactions_archive;
+
+DROP TABLE IF EXISTS transaction_archive_runs;
+DROP TABLE IF EXISTS transactions_archive;
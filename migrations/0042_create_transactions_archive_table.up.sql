@@ -0,0 +1,29 @@
+-- Transactions older than the retention, moved out of transactions by the archive job
+-- with every column they had. Not partitioned: it is only read for old history and
+-- whole-life reports.
+CREATE TABLE IF NOT EXISTS transactions_archive (
+    LIKE transactions INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+    PRIMARY KEY (id),
+    FOREIGN KEY (wallet_id) REFERENCES wallets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_archive_wallet_id_created_at_id
+    ON transactions_archive (wallet_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_archive_transfer_id
+    ON transactions_archive (transfer_id) WHERE transfer_id IS NOT NULL;
+
+-- One row per run of the archive job, counting what it moved so far. Each batch adds
+-- to archived in the transaction that moves it, so the count survives a crash, and a
+-- RUNNING run left behind by one is resumed with the same cutoff.
+CREATE TABLE IF NOT EXISTS transaction_archive_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    cutoff TIMESTAMP NOT NULL, -- transactions created before it are archived
+    status VARCHAR(20) NOT NULL DEFAULT 'RUNNING', -- 'RUNNING', 'COMPLETED', 'FAILED'
+    archived BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    CONSTRAINT transaction_archive_runs_status_valid CHECK (status IN ('RUNNING', 'COMPLETED', 'FAILED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_archive_runs_started_at ON transaction_archive_runs (started_at DESC);
@@ -0,0 +1,16 @@
+-- Responses to POST requests sent with an Idempotency-Key header, replayed to retries
+-- of the same request. A record without a status_code is a request still in flight;
+-- records are replaced, and eventually purged, once they are a day old.
+CREATE TABLE IF NOT EXISTS idempotency_records (
+    user_id VARCHAR(255) NOT NULL, -- the user the request acted for, as it named them
+    endpoint VARCHAR(255) NOT NULL, -- the method and route, e.g. 'POST /api/v1/wallets/transfer'
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL, -- hex SHA-256 of the request body
+    status_code INTEGER,
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, endpoint, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_records_created_at ON idempotency_records (created_at);
@@ -0,0 +1,3 @@
+-- A request in flight holds its key only until locked_until, so a key whose request
+-- was lost to a crash or cancellation can be claimed again by a retry
+ALTER TABLE idempotency_records ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ NOT NULL DEFAULT NOW();
@@ -0,0 +1,10 @@
+-- Wrong current passwords given to change a user's password, counted so the endpoint
+-- cannot be used to guess it
+CREATE TABLE IF NOT EXISTS password_change_failures (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Serves the failure limit, which counts a user's recent failures
+CREATE INDEX IF NOT EXISTS idx_password_change_failures_user ON password_change_failures (user_id, created_at);
@@ -0,0 +1,8 @@
+-- The email a verification token was sent to, so it stops verifying the user once
+-- they change their email. Tokens sent before this column cannot tell which email
+-- they went to, so the unused ones are used up; their users can ask for another.
+ALTER TABLE email_verification_tokens ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+UPDATE email_verification_tokens t SET email = u.email, used_at = COALESCE(t.used_at, NOW())
+FROM users u
+WHERE u.id = t.user_id AND t.email IS NULL;
+ALTER TABLE email_verification_tokens ALTER COLUMN email SET NOT NULL;